@@ -0,0 +1,392 @@
+// Package executor runs a generated INSERT plan against a target database,
+// reporting affected rows per batch and the database errors of the first
+// failing source rows.
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"db-importer/generator"
+)
+
+// Mode selects the transaction boundaries used when executing a plan
+type Mode string
+
+const (
+	// ModeTransaction runs every batch inside a single transaction
+	ModeTransaction Mode = "transaction"
+	// ModeBatch commits each batch in its own transaction
+	ModeBatch Mode = "batch"
+)
+
+// Status is the outcome of an execution
+type Status string
+
+const (
+	// StatusExecuted means at least one batch (or the whole plan) was committed
+	StatusExecuted Status = "executed"
+	// StatusRolledBack means nothing was committed
+	StatusRolledBack Status = "rolled_back"
+)
+
+// Defaults applied to zero Options values
+const (
+	DefaultBatchSize = 500
+	DefaultMaxErrors = 10
+)
+
+// Options controls how a plan is executed
+type Options struct {
+	Mode Mode
+	// MaxErrors caps the number of row errors collected (default 10)
+	MaxErrors int
+	// AllowPartial commits the batches that succeeded instead of rolling
+	// everything back. ModeBatch always commits per batch and requires it.
+	AllowPartial bool
+	// OnBatch, when set, is called after each batch has run (Committed is
+	// tentative in ModeTransaction until the transaction commits)
+	OnBatch func(BatchResult)
+}
+
+// RowError is a database error mapped back to a source row
+type RowError struct {
+	// Row is the 1-based source row; 0 when the batch failed as a whole
+	// but no single row reproduced the error (e.g. duplicates inside it)
+	Row     int    `json:"row,omitempty"`
+	Batch   int    `json:"batch"`
+	Message string `json:"message"`
+}
+
+// BatchResult describes one executed batch
+type BatchResult struct {
+	Index        int    `json:"index"`
+	FirstRow     int    `json:"firstRow"` // 1-based
+	RowCount     int    `json:"rowCount"`
+	RowsAffected int64  `json:"rowsAffected"`
+	Committed    bool   `json:"committed"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Result is the report of an execution
+type Result struct {
+	Status       Status        `json:"status"`
+	Mode         Mode          `json:"mode"`
+	RowsAffected int64         `json:"rowsAffected"` // committed rows only
+	Batches      []BatchResult `json:"batches"`
+	Errors       []RowError    `json:"errors,omitempty"`
+	// ErrorsTruncated is set when more failures occurred than MaxErrors
+	ErrorsTruncated bool      `json:"errorsTruncated,omitempty"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationMs      int64     `json:"durationMs"`
+	// PreImages holds, for upsert plans, the rows each committed batch
+	// overwrote as read just before it ran (see InsertPlan.RollbackSQL)
+	PreImages map[int][][]interface{} `json:"-"`
+}
+
+// Committed returns the indexes of the committed batches in execution order
+func (r *Result) Committed() []int {
+	var committed []int
+	for _, b := range r.Batches {
+		if b.Committed {
+			committed = append(committed, b.Index)
+		}
+	}
+	return committed
+}
+
+// Failed reports whether any batch failed
+func (r *Result) Failed() bool {
+	for _, b := range r.Batches {
+		if b.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize fills defaults and validates the options
+func (o Options) Normalize() (Options, error) {
+	if o.Mode == "" {
+		o.Mode = ModeTransaction
+	}
+	if o.MaxErrors <= 0 {
+		o.MaxErrors = DefaultMaxErrors
+	}
+	switch o.Mode {
+	case ModeTransaction:
+	case ModeBatch:
+		if !o.AllowPartial {
+			return o, fmt.Errorf("mode %q commits each batch independently and requires allowPartial", ModeBatch)
+		}
+	default:
+		return o, fmt.Errorf("unknown execution mode %q (expected %q or %q)", o.Mode, ModeTransaction, ModeBatch)
+	}
+	return o, nil
+}
+
+// Execute runs the plan against db. Failures of individual batches are
+// reported in the Result; the returned error is reserved for problems that
+// prevent execution or leave its outcome unknown (e.g. a failed COMMIT).
+func Execute(ctx context.Context, db *sql.DB, plan *generator.InsertPlan, opts Options) (*Result, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Mode: opts.Mode, StartedAt: time.Now(), Batches: []BatchResult{}, PreImages: map[int][][]interface{}{}}
+	if opts.Mode == ModeBatch {
+		err = executePerBatch(ctx, db, plan, opts, result)
+	} else {
+		err = executeInTransaction(ctx, db, plan, opts, result)
+	}
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Status = StatusRolledBack
+	for _, b := range result.Batches {
+		if b.Committed {
+			result.RowsAffected += b.RowsAffected
+		}
+	}
+	if !result.Failed() || anyCommitted(result.Batches) {
+		result.Status = StatusExecuted
+	}
+
+	return result, nil
+}
+
+// executeInTransaction runs every batch under a savepoint of one
+// transaction. After a failure the remaining batches still run so that up
+// to MaxErrors errors are reported, then everything is rolled back unless
+// partial commits are allowed.
+func executeInTransaction(ctx context.Context, db *sql.DB, plan *generator.InsertPlan, opts Options, result *Result) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, batch := range plan.Batches {
+		if !opts.AllowPartial && len(result.Errors) >= opts.MaxErrors {
+			result.ErrorsTruncated = true
+			break
+		}
+
+		br := newBatchResult(i, batch)
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_batch"); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		preImage, execErr := capturePreImage(ctx, tx, plan, i)
+		var res sql.Result
+		if execErr == nil {
+			res, execErr = tx.ExecContext(ctx, batch.SQL)
+		}
+		if execErr == nil {
+			br.RowsAffected, _ = res.RowsAffected()
+			br.Committed = true
+			if preImage != nil {
+				result.PreImages[i] = preImage
+			}
+		} else {
+			br.Error = execErr.Error()
+			if ctx.Err() != nil {
+				// The transaction is rolled back on return
+				result.Batches = append(result.Batches, br)
+				uncommit(result.Batches)
+				return nil
+			}
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_batch"); err != nil {
+				return fmt.Errorf("failed to roll back batch %d: %w", i, err)
+			}
+			if err := diagnoseBatch(ctx, tx, plan, i, batch, execErr, opts, result); err != nil {
+				return err
+			}
+			// Discard the rows inserted while diagnosing
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_batch"); err != nil {
+				return fmt.Errorf("failed to roll back batch %d: %w", i, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_batch"); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+
+		result.Batches = append(result.Batches, br)
+		if opts.OnBatch != nil {
+			opts.OnBatch(br)
+		}
+	}
+
+	if result.Failed() && !opts.AllowPartial {
+		uncommit(result.Batches)
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// executePerBatch commits every batch in its own transaction. Failed
+// batches are diagnosed in a separate transaction that is always rolled back.
+func executePerBatch(ctx context.Context, db *sql.DB, plan *generator.InsertPlan, opts Options, result *Result) error {
+	for i, batch := range plan.Batches {
+		if ctx.Err() != nil {
+			break
+		}
+
+		br := newBatchResult(i, batch)
+		rowsAffected, preImage, execErr := execInTx(ctx, db, plan, i)
+		if execErr == nil {
+			br.RowsAffected = rowsAffected
+			br.Committed = true
+			if preImage != nil {
+				result.PreImages[i] = preImage
+			}
+		} else {
+			br.Error = execErr.Error()
+			if ctx.Err() == nil {
+				if err := diagnoseInTx(ctx, db, plan, i, batch, execErr, opts, result); err != nil {
+					return err
+				}
+			}
+		}
+
+		result.Batches = append(result.Batches, br)
+		if opts.OnBatch != nil {
+			opts.OnBatch(br)
+		}
+	}
+
+	return nil
+}
+
+// execInTx executes a batch in its own committed transaction
+func execInTx(ctx context.Context, db *sql.DB, plan *generator.InsertPlan, index int) (int64, [][]interface{}, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	preImage, err := capturePreImage(ctx, tx, plan, index)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, plan.Batches[index].SQL)
+	if err != nil {
+		return 0, nil, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+
+	return rowsAffected, preImage, tx.Commit()
+}
+
+// capturePreImage reads the rows an upsert batch is about to overwrite,
+// inside the transaction that runs it; plain INSERT batches return nil
+func capturePreImage(ctx context.Context, tx *sql.Tx, plan *generator.InsertPlan, index int) ([][]interface{}, error) {
+	query := plan.PreImageSQL(index)
+	if query == "" {
+		return nil, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing rows: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	preImage := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to read existing rows: %w", err)
+		}
+		preImage = append(preImage, values)
+	}
+	return preImage, rows.Err()
+}
+
+func diagnoseInTx(ctx context.Context, db *sql.DB, plan *generator.InsertPlan, index int, batch generator.InsertBatch, batchErr error, opts Options, result *Result) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	return diagnoseBatch(ctx, tx, plan, index, batch, batchErr, opts, result)
+}
+
+// diagnoseBatch re-runs the rows of a failed batch one by one, each under
+// its own savepoint, and records which rows the database rejects. The
+// caller is responsible for discarding the rows that did get inserted.
+func diagnoseBatch(ctx context.Context, tx *sql.Tx, plan *generator.InsertPlan, index int, batch generator.InsertBatch, batchErr error, opts Options, result *Result) error {
+	found := false
+	for row := batch.FirstRow; row < batch.FirstRow+batch.RowCount; row++ {
+		if len(result.Errors) >= opts.MaxErrors {
+			result.ErrorsTruncated = true
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, plan.RowSQL(row)); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			result.Errors = append(result.Errors, RowError{Row: row + 1, Batch: index, Message: err.Error()})
+			found = true
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return fmt.Errorf("failed to roll back row %d: %w", row+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if !found {
+		result.Errors = append(result.Errors, RowError{Batch: index, Message: batchErr.Error()})
+	}
+	return nil
+}
+
+func newBatchResult(index int, batch generator.InsertBatch) BatchResult {
+	return BatchResult{
+		Index:    index,
+		FirstRow: batch.FirstRow + 1,
+		RowCount: batch.RowCount,
+	}
+}
+
+func anyCommitted(batches []BatchResult) bool {
+	for _, b := range batches {
+		if b.Committed {
+			return true
+		}
+	}
+	return false
+}
+
+func uncommit(batches []BatchResult) {
+	for i := range batches {
+		batches[i].Committed = false
+	}
+}
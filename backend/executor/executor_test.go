@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"db-importer/generator"
+	"db-importer/parser"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "target.db"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	return db
+}
+
+func testPlan(t *testing.T, rows [][]interface{}, batchSize int) *generator.InsertPlan {
+	t.Helper()
+
+	mapping := map[string]string{"col_0": "id", "col_1": "name"}
+	fields := []generator.FieldInfo{{Name: "id", Type: "INTEGER"}, {Name: "name", Type: "TEXT"}}
+	plan := generator.PlanInsert(parser.DialectSQLite, "items", mapping, rows, fields, batchSize)
+	if plan == nil {
+		t.Fatal("Expected a plan")
+	}
+	return plan
+}
+
+func countRows(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return n
+}
+
+func TestExecute_Transaction(t *testing.T) {
+	db := openTestDB(t)
+	plan := testPlan(t, [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}, 2)
+
+	result, err := Execute(context.Background(), db, plan, Options{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Status != StatusExecuted || result.RowsAffected != 3 || len(result.Batches) != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if got := countRows(t, db); got != 3 {
+		t.Errorf("Expected 3 rows, got %d", got)
+	}
+}
+
+func TestExecute_RollsBackAndMapsRows(t *testing.T) {
+	db := openTestDB(t)
+	// Row 3 duplicates row 1 and row 5 has a NULL name
+	plan := testPlan(t, [][]interface{}{{1, "a"}, {2, "b"}, {1, "dup"}, {4, "d"}, {5, nil}, {6, "f"}}, 2)
+
+	result, err := Execute(context.Background(), db, plan, Options{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Status != StatusRolledBack || result.RowsAffected != 0 {
+		t.Errorf("Expected a rollback, got %+v", result)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Row != 3 || result.Errors[1].Row != 5 || result.Errors[1].Batch != 2 {
+		t.Errorf("Expected errors on rows 3 and 5, got %+v", result.Errors)
+	}
+	for _, b := range result.Batches {
+		if b.Committed {
+			t.Errorf("Batch %d should not be committed", b.Index)
+		}
+	}
+	if got := countRows(t, db); got != 0 {
+		t.Errorf("Expected no rows after rollback, got %d", got)
+	}
+}
+
+func TestExecute_AllowPartial(t *testing.T) {
+	db := openTestDB(t)
+	plan := testPlan(t, [][]interface{}{{1, "a"}, {2, "b"}, {1, "dup"}, {4, "d"}, {5, "e"}}, 2)
+
+	for _, mode := range []Mode{ModeTransaction, ModeBatch} {
+		t.Run(string(mode), func(t *testing.T) {
+			if _, err := db.Exec(`DELETE FROM items`); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := Execute(context.Background(), db, plan, Options{Mode: mode, AllowPartial: true})
+			if err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if result.Status != StatusExecuted || result.RowsAffected != 3 {
+				t.Errorf("Expected 3 committed rows, got %+v", result)
+			}
+			if len(result.Errors) != 1 || result.Errors[0].Row != 3 {
+				t.Errorf("Expected an error on row 3, got %+v", result.Errors)
+			}
+			if result.Batches[1].Committed || !result.Batches[2].Committed {
+				t.Errorf("Expected only the failing batch to be skipped, got %+v", result.Batches)
+			}
+			// Rows inserted while diagnosing the failed batch are discarded
+			if got := countRows(t, db); got != 3 {
+				t.Errorf("Expected 3 rows, got %d", got)
+			}
+		})
+	}
+}
+
+func TestExecute_MaxErrors(t *testing.T) {
+	db := openTestDB(t)
+	plan := testPlan(t, [][]interface{}{{1, nil}, {2, nil}, {3, nil}, {4, nil}}, 1)
+
+	result, err := Execute(context.Background(), db, plan, Options{MaxErrors: 2})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(result.Errors) != 2 || !result.ErrorsTruncated {
+		t.Errorf("Expected 2 errors and truncation, got %+v", result)
+	}
+}
+
+func TestExecute_BatchModeRequiresAllowPartial(t *testing.T) {
+	db := openTestDB(t)
+	plan := testPlan(t, [][]interface{}{{1, "a"}}, 1)
+
+	if _, err := Execute(context.Background(), db, plan, Options{Mode: ModeBatch}); err == nil {
+		t.Error("Expected an error for batch mode without allowPartial")
+	}
+	if _, err := Execute(context.Background(), db, plan, Options{Mode: "nope"}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestExecute_UpsertRollback(t *testing.T) {
+	for _, mode := range []Mode{ModeTransaction, ModeBatch} {
+		t.Run(string(mode), func(t *testing.T) {
+			db := openTestDB(t)
+			if _, err := db.Exec(`INSERT INTO items (id, name) VALUES (1, 'old'), (9, 'kept')`); err != nil {
+				t.Fatal(err)
+			}
+
+			mapping := map[string]string{"col_0": "id", "col_1": "name"}
+			fields := []generator.FieldInfo{{Name: "id", Type: "INTEGER", Key: true}, {Name: "name", Type: "TEXT"}}
+			rows := [][]interface{}{{1, "new"}, {2, "b"}, {2, "again"}}
+			plan := generator.PlanUpsert(parser.DialectSQLite, "items", mapping, rows, fields, 2)
+
+			result, err := Execute(context.Background(), db, plan, Options{Mode: mode, AllowPartial: true})
+			if err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if result.Failed() || len(result.PreImages[0]) != 1 || len(result.PreImages[1]) != 1 {
+				t.Fatalf("Expected a pre-image per batch, got %+v", result)
+			}
+
+			rb, err := plan.RollbackSQL(result.Committed(), result.PreImages)
+			if err != nil {
+				t.Fatalf("RollbackSQL failed: %v", err)
+			}
+			if _, err := db.Exec(rb.SQL); err != nil {
+				t.Fatalf("Rollback script failed: %v\n%s", err, rb.SQL)
+			}
+
+			var name string
+			if err := db.QueryRow(`SELECT name FROM items WHERE id = 1`).Scan(&name); err != nil || name != "old" {
+				t.Errorf("Expected row 1 restored to 'old', got %q (%v)", name, err)
+			}
+			if got := countRows(t, db); got != 2 {
+				t.Errorf("Expected the inserted row to be deleted, got %d rows", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"db-importer/parser"
+)
+
+// GenerateCreateTable generates a CREATE TABLE statement for table, quoted
+// for the given SQL dialect. Field types are written as they are; primary
+// keys, unique and auto-increment columns, defaults and foreign keys are
+// kept. Unknown dialects fall back to MySQL style.
+func GenerateCreateTable(dialect parser.Dialect, table parser.Table) string {
+	primaryKey := table.PrimaryKey()
+
+	// SQLite only auto-increments an INTEGER PRIMARY KEY declared inline
+	inlineKey := dialect == parser.DialectSQLite && len(primaryKey) == 1
+
+	var lines []string
+	for _, f := range table.Fields {
+		line := "  " + escapeIdentifierForDialect(f.Name, dialect) + " " + f.Type
+		if inlineKey && f.PrimaryKey {
+			line += " PRIMARY KEY"
+			if f.AutoIncrement {
+				line += " AUTOINCREMENT"
+			}
+		}
+		if !f.Nullable || f.PrimaryKey {
+			line += " NOT NULL"
+		}
+		if f.Default != nil {
+			line += " DEFAULT " + *f.Default
+		}
+		if f.AutoIncrement {
+			switch dialect {
+			case parser.DialectPostgres:
+				line += " GENERATED BY DEFAULT AS IDENTITY"
+			case parser.DialectSQLServer:
+				line += " IDENTITY(1,1)"
+			case parser.DialectSQLite:
+				// Declared with the inline primary key
+			default:
+				line += " AUTO_INCREMENT"
+			}
+		}
+		if f.Unique && !f.PrimaryKey {
+			line += " UNIQUE"
+		}
+		lines = append(lines, line)
+	}
+
+	if len(primaryKey) > 0 && !inlineKey {
+		lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", quoteIdentifiers(primaryKey, dialect)))
+	}
+
+	for _, fk := range table.ForeignKeys {
+		line := "  "
+		if fk.Name != "" {
+			line += "CONSTRAINT " + escapeIdentifierForDialect(fk.Name, dialect) + " "
+		}
+		line += fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)",
+			quoteIdentifiers(fk.Columns, dialect),
+			escapeIdentifierForDialect(fk.RefTable, dialect),
+			quoteIdentifiers(fk.RefColumns, dialect))
+		if fk.OnDelete != "" {
+			line += " ON DELETE " + fk.OnDelete
+		}
+		if fk.OnUpdate != "" {
+			line += " ON UPDATE " + fk.OnUpdate
+		}
+		lines = append(lines, line)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n",
+		escapeIdentifierForDialect(table.Name, dialect), strings.Join(lines, ",\n"))
+}
+
+// quoteIdentifiers quotes and joins column names for a dialect
+func quoteIdentifiers(names []string, dialect parser.Dialect) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = escapeIdentifierForDialect(name, dialect)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"testing"
+
+	"db-importer/parser"
+)
+
+func createTable() parser.Table {
+	draft := "'draft'"
+	return parser.Table{
+		Name: "orders",
+		Fields: []parser.Field{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true, AutoIncrement: true},
+			{Name: "customer_id", Type: "INTEGER"},
+			{Name: "reference", Type: "VARCHAR(32)", Unique: true},
+			{Name: "status", Type: "VARCHAR(16)", Default: &draft},
+			{Name: "note", Type: "TEXT", Nullable: true},
+		},
+		ForeignKeys: []parser.ForeignKey{
+			{Name: "fk_orders_customer", Columns: []string{"customer_id"}, RefTable: "customers", RefColumns: []string{"id"}, OnDelete: "CASCADE"},
+		},
+	}
+}
+
+func TestGenerateCreateTable_Dialects(t *testing.T) {
+	tests := []struct {
+		dialect  parser.Dialect
+		expected string
+	}{
+		{parser.DialectMySQL, "CREATE TABLE `orders` (\n" +
+			"  `id` INTEGER NOT NULL AUTO_INCREMENT,\n" +
+			"  `customer_id` INTEGER NOT NULL,\n" +
+			"  `reference` VARCHAR(32) NOT NULL UNIQUE,\n" +
+			"  `status` VARCHAR(16) NOT NULL DEFAULT 'draft',\n" +
+			"  `note` TEXT,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  CONSTRAINT `fk_orders_customer` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE\n" +
+			");\n"},
+		{parser.DialectPostgres, "CREATE TABLE \"orders\" (\n" +
+			"  \"id\" INTEGER NOT NULL GENERATED BY DEFAULT AS IDENTITY,\n" +
+			"  \"customer_id\" INTEGER NOT NULL,\n" +
+			"  \"reference\" VARCHAR(32) NOT NULL UNIQUE,\n" +
+			"  \"status\" VARCHAR(16) NOT NULL DEFAULT 'draft',\n" +
+			"  \"note\" TEXT,\n" +
+			"  PRIMARY KEY (\"id\"),\n" +
+			"  CONSTRAINT \"fk_orders_customer\" FOREIGN KEY (\"customer_id\") REFERENCES \"customers\" (\"id\") ON DELETE CASCADE\n" +
+			");\n"},
+		{parser.DialectSQLServer, "CREATE TABLE [orders] (\n" +
+			"  [id] INTEGER NOT NULL IDENTITY(1,1),\n" +
+			"  [customer_id] INTEGER NOT NULL,\n" +
+			"  [reference] VARCHAR(32) NOT NULL UNIQUE,\n" +
+			"  [status] VARCHAR(16) NOT NULL DEFAULT 'draft',\n" +
+			"  [note] TEXT,\n" +
+			"  PRIMARY KEY ([id]),\n" +
+			"  CONSTRAINT [fk_orders_customer] FOREIGN KEY ([customer_id]) REFERENCES [customers] ([id]) ON DELETE CASCADE\n" +
+			");\n"},
+		{parser.DialectSQLite, "CREATE TABLE \"orders\" (\n" +
+			"  \"id\" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,\n" +
+			"  \"customer_id\" INTEGER NOT NULL,\n" +
+			"  \"reference\" VARCHAR(32) NOT NULL UNIQUE,\n" +
+			"  \"status\" VARCHAR(16) NOT NULL DEFAULT 'draft',\n" +
+			"  \"note\" TEXT,\n" +
+			"  CONSTRAINT \"fk_orders_customer\" FOREIGN KEY (\"customer_id\") REFERENCES \"customers\" (\"id\") ON DELETE CASCADE\n" +
+			");\n"},
+	}
+
+	for _, tt := range tests {
+		if got := GenerateCreateTable(tt.dialect, createTable()); got != tt.expected {
+			t.Errorf("dialect %s:\ngot:\n%s\nwant:\n%s", tt.dialect, got, tt.expected)
+		}
+	}
+}
+
+func TestGenerateCreateTable_CompositeKey(t *testing.T) {
+	table := parser.Table{
+		Name: "order_lines",
+		Fields: []parser.Field{
+			{Name: "order_id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "line", Type: "INTEGER", PrimaryKey: true},
+		},
+	}
+
+	expected := "CREATE TABLE \"order_lines\" (\n" +
+		"  \"order_id\" INTEGER NOT NULL,\n" +
+		"  \"line\" INTEGER NOT NULL,\n" +
+		"  PRIMARY KEY (\"order_id\", \"line\")\n" +
+		");\n"
+	if got := GenerateCreateTable(parser.DialectSQLite, table); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestGenerateCreateTable_RoundTrip(t *testing.T) {
+	table := createTable()
+	table.ForeignKeys = nil
+	table.Fields[0].AutoIncrement = false
+
+	sql := GenerateCreateTable(parser.DialectMySQL, table)
+	result, err := parser.DefaultRegistry().Parse(sql, parser.ParseOptions{Dialect: parser.DialectMySQL})
+	if err != nil {
+		t.Fatalf("Failed to parse generated SQL: %v\n%s", err, sql)
+	}
+	if len(result.Tables) != 1 || result.Tables[0].Name != table.Name || len(result.Tables[0].Fields) != len(table.Fields) {
+		t.Fatalf("Unexpected tables %+v", result.Tables)
+	}
+	for i, f := range result.Tables[0].Fields {
+		want := table.Fields[i]
+		if f.Name != want.Name || f.Nullable != want.Nullable {
+			t.Errorf("Field %d = %+v, want %+v", i, f, want)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"db-importer/parser"
 )
 
 // FieldInfo contains information about a database field
@@ -13,13 +15,94 @@ type FieldInfo struct {
 	Name     string
 	Type     string
 	Nullable bool
+	Key      bool // part of the primary or a unique key, identifies rows
 }
 
 // GenerateInsertSQL generates INSERT statements from the mapping and data
+// using MySQL/MariaDB quoting
 func GenerateInsertSQL(tableName string, mapping map[string]string, rows [][]interface{}, fields []FieldInfo) string {
-	if len(rows) == 0 {
+	return GenerateInsertSQLForDialect(parser.DialectMySQL, tableName, mapping, rows, fields)
+}
+
+// GenerateInsertSQLForDialect generates INSERT statements quoted and escaped
+// for the given SQL dialect (unknown dialects fall back to MySQL style)
+func GenerateInsertSQLForDialect(dialect parser.Dialect, tableName string, mapping map[string]string, rows [][]interface{}, fields []FieldInfo) string {
+	plan := PlanInsert(dialect, tableName, mapping, rows, fields, len(rows))
+	if plan == nil {
 		return ""
 	}
+	return plan.Batches[0].SQL
+}
+
+// InsertPlan is a generated INSERT split into batches of rows, keeping each
+// row's VALUES tuple so single rows can be re-run on their own
+type InsertPlan struct {
+	Batches []InsertBatch
+
+	dialect parser.Dialect
+	table   string      // quoted table name
+	columns []string    // quoted column names
+	fields  []FieldInfo // target field of each column
+	upsert  bool
+	prefix  string     // INSERT INTO ... VALUES
+	suffix  string     // conflict clause of upserts
+	cells   [][]string // formatted literals of every source row
+	values  []string   // one "(...)" tuple per source row
+}
+
+// InsertBatch is one multi-row INSERT statement covering rows
+// [FirstRow, FirstRow+RowCount) of the source data (0-based)
+type InsertBatch struct {
+	SQL      string
+	FirstRow int
+	RowCount int
+}
+
+// RowCount returns the number of source rows in the plan
+func (p *InsertPlan) RowCount() int {
+	return len(p.values)
+}
+
+// RowSQL returns a single-row INSERT for the 0-based source row
+func (p *InsertPlan) RowSQL(row int) string {
+	return p.prefix + p.values[row] + p.suffix + ";"
+}
+
+// Upsert reports whether the plan updates rows that already exist
+func (p *InsertPlan) Upsert() bool {
+	return p.upsert
+}
+
+// KeyColumns returns the names of the inserted key columns
+func (p *InsertPlan) KeyColumns() []string {
+	var keys []string
+	for _, f := range p.fields {
+		if f.Key {
+			keys = append(keys, f.Name)
+		}
+	}
+	return keys
+}
+
+// PlanInsert generates INSERT statements of at most batchSize rows each
+// (batchSize <= 0 puts every row into one statement). It returns nil when
+// there are no rows or no mapped columns.
+func PlanInsert(dialect parser.Dialect, tableName string, mapping map[string]string, rows [][]interface{}, fields []FieldInfo, batchSize int) *InsertPlan {
+	return planStatements(dialect, tableName, mapping, rows, fields, batchSize, false)
+}
+
+// PlanUpsert is PlanInsert for statements that update the existing row
+// when a row with the same key is already present: ON CONFLICT for
+// PostgreSQL and SQLite, ON DUPLICATE KEY UPDATE for MySQL and MERGE for
+// SQL Server. It also returns nil when no mapped field is a key.
+func PlanUpsert(dialect parser.Dialect, tableName string, mapping map[string]string, rows [][]interface{}, fields []FieldInfo, batchSize int) *InsertPlan {
+	return planStatements(dialect, tableName, mapping, rows, fields, batchSize, true)
+}
+
+func planStatements(dialect parser.Dialect, tableName string, mapping map[string]string, rows [][]interface{}, fields []FieldInfo, batchSize int, upsert bool) *InsertPlan {
+	if len(rows) == 0 {
+		return nil
+	}
 
 	// Extract database columns in order from fields array (preserves Excel column order)
 	var dbColumns []string
@@ -40,38 +123,116 @@ func GenerateInsertSQL(tableName string, mapping map[string]string, rows [][]int
 	}
 
 	if len(dbColumns) == 0 {
-		return ""
+		return nil
 	}
 
 	// Build column list with proper escaping
 	var escapedColumns []string
 	for _, col := range dbColumns {
-		escapedColumns = append(escapedColumns, escapeIdentifier(col))
+		escapedColumns = append(escapedColumns, escapeIdentifierForDialect(col, dialect))
 	}
 	columnList := strings.Join(escapedColumns, ", ")
 
-	// Build INSERT statement
-	var sql strings.Builder
-	sql.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", escapeIdentifier(tableName), columnList))
+	plan := &InsertPlan{
+		dialect: dialect,
+		table:   escapeIdentifierForDialect(tableName, dialect),
+		columns: escapedColumns,
+		fields:  columnFields,
+		upsert:  upsert,
+	}
+	plan.prefix = fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", plan.table, columnList)
+	if upsert {
+		if len(plan.KeyColumns()) == 0 {
+			return nil
+		}
+		plan.prefix, plan.suffix = plan.upsertClauses()
+	}
 
 	// Build values
-	var valueRows []string
 	for _, row := range rows {
 		var values []string
 		for i, cell := range row {
 			if i < len(columnFields) {
-				values = append(values, formatValueByType(cell, columnFields[i].Type))
+				values = append(values, formatValueForDialect(cell, columnFields[i].Type, dialect))
 			} else {
-				values = append(values, formatValueByType(cell, "varchar"))
+				values = append(values, formatValueForDialect(cell, "varchar", dialect))
 			}
 		}
-		valueRows = append(valueRows, fmt.Sprintf("(%s)", strings.Join(values, ", ")))
+		plan.cells = append(plan.cells, values)
+		plan.values = append(plan.values, fmt.Sprintf("(%s)", strings.Join(values, ", ")))
 	}
 
-	sql.WriteString(strings.Join(valueRows, ",\n"))
-	sql.WriteString(";")
+	if batchSize <= 0 {
+		batchSize = len(rows)
+	}
+	for first := 0; first < len(plan.values); first += batchSize {
+		last := min(first+batchSize, len(plan.values))
+		plan.Batches = append(plan.Batches, InsertBatch{
+			SQL:      plan.prefix + strings.Join(plan.values[first:last], ",\n") + plan.suffix + ";",
+			FirstRow: first,
+			RowCount: last - first,
+		})
+	}
 
-	return sql.String()
+	return plan
+}
+
+// upsertClauses returns the text before and after the VALUES tuples of an
+// upsert. Key columns are never updated; when every column is a key the
+// existing row is kept as it is.
+func (p *InsertPlan) upsertClauses() (string, string) {
+	var keys, updates []string
+	for i, f := range p.fields {
+		if f.Key {
+			keys = append(keys, p.columns[i])
+		} else {
+			updates = append(updates, p.columns[i])
+		}
+	}
+	columnList := strings.Join(p.columns, ", ")
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", p.table, columnList)
+
+	switch p.dialect {
+	case parser.DialectPostgres, parser.DialectSQLite:
+		if len(updates) == 0 {
+			return prefix, fmt.Sprintf("\nON CONFLICT (%s) DO NOTHING", strings.Join(keys, ", "))
+		}
+		set := make([]string, len(updates))
+		for i, col := range updates {
+			set[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+		}
+		return prefix, fmt.Sprintf("\nON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(set, ", "))
+
+	case parser.DialectSQLServer:
+		on := make([]string, len(keys))
+		for i, col := range keys {
+			on[i] = fmt.Sprintf("target.%s = source.%s", col, col)
+		}
+		sourceColumns := make([]string, len(p.columns))
+		for i, col := range p.columns {
+			sourceColumns[i] = "source." + col
+		}
+		suffix := fmt.Sprintf("\n) AS source (%s)\nON %s", columnList, strings.Join(on, " AND "))
+		if len(updates) > 0 {
+			set := make([]string, len(updates))
+			for i, col := range updates {
+				set[i] = fmt.Sprintf("target.%s = source.%s", col, col)
+			}
+			suffix += "\nWHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", ")
+		}
+		suffix += fmt.Sprintf("\nWHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", columnList, strings.Join(sourceColumns, ", "))
+		return fmt.Sprintf("MERGE INTO %s AS target\nUSING (VALUES\n", p.table), suffix
+
+	default:
+		if len(updates) == 0 {
+			return prefix, fmt.Sprintf("\nON DUPLICATE KEY UPDATE %s = %s", keys[0], keys[0])
+		}
+		set := make([]string, len(updates))
+		for i, col := range updates {
+			set[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+		}
+		return prefix, "\nON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
 }
 
 // escapeIdentifier escapes table and column names
@@ -83,8 +244,27 @@ func escapeIdentifier(identifier string) string {
 	return "`" + identifier + "`"
 }
 
-// formatValueByType formats a value based on its SQL type
+// escapeIdentifierForDialect quotes table and column names for a dialect
+func escapeIdentifierForDialect(identifier string, dialect parser.Dialect) string {
+	switch dialect {
+	case parser.DialectPostgres, parser.DialectSQLite:
+		identifier = strings.Trim(identifier, "`\"[]")
+		return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+	case parser.DialectSQLServer:
+		identifier = strings.Trim(identifier, "`\"[]")
+		return "[" + strings.ReplaceAll(identifier, "]", "]]") + "]"
+	default:
+		return escapeIdentifier(identifier)
+	}
+}
+
+// formatValueByType formats a value based on its SQL type (MySQL style)
 func formatValueByType(value interface{}, sqlType string) string {
+	return formatValueForDialect(value, sqlType, parser.DialectMySQL)
+}
+
+// formatValueForDialect formats a value based on its SQL type and dialect
+func formatValueForDialect(value interface{}, sqlType string, dialect parser.Dialect) string {
 	// Handle NULL values
 	if value == nil {
 		return "NULL"
@@ -110,12 +290,17 @@ func formatValueByType(value interface{}, sqlType string) string {
 	if isNumericType(sqlType) {
 		return formatNumericValue(strValue)
 	} else if isBooleanType(sqlType) {
+		if dialect == parser.DialectSQLServer {
+			return formatBitValue(strValue)
+		}
 		return formatBooleanValue(strValue)
 	} else if isDateTimeType(sqlType) {
 		return formatDateTimeValue(strValue)
-	} else {
-		// Default to string formatting
+	} else if dialect == parser.DialectMySQL || dialect == parser.DialectUnknown {
 		return formatStringValue(strValue)
+	} else {
+		// Standard SQL strings: backslashes and newlines are literal
+		return formatStandardStringValue(strValue)
 	}
 }
 
@@ -181,6 +366,18 @@ func formatBooleanValue(value string) string {
 	}
 }
 
+// formatBitValue formats boolean values as 1/0 for dialects without TRUE/FALSE
+func formatBitValue(value string) string {
+	switch formatBooleanValue(value) {
+	case "TRUE":
+		return "1"
+	case "FALSE":
+		return "0"
+	default:
+		return "NULL"
+	}
+}
+
 // formatDateTimeValue formats date/time values
 func formatDateTimeValue(value string) string {
 	value = strings.TrimSpace(value)
@@ -233,39 +430,57 @@ func formatStringValue(value string) string {
 	return fmt.Sprintf("'%s'", value)
 }
 
+// formatStandardStringValue formats string values for dialects that follow
+// standard SQL quoting, where only single quotes need escaping
+func formatStandardStringValue(value string) string {
+	value = strings.ReplaceAll(value, "\x00", "")
+	value = strings.ReplaceAll(value, "'", "''")
+	return fmt.Sprintf("'%s'", value)
+}
+
 // ValidateFieldTypes validates that data matches field constraints
 func ValidateFieldTypes(rows [][]interface{}, fields []FieldInfo, mapping map[string]string) []string {
 	var errors []string
 
 	for rowIdx, row := range rows {
-		for colIdx, cell := range row {
-			if colIdx >= len(fields) {
-				continue
-			}
+		errors = append(errors, ValidateRow(rowIdx+1, row, fields)...)
+	}
 
-			field := fields[colIdx]
+	return errors
+}
 
-			// Check NOT NULL constraint
-			cellStr := fmt.Sprintf("%v", cell)
-			trimmedCell := strings.TrimSpace(cellStr)
-			isNullValue := cell == nil || trimmedCell == "" || strings.EqualFold(trimmedCell, "null")
+// ValidateRow validates a single data row; rowNumber is the 1-based row
+// number used in the messages
+func ValidateRow(rowNumber int, row []interface{}, fields []FieldInfo) []string {
+	var errors []string
 
-			// For date/time fields, also treat "0" and invalid date strings as NULL
-			if isDateTimeType(field.Type) {
-				if trimmedCell == "0" || trimmedCell == "0000-00-00" || trimmedCell == "0000-00-00 00:00:00" {
-					isNullValue = true
-				}
-			}
+	for colIdx, cell := range row {
+		if colIdx >= len(fields) {
+			continue
+		}
 
-			if !field.Nullable && isNullValue {
-				errors = append(errors, fmt.Sprintf("Row %d: Field '%s' cannot be NULL", rowIdx+1, field.Name))
+		field := fields[colIdx]
+
+		// Check NOT NULL constraint
+		cellStr := fmt.Sprintf("%v", cell)
+		trimmedCell := strings.TrimSpace(cellStr)
+		isNullValue := cell == nil || trimmedCell == "" || strings.EqualFold(trimmedCell, "null")
+
+		// For date/time fields, also treat "0" and invalid date strings as NULL
+		if isDateTimeType(field.Type) {
+			if trimmedCell == "0" || trimmedCell == "0000-00-00" || trimmedCell == "0000-00-00 00:00:00" {
+				isNullValue = true
 			}
+		}
+
+		if !field.Nullable && isNullValue {
+			errors = append(errors, fmt.Sprintf("Row %d: Field '%s' cannot be NULL", rowNumber, field.Name))
+		}
 
-			// Validate type (skip if NULL)
-			if !isNullValue {
-				if err := validateValueType(cell, field.Type); err != nil {
-					errors = append(errors, fmt.Sprintf("Row %d, Field '%s': %s", rowIdx+1, field.Name, err.Error()))
-				}
+		// Validate type (skip if NULL)
+		if !isNullValue {
+			if err := validateValueType(cell, field.Type); err != nil {
+				errors = append(errors, fmt.Sprintf("Row %d, Field '%s': %s", rowNumber, field.Name, err.Error()))
 			}
 		}
 	}
@@ -3,6 +3,8 @@ package generator
 import (
 	"strings"
 	"testing"
+
+	"db-importer/parser"
 )
 
 func TestGenerateInsertSQL_BasicInsert(t *testing.T) {
@@ -435,3 +437,88 @@ func TestGenerateInsertSQL_RealWorldScenario(t *testing.T) {
 		t.Error("Row 3 formatting incorrect")
 	}
 }
+
+func TestGenerateInsertSQLForDialect_Quoting(t *testing.T) {
+	mapping := map[string]string{"col_0": "id", "col_1": "note", "col_2": "active"}
+	fields := []FieldInfo{
+		{Name: "id", Type: "INT", Nullable: false},
+		{Name: "note", Type: "TEXT", Nullable: true},
+		{Name: "active", Type: "BOOLEAN", Nullable: true},
+	}
+	rows := [][]interface{}{{1, `C:\temp`, "yes"}}
+
+	tests := []struct {
+		dialect  parser.Dialect
+		expected string
+	}{
+		{parser.DialectMySQL, "INSERT INTO `notes` (`id`, `note`, `active`) VALUES\n(1, 'C:\\\\temp', TRUE);"},
+		{parser.DialectPostgres, "INSERT INTO \"notes\" (\"id\", \"note\", \"active\") VALUES\n(1, 'C:\\temp', TRUE);"},
+		{parser.DialectSQLServer, "INSERT INTO [notes] ([id], [note], [active]) VALUES\n(1, 'C:\\temp', 1);"},
+	}
+
+	for _, tt := range tests {
+		sql := GenerateInsertSQLForDialect(tt.dialect, "notes", mapping, rows, fields)
+		if sql != tt.expected {
+			t.Errorf("dialect %s:\ngot:  %s\nwant: %s", tt.dialect, sql, tt.expected)
+		}
+	}
+}
+
+func TestPlanInsert_Batches(t *testing.T) {
+	mapping := map[string]string{"col_0": "id", "col_1": "name"}
+	fields := []FieldInfo{
+		{Name: "id", Type: "INT"},
+		{Name: "name", Type: "VARCHAR(50)"},
+	}
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}, {4, "d"}, {5, "e"}}
+
+	plan := PlanInsert(parser.DialectPostgres, "items", mapping, rows, fields, 2)
+	if plan == nil {
+		t.Fatal("Expected a plan")
+	}
+	if plan.RowCount() != 5 || len(plan.Batches) != 3 {
+		t.Fatalf("Expected 5 rows in 3 batches, got %d rows in %d batches", plan.RowCount(), len(plan.Batches))
+	}
+
+	last := plan.Batches[2]
+	if last.FirstRow != 4 || last.RowCount != 1 {
+		t.Errorf("Unexpected last batch bounds: %+v", last)
+	}
+	if plan.Batches[1].SQL != "INSERT INTO \"items\" (\"id\", \"name\") VALUES\n(3, 'c'),\n(4, 'd');" {
+		t.Errorf("Unexpected batch SQL: %s", plan.Batches[1].SQL)
+	}
+	if plan.RowSQL(3) != "INSERT INTO \"items\" (\"id\", \"name\") VALUES\n(4, 'd');" {
+		t.Errorf("Unexpected row SQL: %s", plan.RowSQL(3))
+	}
+
+	// A single batch matches GenerateInsertSQLForDialect
+	single := PlanInsert(parser.DialectPostgres, "items", mapping, rows, fields, 0)
+	if len(single.Batches) != 1 || single.Batches[0].SQL != GenerateInsertSQLForDialect(parser.DialectPostgres, "items", mapping, rows, fields) {
+		t.Error("Expected one batch identical to the unbatched SQL")
+	}
+
+	if PlanInsert(parser.DialectPostgres, "items", map[string]string{}, rows, fields, 2) != nil {
+		t.Error("Expected nil plan without mapped columns")
+	}
+}
+
+func TestValidateRow_UsesRowNumber(t *testing.T) {
+	fields := []FieldInfo{
+		{Name: "id", Type: "INT", Nullable: false},
+		{Name: "email", Type: "VARCHAR(50)", Nullable: false},
+	}
+
+	if errs := ValidateRow(7, []interface{}{1, "a@example.com"}, fields); len(errs) != 0 {
+		t.Errorf("Expected a valid row, got %v", errs)
+	}
+
+	errs := ValidateRow(7, []interface{}{"x", nil}, fields)
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+	for _, e := range errs {
+		if !strings.HasPrefix(e, "Row 7") {
+			t.Errorf("Expected error for row 7, got %q", e)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"db-importer/parser"
+)
+
+var (
+	// ErrNoKeyColumns is returned when rows cannot be identified for undoing
+	ErrNoKeyColumns = errors.New("no primary or unique key columns to identify the inserted rows")
+	// ErrUpsertNotReversible is returned for upserts whose previous values
+	// were not captured, i.e. that were not executed by the server
+	ErrUpsertNotReversible = errors.New("upserts can only be undone when executed against a saved connection")
+)
+
+// rollbackDeleteBatch caps the keys listed in one DELETE statement
+const rollbackDeleteBatch = 500
+
+// Rollback is an undo script for an import: inserted rows are deleted by
+// key and rows overwritten by an upsert are set back to their previous values
+type Rollback struct {
+	SQL        string
+	KeyColumns []string
+	Deleted    int // rows deleted by key
+	Restored   int // rows set back to their captured values
+	Unmatched  int // rows whose key is NULL or not a literal and cannot be undone
+}
+
+// rowSet is inserted data with every value as an SQL literal
+type rowSet struct {
+	table   string   // quoted table name
+	columns []string // quoted column names
+	keys    []int    // indexes of the key columns
+	rows    [][]string
+}
+
+// keyOf returns the key literals of a row joined into a map key, or false
+// when a key value cannot be matched (NULL, DEFAULT or an expression)
+func (s *rowSet) keyOf(row []string) (string, bool) {
+	values := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		if !isLiteral(row[k]) {
+			return "", false
+		}
+		values[i] = row[k]
+		// Numbers compare by value: 01, 1.0 and 1 are the same key
+		if r, ok := new(big.Rat).SetString(row[k]); ok {
+			values[i] = r.RatString()
+		}
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// predicate matches the rows with the given key tuples
+func (s *rowSet) predicate(tuples [][]string) string {
+	if len(s.keys) == 1 {
+		values := make([]string, len(tuples))
+		for i, t := range tuples {
+			values[i] = t[0]
+		}
+		return fmt.Sprintf("%s IN (%s)", s.columns[s.keys[0]], strings.Join(values, ", "))
+	}
+
+	alternatives := make([]string, len(tuples))
+	for i, t := range tuples {
+		alternatives[i] = "(" + s.match(t) + ")"
+	}
+	return strings.Join(alternatives, " OR ")
+}
+
+// match compares every key column with one key tuple
+func (s *rowSet) match(tuple []string) string {
+	conditions := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		conditions[i] = fmt.Sprintf("%s = %s", s.columns[k], tuple[i])
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// keyTuple returns the key literals of a row
+func (s *rowSet) keyTuple(row []string) []string {
+	tuple := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		tuple[i] = row[k]
+	}
+	return tuple
+}
+
+// buildRollback writes the statements restoring the rows in preImages and
+// deleting every other inserted row. preImages are full rows in column order.
+// The DELETE statements also exclude the restored keys by the database's
+// own comparison, so a key the database matched differently (e.g. by a
+// case-insensitive collation) is never deleted.
+func buildRollback(b *strings.Builder, s *rowSet, preImages [][]string) *Rollback {
+	rb := &Rollback{}
+
+	restored := make(map[string]bool)
+	var restoredTuples [][]string
+	for _, row := range preImages {
+		key, ok := s.keyOf(row)
+		if !ok || restored[key] {
+			continue
+		}
+		restored[key] = true
+		restoredTuples = append(restoredTuples, s.keyTuple(row))
+		rb.Restored++
+
+		var set []string
+		for i, col := range s.columns {
+			if !isKeyIndex(s.keys, i) {
+				set = append(set, fmt.Sprintf("%s = %s", col, row[i]))
+			}
+		}
+		if len(set) > 0 {
+			fmt.Fprintf(b, "UPDATE %s SET %s WHERE %s;", s.table, strings.Join(set, ", "), s.match(s.keyTuple(row)))
+			b.WriteString("\n")
+		}
+	}
+
+	deleted := make(map[string]bool)
+	var tuples [][]string
+	for _, row := range s.rows {
+		key, ok := s.keyOf(row)
+		if !ok {
+			rb.Unmatched++
+			continue
+		}
+		if restored[key] || deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		tuples = append(tuples, s.keyTuple(row))
+	}
+	rb.Deleted = len(tuples)
+
+	for first := 0; first < len(tuples); first += rollbackDeleteBatch {
+		last := min(first+rollbackDeleteBatch, len(tuples))
+		where := s.predicate(tuples[first:last])
+		if len(restoredTuples) > 0 {
+			where = fmt.Sprintf("(%s) AND NOT (%s)", where, s.predicate(restoredTuples))
+		}
+		fmt.Fprintf(b, "DELETE FROM %s WHERE %s;\n", s.table, where)
+	}
+	return rb
+}
+
+// writeRollbackHeader starts an undo script
+func writeRollbackHeader(b *strings.Builder, table string) {
+	fmt.Fprintf(b, "-- Rollback of the import into %s\n", table)
+	b.WriteString("-- Run it once, before the rows are changed by anything else\n\n")
+}
+
+// finish sets the script, noting the rows that were left out
+func (rb *Rollback) finish(b *strings.Builder) *Rollback {
+	if rb.Unmatched > 0 {
+		fmt.Fprintf(b, "-- %d rows without a key value could not be included\n", rb.Unmatched)
+	}
+	rb.SQL = b.String()
+	return rb
+}
+
+func isKeyIndex(keys []int, i int) bool {
+	for _, k := range keys {
+		if k == i {
+			return true
+		}
+	}
+	return false
+}
+
+// isLiteral reports whether a value identifies a row when compared with =
+func isLiteral(value string) bool {
+	switch strings.ToUpper(value) {
+	case "", "NULL", "DEFAULT":
+		return false
+	}
+	// Strings, including prefixed ones such as N'...' and E'...'
+	if strings.HasSuffix(value, "'") {
+		return true
+	}
+	// Numbers, TRUE and FALSE; anything else is an expression
+	if upper := strings.ToUpper(value); upper == "TRUE" || upper == "FALSE" {
+		return true
+	}
+	return strings.Trim(value, "+-0123456789.eE") == ""
+}
+
+// PreImageSQL returns a SELECT of the rows a batch of an upsert plan is
+// about to overwrite, or "" for plain INSERT plans. It reads the inserted
+// columns in plan order.
+func (p *InsertPlan) PreImageSQL(batch int) string {
+	if !p.upsert {
+		return ""
+	}
+
+	set := p.rowSet()
+	b := p.Batches[batch]
+	var tuples [][]string
+	for _, row := range p.cells[b.FirstRow : b.FirstRow+b.RowCount] {
+		if _, ok := set.keyOf(row); ok {
+			tuples = append(tuples, set.keyTuple(row))
+		}
+	}
+	if len(tuples) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(p.columns, ", "), p.table, set.predicate(tuples))
+}
+
+// RollbackSQL returns the undo script for the committed batches (in the
+// order they ran). preImages holds the rows returned by PreImageSQL per
+// batch; a key seen in an earlier batch keeps the first captured values.
+// It returns ErrNoKeyColumns when no inserted column is a key.
+func (p *InsertPlan) RollbackSQL(committed []int, preImages map[int][][]interface{}) (*Rollback, error) {
+	set := p.rowSet()
+	if len(set.keys) == 0 {
+		return nil, ErrNoKeyColumns
+	}
+
+	var images [][]string
+	seen := make(map[string]bool)
+	for _, index := range committed {
+		b := p.Batches[index]
+		rows := p.cells[b.FirstRow : b.FirstRow+b.RowCount]
+
+		// Rows inserted by earlier batches did not exist before the import
+		for _, values := range preImages[index] {
+			row := make([]string, len(values))
+			for i, v := range values {
+				row[i] = formatStoredValue(v, p.fields[i].Type, p.dialect)
+			}
+			if key, ok := set.keyOf(row); ok && !seen[key] {
+				images = append(images, row)
+			}
+		}
+		for _, row := range rows {
+			if key, ok := set.keyOf(row); ok {
+				seen[key] = true
+			}
+		}
+		set.rows = append(set.rows, rows...)
+	}
+
+	var b strings.Builder
+	writeRollbackHeader(&b, p.table)
+	rb := buildRollback(&b, set, images)
+	rb.KeyColumns = p.KeyColumns()
+	return rb.finish(&b), nil
+}
+
+func (p *InsertPlan) rowSet() *rowSet {
+	set := &rowSet{table: p.table, columns: p.columns}
+	for i, f := range p.fields {
+		if f.Key {
+			set.keys = append(set.keys, i)
+		}
+	}
+	return set
+}
+
+// formatStoredValue formats a value read from the database so that writing
+// it back restores it exactly: unlike source data, empty strings and the
+// text "NULL" stay strings
+func formatStoredValue(value interface{}, sqlType string, dialect parser.Dialect) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case time.Time:
+		return quoteString(v.Format("2006-01-02 15:04:05.999999999"), dialect)
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	if isNumericType(sqlType) || isBooleanType(sqlType) {
+		return formatValueForDialect(text, sqlType, dialect)
+	}
+	return quoteString(text, dialect)
+}
+
+func quoteString(value string, dialect parser.Dialect) string {
+	if dialect == parser.DialectMySQL || dialect == parser.DialectUnknown {
+		return formatStringValue(value)
+	}
+	return formatStandardStringValue(value)
+}
+
+// RollbackForSQL reads the INSERT statements of a generated script back
+// and returns the script deleting the inserted rows by the given key
+// columns. Statements other than INSERT (e.g. SET or transaction control)
+// are ignored; upserts return ErrUpsertNotReversible since the values they
+// overwrote are unknown.
+func RollbackForSQL(dialect parser.Dialect, script string, keyColumns []string) (*Rollback, error) {
+	if len(keyColumns) == 0 {
+		return nil, ErrNoKeyColumns
+	}
+
+	statements, err := readInserts(script, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no INSERT statements found")
+	}
+
+	var b strings.Builder
+	writeRollbackHeader(&b, statements[0].table)
+	rb := &Rollback{KeyColumns: keyColumns}
+	// Undo the last statement first in case later rows depend on earlier ones
+	for i := len(statements) - 1; i >= 0; i-- {
+		stmt := statements[i]
+		if stmt.upsert {
+			return nil, ErrUpsertNotReversible
+		}
+
+		set := &rowSet{table: stmt.table, columns: stmt.columns, rows: stmt.rows}
+		for _, key := range keyColumns {
+			index := -1
+			for c, name := range stmt.names {
+				if strings.EqualFold(name, key) {
+					index = c
+					break
+				}
+			}
+			if index < 0 {
+				return nil, fmt.Errorf("key column %q is not inserted into %s", key, stmt.table)
+			}
+			set.keys = append(set.keys, index)
+		}
+
+		part := buildRollback(&b, set, nil)
+		rb.Deleted += part.Deleted
+		rb.Unmatched += part.Unmatched
+	}
+
+	return rb.finish(&b), nil
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"db-importer/parser"
+)
+
+func upsertFields() []FieldInfo {
+	return []FieldInfo{
+		{Name: "id", Type: "INT", Key: true},
+		{Name: "name", Type: "VARCHAR(50)"},
+	}
+}
+
+func TestPlanUpsert_Dialects(t *testing.T) {
+	mapping := map[string]string{"col_0": "id", "col_1": "name"}
+	rows := [][]interface{}{{1, "a"}}
+
+	tests := []struct {
+		dialect  parser.Dialect
+		expected string
+	}{
+		{parser.DialectPostgres, "INSERT INTO \"items\" (\"id\", \"name\") VALUES\n(1, 'a')\nON CONFLICT (\"id\") DO UPDATE SET \"name\" = EXCLUDED.\"name\";"},
+		{parser.DialectMySQL, "INSERT INTO `items` (`id`, `name`) VALUES\n(1, 'a')\nON DUPLICATE KEY UPDATE `name` = VALUES(`name`);"},
+		{parser.DialectSQLServer, "MERGE INTO [items] AS target\nUSING (VALUES\n(1, 'a')\n) AS source ([id], [name])\nON target.[id] = source.[id]\nWHEN MATCHED THEN UPDATE SET target.[name] = source.[name]\nWHEN NOT MATCHED THEN INSERT ([id], [name]) VALUES (source.[id], source.[name]);"},
+	}
+
+	for _, tt := range tests {
+		plan := PlanUpsert(tt.dialect, "items", mapping, rows, upsertFields(), 0)
+		if plan == nil {
+			t.Fatalf("dialect %s: expected a plan", tt.dialect)
+		}
+		if plan.Batches[0].SQL != tt.expected {
+			t.Errorf("dialect %s:\ngot:  %s\nwant: %s", tt.dialect, plan.Batches[0].SQL, tt.expected)
+		}
+		if plan.RowSQL(0) != tt.expected {
+			t.Errorf("dialect %s: row SQL differs from the batch: %s", tt.dialect, plan.RowSQL(0))
+		}
+	}
+
+	noKeys := []FieldInfo{{Name: "id", Type: "INT"}, {Name: "name", Type: "TEXT"}}
+	if PlanUpsert(parser.DialectPostgres, "items", mapping, rows, noKeys, 0) != nil {
+		t.Error("Expected nil upsert plan without key fields")
+	}
+}
+
+func TestInsertPlan_RollbackSQL_Insert(t *testing.T) {
+	mapping := map[string]string{"col_0": "id", "col_1": "name"}
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {nil, "c"}, {4, "d"}}
+
+	plan := PlanInsert(parser.DialectPostgres, "items", mapping, rows, upsertFields(), 2)
+	if plan.PreImageSQL(0) != "" {
+		t.Error("Expected no pre-image query for a plain INSERT")
+	}
+
+	// Only the second batch was committed
+	rb, err := plan.RollbackSQL([]int{1}, nil)
+	if err != nil {
+		t.Fatalf("RollbackSQL failed: %v", err)
+	}
+	if rb.Deleted != 1 || rb.Unmatched != 1 || rb.Restored != 0 {
+		t.Errorf("Unexpected counts: %+v", rb)
+	}
+	if !strings.Contains(rb.SQL, "DELETE FROM \"items\" WHERE \"id\" IN (4);") {
+		t.Errorf("Expected a DELETE of the committed row, got:\n%s", rb.SQL)
+	}
+
+	noKeys := PlanInsert(parser.DialectPostgres, "items", mapping, rows, []FieldInfo{{Name: "id", Type: "INT"}, {Name: "name", Type: "TEXT"}}, 2)
+	if _, err := noKeys.RollbackSQL([]int{0}, nil); !errors.Is(err, ErrNoKeyColumns) {
+		t.Errorf("Expected ErrNoKeyColumns, got %v", err)
+	}
+}
+
+func TestInsertPlan_RollbackSQL_Upsert(t *testing.T) {
+	mapping := map[string]string{"col_0": "id", "col_1": "name"}
+	rows := [][]interface{}{{1, "new"}, {2, "b"}, {2, "c"}}
+
+	plan := PlanUpsert(parser.DialectPostgres, "items", mapping, rows, upsertFields(), 2)
+	if query := plan.PreImageSQL(0); query != "SELECT \"id\", \"name\" FROM \"items\" WHERE \"id\" IN (1, 2)" {
+		t.Errorf("Unexpected pre-image query: %s", query)
+	}
+
+	// Row 1 existed with an empty name; the second batch saw row 2 as
+	// written by the first, which must still be deleted
+	preImages := map[int][][]interface{}{
+		0: {{int64(1), []byte("")}},
+		1: {{int64(2), "b"}},
+	}
+	rb, err := plan.RollbackSQL([]int{0, 1}, preImages)
+	if err != nil {
+		t.Fatalf("RollbackSQL failed: %v", err)
+	}
+	if rb.Restored != 1 || rb.Deleted != 1 {
+		t.Errorf("Unexpected counts: %+v", rb)
+	}
+	if !strings.Contains(rb.SQL, "UPDATE \"items\" SET \"name\" = '' WHERE \"id\" = 1;") {
+		t.Errorf("Expected the empty name to be restored, got:\n%s", rb.SQL)
+	}
+	if !strings.Contains(rb.SQL, "DELETE FROM \"items\" WHERE (\"id\" IN (2)) AND NOT (\"id\" IN (1));") {
+		t.Errorf("Expected a DELETE excluding the restored key, got:\n%s", rb.SQL)
+	}
+}
+
+func TestRollbackForSQL(t *testing.T) {
+	script := "-- generated\nSET NAMES utf8mb4;\n" +
+		"INSERT INTO `shop`.`orders` (`order_id`, `line`, `note`) VALUES\n" +
+		"(1, 1, 'it''s; fine'),\n(1, 2, 'C:\\\\temp\\''),\n(01, 2, NULL),\n(NULL, 3, 'x');\n" +
+		"INSERT INTO `shop`.`orders` (`order_id`, `line`, `note`) VALUES (2, 1, NOW());"
+
+	rb, err := RollbackForSQL(parser.DialectMySQL, script, []string{"order_id", "LINE"})
+	if err != nil {
+		t.Fatalf("RollbackForSQL failed: %v", err)
+	}
+	if rb.Deleted != 3 || rb.Unmatched != 1 {
+		t.Errorf("Unexpected counts: %+v", rb)
+	}
+
+	// The last statement is undone first
+	second := strings.Index(rb.SQL, "WHERE (`order_id` = 2 AND `line` = 1);")
+	first := strings.Index(rb.SQL, "WHERE (`order_id` = 1 AND `line` = 1) OR (`order_id` = 1 AND `line` = 2);")
+	if second < 0 || first < 0 || second > first {
+		t.Errorf("Unexpected rollback script:\n%s", rb.SQL)
+	}
+	if !strings.HasPrefix(rb.SQL, "-- Rollback of the import into `shop`.`orders`") {
+		t.Errorf("Expected a header, got:\n%s", rb.SQL)
+	}
+}
+
+func TestRollbackForSQL_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		keys   []string
+		err    error
+	}{
+		{"no keys", "INSERT INTO t (id) VALUES (1);", nil, ErrNoKeyColumns},
+		{"upsert", "INSERT INTO t (id, n) VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET n = EXCLUDED.n;", []string{"id"}, ErrUpsertNotReversible},
+		{"merge", "MERGE INTO [t] AS target USING (VALUES (1)) AS source ([id]) ON target.[id] = source.[id];", []string{"id"}, ErrUpsertNotReversible},
+		{"missing key", "INSERT INTO t (n) VALUES ('a');", []string{"id"}, nil},
+		{"no column list", "INSERT INTO t VALUES (1);", []string{"id"}, nil},
+		{"empty", "SELECT 1;", []string{"id"}, nil},
+	}
+
+	for _, tt := range tests {
+		_, err := RollbackForSQL(parser.DialectPostgres, tt.script, tt.keys)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"db-importer/parser"
+)
+
+// insertStatement is an INSERT read back from SQL text, keeping the table,
+// columns and values exactly as written
+type insertStatement struct {
+	table   string
+	columns []string   // as written, quoted
+	names   []string   // unquoted column names
+	rows    [][]string // value literals
+	upsert  bool
+}
+
+// sqlReader scans INSERT statements in the shape this package generates
+type sqlReader struct {
+	src string
+	pos int
+	// MySQL strings treat backslash as an escape character
+	backslashEscapes bool
+}
+
+// readInserts returns the INSERT ... VALUES statements of script, skipping
+// other statements. A MERGE counts as an upsert and is rejected.
+func readInserts(script string, dialect parser.Dialect) ([]insertStatement, error) {
+	r := &sqlReader{
+		src:              script,
+		backslashEscapes: dialect == parser.DialectMySQL || dialect == parser.DialectUnknown,
+	}
+
+	var statements []insertStatement
+	for {
+		r.skipSpace()
+		if r.eof() {
+			return statements, nil
+		}
+		if r.src[r.pos] == ';' {
+			r.pos++
+			continue
+		}
+
+		switch {
+		case r.keyword("INSERT"):
+			stmt, err := r.insert()
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, *stmt)
+		case r.keyword("MERGE"):
+			return nil, ErrUpsertNotReversible
+		default:
+			r.skipStatement()
+		}
+	}
+}
+
+// insert reads the rest of an INSERT statement
+func (r *sqlReader) insert() (*insertStatement, error) {
+	stmt := &insertStatement{}
+
+	r.keyword("IGNORE")
+	r.keyword("INTO")
+	table, _, err := r.identifier()
+	if err != nil {
+		return nil, err
+	}
+	stmt.table = table
+
+	if err := r.expect('('); err != nil {
+		return nil, fmt.Errorf("INSERT into %s without a column list cannot be undone", table)
+	}
+	for {
+		column, name, err := r.identifier()
+		if err != nil {
+			return nil, err
+		}
+		stmt.columns = append(stmt.columns, column)
+		stmt.names = append(stmt.names, name)
+		if r.accept(')') {
+			break
+		}
+		if err := r.expect(','); err != nil {
+			return nil, err
+		}
+	}
+
+	if !r.keyword("VALUES") {
+		return nil, fmt.Errorf("only INSERT ... VALUES statements can be undone (offset %d)", r.pos)
+	}
+	for {
+		if err := r.expect('('); err != nil {
+			return nil, err
+		}
+		var row []string
+		for {
+			value, err := r.value()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, value)
+			if r.accept(')') {
+				break
+			}
+			if err := r.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		if len(row) != len(stmt.columns) {
+			return nil, fmt.Errorf("row of %d values for %d columns in INSERT into %s", len(row), len(stmt.columns), table)
+		}
+		stmt.rows = append(stmt.rows, row)
+
+		if !r.accept(',') {
+			break
+		}
+	}
+
+	r.skipSpace()
+	switch {
+	case r.eof() || r.accept(';'):
+	case r.keyword("ON"):
+		// ON CONFLICT or ON DUPLICATE KEY UPDATE
+		stmt.upsert = true
+		r.skipStatement()
+	default:
+		return nil, fmt.Errorf("unexpected %q after INSERT values (offset %d)", r.src[r.pos:min(r.pos+20, len(r.src))], r.pos)
+	}
+	return stmt, nil
+}
+
+func (r *sqlReader) eof() bool {
+	return r.pos >= len(r.src)
+}
+
+// skipSpace skips whitespace and comments
+func (r *sqlReader) skipSpace() {
+	for !r.eof() {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(r.src[r.pos])):
+			r.pos++
+		case strings.HasPrefix(r.src[r.pos:], "--"):
+			end := strings.IndexByte(r.src[r.pos:], '\n')
+			if end < 0 {
+				r.pos = len(r.src)
+			} else {
+				r.pos += end + 1
+			}
+		case strings.HasPrefix(r.src[r.pos:], "/*"):
+			end := strings.Index(r.src[r.pos+2:], "*/")
+			if end < 0 {
+				r.pos = len(r.src)
+			} else {
+				r.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword consumes word (case-insensitive) when it comes next
+func (r *sqlReader) keyword(word string) bool {
+	r.skipSpace()
+	end := r.pos + len(word)
+	if end > len(r.src) || !strings.EqualFold(r.src[r.pos:end], word) {
+		return false
+	}
+	if end < len(r.src) && isIdentifierChar(r.src[end]) {
+		return false
+	}
+	r.pos = end
+	return true
+}
+
+// accept consumes ch when it comes next
+func (r *sqlReader) accept(ch byte) bool {
+	r.skipSpace()
+	if !r.eof() && r.src[r.pos] == ch {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+func (r *sqlReader) expect(ch byte) error {
+	if !r.accept(ch) {
+		return fmt.Errorf("expected %q at offset %d", ch, r.pos)
+	}
+	return nil
+}
+
+// identifier reads a possibly qualified and quoted name, returning it as
+// written and the unquoted last part
+func (r *sqlReader) identifier() (string, string, error) {
+	r.skipSpace()
+	start := r.pos
+	var name string
+	for {
+		if r.eof() {
+			return "", "", fmt.Errorf("expected a name at offset %d", r.pos)
+		}
+		switch open := r.src[r.pos]; open {
+		case '`', '"', '[':
+			closing := open
+			if open == '[' {
+				closing = ']'
+			}
+			end := r.pos + 1
+			for ; end < len(r.src); end++ {
+				if r.src[end] != closing {
+					continue
+				}
+				// A doubled quote is part of the name
+				if end+1 < len(r.src) && r.src[end+1] == closing {
+					end++
+					continue
+				}
+				break
+			}
+			if end >= len(r.src) {
+				return "", "", fmt.Errorf("unterminated name at offset %d", r.pos)
+			}
+			quote := string(closing)
+			name = strings.ReplaceAll(r.src[r.pos+1:end], quote+quote, quote)
+			r.pos = end + 1
+		default:
+			end := r.pos
+			for end < len(r.src) && isIdentifierChar(r.src[end]) {
+				end++
+			}
+			if end == r.pos {
+				return "", "", fmt.Errorf("expected a name at offset %d", r.pos)
+			}
+			name = r.src[r.pos:end]
+			r.pos = end
+		}
+
+		if r.eof() || r.src[r.pos] != '.' {
+			return r.src[start:r.pos], name, nil
+		}
+		r.pos++
+	}
+}
+
+// value reads one value up to the next top-level comma or closing
+// parenthesis, keeping it as written
+func (r *sqlReader) value() (string, error) {
+	r.skipSpace()
+	start := r.pos
+	depth := 0
+	for !r.eof() {
+		switch ch := r.src[r.pos]; ch {
+		case '\'':
+			end, err := r.stringEnd(r.pos)
+			if err != nil {
+				return "", err
+			}
+			r.pos = end
+			continue
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return strings.TrimSpace(r.src[start:r.pos]), nil
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return strings.TrimSpace(r.src[start:r.pos]), nil
+			}
+		}
+		r.pos++
+	}
+	return "", fmt.Errorf("unterminated VALUES row at offset %d", start)
+}
+
+// stringEnd returns the offset after the string literal starting at pos
+func (r *sqlReader) stringEnd(pos int) (int, error) {
+	for i := pos + 1; i < len(r.src); i++ {
+		switch r.src[i] {
+		case '\\':
+			if r.backslashEscapes {
+				i++
+			}
+		case '\'':
+			if i+1 < len(r.src) && r.src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", pos)
+}
+
+// skipStatement moves past the next semicolon outside string literals
+func (r *sqlReader) skipStatement() {
+	for !r.eof() {
+		switch r.src[r.pos] {
+		case '\'':
+			end, err := r.stringEnd(r.pos)
+			if err != nil {
+				r.pos = len(r.src)
+				return
+			}
+			r.pos = end
+			continue
+		case ';':
+			r.pos++
+			return
+		}
+		r.pos++
+	}
+}
+
+func isIdentifierChar(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= 0x80
+}
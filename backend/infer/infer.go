@@ -0,0 +1,435 @@
+// Package infer derives column types from the values of a data file, so a
+// table can be created for data that has no target table yet. Rows are
+// added one at a time, so whole files can be streamed through an Inferrer.
+package infer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-importer/mapping"
+	"db-importer/parser"
+	"db-importer/transform"
+)
+
+// Kind is the inferred type of a column's values
+type Kind string
+
+const (
+	KindEmpty    Kind = "empty" // only NULLs
+	KindBoolean  Kind = "boolean"
+	KindInteger  Kind = "integer"
+	KindDecimal  Kind = "decimal"
+	KindFloat    Kind = "float"
+	KindDate     Kind = "date"
+	KindDateTime Kind = "datetime"
+	KindTime     Kind = "time"
+	KindText     Kind = "text"
+)
+
+// Column describes the values of one data file column
+type Column struct {
+	Header string `json:"header"`
+	// Name is the identifier the column gets in the inferred table
+	Name     string `json:"name"`
+	Kind     Kind   `json:"kind"`
+	Nullable bool   `json:"nullable"`
+	Nulls    int    `json:"nulls"`
+	// Unique is set when no two non-null values are equal; it is not known
+	// for columns with more than MaxDistinct different values
+	Unique    bool `json:"unique"`
+	MaxLength int  `json:"maxLength"` // longest value, in characters
+
+	// Integer range
+	Min *int64 `json:"min,omitempty"`
+	Max *int64 `json:"max,omitempty"`
+
+	// Decimal digits in total and after the point
+	Precision int `json:"precision,omitempty"`
+	Scale     int `json:"scale,omitempty"`
+
+	// DateFormat is the layout all date and time values follow, e.g.
+	// DD/MM/YYYY. Day-first is assumed when no day is above 12.
+	DateFormat   string `json:"dateFormat,omitempty"`
+	WithTimeZone bool   `json:"withTimeZone,omitempty"`
+
+	PrimaryKey bool `json:"primaryKey,omitempty"`
+	// Transformation, when set, converts the values to a format the
+	// database accepts (see package transform)
+	Transformation string `json:"transformation,omitempty"`
+}
+
+// Result is the outcome of inferring the columns of a data file
+type Result struct {
+	Rows    int      `json:"rows"`
+	Columns []Column `json:"columns"`
+}
+
+// MaxDistinct caps the distinct values remembered per column to find
+// unique columns; beyond it a column is not considered unique
+const MaxDistinct = 1 << 20
+
+// maxKeyLength is the longest text value of a primary key candidate
+const maxKeyLength = 64
+
+// maxPrecision is the most decimal digits kept exact; longer numbers are
+// inferred as text
+const maxPrecision = 38
+
+// dateLayout is a date or time format values are tried against
+type dateLayout struct {
+	layout   string // Go reference layout
+	format   string // as shown to users
+	kind     Kind
+	timeZone bool
+}
+
+// dateLayouts in order of preference: ISO first and day-first before
+// month-first, as transform.FormatDate reads dates
+var dateLayouts = []dateLayout{
+	{"2006-01-02", "YYYY-MM-DD", KindDate, false},
+	{"2006/01/02", "YYYY/MM/DD", KindDate, false},
+	{"2/1/2006", "DD/MM/YYYY", KindDate, false},
+	{"1/2/2006", "MM/DD/YYYY", KindDate, false},
+	{"2.1.2006", "DD.MM.YYYY", KindDate, false},
+	{"2-1-2006", "DD-MM-YYYY", KindDate, false},
+	{"2006-01-02 15:04:05", "YYYY-MM-DD HH:MM:SS", KindDateTime, false},
+	{"2006-01-02T15:04:05", "YYYY-MM-DDTHH:MM:SS", KindDateTime, false},
+	{"2006-01-02 15:04", "YYYY-MM-DD HH:MM", KindDateTime, false},
+	{time.RFC3339, "YYYY-MM-DDTHH:MM:SS±HH:MM", KindDateTime, true},
+	{"2006-01-02 15:04:05Z07:00", "YYYY-MM-DD HH:MM:SS±HH:MM", KindDateTime, true},
+	{"2/1/2006 15:04:05", "DD/MM/YYYY HH:MM:SS", KindDateTime, false},
+	{"1/2/2006 15:04:05", "MM/DD/YYYY HH:MM:SS", KindDateTime, false},
+	{"2/1/2006 15:04", "DD/MM/YYYY HH:MM", KindDateTime, false},
+	{"1/2/2006 15:04", "MM/DD/YYYY HH:MM", KindDateTime, false},
+	{"15:04:05", "HH:MM:SS", KindTime, false},
+	{"15:04", "HH:MM", KindTime, false},
+}
+
+// dateTransformations are the formats transform.FormatDate converts to ISO
+var dateTransformations = map[string]string{
+	"DD/MM/YYYY": transform.FormatDate,
+	"DD-MM-YYYY": transform.FormatDate,
+}
+
+var (
+	integerValue = regexp.MustCompile(`^[+-]?\d+$`)
+	decimalValue = regexp.MustCompile(`^[+-]?(\d+\.\d*|\.\d+)$`)
+	floatValue   = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)[eE][+-]?\d+$`)
+)
+
+// column accumulates what is known about a column's values so far. The
+// kind flags start out true and are cleared by the first value that does
+// not fit.
+type column struct {
+	header string
+	nulls  int
+	values int
+	maxLen int
+
+	boolean, integer, decimal, float bool
+	boolWord                         bool // a boolean value other than 0 and 1
+	min, max                         int64
+	intDigits, scale                 int
+
+	layouts []bool // still possible, by index in dateLayouts
+
+	distinct map[string]struct{}
+	overflow bool // too many distinct values to tell uniqueness
+}
+
+func newColumn(header string) *column {
+	layouts := make([]bool, len(dateLayouts))
+	for i := range layouts {
+		layouts[i] = true
+	}
+	return &column{
+		header:   header,
+		boolean:  true,
+		integer:  true,
+		decimal:  true,
+		float:    true,
+		layouts:  layouts,
+		distinct: make(map[string]struct{}),
+	}
+}
+
+// Inferrer infers column types from rows added one at a time
+type Inferrer struct {
+	columns []*column
+	rows    int
+}
+
+// New creates an Inferrer for a data file with the given headers
+func New(headers []string) *Inferrer {
+	columns := make([]*column, len(headers))
+	for i, h := range headers {
+		columns[i] = newColumn(h)
+	}
+	return &Inferrer{columns: columns}
+}
+
+// Add adds a data row, in header order. Missing cells count as NULL and
+// cells beyond the headers are ignored.
+func (in *Inferrer) Add(row []interface{}) {
+	in.rows++
+	for i, c := range in.columns {
+		var value interface{}
+		if i < len(row) {
+			value = row[i]
+		}
+		c.add(value)
+	}
+}
+
+// Infer infers the columns of headers from rows
+func Infer(headers []string, rows [][]interface{}) *Result {
+	in := New(headers)
+	for _, row := range rows {
+		in.Add(row)
+	}
+	return in.Result()
+}
+
+// text returns the text of a cell and whether it is NULL. Whole JSON
+// numbers (as decoded from spreadsheets) are written without exponent.
+func text(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		s := strings.TrimSpace(v)
+		return s, s == "" || strings.EqualFold(s, "null")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), false
+	case json.Number:
+		return v.String(), false
+	default:
+		return strings.TrimSpace(fmt.Sprint(v)), false
+	}
+}
+
+func (c *column) add(value interface{}) {
+	s, null := text(value)
+	if null {
+		c.nulls++
+		return
+	}
+	c.values++
+	c.maxLen = max(c.maxLen, len([]rune(s)))
+
+	if !c.overflow {
+		c.distinct[s] = struct{}{}
+		if len(c.distinct) > MaxDistinct {
+			c.overflow = true
+			c.distinct = nil
+		}
+	}
+
+	if c.boolean {
+		switch strings.ToLower(s) {
+		case "0", "1":
+		case "true", "false", "yes", "no", "y", "n", "t", "f":
+			c.boolWord = true
+		default:
+			c.boolean = false
+		}
+	}
+
+	// Leading zeros ("007", zip codes) would be lost in a number
+	unsigned := strings.TrimLeft(s, "+-")
+	leadingZero := len(unsigned) > 1 && unsigned[0] == '0' && unsigned[1] != '.'
+
+	if c.integer {
+		n, err := strconv.ParseInt(s, 10, 64)
+		switch {
+		case leadingZero || !integerValue.MatchString(s):
+			c.integer = false
+		case err != nil:
+			// Out of the int64 range, still a decimal
+			c.integer = false
+		case c.values == 1:
+			c.min, c.max = n, n
+		default:
+			c.min, c.max = min(c.min, n), max(c.max, n)
+		}
+	}
+
+	if c.decimal {
+		if leadingZero || !(integerValue.MatchString(s) || decimalValue.MatchString(s)) {
+			c.decimal = false
+		} else {
+			whole, fraction, _ := strings.Cut(unsigned, ".")
+			whole = strings.TrimLeft(whole, "0")
+			c.intDigits = max(c.intDigits, len(whole))
+			c.scale = max(c.scale, len(fraction))
+		}
+	}
+
+	if c.float {
+		if leadingZero || !(integerValue.MatchString(s) || decimalValue.MatchString(s) || floatValue.MatchString(s)) {
+			c.float = false
+		} else if f, err := strconv.ParseFloat(s, 64); err != nil || math.IsInf(f, 0) {
+			c.float = false
+		}
+	}
+
+	for i, possible := range c.layouts {
+		if possible {
+			if _, err := time.Parse(dateLayouts[i].layout, s); err != nil {
+				c.layouts[i] = false
+			}
+		}
+	}
+}
+
+// Result returns the columns inferred from the rows added so far
+func (in *Inferrer) Result() *Result {
+	result := &Result{Rows: in.rows, Columns: make([]Column, len(in.columns))}
+
+	used := make(map[string]bool, len(in.columns))
+	for i, c := range in.columns {
+		col := c.infer()
+		col.Name = uniqueName(c.header, i, used)
+		result.Columns[i] = col
+	}
+
+	if key := primaryKeyCandidate(result); key >= 0 {
+		result.Columns[key].PrimaryKey = true
+	}
+	return result
+}
+
+// uniqueName derives a column identifier from a header, numbering
+// repeated and unusable names
+func uniqueName(header string, index int, used map[string]bool) string {
+	name := mapping.Identifier(header)
+	if name == "" {
+		name = fmt.Sprintf("column_%d", index+1)
+	}
+	base := name
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	used[name] = true
+	return name
+}
+
+func (c *column) infer() Column {
+	col := Column{
+		Header:    c.header,
+		Nullable:  c.nulls > 0,
+		Nulls:     c.nulls,
+		Unique:    !c.overflow && c.values > 0 && len(c.distinct) == c.values,
+		MaxLength: c.maxLen,
+	}
+
+	switch {
+	case c.values == 0:
+		col.Kind = KindEmpty
+		col.Nullable = true
+	case c.boolean && c.boolWord:
+		col.Kind = KindBoolean
+	case c.integer:
+		col.Kind = KindInteger
+		lo, hi := c.min, c.max
+		col.Min, col.Max = &lo, &hi
+	case c.decimal && c.intDigits+c.scale <= maxPrecision:
+		col.Kind = KindDecimal
+		col.Precision = max(c.intDigits+c.scale, 1)
+		col.Scale = c.scale
+	case c.float && !c.decimal:
+		col.Kind = KindFloat
+	default:
+		col.Kind = KindText
+		for i, possible := range c.layouts {
+			if possible {
+				layout := dateLayouts[i]
+				col.Kind = layout.kind
+				col.DateFormat = layout.format
+				col.WithTimeZone = layout.timeZone
+				col.Transformation = dateTransformations[layout.format]
+				break
+			}
+		}
+	}
+	return col
+}
+
+// primaryKeyCandidate picks the column that can serve as primary key: an
+// integer or short text column without NULLs or duplicates. Columns named
+// id come first, then those ending in _id, then integers, then the leftmost.
+// It returns -1 when there is no candidate or fewer than two rows.
+func primaryKeyCandidate(result *Result) int {
+	if result.Rows < 2 {
+		return -1
+	}
+
+	best, bestRank := -1, 0
+	for i, c := range result.Columns {
+		if c.Nullable || !c.Unique {
+			continue
+		}
+		if c.Kind != KindInteger && !(c.Kind == KindText && c.MaxLength <= maxKeyLength) {
+			continue
+		}
+
+		rank := 4
+		switch {
+		case c.Name == "id":
+			rank = 0
+		case strings.HasSuffix(c.Name, "_id"):
+			rank = 1
+		}
+		if c.Kind == KindInteger {
+			rank++
+		} else {
+			rank += 2
+		}
+		if best < 0 || rank < bestRank {
+			best, bestRank = i, rank
+		}
+	}
+	return best
+}
+
+// Mapping maps each header to the name of its column
+func (r *Result) Mapping() map[string]string {
+	m := make(map[string]string, len(r.Columns))
+	for _, c := range r.Columns {
+		m[c.Header] = c.Name
+	}
+	return m
+}
+
+// Transformations lists the suggested transformation of each column that
+// has one, by column name
+func (r *Result) Transformations() map[string]string {
+	m := make(map[string]string)
+	for _, c := range r.Columns {
+		if c.Transformation != "" {
+			m[c.Name] = c.Transformation
+		}
+	}
+	return m
+}
+
+// Table returns the inferred table with column types for dialect (MySQL
+// when unknown)
+func (r *Result) Table(name string, dialect parser.Dialect) parser.Table {
+	table := parser.Table{Name: name, Fields: make([]parser.Field, len(r.Columns))}
+	for i, c := range r.Columns {
+		table.Fields[i] = parser.Field{
+			Name:       c.Name,
+			Type:       SQLType(c, dialect),
+			Nullable:   c.Nullable,
+			PrimaryKey: c.PrimaryKey,
+		}
+	}
+	return table
+}
@@ -0,0 +1,232 @@
+package infer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"db-importer/parser"
+	"db-importer/transform"
+)
+
+func columnOf(t *testing.T, result *Result, header string) Column {
+	t.Helper()
+	for _, c := range result.Columns {
+		if c.Header == header {
+			return c
+		}
+	}
+	t.Fatalf("No column %q in %+v", header, result.Columns)
+	return Column{}
+}
+
+func TestInfer_Kinds(t *testing.T) {
+	headers := []string{"Customer ID", "Active", "Flag", "Price", "Ratio", "Zip", "Joined", "Seen", "Opens", "Name", "Empty"}
+	rows := [][]interface{}{
+		{"1", "yes", "1", "9.99", "1.5e3", "01234", "31/12/2023", "2024-01-02T10:00:00+02:00", "08:30", "Ann", ""},
+		{"2", "No", "0", "120.5", "2E-2", "75001", "01/02/2024", "2024-01-03T11:30:00Z", "17:45", "Bob", nil},
+		{"300", "TRUE", "1", "-3", "7", "10115", "", "2024-01-04T09:00:00.5+00:00", "9:05", "Cy", "NULL"},
+	}
+
+	result := Infer(headers, rows)
+	if result.Rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", result.Rows)
+	}
+
+	tests := []struct {
+		header string
+		kind   Kind
+	}{
+		{"Customer ID", KindInteger},
+		{"Active", KindBoolean},
+		{"Flag", KindInteger}, // 0 and 1 alone stay numbers
+		{"Price", KindDecimal},
+		{"Ratio", KindFloat},
+		{"Zip", KindText}, // leading zeros are kept
+		{"Joined", KindDate},
+		{"Seen", KindDateTime},
+		{"Opens", KindTime},
+		{"Name", KindText},
+		{"Empty", KindEmpty},
+	}
+	for _, tt := range tests {
+		if got := columnOf(t, result, tt.header).Kind; got != tt.kind {
+			t.Errorf("Column %q: kind %s, want %s", tt.header, got, tt.kind)
+		}
+	}
+
+	id := columnOf(t, result, "Customer ID")
+	if id.Name != "customer_id" || *id.Min != 1 || *id.Max != 300 || id.Nullable || !id.Unique || !id.PrimaryKey {
+		t.Errorf("Unexpected id column %+v", id)
+	}
+
+	price := columnOf(t, result, "Price")
+	if price.Precision != 5 || price.Scale != 2 {
+		t.Errorf("Expected DECIMAL(5,2) for price, got %d,%d", price.Precision, price.Scale)
+	}
+
+	joined := columnOf(t, result, "Joined")
+	if joined.DateFormat != "DD/MM/YYYY" || !joined.Nullable || joined.Nulls != 1 || joined.Transformation != transform.FormatDate {
+		t.Errorf("Unexpected date column %+v", joined)
+	}
+
+	seen := columnOf(t, result, "Seen")
+	if !seen.WithTimeZone {
+		t.Errorf("Expected a time zone on %+v", seen)
+	}
+
+	name := columnOf(t, result, "Name")
+	if name.MaxLength != 3 || name.PrimaryKey {
+		t.Errorf("Unexpected name column %+v", name)
+	}
+}
+
+func TestInfer_DateOrder(t *testing.T) {
+	result := Infer([]string{"us", "iso"}, [][]interface{}{
+		{"12/31/2023", "2023-12-31"},
+		{"1/5/2024", "2024-01-05"},
+	})
+
+	us := columnOf(t, result, "us")
+	if us.Kind != KindDate || us.DateFormat != "MM/DD/YYYY" || us.Transformation != "" {
+		t.Errorf("Unexpected month-first column %+v", us)
+	}
+	if iso := columnOf(t, result, "iso"); iso.DateFormat != "YYYY-MM-DD" || iso.Transformation != "" {
+		t.Errorf("Unexpected ISO column %+v", iso)
+	}
+}
+
+func TestInfer_SpreadsheetValues(t *testing.T) {
+	// Values decoded from JSON arrive as float64 and bool
+	result := Infer([]string{"qty", "amount", "paid"}, [][]interface{}{
+		{float64(3), 12.5, true},
+		{float64(40000), float64(7), false},
+	})
+
+	if c := columnOf(t, result, "qty"); c.Kind != KindInteger || *c.Max != 40000 {
+		t.Errorf("Unexpected qty column %+v", c)
+	}
+	if c := columnOf(t, result, "amount"); c.Kind != KindDecimal || c.Precision != 3 || c.Scale != 1 {
+		t.Errorf("Unexpected amount column %+v", c)
+	}
+	if c := columnOf(t, result, "paid"); c.Kind != KindBoolean {
+		t.Errorf("Unexpected paid column %+v", c)
+	}
+}
+
+func TestInfer_PrimaryKeyCandidate(t *testing.T) {
+	headers := []string{"code", "order_id", "id", "note"}
+	rows := [][]interface{}{
+		{"A", "10", "1", "x"},
+		{"B", "11", "2", "x"},
+	}
+
+	result := Infer(headers, rows)
+	var keys []string
+	for _, c := range result.Columns {
+		if c.PrimaryKey {
+			keys = append(keys, c.Name)
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"id"}) {
+		t.Errorf("Expected id as the only key, got %v", keys)
+	}
+
+	// Duplicates or NULLs rule a column out; a single row proves nothing
+	result = Infer([]string{"id", "sku"}, [][]interface{}{{"1", "a"}, {"1", "b"}, {"2", nil}})
+	for _, c := range result.Columns {
+		if c.PrimaryKey {
+			t.Errorf("Expected no key, got %s", c.Name)
+		}
+	}
+	if result = Infer([]string{"id"}, [][]interface{}{{"1"}}); result.Columns[0].PrimaryKey {
+		t.Error("Expected no key for a single row")
+	}
+}
+
+func TestInfer_ColumnNames(t *testing.T) {
+	result := Infer([]string{"E-Mail", "e mail", "", "Prénom", "123"}, nil)
+
+	var names []string
+	for _, c := range result.Columns {
+		names = append(names, c.Name)
+	}
+	expected := []string{"e_mail", "e_mail_2", "column_3", "prenom", "c_123"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Names = %v, want %v", names, expected)
+	}
+
+	mapping := result.Mapping()
+	if mapping["E-Mail"] != "e_mail" || mapping["e mail"] != "e_mail_2" {
+		t.Errorf("Unexpected mapping %v", mapping)
+	}
+}
+
+func TestInfer_ShortRows(t *testing.T) {
+	in := New([]string{"a", "b"})
+	in.Add([]interface{}{"1"})
+	in.Add([]interface{}{"2", "x", "ignored"})
+
+	result := in.Result()
+	if b := columnOf(t, result, "b"); !b.Nullable || b.Nulls != 1 || b.Kind != KindText {
+		t.Errorf("Unexpected column %+v", b)
+	}
+}
+
+func TestSQLType(t *testing.T) {
+	small, large := int64(-5), int64(40000)
+	big := int64(5_000_000_000)
+
+	tests := []struct {
+		column   Column
+		dialect  parser.Dialect
+		expected string
+	}{
+		{Column{Kind: KindInteger, Min: &small, Max: &small}, parser.DialectMySQL, "TINYINT"},
+		{Column{Kind: KindInteger, Min: &small, Max: &large}, parser.DialectMySQL, "MEDIUMINT"},
+		{Column{Kind: KindInteger, Min: &small, Max: &large}, parser.DialectPostgres, "INTEGER"},
+		{Column{Kind: KindInteger, Min: &small, Max: &big}, parser.DialectSQLServer, "BIGINT"},
+		{Column{Kind: KindInteger, Min: &small, Max: &big}, parser.DialectSQLite, "INTEGER"},
+		{Column{Kind: KindDecimal, Precision: 5, Scale: 2}, parser.DialectMySQL, "DECIMAL(5,2)"},
+		{Column{Kind: KindDecimal, Precision: 5, Scale: 2}, parser.DialectPostgres, "NUMERIC(5,2)"},
+		{Column{Kind: KindFloat}, parser.DialectPostgres, "DOUBLE PRECISION"},
+		{Column{Kind: KindBoolean}, parser.DialectSQLServer, "BIT"},
+		{Column{Kind: KindDateTime, WithTimeZone: true}, parser.DialectPostgres, "TIMESTAMPTZ"},
+		{Column{Kind: KindDateTime}, parser.DialectSQLServer, "DATETIME2"},
+		{Column{Kind: KindText, MaxLength: 40}, parser.DialectMySQL, "VARCHAR(64)"},
+		{Column{Kind: KindText, MaxLength: 40}, parser.DialectSQLServer, "NVARCHAR(64)"},
+		{Column{Kind: KindText, MaxLength: 40}, parser.DialectSQLite, "TEXT"},
+		{Column{Kind: KindText, MaxLength: 5000}, parser.DialectPostgres, "TEXT"},
+		{Column{Kind: KindText, MaxLength: 20000}, parser.DialectMySQL, "LONGTEXT"},
+		{Column{Kind: KindEmpty}, parser.DialectUnknown, "VARCHAR(255)"},
+	}
+
+	for _, tt := range tests {
+		if got := SQLType(tt.column, tt.dialect); got != tt.expected {
+			t.Errorf("SQLType(%+v, %s) = %s, want %s", tt.column, tt.dialect, got, tt.expected)
+		}
+	}
+}
+
+func TestResultTable(t *testing.T) {
+	result := Infer([]string{"ID", "Email", "Signed up"}, [][]interface{}{
+		{"1", "ann@example.com", "2024-01-02"},
+		{"2", "bob@example.com", ""},
+	})
+
+	table := result.Table("customers", parser.DialectPostgres)
+	expected := parser.Table{
+		Name: "customers",
+		Fields: []parser.Field{
+			{Name: "id", Type: "SMALLINT", PrimaryKey: true},
+			{Name: "email", Type: "VARCHAR(16)"},
+			{Name: "signed_up", Type: "DATE", Nullable: true},
+		},
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("Table = %+v, want %+v", table, expected)
+	}
+	if !strings.Contains(strings.Join(table.PrimaryKey(), ","), "id") {
+		t.Errorf("Expected id as primary key, got %v", table.PrimaryKey())
+	}
+}
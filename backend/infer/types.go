@@ -0,0 +1,146 @@
+package infer
+
+import (
+	"fmt"
+	"math"
+
+	"db-importer/parser"
+)
+
+// varcharLengths are the lengths string columns are rounded up to, leaving
+// room for longer values than those seen
+var varcharLengths = []int{16, 32, 64, 128, 255, 512, 1024, 2048, 4000}
+
+// emptyLength is the length of columns that only hold NULLs
+const emptyLength = 255
+
+// varcharLength rounds a length up to the next of varcharLengths, or
+// returns 0 when it exceeds them all
+func varcharLength(n int) int {
+	for _, l := range varcharLengths {
+		if n <= l {
+			return l
+		}
+	}
+	return 0
+}
+
+// SQLType returns the column type of an inferred column in dialect (MySQL
+// when unknown). Integers get the smallest type holding their range and
+// text the next of a few lengths above the longest value.
+func SQLType(c Column, dialect parser.Dialect) string {
+	switch c.Kind {
+	case KindBoolean:
+		if dialect == parser.DialectSQLServer {
+			return "BIT"
+		}
+		return "BOOLEAN"
+	case KindInteger:
+		return integerType(*c.Min, *c.Max, dialect)
+	case KindDecimal:
+		if dialect == parser.DialectPostgres || dialect == parser.DialectSQLite {
+			return fmt.Sprintf("NUMERIC(%d,%d)", c.Precision, c.Scale)
+		}
+		return fmt.Sprintf("DECIMAL(%d,%d)", c.Precision, c.Scale)
+	case KindFloat:
+		switch dialect {
+		case parser.DialectPostgres:
+			return "DOUBLE PRECISION"
+		case parser.DialectSQLServer:
+			return "FLOAT"
+		case parser.DialectSQLite:
+			return "REAL"
+		}
+		return "DOUBLE"
+	case KindDate:
+		return "DATE"
+	case KindTime:
+		return "TIME"
+	case KindDateTime:
+		switch dialect {
+		case parser.DialectPostgres:
+			if c.WithTimeZone {
+				return "TIMESTAMPTZ"
+			}
+			return "TIMESTAMP"
+		case parser.DialectSQLServer:
+			if c.WithTimeZone {
+				return "DATETIMEOFFSET"
+			}
+			return "DATETIME2"
+		}
+		return "DATETIME"
+	}
+
+	length := c.MaxLength
+	if c.Kind == KindEmpty {
+		length = emptyLength
+	}
+	return textType(length, dialect)
+}
+
+func integerType(lo, hi int64, dialect parser.Dialect) string {
+	fits := func(bits uint) bool {
+		limit := int64(1) << (bits - 1)
+		return lo >= -limit && hi <= limit-1
+	}
+
+	switch dialect {
+	case parser.DialectSQLite:
+		return "INTEGER"
+	case parser.DialectPostgres:
+		switch {
+		case fits(16):
+			return "SMALLINT"
+		case fits(32):
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case parser.DialectSQLServer:
+		switch {
+		case fits(16):
+			return "SMALLINT"
+		case fits(32):
+			return "INT"
+		}
+		return "BIGINT"
+	}
+
+	switch {
+	case fits(8):
+		return "TINYINT"
+	case fits(16):
+		return "SMALLINT"
+	case fits(24):
+		return "MEDIUMINT"
+	case lo >= math.MinInt32 && hi <= math.MaxInt32:
+		return "INT"
+	}
+	return "BIGINT"
+}
+
+func textType(length int, dialect parser.Dialect) string {
+	n := varcharLength(length)
+	switch dialect {
+	case parser.DialectSQLite:
+		return "TEXT"
+	case parser.DialectSQLServer:
+		if n == 0 {
+			return "NVARCHAR(MAX)"
+		}
+		return fmt.Sprintf("NVARCHAR(%d)", n)
+	case parser.DialectPostgres:
+		if n == 0 {
+			return "TEXT"
+		}
+		return fmt.Sprintf("VARCHAR(%d)", n)
+	}
+
+	switch {
+	case n > 0:
+		return fmt.Sprintf("VARCHAR(%d)", n)
+	case length <= 16383: // TEXT holds 65535 bytes, up to 4 per character
+		return "TEXT"
+	}
+	return "LONGTEXT"
+}
@@ -0,0 +1,136 @@
+// Package ingest reads tabular data files (CSV) on the server, streaming
+// rows so files larger than memory can be processed, and keeps them as
+// compressed datasets.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupportedFormat is returned for files that cannot be read on the server
+var ErrUnsupportedFormat = errors.New("unsupported data file format (only CSV and TSV can be ingested)")
+
+// delimiters are tried in order when sniffing the header line
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// Supported reports whether a data file can be ingested, by extension
+func Supported(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv", ".tsv", ".txt":
+		return true
+	}
+	return false
+}
+
+// CSVReader streams the rows of a delimited text file whose first record
+// holds the column headers
+type CSVReader struct {
+	reader    *csv.Reader
+	headers   []string
+	delimiter rune
+	rows      int
+}
+
+// NewCSVReader reads the header record, sniffing the delimiter from it
+// (comma, semicolon, tab or pipe) and skipping a UTF-8 byte order mark
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	buffered := bufio.NewReaderSize(r, 64*1024)
+
+	if bom, err := buffered.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		buffered.Discard(3)
+	}
+
+	// Peek at the first line to pick the delimiter
+	head, _ := buffered.Peek(buffered.Size())
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	delimiter := sniffDelimiter(string(head))
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	for i := range headers {
+		headers[i] = strings.TrimSpace(headers[i])
+	}
+
+	return &CSVReader{reader: reader, headers: headers, delimiter: delimiter}, nil
+}
+
+// Headers returns the column headers
+func (c *CSVReader) Headers() []string {
+	return c.headers
+}
+
+// Delimiter returns the detected field delimiter
+func (c *CSVReader) Delimiter() rune {
+	return c.delimiter
+}
+
+// Rows returns the number of data rows read so far
+func (c *CSVReader) Rows() int {
+	return c.rows
+}
+
+// Next returns the next data row, padded or truncated to the number of
+// headers; it returns io.EOF after the last row. Blank lines are skipped.
+func (c *CSVReader) Next() ([]interface{}, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("row %d: %w", c.rows+1, err)
+		}
+		return nil, err
+	}
+	c.rows++
+
+	row := make([]interface{}, len(c.headers))
+	for i := range row {
+		if i < len(record) {
+			row[i] = record[i]
+		} else {
+			row[i] = nil
+		}
+	}
+	return row, nil
+}
+
+// sniffDelimiter picks the candidate delimiter occurring most often in the
+// header line, ignoring quoted sections; comma wins ties and empty lines
+func sniffDelimiter(line string) rune {
+	counts := make(map[rune]int)
+	inQuotes := false
+	for _, ch := range line {
+		if ch == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if !inQuotes {
+			counts[ch]++
+		}
+	}
+
+	best := delimiters[0]
+	for _, d := range delimiters[1:] {
+		if counts[d] > counts[best] {
+			best = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,75 @@
+package ingest
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader_Comma(t *testing.T) {
+	input := "\xEF\xBB\xBFid, name ,email\n1,Alice,a@example.com\n2,\"Bob, Jr.\"\n"
+
+	reader, err := NewCSVReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewCSVReader failed: %v", err)
+	}
+
+	headers := reader.Headers()
+	if len(headers) != 3 || headers[0] != "id" || headers[1] != "name" {
+		t.Fatalf("Unexpected headers: %q", headers)
+	}
+
+	row, err := reader.Next()
+	if err != nil || row[2] != "a@example.com" {
+		t.Fatalf("Unexpected first row: %v (%v)", row, err)
+	}
+
+	row, err = reader.Next()
+	if err != nil || row[1] != "Bob, Jr." || row[2] != nil {
+		t.Fatalf("Expected quoted field and padded row, got %v (%v)", row, err)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if reader.Rows() != 2 {
+		t.Errorf("Expected 2 rows, got %d", reader.Rows())
+	}
+}
+
+func TestCSVReader_SniffsDelimiter(t *testing.T) {
+	tests := []struct {
+		input     string
+		delimiter rune
+	}{
+		{"a;b;c\n1;2;3\n", ';'},
+		{"a\tb\n1\t2\n", '\t'},
+		{"\"x;y\",b\n1,2\n", ','},
+		{"single\nvalue\n", ','},
+	}
+
+	for _, tt := range tests {
+		reader, err := NewCSVReader(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("NewCSVReader(%q) failed: %v", tt.input, err)
+		}
+		if reader.Delimiter() != tt.delimiter {
+			t.Errorf("Input %q: expected delimiter %q, got %q", tt.input, tt.delimiter, reader.Delimiter())
+		}
+	}
+}
+
+func TestCSVReader_Empty(t *testing.T) {
+	if _, err := NewCSVReader(strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an empty file")
+	}
+}
+
+func TestSupported(t *testing.T) {
+	if !Supported("data.CSV") || !Supported("export.tsv") {
+		t.Error("Expected CSV and TSV to be supported")
+	}
+	if Supported("book.xlsx") {
+		t.Error("Expected xlsx to be unsupported")
+	}
+}
@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A dataset is a data file kept on the server after its upload: gzip
+// compressed JSON lines, the headers first and then one array per row.
+// Values keep their JSON type, so CSV cells stay strings and numbers sent
+// by a browser stay numbers.
+
+// DatasetWriter writes a dataset
+type DatasetWriter struct {
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	rows    int
+}
+
+// NewDatasetWriter starts a dataset with the given headers. Close must be
+// called to flush it; it does not close w.
+func NewDatasetWriter(w io.Writer, headers []string) (*DatasetWriter, error) {
+	gz := gzip.NewWriter(w)
+	d := &DatasetWriter{gz: gz, encoder: json.NewEncoder(gz)}
+	if headers == nil {
+		headers = []string{}
+	}
+	if err := d.encoder.Encode(headers); err != nil {
+		return nil, fmt.Errorf("failed to write headers: %w", err)
+	}
+	return d, nil
+}
+
+// Write appends a row
+func (d *DatasetWriter) Write(row []interface{}) error {
+	if err := d.encoder.Encode(row); err != nil {
+		return fmt.Errorf("row %d: %w", d.rows+1, err)
+	}
+	d.rows++
+	return nil
+}
+
+// Rows returns the number of rows written so far
+func (d *DatasetWriter) Rows() int {
+	return d.rows
+}
+
+// Close flushes the dataset
+func (d *DatasetWriter) Close() error {
+	return d.gz.Close()
+}
+
+// DatasetReader streams the rows of a dataset
+type DatasetReader struct {
+	gz      *gzip.Reader
+	decoder *json.Decoder
+	headers []string
+	rows    int
+}
+
+// NewDatasetReader reads the headers of a dataset. Close releases the
+// decompressor; it does not close r.
+func NewDatasetReader(r io.Reader) (*DatasetReader, error) {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dataset: %w", err)
+	}
+
+	d := &DatasetReader{gz: gz, decoder: json.NewDecoder(gz)}
+	if err := d.decoder.Decode(&d.headers); err != nil {
+		gz.Close()
+		return nil, fmt.Errorf("invalid dataset headers: %w", err)
+	}
+	return d, nil
+}
+
+// Headers returns the column headers
+func (d *DatasetReader) Headers() []string {
+	return d.headers
+}
+
+// Rows returns the number of rows read so far
+func (d *DatasetReader) Rows() int {
+	return d.rows
+}
+
+// Next returns the next row; it returns io.EOF after the last one
+func (d *DatasetReader) Next() ([]interface{}, error) {
+	var row []interface{}
+	if err := d.decoder.Decode(&row); err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("row %d: %w", d.rows+1, err)
+		}
+		return nil, err
+	}
+	d.rows++
+	return row, nil
+}
+
+// Close releases the decompressor
+func (d *DatasetReader) Close() error {
+	return d.gz.Close()
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDataset_RoundTrip(t *testing.T) {
+	rows := [][]interface{}{
+		{"1", "Alice", nil},
+		{float64(2), "Bob, Jr.", true},
+		{"3", "Zoë \"Z\"\nNewline", "x"},
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewDatasetWriter(&buf, []string{"id", "name", "flag"})
+	if err != nil {
+		t.Fatalf("NewDatasetWriter failed: %v", err)
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if writer.Rows() != 3 {
+		t.Errorf("Expected 3 rows written, got %d", writer.Rows())
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := NewDatasetReader(&buf)
+	if err != nil {
+		t.Fatalf("NewDatasetReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	if headers := reader.Headers(); !reflect.DeepEqual(headers, []string{"id", "name", "flag"}) {
+		t.Errorf("Unexpected headers %q", headers)
+	}
+
+	var got [][]interface{}
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, row)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("Rows = %v, want %v", got, rows)
+	}
+	if reader.Rows() != 3 {
+		t.Errorf("Expected 3 rows read, got %d", reader.Rows())
+	}
+}
+
+func TestDataset_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewDatasetWriter(&buf, nil)
+	if err != nil {
+		t.Fatalf("NewDatasetWriter failed: %v", err)
+	}
+	writer.Close()
+
+	reader, err := NewDatasetReader(&buf)
+	if err != nil {
+		t.Fatalf("NewDatasetReader failed: %v", err)
+	}
+	if len(reader.Headers()) != 0 {
+		t.Errorf("Expected no headers, got %q", reader.Headers())
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestDataset_Invalid(t *testing.T) {
+	if _, err := NewDatasetReader(bytes.NewBufferString("id,name\n1,Alice\n")); err == nil {
+		t.Error("Expected an error for a file that is not a dataset")
+	}
+}
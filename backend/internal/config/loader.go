@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"db-importer/internal/utils"
+	"db-importer/ratelimit"
 
 	"github.com/joho/godotenv"
 )
@@ -28,12 +29,73 @@ type Config struct {
 	JWTAccessExpiry  time.Duration
 	JWTRefreshExpiry time.Duration
 
+	// CredentialsKey encrypts stored database credentials (connection profiles)
+	CredentialsKey string
+
 	// CORS
 	AllowedOrigins []string
 
+	// TrustedProxies lists the reverse proxies whose X-Forwarded-For and
+	// X-Real-IP headers are believed, see utils.ParseTrustedProxies
+	TrustedProxies string
+
+	// AppURL is the frontend base URL used in links sent by email
+	AppURL string
+
+	// Email (verification and password reset links)
+	EmailTokenSecret string // signs the tokens in links; defaults to the JWT refresh secret
+	MailSender       string // file (written to MailFileDir, or logged) or smtp
+	MailFrom         string
+	MailFileDir      string
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+
+	// Single sign-on through an OpenID Connect provider (disabled without
+	// an issuer)
+	OIDCIssuerURL      string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string // this server's /auth/oidc/callback as registered at the provider
+	OIDCScopes         []string
+	OIDCProviderName   string // label of the sign-in button
+	OIDCAutoProvision  bool   // create users on their first sign-in
+	OIDCTrustEmail     bool   // treat provider emails as verified without email_verified
+	OIDCEmailClaim     string
+	OIDCFirstNameClaim string
+	OIDCLastNameClaim  string
+
+	// Two-factor authentication (needs CREDENTIALS_ENCRYPTION_KEY)
+	MFAIssuer string // service name shown by authenticator apps
+
+	// Brute-force protection of logins per account
+	LoginDelayAfter      int           // failed logins after which attempts are delayed (0 = never)
+	LoginLockoutAfter    int           // failed logins that lock the account (0 = never)
+	LoginLockoutDuration time.Duration // how long a lockout lasts
+
+	// Account data export and deletion
+	AccountDeletionGrace time.Duration // how long a requested deletion can be canceled
+	AccountExportTTL     time.Duration // how long a finished export can be downloaded
+
 	// Upload
 	MaxUploadSize int64
 
+	// Object storage (uploads, generated SQL, schema content)
+	StorageBackend  string // local or s3
+	StorageLocalDir string // root directory of the local backend
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKeyID   string
+	S3SecretKey     string
+	S3PathStyle     bool // address the bucket in the path (MinIO and most self-hosted services)
+
+	// Chunked uploads
+	MaxChunkedUploadSize int64         // maximum size of a chunked upload
+	UploadChunkMaxSize   int64         // maximum bytes per chunk request
+	UploadTTL            time.Duration // lifetime of unfinished and completed uploads
+
 	// Database
 	DatabaseURL       string
 	DBMaxOpenConns    int
@@ -41,11 +103,26 @@ type Config struct {
 	DBConnMaxLifetime time.Duration
 	DBConnMaxIdleTime time.Duration
 
+	// Introspection
+	// SQLiteIntrospectionDir is the only directory SQLite connection profiles
+	// may point into; empty disables SQLite profiles
+	SQLiteIntrospectionDir string
+
+	// SQLExecutionEnabled exposes running generated SQL against saved
+	// connections (intended for internal environments)
+	SQLExecutionEnabled bool
+
+	// Background jobs
+	JobWorkers      int           // workers on this instance (0 = only queue jobs)
+	JobPollInterval time.Duration // how often idle workers check the queue
+
 	// Features
-	RateLimitEnabled bool
-	RateLimitGuest   int // requests per window for guest users
-	RateLimitAuth    int // requests per window for authenticated users (0 = unlimited)
-	RateLimitWindow  int // window in seconds
+	RateLimitEnabled  bool
+	RateLimitGuest    int    // requests per window for guest users
+	RateLimitAuth     int    // requests per window for authenticated users (0 = unlimited)
+	RateLimitWindow   int    // window in seconds
+	RateLimitStore    string // memory or postgres
+	RateLimitPolicies string // rules per route and caller class taking precedence, see ratelimit.ParsePolicies
 
 	// Observability
 	EnableDebugLog bool
@@ -98,13 +175,66 @@ func Load() (*Config, error) {
 		JWTRefreshSecret: getOrGenerateSecret("JWT_REFRESH_SECRET", env),
 		JWTAccessExpiry:  getDuration("JWT_ACCESS_EXPIRY", 15*time.Minute),
 		JWTRefreshExpiry: getDuration("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
+		CredentialsKey:   getOrGenerateSecret("CREDENTIALS_ENCRYPTION_KEY", env),
 
 		// CORS
 		AllowedOrigins: parseOrigins(getEnv("ALLOWED_ORIGINS", "*")),
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+		AppURL:         getEnv("APP_URL", "http://localhost:5173"),
+
+		// Email
+		EmailTokenSecret: os.Getenv("EMAIL_TOKEN_SECRET"),
+		MailSender:       strings.ToLower(getEnv("MAIL_SENDER", "file")),
+		MailFrom:         getEnv("MAIL_FROM", "DB Importer <no-reply@localhost>"),
+		MailFileDir:      os.Getenv("MAIL_FILE_DIR"),
+		SMTPHost:         os.Getenv("SMTP_HOST"),
+		SMTPPort:         getInt("SMTP_PORT", 587),
+		SMTPUsername:     os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+
+		// Single sign-on
+		OIDCIssuerURL:      os.Getenv("OIDC_ISSUER_URL"),
+		OIDCClientID:       os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:         strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		OIDCProviderName:   getEnv("OIDC_PROVIDER_NAME", "Single sign-on"),
+		OIDCAutoProvision:  getBool("OIDC_AUTO_PROVISION", true),
+		OIDCTrustEmail:     getBool("OIDC_TRUST_EMAIL", false),
+		OIDCEmailClaim:     os.Getenv("OIDC_EMAIL_CLAIM"),
+		OIDCFirstNameClaim: os.Getenv("OIDC_FIRST_NAME_CLAIM"),
+		OIDCLastNameClaim:  os.Getenv("OIDC_LAST_NAME_CLAIM"),
+
+		// Two-factor authentication
+		MFAIssuer: getEnv("MFA_ISSUER", "DB Importer"),
+
+		// Brute-force protection
+		LoginDelayAfter:      getInt("LOGIN_DELAY_AFTER", 3),
+		LoginLockoutAfter:    getInt("LOGIN_LOCKOUT_AFTER", 10),
+		LoginLockoutDuration: getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+
+		// Account data export and deletion
+		AccountDeletionGrace: getDuration("ACCOUNT_DELETION_GRACE", 14*24*time.Hour),
+		AccountExportTTL:     getDuration("ACCOUNT_EXPORT_TTL", 7*24*time.Hour),
 
 		// Upload
 		MaxUploadSize: getInt64("MAX_UPLOAD_SIZE", 52428800), // 50MB default
 
+		// Object storage
+		StorageBackend:  strings.ToLower(getEnv("STORAGE_BACKEND", "local")),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "./data/storage"),
+		S3Endpoint:      os.Getenv("S3_ENDPOINT"),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:   os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:     os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3PathStyle:     getBool("S3_PATH_STYLE", false),
+
+		// Chunked uploads
+		MaxChunkedUploadSize: getInt64("MAX_CHUNKED_UPLOAD_SIZE", 2147483648), // 2GB default
+		UploadChunkMaxSize:   getInt64("UPLOAD_CHUNK_MAX_SIZE", 16777216),     // 16MB default
+		UploadTTL:            getDuration("UPLOAD_TTL", 24*time.Hour),
+
 		// Database
 		DatabaseURL:       os.Getenv("DATABASE_URL"),
 		DBMaxOpenConns:    getInt("DB_MAX_OPEN_CONNS", 25),
@@ -112,11 +242,21 @@ func Load() (*Config, error) {
 		DBConnMaxLifetime: getDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 		DBConnMaxIdleTime: getDuration("DB_CONN_MAX_IDLE_TIME", 10*time.Minute),
 
+		// Introspection
+		SQLiteIntrospectionDir: os.Getenv("SQLITE_INTROSPECTION_DIR"),
+		SQLExecutionEnabled:    getBool("SQL_EXECUTION_ENABLED", false),
+
+		// Background jobs
+		JobWorkers:      getInt("JOB_WORKERS", 2),
+		JobPollInterval: getDuration("JOB_POLL_INTERVAL", time.Second),
+
 		// Rate limiting
-		RateLimitEnabled: getBool("RATE_LIMIT_ENABLED", true),
-		RateLimitGuest:   getInt("RATE_LIMIT_GUEST", 3),
-		RateLimitAuth:    getInt("RATE_LIMIT_AUTH", 0),       // 0 = unlimited
-		RateLimitWindow:  getInt("RATE_LIMIT_WINDOW", 86400), // 24h default for guest
+		RateLimitEnabled:  getBool("RATE_LIMIT_ENABLED", true),
+		RateLimitGuest:    getInt("RATE_LIMIT_GUEST", 3),
+		RateLimitAuth:     getInt("RATE_LIMIT_AUTH", 0),       // 0 = unlimited
+		RateLimitWindow:   getInt("RATE_LIMIT_WINDOW", 86400), // 24h default for guest
+		RateLimitStore:    strings.ToLower(getEnv("RATE_LIMIT_STORE", "postgres")),
+		RateLimitPolicies: getEnv("RATE_LIMIT_POLICIES", ""),
 
 		// Observability
 		EnableDebugLog: getBool("DEBUG_LOG", env == "development"),
@@ -144,10 +284,58 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.JobWorkers < 0 {
+		return fmt.Errorf("JOB_WORKERS must not be negative")
+	}
+
 	if c.MaxUploadSize <= 0 {
 		return fmt.Errorf("MAX_UPLOAD_SIZE must be positive")
 	}
 
+	if c.MaxChunkedUploadSize <= 0 || c.UploadChunkMaxSize <= 0 {
+		return fmt.Errorf("MAX_CHUNKED_UPLOAD_SIZE and UPLOAD_CHUNK_MAX_SIZE must be positive")
+	}
+
+	if c.StorageBackend != "local" && c.StorageBackend != "s3" {
+		return fmt.Errorf("STORAGE_BACKEND must be local or s3")
+	}
+
+	if c.UploadTTL <= 0 {
+		return fmt.Errorf("UPLOAD_TTL must be positive")
+	}
+
+	if c.AccountDeletionGrace <= 0 || c.AccountExportTTL <= 0 {
+		return fmt.Errorf("ACCOUNT_DELETION_GRACE and ACCOUNT_EXPORT_TTL must be positive")
+	}
+
+	if _, err := utils.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "postgres" {
+		return fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres")
+	}
+
+	if c.RateLimitWindow <= 0 {
+		return fmt.Errorf("RATE_LIMIT_WINDOW must be positive")
+	}
+
+	if _, err := ratelimit.ParsePolicies(c.RateLimitPolicies); err != nil {
+		return fmt.Errorf("RATE_LIMIT_POLICIES: %w", err)
+	}
+
+	if c.MailSender != "file" && c.MailSender != "smtp" {
+		return fmt.Errorf("MAIL_SENDER must be file or smtp")
+	}
+
+	if c.MailSender == "smtp" && c.SMTPHost == "" {
+		return fmt.Errorf("SMTP_HOST is required when MAIL_SENDER is smtp")
+	}
+
+	if c.OIDCIssuerURL != "" && (c.OIDCClientID == "" || c.OIDCRedirectURL == "") {
+		return fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
 	return nil
 }
 
@@ -171,6 +359,14 @@ func (c *Config) JWTConfig() utils.JWTConfig {
 	}
 }
 
+// EmailTokenKey returns the key signing the tokens in emailed links
+func (c *Config) EmailTokenKey() []byte {
+	if c.EmailTokenSecret != "" {
+		return []byte(c.EmailTokenSecret)
+	}
+	return []byte(c.JWTRefreshSecret)
+}
+
 // Helper functions
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+)
+
+// AccountDataHandler handles users exporting their data and deleting their
+// account
+type AccountDataHandler struct {
+	accountService *service.AccountService
+	exportService  *service.AccountExportService
+}
+
+// NewAccountDataHandler creates a new AccountDataHandler
+func NewAccountDataHandler(accountService *service.AccountService, exportService *service.AccountExportService) *AccountDataHandler {
+	return &AccountDataHandler{
+		accountService: accountService,
+		exportService:  exportService,
+	}
+}
+
+// RequestExport handles requesting an export of the user's data
+// @Summary      Export account data
+// @Description  Queue a zip archive of everything stored for the signed-in user: profile and organizations, imports with their SQL and rollback scripts, workflow sessions with their schema and data file, and the audit events they caused. Follow the returned job with /api/v1/jobs/get and download the archive from /auth/account/export/download once it succeeded. While an export is queued or running, it is returned instead of a new one
+// @Tags         Account
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  models.JobResponse      "Export queued"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      503  {object}  map[string]interface{}  "Object storage is not configured"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/account/export [post]
+func (h *AccountDataHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.exportService.RequestExport(r.Context(), uid)
+	if err != nil {
+		respondAccountExportError(w, "Failed to export account data", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusAccepted, job, "Account export queued")
+}
+
+// DownloadExport handles downloading a finished export
+// @Summary      Download account export
+// @Description  Stream the zip archive of a succeeded account export. Archives are kept for ACCOUNT_EXPORT_TTL (7 days by default) after the export finished. Range requests are supported
+// @Tags         Account
+// @Produce      application/zip
+// @Security     BearerAuth
+// @Param        id     query     string  true   "Export job UUID"
+// @Param        Range  header    string  false  "Byte range of the download, e.g. bytes=0-1023"
+// @Success      200    {file}    file                    "Account export"
+// @Success      206    {file}    file                    "Requested range of the account export"
+// @Failure      400    {object}  map[string]interface{}  "Invalid or missing export job ID"
+// @Failure      401    {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404    {object}  map[string]interface{}  "Export not found"
+// @Failure      409    {object}  map[string]interface{}  "Export has not finished"
+// @Failure      410    {object}  map[string]interface{}  "Export has expired"
+// @Router       /auth/account/export/download [get]
+func (h *AccountDataHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.URL.Query().Get("id")
+	if jobID == "" {
+		utils.BadRequest(w, "Missing export job ID")
+		return
+	}
+
+	id, err := utils.ParseUUID(jobID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid export job ID")
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := h.exportService.OpenExport(r.Context(), uid, id)
+	if err != nil {
+		respondAccountExportError(w, "Failed to open account export", err)
+		return
+	}
+	defer file.Close()
+
+	// Large archives take longer than the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	http.ServeContent(w, r, file.Name, file.ModTime, file)
+}
+
+// DeleteAccount handles scheduling the deletion of the user's account
+// @Summary      Delete account
+// @Description  Schedule the deletion of the signed-in user's account after ACCOUNT_DELETION_GRACE (14 days by default), confirmed with the password, or the email address for accounts without one. Until then it can be canceled with /auth/account/delete/cancel. Then the account is deleted for good with its imports, workflow sessions, refresh tokens, stored files and the organizations it is the only member of; its audit events are kept without email or IP addresses. The only owner of organizations with other members has to hand them over first. Asking again keeps the first date
+// @Tags         Account
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.DeleteAccountRequest     true  "Password, or email address for accounts without a password"
+// @Success      200      {object}  models.AccountDeletionResponse  "Deletion scheduled"
+// @Failure      400      {object}  map[string]interface{}          "Invalid request body"
+// @Failure      401      {object}  map[string]interface{}          "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}          "Wrong password or email address"
+// @Failure      409      {object}  map[string]interface{}          "Only owner of organizations with other members"
+// @Failure      500      {object}  map[string]interface{}          "Internal server error"
+// @Router       /auth/account/delete [post]
+func (h *AccountDataHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.DeleteAccountRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	deletion, err := h.accountService.ScheduleDeletion(r.Context(), uid, &req)
+	if err != nil {
+		var soleOwner *service.SoleOwnerError
+		switch {
+		case errors.Is(err, service.ErrDeletionConfirmation):
+			utils.RespondError(w, http.StatusForbidden, utils.ErrInvalidCredentials, err.Error(), nil)
+		case errors.As(err, &soleOwner):
+			utils.RespondError(w, http.StatusConflict, utils.ErrConflict, err.Error(), map[string]interface{}{
+				"organizations": soleOwner.Organizations,
+			})
+		case err.Error() == "user not found":
+			utils.NotFound(w, "User not found")
+		default:
+			utils.InternalServerError(w, "Failed to schedule account deletion: "+err.Error())
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, deletion, "Account deletion scheduled")
+}
+
+// CancelDeletion handles canceling the scheduled deletion of the user's
+// account
+// @Summary      Cancel account deletion
+// @Description  Cancel the scheduled deletion of the signed-in user's account
+// @Tags         Account
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Deletion canceled"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      409  {object}  map[string]interface{}  "No deletion is scheduled"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/account/delete/cancel [post]
+func (h *AccountDataHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.CancelDeletion(r.Context(), uid); err != nil {
+		if errors.Is(err, service.ErrDeletionNotScheduled) {
+			utils.Conflict(w, err.Error())
+			return
+		}
+		utils.InternalServerError(w, "Failed to cancel account deletion: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Account deletion canceled")
+}
+
+// respondAccountExportError maps account export errors to HTTP responses
+func respondAccountExportError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrExportUnavailable):
+		utils.RespondError(w, http.StatusServiceUnavailable, utils.ErrInternalServer, err.Error(), nil)
+	case errors.Is(err, service.ErrExportNotReady):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrExportExpired):
+		utils.RespondError(w, http.StatusGone, utils.ErrNotFound, err.Error(), nil)
+	case err.Error() == "job not found":
+		utils.NotFound(w, "Export not found")
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
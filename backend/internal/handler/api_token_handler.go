@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+)
+
+// APITokenHandler handles personal access token HTTP requests
+type APITokenHandler struct {
+	tokenService *service.APITokenService
+}
+
+// NewAPITokenHandler creates a new APITokenHandler
+func NewAPITokenHandler(tokenService *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+// CreateToken handles creating a personal access token
+// @Summary      Create API token
+// @Description  Create a long-lived personal access token for scripts such as CI pipelines, sent as "Authorization: Bearer dbi_pat_..."
+// @Description  Scopes: schema:parse, sql:generate, imports:read, imports:write. The token is only returned once
+// @Tags         API Tokens
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateAPITokenRequest  true  "Token name, scopes and optional expiry"
+// @Success      201      {object}  models.CreateAPITokenResponse "Token created, with its value"
+// @Failure      400      {object}  map[string]interface{}        "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}        "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}        "API tokens cannot create tokens"
+// @Failure      409      {object}  map[string]interface{}        "Too many active tokens"
+// @Failure      500      {object}  map[string]interface{}        "Internal server error"
+// @Router       /api/v1/tokens [post]
+func (h *APITokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPITokenRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := h.tokenService.CreateToken(r.Context(), uid, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyAPITokens) {
+			utils.Conflict(w, err.Error())
+			return
+		}
+		utils.InternalServerError(w, "Failed to create API token: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, token, "API token created; copy it now, it will not be shown again")
+}
+
+// ListTokens handles listing the user's personal access tokens
+// @Summary      List API tokens
+// @Description  List personal access tokens with their scopes, expiry and last use; token values are never returned
+// @Tags         API Tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.APIToken         "API tokens"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/tokens [get]
+func (h *APITokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.tokenService.ListTokens(r.Context(), uid)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to list API tokens: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, tokens, "")
+}
+
+// RevokeToken handles revoking a personal access token
+// @Summary      Revoke API token
+// @Description  Revoke a personal access token; requests made with it are refused from then on
+// @Tags         API Tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "API token UUID"
+// @Success      200  {object}  map[string]interface{}  "API token revoked"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing token ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "API token not found or already revoked"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/tokens/revoke [post]
+func (h *APITokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenID := r.URL.Query().Get("id")
+	if tokenID == "" {
+		utils.BadRequest(w, "Missing API token ID")
+		return
+	}
+
+	// Parse token ID
+	id, err := utils.ParseUUID(tokenID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid API token ID")
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tokenService.RevokeToken(r.Context(), uid, id); err != nil {
+		if err.Error() == "API token not found" {
+			utils.NotFound(w, "API token not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to revoke API token: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "API token revoked successfully")
+}
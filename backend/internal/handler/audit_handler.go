@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+)
+
+// AuditHandler handles reading the audit log. Users read their own
+// personal events; organization admins read every event of the
+// organization selected with the X-Organization-ID header.
+type AuditHandler struct {
+	auditService *service.AuditService
+	orgService   *service.OrganizationService
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(auditService *service.AuditService, orgService *service.OrganizationService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		orgService:   orgService,
+	}
+}
+
+// ListEvents handles listing audit events
+// @Summary      List audit events
+// @Description  List audit events of the workspace, newest first: the user's personal events, or with X-Organization-ID every event of the organization (admins and owners only). action matches exactly or, ending in ".", by prefix (e.g. "import.")
+// @Tags         Audit
+// @Produce      json
+// @Security     BearerAuth
+// @Param        action      query     string  false  "Action, or action prefix ending in '.'"
+// @Param        actorId     query     string  false  "Actor user UUID"
+// @Param        targetType  query     string  false  "Target type, e.g. import"
+// @Param        targetId    query     string  false  "Target ID"
+// @Param        from        query     string  false  "Occurred at or after (RFC 3339)"
+// @Param        to          query     string  false  "Occurred before (RFC 3339)"
+// @Param        page        query     int     false  "Page number (default 1)"
+// @Param        pageSize    query     int     false  "Page size (default 20, max 100)"
+// @Success      200         {object}  models.GetAuditEventsResponse  "Audit events"
+// @Failure      400         {object}  map[string]interface{}         "Invalid filter"
+// @Failure      401         {object}  map[string]interface{}         "Unauthorized"
+// @Failure      403         {object}  map[string]interface{}         "Not an admin of the organization"
+// @Failure      500         {object}  map[string]interface{}         "Internal server error"
+// @Router       /api/v1/audit [get]
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws, filter, ok := h.auditRequest(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.auditService.ListEvents(r.Context(), ws, filter)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to list audit events: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, events, "")
+}
+
+// ExportEvents handles downloading audit events as NDJSON
+// @Summary      Export audit events
+// @Description  Download every audit event matching the filters of /api/v1/audit as newline-delimited JSON, oldest first. The export is itself recorded
+// @Tags         Audit
+// @Produce      application/x-ndjson
+// @Security     BearerAuth
+// @Param        action      query     string  false  "Action, or action prefix ending in '.'"
+// @Param        actorId     query     string  false  "Actor user UUID"
+// @Param        targetType  query     string  false  "Target type, e.g. import"
+// @Param        targetId    query     string  false  "Target ID"
+// @Param        from        query     string  false  "Occurred at or after (RFC 3339)"
+// @Param        to          query     string  false  "Occurred before (RFC 3339)"
+// @Success      200         {file}    file                    "One audit event per line"
+// @Failure      400         {object}  map[string]interface{}  "Invalid filter"
+// @Failure      401         {object}  map[string]interface{}  "Unauthorized"
+// @Failure      403         {object}  map[string]interface{}  "Not an admin of the organization"
+// @Failure      500         {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/audit/export [get]
+func (h *AuditHandler) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws, filter, ok := h.auditRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Headers are sent with the first event so that a failure before it
+	// can still be reported as an error
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "audit_"+time.Now().UTC().Format("20060102_150405")+".ndjson"))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	encoder := json.NewEncoder(w)
+	err := h.auditService.ExportEvents(r.Context(), ws, filter, func(event *models.AuditEvent) error {
+		start()
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		if !started {
+			utils.InternalServerError(w, "Failed to export audit events: "+err.Error())
+			return
+		}
+		// The response is under way; all that is left is to cut it short
+		logger.Error("Audit export interrupted", err)
+		return
+	}
+
+	start()
+}
+
+// auditRequest resolves the workspace of an audit request, which takes an
+// admin in an organization, and parses its filter, responding with an
+// error if either fails
+func (h *AuditHandler) auditRequest(w http.ResponseWriter, r *http.Request) (models.Workspace, *models.AuditFilter, bool) {
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return models.Workspace{}, nil, false
+	}
+
+	queryParams := r.URL.Query()
+	filter := &models.AuditFilter{
+		Action:     queryParams.Get("action"),
+		TargetType: queryParams.Get("targetType"),
+		TargetID:   queryParams.Get("targetId"),
+	}
+
+	if actorStr := queryParams.Get("actorId"); actorStr != "" {
+		actorID, err := utils.ParseUUID(actorStr)
+		if err != nil {
+			utils.BadRequest(w, "Invalid actorId")
+			return models.Workspace{}, nil, false
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := queryParams.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.BadRequest(w, "Invalid "+name+": expected an RFC 3339 time such as 2006-01-02T15:04:05Z")
+				return models.Workspace{}, nil, false
+			}
+			*dst = &t
+		}
+	}
+
+	// Parse page
+	if pageStr := queryParams.Get("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err == nil && page > 0 {
+			filter.Page = page
+		}
+	}
+
+	// Parse pageSize
+	if pageSizeStr := queryParams.Get("pageSize"); pageSizeStr != "" {
+		pageSize, err := strconv.Atoi(pageSizeStr)
+		if err == nil && pageSize > 0 && pageSize <= 100 {
+			filter.PageSize = pageSize
+		}
+	}
+
+	ws, err := h.orgService.Workspace(r.Context(), uid, models.RoleAdmin)
+	if err != nil {
+		if !respondWorkspaceError(w, err) {
+			utils.InternalServerError(w, "Failed to resolve workspace: "+err.Error())
+		}
+		return models.Workspace{}, nil, false
+	}
+
+	return ws, filter, true
+}
@@ -3,22 +3,29 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"db-importer/internal/models"
 	"db-importer/internal/service"
 	"db-importer/internal/utils"
+	"db-importer/logger"
 )
 
 // AuthHandler handles authentication HTTP requests
 type AuthHandler struct {
-	authService *service.AuthService
+	authService    *service.AuthService
+	accountService *service.AccountService
+	orgService     *service.OrganizationService
 }
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, accountService *service.AccountService, orgService *service.OrganizationService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:    authService,
+		accountService: accountService,
+		orgService:     orgService,
 	}
 }
 
@@ -26,6 +33,7 @@ func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 // @Summary      Register new user
 // @Description  Create a new user account with email and password
 // @Description  Password must be at least 8 characters. Email must be unique.
+// @Description  A link confirming the email address is sent to it.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -61,12 +69,21 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account works without a verified address; a failed email can
+	// be sent again from /auth/verify-email/send
+	if err := h.accountService.SendVerificationEmail(r.Context(), user.ID); err != nil {
+		logger.Error("Failed to send verification email", err, map[string]interface{}{
+			"userId": user.ID.String(),
+		})
+	}
+
 	utils.RespondSuccess(w, http.StatusCreated, user, "User registered successfully")
 }
 
 // Login handles user login
 // @Summary      User login
 // @Description  Authenticate user with email and password, returns JWT access and refresh tokens
+// @Description  Users with two-factor authentication get {"mfaRequired": true, "mfaToken": "..."} instead and complete the sign-in with /auth/login/mfa
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -75,6 +92,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Failure      400      {object}  map[string]interface{}  "Invalid request body or validation failed"
 // @Failure      401      {object}  map[string]interface{}  "Invalid credentials"
 // @Failure      403      {object}  map[string]interface{}  "User account not active"
+// @Failure      429      {object}  map[string]interface{}  "Too many failed logins; see Retry-After"
 // @Failure      500      {object}  map[string]interface{}  "Internal server error"
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -95,6 +113,9 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Login user
 	loginResp, err := h.authService.Login(r.Context(), &req)
 	if err != nil {
+		if respondLoginThrottled(w, err) {
+			return
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			utils.RespondError(w, http.StatusUnauthorized, utils.ErrInvalidCredentials, "Invalid email or password", nil)
 			return
@@ -107,21 +128,103 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set access token in HTTP-only cookie
+	// The second step sets the cookies
+	if loginResp.MFARequired {
+		utils.RespondSuccess(w, http.StatusOK, map[string]interface{}{
+			"mfaRequired": true,
+			"mfaToken":    loginResp.MFAToken,
+		}, "Two-factor authentication required")
+		return
+	}
+
+	setSessionCookies(w, r, loginResp, req.RememberMe)
+
+	// Return user data only (tokens are in cookies now)
+	utils.RespondSuccess(w, http.StatusOK, map[string]interface{}{
+		"user": loginResp.User,
+	}, "Login successful")
+}
+
+// LoginMFA handles the second step of a login with two-factor authentication
+// @Summary      Complete login with two-factor authentication
+// @Description  Complete a login that returned mfaRequired with its mfaToken and a code from the authenticator app or a recovery code. The token is valid for 5 minutes and 5 attempts
+// @Description  Sets the same cookies as /auth/login
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.MFALoginRequest  true  "MFA token and code"
+// @Success      200      {object}  map[string]interface{}  "Login successful with tokens and user data"
+// @Failure      400      {object}  map[string]interface{}  "Invalid request body or validation failed"
+// @Failure      401      {object}  map[string]interface{}  "Invalid code, or token invalid, expired or out of attempts"
+// @Failure      403      {object}  map[string]interface{}  "User account not active"
+// @Failure      429      {object}  map[string]interface{}  "Too many failed logins; see Retry-After"
+// @Failure      500      {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	loginResp, rememberMe, err := h.authService.CompleteMFALogin(r.Context(), &req)
+	if err != nil {
+		if respondLoginThrottled(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrMFAInvalidCode):
+			utils.RespondError(w, http.StatusUnauthorized, utils.ErrInvalidCredentials, err.Error(), nil)
+		case errors.Is(err, service.ErrMFAChallengeInvalid):
+			utils.RespondError(w, http.StatusUnauthorized, utils.ErrTokenInvalid, err.Error(), nil)
+		case errors.Is(err, service.ErrUserNotActive):
+			utils.RespondError(w, http.StatusForbidden, utils.ErrForbidden, "User account is not active", nil)
+		default:
+			utils.InternalServerError(w, "Failed to login")
+		}
+		return
+	}
+
+	setSessionCookies(w, r, loginResp, rememberMe)
+
+	utils.RespondSuccess(w, http.StatusOK, map[string]interface{}{
+		"user": loginResp.User,
+	}, "Login successful")
+}
+
+// setSessionCookies stores the tokens of a new session in HTTP-only cookies
+func setSessionCookies(w http.ResponseWriter, r *http.Request, loginResp *models.LoginResponse, rememberMe bool) {
 	utils.SetCookie(w, r, "access_token", loginResp.AccessToken, 15*60) // 15 minutes
 
-	// Set refresh token in HTTP-only cookie
 	// MaxAge depends on rememberMe (1 day or 3 days)
 	refreshMaxAge := 24 * 60 * 60 // 1 day in seconds
-	if req.RememberMe {
+	if rememberMe {
 		refreshMaxAge = 72 * 60 * 60 // 3 days in seconds
 	}
 	utils.SetCookie(w, r, "refresh_token", loginResp.RefreshToken, refreshMaxAge)
+}
 
-	// Return user data only (tokens are in cookies now)
-	utils.RespondSuccess(w, http.StatusOK, map[string]interface{}{
-		"user": loginResp.User,
-	}, "Login successful")
+// respondLoginThrottled responds to logins refused after too many failures
+// and reports whether err was one
+func respondLoginThrottled(w http.ResponseWriter, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.RespondError(w, http.StatusTooManyRequests, utils.ErrTooManyRequests, throttled.Error(), map[string]interface{}{
+		"retryAfterSeconds": seconds,
+		"locked":            throttled.Locked,
+	})
+	return true
 }
 
 // RefreshToken handles token refresh
@@ -184,7 +287,8 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, http.StatusOK, nil, "Logout successful")
 }
 
-// Me returns the current authenticated user
+// Me returns the current authenticated user with the organizations they
+// belong to
 // GET /auth/me
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
@@ -200,5 +304,186 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, user, "")
+	orgs, err := h.orgService.ListOrganizations(r.Context(), uid)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to list organizations")
+		return
+	}
+
+	resp := &models.MeResponse{UserResponse: user, Organizations: orgs}
+	if orgID, ok := service.OrganizationFromContext(r.Context()); ok {
+		for _, org := range orgs {
+			if org.ID == orgID {
+				resp.ActiveOrganizationID = &org.ID
+				break
+			}
+		}
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, resp, "")
+}
+
+// SendVerificationEmail handles sending another email verification link
+// @Summary      Send verification email
+// @Description  Email the signed-in user a new link confirming their address; earlier links stop working. At most one email per minute
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Verification email sent"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      409  {object}  map[string]interface{}  "Email address already verified"
+// @Failure      429  {object}  map[string]interface{}  "An email was sent less than a minute ago"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/verify-email/send [post]
+func (h *AuthHandler) SendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.SendVerificationEmail(r.Context(), uid); err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			utils.Conflict(w, err.Error())
+		case errors.Is(err, service.ErrEmailThrottled):
+			utils.RespondError(w, http.StatusTooManyRequests, utils.ErrTooManyRequests, err.Error(), nil)
+		default:
+			utils.InternalServerError(w, "Failed to send verification email: "+err.Error())
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Verification email sent")
+}
+
+// VerifyEmail handles confirming an email address
+// @Summary      Verify email address
+// @Description  Mark the email address verified with the token from a verification link. Each link works once, for 48 hours
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.VerifyEmailRequest  true  "Token from the verification link"
+// @Success      200      {object}  models.UserResponse        "Email address verified"
+// @Failure      400      {object}  map[string]interface{}     "Invalid request, or invalid, expired or used token"
+// @Failure      500      {object}  map[string]interface{}     "Internal server error"
+// @Router       /auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.VerifyEmailRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	user, err := h.accountService.VerifyEmail(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrEmailTokenInvalid) {
+			utils.RespondError(w, http.StatusBadRequest, utils.ErrTokenInvalid, err.Error(), nil)
+			return
+		}
+		utils.InternalServerError(w, "Failed to verify email address")
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, user, "Email address verified")
+}
+
+// ForgotPassword handles requesting a password reset link
+// @Summary      Request password reset
+// @Description  Email a password reset link to the address if it belongs to an active account. The response is the same either way, so it does not reveal which addresses have accounts
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ForgotPasswordRequest  true  "Account email address"
+// @Success      200      {object}  map[string]interface{}        "Reset link sent if the account exists"
+// @Failure      400      {object}  map[string]interface{}        "Invalid request body or validation failed"
+// @Router       /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	h.accountService.RequestPasswordReset(r.Context(), &req)
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "If an account uses this address, a password reset link was sent to it")
+}
+
+// ResetPassword handles choosing a new password
+// @Summary      Reset password
+// @Description  Set a new password with the token from a password reset link. Each link works once, for an hour; every session of the account is signed out
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.ResetPasswordRequest  true  "Token from the reset link and new password"
+// @Success      200      {object}  map[string]interface{}       "Password reset"
+// @Failure      400      {object}  map[string]interface{}       "Invalid request, or invalid, expired or used token"
+// @Failure      500      {object}  map[string]interface{}       "Internal server error"
+// @Router       /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ResetPasswordRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	if err := h.accountService.ResetPassword(r.Context(), &req); err != nil {
+		if errors.Is(err, service.ErrEmailTokenInvalid) {
+			utils.RespondError(w, http.StatusBadRequest, utils.ErrTokenInvalid, err.Error(), nil)
+			return
+		}
+		utils.InternalServerError(w, "Failed to reset password")
+		return
+	}
+
+	// Sessions were revoked, including this browser's
+	utils.ClearCookie(w, r, "access_token")
+	utils.ClearCookie(w, r, "refresh_token")
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Password reset; sign in with the new password")
 }
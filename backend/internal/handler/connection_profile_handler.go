@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+)
+
+// ConnectionProfileHandler handles connection profile HTTP requests
+type ConnectionProfileHandler struct {
+	profileService *service.ConnectionProfileService
+}
+
+// NewConnectionProfileHandler creates a new ConnectionProfileHandler
+func NewConnectionProfileHandler(profileService *service.ConnectionProfileService) *ConnectionProfileHandler {
+	return &ConnectionProfileHandler{
+		profileService: profileService,
+	}
+}
+
+// CreateProfile handles saving a new connection profile
+// @Summary      Create connection profile
+// @Description  Save a database connection (PostgreSQL, MySQL or SQLite) for live schema introspection
+// @Description  The connection string is encrypted at rest and never returned; set test=true to connect before saving
+// @Tags         Connection Profiles
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateConnectionProfileRequest  true  "Connection details"
+// @Success      201      {object}  map[string]interface{}                 "Connection profile created"
+// @Failure      400      {object}  map[string]interface{}                 "Invalid request, validation failed or connection test failed"
+// @Failure      401      {object}  map[string]interface{}                 "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}                 "A profile with this name already exists"
+// @Failure      500      {object}  map[string]interface{}                 "Internal server error"
+// @Router       /api/v1/connections [post]
+func (h *ConnectionProfileHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateConnectionProfileRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := h.profileService.CreateProfile(r.Context(), uid, &req)
+	if err != nil {
+		respondConnectionProfileError(w, "Failed to create connection profile", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, profile, "Connection profile created successfully")
+}
+
+// ListProfiles handles listing the user's connection profiles
+// @Summary      List connection profiles
+// @Description  List saved database connections with redacted connection strings
+// @Tags         Connection Profiles
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Connection profiles"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/connections [get]
+func (h *ConnectionProfileHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	profiles, err := h.profileService.ListProfiles(r.Context(), uid)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to list connection profiles: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, profiles, "")
+}
+
+// DeleteProfile handles deleting a connection profile
+// @Summary      Delete connection profile
+// @Description  Delete a saved database connection
+// @Tags         Connection Profiles
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Connection profile UUID"
+// @Success      200  {object}  map[string]interface{}  "Connection profile deleted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing profile ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Connection profile not found"
+// @Router       /api/v1/connections/delete [delete]
+func (h *ConnectionProfileHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	profileID := r.URL.Query().Get("id")
+	if profileID == "" {
+		utils.BadRequest(w, "Missing connection profile ID")
+		return
+	}
+
+	// Parse profile ID
+	id, err := utils.ParseUUID(profileID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid connection profile ID")
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.profileService.DeleteProfile(r.Context(), id, uid); err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.NotFound(w, "Connection profile not found")
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Connection profile deleted successfully")
+}
+
+// respondConnectionProfileError maps connection profile errors to HTTP responses
+func respondConnectionProfileError(w http.ResponseWriter, message string, err error) {
+	if respondWorkspaceError(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrConnectionProfileExists):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrConnectionFailed),
+		errors.Is(err, service.ErrSQLiteNotAllowed),
+		errors.Is(err, service.ErrInvalidDSN),
+		errors.Is(err, service.ErrNoTablesFound):
+		utils.BadRequest(w, message+": "+err.Error())
+	case err.Error() == "connection profile not found":
+		utils.NotFound(w, "Connection profile not found")
+	case errors.Is(err, utils.ErrEncryptionKeyMissing):
+		utils.RespondError(w, http.StatusServiceUnavailable, utils.ErrInternalServer, "Connection profiles are not available: "+err.Error(), nil)
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
@@ -2,23 +2,31 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
+	"db-importer/ingest"
 	"db-importer/internal/models"
 	"db-importer/internal/service"
 	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
 )
 
 // ImportHandler handles import HTTP requests
 type ImportHandler struct {
-	importService *service.ImportService
+	importService    *service.ImportService
+	executionEnabled bool // re-runs may execute (SQL_EXECUTION_ENABLED)
 }
 
 // NewImportHandler creates a new ImportHandler
-func NewImportHandler(importService *service.ImportService) *ImportHandler {
+func NewImportHandler(importService *service.ImportService, executionEnabled bool) *ImportHandler {
 	return &ImportHandler{
-		importService: importService,
+		importService:    importService,
+		executionEnabled: executionEnabled,
 	}
 }
 
@@ -60,6 +68,9 @@ func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
 	// Create import
 	importResp, err := h.importService.CreateImport(r.Context(), uid, &req)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		// Log the actual error for debugging
 		utils.InternalServerError(w, "Failed to create import: "+err.Error())
 		return
@@ -68,6 +79,163 @@ func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, http.StatusCreated, importResp, "Import created successfully")
 }
 
+// ExecuteImport handles running an import against a saved connection
+// @Summary      Execute import against a connection
+// @Description  Generate INSERT batches for the rows and run them against a saved connection profile
+// @Description  Mode "transaction" (default) runs all batches in one transaction; "batch" commits each batch and requires allowPartial
+// @Description  Failed rows are reported with their 1-based source row (first maxErrors only) and the outcome is recorded as an import with status executed or rolled_back
+// @Tags         Imports
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.ExecuteImportRequest  true  "Connection profile, table, mapping, rows and execution options"
+// @Success      201      {object}  map[string]interface{}       "Import executed or rolled back, with per-batch results in metadata.execution"
+// @Failure      400      {object}  map[string]interface{}       "Invalid request or connection failed"
+// @Failure      401      {object}  map[string]interface{}       "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}       "Connection profile not found"
+// @Failure      422      {object}  map[string]interface{}       "Data does not match the field definitions"
+// @Failure      500      {object}  map[string]interface{}       "Internal server error"
+// @Router       /api/v1/imports/execute [post]
+func (h *ImportHandler) ExecuteImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ExecuteImportRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	importResp, err := h.importService.ExecuteImport(r.Context(), uid, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		var validationErr *service.DataValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.RespondError(w, http.StatusUnprocessableEntity, utils.ErrValidationFailed, "Some data does not match field constraints", validationErr.Errors)
+		case errors.Is(err, service.ErrInvalidExecution), errors.Is(err, service.ErrNothingToExecute):
+			utils.BadRequest(w, err.Error())
+		default:
+			respondConnectionProfileError(w, "Failed to execute import", err)
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, importResp, "Import "+string(importResp.Status))
+}
+
+// RerunImport handles re-running a past import with a new data file
+// @Summary      Re-run an import with a new data file
+// @Description  Reuse the table definition, mapping, transformations and options recorded on an import with a completed data upload. Headers are matched exactly, then ignoring case, spaces and punctuation; metadata.headerMatch on the new import lists the matched, renamed, missing and added headers and previousImportId links back to the original.
+// @Description  Rows failing validation are rejected as in background jobs. With execute=true an executed import runs again against the same connection with the same options. With dryRun=true only the header report is returned.
+// @Tags         Imports
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                     true  "UUID of the import to re-run"
+// @Param        request  body      models.RerunImportRequest  true  "Data upload, mapping overrides and options"
+// @Success      200      {object}  map[string]interface{}     "Dry run: header report"
+// @Success      201      {object}  map[string]interface{}     "New import with the header report"
+// @Failure      400      {object}  map[string]interface{}     "Invalid request, mapping or data file"
+// @Failure      401      {object}  map[string]interface{}     "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}     "Execution is disabled"
+// @Failure      404      {object}  map[string]interface{}     "Import, upload or connection profile not found"
+// @Failure      422      {object}  map[string]interface{}     "Import does not record its table definition, or data does not match it"
+// @Failure      500      {object}  map[string]interface{}     "Internal server error"
+// @Router       /api/v1/imports/rerun [post]
+func (h *ImportHandler) RerunImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	importID := r.URL.Query().Get("id")
+	if importID == "" {
+		utils.BadRequest(w, "Missing import ID")
+		return
+	}
+
+	id, err := utils.ParseUUID(importID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid import ID")
+		return
+	}
+
+	var req models.RerunImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	if req.Execute && !req.DryRun && !h.executionEnabled {
+		utils.Forbidden(w, "Executing imports is disabled on this server")
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.RerunImport(r.Context(), id, uid, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		var validationErr *service.DataValidationError
+		switch {
+		case err.Error() == "import not found":
+			utils.NotFound(w, "Import not found")
+		case errors.Is(err, service.ErrImportNotRerunnable):
+			utils.RespondError(w, http.StatusUnprocessableEntity, utils.ErrValidationFailed, err.Error(), nil)
+		case errors.As(err, &validationErr):
+			utils.RespondError(w, http.StatusUnprocessableEntity, utils.ErrValidationFailed, "Some data does not match field constraints", validationErr.Errors)
+		case errors.Is(err, service.ErrInvalidMapping),
+			errors.Is(err, service.ErrInvalidExecution),
+			errors.Is(err, service.ErrNothingToExecute),
+			errors.Is(err, ingest.ErrUnsupportedFormat),
+			errors.Is(err, service.ErrInvalidUploadContent):
+			utils.BadRequest(w, err.Error())
+		case err.Error() == "connection profile not found",
+			errors.Is(err, service.ErrConnectionFailed),
+			errors.Is(err, utils.ErrEncryptionKeyMissing):
+			respondConnectionProfileError(w, "Failed to re-run import", err)
+		default:
+			respondUploadError(w, "Failed to re-run import", err)
+		}
+		return
+	}
+
+	if result.Import == nil {
+		utils.RespondSuccess(w, http.StatusOK, result, "Header report")
+		return
+	}
+	utils.RespondSuccess(w, http.StatusCreated, result, "Import re-run")
+}
+
 // GetImport handles retrieving an import by ID
 // @Summary      Get import details
 // @Description  Retrieve import metadata by ID (without SQL content)
@@ -104,6 +272,9 @@ func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
 	// Get import
 	importResp, err := h.importService.GetImport(r.Context(), id, uid)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.NotFound(w, "Import not found")
 		return
 	}
@@ -113,15 +284,20 @@ func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
 
 // GetImportSQL handles retrieving an import with its SQL
 // @Summary      Get import with SQL
-// @Description  Retrieve import details including the generated SQL content (decompressed)
+// @Description  Retrieve import details including the generated SQL content. With download=true the SQL is streamed as a file and Range requests are supported.
 // @Tags         Imports
 // @Produce      json
+// @Produce      application/sql
 // @Security     BearerAuth
-// @Param        id   query     string  true  "Import UUID"
-// @Success      200  {object}  map[string]interface{}  "Import details with SQL content"
-// @Failure      400  {object}  map[string]interface{}  "Invalid or missing import ID"
-// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
-// @Failure      404  {object}  map[string]interface{}  "Import not found"
+// @Param        id        query     string  true   "Import UUID"
+// @Param        download  query     bool    false  "Stream the SQL as a file download"
+// @Param        Range     header    string  false  "Byte range of the download, e.g. bytes=0-1023"
+// @Success      200       {object}  map[string]interface{}  "Import details with SQL content"
+// @Success      206       {file}    file                    "Requested range of the SQL file"
+// @Failure      400       {object}  map[string]interface{}  "Invalid or missing import ID"
+// @Failure      401       {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404       {object}  map[string]interface{}  "Import not found"
+// @Failure      416       {string}  string                  "Range not satisfiable"
 // @Router       /api/v1/imports/sql [get]
 func (h *ImportHandler) GetImportSQL(w http.ResponseWriter, r *http.Request) {
 	importID := r.URL.Query().Get("id")
@@ -143,9 +319,17 @@ func (h *ImportHandler) GetImportSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("download") == "true" {
+		h.downloadImportSQL(w, r, id, uid)
+		return
+	}
+
 	// Get import with SQL
 	importWithSQL, err := h.importService.GetImportWithSQL(r.Context(), id, uid)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.NotFound(w, "Import not found")
 		return
 	}
@@ -153,6 +337,90 @@ func (h *ImportHandler) GetImportSQL(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, http.StatusOK, importWithSQL, "")
 }
 
+// downloadImportSQL streams the generated SQL of an import
+func (h *ImportHandler) downloadImportSQL(w http.ResponseWriter, r *http.Request, id, userID uuid.UUID) {
+	file, err := h.importService.OpenImportSQL(r.Context(), id, userID)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if err.Error() == "import not found" {
+			utils.NotFound(w, "Import not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to open SQL")
+		return
+	}
+	serveSQLFile(w, r, file)
+}
+
+// GetImportRollback handles downloading the rollback script of an import
+// @Summary      Download rollback script
+// @Description  Stream the script undoing an import: DELETE statements for inserted rows by their key and UPDATE statements restoring rows an upsert overwrote. metadata.rollback tells whether a script is available. Range requests are supported.
+// @Tags         Imports
+// @Produce      application/sql
+// @Security     BearerAuth
+// @Param        id     query     string  true   "Import UUID"
+// @Param        Range  header    string  false  "Byte range of the download, e.g. bytes=0-1023"
+// @Success      200    {file}    file                    "Rollback script"
+// @Success      206    {file}    file                    "Requested range of the rollback script"
+// @Failure      400    {object}  map[string]interface{}  "Invalid or missing import ID"
+// @Failure      401    {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404    {object}  map[string]interface{}  "Import not found or without rollback script"
+// @Router       /api/v1/imports/rollback [get]
+func (h *ImportHandler) GetImportRollback(w http.ResponseWriter, r *http.Request) {
+	importID := r.URL.Query().Get("id")
+	if importID == "" {
+		utils.BadRequest(w, "Missing import ID")
+		return
+	}
+
+	id, err := utils.ParseUUID(importID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid import ID")
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := h.importService.OpenImportRollback(r.Context(), id, uid)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		switch {
+		case err.Error() == "import not found":
+			utils.NotFound(w, "Import not found")
+		case errors.Is(err, service.ErrRollbackUnavailable):
+			utils.NotFound(w, "Import has no rollback script")
+		default:
+			utils.InternalServerError(w, "Failed to open rollback script")
+		}
+		return
+	}
+	serveSQLFile(w, r, file)
+}
+
+// serveSQLFile streams an SQL file as a download; http.ServeContent answers
+// Range and conditional requests
+func serveSQLFile(w http.ResponseWriter, r *http.Request, file *service.ImportSQLFile) {
+	defer file.Close()
+
+	// Large files take longer than the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	if file.Checksum != "" {
+		w.Header().Set("ETag", `"`+file.Checksum+`"`)
+	}
+
+	http.ServeContent(w, r, file.Name, file.ModTime, file)
+}
+
 // ListImports handles listing imports with pagination and filters
 // @Summary      List user imports
 // @Description  List imports with pagination, filtering by table/status, and sorting
@@ -211,6 +479,9 @@ func (h *ImportHandler) ListImports(w http.ResponseWriter, r *http.Request) {
 	// List imports
 	imports, err := h.importService.ListImports(r.Context(), uid, req)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.InternalServerError(w, "Failed to list imports")
 		return
 	}
@@ -253,6 +524,9 @@ func (h *ImportHandler) DeleteImport(w http.ResponseWriter, r *http.Request) {
 	// Delete import
 	err = h.importService.DeleteImport(r.Context(), id, uid)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.NotFound(w, "Import not found")
 		return
 	}
@@ -280,6 +554,9 @@ func (h *ImportHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	// Get stats
 	stats, err := h.importService.GetStats(r.Context(), uid)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.InternalServerError(w, "Failed to get import stats")
 		return
 	}
@@ -317,6 +594,9 @@ func (h *ImportHandler) DeleteOldImports(w http.ResponseWriter, r *http.Request)
 	// Delete old imports
 	deletedCount, err := h.importService.DeleteOldImports(r.Context(), uid, olderThanDays)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		utils.InternalServerError(w, "Failed to delete old imports")
 		return
 	}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// jobEventsPoll is how often the event stream checks the job for changes
+	jobEventsPoll = time.Second
+	// jobEventsKeepAlive is how often an idle event stream sends a comment
+	jobEventsKeepAlive = 15 * time.Second
+)
+
+// JobHandler handles background job HTTP requests
+type JobHandler struct {
+	jobService *service.JobService
+}
+
+// NewJobHandler creates a new JobHandler
+func NewJobHandler(jobService *service.JobService) *JobHandler {
+	return &JobHandler{
+		jobService: jobService,
+	}
+}
+
+// SubmitJob handles queueing a background job
+// @Summary      Submit background job
+// @Description  Queue a generate_sql or execute job and return immediately with its ID
+// @Description  generate_sql takes the /generate-sql body (tableName, mapping, rows, fields, dialect); rows that fail validation are left out and reported on the resulting import
+// @Description  execute takes the /api/v1/imports/execute body and requires SQL_EXECUTION_ENABLED
+// @Tags         Jobs
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.SubmitJobRequest  true  "Job kind and payload"
+// @Success      202      {object}  map[string]interface{}   "Job queued"
+// @Failure      400      {object}  map[string]interface{}   "Invalid request or payload"
+// @Failure      401      {object}  map[string]interface{}   "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}   "Job kind disabled on this server"
+// @Failure      500      {object}  map[string]interface{}   "Internal server error"
+// @Router       /api/v1/jobs [post]
+func (h *JobHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req models.SubmitJobRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.SubmitJob(r.Context(), uid, &req)
+	if err != nil {
+		respondJobError(w, "Failed to submit job", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusAccepted, job, "Job queued")
+}
+
+// ListJobs handles listing the user's recent jobs
+// @Summary      List background jobs
+// @Description  List the 50 most recent jobs of the user, newest first
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Jobs"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/jobs [get]
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.jobService.ListJobs(r.Context(), uid)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to list jobs: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, jobs, "")
+}
+
+// GetJob handles retrieving a job's status
+// @Summary      Get job status
+// @Description  Retrieve status, current phase and rows processed; importId is set once results are attached
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Job UUID"
+// @Success      200  {object}  map[string]interface{}  "Job status"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing job ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Job not found"
+// @Router       /api/v1/jobs/get [get]
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, uid, ok := jobRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), id, uid)
+	if err != nil {
+		respondJobError(w, "Failed to get job", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, job, "")
+}
+
+// CancelJob handles cancelling a job
+// @Summary      Cancel job
+// @Description  Cancel a queued job immediately, or ask the worker of a running job to stop
+// @Description  A running execute job is rolled back unless it commits per batch
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Job UUID"
+// @Success      200  {object}  map[string]interface{}  "Cancellation requested"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing job ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Job not found"
+// @Failure      409  {object}  map[string]interface{}  "Job has already finished"
+// @Router       /api/v1/jobs/cancel [post]
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := jobRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.CancelJob(r.Context(), id, uid)
+	if err != nil {
+		respondJobError(w, "Failed to cancel job", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, job, "Cancellation requested")
+}
+
+// StreamJobEvents handles streaming job progress as Server-Sent Events
+// @Summary      Stream job progress
+// @Description  Server-Sent Events stream of the job: a "progress" event with the job status whenever it changes, and a final "done" event once it has finished
+// @Tags         Jobs
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Job UUID"
+// @Success      200  {string}  string  "Event stream"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing job ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Job not found"
+// @Router       /api/v1/jobs/events [get]
+func (h *JobHandler) StreamJobEvents(w http.ResponseWriter, r *http.Request) {
+	id, uid, ok := jobRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), id, uid)
+	if err != nil {
+		respondJobError(w, "Failed to get job", err)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warn("Failed to clear write deadline for event stream", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, job *models.JobResponse) bool {
+		data, _ := json.Marshal(job)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !send("progress", job) {
+		return
+	}
+
+	poll := time.NewTicker(jobEventsPoll)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(jobEventsKeepAlive)
+	defer keepAlive.Stop()
+
+	last := job
+	for !last.Status.Finished() {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.jobService.Stopping():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case <-poll.C:
+			job, err := h.jobService.GetJob(r.Context(), id, uid)
+			if err != nil {
+				return
+			}
+			if job.UpdatedAt.Equal(last.UpdatedAt) {
+				continue
+			}
+			if !send("progress", job) {
+				return
+			}
+			last = job
+		}
+	}
+
+	send("done", last)
+}
+
+// jobRequestIDs parses the job ID query parameter and the current user ID
+func jobRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	jobID := r.URL.Query().Get("id")
+	if jobID == "" {
+		utils.BadRequest(w, "Missing job ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	// Parse job ID
+	id, err := utils.ParseUUID(jobID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid job ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return id, uid, true
+}
+
+// respondJobError maps job errors to HTTP responses
+func respondJobError(w http.ResponseWriter, message string, err error) {
+	if respondWorkspaceError(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidJob):
+		utils.BadRequest(w, err.Error())
+	case errors.Is(err, service.ErrJobKindDisabled):
+		utils.Forbidden(w, err.Error())
+	case errors.Is(err, service.ErrJobFinished):
+		utils.Conflict(w, err.Error())
+	case err.Error() == "job not found":
+		utils.NotFound(w, "Job not found")
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
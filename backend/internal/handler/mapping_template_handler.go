@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// MappingTemplateHandler handles mapping template HTTP requests
+type MappingTemplateHandler struct {
+	templateService *service.MappingTemplateService
+}
+
+// NewMappingTemplateHandler creates a new MappingTemplateHandler
+func NewMappingTemplateHandler(templateService *service.MappingTemplateService) *MappingTemplateHandler {
+	return &MappingTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+// CreateTemplate handles saving a new mapping template
+// @Summary      Create mapping template
+// @Description  Save a named column mapping (source header -> field) with its transformations and options, for the given target table and source headers
+// @Description  Set shared=true to make the template visible (read-only) to every user
+// @Tags         Mapping Templates
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MappingTemplateRequest  true  "Template"
+// @Success      201      {object}  map[string]interface{}         "Mapping template created"
+// @Failure      400      {object}  map[string]interface{}         "Invalid request or inconsistent template"
+// @Failure      401      {object}  map[string]interface{}         "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}         "A template with this name already exists"
+// @Failure      500      {object}  map[string]interface{}         "Internal server error"
+// @Router       /api/v1/mapping-templates [post]
+func (h *MappingTemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req models.MappingTemplateRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(r.Context(), uid, &req)
+	if err != nil {
+		respondMappingTemplateError(w, "Failed to create mapping template", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, template, "Mapping template created successfully")
+}
+
+// ListTemplates handles listing mapping templates
+// @Summary      List mapping templates
+// @Description  List the user's mapping templates and those shared by other users, by name
+// @Tags         Mapping Templates
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "Mapping templates"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/mapping-templates [get]
+func (h *MappingTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	templates, err := h.templateService.ListTemplates(r.Context(), uid)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to list mapping templates: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, templates, "")
+}
+
+// GetTemplate handles retrieving a mapping template
+// @Summary      Get mapping template
+// @Description  Retrieve a mapping template owned by or shared with the user
+// @Tags         Mapping Templates
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Mapping template UUID"
+// @Success      200  {object}  map[string]interface{}  "Mapping template"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing template ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Mapping template not found"
+// @Router       /api/v1/mapping-templates/get [get]
+func (h *MappingTemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	id, uid, ok := templateRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.GetTemplate(r.Context(), id, uid)
+	if err != nil {
+		respondMappingTemplateError(w, "Failed to get mapping template", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, template, "")
+}
+
+// UpdateTemplate handles replacing a mapping template
+// @Summary      Update mapping template
+// @Description  Replace a mapping template owned by the user
+// @Tags         Mapping Templates
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                         true  "Mapping template UUID"
+// @Param        request  body      models.MappingTemplateRequest  true  "Template"
+// @Success      200      {object}  map[string]interface{}         "Mapping template updated"
+// @Failure      400      {object}  map[string]interface{}         "Invalid request or inconsistent template"
+// @Failure      401      {object}  map[string]interface{}         "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}         "Mapping template not found or not owned by the user"
+// @Failure      409      {object}  map[string]interface{}         "A template with this name already exists"
+// @Router       /api/v1/mapping-templates/update [put]
+func (h *MappingTemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := templateRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.MappingTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	template, err := h.templateService.UpdateTemplate(r.Context(), id, uid, &req)
+	if err != nil {
+		respondMappingTemplateError(w, "Failed to update mapping template", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, template, "Mapping template updated successfully")
+}
+
+// DeleteTemplate handles deleting a mapping template
+// @Summary      Delete mapping template
+// @Description  Delete a mapping template owned by the user
+// @Tags         Mapping Templates
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Mapping template UUID"
+// @Success      200  {object}  map[string]interface{}  "Mapping template deleted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing template ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Mapping template not found or not owned by the user"
+// @Router       /api/v1/mapping-templates/delete [delete]
+func (h *MappingTemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := templateRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(r.Context(), id, uid); err != nil {
+		respondMappingTemplateError(w, "Failed to delete mapping template", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Mapping template deleted successfully")
+}
+
+// ExportTemplate handles downloading a mapping template as JSON
+// @Summary      Export mapping template
+// @Description  Download a mapping template as a JSON document that can be kept in git and imported with /api/v1/mapping-templates/import
+// @Tags         Mapping Templates
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Mapping template UUID"
+// @Success      200  {object}  models.MappingTemplateDocument  "Template document"
+// @Failure      400  {object}  map[string]interface{}          "Invalid or missing template ID"
+// @Failure      401  {object}  map[string]interface{}          "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}          "Mapping template not found"
+// @Router       /api/v1/mapping-templates/export [get]
+func (h *MappingTemplateHandler) ExportTemplate(w http.ResponseWriter, r *http.Request) {
+	id, uid, ok := templateRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := h.templateService.ExportTemplate(r.Context(), id, uid)
+	if err != nil {
+		respondMappingTemplateError(w, "Failed to export mapping template", err)
+		return
+	}
+
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		utils.InternalServerError(w, "Failed to encode mapping template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", templateFileName(doc.Name)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(body, '\n'))
+}
+
+// ImportTemplate handles saving an exported mapping template document
+// @Summary      Import mapping template
+// @Description  Save a template document produced by /api/v1/mapping-templates/export as a new template of the user
+// @Tags         Mapping Templates
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MappingTemplateDocument  true  "Template document"
+// @Success      201      {object}  map[string]interface{}          "Mapping template imported"
+// @Failure      400      {object}  map[string]interface{}          "Invalid document or inconsistent template"
+// @Failure      401      {object}  map[string]interface{}          "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}          "A template with this name already exists"
+// @Router       /api/v1/mapping-templates/import [post]
+func (h *MappingTemplateHandler) ImportTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var doc models.MappingTemplateDocument
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		utils.BadRequest(w, "Invalid template document: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&doc); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.ImportTemplate(r.Context(), uid, &doc)
+	if err != nil {
+		respondMappingTemplateError(w, "Failed to import mapping template", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, template, "Mapping template imported successfully")
+}
+
+// templateRequestIDs reads the template ID query parameter and the user ID,
+// responding with an error when either is missing
+func templateRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	templateID := r.URL.Query().Get("id")
+	if templateID == "" {
+		utils.BadRequest(w, "Missing mapping template ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := utils.ParseUUID(templateID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid mapping template ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return id, uid, true
+}
+
+// templateFileName builds the download name of an exported template
+func templateFileName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	return safe + ".mapping.json"
+}
+
+// respondMappingTemplateError maps mapping template errors to HTTP responses
+func respondMappingTemplateError(w http.ResponseWriter, message string, err error) {
+	if respondWorkspaceError(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrMappingTemplateExists):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrInvalidTemplate):
+		utils.BadRequest(w, err.Error())
+	case err.Error() == "mapping template not found":
+		utils.NotFound(w, "Mapping template not found")
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
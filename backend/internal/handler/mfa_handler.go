@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+
+	"github.com/google/uuid"
+)
+
+// MFAHandler handles two-factor authentication settings of the signed-in
+// user
+type MFAHandler struct {
+	mfaService  *service.MFAService
+	authService *service.AuthService
+}
+
+// NewMFAHandler creates a new MFAHandler
+func NewMFAHandler(mfaService *service.MFAService, authService *service.AuthService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		authService: authService,
+	}
+}
+
+// Status handles describing the user's two-factor authentication
+// @Summary      Two-factor authentication status
+// @Description  Whether two-factor authentication is enabled, how many recovery codes are left and which of the user's organizations require it
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.MFAStatusResponse  "Two-factor authentication status"
+// @Failure      401  {object}  map[string]interface{}    "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}    "Internal server error"
+// @Router       /auth/mfa [get]
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.mfaService.Status(r.Context(), uid)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get two-factor authentication status: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, status, "")
+}
+
+// SetupTOTP handles starting the setup of an authenticator app
+// @Summary      Set up authenticator app
+// @Description  Create a new TOTP secret. Show the otpauth URI as a QR code (or the secret for manual entry) and confirm with a code through /auth/mfa/totp/enable; until then sign-ins are unchanged
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.TOTPSetupResponse  "Secret and otpauth URI"
+// @Failure      401  {object}  map[string]interface{}    "Unauthorized"
+// @Failure      409  {object}  map[string]interface{}    "Two-factor authentication is already enabled"
+// @Failure      503  {object}  map[string]interface{}    "Credential encryption key is not configured"
+// @Router       /auth/mfa/totp/setup [post]
+func (h *MFAHandler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	setup, err := h.mfaService.BeginSetup(r.Context(), uid)
+	if err != nil {
+		respondMFAError(w, "Failed to set up authenticator", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, setup, "Scan the code with your authenticator app and confirm with a code from it")
+}
+
+// EnableTOTP handles confirming the authenticator app
+// @Summary      Enable two-factor authentication
+// @Description  Confirm the authenticator set up by /auth/mfa/totp/setup with a current code. From then on logins need a code. Other sessions and API tokens are revoked and the current session continues in new cookies. Returns 10 single-use recovery codes, shown only once
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MFACodeRequest         true  "Code from the authenticator app"
+// @Success      200      {object}  models.RecoveryCodesResponse  "Two-factor authentication enabled"
+// @Failure      400      {object}  map[string]interface{}        "Invalid request, invalid code or no setup started"
+// @Failure      401      {object}  map[string]interface{}        "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}        "Two-factor authentication is already enabled"
+// @Failure      503      {object}  map[string]interface{}        "Credential encryption key is not configured"
+// @Router       /auth/mfa/totp/enable [post]
+func (h *MFAHandler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	req, uid, ok := mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.Enable(r.Context(), uid, req)
+	if err != nil {
+		respondMFAError(w, "Failed to enable two-factor authentication", err)
+		return
+	}
+
+	// Enabling revoked every session, this one included; the code just
+	// checked lets it continue as one that passed two-factor authentication
+	session, err := h.authService.StartMFASession(r.Context(), uid)
+	if err != nil {
+		// The recovery codes are shown only once, so answer anyway and
+		// let the user sign in again
+		logger.Warn("Failed to start a session after enabling two-factor authentication", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else {
+		setSessionCookies(w, r, session, false)
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, codes, "Two-factor authentication enabled; store the recovery codes now, they will not be shown again")
+}
+
+// DisableTOTP handles turning two-factor authentication off
+// @Summary      Disable two-factor authentication
+// @Description  Turn two-factor authentication off with a current code or a recovery code. Not possible while an organization of the user requires it
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MFACodeRequest   true  "Authenticator or recovery code"
+// @Success      200      {object}  map[string]interface{}  "Two-factor authentication disabled"
+// @Failure      400      {object}  map[string]interface{}  "Invalid request or invalid code"
+// @Failure      401      {object}  map[string]interface{}  "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}  "Required by an organization"
+// @Failure      409      {object}  map[string]interface{}  "Two-factor authentication is not enabled"
+// @Router       /auth/mfa/totp/disable [post]
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	req, uid, ok := mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mfaService.Disable(r.Context(), uid, req); err != nil {
+		respondMFAError(w, "Failed to disable two-factor authentication", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Two-factor authentication disabled")
+}
+
+// RegenerateRecoveryCodes handles replacing the user's recovery codes
+// @Summary      Regenerate recovery codes
+// @Description  Replace all recovery codes with 10 new ones, after checking a current code or a recovery code. The new codes are shown only once
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.MFACodeRequest         true  "Authenticator or recovery code"
+// @Success      200      {object}  models.RecoveryCodesResponse  "New recovery codes"
+// @Failure      400      {object}  map[string]interface{}        "Invalid request or invalid code"
+// @Failure      401      {object}  map[string]interface{}        "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}        "Two-factor authentication is not enabled"
+// @Router       /auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	req, uid, ok := mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(r.Context(), uid, req)
+	if err != nil {
+		respondMFAError(w, "Failed to regenerate recovery codes", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, codes, "Recovery codes replaced; store them now, they will not be shown again")
+}
+
+// mfaCodeRequest reads the code of a POST request and the user ID,
+// responding with an error if either is invalid
+func mfaCodeRequest(w http.ResponseWriter, r *http.Request) (*models.MFACodeRequest, uuid.UUID, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, uuid.Nil, false
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body")
+		return nil, uuid.Nil, false
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return nil, uuid.Nil, false
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+
+	return &req, uid, true
+}
+
+// respondMFAError maps two-factor authentication errors to HTTP responses
+func respondMFAError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrMFAUnavailable):
+		utils.RespondError(w, http.StatusServiceUnavailable, utils.ErrInternalServer, err.Error(), nil)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrMFASetupMissing), errors.Is(err, service.ErrMFAInvalidCode):
+		utils.BadRequest(w, err.Error())
+	case errors.Is(err, service.ErrMFARequiredByOrganization):
+		utils.Forbidden(w, err.Error())
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
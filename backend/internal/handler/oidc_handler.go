@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+)
+
+// oidcLoginCookie keeps the login state between the redirect to the
+// provider and its callback
+const oidcLoginCookie = "oidc_login"
+
+// OIDCHandler handles single sign-on through an OpenID Connect provider
+type OIDCHandler struct {
+	oidcService *service.OIDCService // nil when single sign-on is not configured
+}
+
+// NewOIDCHandler creates a new OIDCHandler
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+// Status handles telling the frontend whether single sign-on is offered
+// @Summary      Single sign-on status
+// @Description  Whether users can sign in through the OpenID Connect provider, and the label of its sign-in button
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  models.OIDCStatusResponse  "Single sign-on status"
+// @Router       /auth/oidc [get]
+func (h *OIDCHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := models.OIDCStatusResponse{Enabled: h.oidcService != nil}
+	if status.Enabled {
+		status.ProviderName = h.oidcService.ProviderName()
+		status.LoginURL = "/auth/oidc/login"
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, status, "")
+}
+
+// Login handles starting a single sign-on
+// @Summary      Start single sign-on
+// @Description  Redirect the browser to the OpenID Connect provider (authorization code flow with PKCE). After signing in there it returns through /auth/oidc/callback to the frontend path in redirect
+// @Description  When the provider cannot be reached, redirects to the frontend login page with ?error=sso_unavailable
+// @Tags         Auth
+// @Param        redirect  query     string                  false  "Frontend path to return to (default /)"
+// @Success      302       {string}  string                  "Redirect to the provider"
+// @Failure      404       {object}  map[string]interface{}  "Single sign-on is not configured"
+// @Router       /auth/oidc/login [get]
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.oidcService == nil {
+		utils.NotFound(w, "Single sign-on is not configured")
+		return
+	}
+
+	authURL, loginState, err := h.oidcService.BeginLogin(r.Context(), r.URL.Query().Get("redirect"))
+	if err != nil {
+		logger.Error("Failed to start single sign-on", err)
+		h.redirectWithError(w, r, "sso_unavailable")
+		return
+	}
+
+	utils.SetCookie(w, r, oidcLoginCookie, loginState, int(service.OIDCLoginTTL.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles the provider returning from a single sign-on
+// @Summary      Single sign-on callback
+// @Description  Completes the sign-in started by /auth/oidc/login. The provider account signs in its linked user, is linked to the user with the same verified email address, or creates a user when automatic sign-up is enabled. Session cookies are set as on /auth/login
+// @Description  Failures redirect to the frontend login page with ?error= sso_denied, sso_expired, sso_email_required, sso_email_unverified, sso_no_account, account_inactive or sso_failed. Users with two-factor authentication are sent to the login page with ?mfaToken= to complete /auth/login/mfa
+// @Tags         Auth
+// @Param        code   query     string                  false  "Authorization code"
+// @Param        state  query     string                  false  "State of the sign-in"
+// @Param        error  query     string                  false  "Error reported by the provider"
+// @Success      302    {string}  string                  "Redirect to the frontend"
+// @Failure      404    {object}  map[string]interface{}  "Single sign-on is not configured"
+// @Router       /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.oidcService == nil {
+		utils.NotFound(w, "Single sign-on is not configured")
+		return
+	}
+
+	// The login state is good for one attempt
+	var loginState string
+	if cookie, err := r.Cookie(oidcLoginCookie); err == nil {
+		loginState = cookie.Value
+	}
+	utils.ClearCookie(w, r, oidcLoginCookie)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		h.redirectWithError(w, r, "sso_denied")
+		return
+	}
+
+	session, redirect, err := h.oidcService.CompleteLogin(r.Context(), loginState, query.Get("state"), query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOIDCLoginInvalid):
+			h.redirectWithError(w, r, "sso_expired")
+		case errors.Is(err, service.ErrOIDCEmailRequired):
+			h.redirectWithError(w, r, "sso_email_required")
+		case errors.Is(err, service.ErrOIDCEmailUnverified):
+			h.redirectWithError(w, r, "sso_email_unverified")
+		case errors.Is(err, service.ErrOIDCNoAccount):
+			h.redirectWithError(w, r, "sso_no_account")
+		case errors.Is(err, service.ErrUserNotActive):
+			h.redirectWithError(w, r, "account_inactive")
+		default:
+			logger.Error("Single sign-on failed", err)
+			h.redirectWithError(w, r, "sso_failed")
+		}
+		return
+	}
+
+	if session.MFARequired {
+		// The frontend login page completes the second step
+		query := url.Values{"mfaToken": {session.MFAToken}, "redirect": {redirect}}
+		http.Redirect(w, r, h.oidcService.AppLink("/login?"+query.Encode()), http.StatusFound)
+		return
+	}
+
+	// Same cookies as a password login without rememberMe
+	setSessionCookies(w, r, session, false)
+
+	http.Redirect(w, r, h.oidcService.AppLink(redirect), http.StatusFound)
+}
+
+// redirectWithError sends the browser to the frontend login page with an
+// error code it can explain
+func (h *OIDCHandler) redirectWithError(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, h.oidcService.AppLink("/login?error="+url.QueryEscape(code)), http.StatusFound)
+}
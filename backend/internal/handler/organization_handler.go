@@ -0,0 +1,560 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationHandler handles organization, member and invitation HTTP
+// requests
+type OrganizationHandler struct {
+	orgService *service.OrganizationService
+}
+
+// NewOrganizationHandler creates a new OrganizationHandler
+func NewOrganizationHandler(orgService *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+// CreateOrganization handles creating an organization
+// @Summary      Create organization
+// @Description  Create an organization owned by the user. Imports, mapping templates, connection profiles and workflow sessions are created in it by sending its ID in the X-Organization-ID header
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateOrganizationRequest  true  "Organization name"
+// @Success      201      {object}  models.OrganizationMembership     "Organization created"
+// @Failure      400      {object}  map[string]interface{}            "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
+// @Failure      500      {object}  map[string]interface{}            "Internal server error"
+// @Router       /api/v1/organizations [post]
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(r.Context(), uid, &req)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create organization: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, org, "Organization created successfully")
+}
+
+// ListOrganizations handles listing the user's organizations
+// @Summary      List organizations
+// @Description  List the organizations the user belongs to and their role in each, by name
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.OrganizationMembership  "Organizations"
+// @Failure      401  {object}  map[string]interface{}         "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}         "Internal server error"
+// @Router       /api/v1/organizations [get]
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	orgs, err := h.orgService.ListOrganizations(r.Context(), uid)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to list organizations: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, orgs, "")
+}
+
+// RenameOrganization handles renaming an organization
+// @Summary      Rename organization
+// @Description  Rename an organization; admins and owners only
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                            true  "Organization UUID"
+// @Param        request  body      models.CreateOrganizationRequest  true  "New name"
+// @Success      200      {object}  models.OrganizationMembership     "Organization renamed"
+// @Failure      400      {object}  map[string]interface{}            "Invalid request or organization ID"
+// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}            "Role does not allow this"
+// @Failure      404      {object}  map[string]interface{}            "Organization not found"
+// @Router       /api/v1/organizations/update [put]
+func (h *OrganizationHandler) RenameOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	org, err := h.orgService.RenameOrganization(r.Context(), uid, orgID, &req)
+	if err != nil {
+		respondOrganizationError(w, "Failed to rename organization", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, org, "Organization renamed successfully")
+}
+
+// SetMFARequirement handles requiring two-factor authentication in an
+// organization
+// @Summary      Require two-factor authentication
+// @Description  Require the organization's members to use two-factor authentication, or stop requiring it; admins and owners only, who must have enabled it themselves
+// @Description  While required, members without it get 403 with code MFA_REQUIRED on everything in the organization until they enable it
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                              true  "Organization UUID"
+// @Param        request  body      models.UpdateMFARequirementRequest  true  "Whether it is required"
+// @Success      200      {object}  models.OrganizationMembership       "Requirement updated"
+// @Failure      400      {object}  map[string]interface{}              "Invalid request or organization ID"
+// @Failure      401      {object}  map[string]interface{}              "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}              "Role does not allow this"
+// @Failure      404      {object}  map[string]interface{}              "Organization not found"
+// @Failure      409      {object}  map[string]interface{}              "Two-factor authentication is not enabled on your account"
+// @Router       /api/v1/organizations/mfa [put]
+func (h *OrganizationHandler) SetMFARequirement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMFARequirementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	org, err := h.orgService.SetMFARequirement(r.Context(), uid, orgID, &req)
+	if err != nil {
+		respondOrganizationError(w, "Failed to update two-factor authentication requirement", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, org, "Two-factor authentication requirement updated")
+}
+
+// DeleteOrganization handles deleting an organization
+// @Summary      Delete organization
+// @Description  Delete an organization with its imports, mapping templates, connection profiles and workflow sessions; owners only
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Organization UUID"
+// @Success      200  {object}  map[string]interface{}  "Organization deleted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing organization ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      403  {object}  map[string]interface{}  "Role does not allow this"
+// @Failure      404  {object}  map[string]interface{}  "Organization not found"
+// @Router       /api/v1/organizations/delete [delete]
+func (h *OrganizationHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.DeleteOrganization(r.Context(), uid, orgID); err != nil {
+		respondOrganizationError(w, "Failed to delete organization", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Organization deleted successfully")
+}
+
+// ListMembers handles listing the members of an organization
+// @Summary      List organization members
+// @Description  List the members of an organization with their role, owners first
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Organization UUID"
+// @Success      200  {array}   models.OrganizationMember  "Members"
+// @Failure      400  {object}  map[string]interface{}     "Invalid or missing organization ID"
+// @Failure      401  {object}  map[string]interface{}     "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}     "Organization not found"
+// @Router       /api/v1/organizations/members [get]
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.ListMembers(r.Context(), uid, orgID)
+	if err != nil {
+		respondOrganizationError(w, "Failed to list organization members", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, members, "")
+}
+
+// UpdateMemberRole handles changing the role of a member
+// @Summary      Change member role
+// @Description  Change the role of a member. Admins manage admins, editors and viewers; only owners grant ownership or change the role of another owner
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                          true  "Organization UUID"
+// @Param        userId   query     string                          true  "Member user UUID"
+// @Param        request  body      models.UpdateMemberRoleRequest  true  "New role"
+// @Success      200      {object}  map[string]interface{}          "Member role updated"
+// @Failure      400      {object}  map[string]interface{}          "Invalid request or IDs"
+// @Failure      401      {object}  map[string]interface{}          "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}          "Role does not allow this"
+// @Failure      404      {object}  map[string]interface{}          "Organization or member not found"
+// @Failure      409      {object}  map[string]interface{}          "The organization would be left without an owner"
+// @Router       /api/v1/organizations/members/role [put]
+func (h *OrganizationHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, ok := queryUUID(w, r, "userId", "member user ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	if err := h.orgService.UpdateMemberRole(r.Context(), uid, orgID, memberID, &req); err != nil {
+		respondOrganizationError(w, "Failed to update member role", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Member role updated successfully")
+}
+
+// RemoveMember handles removing a member from an organization
+// @Summary      Remove member
+// @Description  Remove a member from an organization; admins and owners only, except that members may leave on their own. Only owners remove owners
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      query     string  true  "Organization UUID"
+// @Param        userId  query     string  true  "Member user UUID"
+// @Success      200     {object}  map[string]interface{}  "Member removed"
+// @Failure      400     {object}  map[string]interface{}  "Invalid or missing IDs"
+// @Failure      401     {object}  map[string]interface{}  "Unauthorized"
+// @Failure      403     {object}  map[string]interface{}  "Role does not allow this"
+// @Failure      404     {object}  map[string]interface{}  "Organization or member not found"
+// @Failure      409     {object}  map[string]interface{}  "The organization would be left without an owner"
+// @Router       /api/v1/organizations/members/remove [delete]
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, ok := queryUUID(w, r, "userId", "member user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RemoveMember(r.Context(), uid, orgID, memberID); err != nil {
+		respondOrganizationError(w, "Failed to remove member", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Member removed successfully")
+}
+
+// CreateInvitation handles inviting someone to an organization
+// @Summary      Invite to organization
+// @Description  Invite an email address to join an organization as admin, editor or viewer; admins and owners only
+// @Description  The returned token is only shown once: pass it on to the invitee, who accepts it with /api/v1/organizations/invitations/accept. Invitations expire after 7 days
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                           true  "Organization UUID"
+// @Param        request  body      models.CreateInvitationRequest   true  "Email and role"
+// @Success      201      {object}  models.CreateInvitationResponse  "Invitation created, with its token"
+// @Failure      400      {object}  map[string]interface{}           "Invalid request or organization ID"
+// @Failure      401      {object}  map[string]interface{}           "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}           "Role does not allow this"
+// @Failure      404      {object}  map[string]interface{}           "Organization not found"
+// @Router       /api/v1/organizations/invitations [post]
+func (h *OrganizationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	invitation, err := h.orgService.CreateInvitation(r.Context(), uid, orgID, &req)
+	if err != nil {
+		respondOrganizationError(w, "Failed to create invitation", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, invitation, "Invitation created; copy the token now, it will not be shown again")
+}
+
+// ListInvitations handles listing the pending invitations of an organization
+// @Summary      List invitations
+// @Description  List the invitations of an organization that were neither accepted nor expired; admins and owners only
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Organization UUID"
+// @Success      200  {array}   models.OrganizationInvitation  "Pending invitations"
+// @Failure      400  {object}  map[string]interface{}         "Invalid or missing organization ID"
+// @Failure      401  {object}  map[string]interface{}         "Unauthorized"
+// @Failure      403  {object}  map[string]interface{}         "Role does not allow this"
+// @Failure      404  {object}  map[string]interface{}         "Organization not found"
+// @Router       /api/v1/organizations/invitations [get]
+func (h *OrganizationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.orgService.ListInvitations(r.Context(), uid, orgID)
+	if err != nil {
+		respondOrganizationError(w, "Failed to list invitations", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, invitations, "")
+}
+
+// RevokeInvitation handles revoking a pending invitation
+// @Summary      Revoke invitation
+// @Description  Revoke a pending invitation; admins and owners only
+// @Tags         Organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            query     string  true  "Organization UUID"
+// @Param        invitationId  query     string  true  "Invitation UUID"
+// @Success      200           {object}  map[string]interface{}  "Invitation revoked"
+// @Failure      400           {object}  map[string]interface{}  "Invalid or missing IDs"
+// @Failure      401           {object}  map[string]interface{}  "Unauthorized"
+// @Failure      403           {object}  map[string]interface{}  "Role does not allow this"
+// @Failure      404           {object}  map[string]interface{}  "Organization or invitation not found"
+// @Router       /api/v1/organizations/invitations/revoke [delete]
+func (h *OrganizationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orgID, uid, ok := organizationRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	invitationID, ok := queryUUID(w, r, "invitationId", "invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RevokeInvitation(r.Context(), uid, orgID, invitationID); err != nil {
+		respondOrganizationError(w, "Failed to revoke invitation", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Invitation revoked successfully")
+}
+
+// AcceptInvitation handles joining an organization
+// @Summary      Accept invitation
+// @Description  Join the organization an invitation token was created for. The invitation must have been sent to the user's email address; members keep their current role
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.AcceptInvitationRequest  true  "Invitation token"
+// @Success      200      {object}  models.OrganizationMembership   "Joined the organization"
+// @Failure      400      {object}  map[string]interface{}          "Invalid request, or invalid, expired or used invitation"
+// @Failure      401      {object}  map[string]interface{}          "Unauthorized"
+// @Failure      403      {object}  map[string]interface{}          "Invitation was sent to a different email address, or the email address is not verified"
+// @Router       /api/v1/organizations/invitations/accept [post]
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.AcceptInvitation(r.Context(), uid, &req)
+	if err != nil {
+		respondOrganizationError(w, "Failed to accept invitation", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, org, "Joined "+org.Name)
+}
+
+// organizationRequestIDs reads the organization ID query parameter and the
+// user ID, responding with an error when either is missing
+func organizationRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	orgID, ok := queryUUID(w, r, "id", "organization ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return orgID, uid, true
+}
+
+// queryUUID reads a required UUID query parameter, responding with an error
+// naming what when it is missing or invalid
+func queryUUID(w http.ResponseWriter, r *http.Request, param, what string) (uuid.UUID, bool) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		utils.BadRequest(w, "Missing "+what)
+		return uuid.Nil, false
+	}
+
+	id, err := utils.ParseUUID(value)
+	if err != nil {
+		utils.BadRequest(w, "Invalid "+what)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondWorkspaceError responds to errors resolving the organization a
+// request acts in and reports whether err was one
+func respondWorkspaceError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrOrganizationNotFound):
+		utils.NotFound(w, "Organization not found")
+	case errors.Is(err, service.ErrInsufficientRole):
+		utils.Forbidden(w, err.Error())
+	case errors.Is(err, service.ErrMFARequired), errors.Is(err, service.ErrMFASessionRequired):
+		utils.RespondError(w, http.StatusForbidden, utils.ErrMFARequired, err.Error(), nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// respondOrganizationError maps organization errors to HTTP responses
+func respondOrganizationError(w http.ResponseWriter, message string, err error) {
+	if respondWorkspaceError(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrMemberNotFound):
+		utils.NotFound(w, "Organization member not found")
+	case err.Error() == "invitation not found":
+		utils.NotFound(w, "Invitation not found")
+	case errors.Is(err, service.ErrLastOwner):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrInvitationInvalid):
+		utils.BadRequest(w, err.Error())
+	case errors.Is(err, service.ErrInvitationEmailMismatch),
+		errors.Is(err, service.ErrInvitationUnverified):
+		utils.Forbidden(w, err.Error())
+	case errors.Is(err, service.ErrMFANotEnabled):
+		utils.Conflict(w, "Enable two-factor authentication on your account before requiring it")
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
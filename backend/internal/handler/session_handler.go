@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"net/http"
+
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+)
+
+// SessionHandler handles listing and revoking the signed-in devices of the
+// user
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+// NewSessionHandler creates a new SessionHandler
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// ListSessions handles listing the user's active sessions
+// @Summary      List sessions
+// @Description  List the devices the user is signed in on, with user agent, IP address, sign-in time and last use, most recently used first. current marks the session of the request
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Session          "Active sessions"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/sessions [get]
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(r.Context(), uid, currentRefreshToken(r))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to list sessions: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, sessions, "")
+}
+
+// RevokeSession handles signing out one session
+// @Summary      Revoke session
+// @Description  Sign out one of the user's sessions. Its refresh token stops working at once; its access token expires within 15 minutes
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string                  true  "Session UUID"
+// @Success      200  {object}  map[string]interface{}  "Session revoked"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing session ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Session not found or already revoked"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /auth/sessions/revoke [post]
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("id")
+	if sessionID == "" {
+		utils.BadRequest(w, "Missing session ID")
+		return
+	}
+
+	id, err := utils.ParseUUID(sessionID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid session ID")
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(r.Context(), uid, id); err != nil {
+		if err.Error() == "refresh token not found" {
+			utils.NotFound(w, "Session not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to revoke session: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Session revoked successfully")
+}
+
+// RevokeOtherSessions handles signing out every other session
+// @Summary      Revoke other sessions
+// @Description  Sign out every session of the user except the one of the request (identified by its refresh token cookie; without it all sessions are revoked)
+// @Tags         Auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.RevokeSessionsResponse  "Number of sessions revoked"
+// @Failure      401  {object}  map[string]interface{}         "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}         "Internal server error"
+// @Router       /auth/sessions/revoke-others [post]
+func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.sessionService.RevokeOtherSessions(r.Context(), uid, currentRefreshToken(r))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to revoke sessions: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, result, "Other sessions revoked successfully")
+}
+
+// currentRefreshToken returns the refresh token cookie of the request, if
+// any
+func currentRefreshToken(r *http.Request) string {
+	if cookie, err := r.Cookie("refresh_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+
+	"github.com/google/uuid"
+)
+
+// chunkReadTimeout bounds how long a single chunk request may take to
+// arrive; it replaces the server-wide read timeout for chunk uploads
+const chunkReadTimeout = 10 * time.Minute
+
+// UploadHandler handles chunked upload HTTP requests
+type UploadHandler struct {
+	uploadService *service.UploadService
+}
+
+// NewUploadHandler creates a new UploadHandler
+func NewUploadHandler(uploadService *service.UploadService) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+	}
+}
+
+// CreateUpload handles starting a chunked upload
+// @Summary      Create chunked upload
+// @Description  Start a resumable upload for a schema or data file that is too large for a single request
+// @Description  Send the bytes with PUT /api/v1/uploads/chunk, then finalise with POST /api/v1/uploads/complete
+// @Tags         Uploads
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateUploadRequest  true  "File name, purpose and optional total size"
+// @Success      201      {object}  map[string]interface{}      "Upload created"
+// @Failure      400      {object}  map[string]interface{}      "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}      "Unauthorized"
+// @Failure      413      {object}  map[string]interface{}      "File exceeds the maximum upload size"
+// @Failure      500      {object}  map[string]interface{}      "Internal server error"
+// @Router       /api/v1/uploads [post]
+func (h *UploadHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CreateUploadRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	upload, err := h.uploadService.CreateUpload(r.Context(), uid, &req)
+	if err != nil {
+		respondUploadError(w, "Failed to create upload", err)
+		return
+	}
+
+	setUploadOffset(w, upload)
+	utils.RespondSuccess(w, http.StatusCreated, upload, "Upload created")
+}
+
+// GetUpload handles retrieving the state of an upload
+// @Summary      Get upload status
+// @Description  Retrieve an upload; receivedBytes (also sent as the Upload-Offset header) is the offset to resume from
+// @Tags         Uploads
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Upload UUID"
+// @Success      200  {object}  map[string]interface{}  "Upload status"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing upload ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Upload not found or expired"
+// @Router       /api/v1/uploads/get [get]
+func (h *UploadHandler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	id, uid, ok := uploadRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	upload, err := h.uploadService.GetUpload(r.Context(), id, uid)
+	if err != nil {
+		respondUploadError(w, "Failed to get upload", err)
+		return
+	}
+
+	setUploadOffset(w, upload)
+	utils.RespondSuccess(w, http.StatusOK, upload, "")
+}
+
+// UploadChunk handles receiving a byte range of an upload
+// @Summary      Upload chunk
+// @Description  Append the raw request body at the given offset, taken from a "Content-Range: bytes start-end/total" header or the offset query parameter
+// @Description  Chunks must be sent in order; on 409 resume from receivedBytes in the error details
+// @Tags         Uploads
+// @Accept       application/octet-stream
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id             query     string  true   "Upload UUID"
+// @Param        offset         query     int     false  "Offset of the chunk (when Content-Range is not sent)"
+// @Param        Content-Range  header    string  false  "Byte range of the chunk"
+// @Success      200            {object}  map[string]interface{}  "Chunk stored"
+// @Failure      400            {object}  map[string]interface{}  "Invalid range or incomplete chunk"
+// @Failure      401            {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404            {object}  map[string]interface{}  "Upload not found or expired"
+// @Failure      409            {object}  map[string]interface{}  "Offset does not match the received bytes, or upload not in progress"
+// @Failure      413            {object}  map[string]interface{}  "Chunk or file too large"
+// @Router       /api/v1/uploads/chunk [put]
+func (h *UploadHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := uploadRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	offset, length, err := parseChunkRange(r)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	// Large chunks over slow links need more than the server-wide timeouts
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(chunkReadTimeout)
+	for _, set := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := set(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			logger.Warn("Failed to extend deadline for chunk upload", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	upload, err := h.uploadService.WriteChunk(r.Context(), id, uid, offset, length, r.Body)
+	if err != nil {
+		if errors.Is(err, service.ErrUploadOffsetMismatch) && upload != nil {
+			setUploadOffset(w, upload)
+			utils.RespondError(w, http.StatusConflict, utils.ErrConflict, err.Error(), map[string]interface{}{
+				"receivedBytes": upload.ReceivedBytes,
+			})
+			return
+		}
+		respondUploadError(w, "Failed to store chunk", err)
+		return
+	}
+
+	setUploadOffset(w, upload)
+	utils.RespondSuccess(w, http.StatusOK, upload, "Chunk stored")
+}
+
+// CompleteUpload handles finalising an upload
+// @Summary      Complete upload
+// @Description  Assemble the received chunks and verify the SHA-256 of the whole file
+// @Description  On a checksum mismatch the upload is marked failed and must be restarted
+// @Tags         Uploads
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       query     string                        true  "Upload UUID"
+// @Param        request  body      models.CompleteUploadRequest  true  "SHA-256 of the file"
+// @Success      200      {object}  map[string]interface{}        "Upload completed"
+// @Failure      400      {object}  map[string]interface{}        "Invalid checksum or upload incomplete"
+// @Failure      401      {object}  map[string]interface{}        "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}        "Upload not found or expired"
+// @Failure      409      {object}  map[string]interface{}        "Upload not in progress"
+// @Failure      422      {object}  map[string]interface{}        "Checksum mismatch"
+// @Router       /api/v1/uploads/complete [post]
+func (h *UploadHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := uploadRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CompleteUploadRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	upload, err := h.uploadService.CompleteUpload(r.Context(), id, uid, req.Checksum)
+	if err != nil {
+		respondUploadError(w, "Failed to complete upload", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, upload, "Upload completed")
+}
+
+// DeleteUpload handles aborting or deleting an upload
+// @Summary      Delete upload
+// @Description  Abort an upload in progress or delete a completed one, including its stored bytes
+// @Tags         Uploads
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   query     string  true  "Upload UUID"
+// @Success      200  {object}  map[string]interface{}  "Upload deleted"
+// @Failure      400  {object}  map[string]interface{}  "Invalid or missing upload ID"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "Upload not found"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/uploads/delete [delete]
+func (h *UploadHandler) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, uid, ok := uploadRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.DeleteUpload(r.Context(), id, uid); err != nil {
+		respondUploadError(w, "Failed to delete upload", err)
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, nil, "Upload deleted")
+}
+
+// parseChunkRange returns the offset and announced length of a chunk
+// request; length is -1 when unknown
+func parseChunkRange(r *http.Request) (int64, int64, error) {
+	if header := r.Header.Get("Content-Range"); header != "" {
+		// bytes <start>-<end>/<total or *>
+		spec, ok := strings.CutPrefix(header, "bytes ")
+		rangePart, _, hasTotal := strings.Cut(spec, "/")
+		startStr, endStr, hasEnd := strings.Cut(rangePart, "-")
+		if !ok || !hasTotal || !hasEnd {
+			return 0, 0, fmt.Errorf("invalid Content-Range header, expected \"bytes start-end/total\"")
+		}
+		start, err1 := strconv.ParseInt(startStr, 10, 64)
+		end, err2 := strconv.ParseInt(endStr, 10, 64)
+		if err1 != nil || err2 != nil || start < 0 || end < start {
+			return 0, 0, fmt.Errorf("invalid Content-Range header, expected \"bytes start-end/total\"")
+		}
+		return start, end - start + 1, nil
+	}
+
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr == "" {
+		return 0, 0, fmt.Errorf("missing Content-Range header or offset parameter")
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset parameter")
+	}
+	return offset, r.ContentLength, nil
+}
+
+// setUploadOffset exposes the resume offset as a header
+func setUploadOffset(w http.ResponseWriter, upload *models.UploadResponse) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.ReceivedBytes, 10))
+}
+
+// uploadRequestIDs parses the upload ID query parameter and the current user ID
+func uploadRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	uploadID := r.URL.Query().Get("id")
+	if uploadID == "" {
+		utils.BadRequest(w, "Missing upload ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	// Parse upload ID
+	id, err := utils.ParseUUID(uploadID)
+	if err != nil {
+		utils.BadRequest(w, "Invalid upload ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return id, uid, true
+}
+
+// respondUploadError maps upload errors to HTTP responses
+func respondUploadError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrUploadTooLarge):
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, utils.ErrBadRequest, err.Error(), nil)
+	case errors.Is(err, service.ErrChecksumMismatch):
+		utils.RespondError(w, http.StatusUnprocessableEntity, utils.ErrValidationFailed, err.Error(), nil)
+	case errors.Is(err, service.ErrUploadOffsetMismatch),
+		errors.Is(err, service.ErrUploadNotInProgress):
+		utils.Conflict(w, err.Error())
+	case errors.Is(err, service.ErrChunkIncomplete),
+		errors.Is(err, service.ErrUploadIncomplete),
+		errors.Is(err, service.ErrInvalidChecksum),
+		errors.Is(err, service.ErrUploadNotCompleted),
+		errors.Is(err, service.ErrUploadWrongPurpose):
+		utils.BadRequest(w, err.Error())
+	case err.Error() == "upload not found":
+		utils.NotFound(w, "Upload not found")
+	default:
+		utils.InternalServerError(w, message+": "+err.Error())
+	}
+}
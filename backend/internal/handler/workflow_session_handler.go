@@ -2,12 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"db-importer/ingest"
 	"db-importer/internal/models"
 	"db-importer/internal/service"
 	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
 )
 
 // WorkflowSessionHandler handles workflow session HTTP requests
@@ -22,82 +29,910 @@ func NewWorkflowSessionHandler(sessionService *service.WorkflowSessionService) *
 	}
 }
 
-// GetSession handles retrieving the active workflow session
-// @Summary      Get active workflow session
-// @Description  Retrieve the current workflow session for the authenticated user
-// @Description  Returns nil if no active session exists
+// sessionIDFromPath reads the workflow session ID of /workflow/sessions/{id}
+// routes. Routes without one alias the user's most recent session and get
+// uuid.Nil.
+func sessionIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
+		return uuid.Nil, true
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil || id == uuid.Nil {
+		utils.BadRequest(w, "Invalid workflow session ID")
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// sessionWriteTarget reads the session ID of a write like sessionIDFromPath
+// and the version it is based on from an If-Match header. The version is 0
+// when the write is not based on a particular version.
+func sessionWriteTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, int, bool) {
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return uuid.Nil, 0, false
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return sessionID, 0, true
+	}
+
+	// ETags are the quoted version; weak ones are accepted too
+	tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		utils.BadRequest(w, "Invalid If-Match header: expected the ETag of the session")
+		return uuid.Nil, 0, false
+	}
+
+	return sessionID, version, true
+}
+
+// setSessionETag sends the session version as the ETag, to be returned in
+// the If-Match header of the next write
+func setSessionETag(w http.ResponseWriter, session *models.WorkflowSessionResponse) {
+	if session != nil {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, session.Version))
+	}
+}
+
+// respondConflict answers a write based on an outdated version of the
+// session with 409 and the current state of the session
+func (h *WorkflowSessionHandler) respondConflict(w http.ResponseWriter, r *http.Request, userID, sessionID uuid.UUID, err error) {
+	current, getErr := h.sessionService.GetSession(r.Context(), userID, sessionID)
+	if getErr != nil {
+		utils.InternalServerError(w, "Failed to get session: "+getErr.Error())
+		return
+	}
+
+	setSessionETag(w, current)
+	utils.RespondError(w, http.StatusConflict, utils.ErrConflict, err.Error(), map[string]interface{}{
+		"session": current,
+	})
+}
+
+// CreateSession handles starting a new workflow session
+// @Summary      Create workflow session
+// @Description  Start a new, empty workflow session next to the user's other sessions; at most 20 can be in progress
+// @Description  Step endpoints under /workflow/sessions/{id} fill the new session
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateWorkflowSessionRequest  false  "Optional session name"
+// @Success      201      {object}  map[string]interface{}               "Session created successfully"
+// @Failure      400      {object}  map[string]interface{}               "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}               "Unauthorized"
+// @Failure      409      {object}  map[string]interface{}               "Too many sessions in progress"
+// @Failure      500      {object}  map[string]interface{}               "Internal server error"
+// @Router       /api/v1/workflow/sessions [post]
+func (h *WorkflowSessionHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWorkflowSessionRequest
+
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.CreateSession(r.Context(), uid, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrTooManySessions) {
+			utils.Conflict(w, err.Error())
+			return
+		}
+		utils.InternalServerError(w, "Failed to create session: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusCreated, session, "Session created successfully")
+}
+
+// ListSessions handles listing the user's workflow sessions
+// @Summary      List workflow sessions
+// @Description  List the user's active workflow sessions, most recently updated first, without schema, sample data or mapping
+// @Tags         Workflow Sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.WorkflowSessionSummary  "Active sessions"
+// @Failure      401  {object}  map[string]interface{}         "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}         "Internal server error"
+// @Router       /api/v1/workflow/sessions [get]
+func (h *WorkflowSessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(r.Context(), uid)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to list sessions: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, http.StatusOK, sessions, "")
+}
+
+// RenameSession handles renaming a workflow session
+// @Summary      Rename workflow session
+// @Description  Change the name telling a session apart from the user's other sessions
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                               true  "Workflow session UUID"
+// @Param        If-Match header    string                               false  "ETag of the session version the change is based on"
+// @Param        request  body      models.RenameWorkflowSessionRequest  true  "New name"
+// @Success      200      {object}  map[string]interface{}               "Session renamed successfully"
+// @Failure      400      {object}  map[string]interface{}               "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}               "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}               "No active session found"
+// @Failure      409      {object}  map[string]interface{}               "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}               "Internal server error"
+// @Router       /api/v1/workflow/sessions/{id} [put]
+func (h *WorkflowSessionHandler) RenameSession(w http.ResponseWriter, r *http.Request) {
+	var req models.RenameWorkflowSessionRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.RenameSession(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		switch err.Error() {
+		case "no active session found":
+			utils.NotFound(w, "No active session found")
+		case "session name must not be blank":
+			utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		default:
+			utils.InternalServerError(w, "Failed to rename session: "+err.Error())
+		}
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Session renamed successfully")
+}
+
+// GetSession handles retrieving the active workflow session
+// @Summary      Get active workflow session
+// @Description  Retrieve the current workflow session for the authenticated user
+// @Description  Returns nil if no active session exists; on /workflow/sessions/{id} a missing session is a 404
+// @Tags         Workflow Sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Success      200  {object}  map[string]interface{}  "Active session or null if none exists"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/workflow/session [get]
+// @Router       /api/v1/workflow/sessions/{id} [get]
+func (h *WorkflowSessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Get session
+	session, err := h.sessionService.GetSession(r.Context(), uid, sessionID)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to get session: "+err.Error())
+		return
+	}
+
+	// A session asked for by ID must exist
+	if session == nil && sessionID != uuid.Nil {
+		utils.NotFound(w, "No active session found")
+		return
+	}
+
+	if session != nil {
+		setSessionETag(w, session)
+	}
+	utils.RespondSuccess(w, http.StatusOK, session, "")
+}
+
+// GetSessionWithSchema handles retrieving the session with schema content
+// @Summary      Get session with schema content
+// @Description  Retrieve the workflow session including decompressed schema content
+// @Tags         Workflow Sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Success      200  {object}  map[string]interface{}  "Session with schema content"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "No active session found"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/workflow/session/schema [get]
+// @Router       /api/v1/workflow/sessions/{id}/schema [get]
+func (h *WorkflowSessionHandler) GetSessionWithSchema(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Get session with schema
+	session, err := h.sessionService.GetSessionWithSchema(r.Context(), uid, sessionID)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to get session: "+err.Error())
+		return
+	}
+
+	if session == nil {
+		utils.NotFound(w, "No active session found")
+		return
+	}
+
+	setSessionETag(w, &session.WorkflowSessionResponse)
+	utils.RespondSuccess(w, http.StatusOK, session, "")
+}
+
+// SaveSchema handles saving schema content (step 1)
+// @Summary      Save schema (Step 1)
+// @Description  Save SQL schema content and parsed tables for the workflow
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveSchemaRequest  true  "Schema content and tables"
+// @Success      200      {object}  map[string]interface{}    "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}    "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}    "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}    "No active session found"
+// @Failure      409      {object}  map[string]interface{}    "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}    "Internal server error"
+// @Router       /api/v1/workflow/session/schema [post]
+// @Router       /api/v1/workflow/sessions/{id}/schema [post]
+func (h *WorkflowSessionHandler) SaveSchema(w http.ResponseWriter, r *http.Request) {
+	var req models.SaveSchemaRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// Save schema
+	session, err := h.sessionService.SaveSchema(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to save schema: "+err.Error())
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Schema saved successfully")
+}
+
+// SaveSchemaFromConnection handles filling step 1 from a saved connection
+// @Summary      Save schema from connection (Step 1)
+// @Description  Introspect a saved connection profile (tables, keys, defaults, foreign keys) and store the result as the session schema
+// @Description  Alternative to uploading schema content
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveSchemaFromConnectionRequest  true  "Connection profile ID"
+// @Success      200      {object}  map[string]interface{}                  "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}                  "Invalid request, connection failed or no tables found"
+// @Failure      401      {object}  map[string]interface{}                  "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}                  "Session or connection profile not found"
+// @Failure      409      {object}  map[string]interface{}                  "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}                  "Internal server error"
+// @Router       /api/v1/workflow/session/schema/connection [post]
+// @Router       /api/v1/workflow/sessions/{id}/schema/connection [post]
+func (h *WorkflowSessionHandler) SaveSchemaFromConnection(w http.ResponseWriter, r *http.Request) {
+	var req models.SaveSchemaFromConnectionRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.SaveSchemaFromConnection(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		respondConnectionProfileError(w, "Failed to read schema from connection", err)
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Schema saved successfully")
+}
+
+// SaveSchemaFromUpload handles filling step 1 from a chunked upload
+// @Summary      Save schema from upload (Step 1)
+// @Description  Parse a completed chunked upload (purpose "schema") with the schema parser registry and store the result as the session schema
+// @Description  Format and dialect behave as on /parse-schema
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveSchemaFromUploadRequest  true  "Upload ID and optional format and dialect"
+// @Success      200      {object}  map[string]interface{}              "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}              "Invalid request, upload not completed or no tables found"
+// @Failure      401      {object}  map[string]interface{}              "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}              "Session or upload not found"
+// @Failure      409      {object}  map[string]interface{}              "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}              "Internal server error"
+// @Router       /api/v1/workflow/session/schema/upload [post]
+// @Router       /api/v1/workflow/sessions/{id}/schema/upload [post]
+func (h *WorkflowSessionHandler) SaveSchemaFromUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.SaveSchemaFromUploadRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.SaveSchemaFromUpload(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		switch {
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, service.ErrNoTablesFound), errors.Is(err, service.ErrInvalidUploadContent):
+			utils.BadRequest(w, "Failed to parse schema: "+err.Error())
+		default:
+			respondUploadError(w, "Failed to save schema", err)
+		}
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Schema saved successfully")
+}
+
+// SaveTableSelection handles saving table selection (step 2)
+// @Summary      Save table selection (Step 2)
+// @Description  Save the selected table name for the workflow
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveTableSelectionRequest  true  "Table name"
+// @Success      200      {object}  map[string]interface{}            "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}            "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}            "No active session found"
+// @Failure      409      {object}  map[string]interface{}            "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}            "Internal server error"
+// @Router       /api/v1/workflow/session/table [post]
+// @Router       /api/v1/workflow/sessions/{id}/table [post]
+func (h *WorkflowSessionHandler) SaveTableSelection(w http.ResponseWriter, r *http.Request) {
+	var req models.SaveTableSelectionRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// Save table selection
+	session, err := h.sessionService.SaveTableSelection(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to save table selection: "+err.Error())
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Table selection saved successfully")
+}
+
+// SaveDataFile handles saving data file information (step 3)
+// @Summary      Save data file (Step 3)
+// @Description  Save data file name, headers, and sample data for the workflow
+// @Description  When all rows are sent they are kept in object storage for /generate, and the sample defaults to the first 50
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveDataFileRequest  true  "Data file information"
+// @Success      200      {object}  map[string]interface{}      "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}      "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}      "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}      "No active session found"
+// @Failure      409      {object}  map[string]interface{}      "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}      "Internal server error"
+// @Router       /api/v1/workflow/session/data [post]
+// @Router       /api/v1/workflow/sessions/{id}/data [post]
+func (h *WorkflowSessionHandler) SaveDataFile(w http.ResponseWriter, r *http.Request) {
+	var req models.SaveDataFileRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// Save data file
+	session, err := h.sessionService.SaveDataFile(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to save data file: "+err.Error())
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Data file saved successfully")
+}
+
+// SaveDataFromUpload handles filling step 3 from a chunked upload
+// @Summary      Save data file from upload (Step 3)
+// @Description  Read the headers and first 50 rows of a completed chunked CSV or TSV upload (purpose "data") into the session
+// @Tags         Workflow Sessions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveDataFromUploadRequest  true  "Upload ID"
+// @Success      200      {object}  map[string]interface{}            "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}            "Invalid request, unsupported file or upload not completed"
+// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}            "No active session or upload not found"
+// @Failure      409      {object}  map[string]interface{}            "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}            "Internal server error"
+// @Router       /api/v1/workflow/session/data/upload [post]
+// @Router       /api/v1/workflow/sessions/{id}/data/upload [post]
+func (h *WorkflowSessionHandler) SaveDataFromUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.SaveDataFromUploadRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.SaveDataFromUpload(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		switch {
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, ingest.ErrUnsupportedFormat), errors.Is(err, service.ErrInvalidUploadContent):
+			utils.BadRequest(w, "Failed to read data file: "+err.Error())
+		default:
+			respondUploadError(w, "Failed to save data file", err)
+		}
+		return
+	}
+
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Data file saved successfully")
+}
+
+// SaveMapping handles saving column mapping (step 4)
+// @Summary      Save column mapping (Step 4)
+// @Description  Save the column mapping between data file and database table
 // @Tags         Workflow Sessions
+// @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {object}  map[string]interface{}  "Active session or null if none exists"
-// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
-// @Failure      500  {object}  map[string]interface{}  "Internal server error"
-// @Router       /api/v1/workflow/session [get]
-func (h *WorkflowSessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.SaveMappingRequest  true  "Column mapping"
+// @Success      200      {object}  map[string]interface{}     "Session updated successfully"
+// @Failure      400      {object}  map[string]interface{}     "Invalid request or validation failed"
+// @Failure      401      {object}  map[string]interface{}     "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}     "No active session found"
+// @Failure      409      {object}  map[string]interface{}     "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}     "Internal server error"
+// @Router       /api/v1/workflow/session/mapping [post]
+// @Router       /api/v1/workflow/sessions/{id}/mapping [post]
+func (h *WorkflowSessionHandler) SaveMapping(w http.ResponseWriter, r *http.Request) {
+	var req models.SaveMappingRequest
+
+	// Parse request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	// Validate request
+	if err := utils.ValidateStruct(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, utils.ErrValidationFailed, err.Error(), nil)
+		return
+	}
+
 	// Get user ID from context
 	uid, ok := utils.GetUserIDFromContext(w, r)
 	if !ok {
 		return
 	}
 
-	// Get session
-	session, err := h.sessionService.GetSession(r.Context(), uid)
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// Save mapping
+	session, err := h.sessionService.SaveMapping(r.Context(), uid, sessionID, expectedVersion, &req)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to get session: "+err.Error())
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to save mapping: "+err.Error())
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "")
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Mapping saved successfully")
 }
 
-// GetSessionWithSchema handles retrieving the session with schema content
-// @Summary      Get session with schema content
-// @Description  Retrieve the workflow session including decompressed schema content
+// SuggestMappingTemplates handles listing the templates matching the data file
+// @Summary      Suggest mapping templates
+// @Description  List the mapping templates made for data files with the same headers as the session's (in any order, ignoring case, spaces and punctuation); templates for the selected table come first
 // @Tags         Workflow Sessions
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {object}  map[string]interface{}  "Session with schema content"
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Success      200  {object}  map[string]interface{}  "Matching templates"
 // @Failure      401  {object}  map[string]interface{}  "Unauthorized"
 // @Failure      404  {object}  map[string]interface{}  "No active session found"
 // @Failure      500  {object}  map[string]interface{}  "Internal server error"
-// @Router       /api/v1/workflow/session/schema [get]
-func (h *WorkflowSessionHandler) GetSessionWithSchema(w http.ResponseWriter, r *http.Request) {
+// @Router       /api/v1/workflow/session/templates [get]
+// @Router       /api/v1/workflow/sessions/{id}/templates [get]
+func (h *WorkflowSessionHandler) SuggestMappingTemplates(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	uid, ok := utils.GetUserIDFromContext(w, r)
 	if !ok {
 		return
 	}
 
-	// Get session with schema
-	session, err := h.sessionService.GetSessionWithSchema(r.Context(), uid)
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	suggestions, err := h.sessionService.SuggestMappingTemplates(r.Context(), uid, sessionID)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to get session: "+err.Error())
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to suggest mapping templates: "+err.Error())
 		return
 	}
 
-	if session == nil {
-		utils.NotFound(w, "No active session found")
+	utils.RespondSuccess(w, http.StatusOK, suggestions, "")
+}
+
+// ApplyMappingTemplate handles filling step 4 from a mapping template
+// @Summary      Apply mapping template (Step 4)
+// @Description  Set the session's column mapping and transformations from a mapping template. Headers missing from the data file and fields missing from the selected table are skipped and reported.
+// @Tags         Workflow Sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        id   query     string  true  "Mapping template UUID"
+// @Success      200  {object}  map[string]interface{}  "Session updated with the applied mapping"
+// @Failure      400  {object}  map[string]interface{}  "Invalid template ID or no data file yet"
+// @Failure      401  {object}  map[string]interface{}  "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}  "No active session or mapping template not found"
+// @Failure      409  {object}  map[string]interface{}  "Session changed since the If-Match version; details hold the current session"
+// @Failure      500  {object}  map[string]interface{}  "Internal server error"
+// @Router       /api/v1/workflow/session/templates/apply [post]
+// @Router       /api/v1/workflow/sessions/{id}/templates/apply [post]
+func (h *WorkflowSessionHandler) ApplyMappingTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "")
+	id, uid, ok := templateRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.sessionService.ApplyMappingTemplate(r.Context(), uid, sessionID, expectedVersion, id)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		if err.Error() == "no active session found" {
+			utils.NotFound(w, "No active session found")
+			return
+		}
+		respondMappingTemplateError(w, "Failed to apply mapping template", err)
+		return
+	}
+
+	setSessionETag(w, result.Session)
+	utils.RespondSuccess(w, http.StatusOK, result, "Mapping template applied")
 }
 
-// SaveSchema handles saving schema content (step 1)
-// @Summary      Save schema (Step 1)
-// @Description  Save SQL schema content and parsed tables for the workflow
+// InferTable handles creating the target table from the session's data file
+// @Summary      Infer table from data file
+// @Description  Infer column types, nullability and a primary key from the session's sample rows and generate a CREATE TABLE statement
+// @Description  The inferred table replaces the session schema and is selected, and every column is mapped to it (step 4)
 // @Tags         Workflow Sessions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      models.SaveSchemaRequest  true  "Schema content and tables"
-// @Success      200      {object}  map[string]interface{}    "Session updated successfully"
-// @Failure      400      {object}  map[string]interface{}    "Invalid request or validation failed"
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.InferTableRequest  true  "Optional table name and dialect"
+// @Success      200      {object}  models.InferTableResult   "Session, CREATE TABLE statement and inferred columns"
+// @Failure      400      {object}  map[string]interface{}    "Invalid request or no data file yet"
 // @Failure      401      {object}  map[string]interface{}    "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}    "No active session found"
+// @Failure      409      {object}  map[string]interface{}    "Session changed since the If-Match version; details hold the current session"
 // @Failure      500      {object}  map[string]interface{}    "Internal server error"
-// @Router       /api/v1/workflow/session/schema [post]
-func (h *WorkflowSessionHandler) SaveSchema(w http.ResponseWriter, r *http.Request) {
-	var req models.SaveSchemaRequest
+// @Router       /api/v1/workflow/session/infer [post]
+// @Router       /api/v1/workflow/sessions/{id}/infer [post]
+func (h *WorkflowSessionHandler) InferTable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.InferTableRequest
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -117,32 +952,62 @@ func (h *WorkflowSessionHandler) SaveSchema(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Save schema
-	session, err := h.sessionService.SaveSchema(r.Context(), uid, &req)
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.sessionService.InferTable(r.Context(), uid, sessionID, expectedVersion, &req)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to save schema: "+err.Error())
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		switch {
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, service.ErrNoDataFile):
+			utils.BadRequest(w, err.Error())
+		default:
+			utils.InternalServerError(w, "Failed to infer table: "+err.Error())
+		}
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "Schema saved successfully")
+	setSessionETag(w, result.Session)
+	utils.RespondSuccess(w, http.StatusOK, result, "Table inferred successfully")
 }
 
-// SaveTableSelection handles saving table selection (step 2)
-// @Summary      Save table selection (Step 2)
-// @Description  Save the selected table name for the workflow
+// InferTableFromUpload handles filling the session from a chunked upload
+// and creating the target table from all of its rows
+// @Summary      Infer table from upload
+// @Description  Read a completed chunked CSV or TSV upload (purpose "data") into the session like /data/upload, inferring the table from every row rather than the sample
+// @Description  On /workflow/session a session is created when there is none; otherwise behaves as /infer
 // @Tags         Workflow Sessions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      models.SaveTableSelectionRequest  true  "Table name"
-// @Success      200      {object}  map[string]interface{}            "Session updated successfully"
-// @Failure      400      {object}  map[string]interface{}            "Invalid request or validation failed"
-// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
-// @Failure      404      {object}  map[string]interface{}            "No active session found"
-// @Failure      500      {object}  map[string]interface{}            "Internal server error"
-// @Router       /api/v1/workflow/session/table [post]
-func (h *WorkflowSessionHandler) SaveTableSelection(w http.ResponseWriter, r *http.Request) {
-	var req models.SaveTableSelectionRequest
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
+// @Param        request  body      models.InferTableFromUploadRequest  true  "Upload ID and optional table name and dialect"
+// @Success      200      {object}  models.InferTableResult             "Session, CREATE TABLE statement and inferred columns"
+// @Failure      400      {object}  map[string]interface{}              "Invalid request, unsupported file or upload not completed"
+// @Failure      401      {object}  map[string]interface{}              "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}              "Session or upload not found"
+// @Failure      409      {object}  map[string]interface{}              "Session changed since the If-Match version; details hold the current session"
+// @Failure      500      {object}  map[string]interface{}              "Internal server error"
+// @Router       /api/v1/workflow/session/infer/upload [post]
+// @Router       /api/v1/workflow/sessions/{id}/infer/upload [post]
+func (h *WorkflowSessionHandler) InferTableFromUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.InferTableFromUploadRequest
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -162,36 +1027,107 @@ func (h *WorkflowSessionHandler) SaveTableSelection(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Save table selection
-	session, err := h.sessionService.SaveTableSelection(r.Context(), uid, &req)
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.sessionService.InferTableFromUpload(r.Context(), uid, sessionID, expectedVersion, &req)
+	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
+		switch {
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, ingest.ErrUnsupportedFormat), errors.Is(err, service.ErrInvalidUploadContent):
+			utils.BadRequest(w, "Failed to read data file: "+err.Error())
+		default:
+			respondUploadError(w, "Failed to infer table", err)
+		}
+		return
+	}
+
+	setSessionETag(w, result.Session)
+	utils.RespondSuccess(w, http.StatusOK, result, "Table inferred successfully")
+}
+
+// ListHistory handles listing the changes of a workflow session
+// @Summary      List session history
+// @Description  List the changes of a workflow session, newest first, each with the step, table selection and mapping it left the session in
+// @Tags         Workflow Sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Success      200  {array}   models.WorkflowSessionHistoryEntry  "Session history"
+// @Failure      401  {object}  map[string]interface{}              "Unauthorized"
+// @Failure      404  {object}  map[string]interface{}              "No active session found"
+// @Failure      500  {object}  map[string]interface{}              "Internal server error"
+// @Router       /api/v1/workflow/session/history [get]
+// @Router       /api/v1/workflow/sessions/{id}/history [get]
+func (h *WorkflowSessionHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context
+	uid, ok := utils.GetUserIDFromContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.sessionService.ListHistory(r.Context(), uid, sessionID)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
 		if err.Error() == "no active session found" {
 			utils.NotFound(w, "No active session found")
 			return
 		}
-		utils.InternalServerError(w, "Failed to save table selection: "+err.Error())
+		utils.InternalServerError(w, "Failed to list session history: "+err.Error())
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "Table selection saved successfully")
+	utils.RespondSuccess(w, http.StatusOK, entries, "")
 }
 
-// SaveDataFile handles saving data file information (step 3)
-// @Summary      Save data file (Step 3)
-// @Description  Save data file name, headers, and sample data for the workflow
+// RestoreVersion handles undoing a workflow session to an earlier version
+// @Summary      Restore session version
+// @Description  Undo the session back to the step, table selection and mapping of an earlier version from its history
+// @Description  The schema and data file are kept; the restore is recorded as a new version
 // @Tags         Workflow Sessions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      models.SaveDataFileRequest  true  "Data file information"
-// @Success      200      {object}  map[string]interface{}      "Session updated successfully"
-// @Failure      400      {object}  map[string]interface{}      "Invalid request or validation failed"
-// @Failure      401      {object}  map[string]interface{}      "Unauthorized"
-// @Failure      404      {object}  map[string]interface{}      "No active session found"
-// @Failure      500      {object}  map[string]interface{}      "Internal server error"
-// @Router       /api/v1/workflow/session/data [post]
-func (h *WorkflowSessionHandler) SaveDataFile(w http.ResponseWriter, r *http.Request) {
-	var req models.SaveDataFileRequest
+// @Param        id        path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match  header    string  false  "ETag of the session version the change is based on"
+// @Param        request   body      models.RestoreWorkflowSessionRequest  true  "Version to restore"
+// @Success      200       {object}  map[string]interface{}                "Session restored successfully"
+// @Failure      400       {object}  map[string]interface{}                "Invalid request or the table of the version is no longer in the schema"
+// @Failure      401       {object}  map[string]interface{}                "Unauthorized"
+// @Failure      404       {object}  map[string]interface{}                "Session or version not found"
+// @Failure      409       {object}  map[string]interface{}                "Session changed since the If-Match version; details hold the current session"
+// @Failure      500       {object}  map[string]interface{}                "Internal server error"
+// @Router       /api/v1/workflow/session/history/restore [post]
+// @Router       /api/v1/workflow/sessions/{id}/history/restore [post]
+func (h *WorkflowSessionHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RestoreWorkflowSessionRequest
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -211,39 +1147,62 @@ func (h *WorkflowSessionHandler) SaveDataFile(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Save data file
-	session, err := h.sessionService.SaveDataFile(r.Context(), uid, &req)
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessionService.RestoreVersion(r.Context(), uid, sessionID, expectedVersion, &req)
 	if err != nil {
-		if err.Error() == "no active session found" {
-			utils.NotFound(w, "No active session found")
+		if respondWorkspaceError(w, err) {
 			return
 		}
-		utils.InternalServerError(w, "Failed to save data file: "+err.Error())
+		switch {
+		case errors.Is(err, service.ErrSessionConflict):
+			h.respondConflict(w, r, uid, sessionID, err)
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, service.ErrHistoryVersionNotFound):
+			utils.NotFound(w, err.Error())
+		case errors.Is(err, service.ErrInvalidMapping):
+			utils.BadRequest(w, err.Error())
+		default:
+			utils.InternalServerError(w, "Failed to restore session: "+err.Error())
+		}
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "Data file saved successfully")
+	setSessionETag(w, session)
+	utils.RespondSuccess(w, http.StatusOK, session, "Session restored successfully")
 }
 
-// SaveMapping handles saving column mapping (step 4)
-// @Summary      Save column mapping (Step 4)
-// @Description  Save the column mapping between data file and database table
+// GenerateSQL handles generating SQL for every row of the session's data file
+// @Summary      Generate SQL for the session
+// @Description  Generate INSERT SQL for all stored rows of the session's data file into the selected table, using the saved mapping and transformations
+// @Description  Needs a data file whose rows were kept (see /data and /data/upload); the SQL is recorded as an import
 // @Tags         Workflow Sessions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      models.SaveMappingRequest  true  "Column mapping"
-// @Success      200      {object}  map[string]interface{}     "Session updated successfully"
-// @Failure      400      {object}  map[string]interface{}     "Invalid request or validation failed"
-// @Failure      401      {object}  map[string]interface{}     "Unauthorized"
-// @Failure      404      {object}  map[string]interface{}     "No active session found"
-// @Failure      500      {object}  map[string]interface{}     "Internal server error"
-// @Router       /api/v1/workflow/session/mapping [post]
-func (h *WorkflowSessionHandler) SaveMapping(w http.ResponseWriter, r *http.Request) {
-	var req models.SaveMappingRequest
+// @Param        id       path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        request  body      models.GenerateSessionSQLRequest  false  "Optional key columns and upsert flag"
+// @Success      201      {object}  models.ImportResponse             "SQL generated successfully"
+// @Failure      400      {object}  map[string]interface{}            "Invalid request, no stored rows, or no table or mapping yet"
+// @Failure      401      {object}  map[string]interface{}            "Unauthorized"
+// @Failure      404      {object}  map[string]interface{}            "No active session found"
+// @Failure      500      {object}  map[string]interface{}            "Internal server error"
+// @Router       /api/v1/workflow/session/generate [post]
+// @Router       /api/v1/workflow/sessions/{id}/generate [post]
+func (h *WorkflowSessionHandler) GenerateSQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	// Parse request body
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var req models.GenerateSessionSQLRequest
+
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 		utils.BadRequest(w, "Invalid request body: "+err.Error())
 		return
 	}
@@ -260,18 +1219,31 @@ func (h *WorkflowSessionHandler) SaveMapping(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Save mapping
-	session, err := h.sessionService.SaveMapping(r.Context(), uid, &req)
+	sessionID, ok := sessionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.sessionService.GenerateSQL(r.Context(), uid, sessionID, &req)
 	if err != nil {
-		if err.Error() == "no active session found" {
-			utils.NotFound(w, "No active session found")
+		if respondWorkspaceError(w, err) {
 			return
 		}
-		utils.InternalServerError(w, "Failed to save mapping: "+err.Error())
+		switch {
+		case err.Error() == "no active session found":
+			utils.NotFound(w, "No active session found")
+		case errors.Is(err, service.ErrNoDataset),
+			errors.Is(err, service.ErrSessionIncomplete),
+			errors.Is(err, service.ErrNothingToExecute),
+			errors.Is(err, service.ErrInvalidMapping):
+			utils.BadRequest(w, err.Error())
+		default:
+			utils.InternalServerError(w, "Failed to generate SQL: "+err.Error())
+		}
 		return
 	}
 
-	utils.RespondSuccess(w, http.StatusOK, session, "Mapping saved successfully")
+	utils.RespondSuccess(w, http.StatusCreated, result, "SQL generated successfully")
 }
 
 // DeleteSession handles deleting a workflow session
@@ -280,11 +1252,15 @@ func (h *WorkflowSessionHandler) SaveMapping(w http.ResponseWriter, r *http.Requ
 // @Tags         Workflow Sessions
 // @Produce      json
 // @Security     BearerAuth
+// @Param        id   path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
 // @Success      200  {object}  map[string]interface{}  "Session deleted successfully"
 // @Failure      401  {object}  map[string]interface{}  "Unauthorized"
 // @Failure      404  {object}  map[string]interface{}  "Session not found"
+// @Failure      409  {object}  map[string]interface{}  "Session changed since the If-Match version; details hold the current session"
 // @Failure      500  {object}  map[string]interface{}  "Internal server error"
 // @Router       /api/v1/workflow/session [delete]
+// @Router       /api/v1/workflow/sessions/{id} [delete]
 func (h *WorkflowSessionHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	uid, ok := utils.GetUserIDFromContext(w, r)
@@ -292,9 +1268,21 @@ func (h *WorkflowSessionHandler) DeleteSession(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
 	// Delete session
-	err := h.sessionService.DeleteSession(r.Context(), uid)
+	err := h.sessionService.DeleteSession(r.Context(), uid, sessionID, expectedVersion)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
 		if err.Error() == "workflow session not found" {
 			utils.NotFound(w, "Session not found")
 			return
@@ -312,13 +1300,17 @@ func (h *WorkflowSessionHandler) DeleteSession(w http.ResponseWriter, r *http.Re
 // @Tags         Workflow Sessions
 // @Produce      json
 // @Security     BearerAuth
+// @Param        id    path      string  false  "Workflow session UUID on /workflow/sessions/{id} routes; otherwise the most recent session"
+// @Param        If-Match header    string  false  "ETag of the session version the change is based on"
 // @Param        days  query     int  false  "Number of days to extend (1-30, default: 7)"
 // @Success      200   {object}  map[string]interface{}  "Expiration extended successfully"
 // @Failure      400   {object}  map[string]interface{}  "Invalid days parameter"
 // @Failure      401   {object}  map[string]interface{}  "Unauthorized"
 // @Failure      404   {object}  map[string]interface{}  "Session not found or expired"
+// @Failure      409   {object}  map[string]interface{}  "Session changed since the If-Match version; details hold the current session"
 // @Failure      500   {object}  map[string]interface{}  "Internal server error"
 // @Router       /api/v1/workflow/session/extend [post]
+// @Router       /api/v1/workflow/sessions/{id}/extend [post]
 func (h *WorkflowSessionHandler) ExtendExpiration(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	uid, ok := utils.GetUserIDFromContext(w, r)
@@ -326,6 +1318,11 @@ func (h *WorkflowSessionHandler) ExtendExpiration(w http.ResponseWriter, r *http
 		return
 	}
 
+	sessionID, expectedVersion, ok := sessionWriteTarget(w, r)
+	if !ok {
+		return
+	}
+
 	// Parse query parameter for days
 	days := 7 // default to 7 days
 	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
@@ -336,8 +1333,15 @@ func (h *WorkflowSessionHandler) ExtendExpiration(w http.ResponseWriter, r *http
 	}
 
 	// Extend expiration
-	err := h.sessionService.ExtendExpiration(r.Context(), uid, days)
+	err := h.sessionService.ExtendExpiration(r.Context(), uid, sessionID, expectedVersion, days)
 	if err != nil {
+		if respondWorkspaceError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionConflict) {
+			h.respondConflict(w, r, uid, sessionID, err)
+			return
+		}
 		if err.Error() == "workflow session not found or expired" {
 			utils.NotFound(w, "Session not found or expired")
 			return
@@ -3,9 +3,11 @@ package handlers
 import (
 	"db-importer/errors"
 	"db-importer/generator"
+	"db-importer/infer"
 	"db-importer/internal/config"
 	"db-importer/internal/database"
 	"db-importer/logger"
+	"db-importer/mapping"
 	"db-importer/parser"
 	"db-importer/version"
 	"encoding/json"
@@ -16,14 +18,16 @@ import (
 
 // PublicHandler handles public API endpoints
 type PublicHandler struct {
-	config *config.Config
-	db     *database.DB
+	config   *config.Config
+	db       *database.DB
+	registry *parser.Registry
 }
 
-// NewPublicHandler creates a new PublicHandler
+// NewPublicHandler creates a new PublicHandler using the default parser registry
 func NewPublicHandler(cfg *config.Config) *PublicHandler {
 	return &PublicHandler{
-		config: cfg,
+		config:   cfg,
+		registry: parser.DefaultRegistry(),
 	}
 }
 
@@ -35,6 +39,23 @@ func (h *PublicHandler) SetDB(db *database.DB) {
 // Response structures
 type ParseSchemaResponse struct {
 	Tables []parser.Table `json:"tables"`
+	// Format is the name of the registered parser that produced the tables
+	Format string `json:"format"`
+	// Diagnostics lists statements that were skipped or could not be parsed
+	Diagnostics []parser.Diagnostic `json:"diagnostics,omitempty"`
+	// Dialect is the SQL dialect the schema was parsed as
+	Dialect parser.Dialect `json:"dialect"`
+	// DialectSource is "explicit" when forced via the dialect form field, "format" when
+	// declared by the schema format itself (e.g. a Prisma datasource), "detected" otherwise
+	DialectSource string `json:"dialectSource"`
+	// Detection holds the marker scores, even when the dialect was forced
+	Detection parser.DialectDetection `json:"detection"`
+}
+
+// SchemaFormatsResponse lists the schema formats accepted by /parse-schema
+type SchemaFormatsResponse struct {
+	Formats  []parser.FormatInfo `json:"formats"`
+	Dialects []parser.Dialect    `json:"dialects"`
 }
 
 type GenerateSQLRequest struct {
@@ -42,6 +63,51 @@ type GenerateSQLRequest struct {
 	Mapping map[string]string     `json:"mapping"`
 	Rows    [][]interface{}       `json:"rows"`
 	Fields  []generator.FieldInfo `json:"fields"`
+	// Dialect controls identifier quoting and escaping (default: mysql)
+	Dialect string `json:"dialect,omitempty"`
+}
+
+// SuggestMappingRequest carries a data file's headers and sample rows and
+// the table they are to be imported into
+type SuggestMappingRequest struct {
+	Headers    []string        `json:"headers"`
+	SampleData [][]interface{} `json:"sampleData"`
+	Table      parser.Table    `json:"table"`
+	// MinScore is the lowest score a column is mapped with (default 0.6)
+	MinScore float64 `json:"minScore,omitempty"`
+	// MaxCandidates caps the candidates listed per column (default 3)
+	MaxCandidates int `json:"maxCandidates,omitempty"`
+}
+
+// SuggestMappingResponse lists a suggestion per header, in header order,
+// and the resulting header -> field mapping
+type SuggestMappingResponse struct {
+	Suggestions []mapping.Suggestion `json:"suggestions"`
+	Mapping     map[string]string    `json:"mapping"`
+}
+
+// InferSchemaRequest carries the rows of a data file to infer a table from
+type InferSchemaRequest struct {
+	// TableName defaults to "imported_data"
+	TableName string          `json:"tableName,omitempty"`
+	Headers   []string        `json:"headers"`
+	Rows      [][]interface{} `json:"rows"`
+	// Dialect controls column types and quoting (default: mysql)
+	Dialect string `json:"dialect,omitempty"`
+}
+
+// InferSchemaResponse is the inferred table, usable like a table from
+// /parse-schema, with its CREATE TABLE statement and the column details
+type InferSchemaResponse struct {
+	Table   parser.Table   `json:"table"`
+	Dialect parser.Dialect `json:"dialect"`
+	SQL     string         `json:"sql"`
+	Rows    int            `json:"rows"`
+	Columns []infer.Column `json:"columns"`
+	// Mapping maps every header to its column
+	Mapping map[string]string `json:"mapping"`
+	// Transformations convert values (e.g. day-first dates) by column
+	Transformations map[string]string `json:"transformations,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -52,14 +118,17 @@ type ErrorResponse struct {
 
 // ParseSchema handles the /parse-schema endpoint
 // @Summary      Parse SQL schema from file
-// @Description  Upload a SQL dump file (.sql) to extract table definitions and column information
-// @Description  Supports MySQL and PostgreSQL schemas using TiDB parser with regex fallbacks
+// @Description  Upload a schema file to extract table definitions and column information
+// @Description  Every parser in the registry is scored against the file and the best match wins (see /schema-formats)
+// @Description  The dialect is auto-detected from markers in the file unless forced with the dialect field
 // @Tags         Schema
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        file  formData  file  true  "SQL schema file (.sql)"
+// @Param        file     formData  file    true   "Schema file (.sql or any extension listed by /schema-formats)"
+// @Param        dialect  formData  string  false  "Force a dialect (mysql, postgresql, sqlserver, sqlite)"
+// @Param        format   formData  string  false  "Force a parser by name (see /schema-formats)"
 // @Success      200   {object}  ParseSchemaResponse  "Successfully parsed schema with table definitions"
-// @Failure      400   {object}  ErrorResponse        "Invalid request (bad file, empty file, or no tables found)"
+// @Failure      400   {object}  ErrorResponse        "Invalid request (bad file, empty file, unknown dialect, or no tables found)"
 // @Failure      500   {object}  ErrorResponse        "Internal server error"
 // @Router       /parse-schema [post]
 func (h *PublicHandler) ParseSchema(w http.ResponseWriter, r *http.Request) {
@@ -84,14 +153,28 @@ func (h *PublicHandler) ParseSchema(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	forcedDialect, err := parser.ParseDialect(r.FormValue("dialect"))
+	if err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid dialect", err.Error()))
+		return
+	}
+
 	logger.Info("Processing schema file", map[string]interface{}{
 		"filename": fileHeader.Filename,
 		"size":     fileHeader.Size,
 	})
 
-	// Check file extension
-	if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".sql") {
-		errors.RespondWithError(w, errors.NewBadRequestError("Invalid file type", "Only .sql files are accepted"))
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+	if format != "" {
+		if _, ok := h.registry.Get(format); !ok {
+			errors.RespondWithError(w, errors.NewBadRequestError("Invalid format", "Unknown schema format '"+format+"', see /schema-formats"))
+			return
+		}
+	}
+
+	// Check file extension against the formats the registry understands
+	if format == "" && !h.registry.AcceptsExtension(fileHeader.Filename) {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid file type", "Unsupported file extension, see /schema-formats for accepted files"))
 		return
 	}
 
@@ -110,44 +193,86 @@ func (h *PublicHandler) ParseSchema(w http.ResponseWriter, r *http.Request) {
 
 	sqlContent := string(content)
 
-	// Try TiDB parser first (robust MySQL/PostgreSQL parser)
-	tables := parser.ParseWithTiDB(sqlContent)
+	// Detect the dialect from content markers; an explicit form value wins
+	detection := parser.DetectDialect(sqlContent)
+	dialect := detection.Dialect
+	dialectSource := "detected"
+	if forcedDialect != parser.DialectUnknown {
+		dialect = forcedDialect
+		dialectSource = "explicit"
+	}
 
-	// If no tables found, fallback to regex parsers
-	if len(tables) == 0 {
-		logger.Debug("TiDB parser found no tables, trying PostgreSQL parser")
-		tables = parser.ParsePostgreSQL(sqlContent)
+	logger.Debug("Schema dialect resolved", map[string]interface{}{
+		"dialect":    dialect,
+		"source":     dialectSource,
+		"confidence": detection.Confidence,
+	})
 
-		if len(tables) == 0 {
-			logger.Debug("PostgreSQL parser found no tables, trying MySQL parser")
-			tables = parser.ParseMySQL(sqlContent)
-		}
+	// Registered parsers are tried from best to worst match. Only a forced
+	// dialect is passed on: SQL parsers already weigh the detection, and
+	// ORM formats fall back to the dialect declared in the file.
+	result, err := h.registry.Parse(sqlContent, parser.ParseOptions{Format: format, Dialect: forcedDialect})
+	if err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Failed to parse schema", err.Error()))
+		return
 	}
 
-	if len(tables) == 0 {
-		logger.Warn("No tables found in SQL file", map[string]interface{}{
-			"filename": fileHeader.Filename,
+	if len(result.Tables) == 0 {
+		logger.Warn("No tables found in schema file", map[string]interface{}{
+			"filename":    fileHeader.Filename,
+			"diagnostics": len(result.Diagnostics),
 		})
 		errors.RespondWithError(w, errors.NewBadRequestError(
 			"No tables found",
-			"Could not parse any CREATE TABLE statements from the SQL file. Make sure the file contains valid MySQL or PostgreSQL table definitions.",
+			"Could not parse any table definitions from the file. Make sure it contains valid definitions in one of the formats listed by /schema-formats.",
 		))
 		return
 	}
 
+	if result.Dialect != parser.DialectUnknown && dialectSource != "explicit" {
+		dialect = result.Dialect
+		dialectSource = "format"
+	}
+
 	logger.Info("Successfully parsed schema", map[string]interface{}{
 		"filename":   fileHeader.Filename,
-		"tableCount": len(tables),
+		"tableCount": len(result.Tables),
+		"format":     result.Format,
+		"dialect":    dialect,
 	})
 
 	// Return response
 	response := ParseSchemaResponse{
-		Tables: tables,
+		Tables:        result.Tables,
+		Format:        result.Format,
+		Diagnostics:   result.Diagnostics,
+		Dialect:       dialect,
+		DialectSource: dialectSource,
+		Detection:     detection,
 	}
 
 	errors.RespondWithJSON(w, http.StatusOK, response)
 }
 
+// SchemaFormats handles the /schema-formats endpoint
+// @Summary      List supported schema formats
+// @Description  List the registered schema parsers, the file extensions they accept and the SQL dialects they understand
+// @Tags         Schema
+// @Produce      json
+// @Success      200  {object}  SchemaFormatsResponse  "Registered schema formats"
+// @Router       /schema-formats [get]
+func (h *PublicHandler) SchemaFormats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errors.RespondWithError(w, errors.NewBadRequestError("Method not allowed", "Only GET method is supported"))
+		return
+	}
+
+	errors.RespondWithJSON(w, http.StatusOK, SchemaFormatsResponse{
+		Formats:  h.registry.Formats(),
+		Dialects: parser.SupportedDialects,
+	})
+}
+
 // GenerateSQL handles the /generate-sql endpoint
 // @Summary      Generate SQL INSERT statements
 // @Description  Generate type-safe SQL INSERT statements from mapped data rows
@@ -192,10 +317,17 @@ func (h *PublicHandler) GenerateSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dialect, err := parser.ParseDialect(req.Dialect)
+	if err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid dialect", err.Error()))
+		return
+	}
+
 	logger.Info("Generating SQL", map[string]interface{}{
 		"table":    req.Table,
 		"rowCount": len(req.Rows),
 		"columns":  len(req.Mapping),
+		"dialect":  dialect,
 	})
 
 	// Validate data types if fields are provided
@@ -219,7 +351,7 @@ func (h *PublicHandler) GenerateSQL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate SQL
-	sql := generator.GenerateInsertSQL(req.Table, req.Mapping, req.Rows, req.Fields)
+	sql := generator.GenerateInsertSQLForDialect(dialect, req.Table, req.Mapping, req.Rows, req.Fields)
 
 	if sql == "" {
 		logger.Error("SQL generation returned empty result", nil)
@@ -272,6 +404,124 @@ func (h *PublicHandler) Validate(w http.ResponseWriter, r *http.Request) {
 	errors.RespondWithJSON(w, http.StatusOK, response)
 }
 
+// SuggestMapping handles the /suggest-mapping endpoint
+// @Summary      Suggest column mappings
+// @Description  Rank the fields of a table as targets for each data file column
+// @Description  Scores combine name similarity (case, separators, accents, synonyms), how well the sample values fit the field type, and NOT NULL/unique fit
+// @Description  Each field is suggested for at most one column; the result is deterministic
+// @Tags         Mapping
+// @Accept       json
+// @Produce      json
+// @Param        request  body      SuggestMappingRequest   true  "Headers, sample rows and the target table"
+// @Success      200      {object}  SuggestMappingResponse  "Ranked candidates per column and the suggested mapping"
+// @Failure      400      {object}  ErrorResponse           "Invalid request (no headers, no table fields, bad scores)"
+// @Router       /suggest-mapping [post]
+func (h *PublicHandler) SuggestMapping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.RespondWithError(w, errors.NewBadRequestError("Method not allowed", "Only POST method is supported"))
+		return
+	}
+
+	var req SuggestMappingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid JSON", err.Error()))
+		return
+	}
+
+	if len(req.Headers) == 0 {
+		errors.RespondWithError(w, errors.NewBadRequestError("Missing headers", "At least one header is required"))
+		return
+	}
+
+	if len(req.Table.Fields) == 0 {
+		errors.RespondWithError(w, errors.NewBadRequestError("Missing table fields", "The table must have at least one field"))
+		return
+	}
+
+	if req.MinScore < 0 || req.MinScore > 1 {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid minScore", "minScore must be between 0 and 1"))
+		return
+	}
+
+	suggestions := mapping.Suggest(req.Headers, req.SampleData, req.Table, mapping.Options{
+		MinScore:      req.MinScore,
+		MaxCandidates: req.MaxCandidates,
+	})
+
+	errors.RespondWithJSON(w, http.StatusOK, SuggestMappingResponse{
+		Suggestions: suggestions,
+		Mapping:     mapping.Mapping(suggestions),
+	})
+}
+
+// InferSchema handles the /infer-schema endpoint
+// @Summary      Infer a table from data
+// @Description  Infer column types (integer ranges, decimal precision, booleans, dates and their format, string lengths), nullability and a primary key candidate from data rows
+// @Description  Returns the table, usable with /suggest-mapping and /generate-sql, and a CREATE TABLE statement for the dialect
+// @Tags         Schema
+// @Accept       json
+// @Produce      json
+// @Param        request  body      InferSchemaRequest   true  "Headers, data rows, optional table name and dialect"
+// @Success      200      {object}  InferSchemaResponse  "Inferred table, CREATE TABLE statement and column details"
+// @Failure      400      {object}  ErrorResponse        "Invalid request (no headers, no rows, unknown dialect)"
+// @Router       /infer-schema [post]
+func (h *PublicHandler) InferSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errors.RespondWithError(w, errors.NewBadRequestError("Method not allowed", "Only POST method is supported"))
+		return
+	}
+
+	var req InferSchemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid JSON", err.Error()))
+		return
+	}
+
+	if len(req.Headers) == 0 {
+		errors.RespondWithError(w, errors.NewBadRequestError("Missing headers", "At least one header is required"))
+		return
+	}
+
+	if len(req.Rows) == 0 {
+		errors.RespondWithError(w, errors.NewBadRequestError("Missing data", "At least one data row is required"))
+		return
+	}
+
+	dialect, err := parser.ParseDialect(req.Dialect)
+	if err != nil {
+		errors.RespondWithError(w, errors.NewBadRequestError("Invalid dialect", err.Error()))
+		return
+	}
+	if dialect == parser.DialectUnknown {
+		dialect = parser.DialectMySQL
+	}
+
+	tableName := req.TableName
+	if tableName == "" {
+		tableName = "imported_data"
+	}
+
+	result := infer.Infer(req.Headers, req.Rows)
+	table := result.Table(tableName, dialect)
+
+	logger.Info("Inferred table from data", map[string]interface{}{
+		"table":    tableName,
+		"rowCount": result.Rows,
+		"columns":  len(result.Columns),
+		"dialect":  dialect,
+	})
+
+	errors.RespondWithJSON(w, http.StatusOK, InferSchemaResponse{
+		Table:           table,
+		Dialect:         dialect,
+		SQL:             generator.GenerateCreateTable(dialect, table),
+		Rows:            result.Rows,
+		Columns:         result.Columns,
+		Mapping:         result.Mapping(),
+		Transformations: result.Transformations(),
+	})
+}
+
 // Health handles the /health endpoint
 // @Summary      Health check
 // @Description  Check API server health, version, and database connection status
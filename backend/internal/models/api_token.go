@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes a personal access token can be granted
+const (
+	ScopeSchemaParse  = "schema:parse"  // parse and infer schemas
+	ScopeSQLGenerate  = "sql:generate"  // generate and validate SQL, suggest mappings
+	ScopeImportsRead  = "imports:read"  // read imports, their SQL and stats
+	ScopeImportsWrite = "imports:write" // create, re-run, execute and delete imports, upload files
+)
+
+// TokenScopes is a list of scopes stored as JSON
+type TokenScopes []string
+
+// Scan implements the sql.Scanner interface for TokenScopes
+func (s *TokenScopes) Scan(value interface{}) error {
+	if value == nil {
+		*s = TokenScopes{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// Value implements the driver.Valuer interface for TokenScopes
+func (s TokenScopes) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+// APIToken is a personal access token used instead of a JWT by scripts. The
+// token itself is only shown when it is created.
+type APIToken struct {
+	ID          uuid.UUID   `db:"id" json:"id"`
+	UserID      uuid.UUID   `db:"user_id" json:"userId"`
+	Name        string      `db:"name" json:"name"`
+	TokenHash   string      `db:"token_hash" json:"-"` // Never expose token hash
+	TokenPrefix string      `db:"token_prefix" json:"tokenPrefix"`
+	Scopes      TokenScopes `db:"scopes" json:"scopes"`
+	ExpiresAt   *time.Time  `db:"expires_at" json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time  `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time  `db:"revoked_at" json:"revokedAt,omitempty"`
+	CreatedAt   time.Time   `db:"created_at" json:"createdAt"`
+}
+
+// IsValid checks if the token is neither revoked nor expired
+func (t *APIToken) IsValid() bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt))
+}
+
+// CreateAPITokenRequest represents the request to create a personal access
+// token
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=schema:parse sql:generate imports:read imports:write"`
+	// ExpiresInDays is unset for tokens that never expire
+	ExpiresInDays int `json:"expiresInDays,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// CreateAPITokenResponse is a new token together with its secret value,
+// which cannot be retrieved again
+type CreateAPITokenResponse struct {
+	*APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions
+const (
+	AuditLogin       = "auth.login"
+	AuditLoginFailed = "auth.login_failed"
+	AuditLogout      = "auth.logout"
+
+	AuditAPITokenCreate = "api_token.create"
+	AuditAPITokenRevoke = "api_token.revoke"
+
+	AuditImportCreate    = "import.create"
+	AuditImportViewSQL   = "import.view_sql"
+	AuditImportDelete    = "import.delete"
+	AuditImportDeleteOld = "import.delete_old"
+
+	AuditSessionSchemaSet = "workflow_session.schema_upload"
+
+	AuditOrganizationDelete = "organization.delete"
+	AuditMemberJoin         = "organization.member_join"
+	AuditMemberRoleChange   = "organization.member_role_change"
+	AuditMemberRemove       = "organization.member_remove"
+	AuditInvitationCreate   = "organization.invitation_create"
+	AuditInvitationRevoke   = "organization.invitation_revoke"
+
+	AuditAccountExport         = "account.export"
+	AuditAccountExportDownload = "account.export_download"
+	AuditAccountDeletionSet    = "account.deletion_schedule"
+	AuditAccountDeletionCancel = "account.deletion_cancel"
+	AuditAccountDelete         = "account.delete"
+
+	AuditExport = "audit.export"
+)
+
+// Audit target types
+const (
+	AuditTargetUser            = "user"
+	AuditTargetAPIToken        = "api_token"
+	AuditTargetImport          = "import"
+	AuditTargetWorkflowSession = "workflow_session"
+	AuditTargetInvitation      = "organization_invitation"
+	AuditTargetOrganization    = "organization"
+)
+
+// AuditMetadata holds details of an audit event
+type AuditMetadata map[string]interface{}
+
+// Scan implements the sql.Scanner interface for AuditMetadata
+func (m *AuditMetadata) Scan(value interface{}) error {
+	if value == nil {
+		*m = AuditMetadata{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface for AuditMetadata
+func (m AuditMetadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return json.Marshal(map[string]interface{}{})
+	}
+	return json.Marshal(m)
+}
+
+// AuditEvent records who did what to which resource, from where. Events
+// are never changed or deleted.
+type AuditEvent struct {
+	ID             int64         `db:"id" json:"id"`
+	OccurredAt     time.Time     `db:"occurred_at" json:"occurredAt"`
+	ActorID        *uuid.UUID    `db:"actor_id" json:"actorId,omitempty"`
+	ActorEmail     *string       `db:"actor_email" json:"actorEmail,omitempty"` // as when the event happened
+	OrganizationID *uuid.UUID    `db:"organization_id" json:"organizationId,omitempty"`
+	Action         string        `db:"action" json:"action"`
+	TargetType     string        `db:"target_type" json:"targetType,omitempty"`
+	TargetID       string        `db:"target_id" json:"targetId,omitempty"`
+	IPAddress      string        `db:"ip_address" json:"ipAddress,omitempty"`
+	UserAgent      string        `db:"user_agent" json:"userAgent,omitempty"`
+	Metadata       AuditMetadata `db:"metadata" json:"metadata"`
+}
+
+// AuditFilter selects audit events of a workspace
+type AuditFilter struct {
+	Action     string     // exact action, or a prefix ending in "." such as "import."
+	ActorID    *uuid.UUID // events of one user
+	TargetType string
+	TargetID   string
+	From       *time.Time // occurred at or after
+	To         *time.Time // occurred before
+	Page       int        `validate:"omitempty,gte=1"`
+	PageSize   int        `validate:"omitempty,gte=1,lte=100"`
+}
+
+// GetAuditEventsResponse represents a page of audit events, newest first
+type GetAuditEventsResponse struct {
+	Events     []*AuditEvent `json:"events"`
+	Total      int64         `json:"total"`
+	Page       int           `json:"page"`
+	PageSize   int           `json:"pageSize"`
+	TotalPages int           `json:"totalPages"`
+}
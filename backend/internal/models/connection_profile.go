@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConnectionProfile is a saved database connection used for live schema
+// introspection. The connection string is stored encrypted.
+type ConnectionProfile struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	UserID         uuid.UUID  `db:"user_id" json:"userId"`
+	OrganizationID *uuid.UUID `db:"organization_id" json:"organizationId,omitempty"` // nil for personal profiles
+	Name           string     `db:"name" json:"name"`
+	Dialect        string     `db:"dialect" json:"dialect"`
+	EncryptedDSN   string     `db:"encrypted_dsn" json:"-"` // Never exposed
+	DisplayDSN     string     `db:"display_dsn" json:"displayDsn"`
+	DBSchema       *string    `db:"db_schema" json:"dbSchema,omitempty"`
+	LastUsedAt     *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// ConnectionProfileResponse is the response returned to clients
+type ConnectionProfileResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+	Name           string     `json:"name"`
+	Dialect        string     `json:"dialect"`
+	DisplayDSN     string     `json:"displayDsn"`
+	DBSchema       *string    `json:"dbSchema,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// ToResponse converts ConnectionProfile to ConnectionProfileResponse
+func (p *ConnectionProfile) ToResponse() *ConnectionProfileResponse {
+	return &ConnectionProfileResponse{
+		ID:             p.ID,
+		OrganizationID: p.OrganizationID,
+		Name:           p.Name,
+		Dialect:        p.Dialect,
+		DisplayDSN:     p.DisplayDSN,
+		DBSchema:       p.DBSchema,
+		LastUsedAt:     p.LastUsedAt,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+	}
+}
+
+// CreateConnectionProfileRequest represents the request to save a connection
+type CreateConnectionProfileRequest struct {
+	Name     string `json:"name" validate:"required,min=1,max=100"`
+	Dialect  string `json:"dialect" validate:"required,oneof=postgresql mysql sqlite"`
+	DSN      string `json:"dsn" validate:"required,max=2048"`
+	DBSchema string `json:"dbSchema,omitempty" validate:"omitempty,max=255"`
+	// Test connects before saving so typos are caught early
+	Test bool `json:"test,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailToken is a single-use token sent by email to verify an address or
+// reset a password
+type EmailToken struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	UserID    uuid.UUID  `db:"user_id" json:"userId"`
+	Purpose   string     `db:"purpose" json:"purpose"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expiresAt"`
+	UsedAt    *time.Time `db:"used_at" json:"usedAt,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// VerifyEmailRequest represents the request to confirm an email address
+// with the token from the verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPasswordRequest represents the request to email a password reset
+// link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request to choose a new password
+// with the token from the password reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"` // bcrypt max 72 bytes
+}
@@ -15,6 +15,10 @@ const (
 	ImportStatusSuccess ImportStatus = "success"
 	ImportStatusWarning ImportStatus = "warning"
 	ImportStatusFailed  ImportStatus = "failed"
+
+	// Statuses of imports executed against a saved connection
+	ImportStatusExecuted   ImportStatus = "executed"
+	ImportStatusRolledBack ImportStatus = "rolled_back"
 )
 
 // ImportMetadata contains additional information about the import
@@ -26,6 +30,109 @@ type ImportMetadata struct {
 	ValidationErrors   []string               `json:"validationErrors,omitempty"`   // error messages if any
 	ValidationWarnings []string               `json:"validationWarnings,omitempty"` // warning messages if any
 	Extra              map[string]interface{} `json:"extra,omitempty"`              // any additional data
+	Execution          *ImportExecution       `json:"execution,omitempty"`          // set for executed imports
+	RejectedRows       []RejectedRow          `json:"rejectedRows,omitempty"`       // rows left out of the generated SQL
+	Report             *ImportReport          `json:"report,omitempty"`             // set for imports produced by background jobs
+	Rollback           *ImportRollback        `json:"rollback,omitempty"`           // undo script, see /api/v1/imports/rollback
+	SourceHeaders      []string               `json:"sourceHeaders,omitempty"`      // headers of the data file, in file order
+	Fields             []ImportField          `json:"fields,omitempty"`             // target table definition, needed to re-run
+	Options            *ImportOptions         `json:"options,omitempty"`            // generation options, reused when re-run
+	PreviousImportID   *uuid.UUID             `json:"previousImportId,omitempty"`   // import this one re-ran
+	HeaderMatch        *HeaderMatchReport     `json:"headerMatch,omitempty"`        // headers compared with the previous import
+}
+
+// ImportOptions are the generation options of an import
+type ImportOptions struct {
+	KeyColumns []string `json:"keyColumns,omitempty"`
+	Upsert     bool     `json:"upsert,omitempty"`
+}
+
+// Scan implements the sql.Scanner interface for ImportOptions
+func (o *ImportOptions) Scan(value interface{}) error {
+	if value == nil {
+		*o = ImportOptions{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, o)
+}
+
+// Value implements the driver.Valuer interface for ImportOptions
+func (o ImportOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+// HeaderMatchReport compares the headers of a re-run's data file with those
+// of the import it re-runs
+type HeaderMatchReport struct {
+	Matched        []string          `json:"matched"`                  // headers found unchanged
+	Renamed        map[string]string `json:"renamed,omitempty"`        // previous -> new header, matched ignoring case, spaces and punctuation
+	Missing        []string          `json:"missing"`                  // previous headers the new file lacks
+	Added          []string          `json:"added"`                    // new headers, not mapped
+	UnmappedFields []string          `json:"unmappedFields,omitempty"` // mapped fields whose column is missing, left to the column default
+}
+
+// ImportRollback describes the undo script stored next to the generated SQL
+type ImportRollback struct {
+	Available     bool     `json:"available"`
+	Reason        string   `json:"reason,omitempty"` // why no script could be generated
+	KeyColumns    []string `json:"keyColumns,omitempty"`
+	RowsDeleted   int      `json:"rowsDeleted"`             // inserted rows deleted by key
+	RowsRestored  int      `json:"rowsRestored"`            // overwritten rows set back to their previous values
+	RowsUnmatched int      `json:"rowsUnmatched,omitempty"` // rows without a key value, not undone
+	Checksum      string   `json:"checksum,omitempty"`      // SHA-256 of the script, hex encoded
+	Size          int64    `json:"size,omitempty"`
+}
+
+// RejectedRow is a source row that failed validation
+type RejectedRow struct {
+	Row    int      `json:"row"` // 1-based source row
+	Errors []string `json:"errors"`
+}
+
+// ImportReport summarizes the background job that produced an import
+type ImportReport struct {
+	JobID        uuid.UUID `json:"jobId"`
+	RowsTotal    int       `json:"rowsTotal"`
+	RowsAccepted int       `json:"rowsAccepted"`
+	RowsRejected int       `json:"rowsRejected"`
+	StartedAt    time.Time `json:"startedAt"`
+	DurationMs   int64     `json:"durationMs"`
+}
+
+// ImportExecution records running the generated SQL against a saved connection
+type ImportExecution struct {
+	ConnectionProfileID uuid.UUID              `json:"connectionProfileId"`
+	ConnectionName      string                 `json:"connectionName"`
+	Mode                string                 `json:"mode"` // transaction or batch
+	BatchSize           int                    `json:"batchSize"`
+	AllowPartial        bool                   `json:"allowPartial"`
+	RowsAffected        int64                  `json:"rowsAffected"`
+	Batches             []ImportExecutionBatch `json:"batches"`
+	Errors              []ImportExecutionError `json:"errors,omitempty"`
+	ErrorsTruncated     bool                   `json:"errorsTruncated,omitempty"`
+	StartedAt           time.Time              `json:"startedAt"`
+	DurationMs          int64                  `json:"durationMs"`
+}
+
+// ImportExecutionBatch is the outcome of one INSERT batch
+type ImportExecutionBatch struct {
+	Index        int    `json:"index"`
+	FirstRow     int    `json:"firstRow"` // 1-based source row
+	RowCount     int    `json:"rowCount"`
+	RowsAffected int64  `json:"rowsAffected"`
+	Committed    bool   `json:"committed"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ImportExecutionError is a database error mapped back to a source row
+type ImportExecutionError struct {
+	Row     int    `json:"row,omitempty"` // 1-based source row, 0 for batch-level errors
+	Batch   int    `json:"batch"`
+	Message string `json:"message"`
 }
 
 // Scan implements the sql.Scanner interface for ImportMetadata
@@ -48,46 +155,58 @@ func (m ImportMetadata) Value() (driver.Value, error) {
 
 // Import represents an import record in the database
 type Import struct {
-	ID           uuid.UUID      `db:"id" json:"id"`
-	UserID       uuid.UUID      `db:"user_id" json:"userId"`
-	TableName    string         `db:"table_name" json:"tableName"`
-	RowCount     int            `db:"row_count" json:"rowCount"`
-	Status       ImportStatus   `db:"status" json:"status"`
-	GeneratedSQL *string        `db:"generated_sql" json:"-"` // Compressed, not exposed in JSON
-	ErrorCount   int            `db:"error_count" json:"errorCount"`
-	WarningCount int            `db:"warning_count" json:"warningCount"`
-	Metadata     ImportMetadata `db:"metadata" json:"metadata"`
-	CreatedAt    time.Time      `db:"created_at" json:"createdAt"`
-	UpdatedAt    time.Time      `db:"updated_at" json:"updatedAt"`
+	ID             uuid.UUID      `db:"id" json:"id"`
+	UserID         uuid.UUID      `db:"user_id" json:"userId"`
+	OrganizationID *uuid.UUID     `db:"organization_id" json:"organizationId,omitempty"` // nil for personal imports
+	TableName      string         `db:"table_name" json:"tableName"`
+	RowCount       int            `db:"row_count" json:"rowCount"`
+	Status         ImportStatus   `db:"status" json:"status"`
+	GeneratedSQL   *string        `db:"generated_sql" json:"-"` // Compressed, legacy (see SQLStorageKey)
+	SQLStorageKey  *string        `db:"sql_storage_key" json:"-"`
+	SQLChecksum    *string        `db:"sql_checksum" json:"sqlChecksum,omitempty"` // SHA-256, hex encoded
+	SQLSize        *int64         `db:"sql_size" json:"sqlSize,omitempty"`
+	RollbackSQL    *string        `db:"rollback_sql" json:"-"` // Compressed, without object storage
+	RollbackKey    *string        `db:"rollback_storage_key" json:"-"`
+	ErrorCount     int            `db:"error_count" json:"errorCount"`
+	WarningCount   int            `db:"warning_count" json:"warningCount"`
+	Metadata       ImportMetadata `db:"metadata" json:"metadata"`
+	CreatedAt      time.Time      `db:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updatedAt"`
 }
 
 // ImportResponse is the response returned to clients
 type ImportResponse struct {
-	ID           uuid.UUID      `json:"id"`
-	UserID       uuid.UUID      `json:"userId"`
-	TableName    string         `json:"tableName"`
-	RowCount     int            `json:"rowCount"`
-	Status       ImportStatus   `json:"status"`
-	ErrorCount   int            `json:"errorCount"`
-	WarningCount int            `json:"warningCount"`
-	Metadata     ImportMetadata `json:"metadata"`
-	CreatedAt    time.Time      `json:"createdAt"`
-	UpdatedAt    time.Time      `json:"updatedAt"`
+	ID             uuid.UUID      `json:"id"`
+	UserID         uuid.UUID      `json:"userId"`
+	OrganizationID *uuid.UUID     `json:"organizationId,omitempty"`
+	TableName      string         `json:"tableName"`
+	RowCount       int            `json:"rowCount"`
+	Status         ImportStatus   `json:"status"`
+	ErrorCount     int            `json:"errorCount"`
+	WarningCount   int            `json:"warningCount"`
+	SQLChecksum    *string        `json:"sqlChecksum,omitempty"`
+	SQLSize        *int64         `json:"sqlSize,omitempty"`
+	Metadata       ImportMetadata `json:"metadata"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
 }
 
 // ToResponse converts Import to ImportResponse
 func (i *Import) ToResponse() *ImportResponse {
 	return &ImportResponse{
-		ID:           i.ID,
-		UserID:       i.UserID,
-		TableName:    i.TableName,
-		RowCount:     i.RowCount,
-		Status:       i.Status,
-		ErrorCount:   i.ErrorCount,
-		WarningCount: i.WarningCount,
-		Metadata:     i.Metadata,
-		CreatedAt:    i.CreatedAt,
-		UpdatedAt:    i.UpdatedAt,
+		ID:             i.ID,
+		UserID:         i.UserID,
+		OrganizationID: i.OrganizationID,
+		TableName:      i.TableName,
+		RowCount:       i.RowCount,
+		Status:         i.Status,
+		ErrorCount:     i.ErrorCount,
+		WarningCount:   i.WarningCount,
+		SQLChecksum:    i.SQLChecksum,
+		SQLSize:        i.SQLSize,
+		Metadata:       i.Metadata,
+		CreatedAt:      i.CreatedAt,
+		UpdatedAt:      i.UpdatedAt,
 	}
 }
 
@@ -106,6 +225,46 @@ type CreateImportRequest struct {
 	ErrorCount   int            `json:"errorCount" validate:"gte=0"`
 	WarningCount int            `json:"warningCount" validate:"gte=0"`
 	Metadata     ImportMetadata `json:"metadata"`
+	KeyColumns   []string       `json:"keyColumns" validate:"omitempty,dive,min=1"` // primary or unique key of the rows, for the rollback script
+}
+
+// ImportField describes a target column of an executed import
+type ImportField struct {
+	Name     string `json:"name" validate:"required"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	Key      bool   `json:"key"` // part of the primary or a unique key
+}
+
+// ExecuteImportRequest represents the request to run an import against a
+// saved connection profile
+type ExecuteImportRequest struct {
+	ProfileID    string            `json:"profileId" validate:"required,uuid"`
+	TableName    string            `json:"tableName" validate:"required,min=1,max=255"`
+	Mapping      map[string]string `json:"mapping" validate:"required,min=1"`
+	Rows         [][]interface{}   `json:"rows" validate:"required,min=1"`
+	Fields       []ImportField     `json:"fields" validate:"required,min=1,dive"`
+	Mode         string            `json:"mode" validate:"omitempty,oneof=transaction batch"` // default transaction
+	BatchSize    int               `json:"batchSize" validate:"omitempty,gte=1,lte=10000"`    // default 500
+	MaxErrors    int               `json:"maxErrors" validate:"omitempty,gte=1,lte=1000"`     // default 10
+	AllowPartial bool              `json:"allowPartial"`
+	Upsert       bool              `json:"upsert"` // update rows whose key already exists; needs a key field
+	Metadata     ImportMetadata    `json:"metadata"`
+}
+
+// RerunImportRequest re-runs a past import with a new data file, reusing its
+// table definition, mapping, transformations and options
+type RerunImportRequest struct {
+	UploadID string            `json:"uploadId" validate:"required,uuid"` // completed upload with purpose "data"
+	Mapping  map[string]string `json:"mapping"`                           // header -> field overrides; an empty field unmaps the header
+	Execute  bool              `json:"execute"`                           // run against the connection of an executed import
+	DryRun   bool              `json:"dryRun"`                            // only compare the headers
+}
+
+// RerunImportResponse is the outcome of a re-run; Import is nil for dry runs
+type RerunImportResponse struct {
+	HeaderMatch *HeaderMatchReport `json:"headerMatch"`
+	Import      *ImportResponse    `json:"import,omitempty"`
 }
 
 // GetImportsRequest represents query parameters for listing imports
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobKind identifies the work a background job performs
+type JobKind string
+
+const (
+	// JobKindGenerateSQL validates rows and generates INSERT SQL
+	JobKindGenerateSQL JobKind = "generate_sql"
+	// JobKindExecute runs an import against a saved connection
+	JobKindExecute JobKind = "execute"
+	// JobKindAccountExport bundles the data of the user into a zip archive
+	JobKindAccountExport JobKind = "account_export"
+)
+
+// JobStatus represents the lifecycle state of a job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCanceled  JobStatus = "canceled"
+)
+
+// Finished reports whether the status is terminal
+func (s JobStatus) Finished() bool {
+	return s == JobStatusSucceeded || s == JobStatusFailed || s == JobStatusCanceled
+}
+
+// Job phases reported while a job runs
+const (
+	JobPhaseValidating = "validating"
+	JobPhaseGenerating = "generating"
+	JobPhaseExecuting  = "executing"
+	JobPhaseSaving     = "saving"
+	JobPhaseExporting  = "exporting"
+)
+
+// Job represents a queued or running background import job
+type Job struct {
+	ID              uuid.UUID       `db:"id" json:"id"`
+	UserID          uuid.UUID       `db:"user_id" json:"userId"`
+	OrganizationID  *uuid.UUID      `db:"organization_id" json:"organizationId,omitempty"` // organization the import is created in
+	Kind            JobKind         `db:"kind" json:"kind"`
+	Status          JobStatus       `db:"status" json:"status"`
+	Payload         json.RawMessage `db:"payload" json:"-"` // Request body, not exposed
+	Phase           *string         `db:"phase" json:"phase,omitempty"`
+	RowsTotal       int             `db:"rows_total" json:"rowsTotal"`
+	RowsProcessed   int             `db:"rows_processed" json:"rowsProcessed"`
+	Error           *string         `db:"error" json:"error,omitempty"`
+	ImportID        *uuid.UUID      `db:"import_id" json:"importId,omitempty"`
+	ResultKey       *string         `db:"result_storage_key" json:"-"` // file produced by the job
+	ResultSize      *int64          `db:"result_size" json:"resultSize,omitempty"`
+	CancelRequested bool            `db:"cancel_requested" json:"cancelRequested"`
+	Attempts        int             `db:"attempts" json:"attempts"`
+	LockedBy        *string         `db:"locked_by" json:"-"`
+	LockedAt        *time.Time      `db:"locked_at" json:"-"`
+	StartedAt       *time.Time      `db:"started_at" json:"startedAt,omitempty"`
+	FinishedAt      *time.Time      `db:"finished_at" json:"finishedAt,omitempty"`
+	CreatedAt       time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// JobResponse is the response returned to clients
+type JobResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	OrganizationID  *uuid.UUID `json:"organizationId,omitempty"`
+	Kind            JobKind    `json:"kind"`
+	Status          JobStatus  `json:"status"`
+	Phase           *string    `json:"phase,omitempty"`
+	RowsTotal       int        `json:"rowsTotal"`
+	RowsProcessed   int        `json:"rowsProcessed"`
+	Progress        float64    `json:"progress"` // 0-100
+	Error           *string    `json:"error,omitempty"`
+	ImportID        *uuid.UUID `json:"importId,omitempty"`
+	ResultSize      *int64     `json:"resultSize,omitempty"` // size of the file to download, while it is kept
+	CancelRequested bool       `json:"cancelRequested"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// ToResponse converts Job to JobResponse
+func (j *Job) ToResponse() *JobResponse {
+	resp := &JobResponse{
+		ID:              j.ID,
+		OrganizationID:  j.OrganizationID,
+		Kind:            j.Kind,
+		Status:          j.Status,
+		Phase:           j.Phase,
+		RowsTotal:       j.RowsTotal,
+		RowsProcessed:   j.RowsProcessed,
+		Error:           j.Error,
+		ImportID:        j.ImportID,
+		CancelRequested: j.CancelRequested,
+		StartedAt:       j.StartedAt,
+		FinishedAt:      j.FinishedAt,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+	}
+	if j.ResultKey != nil {
+		resp.ResultSize = j.ResultSize
+	}
+	if j.Status == JobStatusSucceeded {
+		resp.Progress = 100
+	} else if j.RowsTotal > 0 {
+		resp.Progress = float64(j.RowsProcessed) * 100 / float64(j.RowsTotal)
+	}
+	return resp
+}
+
+// SubmitJobRequest represents the request to queue a background job. The
+// payload is a GenerateSQLJobPayload or an ExecuteImportRequest depending
+// on the kind.
+type SubmitJobRequest struct {
+	Kind    JobKind         `json:"kind" validate:"required,oneof=generate_sql execute"`
+	Payload json.RawMessage `json:"payload" validate:"required"`
+}
+
+// GenerateSQLJobPayload is the payload of a generate_sql job. Rows that fail
+// validation are left out of the SQL and reported on the import.
+type GenerateSQLJobPayload struct {
+	TableName string            `json:"tableName" validate:"required,min=1,max=255"`
+	Mapping   map[string]string `json:"mapping" validate:"required,min=1"`
+	Rows      [][]interface{}   `json:"rows" validate:"required,min=1"`
+	Fields    []ImportField     `json:"fields" validate:"required,min=1,dive"`
+	Dialect   string            `json:"dialect,omitempty"` // default mysql
+	Metadata  ImportMetadata    `json:"metadata"`
+}
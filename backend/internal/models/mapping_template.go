@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MappingTemplateFormat identifies exported mapping template documents
+const (
+	MappingTemplateFormat  = "db-importer/mapping-template"
+	MappingTemplateVersion = 1
+)
+
+// TemplateFields is the target table definition of a template, stored as JSONB
+type TemplateFields []ImportField
+
+// Scan implements the sql.Scanner interface for TemplateFields
+func (f *TemplateFields) Scan(value interface{}) error {
+	if value == nil {
+		*f = TemplateFields{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, f)
+}
+
+// Value implements the driver.Valuer interface for TemplateFields
+func (f TemplateFields) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return json.Marshal([]ImportField{})
+	}
+	return json.Marshal(f)
+}
+
+// MappingTemplate is a named column mapping that can be applied to any
+// workflow session whose data file has the same headers
+type MappingTemplate struct {
+	ID                   uuid.UUID            `db:"id" json:"id"`
+	UserID               uuid.UUID            `db:"user_id" json:"userId"`
+	OrganizationID       *uuid.UUID           `db:"organization_id" json:"organizationId,omitempty"` // nil for personal templates
+	Name                 string               `db:"name" json:"name"`
+	Description          *string              `db:"description" json:"description,omitempty"`
+	TableName            string               `db:"table_name" json:"tableName"`
+	TableFields          TemplateFields       `db:"table_fields" json:"tableFields"`
+	TableSignature       string               `db:"table_signature" json:"tableSignature"`
+	SourceHeaders        DataHeaders          `db:"source_headers" json:"sourceHeaders"`
+	HeaderSignature      string               `db:"header_signature" json:"headerSignature"`
+	ColumnMapping        ColumnMapping        `db:"column_mapping" json:"columnMapping"`
+	FieldTransformations FieldTransformations `db:"field_transformations" json:"fieldTransformations"`
+	Options              ImportOptions        `db:"options" json:"options"`
+	Shared               bool                 `db:"shared" json:"shared"`
+	CreatedAt            time.Time            `db:"created_at" json:"createdAt"`
+	UpdatedAt            time.Time            `db:"updated_at" json:"updatedAt"`
+}
+
+// MappingTemplateResponse is the response returned to clients
+type MappingTemplateResponse struct {
+	MappingTemplate
+	Owned bool `json:"owned"` // false for templates shared from other workspaces
+}
+
+// ToResponse converts MappingTemplate to MappingTemplateResponse for the
+// given workspace
+func (t *MappingTemplate) ToResponse(ws Workspace) *MappingTemplateResponse {
+	return &MappingTemplateResponse{
+		MappingTemplate: *t,
+		Owned:           ws.Owns(t.UserID, t.OrganizationID),
+	}
+}
+
+// MappingTemplateSuggestion is a template whose headers match a data file
+type MappingTemplateSuggestion struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	TableName  string    `json:"tableName"`
+	TableMatch bool      `json:"tableMatch"` // made for the selected table
+	Owned      bool      `json:"owned"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// MappingTemplateRequest creates or replaces a mapping template
+type MappingTemplateRequest struct {
+	Name                 string            `json:"name" validate:"required,min=1,max=100"`
+	Description          string            `json:"description,omitempty" validate:"max=1000"`
+	TableName            string            `json:"tableName" validate:"required,min=1,max=255"`
+	TableFields          []ImportField     `json:"tableFields" validate:"required,min=1,dive"`
+	SourceHeaders        []string          `json:"sourceHeaders" validate:"required,min=1"`
+	ColumnMapping        map[string]string `json:"columnMapping" validate:"required,min=1"` // source header -> field
+	FieldTransformations map[string]string `json:"fieldTransformations,omitempty"`          // field -> transformation
+	Options              ImportOptions     `json:"options"`
+	Shared               bool              `json:"shared,omitempty"`
+}
+
+// MappingTemplateDocument is the JSON file a template is exported to and
+// imported from, e.g. to keep templates in git. Signatures are recomputed
+// on import.
+type MappingTemplateDocument struct {
+	Format  string `json:"format" validate:"required"`
+	Version int    `json:"version" validate:"required,gte=1"`
+	MappingTemplateRequest
+}
+
+// ApplyMappingTemplateResult tells what applying a template to the session
+// carried over
+type ApplyMappingTemplateResult struct {
+	Session        *WorkflowSessionResponse `json:"session"`
+	Applied        map[string]string        `json:"applied"`                  // header -> field set on the session
+	MissingHeaders []string                 `json:"missingHeaders,omitempty"` // template headers the data file lacks
+	UnknownFields  []string                 `json:"unknownFields,omitempty"`  // template fields the selected table lacks
+}
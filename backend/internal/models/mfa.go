@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTOTP is a user's TOTP authenticator; it protects sign-ins once enabled
+type UserTOTP struct {
+	UserID          uuid.UUID  `db:"user_id" json:"userId"`
+	SecretEncrypted string     `db:"secret_encrypted" json:"-"` // Never expose the secret
+	EnabledAt       *time.Time `db:"enabled_at" json:"enabledAt,omitempty"`
+	LastUsedStep    int64      `db:"last_used_step" json:"-"`
+	CreatedAt       time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// Enabled reports whether sign-ins require a code
+func (t *UserTOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// MFAChallenge is the pending second step of a sign-in
+type MFAChallenge struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	UserID     uuid.UUID  `db:"user_id" json:"userId"`
+	RememberMe bool       `db:"remember_me" json:"rememberMe"`
+	Attempts   int        `db:"attempts" json:"attempts"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expiresAt"`
+	UsedAt     *time.Time `db:"used_at" json:"usedAt,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// MFAStatusResponse describes the two-factor authentication of a user
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+	// RequiredBy names the user's organizations that require it
+	RequiredBy []string `json:"requiredBy"`
+}
+
+// TOTPSetupResponse is a new authenticator secret to confirm with a code.
+// Authenticator apps import the otpauth URI, usually shown as a QR code.
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+// MFACodeRequest carries a code from the authenticator app or, where
+// accepted, a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+// RecoveryCodesResponse lists new recovery codes, shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFALoginRequest represents the second step of a sign-in with the token
+// returned by the first step
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required,max=20"` // authenticator or recovery code
+}
+
+// UpdateMFARequirementRequest represents the request to require two-factor
+// authentication from an organization's members, or to stop requiring it
+type UpdateMFARequirementRequest struct {
+	Required bool `json:"required"`
+}
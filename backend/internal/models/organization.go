@@ -0,0 +1,163 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationRole is the role of a member within an organization
+type OrganizationRole string
+
+// Roles in decreasing order of privilege
+const (
+	RoleOwner  OrganizationRole = "owner"  // everything, including deleting the organization
+	RoleAdmin  OrganizationRole = "admin"  // manage members and invitations, bulk cleanup
+	RoleEditor OrganizationRole = "editor" // create, change and delete shared resources
+	RoleViewer OrganizationRole = "viewer" // read shared resources
+)
+
+// rank orders roles by privilege; unknown roles rank lowest
+func (r OrganizationRole) rank() int {
+	switch r {
+	case RoleOwner:
+		return 4
+	case RoleAdmin:
+		return 3
+	case RoleEditor:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Valid reports whether r is a known role
+func (r OrganizationRole) Valid() bool {
+	return r.rank() > 0
+}
+
+// Allows reports whether r grants at least the privileges of minRole
+func (r OrganizationRole) Allows(minRole OrganizationRole) bool {
+	return r.Valid() && r.rank() >= minRole.rank()
+}
+
+// Workspace is the set of resources a request acts on: the user's personal
+// resources, or those of an organization the user belongs to
+type Workspace struct {
+	UserID         uuid.UUID
+	OrganizationID *uuid.UUID       // nil for the personal workspace
+	Role           OrganizationRole // the user's role in the organization
+}
+
+// Personal reports whether w is the user's personal workspace
+func (w Workspace) Personal() bool {
+	return w.OrganizationID == nil
+}
+
+// Owns reports whether a resource created by userID and owned by orgID, nil
+// for personal resources, belongs to the workspace
+func (w Workspace) Owns(userID uuid.UUID, orgID *uuid.UUID) bool {
+	if w.OrganizationID == nil {
+		return orgID == nil && userID == w.UserID
+	}
+	return orgID != nil && *orgID == *w.OrganizationID
+}
+
+// Organization is a shared workspace for a team
+type Organization struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Plan       string     `db:"plan" json:"plan"`              // set by operators, selects rate limits
+	RequireMFA bool       `db:"require_mfa" json:"requireMfa"` // members need two-factor authentication
+	CreatedBy  *uuid.UUID `db:"created_by" json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// OrganizationMembership is an organization as seen by one of its members,
+// listed by /auth/me for the organization switcher
+type OrganizationMembership struct {
+	ID         uuid.UUID        `db:"id" json:"id"`
+	Name       string           `db:"name" json:"name"`
+	Role       OrganizationRole `db:"role" json:"role"`
+	Plan       string           `db:"plan" json:"plan"`
+	RequireMFA bool             `db:"require_mfa" json:"requireMfa"`
+}
+
+// OrganizationMember is a user belonging to an organization
+type OrganizationMember struct {
+	UserID     uuid.UUID        `db:"user_id" json:"userId"`
+	Email      string           `db:"email" json:"email"`
+	FirstName  *string          `db:"first_name" json:"firstName,omitempty"`
+	LastName   *string          `db:"last_name" json:"lastName,omitempty"`
+	Role       OrganizationRole `db:"role" json:"role"`
+	MFAEnabled bool             `db:"mfa_enabled" json:"mfaEnabled"`
+	CreatedAt  time.Time        `db:"created_at" json:"joinedAt"`
+}
+
+// MemberAccess is what decides whether a member may act in an organization
+type MemberAccess struct {
+	Role       OrganizationRole `db:"role"`
+	RequireMFA bool             `db:"require_mfa"`
+	MFAEnabled bool             `db:"mfa_enabled"`
+}
+
+// OrganizationInvitation invites an email address to join an organization
+type OrganizationInvitation struct {
+	ID             uuid.UUID        `db:"id" json:"id"`
+	OrganizationID uuid.UUID        `db:"organization_id" json:"organizationId"`
+	Email          string           `db:"email" json:"email"`
+	Role           OrganizationRole `db:"role" json:"role"`
+	TokenHash      string           `db:"token_hash" json:"-"` // Never expose token hash
+	InvitedBy      *uuid.UUID       `db:"invited_by" json:"invitedBy,omitempty"`
+	ExpiresAt      time.Time        `db:"expires_at" json:"expiresAt"`
+	AcceptedAt     *time.Time       `db:"accepted_at" json:"acceptedAt,omitempty"`
+	CreatedAt      time.Time        `db:"created_at" json:"createdAt"`
+}
+
+// IsPending checks if the invitation can still be accepted
+func (i *OrganizationInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// CreateOrganizationRequest represents the request to create an
+// organization; the creator becomes its owner
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// CreateInvitationRequest represents the request to invite someone to an
+// organization. Ownership is granted by changing a member's role.
+type CreateInvitationRequest struct {
+	Email string           `json:"email" validate:"required,email"`
+	Role  OrganizationRole `json:"role" validate:"required,oneof=admin editor viewer"`
+}
+
+// CreateInvitationResponse is a new invitation together with its token,
+// which cannot be retrieved again
+type CreateInvitationResponse struct {
+	*OrganizationInvitation
+	Token string `json:"token"`
+}
+
+// AcceptInvitationRequest represents the request to join an organization
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UpdateMemberRoleRequest represents the request to change a member's role
+type UpdateMemberRoleRequest struct {
+	Role OrganizationRole `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+// MeResponse is the current user together with the organizations they can
+// switch to
+type MeResponse struct {
+	*UserResponse
+	Organizations []OrganizationMembership `json:"organizations"`
+	// ActiveOrganizationID is the organization selected by the request's
+	// X-Organization-ID header, if any
+	ActiveOrganizationID *uuid.UUID `json:"activeOrganizationId,omitempty"`
+}
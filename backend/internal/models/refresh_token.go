@@ -15,9 +15,45 @@ type RefreshToken struct {
 	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
 	Revoked   bool       `db:"revoked" json:"revoked"`
 	RevokedAt *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+
+	// The device session the token belongs to
+	UserAgent  string     `db:"user_agent" json:"userAgent"`
+	IPAddress  string     `db:"ip_address" json:"ipAddress"`
+	SignedInAt *time.Time `db:"signed_in_at" json:"signedInAt,omitempty"` // kept when the token is rotated
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
 }
 
 // IsValid checks if the refresh token is valid (not expired and not revoked)
 func (rt *RefreshToken) IsValid() bool {
 	return !rt.Revoked && time.Now().Before(rt.ExpiresAt)
 }
+
+// ToSession converts the token to the session it represents; current marks
+// the session of the request
+func (rt *RefreshToken) ToSession(current bool) *Session {
+	return &Session{
+		ID:         rt.ID,
+		UserAgent:  rt.UserAgent,
+		IPAddress:  rt.IPAddress,
+		SignedInAt: rt.SignedInAt,
+		LastUsedAt: rt.LastUsedAt,
+		ExpiresAt:  rt.ExpiresAt,
+		Current:    current,
+	}
+}
+
+// Session is a signed-in device, listed so users can revoke it
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserAgent  string     `json:"userAgent"`
+	IPAddress  string     `json:"ipAddress"`
+	SignedInAt *time.Time `json:"signedInAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	Current    bool       `json:"current"` // the session making the request
+}
+
+// RevokeSessionsResponse reports how many sessions were revoked
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
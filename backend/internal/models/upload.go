@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadStatus represents the state of a chunked upload
+type UploadStatus string
+
+const (
+	UploadStatusUploading UploadStatus = "uploading"
+	UploadStatusCompleted UploadStatus = "completed"
+	UploadStatusFailed    UploadStatus = "failed"
+)
+
+// Upload purposes: what the file will be handed to once completed
+const (
+	UploadPurposeSchema = "schema"
+	UploadPurposeData   = "data"
+)
+
+// UploadPart is a stored chunk of an upload
+type UploadPart struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// UploadParts is a slice of UploadPart stored as JSONB
+type UploadParts []UploadPart
+
+// Scan implements the sql.Scanner interface for UploadParts
+func (p *UploadParts) Scan(value interface{}) error {
+	if value == nil {
+		*p = UploadParts{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Value implements the driver.Valuer interface for UploadParts
+func (p UploadParts) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(p)
+}
+
+// Upload represents a resumable upload session
+type Upload struct {
+	ID            uuid.UUID    `db:"id" json:"id"`
+	UserID        uuid.UUID    `db:"user_id" json:"userId"`
+	FileName      string       `db:"file_name" json:"fileName"`
+	Purpose       string       `db:"purpose" json:"purpose"`
+	Status        UploadStatus `db:"status" json:"status"`
+	TotalSize     *int64       `db:"total_size" json:"totalSize,omitempty"`
+	ReceivedBytes int64        `db:"received_bytes" json:"receivedBytes"`
+	Parts         UploadParts  `db:"parts" json:"-"`
+	Checksum      *string      `db:"checksum" json:"checksum,omitempty"`
+	StorageKey    *string      `db:"storage_key" json:"-"`
+	ExpiresAt     time.Time    `db:"expires_at" json:"expiresAt"`
+	CompletedAt   *time.Time   `db:"completed_at" json:"completedAt,omitempty"`
+	CreatedAt     time.Time    `db:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time    `db:"updated_at" json:"updatedAt"`
+}
+
+// UploadResponse is the response returned to clients
+type UploadResponse struct {
+	ID            uuid.UUID    `json:"id"`
+	FileName      string       `json:"fileName"`
+	Purpose       string       `json:"purpose"`
+	Status        UploadStatus `json:"status"`
+	TotalSize     *int64       `json:"totalSize,omitempty"`
+	ReceivedBytes int64        `json:"receivedBytes"` // offset to send the next chunk from
+	MaxChunkSize  int64        `json:"maxChunkSize"`
+	Checksum      *string      `json:"checksum,omitempty"`
+	ExpiresAt     time.Time    `json:"expiresAt"`
+	CompletedAt   *time.Time   `json:"completedAt,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+}
+
+// ToResponse converts Upload to UploadResponse
+func (u *Upload) ToResponse(maxChunkSize int64) *UploadResponse {
+	return &UploadResponse{
+		ID:            u.ID,
+		FileName:      u.FileName,
+		Purpose:       u.Purpose,
+		Status:        u.Status,
+		TotalSize:     u.TotalSize,
+		ReceivedBytes: u.ReceivedBytes,
+		MaxChunkSize:  maxChunkSize,
+		Checksum:      u.Checksum,
+		ExpiresAt:     u.ExpiresAt,
+		CompletedAt:   u.CompletedAt,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// CreateUploadRequest represents the request to start a chunked upload
+type CreateUploadRequest struct {
+	FileName string `json:"fileName" validate:"required,min=1,max=255"`
+	Purpose  string `json:"purpose" validate:"required,oneof=schema data"`
+	// TotalSize is the file size in bytes, if known up front
+	TotalSize int64 `json:"totalSize,omitempty" validate:"gte=0"`
+}
+
+// CompleteUploadRequest represents the request to finalise an upload
+type CompleteUploadRequest struct {
+	// Checksum is the SHA-256 of the whole file, hex encoded, optionally
+	// prefixed with "sha256:"
+	Checksum string `json:"checksum" validate:"required"`
+}
+
+// SaveSchemaFromUploadRequest represents the request to fill step 1 from a
+// completed chunked upload
+type SaveSchemaFromUploadRequest struct {
+	UploadID string `json:"uploadId" validate:"required,uuid"`
+	Format   string `json:"format,omitempty"`
+	Dialect  string `json:"dialect,omitempty" validate:"omitempty,oneof=mysql postgresql sqlserver sqlite"`
+}
+
+// SaveDataFromUploadRequest represents the request to fill step 3 from a
+// completed chunked CSV upload
+type SaveDataFromUploadRequest struct {
+	UploadID string `json:"uploadId" validate:"required,uuid"`
+}
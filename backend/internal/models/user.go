@@ -18,6 +18,9 @@ type User struct {
 	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
 	UpdatedAt     time.Time  `db:"updated_at" json:"updatedAt"`
 	LastLoginAt   *time.Time `db:"last_login_at" json:"lastLoginAt,omitempty"`
+	// DeletionScheduledAt is when the account will be deleted, if the user
+	// asked for it
+	DeletionScheduledAt *time.Time `db:"deletion_scheduled_at" json:"deletionScheduledAt,omitempty"`
 }
 
 // UserResponse is the sanitized user response (no sensitive data)
@@ -30,6 +33,8 @@ type UserResponse struct {
 	EmailVerified bool       `json:"emailVerified"`
 	CreatedAt     time.Time  `json:"createdAt"`
 	LastLoginAt   *time.Time `json:"lastLoginAt,omitempty"`
+
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
 }
 
 // ToResponse converts User to UserResponse
@@ -43,6 +48,8 @@ func (u *User) ToResponse() *UserResponse {
 		EmailVerified: u.EmailVerified,
 		CreatedAt:     u.CreatedAt,
 		LastLoginAt:   u.LastLoginAt,
+
+		DeletionScheduledAt: u.DeletionScheduledAt,
 	}
 }
 
@@ -61,11 +68,15 @@ type LoginRequest struct {
 	RememberMe bool   `json:"rememberMe"`
 }
 
-// LoginResponse represents the response after successful login
+// LoginResponse represents the response after successful login. Users
+// with two-factor authentication get only MFAToken, to complete the sign-in
+// with a code.
 type LoginResponse struct {
 	AccessToken  string        `json:"accessToken"`
 	RefreshToken string        `json:"refreshToken"`
 	User         *UserResponse `json:"user"`
+	MFARequired  bool          `json:"mfaRequired,omitempty"`
+	MFAToken     string        `json:"mfaToken,omitempty"`
 }
 
 // RefreshTokenRequest represents the request to refresh an access token
@@ -78,3 +89,16 @@ type RefreshTokenResponse struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
 }
+
+// DeleteAccountRequest represents the request to schedule the deletion of
+// the user's account. Accounts with a password must confirm it; accounts
+// that only sign in with single sign-on confirm their email address.
+type DeleteAccountRequest struct {
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// AccountDeletionResponse tells when a scheduled account deletion happens
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletionScheduledAt"`
+}
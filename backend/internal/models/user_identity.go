@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to their account at an OpenID Connect provider
+type UserIdentity struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	UserID      uuid.UUID  `db:"user_id" json:"userId"`
+	Issuer      string     `db:"issuer" json:"issuer"`
+	Subject     string     `db:"subject" json:"subject"`
+	Email       *string    `db:"email" json:"email,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	LastLoginAt *time.Time `db:"last_login_at" json:"lastLoginAt,omitempty"`
+}
+
+// OIDCStatusResponse tells the frontend whether single sign-on is offered
+type OIDCStatusResponse struct {
+	Enabled      bool   `json:"enabled"`
+	ProviderName string `json:"providerName,omitempty"` // label of the sign-in button
+	LoginURL     string `json:"loginUrl,omitempty"`
+}
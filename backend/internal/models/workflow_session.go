@@ -20,15 +20,30 @@ const (
 
 // TableDefinition represents a parsed table from the schema
 type TableDefinition struct {
-	Name   string  `json:"name"`
-	Fields []Field `json:"fields"`
+	Name        string       `json:"name"`
+	Fields      []Field      `json:"fields"`
+	ForeignKeys []ForeignKey `json:"foreignKeys,omitempty"`
 }
 
 // Field represents a database column
 type Field struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"`
-	Nullable bool   `json:"nullable"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	Nullable      bool    `json:"nullable"`
+	PrimaryKey    bool    `json:"primaryKey,omitempty"`
+	Unique        bool    `json:"unique,omitempty"`
+	AutoIncrement bool    `json:"autoIncrement,omitempty"`
+	Default       *string `json:"default,omitempty"`
+}
+
+// ForeignKey represents a reference from a table's columns to another table
+type ForeignKey struct {
+	Name       string   `json:"name,omitempty"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"refTable"`
+	RefColumns []string `json:"refColumns"`
+	OnDelete   string   `json:"onDelete,omitempty"`
+	OnUpdate   string   `json:"onUpdate,omitempty"`
 }
 
 // TableDefinitions is a slice of TableDefinition with custom JSON marshaling
@@ -153,13 +168,18 @@ func (f FieldTransformations) Value() (driver.Value, error) {
 
 // WorkflowSession represents a user's workflow session in the database
 type WorkflowSession struct {
-	ID          uuid.UUID `db:"id" json:"id"`
-	UserID      uuid.UUID `db:"user_id" json:"userId"`
-	CurrentStep int       `db:"current_step" json:"currentStep"`
+	ID             uuid.UUID  `db:"id" json:"id"`
+	UserID         uuid.UUID  `db:"user_id" json:"userId"`
+	OrganizationID *uuid.UUID `db:"organization_id" json:"organizationId,omitempty"` // nil for personal sessions
+	Name           string     `db:"name" json:"name"`
+	CurrentStep    int        `db:"current_step" json:"currentStep"`
+	Version        int        `db:"version" json:"version"` // incremented on every change
 
 	// Step 1: Schema upload
-	SchemaContent *string          `db:"schema_content" json:"-"` // Compressed, not exposed in JSON
-	SchemaTables  TableDefinitions `db:"schema_tables" json:"schemaTables"`
+	SchemaContent    *string          `db:"schema_content" json:"-"` // Compressed, legacy (see SchemaStorageKey)
+	SchemaStorageKey *string          `db:"schema_storage_key" json:"-"`
+	SchemaTables     TableDefinitions `db:"schema_tables" json:"schemaTables"`
+	Dialect          *string          `db:"dialect" json:"dialect,omitempty"` // Detected or forced SQL dialect
 
 	// Step 2: Table selection
 	SelectedTableName *string `db:"selected_table_name" json:"selectedTableName,omitempty"`
@@ -168,6 +188,9 @@ type WorkflowSession struct {
 	DataFileName *string     `db:"data_file_name" json:"dataFileName,omitempty"`
 	DataHeaders  DataHeaders `db:"data_headers" json:"dataHeaders"`
 	SampleData   SampleData  `db:"sample_data" json:"sampleData"`
+	// All rows of the data file, when kept in object storage
+	DataStorageKey *string `db:"data_storage_key" json:"-"`
+	DataRowCount   *int    `db:"data_row_count" json:"dataRowCount,omitempty"`
 
 	// Step 4: Column mapping
 	ColumnMapping        ColumnMapping        `db:"column_mapping" json:"columnMapping"`
@@ -183,17 +206,25 @@ type WorkflowSession struct {
 type WorkflowSessionResponse struct {
 	ID                   uuid.UUID            `json:"id"`
 	UserID               uuid.UUID            `json:"userId"`
+	OrganizationID       *uuid.UUID           `json:"organizationId,omitempty"`
+	Name                 string               `json:"name"`
 	CurrentStep          int                  `json:"currentStep"`
+	Version              int                  `json:"version"` // also sent as the ETag
 	SchemaTables         TableDefinitions     `json:"schemaTables"`
+	Dialect              *string              `json:"dialect,omitempty"`
 	SelectedTableName    *string              `json:"selectedTableName,omitempty"`
 	DataFileName         *string              `json:"dataFileName,omitempty"`
 	DataHeaders          DataHeaders          `json:"dataHeaders"`
 	SampleData           SampleData           `json:"sampleData"`
+	DataRowCount         *int                 `json:"dataRowCount,omitempty"` // rows kept on the server; unset when only the sample is
 	ColumnMapping        ColumnMapping        `json:"columnMapping"`
 	FieldTransformations FieldTransformations `json:"fieldTransformations"`
 	ExpiresAt            time.Time            `json:"expiresAt"`
 	CreatedAt            time.Time            `json:"createdAt"`
 	UpdatedAt            time.Time            `json:"updatedAt"`
+
+	// Templates matching the data file headers, set when a data file is saved
+	SuggestedTemplates []MappingTemplateSuggestion `json:"suggestedTemplates,omitempty"`
 }
 
 // ToResponse converts WorkflowSession to WorkflowSessionResponse
@@ -201,12 +232,17 @@ func (w *WorkflowSession) ToResponse() *WorkflowSessionResponse {
 	return &WorkflowSessionResponse{
 		ID:                   w.ID,
 		UserID:               w.UserID,
+		OrganizationID:       w.OrganizationID,
+		Name:                 w.Name,
 		CurrentStep:          w.CurrentStep,
+		Version:              w.Version,
 		SchemaTables:         w.SchemaTables,
+		Dialect:              w.Dialect,
 		SelectedTableName:    w.SelectedTableName,
 		DataFileName:         w.DataFileName,
 		DataHeaders:          w.DataHeaders,
 		SampleData:           w.SampleData,
+		DataRowCount:         w.DataRowCount,
 		ColumnMapping:        w.ColumnMapping,
 		FieldTransformations: w.FieldTransformations,
 		ExpiresAt:            w.ExpiresAt,
@@ -215,16 +251,104 @@ func (w *WorkflowSession) ToResponse() *WorkflowSessionResponse {
 	}
 }
 
+// WorkflowSessionSummary describes a session in the list of a user's
+// sessions, without its schema, sample data and mapping
+type WorkflowSessionSummary struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	CurrentStep       int       `json:"currentStep"`
+	Version           int       `json:"version"`
+	Dialect           *string   `json:"dialect,omitempty"`
+	SelectedTableName *string   `json:"selectedTableName,omitempty"`
+	DataFileName      *string   `json:"dataFileName,omitempty"`
+	DataRowCount      *int      `json:"dataRowCount,omitempty"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// ToSummary converts WorkflowSession to WorkflowSessionSummary
+func (w *WorkflowSession) ToSummary() WorkflowSessionSummary {
+	return WorkflowSessionSummary{
+		ID:                w.ID,
+		Name:              w.Name,
+		CurrentStep:       w.CurrentStep,
+		Version:           w.Version,
+		Dialect:           w.Dialect,
+		SelectedTableName: w.SelectedTableName,
+		DataFileName:      w.DataFileName,
+		DataRowCount:      w.DataRowCount,
+		ExpiresAt:         w.ExpiresAt,
+		CreatedAt:         w.CreatedAt,
+		UpdatedAt:         w.UpdatedAt,
+	}
+}
+
+// WorkflowSessionChange names what a change to a session did, as recorded
+// in its history
+type WorkflowSessionChange string
+
+const (
+	SessionCreated  WorkflowSessionChange = "created"
+	SessionRenamed  WorkflowSessionChange = "renamed"
+	SessionSchema   WorkflowSessionChange = "schema"
+	SessionTable    WorkflowSessionChange = "table"
+	SessionData     WorkflowSessionChange = "data"
+	SessionMapping  WorkflowSessionChange = "mapping"
+	SessionTemplate WorkflowSessionChange = "template"
+	SessionInferred WorkflowSessionChange = "inferred"
+	SessionRestored WorkflowSessionChange = "restored"
+)
+
+// WorkflowSessionHistoryEntry is the step state of a session after one of
+// its changes. Schema and data files are not kept, so restoring an entry
+// brings back its table selection and mapping only.
+type WorkflowSessionHistoryEntry struct {
+	ID                   uuid.UUID             `db:"id" json:"id"`
+	SessionID            uuid.UUID             `db:"session_id" json:"sessionId"`
+	Version              int                   `db:"version" json:"version"`
+	Change               WorkflowSessionChange `db:"change" json:"change"`
+	CurrentStep          int                   `db:"current_step" json:"currentStep"`
+	SelectedTableName    *string               `db:"selected_table_name" json:"selectedTableName,omitempty"`
+	ColumnMapping        ColumnMapping         `db:"column_mapping" json:"columnMapping"`
+	FieldTransformations FieldTransformations  `db:"field_transformations" json:"fieldTransformations"`
+	CreatedAt            time.Time             `db:"created_at" json:"createdAt"`
+}
+
+// RestoreWorkflowSessionRequest represents the request to undo a session
+// back to the table selection and mapping of an earlier version
+type RestoreWorkflowSessionRequest struct {
+	Version int `json:"version" validate:"required,min=1"`
+}
+
 // WorkflowSessionWithSchema includes the schema content (decompressed)
 type WorkflowSessionWithSchema struct {
 	WorkflowSessionResponse
 	SchemaContent string `json:"schemaContent"`
 }
 
+// CreateWorkflowSessionRequest represents the request to start a new
+// session next to the user's other sessions
+type CreateWorkflowSessionRequest struct {
+	Name string `json:"name,omitempty" validate:"omitempty,max=255"`
+}
+
+// RenameWorkflowSessionRequest represents the request to rename a session
+type RenameWorkflowSessionRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=255"`
+}
+
 // SaveSchemaRequest represents the request to save schema (step 1)
 type SaveSchemaRequest struct {
-	SchemaContent string              `json:"schemaContent" validate:"required"`
-	Tables        []TableDefinition   `json:"tables" validate:"required,min=1"`
+	SchemaContent string            `json:"schemaContent" validate:"required"`
+	Tables        []TableDefinition `json:"tables" validate:"required,min=1"`
+	Dialect       string            `json:"dialect,omitempty" validate:"omitempty,oneof=mysql postgresql sqlserver sqlite"`
+}
+
+// SaveSchemaFromConnectionRequest represents the request to fill step 1 by
+// introspecting a saved connection profile instead of uploading a schema
+type SaveSchemaFromConnectionRequest struct {
+	ProfileID string `json:"profileId" validate:"required,uuid"`
 }
 
 // SaveTableSelectionRequest represents the request to save table selection (step 2)
@@ -232,11 +356,15 @@ type SaveTableSelectionRequest struct {
 	TableName string `json:"tableName" validate:"required,min=1,max=255"`
 }
 
-// SaveDataFileRequest represents the request to save data file info (step 3)
+// SaveDataFileRequest represents the request to save data file info (step 3).
+// Rows, when sent, are kept in object storage so SQL can be generated for
+// all of them after the page is reloaded; the sample then defaults to the
+// first 50 rows.
 type SaveDataFileRequest struct {
 	FileName    string          `json:"fileName" validate:"required,min=1,max=255"`
 	Headers     []string        `json:"headers" validate:"required,min=1"`
-	SampleData  [][]interface{} `json:"sampleData" validate:"required,min=1,max=50"`
+	SampleData  [][]interface{} `json:"sampleData" validate:"required_without=Rows,max=50"`
+	Rows        [][]interface{} `json:"rows,omitempty"`
 }
 
 // SaveMappingRequest represents the request to save column mapping (step 4)
@@ -244,3 +372,50 @@ type SaveMappingRequest struct {
 	Mapping        map[string]string `json:"mapping" validate:"required,min=1"`
 	Transformations map[string]string `json:"transformations"`
 }
+
+// GenerateSessionSQLRequest represents the request to generate INSERT SQL
+// for every stored row of the session's data file
+type GenerateSessionSQLRequest struct {
+	// KeyColumns default to the primary and unique key fields of the table
+	KeyColumns []string `json:"keyColumns" validate:"omitempty,dive,min=1"`
+	Upsert     bool     `json:"upsert"`
+}
+
+// InferTableRequest represents the request to create the session's target
+// table from its data file
+type InferTableRequest struct {
+	// TableName defaults to a name derived from the data file name
+	TableName string `json:"tableName,omitempty" validate:"omitempty,max=255"`
+	// Dialect defaults to the session's dialect, then MySQL
+	Dialect string `json:"dialect,omitempty" validate:"omitempty,oneof=mysql postgresql sqlserver sqlite"`
+}
+
+// InferTableFromUploadRequest represents the request to fill the session
+// from a completed chunked CSV upload, inferring the table from every row
+type InferTableFromUploadRequest struct {
+	UploadID string `json:"uploadId" validate:"required,uuid"`
+	InferTableRequest
+}
+
+// InferredColumn describes the type inferred for a data file column
+type InferredColumn struct {
+	Header     string `json:"header"`
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primaryKey,omitempty"`
+	MaxLength  int    `json:"maxLength"`
+	DateFormat string `json:"dateFormat,omitempty"`
+	// Transformation is set on the session mapping to convert the values
+	Transformation string `json:"transformation,omitempty"`
+}
+
+// InferTableResult is the session after its table was inferred, with the
+// CREATE TABLE statement to run before importing
+type InferTableResult struct {
+	Session *WorkflowSessionResponse `json:"session"`
+	SQL     string                   `json:"sql"`
+	Rows    int                      `json:"rows"` // data rows the types were inferred from
+	Columns []InferredColumn         `json:"columns"`
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// APITokenRepository handles database operations for personal access tokens
+type APITokenRepository struct {
+	db *database.DB
+}
+
+// NewAPITokenRepository creates a new APITokenRepository
+func NewAPITokenRepository(db *database.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+// Create creates a new personal access token, unless its owner already
+// has limit tokens that are neither revoked nor expired. It returns false
+// then.
+func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken, limit int) (bool, error) {
+	// Locking the owner keeps concurrent requests from passing the limit
+	query := `
+		WITH owner AS (
+			SELECT id FROM users WHERE id = $1 FOR UPDATE
+		)
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		SELECT owner.id, $2, $3, $4, $5, $6
+		FROM owner
+		WHERE (
+			SELECT COUNT(*) FROM api_tokens
+			WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		) < $7
+		RETURNING id, created_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		token.Scopes,
+		token.ExpiresAt,
+		limit,
+	).Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetByTokenHash retrieves a personal access token by its hash
+func (r *APITokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM api_tokens
+		WHERE token_hash = $1
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("API token not found")
+		}
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListByUserID lists the personal access tokens of a user, newest first
+func (r *APITokenRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	var tokens []*models.APIToken
+	if err := r.db.Sqlx.SelectContext(ctx, &tokens, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Revoke revokes a personal access token of a user
+func (r *APITokenRepository) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	query := `
+		UPDATE api_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API token not found")
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every personal access token of a user
+func (r *APITokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	return revokeAllAPITokens(ctx, r.db.Sqlx, userID)
+}
+
+// revokeAllAPITokens revokes every personal access token of a user through
+// q, which may be a transaction
+func revokeAllAPITokens(ctx context.Context, q sqlx.ExecerContext, userID uuid.UUID) error {
+	query := `
+		UPDATE api_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := q.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke all API tokens: %w", err)
+	}
+
+	return nil
+}
+
+// TouchLastUsed records that a token was used. To spare a write per
+// request it is updated at most once a minute.
+func (r *APITokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update API token: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AuditRepository handles database operations for audit events. Events can
+// only be added; the table rejects updates and deletes, except for erasing
+// the personal data of deleted users.
+type AuditRepository struct {
+	db *database.DB
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const auditEventColumns = `
+		id, occurred_at, actor_id, actor_email, organization_id, action,
+		target_type, target_id, ip_address, user_agent, metadata
+`
+
+// Create records an audit event, copying the actor's current email
+func (r *AuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (
+			actor_id, actor_email, organization_id, action,
+			target_type, target_id, ip_address, user_agent, metadata
+		)
+		VALUES (
+			$1, COALESCE($2, (SELECT email FROM users WHERE id = $1)),
+			$3, $4, $5, $6, $7, $8, $9
+		)
+		RETURNING id, occurred_at, actor_email
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		event.ActorID,
+		event.ActorEmail,
+		event.OrganizationID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IPAddress,
+		event.UserAgent,
+		event.Metadata,
+	).Scan(&event.ID, &event.OccurredAt, &event.ActorEmail)
+
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	return nil
+}
+
+// auditConditions builds the WHERE clause selecting the events of the
+// workspace that match the filter. Personal workspaces see the user's own
+// events outside organizations; organization workspaces see every event of
+// the organization.
+func auditConditions(ws models.Workspace, filter *models.AuditFilter) (string, []interface{}) {
+	var whereConditions []string
+	var args []interface{}
+
+	if ws.OrganizationID != nil {
+		whereConditions = append(whereConditions, "organization_id = $1")
+		args = append(args, *ws.OrganizationID)
+	} else {
+		whereConditions = append(whereConditions, "organization_id IS NULL AND actor_id = $1")
+		args = append(args, ws.UserID)
+	}
+	argCounter := 2
+
+	if filter.Action != "" {
+		if strings.HasSuffix(filter.Action, ".") {
+			whereConditions = append(whereConditions, fmt.Sprintf("starts_with(action, $%d)", argCounter))
+		} else {
+			whereConditions = append(whereConditions, fmt.Sprintf("action = $%d", argCounter))
+		}
+		args = append(args, filter.Action)
+		argCounter++
+	}
+
+	if filter.ActorID != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("actor_id = $%d", argCounter))
+		args = append(args, *filter.ActorID)
+		argCounter++
+	}
+
+	if filter.TargetType != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("target_type = $%d", argCounter))
+		args = append(args, filter.TargetType)
+		argCounter++
+	}
+
+	if filter.TargetID != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("target_id = $%d", argCounter))
+		args = append(args, filter.TargetID)
+		argCounter++
+	}
+
+	if filter.From != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("occurred_at >= $%d", argCounter))
+		args = append(args, filter.From.UTC())
+		argCounter++
+	}
+
+	if filter.To != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("occurred_at < $%d", argCounter))
+		args = append(args, filter.To.UTC())
+	}
+
+	return strings.Join(whereConditions, " AND "), args
+}
+
+// List retrieves the audit events of the workspace matching the filter,
+// newest first, with pagination
+func (r *AuditRepository) List(ctx context.Context, ws models.Workspace, filter *models.AuditFilter) ([]*models.AuditEvent, int64, error) {
+	// Set defaults
+	page := 1
+	pageSize := 20
+
+	if filter.Page > 0 {
+		page = filter.Page
+	}
+	if filter.PageSize > 0 && filter.PageSize <= 100 {
+		pageSize = filter.PageSize
+	}
+
+	whereClause, args := auditConditions(ws, filter)
+	argCounter := len(args) + 1
+
+	// Get total count
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM audit_events
+		WHERE %s
+	`, whereClause)
+
+	var total int64
+	err := r.db.Sqlx.GetContext(ctx, &total, countQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	// Get paginated results
+	offset := (page - 1) * pageSize
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM audit_events
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT $%d OFFSET $%d
+	`, auditEventColumns, whereClause, argCounter, argCounter+1)
+
+	args = append(args, pageSize, offset)
+
+	var events []*models.AuditEvent
+	err = r.db.Sqlx.SelectContext(ctx, &events, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// ListAfter retrieves up to limit audit events of the workspace matching
+// the filter with IDs above afterID, oldest first. Exports page through
+// the log this way so events recorded meanwhile are neither skipped nor
+// repeated.
+func (r *AuditRepository) ListAfter(ctx context.Context, ws models.Workspace, filter *models.AuditFilter, afterID int64, limit int) ([]*models.AuditEvent, error) {
+	whereClause, args := auditConditions(ws, filter)
+	argCounter := len(args) + 1
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM audit_events
+		WHERE %s AND id > $%d
+		ORDER BY id
+		LIMIT $%d
+	`, auditEventColumns, whereClause, argCounter, argCounter+1)
+
+	args = append(args, afterID, limit)
+
+	var events []*models.AuditEvent
+	if err := r.db.Sqlx.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ListByActorAfter retrieves up to limit events the user caused, in any
+// workspace, with IDs above afterID, oldest first
+func (r *AuditRepository) ListByActorAfter(ctx context.Context, actorID uuid.UUID, afterID int64, limit int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT ` + auditEventColumns + `
+		FROM audit_events
+		WHERE actor_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	var events []*models.AuditEvent
+	if err := r.db.Sqlx.SelectContext(ctx, &events, query, actorID, afterID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// RedactActor erases the personal data of a deleted user from the events
+// they caused and from failed logins with their email address: the email,
+// IP address and user agent. The actor ID stays, so the events remain
+// attributable without identifying the person. It returns how many events
+// were redacted.
+func (r *AuditRepository) RedactActor(ctx context.Context, actorID uuid.UUID, email string) (int64, error) {
+	query := `
+		UPDATE audit_events
+		SET actor_email = NULL, ip_address = '', user_agent = '', metadata = metadata - 'email'
+		WHERE actor_id = $1 OR (actor_id IS NULL AND lower(metadata->>'email') = lower($2))
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, actorID, email)
+	if err != nil {
+		return 0, fmt.Errorf("failed to redact audit events: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ConnectionProfileRepository handles database operations for connection profiles
+type ConnectionProfileRepository struct {
+	db *database.DB
+}
+
+// NewConnectionProfileRepository creates a new ConnectionProfileRepository
+func NewConnectionProfileRepository(db *database.DB) *ConnectionProfileRepository {
+	return &ConnectionProfileRepository{db: db}
+}
+
+// Create creates a new connection profile
+func (r *ConnectionProfileRepository) Create(ctx context.Context, profile *models.ConnectionProfile) error {
+	query := `
+		INSERT INTO connection_profiles (user_id, organization_id, name, dialect, encrypted_dsn, display_dsn, db_schema)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		profile.UserID,
+		profile.OrganizationID,
+		profile.Name,
+		profile.Dialect,
+		profile.EncryptedDSN,
+		profile.DisplayDSN,
+		profile.DBSchema,
+	).Scan(&profile.ID, &profile.CreatedAt, &profile.UpdatedAt)
+
+	if err != nil {
+		// Check for unique constraint violation (duplicate name per workspace)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("connection profile name already exists")
+		}
+		return fmt.Errorf("failed to create connection profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a connection profile of the workspace
+func (r *ConnectionProfileRepository) GetByID(ctx context.Context, id uuid.UUID, ws models.Workspace) (*models.ConnectionProfile, error) {
+	var profile models.ConnectionProfile
+
+	query := `
+		SELECT id, user_id, organization_id, name, dialect, encrypted_dsn, display_dsn, db_schema,
+		       last_used_at, created_at, updated_at
+		FROM connection_profiles
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + `
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &profile, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("connection profile not found")
+		}
+		return nil, fmt.Errorf("failed to get connection profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// List lists the workspace's connection profiles by name
+func (r *ConnectionProfileRepository) List(ctx context.Context, ws models.Workspace) ([]models.ConnectionProfile, error) {
+	var profiles []models.ConnectionProfile
+
+	query := `
+		SELECT id, user_id, organization_id, name, dialect, encrypted_dsn, display_dsn, db_schema,
+		       last_used_at, created_at, updated_at
+		FROM connection_profiles
+		WHERE ` + workspaceCondition(1, 2) + `
+		ORDER BY name
+	`
+
+	err := r.db.Sqlx.SelectContext(ctx, &profiles, query, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connection profiles: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// TouchLastUsed records that the profile was used for introspection
+func (r *ConnectionProfileRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE connection_profiles SET last_used_at = NOW() WHERE id = $1`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update connection profile: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a connection profile of the workspace
+func (r *ConnectionProfileRepository) Delete(ctx context.Context, id uuid.UUID, ws models.Workspace) error {
+	query := `DELETE FROM connection_profiles WHERE id = $1 AND ` + workspaceCondition(2, 3)
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete connection profile: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("connection profile not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// EmailTokenRepository handles database operations for email verification
+// and password reset tokens
+type EmailTokenRepository struct {
+	db *database.DB
+}
+
+// NewEmailTokenRepository creates a new EmailTokenRepository
+func NewEmailTokenRepository(db *database.DB) *EmailTokenRepository {
+	return &EmailTokenRepository{db: db}
+}
+
+// Create creates a new token, superseding the user's unused tokens of the
+// same purpose so only the latest email works
+func (r *EmailTokenRepository) Create(ctx context.Context, token *models.EmailToken) error {
+	query := `
+		WITH superseded AS (
+			UPDATE email_tokens
+			SET used_at = NOW()
+			WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+		)
+		INSERT INTO email_tokens (user_id, purpose, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Purpose,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create email token: %w", err)
+	}
+
+	return nil
+}
+
+// CreatedSince reports whether a token of the purpose was created for the
+// user after since
+func (r *EmailTokenRepository) CreatedSince(ctx context.Context, userID uuid.UUID, purpose string, since time.Time) (bool, error) {
+	var exists bool
+
+	query := `SELECT EXISTS(SELECT 1 FROM email_tokens WHERE user_id = $1 AND purpose = $2 AND created_at > $3)`
+
+	err := r.db.Sqlx.GetContext(ctx, &exists, query, userID, purpose, since)
+	if err != nil {
+		return false, fmt.Errorf("failed to check email tokens: %w", err)
+	}
+
+	return exists, nil
+}
+
+// VerifyEmail uses a verification token and marks the email address of
+// its user verified, returning the user ID
+func (r *EmailTokenRepository) VerifyEmail(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	query := `
+		WITH used AS (
+			UPDATE email_tokens
+			SET used_at = NOW()
+			WHERE id = $1 AND purpose = 'verify_email' AND used_at IS NULL AND expires_at > NOW()
+			RETURNING user_id
+		)
+		UPDATE users
+		SET email_verified = true
+		FROM used
+		WHERE users.id = used.user_id
+		RETURNING users.id
+	`
+
+	return r.useToken(ctx, query, id)
+}
+
+// ResetPassword uses a password reset token and sets the password hash of
+// its user, returning the user ID
+func (r *EmailTokenRepository) ResetPassword(ctx context.Context, id uuid.UUID, passwordHash string) (uuid.UUID, error) {
+	query := `
+		WITH used AS (
+			UPDATE email_tokens
+			SET used_at = NOW()
+			WHERE id = $1 AND purpose = 'reset_password' AND used_at IS NULL AND expires_at > NOW()
+			RETURNING user_id
+		)
+		UPDATE users
+		SET password_hash = $2
+		FROM used
+		WHERE users.id = used.user_id
+		RETURNING users.id
+	`
+
+	return r.useToken(ctx, query, id, passwordHash)
+}
+
+// useToken runs a query that consumes a token and returns its user ID
+func (r *EmailTokenRepository) useToken(ctx context.Context, query string, args ...interface{}) (uuid.UUID, error) {
+	var userID uuid.UUID
+
+	err := r.db.Sqlx.GetContext(ctx, &userID, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("email token not found")
+		}
+		return uuid.Nil, fmt.Errorf("failed to use email token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// DeleteExpired deletes tokens that expired before olderThan ago
+func (r *EmailTokenRepository) DeleteExpired(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `DELETE FROM email_tokens WHERE expires_at < $1`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired email tokens: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
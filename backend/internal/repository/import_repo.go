@@ -27,10 +27,12 @@ func NewImportRepository(db *database.DB) *ImportRepository {
 func (r *ImportRepository) Create(ctx context.Context, imp *models.Import) error {
 	query := `
 		INSERT INTO imports (
-			user_id, table_name, row_count, status, generated_sql,
+			user_id, organization_id, table_name, row_count, status, generated_sql,
+			sql_storage_key, sql_checksum, sql_size,
+			rollback_sql, rollback_storage_key,
 			error_count, warning_count, metadata
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING id, created_at, updated_at
 	`
 
@@ -38,10 +40,16 @@ func (r *ImportRepository) Create(ctx context.Context, imp *models.Import) error
 		ctx,
 		query,
 		imp.UserID,
+		imp.OrganizationID,
 		imp.TableName,
 		imp.RowCount,
 		imp.Status,
 		imp.GeneratedSQL,
+		imp.SQLStorageKey,
+		imp.SQLChecksum,
+		imp.SQLSize,
+		imp.RollbackSQL,
+		imp.RollbackKey,
 		imp.ErrorCount,
 		imp.WarningCount,
 		imp.Metadata,
@@ -54,19 +62,20 @@ func (r *ImportRepository) Create(ctx context.Context, imp *models.Import) error
 	return nil
 }
 
-// GetByID retrieves an import by ID (without SQL)
-func (r *ImportRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Import, error) {
+// GetByID retrieves an import of the workspace by ID (without SQL)
+func (r *ImportRepository) GetByID(ctx context.Context, id uuid.UUID, ws models.Workspace) (*models.Import, error) {
 	var imp models.Import
 
 	query := `
-		SELECT id, user_id, table_name, row_count, status,
+		SELECT id, user_id, organization_id, table_name, row_count, status,
+		       sql_storage_key, sql_checksum, sql_size,
 		       error_count, warning_count, metadata,
 		       created_at, updated_at
 		FROM imports
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + `
 	`
 
-	err := r.db.Sqlx.GetContext(ctx, &imp, query, id, userID)
+	err := r.db.Sqlx.GetContext(ctx, &imp, query, id, ws.UserID, ws.OrganizationID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("import not found")
@@ -78,18 +87,21 @@ func (r *ImportRepository) GetByID(ctx context.Context, id uuid.UUID, userID uui
 }
 
 // GetByIDWithSQL retrieves an import by ID including the generated SQL
-func (r *ImportRepository) GetByIDWithSQL(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Import, error) {
+// and rollback script
+func (r *ImportRepository) GetByIDWithSQL(ctx context.Context, id uuid.UUID, ws models.Workspace) (*models.Import, error) {
 	var imp models.Import
 
 	query := `
-		SELECT id, user_id, table_name, row_count, status, generated_sql,
+		SELECT id, user_id, organization_id, table_name, row_count, status, generated_sql,
+		       sql_storage_key, sql_checksum, sql_size,
+		       rollback_sql, rollback_storage_key,
 		       error_count, warning_count, metadata,
 		       created_at, updated_at
 		FROM imports
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + `
 	`
 
-	err := r.db.Sqlx.GetContext(ctx, &imp, query, id, userID)
+	err := r.db.Sqlx.GetContext(ctx, &imp, query, id, ws.UserID, ws.OrganizationID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("import not found")
@@ -100,8 +112,8 @@ func (r *ImportRepository) GetByIDWithSQL(ctx context.Context, id uuid.UUID, use
 	return &imp, nil
 }
 
-// List retrieves imports with pagination and filters
-func (r *ImportRepository) List(ctx context.Context, userID uuid.UUID, req *models.GetImportsRequest) ([]*models.Import, int64, error) {
+// List retrieves the imports of the workspace with pagination and filters
+func (r *ImportRepository) List(ctx context.Context, ws models.Workspace, req *models.GetImportsRequest) ([]*models.Import, int64, error) {
 	// Set defaults
 	page := 1
 	pageSize := 20
@@ -122,9 +134,9 @@ func (r *ImportRepository) List(ctx context.Context, userID uuid.UUID, req *mode
 	}
 
 	// Build WHERE clause
-	whereConditions := []string{"user_id = $1"}
-	args := []interface{}{userID}
-	argCounter := 2
+	whereConditions := []string{workspaceCondition(1, 2)}
+	args := []interface{}{ws.UserID, ws.OrganizationID}
+	argCounter := 3
 
 	if req.TableName != "" {
 		whereConditions = append(whereConditions, fmt.Sprintf("table_name ILIKE $%d", argCounter))
@@ -157,7 +169,8 @@ func (r *ImportRepository) List(ctx context.Context, userID uuid.UUID, req *mode
 	offset := (page - 1) * pageSize
 
 	query := fmt.Sprintf(`
-		SELECT id, user_id, table_name, row_count, status,
+		SELECT id, user_id, organization_id, table_name, row_count, status,
+		       sql_storage_key, sql_checksum, sql_size,
 		       error_count, warning_count, metadata,
 		       created_at, updated_at
 		FROM imports
@@ -177,32 +190,47 @@ func (r *ImportRepository) List(ctx context.Context, userID uuid.UUID, req *mode
 	return imports, total, nil
 }
 
-// Delete deletes an import by ID
-func (r *ImportRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
-	query := `
-		DELETE FROM imports
-		WHERE id = $1 AND user_id = $2
-	`
+// storageKeys are the object storage keys returned by deleted imports
+type storageKeys struct {
+	SQL      string `db:"sql_key"`
+	Rollback string `db:"rollback_key"`
+}
 
-	result, err := r.db.Sqlx.ExecContext(ctx, query, id, userID)
-	if err != nil {
-		return fmt.Errorf("failed to delete import: %w", err)
-	}
+const returningStorageKeys = `
+		RETURNING COALESCE(sql_storage_key, '') AS sql_key,
+		          COALESCE(rollback_storage_key, '') AS rollback_key
+`
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+// flattenStorageKeys lists the non-empty keys of deleted imports
+func flattenStorageKeys(rows []storageKeys) []string {
+	var keys []string
+	for _, row := range rows {
+		keys = append(keys, row.SQL, row.Rollback)
 	}
+	return nonEmpty(keys)
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("import not found")
+// Delete deletes an import of the workspace by ID and returns the storage
+// keys of its SQL and rollback script
+func (r *ImportRepository) Delete(ctx context.Context, id uuid.UUID, ws models.Workspace) ([]string, error) {
+	query := `
+		DELETE FROM imports
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + returningStorageKeys
+
+	var keys storageKeys
+	err := r.db.Sqlx.GetContext(ctx, &keys, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("import not found")
+		}
+		return nil, fmt.Errorf("failed to delete import: %w", err)
 	}
 
-	return nil
+	return flattenStorageKeys([]storageKeys{keys}), nil
 }
 
-// GetStats retrieves statistics about user's imports
-func (r *ImportRepository) GetStats(ctx context.Context, userID uuid.UUID) (*models.ImportStats, error) {
+// GetStats retrieves statistics about the imports of the workspace
+func (r *ImportRepository) GetStats(ctx context.Context, ws models.Workspace) (*models.ImportStats, error) {
 	query := `
 		SELECT
 			COUNT(*) as total_imports,
@@ -212,7 +240,7 @@ func (r *ImportRepository) GetStats(ctx context.Context, userID uuid.UUID) (*mod
 			COUNT(*) FILTER (WHERE status = 'failed') as failed_count,
 			MAX(created_at) as last_import_date
 		FROM imports
-		WHERE user_id = $1
+		WHERE ` + workspaceCondition(1, 2) + `
 	`
 
 	var stats struct {
@@ -224,7 +252,7 @@ func (r *ImportRepository) GetStats(ctx context.Context, userID uuid.UUID) (*mod
 		LastImportDate *sql.NullTime `db:"last_import_date"`
 	}
 
-	err := r.db.Sqlx.GetContext(ctx, &stats, query, userID)
+	err := r.db.Sqlx.GetContext(ctx, &stats, query, ws.UserID, ws.OrganizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get import stats: %w", err)
 	}
@@ -239,14 +267,14 @@ func (r *ImportRepository) GetStats(ctx context.Context, userID uuid.UUID) (*mod
 	mostUsedQuery := `
 		SELECT table_name
 		FROM imports
-		WHERE user_id = $1
+		WHERE ` + workspaceCondition(1, 2) + `
 		GROUP BY table_name
 		ORDER BY COUNT(*) DESC
 		LIMIT 1
 	`
 
 	var mostUsedTable sql.NullString
-	_ = r.db.Sqlx.GetContext(ctx, &mostUsedTable, mostUsedQuery, userID)
+	_ = r.db.Sqlx.GetContext(ctx, &mostUsedTable, mostUsedQuery, ws.UserID, ws.OrganizationID)
 
 	result := &models.ImportStats{
 		TotalImports: stats.TotalImports,
@@ -268,22 +296,138 @@ func (r *ImportRepository) GetStats(ctx context.Context, userID uuid.UUID) (*mod
 	return result, nil
 }
 
-// DeleteOldImports deletes imports older than the specified number of days
-func (r *ImportRepository) DeleteOldImports(ctx context.Context, userID uuid.UUID, olderThanDays int) (int64, error) {
+// DeleteOldImports deletes the workspace's imports older than the specified
+// number of days and returns how many were deleted and the storage keys of
+// their SQL and rollback scripts
+func (r *ImportRepository) DeleteOldImports(ctx context.Context, ws models.Workspace, olderThanDays int) (int64, []string, error) {
 	query := `
 		DELETE FROM imports
-		WHERE user_id = $1 AND created_at < NOW() - INTERVAL '1 day' * $2
+		WHERE ` + workspaceCondition(1, 2) + ` AND created_at < NOW() - INTERVAL '1 day' * $3` + returningStorageKeys
+
+	var rows []storageKeys
+	err := r.db.Sqlx.SelectContext(ctx, &rows, query, ws.UserID, ws.OrganizationID, olderThanDays)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to delete old imports: %w", err)
+	}
+
+	return int64(len(rows)), flattenStorageKeys(rows), nil
+}
+
+// ListLegacySQL returns up to limit imports whose SQL is still stored
+// compressed in the generated_sql column
+func (r *ImportRepository) ListLegacySQL(ctx context.Context, limit int) ([]*models.Import, error) {
+	query := `
+		SELECT id, user_id, generated_sql
+		FROM imports
+		WHERE generated_sql IS NOT NULL AND sql_storage_key IS NULL
+		ORDER BY created_at
+		LIMIT $1
 	`
 
-	result, err := r.db.Sqlx.ExecContext(ctx, query, userID, olderThanDays)
+	var imports []*models.Import
+	if err := r.db.Sqlx.SelectContext(ctx, &imports, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list imports with legacy SQL: %w", err)
+	}
+
+	return imports, nil
+}
+
+// SetSQLObject points an import at its SQL in object storage and drops the
+// compressed copy. It returns false when the import was deleted or moved in
+// the meantime.
+func (r *ImportRepository) SetSQLObject(ctx context.Context, id uuid.UUID, storageKey, checksum string, size int64) (bool, error) {
+	query := `
+		UPDATE imports
+		SET sql_storage_key = $2, sql_checksum = $3, sql_size = $4, generated_sql = NULL
+		WHERE id = $1 AND sql_storage_key IS NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, storageKey, checksum, size)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete old imports: %w", err)
+		return false, fmt.Errorf("failed to update import: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return rowsAffected, nil
+	return rowsAffected == 1, nil
+}
+
+// ListLegacyRollbackSQL returns up to limit imports whose rollback script
+// is stored compressed in the rollback_sql column
+func (r *ImportRepository) ListLegacyRollbackSQL(ctx context.Context, limit int) ([]*models.Import, error) {
+	query := `
+		SELECT id, user_id, rollback_sql
+		FROM imports
+		WHERE rollback_sql IS NOT NULL AND rollback_storage_key IS NULL
+		ORDER BY created_at
+		LIMIT $1
+	`
+
+	var imports []*models.Import
+	if err := r.db.Sqlx.SelectContext(ctx, &imports, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list imports with legacy rollback scripts: %w", err)
+	}
+
+	return imports, nil
+}
+
+// SetRollbackObject points an import at its rollback script in object
+// storage and drops the compressed copy. It returns false when the import
+// was deleted or moved in the meantime.
+func (r *ImportRepository) SetRollbackObject(ctx context.Context, id uuid.UUID, storageKey string) (bool, error) {
+	query := `
+		UPDATE imports
+		SET rollback_storage_key = $2, rollback_sql = NULL
+		WHERE id = $1 AND rollback_storage_key IS NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, storageKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to update import: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// ListByUserAfter retrieves up to limit imports the user created, in any
+// workspace, with IDs above afterID in ID order, including where their SQL
+// and rollback script are kept
+func (r *ImportRepository) ListByUserAfter(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*models.Import, error) {
+	query := `
+		SELECT id, user_id, organization_id, table_name, row_count, status, generated_sql,
+		       sql_storage_key, sql_checksum, sql_size,
+		       rollback_sql, rollback_storage_key,
+		       error_count, warning_count, metadata,
+		       created_at, updated_at
+		FROM imports
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	var imports []*models.Import
+	if err := r.db.Sqlx.SelectContext(ctx, &imports, query, userID, afterID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list imports: %w", err)
+	}
+
+	return imports, nil
+}
+
+// nonEmpty drops empty strings
+func nonEmpty(values []string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
 }
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrJobOwnershipLost is returned when a worker updates a job that is no
+// longer running under its lock, e.g. after another worker reclaimed it
+var ErrJobOwnershipLost = errors.New("job ownership lost")
+
+// JobRepository handles database operations for background jobs
+type JobRepository struct {
+	db *database.DB
+}
+
+// NewJobRepository creates a new JobRepository
+func NewJobRepository(db *database.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+const jobColumns = `
+	id, user_id, organization_id, kind, status, payload, phase, rows_total, rows_processed,
+	error, import_id, result_storage_key, result_size, cancel_requested, attempts, locked_by, locked_at,
+	started_at, finished_at, created_at, updated_at
+`
+
+// Create queues a new job
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	query := `
+		INSERT INTO jobs (user_id, organization_id, kind, payload, rows_total)
+		VALUES ($1, $2, $3, $4::jsonb, $5)
+		RETURNING id, status, created_at, updated_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		job.UserID,
+		job.OrganizationID,
+		job.Kind,
+		string(job.Payload),
+		job.RowsTotal,
+	).Scan(&job.ID, &job.Status, &job.CreatedAt, &job.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a job owned by the user (without payload)
+func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Job, error) {
+	var job models.Job
+
+	query := `
+		SELECT id, user_id, organization_id, kind, status, phase, rows_total, rows_processed,
+		       error, import_id, result_storage_key, result_size, cancel_requested, attempts, started_at,
+		       finished_at, created_at, updated_at
+		FROM jobs
+		WHERE id = $1 AND user_id = $2
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &job, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("job not found")
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ListByUserID lists the user's most recent jobs (without payload)
+func (r *JobRepository) ListByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+
+	query := `
+		SELECT id, user_id, organization_id, kind, status, phase, rows_total, rows_processed,
+		       error, import_id, result_storage_key, result_size, cancel_requested, attempts, started_at,
+		       finished_at, created_at, updated_at
+		FROM jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	err := r.db.Sqlx.SelectContext(ctx, &jobs, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Claim locks the oldest queued job for a worker. Running jobs whose
+// heartbeat is older than staleAfter (their worker died) are reclaimed too.
+// It returns nil when there is nothing to do.
+func (r *JobRepository) Claim(ctx context.Context, workerID string, staleAfter time.Duration) (*models.Job, error) {
+	var job models.Job
+
+	query := `
+		UPDATE jobs
+		SET status = 'running', locked_by = $1, locked_at = NOW(),
+		    started_at = COALESCE(started_at, NOW()), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued'
+			   OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $2))
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	err := r.db.Sqlx.GetContext(ctx, &job, query, workerID, staleAfter.Seconds())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// The updates of a running job below only apply while workerID holds it.
+// Once another worker reclaimed the job they fail with ErrJobOwnershipLost,
+// and the worker must stop the job without recording anything.
+
+// UpdateProgress records progress and refreshes the worker heartbeat. It
+// returns whether cancellation was requested.
+func (r *JobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, workerID string, phase string, rowsProcessed int) (bool, error) {
+	query := `
+		UPDATE jobs
+		SET phase = $3, rows_processed = $4, locked_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+		RETURNING cancel_requested
+	`
+
+	var cancelRequested bool
+	if err := r.db.Sqlx.QueryRowContext(ctx, query, id, workerID, phase, rowsProcessed).Scan(&cancelRequested); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrJobOwnershipLost
+		}
+		return false, fmt.Errorf("failed to update job progress: %w", err)
+	}
+
+	return cancelRequested, nil
+}
+
+// Finish moves a running job to a terminal status
+func (r *JobRepository) Finish(ctx context.Context, id uuid.UUID, workerID string, status models.JobStatus, importID *uuid.UUID, errMsg *string) error {
+	query := `
+		UPDATE jobs
+		SET status = $3, import_id = $4, error = $5, finished_at = NOW(),
+		    locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, workerID, status, importID, errMsg)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+
+	return requireJobOwnership(result)
+}
+
+// SetResult records the file a running job produced
+func (r *JobRepository) SetResult(ctx context.Context, id uuid.UUID, workerID string, storageKey string, size int64) error {
+	query := `
+		UPDATE jobs
+		SET result_storage_key = $3, result_size = $4
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, workerID, storageKey, size)
+	if err != nil {
+		return fmt.Errorf("failed to record job result: %w", err)
+	}
+
+	return requireJobOwnership(result)
+}
+
+// requireJobOwnership fails when an update of a running job matched no row
+func requireJobOwnership(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrJobOwnershipLost
+	}
+
+	return nil
+}
+
+// GetUnfinishedByKind retrieves the user's queued or running job of a kind,
+// or nil when there is none
+func (r *JobRepository) GetUnfinishedByKind(ctx context.Context, userID uuid.UUID, kind models.JobKind) (*models.Job, error) {
+	var job models.Job
+
+	query := `
+		SELECT id, user_id, organization_id, kind, status, phase, rows_total, rows_processed,
+		       error, import_id, result_storage_key, result_size, cancel_requested, attempts, started_at,
+		       finished_at, created_at, updated_at
+		FROM jobs
+		WHERE user_id = $1 AND kind = $2 AND status IN ('queued', 'running')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &job, query, userID, kind)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ExpireResults forgets the files of jobs that finished more than olderThan
+// ago and returns their storage keys
+func (r *JobRepository) ExpireResults(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	query := `
+		WITH expired AS (
+			SELECT id, result_storage_key
+			FROM jobs
+			WHERE result_storage_key IS NOT NULL
+			  AND finished_at < NOW() - make_interval(secs => $1)
+			FOR UPDATE
+		)
+		UPDATE jobs j
+		SET result_storage_key = NULL, result_size = NULL
+		FROM expired e
+		WHERE j.id = e.id
+		RETURNING e.result_storage_key
+	`
+
+	var keys []string
+	if err := r.db.Sqlx.SelectContext(ctx, &keys, query, olderThan.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to expire job results: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Requeue releases a running job so another worker can pick it up
+func (r *JobRepository) Requeue(ctx context.Context, id uuid.UUID, workerID string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'queued', locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+
+	return requireJobOwnership(result)
+}
+
+// RequestCancel cancels a queued job immediately and flags a running one
+// for its worker. It returns the updated job.
+func (r *JobRepository) RequestCancel(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Job, error) {
+	var job models.Job
+
+	query := `
+		UPDATE jobs
+		SET cancel_requested = TRUE,
+		    status = CASE WHEN status = 'queued' THEN 'canceled' ELSE status END,
+		    finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, organization_id, kind, status, phase, rows_total, rows_processed,
+		          error, import_id, result_storage_key, result_size, cancel_requested, attempts, started_at,
+		          finished_at, created_at, updated_at
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &job, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("job not found")
+		}
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	return &job, nil
+}
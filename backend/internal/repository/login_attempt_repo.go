@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"db-importer/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LoginAttemptRepository handles database operations for login attempts
+type LoginAttemptRepository struct {
+	db *database.DB
+}
+
+// NewLoginAttemptRepository creates a new LoginAttemptRepository
+func NewLoginAttemptRepository(db *database.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// Begin records a login attempt for an email address as failed until it
+// succeeds and returns its ID, unless allow refuses it given the failed
+// attempts within window (see failures). The attempts of an address are
+// counted and recorded under a transaction-level advisory lock on it, so
+// concurrent attempts count against each other.
+func (r *LoginAttemptRepository) Begin(ctx context.Context, email, ipAddress string, window time.Duration, allow func(failures int, sinceLast time.Duration) error) (int64, error) {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, email); err != nil {
+		return 0, fmt.Errorf("failed to lock login attempts: %w", err)
+	}
+
+	count, sinceLast, err := failures(ctx, tx, email, window)
+	if err != nil {
+		return 0, err
+	}
+	if err := allow(count, sinceLast); err != nil {
+		return 0, err
+	}
+
+	var id int64
+
+	query := `
+		INSERT INTO login_attempts (email, ip_address)
+		VALUES ($1, $2)
+		RETURNING id
+	`
+
+	if err := sqlx.GetContext(ctx, tx, &id, query, email, ipAddress); err != nil {
+		return 0, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	return id, nil
+}
+
+// Succeed marks a login attempt succeeded
+func (r *LoginAttemptRepository) Succeed(ctx context.Context, id int64) error {
+	query := `UPDATE login_attempts SET succeeded = true WHERE id = $1`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Discard removes a login attempt that neither failed nor succeeded, such
+// as a correct password awaiting a two-factor code
+func (r *LoginAttemptRepository) Discard(ctx context.Context, id int64) error {
+	query := `DELETE FROM login_attempts WHERE id = $1`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to discard login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// failures counts the failed attempts for an email address within window
+// since its last successful login, including attempts still in progress,
+// and returns how long ago the last one was made
+func failures(ctx context.Context, q sqlx.QueryerContext, email string, window time.Duration) (int, time.Duration, error) {
+	var result struct {
+		Count     int     `db:"count"`
+		SinceLast float64 `db:"since_last"`
+	}
+
+	query := `
+		SELECT COUNT(*) AS count,
+		       COALESCE(EXTRACT(EPOCH FROM NOW() - MAX(created_at)), 0)::float8 AS since_last
+		FROM login_attempts
+		WHERE email = $1 AND succeeded = false
+		  AND created_at > NOW() - make_interval(secs => $2)
+		  AND created_at > (
+			SELECT COALESCE(MAX(created_at), '-infinity')
+			FROM login_attempts
+			WHERE email = $1 AND succeeded = true
+		  )
+	`
+
+	if err := sqlx.GetContext(ctx, q, &result, query, email, window.Seconds()); err != nil {
+		return 0, 0, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+
+	return result.Count, time.Duration(result.SinceLast * float64(time.Second)), nil
+}
+
+// DeleteOlderThan deletes attempts older than age
+func (r *LoginAttemptRepository) DeleteOlderThan(ctx context.Context, age time.Duration) (int64, error) {
+	query := `DELETE FROM login_attempts WHERE created_at < NOW() - make_interval(secs => $1)`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, age.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login attempts: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MappingTemplateRepository handles database operations for mapping templates
+type MappingTemplateRepository struct {
+	db *database.DB
+}
+
+// NewMappingTemplateRepository creates a new MappingTemplateRepository
+func NewMappingTemplateRepository(db *database.DB) *MappingTemplateRepository {
+	return &MappingTemplateRepository{db: db}
+}
+
+const mappingTemplateColumns = `
+	id, user_id, organization_id, name, description, table_name, table_fields, table_signature,
+	source_headers, header_signature, column_mapping, field_transformations,
+	options, shared, created_at, updated_at
+`
+
+// Create creates a new mapping template
+func (r *MappingTemplateRepository) Create(ctx context.Context, template *models.MappingTemplate) error {
+	query := `
+		INSERT INTO mapping_templates (
+			user_id, organization_id, name, description, table_name, table_fields, table_signature,
+			source_headers, header_signature, column_mapping, field_transformations,
+			options, shared
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		template.UserID,
+		template.OrganizationID,
+		template.Name,
+		template.Description,
+		template.TableName,
+		template.TableFields,
+		template.TableSignature,
+		template.SourceHeaders,
+		template.HeaderSignature,
+		template.ColumnMapping,
+		template.FieldTransformations,
+		template.Options,
+		template.Shared,
+	).Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+
+	if err != nil {
+		return mappingTemplateWriteError("create", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a mapping template of the workspace or shared by
+// another user
+func (r *MappingTemplateRepository) GetByID(ctx context.Context, id uuid.UUID, ws models.Workspace) (*models.MappingTemplate, error) {
+	var template models.MappingTemplate
+
+	query := `SELECT ` + mappingTemplateColumns + `
+		FROM mapping_templates
+		WHERE id = $1 AND (` + workspaceCondition(2, 3) + ` OR shared)
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &template, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("mapping template not found")
+		}
+		return nil, fmt.Errorf("failed to get mapping template: %w", err)
+	}
+
+	return &template, nil
+}
+
+// List lists the workspace's templates and those shared by others, by name
+func (r *MappingTemplateRepository) List(ctx context.Context, ws models.Workspace) ([]models.MappingTemplate, error) {
+	var templates []models.MappingTemplate
+
+	query := `SELECT ` + mappingTemplateColumns + `
+		FROM mapping_templates
+		WHERE ` + workspaceCondition(1, 2) + ` OR shared
+		ORDER BY name, created_at
+	`
+
+	err := r.db.Sqlx.SelectContext(ctx, &templates, query, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mapping templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// ListByHeaderSignature lists the templates visible in the workspace that
+// were made for data files with the given header signature, most recent
+// first
+func (r *MappingTemplateRepository) ListByHeaderSignature(ctx context.Context, ws models.Workspace, signature string) ([]models.MappingTemplate, error) {
+	var templates []models.MappingTemplate
+
+	query := `SELECT ` + mappingTemplateColumns + `
+		FROM mapping_templates
+		WHERE header_signature = $1 AND (` + workspaceCondition(2, 3) + ` OR shared)
+		ORDER BY updated_at DESC
+		LIMIT 20
+	`
+
+	err := r.db.Sqlx.SelectContext(ctx, &templates, query, signature, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mapping templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// Update replaces a mapping template of the workspace
+func (r *MappingTemplateRepository) Update(ctx context.Context, template *models.MappingTemplate, ws models.Workspace) error {
+	query := `
+		UPDATE mapping_templates
+		SET name = $1,
+		    description = $2,
+		    table_name = $3,
+		    table_fields = $4,
+		    table_signature = $5,
+		    source_headers = $6,
+		    header_signature = $7,
+		    column_mapping = $8,
+		    field_transformations = $9,
+		    options = $10,
+		    shared = $11
+		WHERE id = $12 AND ` + workspaceCondition(13, 14) + `
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		template.Name,
+		template.Description,
+		template.TableName,
+		template.TableFields,
+		template.TableSignature,
+		template.SourceHeaders,
+		template.HeaderSignature,
+		template.ColumnMapping,
+		template.FieldTransformations,
+		template.Options,
+		template.Shared,
+		template.ID,
+		ws.UserID,
+		ws.OrganizationID,
+	).Scan(&template.CreatedAt, &template.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("mapping template not found")
+		}
+		return mappingTemplateWriteError("update", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a mapping template of the workspace
+func (r *MappingTemplateRepository) Delete(ctx context.Context, id uuid.UUID, ws models.Workspace) error {
+	query := `DELETE FROM mapping_templates WHERE id = $1 AND ` + workspaceCondition(2, 3)
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete mapping template: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("mapping template not found")
+	}
+
+	return nil
+}
+
+// mappingTemplateWriteError reports duplicate names per workspace separately
+func mappingTemplateWriteError(action string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("mapping template name already exists")
+	}
+	return fmt.Errorf("failed to %s mapping template: %w", action, err)
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepository handles database operations for two-factor authentication:
+// TOTP secrets, recovery codes and sign-in challenges
+type MFARepository struct {
+	db *database.DB
+}
+
+// NewMFARepository creates a new MFARepository
+func NewMFARepository(db *database.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// GetTOTP retrieves the TOTP authenticator of a user
+func (r *MFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+
+	query := `
+		SELECT user_id, secret_encrypted, enabled_at, last_used_step, created_at, updated_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &totp, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("totp not found")
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+
+	return &totp, nil
+}
+
+// SavePendingTOTP stores a new secret awaiting confirmation, replacing an
+// earlier unconfirmed one. It returns false when the user already enabled
+// an authenticator.
+func (r *MFARepository) SavePendingTOTP(ctx context.Context, userID uuid.UUID, secretEncrypted string) (bool, error) {
+	query := `
+		INSERT INTO user_totp (user_id, secret_encrypted)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted, last_used_step = 0
+		WHERE user_totp.enabled_at IS NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, userID, secretEncrypted)
+	if err != nil {
+		return false, fmt.Errorf("failed to save totp: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// EnableTOTP enables the pending authenticator, recording step as used,
+// and replaces the user's recovery codes. Sessions and API tokens created
+// without it are revoked. It returns false when there was no pending
+// authenticator.
+func (r *MFARepository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) (bool, error) {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		WITH enabled AS (
+			UPDATE user_totp
+			SET enabled_at = NOW(), last_used_step = $2
+			WHERE user_id = $1 AND enabled_at IS NULL
+			RETURNING user_id
+		), removed AS (
+			DELETE FROM mfa_recovery_codes
+			WHERE user_id IN (SELECT user_id FROM enabled)
+		), added AS (
+			INSERT INTO mfa_recovery_codes (user_id, code_hash)
+			SELECT enabled.user_id, hash FROM enabled, unnest($3::text[]) AS hash
+		)
+		SELECT COUNT(*) FROM enabled
+	`
+
+	var count int
+	if err := tx.GetContext(ctx, &count, query, userID, step, codeHashes); err != nil {
+		return false, fmt.Errorf("failed to enable totp: %w", err)
+	}
+	if count != 1 {
+		return false, nil
+	}
+
+	if err := revokeAllRefreshTokens(ctx, tx, userID); err != nil {
+		return false, err
+	}
+	if err := revokeAllAPITokens(ctx, tx, userID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// UseStep records a code of the time step as used. It returns false when a
+// code of this or a later step was already used, so each code works once.
+func (r *MFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DisableTOTP removes the user's authenticator and recovery codes
+func (r *MFARepository) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	return deleteTOTP(ctx, r.db.Sqlx, userID)
+}
+
+// deleteTOTP removes the user's authenticator and recovery codes through q,
+// which may be a transaction
+func deleteTOTP(ctx context.Context, q sqlx.ExecerContext, userID uuid.UUID) error {
+	query := `
+		WITH codes AS (
+			DELETE FROM mfa_recovery_codes WHERE user_id = $1
+		)
+		DELETE FROM user_totp WHERE user_id = $1
+	`
+
+	if _, err := q.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of the user
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	query := `
+		WITH removed AS (
+			DELETE FROM mfa_recovery_codes WHERE user_id = $1
+		)
+		INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, hash FROM unnest($2::text[]) AS hash
+	`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, userID, codeHashes); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. It
+// returns false when the user has no such unused code.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of the user
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	if err := r.db.Sqlx.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateChallenge creates the second step of a sign-in
+func (r *MFARepository) CreateChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	query := `
+		INSERT INTO mfa_challenges (user_id, remember_me, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		challenge.UserID,
+		challenge.RememberMe,
+		challenge.ExpiresAt,
+	).Scan(&challenge.ID, &challenge.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	return nil
+}
+
+// AttemptChallenge counts an attempt at a pending challenge and returns it.
+// Used and expired challenges and those with maxAttempts attempts are not
+// found.
+func (r *MFARepository) AttemptChallenge(ctx context.Context, id uuid.UUID, maxAttempts int) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+
+	query := `
+		UPDATE mfa_challenges
+		SET attempts = attempts + 1
+		WHERE id = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < $2
+		RETURNING id, user_id, remember_me, attempts, expires_at, used_at, created_at
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &challenge, query, id, maxAttempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("mfa challenge not found")
+		}
+		return nil, fmt.Errorf("failed to attempt mfa challenge: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+// CompleteChallenge marks a challenge used. It returns false when it was
+// already used.
+func (r *MFARepository) CompleteChallenge(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE mfa_challenges SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete mfa challenge: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DeleteExpiredChallenges deletes challenges that expired
+func (r *MFARepository) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
+	query := `DELETE FROM mfa_challenges WHERE expires_at < NOW()`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
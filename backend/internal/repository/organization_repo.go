@@ -0,0 +1,548 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OrganizationRepository handles database operations for organizations,
+// their members and invitations
+type OrganizationRepository struct {
+	db *database.DB
+}
+
+// NewOrganizationRepository creates a new OrganizationRepository
+func NewOrganizationRepository(db *database.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Create creates a new organization with ownerID as its owner
+func (r *OrganizationRepository) Create(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error {
+	query := `
+		WITH created AS (
+			INSERT INTO organizations (name, created_by)
+			VALUES ($1, $2)
+			RETURNING id, plan, created_at, updated_at
+		), owner AS (
+			INSERT INTO organization_members (organization_id, user_id, role)
+			SELECT id, $2, 'owner' FROM created
+		)
+		SELECT id, plan, created_at, updated_at FROM created
+	`
+
+	org.CreatedBy = &ownerID
+	err := r.db.Sqlx.QueryRowContext(ctx, query, org.Name, ownerID).Scan(&org.ID, &org.Plan, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an organization by ID
+func (r *OrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+
+	query := `
+		SELECT id, name, plan, require_mfa, created_by, created_at, updated_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &org, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &org, nil
+}
+
+// Rename changes the name of an organization
+func (r *OrganizationRepository) Rename(ctx context.Context, id uuid.UUID, name string) error {
+	query := `UPDATE organizations SET name = $2 WHERE id = $1`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, name)
+	if err != nil {
+		return fmt.Errorf("failed to rename organization: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("organization not found")
+	}
+
+	return nil
+}
+
+// Delete deletes an organization together with its members, invitations
+// and everything it owns, and returns the object storage keys of its
+// imports, workflow sessions and jobs. The
+// organization is locked first, so no resources can be added to it while
+// the keys are collected.
+func (r *OrganizationRepository) Delete(ctx context.Context, id uuid.UUID) ([]string, error) {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	if err := tx.GetContext(ctx, &locked, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	keys, err := organizationStorageKeys(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to delete organization: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return keys, nil
+}
+
+// organizationStorageKeys lists the stored files of everything an
+// organization owns
+func organizationStorageKeys(ctx context.Context, q sqlx.QueryerContext, id uuid.UUID) ([]string, error) {
+	query := `
+		SELECT key FROM (
+			SELECT sql_storage_key AS key FROM imports WHERE organization_id = $1
+			UNION ALL
+			SELECT rollback_storage_key FROM imports WHERE organization_id = $1
+			UNION ALL
+			SELECT schema_storage_key FROM workflow_sessions WHERE organization_id = $1
+			UNION ALL
+			SELECT data_storage_key FROM workflow_sessions WHERE organization_id = $1
+			UNION ALL
+			SELECT result_storage_key FROM jobs WHERE organization_id = $1
+		) keys
+		WHERE key IS NOT NULL AND key <> ''
+	`
+
+	var keys []string
+	if err := sqlx.SelectContext(ctx, q, &keys, query, id); err != nil {
+		return nil, fmt.Errorf("failed to list stored files of organization: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ListByUserID lists the organizations a user belongs to and their role in
+// each, by name
+func (r *OrganizationRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]models.OrganizationMembership, error) {
+	query := `
+		SELECT o.id, o.name, m.role, o.plan, o.require_mfa
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.created_at
+	`
+
+	memberships := []models.OrganizationMembership{}
+	if err := r.db.Sqlx.SelectContext(ctx, &memberships, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// GetMemberRole returns the role of a user in an organization
+func (r *OrganizationRepository) GetMemberRole(ctx context.Context, orgID, userID uuid.UUID) (models.OrganizationRole, error) {
+	var role models.OrganizationRole
+
+	query := `SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`
+
+	err := r.db.Sqlx.GetContext(ctx, &role, query, orgID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("organization member not found")
+		}
+		return "", fmt.Errorf("failed to get organization member: %w", err)
+	}
+
+	return role, nil
+}
+
+// GetMemberAccess returns the role of a user in an organization, whether
+// the organization requires two-factor authentication and whether the user
+// enabled it
+func (r *OrganizationRepository) GetMemberAccess(ctx context.Context, orgID, userID uuid.UUID) (*models.MemberAccess, error) {
+	var access models.MemberAccess
+
+	query := `
+		SELECT m.role, o.require_mfa, t.enabled_at IS NOT NULL AS mfa_enabled
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		LEFT JOIN user_totp t ON t.user_id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &access, query, orgID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("organization member not found")
+		}
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+
+	return &access, nil
+}
+
+// GetMemberPlan returns the plan of an organization the user is a member of
+func (r *OrganizationRepository) GetMemberPlan(ctx context.Context, orgID, userID uuid.UUID) (string, error) {
+	var plan string
+
+	query := `
+		SELECT o.plan
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id = $1 AND m.user_id = $2
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &plan, query, orgID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("organization member not found")
+		}
+		return "", fmt.Errorf("failed to get organization plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+// SetRequireMFA sets whether an organization requires two-factor
+// authentication from its members
+func (r *OrganizationRepository) SetRequireMFA(ctx context.Context, id uuid.UUID, required bool) error {
+	query := `UPDATE organizations SET require_mfa = $2 WHERE id = $1`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, required)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("organization not found")
+	}
+
+	return nil
+}
+
+// ListRequiringMFA lists the names of the user's organizations that require
+// two-factor authentication
+func (r *OrganizationRepository) ListRequiringMFA(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT o.name
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1 AND o.require_mfa
+		ORDER BY o.name
+	`
+
+	names := []string{}
+	if err := r.db.Sqlx.SelectContext(ctx, &names, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	return names, nil
+}
+
+// ListSoleOwnedShared lists the names of the organizations the user is the
+// only owner of while others are members too; they would be left without
+// an owner if the user went away
+func (r *OrganizationRepository) ListSoleOwnedShared(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT o.name
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1 AND m.role = 'owner'
+		  AND NOT EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = o.id AND other.user_id <> $1 AND other.role = 'owner'
+		  )
+		  AND EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = o.id AND other.user_id <> $1
+		  )
+		ORDER BY o.name
+	`
+
+	names := []string{}
+	if err := r.db.Sqlx.SelectContext(ctx, &names, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	return names, nil
+}
+
+// ListSoleMember lists the IDs of the organizations whose only member is
+// the user
+func (r *OrganizationRepository) ListSoleMember(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT m.organization_id
+		FROM organization_members m
+		WHERE m.user_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = m.organization_id AND other.user_id <> $1
+		  )
+	`
+
+	ids := []uuid.UUID{}
+	if err := r.db.Sqlx.SelectContext(ctx, &ids, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DeleteIfEmpty deletes an organization that has no members left, with
+// everything it owns, and returns the storage keys of its files. It returns
+// false when it has members or is gone.
+func (r *OrganizationRepository) DeleteIfEmpty(ctx context.Context, id uuid.UUID) ([]string, bool, error) {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locked so that nobody joins while its files are listed
+	lockQuery := `
+		SELECT id FROM organizations o
+		WHERE o.id = $1
+		  AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = o.id)
+		FOR UPDATE
+	`
+
+	var locked uuid.UUID
+	if err := tx.GetContext(ctx, &locked, lockQuery, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	keys, err := organizationStorageKeys(ctx, tx, id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id); err != nil {
+		return nil, false, fmt.Errorf("failed to delete organization: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return keys, true, nil
+}
+
+// ListMembers lists the members of an organization, owners first
+func (r *OrganizationRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	query := `
+		SELECT m.user_id, u.email, u.first_name, u.last_name, m.role,
+		       t.enabled_at IS NOT NULL AS mfa_enabled, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		LEFT JOIN user_totp t ON t.user_id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 1 WHEN 'admin' THEN 2 WHEN 'editor' THEN 3 ELSE 4 END, u.email
+	`
+
+	members := []models.OrganizationMember{}
+	if err := r.db.Sqlx.SelectContext(ctx, &members, query, orgID); err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+// lastOwnerGuard keeps at least one owner in an organization: it holds
+// unless the member at $1/$2 is the only owner
+const lastOwnerGuard = `
+		(role <> 'owner' OR EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = $1 AND other.user_id <> $2 AND other.role = 'owner'
+		))
+`
+
+// UpdateMemberRole changes the role of a member. It returns false when the
+// member is the organization's only owner and would be demoted.
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID uuid.UUID, role models.OrganizationRole) (bool, error) {
+	query := `
+		UPDATE organization_members
+		SET role = $3::text
+		WHERE organization_id = $1 AND user_id = $2 AND ($3::text = 'owner' OR` + lastOwnerGuard + `)`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, orgID, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// RemoveMember removes a member from an organization. It returns false
+// when the member is the organization's only owner.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2 AND` + lastOwnerGuard
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+const invitationColumns = `id, organization_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at`
+
+// CreateInvitation creates a new invitation
+func (r *OrganizationRepository) CreateInvitation(ctx context.Context, inv *models.OrganizationInvitation) error {
+	query := `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		inv.OrganizationID,
+		inv.Email,
+		inv.Role,
+		inv.TokenHash,
+		inv.InvitedBy,
+		inv.ExpiresAt,
+	).Scan(&inv.ID, &inv.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return nil
+}
+
+// GetInvitationByTokenHash retrieves an invitation by the hash of its token
+func (r *OrganizationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.OrganizationInvitation, error) {
+	var inv models.OrganizationInvitation
+
+	query := `SELECT ` + invitationColumns + ` FROM organization_invitations WHERE token_hash = $1`
+
+	err := r.db.Sqlx.GetContext(ctx, &inv, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invitation not found")
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return &inv, nil
+}
+
+// ListPendingInvitations lists the invitations of an organization that were
+// neither accepted nor expired, newest first
+func (r *OrganizationRepository) ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	invitations := []*models.OrganizationInvitation{}
+	if err := r.db.Sqlx.SelectContext(ctx, &invitations, query, orgID); err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation marks an invitation accepted and adds the user to the
+// organization with the invited role, keeping the role of existing
+// members. It returns false when the invitation was accepted in the
+// meantime.
+func (r *OrganizationRepository) AcceptInvitation(ctx context.Context, inv *models.OrganizationInvitation, userID uuid.UUID) (bool, error) {
+	query := `
+		WITH accepted AS (
+			UPDATE organization_invitations
+			SET accepted_at = NOW()
+			WHERE id = $1 AND accepted_at IS NULL
+			RETURNING organization_id, role
+		), joined AS (
+			INSERT INTO organization_members (organization_id, user_id, role)
+			SELECT organization_id, $2, role FROM accepted
+			ON CONFLICT (organization_id, user_id) DO NOTHING
+		)
+		SELECT COUNT(*) FROM accepted
+	`
+
+	var accepted int
+	if err := r.db.Sqlx.GetContext(ctx, &accepted, query, inv.ID, userID); err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return accepted == 1, nil
+}
+
+// DeleteInvitation revokes a pending invitation of an organization
+func (r *OrganizationRepository) DeleteInvitation(ctx context.Context, orgID, id uuid.UUID) error {
+	query := `DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("invitation not found")
+	}
+
+	return nil
+}
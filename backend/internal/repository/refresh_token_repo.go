@@ -11,6 +11,7 @@ import (
 	"db-importer/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 // RefreshTokenRepository handles database operations for refresh tokens
@@ -23,12 +24,13 @@ func NewRefreshTokenRepository(db *database.DB) *RefreshTokenRepository {
 	return &RefreshTokenRepository{db: db}
 }
 
-// Create creates a new refresh token
+// Create creates a new refresh token. A token without SignedInAt starts a
+// new session.
 func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
 	query := `
-		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, revoked
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at, user_agent, ip_address, signed_in_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, NOW()))
+		RETURNING id, created_at, revoked, signed_in_at, last_used_at
 	`
 
 	err := r.db.Sqlx.QueryRowContext(
@@ -37,7 +39,10 @@ func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.Refre
 		token.UserID,
 		token.TokenHash,
 		token.ExpiresAt,
-	).Scan(&token.ID, &token.CreatedAt, &token.Revoked)
+		token.UserAgent,
+		token.IPAddress,
+		token.SignedInAt,
+	).Scan(&token.ID, &token.CreatedAt, &token.Revoked, &token.SignedInAt, &token.LastUsedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create refresh token: %w", err)
@@ -51,7 +56,8 @@ func (r *RefreshTokenRepository) GetByTokenHash(ctx context.Context, tokenHash s
 	var token models.RefreshToken
 
 	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked, revoked_at
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked, revoked_at,
+		       user_agent, ip_address, signed_in_at, last_used_at
 		FROM refresh_tokens
 		WHERE token_hash = $1
 	`
@@ -72,7 +78,8 @@ func (r *RefreshTokenRepository) GetByUserID(ctx context.Context, userID uuid.UU
 	var tokens []*models.RefreshToken
 
 	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked, revoked_at
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked, revoked_at,
+		       user_agent, ip_address, signed_in_at, last_used_at
 		FROM refresh_tokens
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -86,6 +93,74 @@ func (r *RefreshTokenRepository) GetByUserID(ctx context.Context, userID uuid.UU
 	return tokens, nil
 }
 
+// ListActiveByUserID retrieves the unrevoked, unexpired refresh tokens of a
+// user, most recently used first
+func (r *RefreshTokenRepository) ListActiveByUserID(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
+	tokens := []*models.RefreshToken{}
+
+	query := `
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked, revoked_at,
+		       user_agent, ip_address, signed_in_at, last_used_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked = false AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	err := r.db.Sqlx.SelectContext(ctx, &tokens, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refresh tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeByID revokes an unrevoked refresh token of a user
+func (r *RefreshTokenRepository) RevokeByID(ctx context.Context, userID, id uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true, revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked = false
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("refresh token not found")
+	}
+
+	return nil
+}
+
+// RevokeAllExcept revokes all refresh tokens of a user but the one with
+// keepHash and returns how many were revoked
+func (r *RefreshTokenRepository) RevokeAllExcept(ctx context.Context, userID uuid.UUID, keepHash string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true, revoked_at = NOW()
+		WHERE user_id = $1 AND revoked = false AND token_hash <> $2
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, userID, keepHash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
 // Revoke revokes a refresh token
 func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenHash string) error {
 	query := `
@@ -113,13 +188,19 @@ func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenHash string) e
 
 // RevokeAllForUser revokes all refresh tokens for a user
 func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	return revokeAllRefreshTokens(ctx, r.db.Sqlx, userID)
+}
+
+// revokeAllRefreshTokens revokes every refresh token of a user through q,
+// which may be a transaction
+func revokeAllRefreshTokens(ctx context.Context, q sqlx.ExecerContext, userID uuid.UUID) error {
 	query := `
 		UPDATE refresh_tokens
 		SET revoked = true, revoked_at = NOW()
 		WHERE user_id = $1 AND revoked = false
 	`
 
-	_, err := r.db.Sqlx.ExecContext(ctx, query, userID)
+	_, err := q.ExecContext(ctx, query, userID)
 	if err != nil {
 		return fmt.Errorf("failed to revoke all refresh tokens: %w", err)
 	}
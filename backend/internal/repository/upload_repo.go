@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// UploadRepository handles database operations for chunked uploads
+type UploadRepository struct {
+	db *database.DB
+}
+
+// NewUploadRepository creates a new UploadRepository
+func NewUploadRepository(db *database.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// Create starts a new upload session
+func (r *UploadRepository) Create(ctx context.Context, upload *models.Upload) error {
+	query := `
+		INSERT INTO uploads (user_id, file_name, purpose, total_size, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, received_bytes, created_at, updated_at
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		upload.UserID,
+		upload.FileName,
+		upload.Purpose,
+		upload.TotalSize,
+		upload.ExpiresAt,
+	).Scan(&upload.ID, &upload.Status, &upload.ReceivedBytes, &upload.CreatedAt, &upload.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an unexpired upload owned by the user
+func (r *UploadRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Upload, error) {
+	var upload models.Upload
+
+	query := `
+		SELECT id, user_id, file_name, purpose, status, total_size, received_bytes,
+		       parts, checksum, storage_key, expires_at, completed_at, created_at, updated_at
+		FROM uploads
+		WHERE id = $1 AND user_id = $2 AND expires_at > NOW()
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &upload, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("upload not found")
+		}
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// AppendPart records a stored chunk if the upload is still at offset. It
+// returns false when another chunk was recorded first.
+func (r *UploadRepository) AppendPart(ctx context.Context, id uuid.UUID, offset int64, part models.UploadPart) (bool, error) {
+	encoded, err := json.Marshal([]models.UploadPart{part})
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE uploads
+		SET received_bytes = received_bytes + $3, parts = parts || $4::jsonb
+		WHERE id = $1 AND received_bytes = $2 AND status = 'uploading'
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, offset, part.Size, string(encoded))
+	if err != nil {
+		return false, fmt.Errorf("failed to record upload chunk: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// MarkCompleted records the assembled file of an upload
+func (r *UploadRepository) MarkCompleted(ctx context.Context, id uuid.UUID, storageKey, checksum string, expiresAt time.Time) error {
+	query := `
+		UPDATE uploads
+		SET status = 'completed', storage_key = $2, checksum = $3,
+		    total_size = received_bytes, parts = '[]'::jsonb,
+		    completed_at = NOW(), expires_at = $4
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id, storageKey, checksum, expiresAt); err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed marks an upload as failed
+func (r *UploadRepository) MarkFailed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE uploads SET status = 'failed', parts = '[]'::jsonb WHERE id = $1`
+
+	if _, err := r.db.Sqlx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update upload: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes an upload owned by the user
+func (r *UploadRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM uploads WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("upload not found")
+	}
+
+	return nil
+}
+
+// ListExpiredIDs returns up to limit expired uploads
+func (r *UploadRepository) ListExpiredIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	query := `SELECT id FROM uploads WHERE expires_at < NOW() ORDER BY expires_at LIMIT $1`
+
+	if err := r.db.Sqlx.SelectContext(ctx, &ids, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list expired uploads: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListIDsByUserID lists the IDs of all uploads of a user
+func (r *UploadRepository) ListIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	query := `SELECT id FROM uploads WHERE user_id = $1`
+
+	if err := r.db.Sqlx.SelectContext(ctx, &ids, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DeleteByID deletes an upload regardless of owner (used by cleanup)
+func (r *UploadRepository) DeleteByID(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Sqlx.ExecContext(ctx, `DELETE FROM uploads WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"db-importer/internal/database"
+	"db-importer/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UserIdentityRepository handles database operations for accounts at
+// OpenID Connect providers linked to users
+type UserIdentityRepository struct {
+	db *database.DB
+}
+
+// NewUserIdentityRepository creates a new UserIdentityRepository
+func NewUserIdentityRepository(db *database.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+// GetUser retrieves the user linked to the provider account, active or not
+func (r *UserIdentityRepository) GetUser(ctx context.Context, issuer, subject string) (*models.User, error) {
+	var user models.User
+
+	query := `
+		SELECT u.id, u.email, u.password_hash, u.first_name, u.last_name, u.is_active,
+		       u.email_verified, u.created_at, u.updated_at, u.last_login_at, u.deletion_scheduled_at
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &user, query, issuer, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("identity not found")
+		}
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CreateWithUser creates a user without a password together with their
+// provider account
+func (r *UserIdentityRepository) CreateWithUser(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	query := `
+		WITH new_user AS (
+			INSERT INTO users (email, password_hash, first_name, last_name, email_verified)
+			VALUES ($1, '', $2, $3, $4)
+			RETURNING id, created_at, updated_at, is_active, email_verified
+		), identity AS (
+			INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+			SELECT id, $5, $6, $1, NOW() FROM new_user
+		)
+		SELECT id, created_at, updated_at, is_active, email_verified FROM new_user
+	`
+
+	err := r.db.Sqlx.QueryRowContext(
+		ctx,
+		query,
+		user.Email,
+		user.FirstName,
+		user.LastName,
+		user.EmailVerified,
+		identity.Issuer,
+		identity.Subject,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.EmailVerified)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == "user_identities_issuer_subject_key" {
+				return fmt.Errorf("identity already linked")
+			}
+			return fmt.Errorf("email already exists")
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	identity.UserID = user.ID
+	return nil
+}
+
+// Link links a provider account with a verified email address to the
+// existing user with that address and marks the address verified. If it
+// was not verified before, whoever registered the address did not prove
+// they own it and must not keep access: in the same transaction the
+// password is cleared, refresh tokens and personal access tokens are
+// revoked, and the authenticator and recovery codes are removed.
+func (r *UserIdentityRepository) Link(ctx context.Context, identity *models.UserIdentity) error {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var verified bool
+	if err := tx.GetContext(ctx, &verified, `SELECT email_verified FROM users WHERE id = $1 FOR UPDATE`, identity.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("identity already linked")
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	if !verified {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET email_verified = true, password_hash = '' WHERE id = $1`, identity.UserID); err != nil {
+			return fmt.Errorf("failed to link identity: %w", err)
+		}
+		if err := revokeAllRefreshTokens(ctx, tx, identity.UserID); err != nil {
+			return err
+		}
+		if err := revokeAllAPITokens(ctx, tx, identity.UserID); err != nil {
+			return err
+		}
+		if err := deleteTOTP(ctx, tx, identity.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+// RecordLogin stores the time of a sign-in through the provider account and
+// the email address the provider reported
+func (r *UserIdentityRepository) RecordLogin(ctx context.Context, issuer, subject, email string) error {
+	query := `
+		UPDATE user_identities
+		SET last_login_at = NOW(), email = NULLIF($3, '')
+		WHERE issuer = $1 AND subject = $2
+	`
+
+	_, err := r.db.Sqlx.ExecContext(ctx, query, issuer, subject, email)
+	if err != nil {
+		return fmt.Errorf("failed to record identity login: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"db-importer/internal/database"
 	"db-importer/internal/models"
@@ -58,7 +59,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 
 	query := `
 		SELECT id, email, password_hash, first_name, last_name, is_active,
-		       email_verified, created_at, updated_at, last_login_at
+		       email_verified, created_at, updated_at, last_login_at, deletion_scheduled_at
 		FROM users
 		WHERE id = $1 AND is_active = true
 	`
@@ -80,7 +81,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 
 	query := `
 		SELECT id, email, password_hash, first_name, last_name, is_active,
-		       email_verified, created_at, updated_at, last_login_at
+		       email_verified, created_at, updated_at, last_login_at, deletion_scheduled_at
 		FROM users
 		WHERE email = $1
 	`
@@ -166,6 +167,146 @@ func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// ScheduleDeletion schedules the deletion of a user after grace and
+// returns when it will happen. A deletion already scheduled keeps its time.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, grace time.Duration) (time.Time, error) {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = COALESCE(deletion_scheduled_at, NOW() + make_interval(secs => $2))
+		WHERE id = $1 AND is_active = true
+		RETURNING deletion_scheduled_at
+	`
+
+	var scheduledAt time.Time
+	err := r.db.Sqlx.GetContext(ctx, &scheduledAt, query, id, grace.Seconds())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("user not found")
+		}
+		return time.Time{}, fmt.Errorf("failed to schedule user deletion: %w", err)
+	}
+
+	return scheduledAt, nil
+}
+
+// CancelDeletion cancels the scheduled deletion of a user. It returns false
+// when none was scheduled.
+func (r *UserRepository) CancelDeletion(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`
+
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel user deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ListDueForDeletion lists up to limit users whose scheduled deletion time
+// has passed
+func (r *UserRepository) ListDueForDeletion(ctx context.Context, limit int) ([]*models.User, error) {
+	query := `
+		SELECT id, email, password_hash, first_name, last_name, is_active,
+		       email_verified, created_at, updated_at, last_login_at, deletion_scheduled_at
+		FROM users
+		WHERE deletion_scheduled_at <= NOW()
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+	`
+
+	var users []*models.User
+	if err := r.db.Sqlx.SelectContext(ctx, &users, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list users due for deletion: %w", err)
+	}
+
+	return users, nil
+}
+
+// organizationOwnedTables hold resources that belong to an organization
+// when organization_id is set, and to their creator otherwise
+var organizationOwnedTables = []string{"imports", "mapping_templates", "connection_profiles", "workflow_sessions", "jobs"}
+
+// Purge deletes a user for good, provided their deletion is still due.
+// What they created in organizations with other members is handed to the
+// member with the highest role there; imports, workflow sessions, refresh
+// tokens and everything else the user owns are deleted with them. It
+// returns the storage keys of the deleted files, and false when the
+// deletion was canceled in the meantime.
+func (r *UserRepository) Purge(ctx context.Context, id uuid.UUID) ([]string, bool, error) {
+	tx, err := r.db.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	lockQuery := `SELECT id FROM users WHERE id = $1 AND deletion_scheduled_at <= NOW() FOR UPDATE`
+	if err := tx.GetContext(ctx, &locked, lockQuery, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	for _, table := range organizationOwnedTables {
+		query := `
+			UPDATE ` + table + ` t
+			SET user_id = successor.user_id
+			FROM (
+				SELECT DISTINCT ON (organization_id) organization_id, user_id
+				FROM organization_members
+				WHERE user_id <> $1
+				ORDER BY organization_id,
+				         CASE role WHEN 'owner' THEN 1 WHEN 'admin' THEN 2 WHEN 'editor' THEN 3 ELSE 4 END,
+				         created_at
+			) successor
+			WHERE t.user_id = $1 AND t.organization_id = successor.organization_id
+		`
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return nil, false, fmt.Errorf("failed to hand over %s: %w", table, err)
+		}
+	}
+
+	keysQuery := `
+		SELECT key FROM (
+			SELECT sql_storage_key AS key FROM imports WHERE user_id = $1
+			UNION ALL
+			SELECT rollback_storage_key FROM imports WHERE user_id = $1
+			UNION ALL
+			SELECT schema_storage_key FROM workflow_sessions WHERE user_id = $1
+			UNION ALL
+			SELECT data_storage_key FROM workflow_sessions WHERE user_id = $1
+			UNION ALL
+			SELECT result_storage_key FROM jobs WHERE user_id = $1
+		) keys
+		WHERE key IS NOT NULL AND key <> ''
+	`
+
+	var keys []string
+	if err := tx.SelectContext(ctx, &keys, keysQuery, id); err != nil {
+		return nil, false, fmt.Errorf("failed to list stored files of user: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id); err != nil {
+		return nil, false, fmt.Errorf("failed to purge user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return keys, true, nil
+}
+
 // EmailExists checks if an email already exists
 func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
@@ -22,19 +22,25 @@ func NewWorkflowSessionRepository(db *database.DB) *WorkflowSessionRepository {
 	return &WorkflowSessionRepository{db: db}
 }
 
-// GetByUserID retrieves the active workflow session for a user
-func (r *WorkflowSessionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*models.WorkflowSession, error) {
+// sessionColumns are the columns read into a models.WorkflowSession
+const sessionColumns = `id, user_id, organization_id, name, current_step, version, schema_content, schema_storage_key, schema_tables, dialect,
+		       selected_table_name, data_file_name, data_headers, sample_data, data_storage_key, data_row_count,
+		       column_mapping, field_transformations, expires_at, created_at, updated_at`
+
+// GetLatest retrieves the most recently updated active workflow session
+// the user started in the workspace
+func (r *WorkflowSessionRepository) GetLatest(ctx context.Context, ws models.Workspace) (*models.WorkflowSession, error) {
 	var session models.WorkflowSession
 
 	query := `
-		SELECT id, user_id, current_step, schema_content, schema_tables,
-		       selected_table_name, data_file_name, data_headers, sample_data,
-		       column_mapping, field_transformations, expires_at, created_at, updated_at
+		SELECT ` + sessionColumns + `
 		FROM workflow_sessions
-		WHERE user_id = $1 AND expires_at > NOW()
+		WHERE user_id = $1 AND organization_id IS NOT DISTINCT FROM $2::uuid AND expires_at > NOW()
+		ORDER BY updated_at DESC
+		LIMIT 1
 	`
 
-	err := r.db.Sqlx.GetContext(ctx, &session, query, userID)
+	err := r.db.Sqlx.GetContext(ctx, &session, query, ws.UserID, ws.OrganizationID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // No session found is not an error
@@ -45,36 +51,122 @@ func (r *WorkflowSessionRepository) GetByUserID(ctx context.Context, userID uuid
 	return &session, nil
 }
 
-// Create creates a new workflow session (replaces existing one for user)
-func (r *WorkflowSessionRepository) Create(ctx context.Context, session *models.WorkflowSession) error {
-	// Delete existing session first (due to unique constraint on user_id)
-	_, _ = r.db.Sqlx.ExecContext(ctx, "DELETE FROM workflow_sessions WHERE user_id = $1", session.UserID)
+// GetByID retrieves an active workflow session of the workspace by ID
+func (r *WorkflowSessionRepository) GetByID(ctx context.Context, id uuid.UUID, ws models.Workspace) (*models.WorkflowSession, error) {
+	var session models.WorkflowSession
+
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM workflow_sessions
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + ` AND expires_at > NOW()
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &session, query, id, ws.UserID, ws.OrganizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // No session found is not an error
+		}
+		return nil, fmt.Errorf("failed to get workflow session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// List lists the active workflow sessions of the workspace, most recently
+// updated first. Schema content, sample data and mappings are not read.
+func (r *WorkflowSessionRepository) List(ctx context.Context, ws models.Workspace) ([]*models.WorkflowSession, error) {
+	query := `
+		SELECT id, user_id, organization_id, name, current_step, version, dialect, selected_table_name,
+		       data_file_name, data_row_count, expires_at, created_at, updated_at
+		FROM workflow_sessions
+		WHERE ` + workspaceCondition(1, 2) + ` AND expires_at > NOW()
+		ORDER BY updated_at DESC
+	`
+
+	var sessions []*models.WorkflowSession
+	if err := r.db.Sqlx.SelectContext(ctx, &sessions, query, ws.UserID, ws.OrganizationID); err != nil {
+		return nil, fmt.Errorf("failed to list workflow sessions: %w", err)
+	}
 
+	return sessions, nil
+}
+
+// ListByUserAfter retrieves up to limit workflow sessions the user started,
+// in any workspace and including expired ones not yet deleted, with IDs
+// above afterID in ID order
+func (r *WorkflowSessionRepository) ListByUserAfter(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*models.WorkflowSession, error) {
 	query := `
-		INSERT INTO workflow_sessions (
-			user_id, current_step, schema_content, schema_tables,
-			selected_table_name, data_file_name, data_headers, sample_data,
-			column_mapping, field_transformations, expires_at
+		SELECT ` + sessionColumns + `
+		FROM workflow_sessions
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	var sessions []*models.WorkflowSession
+	if err := r.db.Sqlx.SelectContext(ctx, &sessions, query, userID, afterID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list workflow sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// CountByUserID counts the active workflow sessions a user started, in any
+// workspace
+func (r *WorkflowSessionRepository) CountByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM workflow_sessions WHERE user_id = $1 AND expires_at > NOW()`
+	if err := r.db.Sqlx.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count workflow sessions: %w", err)
+	}
+	return count, nil
+}
+
+// Create creates a new workflow session at version 1 and records it in the
+// session history
+func (r *WorkflowSessionRepository) Create(ctx context.Context, session *models.WorkflowSession, change models.WorkflowSessionChange) error {
+	query := `
+		WITH written AS (
+			INSERT INTO workflow_sessions (
+				user_id, organization_id, name, current_step, schema_content, schema_storage_key, schema_tables,
+				dialect, selected_table_name, data_file_name, data_headers, sample_data, data_storage_key,
+				data_row_count, column_mapping, field_transformations, expires_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			RETURNING id, version, current_step, selected_table_name, column_mapping, field_transformations,
+			          created_at, updated_at
+		), recorded AS (
+			INSERT INTO workflow_session_history (
+				session_id, version, change, current_step, selected_table_name, column_mapping, field_transformations
+			)
+			SELECT id, version, $18::text, current_step, selected_table_name, column_mapping, field_transformations
+			FROM written
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, created_at, updated_at
+		SELECT id, version, created_at, updated_at FROM written
 	`
 
 	err := r.db.Sqlx.QueryRowContext(
 		ctx,
 		query,
 		session.UserID,
+		session.OrganizationID,
+		session.Name,
 		session.CurrentStep,
 		session.SchemaContent,
+		session.SchemaStorageKey,
 		session.SchemaTables,
+		session.Dialect,
 		session.SelectedTableName,
 		session.DataFileName,
 		session.DataHeaders,
 		session.SampleData,
+		session.DataStorageKey,
+		session.DataRowCount,
 		session.ColumnMapping,
 		session.FieldTransformations,
 		session.ExpiresAt,
-	).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+		string(change),
+	).Scan(&session.ID, &session.Version, &session.CreatedAt, &session.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create workflow session: %w", err)
@@ -83,22 +175,41 @@ func (r *WorkflowSessionRepository) Create(ctx context.Context, session *models.
 	return nil
 }
 
-// Update updates an existing workflow session
-func (r *WorkflowSessionRepository) Update(ctx context.Context, session *models.WorkflowSession) error {
+// Update updates an existing workflow session, read with GetByID, if it is
+// still at the version it was read at, increments the version and records
+// the change in the session history. It returns false when the session was
+// changed or deleted in the meantime.
+func (r *WorkflowSessionRepository) Update(ctx context.Context, session *models.WorkflowSession, change models.WorkflowSessionChange) (bool, error) {
 	query := `
-		UPDATE workflow_sessions
-		SET current_step = $1,
-		    schema_content = $2,
-		    schema_tables = $3,
-		    selected_table_name = $4,
-		    data_file_name = $5,
-		    data_headers = $6,
-		    sample_data = $7,
-		    column_mapping = $8,
-		    field_transformations = $9,
-		    expires_at = $10
-		WHERE id = $11 AND user_id = $12
-		RETURNING updated_at
+		WITH written AS (
+			UPDATE workflow_sessions
+			SET current_step = $1,
+			    schema_content = $2,
+			    schema_storage_key = $3,
+			    schema_tables = $4,
+			    dialect = $5,
+			    selected_table_name = $6,
+			    data_file_name = $7,
+			    data_headers = $8,
+			    sample_data = $9,
+			    column_mapping = $10,
+			    field_transformations = $11,
+			    expires_at = $12,
+			    name = $13,
+			    data_storage_key = $14,
+			    data_row_count = $15,
+			    version = version + 1
+			WHERE id = $16 AND version = $17
+			RETURNING id, version, current_step, selected_table_name, column_mapping, field_transformations,
+			          updated_at
+		), recorded AS (
+			INSERT INTO workflow_session_history (
+				session_id, version, change, current_step, selected_table_name, column_mapping, field_transformations
+			)
+			SELECT id, version, $18::text, current_step, selected_table_name, column_mapping, field_transformations
+			FROM written
+		)
+		SELECT version, updated_at FROM written
 	`
 
 	err := r.db.Sqlx.QueryRowContext(
@@ -106,7 +217,9 @@ func (r *WorkflowSessionRepository) Update(ctx context.Context, session *models.
 		query,
 		session.CurrentStep,
 		session.SchemaContent,
+		session.SchemaStorageKey,
 		session.SchemaTables,
+		session.Dialect,
 		session.SelectedTableName,
 		session.DataFileName,
 		session.DataHeaders,
@@ -114,110 +227,201 @@ func (r *WorkflowSessionRepository) Update(ctx context.Context, session *models.
 		session.ColumnMapping,
 		session.FieldTransformations,
 		session.ExpiresAt,
+		session.Name,
+		session.DataStorageKey,
+		session.DataRowCount,
 		session.ID,
-		session.UserID,
-	).Scan(&session.UpdatedAt)
+		session.Version,
+		string(change),
+	).Scan(&session.Version, &session.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("workflow session not found")
+			return false, nil
 		}
-		return fmt.Errorf("failed to update workflow session: %w", err)
+		return false, fmt.Errorf("failed to update workflow session: %w", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-// UpdateStep updates only the current step
-func (r *WorkflowSessionRepository) UpdateStep(ctx context.Context, userID uuid.UUID, step int) error {
+// ListHistory lists the history of a session, newest change first
+func (r *WorkflowSessionRepository) ListHistory(ctx context.Context, sessionID uuid.UUID) ([]*models.WorkflowSessionHistoryEntry, error) {
+	query := `
+		SELECT id, session_id, version, change, current_step, selected_table_name, column_mapping,
+		       field_transformations, created_at
+		FROM workflow_session_history
+		WHERE session_id = $1
+		ORDER BY version DESC
+	`
+
+	var entries []*models.WorkflowSessionHistoryEntry
+	if err := r.db.Sqlx.SelectContext(ctx, &entries, query, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to list workflow session history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetHistoryEntry retrieves the history entry of a session version
+func (r *WorkflowSessionRepository) GetHistoryEntry(ctx context.Context, sessionID uuid.UUID, version int) (*models.WorkflowSessionHistoryEntry, error) {
+	var entry models.WorkflowSessionHistoryEntry
+
+	query := `
+		SELECT id, session_id, version, change, current_step, selected_table_name, column_mapping,
+		       field_transformations, created_at
+		FROM workflow_session_history
+		WHERE session_id = $1 AND version = $2
+	`
+
+	err := r.db.Sqlx.GetContext(ctx, &entry, query, sessionID, version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get workflow session history: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// UpdateStep updates only the current step of a session of the workspace
+// if it is still at version. It returns false when the session was
+// changed, deleted or expired in the meantime.
+func (r *WorkflowSessionRepository) UpdateStep(ctx context.Context, id uuid.UUID, ws models.Workspace, version int, step int) (bool, error) {
 	query := `
 		UPDATE workflow_sessions
-		SET current_step = $1
-		WHERE user_id = $2 AND expires_at > NOW()
+		SET current_step = $1, version = version + 1
+		WHERE id = $2 AND ` + workspaceCondition(3, 4) + ` AND version = $5 AND expires_at > NOW()
 	`
 
-	result, err := r.db.Sqlx.ExecContext(ctx, query, step, userID)
+	result, err := r.db.Sqlx.ExecContext(ctx, query, step, id, ws.UserID, ws.OrganizationID, version)
 	if err != nil {
-		return fmt.Errorf("failed to update workflow step: %w", err)
+		return false, fmt.Errorf("failed to update workflow step: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("workflow session not found or expired")
-	}
+	return rowsAffected == 1, nil
+}
 
-	return nil
+// sessionStorageKeys are the object storage keys of a deleted session
+type sessionStorageKeys struct {
+	Schema string `db:"schema_storage_key"`
+	Data   string `db:"data_storage_key"`
 }
 
-// Delete deletes a workflow session
-func (r *WorkflowSessionRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+// Delete deletes a workflow session of the workspace if it is still at
+// version and returns the storage keys of its schema content and data
+// file, if it has them. It returns false when the session was changed or
+// deleted in the meantime.
+func (r *WorkflowSessionRepository) Delete(ctx context.Context, id uuid.UUID, ws models.Workspace, version int) ([]string, bool, error) {
 	query := `
 		DELETE FROM workflow_sessions
-		WHERE user_id = $1
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + ` AND version = $4
+		RETURNING COALESCE(schema_storage_key, '') AS schema_storage_key,
+		          COALESCE(data_storage_key, '') AS data_storage_key
 	`
 
-	result, err := r.db.Sqlx.ExecContext(ctx, query, userID)
+	var keys sessionStorageKeys
+	err := r.db.Sqlx.GetContext(ctx, &keys, query, id, ws.UserID, ws.OrganizationID, version)
 	if err != nil {
-		return fmt.Errorf("failed to delete workflow session: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to delete workflow session: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return nonEmpty([]string{keys.Schema, keys.Data}), true, nil
+}
+
+// DeleteExpired deletes all expired workflow sessions and returns how many
+// were deleted and the storage keys of their schema content and data files
+func (r *WorkflowSessionRepository) DeleteExpired(ctx context.Context) (int64, []string, error) {
+	query := `
+		DELETE FROM workflow_sessions
+		WHERE expires_at <= NOW()
+		RETURNING COALESCE(schema_storage_key, '') AS schema_storage_key,
+		          COALESCE(data_storage_key, '') AS data_storage_key
+	`
+
+	var deletedKeys []sessionStorageKeys
+	err := r.db.Sqlx.SelectContext(ctx, &deletedKeys, query)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, nil, fmt.Errorf("failed to delete expired workflow sessions: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("workflow session not found")
+	keys := make([]string, 0, 2*len(deletedKeys))
+	for _, k := range deletedKeys {
+		keys = append(keys, k.Schema, k.Data)
 	}
+	return int64(len(deletedKeys)), nonEmpty(keys), nil
+}
 
-	return nil
+// ListLegacySchemas returns up to limit sessions whose schema content is
+// still stored compressed in the schema_content column
+func (r *WorkflowSessionRepository) ListLegacySchemas(ctx context.Context, limit int) ([]*models.WorkflowSession, error) {
+	query := `
+		SELECT id, user_id, schema_content
+		FROM workflow_sessions
+		WHERE schema_content IS NOT NULL AND schema_content <> '' AND schema_storage_key IS NULL
+		ORDER BY created_at
+		LIMIT $1
+	`
+
+	var sessions []*models.WorkflowSession
+	if err := r.db.Sqlx.SelectContext(ctx, &sessions, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list sessions with legacy schema content: %w", err)
+	}
+
+	return sessions, nil
 }
 
-// DeleteExpired deletes all expired workflow sessions
-func (r *WorkflowSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+// SetSchemaObject points a session at its schema content in object storage
+// and drops the compressed copy. It returns false when the session was
+// deleted or given new schema content in the meantime.
+func (r *WorkflowSessionRepository) SetSchemaObject(ctx context.Context, id uuid.UUID, storageKey string) (bool, error) {
 	query := `
-		DELETE FROM workflow_sessions
-		WHERE expires_at <= NOW()
+		UPDATE workflow_sessions
+		SET schema_storage_key = $2, schema_content = NULL
+		WHERE id = $1 AND schema_storage_key IS NULL
 	`
 
-	result, err := r.db.Sqlx.ExecContext(ctx, query)
+	result, err := r.db.Sqlx.ExecContext(ctx, query, id, storageKey)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete expired workflow sessions: %w", err)
+		return false, fmt.Errorf("failed to update workflow session: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return rowsAffected, nil
+	return rowsAffected == 1, nil
 }
 
-// ExtendExpiration extends the expiration time of a session
-func (r *WorkflowSessionRepository) ExtendExpiration(ctx context.Context, userID uuid.UUID, days int) error {
+// ExtendExpiration extends the expiration time of a session of the
+// workspace if it is still at version. It returns false when the session
+// was changed, deleted or expired in the meantime.
+func (r *WorkflowSessionRepository) ExtendExpiration(ctx context.Context, id uuid.UUID, ws models.Workspace, version int, days int) (bool, error) {
 	query := `
 		UPDATE workflow_sessions
 		SET expires_at = NOW() + INTERVAL '%d days'
-		WHERE user_id = $1 AND expires_at > NOW()
+		WHERE id = $1 AND ` + workspaceCondition(2, 3) + ` AND version = $4 AND expires_at > NOW()
 	`
 
-	result, err := r.db.Sqlx.ExecContext(ctx, fmt.Sprintf(query, days), userID)
+	result, err := r.db.Sqlx.ExecContext(ctx, fmt.Sprintf(query, days), id, ws.UserID, ws.OrganizationID, version)
 	if err != nil {
-		return fmt.Errorf("failed to extend expiration: %w", err)
+		return false, fmt.Errorf("failed to extend expiration: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("workflow session not found or expired")
-	}
-
-	return nil
+	return rowsAffected == 1, nil
 }
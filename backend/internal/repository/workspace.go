@@ -0,0 +1,14 @@
+package repository
+
+import "fmt"
+
+// workspaceCondition restricts a query to the rows of a workspace: the
+// user's personal rows, or every row of the organization whoever created
+// it. userArg and orgArg are the positions of the parameters bound to the
+// workspace's UserID and OrganizationID.
+func workspaceCondition(userArg, orgArg int) string {
+	return fmt.Sprintf(
+		"(organization_id IS NOT DISTINCT FROM $%[2]d::uuid AND ($%[2]d::uuid IS NOT NULL OR user_id = $%[1]d))",
+		userArg, orgArg,
+	)
+}
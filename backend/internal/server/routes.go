@@ -1,6 +1,9 @@
 package server
 
 import (
+	"db-importer/internal/models"
+	"db-importer/internal/service"
+	"db-importer/internal/utils"
 	"db-importer/logger"
 	"db-importer/middleware"
 	"net/http"
@@ -32,24 +35,35 @@ func (s *Server) setupPublicRoutes(mux *http.ServeMux) {
 	// Main endpoints with CORS, logging, and rate limiting
 	corsAndLog := s.withCORS(s.withLogging)
 
+	// Schema format listing is cheap and not rate limited
+	mux.HandleFunc("/schema-formats", corsAndLog(s.publicHandler.SchemaFormats))
+
 	if s.config.RateLimitEnabled {
 		// With rate limiting
 		if s.db != nil {
-			// With optional auth for differentiated rate limiting
-			mux.HandleFunc("/parse-schema", corsAndLog(s.withOptionalAuth(s.withRateLimit(s.publicHandler.ParseSchema))))
-			mux.HandleFunc("/generate-sql", corsAndLog(s.withOptionalAuth(s.withRateLimit(s.publicHandler.GenerateSQL))))
-			mux.HandleFunc("/validate", corsAndLog(s.withOptionalAuth(s.withRateLimit(s.publicHandler.Validate))))
+			// With optional auth for differentiated rate limiting; API
+			// tokens need the scope of the endpoint. Members acting in an
+			// organization get the limits of its plan.
+			mux.HandleFunc("/parse-schema", corsAndLog(s.withOptionalAuth(s.withScope(models.ScopeSchemaParse, withOrganization(s.withRateLimit(s.publicHandler.ParseSchema))))))
+			mux.HandleFunc("/generate-sql", corsAndLog(s.withOptionalAuth(s.withScope(models.ScopeSQLGenerate, withOrganization(s.withRateLimit(s.publicHandler.GenerateSQL))))))
+			mux.HandleFunc("/validate", corsAndLog(s.withOptionalAuth(s.withScope(models.ScopeSQLGenerate, withOrganization(s.withRateLimit(s.publicHandler.Validate))))))
+			mux.HandleFunc("/suggest-mapping", corsAndLog(s.withOptionalAuth(s.withScope(models.ScopeSQLGenerate, withOrganization(s.withRateLimit(s.publicHandler.SuggestMapping))))))
+			mux.HandleFunc("/infer-schema", corsAndLog(s.withOptionalAuth(s.withScope(models.ScopeSchemaParse, withOrganization(s.withRateLimit(s.publicHandler.InferSchema))))))
 		} else {
 			// Without auth
 			mux.HandleFunc("/parse-schema", corsAndLog(s.withRateLimit(s.publicHandler.ParseSchema)))
 			mux.HandleFunc("/generate-sql", corsAndLog(s.withRateLimit(s.publicHandler.GenerateSQL)))
 			mux.HandleFunc("/validate", corsAndLog(s.withRateLimit(s.publicHandler.Validate)))
+			mux.HandleFunc("/suggest-mapping", corsAndLog(s.withRateLimit(s.publicHandler.SuggestMapping)))
+			mux.HandleFunc("/infer-schema", corsAndLog(s.withRateLimit(s.publicHandler.InferSchema)))
 		}
 	} else {
 		// Without rate limiting
 		mux.HandleFunc("/parse-schema", corsAndLog(s.publicHandler.ParseSchema))
 		mux.HandleFunc("/generate-sql", corsAndLog(s.publicHandler.GenerateSQL))
 		mux.HandleFunc("/validate", corsAndLog(s.publicHandler.Validate))
+		mux.HandleFunc("/suggest-mapping", corsAndLog(s.publicHandler.SuggestMapping))
+		mux.HandleFunc("/infer-schema", corsAndLog(s.publicHandler.InferSchema))
 	}
 }
 
@@ -59,12 +73,47 @@ func (s *Server) setupAuthRoutes(mux *http.ServeMux) {
 
 	corsAndLog := s.withCORS(s.withLogging)
 	requireAuth := s.withRequireAuth
+	// Sign-in and mail endpoints can be limited per IP address with
+	// RATE_LIMIT_POLICIES
+	rateLimited := func(handler http.HandlerFunc) http.HandlerFunc {
+		return s.withOptionalAuth(s.withRateLimit(handler))
+	}
 
-	mux.HandleFunc("/auth/register", corsAndLog(s.authHandler.Register))
-	mux.HandleFunc("/auth/login", corsAndLog(s.authHandler.Login))
+	mux.HandleFunc("/auth/register", corsAndLog(rateLimited(s.authHandler.Register)))
+	mux.HandleFunc("/auth/login", corsAndLog(rateLimited(s.authHandler.Login)))
+	mux.HandleFunc("/auth/login/mfa", corsAndLog(rateLimited(s.authHandler.LoginMFA)))
 	mux.HandleFunc("/auth/refresh", corsAndLog(s.authHandler.RefreshToken))
 	mux.HandleFunc("/auth/logout", corsAndLog(s.authHandler.Logout))
 	mux.HandleFunc("/auth/me", corsAndLog(requireAuth(s.authHandler.Me)))
+
+	// Email verification and password reset
+	mux.HandleFunc("/auth/verify-email", corsAndLog(s.authHandler.VerifyEmail))
+	mux.HandleFunc("/auth/verify-email/send", corsAndLog(requireAuth(s.authHandler.SendVerificationEmail)))
+	mux.HandleFunc("/auth/password/forgot", corsAndLog(rateLimited(s.authHandler.ForgotPassword)))
+	mux.HandleFunc("/auth/password/reset", corsAndLog(s.authHandler.ResetPassword))
+
+	// Signed-in devices
+	mux.HandleFunc("/auth/sessions", corsAndLog(requireAuth(s.sessionHandler.ListSessions)))
+	mux.HandleFunc("/auth/sessions/revoke", corsAndLog(requireAuth(s.sessionHandler.RevokeSession)))
+	mux.HandleFunc("/auth/sessions/revoke-others", corsAndLog(requireAuth(s.sessionHandler.RevokeOtherSessions)))
+
+	// Account data export and deletion
+	mux.HandleFunc("/auth/account/export", corsAndLog(requireAuth(s.accountDataHandler.RequestExport)))
+	mux.HandleFunc("/auth/account/export/download", corsAndLog(requireAuth(s.accountDataHandler.DownloadExport)))
+	mux.HandleFunc("/auth/account/delete", corsAndLog(requireAuth(s.accountDataHandler.DeleteAccount)))
+	mux.HandleFunc("/auth/account/delete/cancel", corsAndLog(requireAuth(s.accountDataHandler.CancelDeletion)))
+
+	// Two-factor authentication settings
+	mux.HandleFunc("/auth/mfa", corsAndLog(requireAuth(s.mfaHandler.Status)))
+	mux.HandleFunc("/auth/mfa/totp/setup", corsAndLog(requireAuth(s.mfaHandler.SetupTOTP)))
+	mux.HandleFunc("/auth/mfa/totp/enable", corsAndLog(requireAuth(s.mfaHandler.EnableTOTP)))
+	mux.HandleFunc("/auth/mfa/totp/disable", corsAndLog(requireAuth(s.mfaHandler.DisableTOTP)))
+	mux.HandleFunc("/auth/mfa/recovery-codes", corsAndLog(requireAuth(s.mfaHandler.RegenerateRecoveryCodes)))
+
+	// Single sign-on through an OpenID Connect provider
+	mux.HandleFunc("/auth/oidc", corsAndLog(s.oidcHandler.Status))
+	mux.HandleFunc("/auth/oidc/login", corsAndLog(rateLimited(s.oidcHandler.Login)))
+	mux.HandleFunc("/auth/oidc/callback", corsAndLog(rateLimited(s.oidcHandler.Callback)))
 }
 
 // setupProtectedRoutes registers routes that require authentication
@@ -73,23 +122,112 @@ func (s *Server) setupProtectedRoutes(mux *http.ServeMux) {
 
 	corsAndLog := s.withCORS(s.withLogging)
 	requireAuth := s.withRequireAuth
+	requireScope := s.withRequireScope
 
-	// Import endpoints
-	mux.HandleFunc("/api/v1/imports", corsAndLog(requireAuth(s.importHandler.CreateImport)))
-	mux.HandleFunc("/api/v1/imports/list", corsAndLog(requireAuth(s.importHandler.ListImports)))
-	mux.HandleFunc("/api/v1/imports/get", corsAndLog(requireAuth(s.importHandler.GetImport)))
-	mux.HandleFunc("/api/v1/imports/sql", corsAndLog(requireAuth(s.importHandler.GetImportSQL)))
-	mux.HandleFunc("/api/v1/imports/delete", corsAndLog(requireAuth(s.importHandler.DeleteImport)))
-	mux.HandleFunc("/api/v1/imports/stats", corsAndLog(requireAuth(s.importHandler.GetStats)))
-	mux.HandleFunc("/api/v1/imports/old", corsAndLog(requireAuth(s.importHandler.DeleteOldImports)))
+	// Personal access tokens; they can only be managed after signing in
+	mux.HandleFunc("/api/v1/tokens", corsAndLog(requireAuth(s.handleAPITokens)))
+	mux.HandleFunc("/api/v1/tokens/revoke", corsAndLog(requireAuth(s.apiTokenHandler.RevokeToken)))
+
+	// Organization endpoints; members act in an organization's workspace by
+	// sending its ID in the X-Organization-ID header on the endpoints below
+	mux.HandleFunc("/api/v1/organizations", corsAndLog(requireAuth(s.handleOrganizations)))
+	mux.HandleFunc("/api/v1/organizations/update", corsAndLog(requireAuth(s.organizationHandler.RenameOrganization)))
+	mux.HandleFunc("/api/v1/organizations/mfa", corsAndLog(requireAuth(s.organizationHandler.SetMFARequirement)))
+	mux.HandleFunc("/api/v1/organizations/delete", corsAndLog(requireAuth(s.organizationHandler.DeleteOrganization)))
+	mux.HandleFunc("/api/v1/organizations/members", corsAndLog(requireAuth(s.organizationHandler.ListMembers)))
+	mux.HandleFunc("/api/v1/organizations/members/role", corsAndLog(requireAuth(s.organizationHandler.UpdateMemberRole)))
+	mux.HandleFunc("/api/v1/organizations/members/remove", corsAndLog(requireAuth(s.organizationHandler.RemoveMember)))
+	mux.HandleFunc("/api/v1/organizations/invitations", corsAndLog(requireAuth(s.handleOrganizationInvitations)))
+	mux.HandleFunc("/api/v1/organizations/invitations/revoke", corsAndLog(requireAuth(s.organizationHandler.RevokeInvitation)))
+	mux.HandleFunc("/api/v1/organizations/invitations/accept", corsAndLog(requireAuth(s.organizationHandler.AcceptInvitation)))
+
+	// Audit log; organization events are read by its admins
+	mux.HandleFunc("/api/v1/audit", corsAndLog(requireAuth(s.auditHandler.ListEvents)))
+	mux.HandleFunc("/api/v1/audit/export", corsAndLog(requireAuth(s.auditHandler.ExportEvents)))
+
+	// Import endpoints; API tokens need the imports:read or imports:write scope
+	mux.HandleFunc("/api/v1/imports", corsAndLog(requireScope(models.ScopeImportsWrite, s.importHandler.CreateImport)))
+	mux.HandleFunc("/api/v1/imports/list", corsAndLog(requireScope(models.ScopeImportsRead, s.importHandler.ListImports)))
+	mux.HandleFunc("/api/v1/imports/get", corsAndLog(requireScope(models.ScopeImportsRead, s.importHandler.GetImport)))
+	mux.HandleFunc("/api/v1/imports/sql", corsAndLog(requireScope(models.ScopeImportsRead, s.importHandler.GetImportSQL)))
+	mux.HandleFunc("/api/v1/imports/rollback", corsAndLog(requireScope(models.ScopeImportsRead, s.importHandler.GetImportRollback)))
+	mux.HandleFunc("/api/v1/imports/delete", corsAndLog(requireScope(models.ScopeImportsWrite, s.importHandler.DeleteImport)))
+	mux.HandleFunc("/api/v1/imports/stats", corsAndLog(requireScope(models.ScopeImportsRead, s.importHandler.GetStats)))
+	mux.HandleFunc("/api/v1/imports/old", corsAndLog(requireScope(models.ScopeImportsWrite, s.importHandler.DeleteOldImports)))
+	if s.config.SQLExecutionEnabled {
+		mux.HandleFunc("/api/v1/imports/execute", corsAndLog(requireScope(models.ScopeImportsWrite, s.importHandler.ExecuteImport)))
+	}
+
+	// Background job endpoints
+	mux.HandleFunc("/api/v1/jobs", corsAndLog(requireAuth(s.handleJobs)))
+	mux.HandleFunc("/api/v1/jobs/get", corsAndLog(requireAuth(s.jobHandler.GetJob)))
+	mux.HandleFunc("/api/v1/jobs/cancel", corsAndLog(requireAuth(s.jobHandler.CancelJob)))
+	mux.HandleFunc("/api/v1/jobs/events", corsAndLog(requireAuth(s.jobHandler.StreamJobEvents)))
+
+	// Chunked upload endpoints (large schema and data files), which feed
+	// re-runs and so count as imports:write for API tokens
+	if s.uploadHandler != nil {
+		mux.HandleFunc("/api/v1/uploads", corsAndLog(requireScope(models.ScopeImportsWrite, s.uploadHandler.CreateUpload)))
+		mux.HandleFunc("/api/v1/uploads/get", corsAndLog(requireScope(models.ScopeImportsWrite, s.uploadHandler.GetUpload)))
+		mux.HandleFunc("/api/v1/uploads/chunk", corsAndLog(requireScope(models.ScopeImportsWrite, s.uploadHandler.UploadChunk)))
+		mux.HandleFunc("/api/v1/uploads/complete", corsAndLog(requireScope(models.ScopeImportsWrite, s.uploadHandler.CompleteUpload)))
+		mux.HandleFunc("/api/v1/uploads/delete", corsAndLog(requireScope(models.ScopeImportsWrite, s.uploadHandler.DeleteUpload)))
+		mux.HandleFunc("/api/v1/imports/rerun", corsAndLog(requireScope(models.ScopeImportsWrite, s.importHandler.RerunImport)))
+	}
+
+	// Connection profile endpoints (live schema introspection)
+	mux.HandleFunc("/api/v1/connections", corsAndLog(requireAuth(s.handleConnections)))
+	mux.HandleFunc("/api/v1/connections/delete", corsAndLog(requireAuth(s.connectionProfileHandler.DeleteProfile)))
 
 	// Workflow session endpoints
 	mux.HandleFunc("/api/v1/workflow/session", corsAndLog(requireAuth(s.handleWorkflowSession)))
 	mux.HandleFunc("/api/v1/workflow/session/schema", corsAndLog(requireAuth(s.handleWorkflowSessionSchema)))
+	mux.HandleFunc("/api/v1/workflow/session/schema/connection", corsAndLog(requireAuth(s.workflowSessionHandler.SaveSchemaFromConnection)))
 	mux.HandleFunc("/api/v1/workflow/session/table", corsAndLog(requireAuth(s.workflowSessionHandler.SaveTableSelection)))
 	mux.HandleFunc("/api/v1/workflow/session/data", corsAndLog(requireAuth(s.workflowSessionHandler.SaveDataFile)))
+	if s.uploadHandler != nil {
+		mux.HandleFunc("/api/v1/workflow/session/schema/upload", corsAndLog(requireAuth(s.workflowSessionHandler.SaveSchemaFromUpload)))
+		mux.HandleFunc("/api/v1/workflow/session/data/upload", corsAndLog(requireAuth(s.workflowSessionHandler.SaveDataFromUpload)))
+		mux.HandleFunc("/api/v1/workflow/session/infer/upload", corsAndLog(requireAuth(s.workflowSessionHandler.InferTableFromUpload)))
+	}
+	mux.HandleFunc("/api/v1/workflow/session/infer", corsAndLog(requireAuth(s.workflowSessionHandler.InferTable)))
 	mux.HandleFunc("/api/v1/workflow/session/mapping", corsAndLog(requireAuth(s.workflowSessionHandler.SaveMapping)))
+	mux.HandleFunc("/api/v1/workflow/session/generate", corsAndLog(requireAuth(s.workflowSessionHandler.GenerateSQL)))
+	mux.HandleFunc("/api/v1/workflow/session/history", corsAndLog(requireAuth(s.workflowSessionHandler.ListHistory)))
+	mux.HandleFunc("/api/v1/workflow/session/history/restore", corsAndLog(requireAuth(s.workflowSessionHandler.RestoreVersion)))
 	mux.HandleFunc("/api/v1/workflow/session/extend", corsAndLog(requireAuth(s.workflowSessionHandler.ExtendExpiration)))
+	mux.HandleFunc("/api/v1/workflow/session/templates", corsAndLog(requireAuth(s.workflowSessionHandler.SuggestMappingTemplates)))
+	mux.HandleFunc("/api/v1/workflow/session/templates/apply", corsAndLog(requireAuth(s.workflowSessionHandler.ApplyMappingTemplate)))
+
+	// Per-session workflow endpoints; the /workflow/session routes above
+	// work on the user's most recently updated session
+	mux.HandleFunc("/api/v1/workflow/sessions", corsAndLog(requireAuth(s.handleWorkflowSessions)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}", corsAndLog(requireAuth(s.handleWorkflowSessionByID)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/schema", corsAndLog(requireAuth(s.handleWorkflowSessionSchema)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/schema/connection", corsAndLog(requireAuth(s.workflowSessionHandler.SaveSchemaFromConnection)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/table", corsAndLog(requireAuth(s.workflowSessionHandler.SaveTableSelection)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/data", corsAndLog(requireAuth(s.workflowSessionHandler.SaveDataFile)))
+	if s.uploadHandler != nil {
+		mux.HandleFunc("/api/v1/workflow/sessions/{id}/schema/upload", corsAndLog(requireAuth(s.workflowSessionHandler.SaveSchemaFromUpload)))
+		mux.HandleFunc("/api/v1/workflow/sessions/{id}/data/upload", corsAndLog(requireAuth(s.workflowSessionHandler.SaveDataFromUpload)))
+		mux.HandleFunc("/api/v1/workflow/sessions/{id}/infer/upload", corsAndLog(requireAuth(s.workflowSessionHandler.InferTableFromUpload)))
+	}
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/infer", corsAndLog(requireAuth(s.workflowSessionHandler.InferTable)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/mapping", corsAndLog(requireAuth(s.workflowSessionHandler.SaveMapping)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/generate", corsAndLog(requireAuth(s.workflowSessionHandler.GenerateSQL)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/history", corsAndLog(requireAuth(s.workflowSessionHandler.ListHistory)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/history/restore", corsAndLog(requireAuth(s.workflowSessionHandler.RestoreVersion)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/extend", corsAndLog(requireAuth(s.workflowSessionHandler.ExtendExpiration)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/templates", corsAndLog(requireAuth(s.workflowSessionHandler.SuggestMappingTemplates)))
+	mux.HandleFunc("/api/v1/workflow/sessions/{id}/templates/apply", corsAndLog(requireAuth(s.workflowSessionHandler.ApplyMappingTemplate)))
+
+	// Mapping template endpoints (reusable column mappings)
+	mux.HandleFunc("/api/v1/mapping-templates", corsAndLog(requireAuth(s.handleMappingTemplates)))
+	mux.HandleFunc("/api/v1/mapping-templates/get", corsAndLog(requireAuth(s.mappingTemplateHandler.GetTemplate)))
+	mux.HandleFunc("/api/v1/mapping-templates/update", corsAndLog(requireAuth(s.mappingTemplateHandler.UpdateTemplate)))
+	mux.HandleFunc("/api/v1/mapping-templates/delete", corsAndLog(requireAuth(s.mappingTemplateHandler.DeleteTemplate)))
+	mux.HandleFunc("/api/v1/mapping-templates/export", corsAndLog(requireAuth(s.mappingTemplateHandler.ExportTemplate)))
+	mux.HandleFunc("/api/v1/mapping-templates/import", corsAndLog(requireAuth(s.mappingTemplateHandler.ImportTemplate)))
 }
 
 // handleWorkflowSession routes GET and DELETE for /api/v1/workflow/session
@@ -104,7 +242,34 @@ func (s *Server) handleWorkflowSession(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleWorkflowSessions routes GET and POST for /api/v1/workflow/sessions
+func (s *Server) handleWorkflowSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.workflowSessionHandler.ListSessions(w, r)
+	case http.MethodPost:
+		s.workflowSessionHandler.CreateSession(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWorkflowSessionByID routes GET, PUT and DELETE for /api/v1/workflow/sessions/{id}
+func (s *Server) handleWorkflowSessionByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.workflowSessionHandler.GetSession(w, r)
+	case http.MethodPut:
+		s.workflowSessionHandler.RenameSession(w, r)
+	case http.MethodDelete:
+		s.workflowSessionHandler.DeleteSession(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // handleWorkflowSessionSchema routes GET and POST for /api/v1/workflow/session/schema
+// and /api/v1/workflow/sessions/{id}/schema
 func (s *Server) handleWorkflowSessionSchema(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -116,6 +281,78 @@ func (s *Server) handleWorkflowSessionSchema(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// handleConnections routes GET and POST for /api/v1/connections
+func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.connectionProfileHandler.ListProfiles(w, r)
+	case http.MethodPost:
+		s.connectionProfileHandler.CreateProfile(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPITokens routes GET and POST for /api/v1/tokens
+func (s *Server) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.apiTokenHandler.ListTokens(w, r)
+	case http.MethodPost:
+		s.apiTokenHandler.CreateToken(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMappingTemplates routes GET and POST for /api/v1/mapping-templates
+func (s *Server) handleMappingTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mappingTemplateHandler.ListTemplates(w, r)
+	case http.MethodPost:
+		s.mappingTemplateHandler.CreateTemplate(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOrganizations routes GET and POST for /api/v1/organizations
+func (s *Server) handleOrganizations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.organizationHandler.ListOrganizations(w, r)
+	case http.MethodPost:
+		s.organizationHandler.CreateOrganization(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOrganizationInvitations routes GET and POST for /api/v1/organizations/invitations
+func (s *Server) handleOrganizationInvitations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.organizationHandler.ListInvitations(w, r)
+	case http.MethodPost:
+		s.organizationHandler.CreateInvitation(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleJobs routes GET and POST for /api/v1/jobs
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.jobHandler.ListJobs(w, r)
+	case http.MethodPost:
+		s.jobHandler.SubmitJob(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // Middleware helpers
 
 // withCORS wraps a handler with CORS middleware
@@ -125,9 +362,10 @@ func (s *Server) withCORS(next func(http.HandlerFunc) http.HandlerFunc) func(htt
 	}
 }
 
-// withLogging wraps a handler with logging middleware
+// withLogging wraps a handler with logging middleware; the client's device
+// is recorded for the services as well
 func (s *Server) withLogging(handler http.HandlerFunc) http.HandlerFunc {
-	return s.loggingMiddleware(handler)
+	return s.loggingMiddleware(s.withClient(handler))
 }
 
 // withRateLimit wraps a handler with rate limiting middleware
@@ -138,19 +376,78 @@ func (s *Server) withRateLimit(handler http.HandlerFunc) http.HandlerFunc {
 	return s.rateLimiter.RateLimit(handler)
 }
 
+// apiTokens returns the authenticator of personal access tokens, or nil
+// without a database
+func (s *Server) apiTokens() middleware.APITokenAuthenticator {
+	if s.apiTokenService == nil {
+		return nil
+	}
+	return s.apiTokenService
+}
+
 // withOptionalAuth wraps a handler with optional auth middleware (for rate limiting differentiation)
 func (s *Server) withOptionalAuth(handler http.HandlerFunc) http.HandlerFunc {
 	jwtConfig := s.config.JWTConfig()
+	tokens := s.apiTokens()
 	return func(w http.ResponseWriter, r *http.Request) {
-		middleware.OptionalAuthMiddleware(jwtConfig)(http.HandlerFunc(handler)).ServeHTTP(w, r)
+		middleware.OptionalAuthMiddleware(jwtConfig, tokens)(http.HandlerFunc(handler)).ServeHTTP(w, r)
 	}
 }
 
-// withRequireAuth wraps a handler with required auth middleware
+// withScope refuses requests made with an API token lacking scope
+func (s *Server) withScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return middleware.RequireScope(scope)(handler)
+}
+
+// withRequireAuth wraps a handler with required auth middleware; API tokens
+// are refused
 func (s *Server) withRequireAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return s.withRequireScope("", handler)
+}
+
+// withRequireScope wraps a handler with required auth middleware that also
+// accepts API tokens granted scope. Rate limit policies of the route apply
+// once the caller and their organization are known.
+func (s *Server) withRequireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
 	jwtConfig := s.config.JWTConfig()
+	tokens := s.apiTokens()
+	scoped := s.withScope(scope, withOrganization(s.withRateLimit(handler)))
+	return func(w http.ResponseWriter, r *http.Request) {
+		middleware.AuthMiddleware(jwtConfig, tokens)(scoped).ServeHTTP(w, r)
+	}
+}
+
+// withOrganization makes the request act in the organization named by the
+// X-Organization-ID header or the organizationId query parameter, if any.
+// Membership is checked by the services.
+func withOrganization(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("X-Organization-ID")
+		if value == "" {
+			value = r.URL.Query().Get("organizationId")
+		}
+		if value == "" {
+			handler(w, r)
+			return
+		}
+
+		orgID, err := utils.ParseUUID(value)
+		if err != nil {
+			utils.BadRequest(w, "Invalid organization ID")
+			return
+		}
+		handler(w, r.WithContext(service.WithOrganization(r.Context(), orgID)))
+	}
+}
+
+// withClient records the IP address and user agent of the request for the
+// sessions, login attempts and audit events it creates
+func (s *Server) withClient(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		middleware.AuthMiddleware(jwtConfig)(http.HandlerFunc(handler)).ServeHTTP(w, r)
+		handler(w, r.WithContext(service.WithClient(r.Context(), service.ClientInfo{
+			IPAddress: s.trustedProxies.ClientIP(r),
+			UserAgent: r.UserAgent(),
+		})))
 	}
 }
 
@@ -176,7 +473,8 @@ func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Range, Authorization, If-Match, X-Organization-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "Upload-Offset, ETag, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
@@ -8,8 +8,14 @@ import (
 	"db-importer/internal/handlers"
 	"db-importer/internal/repository"
 	"db-importer/internal/service"
+	"db-importer/internal/utils"
 	"db-importer/logger"
+	"db-importer/mailer"
 	"db-importer/middleware"
+	"db-importer/oidc"
+	"db-importer/ratelimit"
+	"db-importer/storage"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -25,14 +31,42 @@ type Server struct {
 	httpServer  *http.Server
 	rateLimiter *middleware.RateLimiter
 
+	// trustedProxies are the reverse proxies whose forwarding headers name
+	// the client's IP address
+	trustedProxies utils.TrustedProxies
+
+	// rateLimitBuckets are the rate limit buckets kept in the database,
+	// nil with the memory store
+	rateLimitBuckets *ratelimit.PostgresStore
+
 	// Services
+	importService          *service.ImportService
 	workflowSessionService *service.WorkflowSessionService
+	jobService             *service.JobService
+	uploadService          *service.UploadService
+	apiTokenService        *service.APITokenService
+	authService            *service.AuthService
+	accountService         *service.AccountService
+	accountExportService   *service.AccountExportService
+	mfaService             *service.MFAService
+	organizationService    *service.OrganizationService
 
 	// Handlers
-	authHandler           *handler.AuthHandler
-	importHandler         *handler.ImportHandler
-	workflowSessionHandler *handler.WorkflowSessionHandler
-	publicHandler         *handlers.PublicHandler
+	authHandler              *handler.AuthHandler
+	oidcHandler              *handler.OIDCHandler
+	mfaHandler               *handler.MFAHandler
+	sessionHandler           *handler.SessionHandler
+	apiTokenHandler          *handler.APITokenHandler
+	importHandler            *handler.ImportHandler
+	jobHandler               *handler.JobHandler
+	uploadHandler            *handler.UploadHandler
+	connectionProfileHandler *handler.ConnectionProfileHandler
+	workflowSessionHandler   *handler.WorkflowSessionHandler
+	mappingTemplateHandler   *handler.MappingTemplateHandler
+	organizationHandler      *handler.OrganizationHandler
+	auditHandler             *handler.AuditHandler
+	accountDataHandler       *handler.AccountDataHandler
+	publicHandler            *handlers.PublicHandler
 
 	// Cleanup
 	cleanupCancel context.CancelFunc
@@ -53,6 +87,14 @@ func New(cfg *config.Config) *Server {
 		"version":     cfg.Version,
 	})
 
+	proxies, err := utils.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Warn("Invalid TRUSTED_PROXIES, ignoring forwarding headers", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	srv.trustedProxies = proxies
+
 	// Initialize database if configured
 	if cfg.DatabaseURL != "" {
 		srv.initDatabase()
@@ -65,17 +107,7 @@ func New(cfg *config.Config) *Server {
 
 	// Initialize rate limiter
 	if cfg.RateLimitEnabled {
-		srv.rateLimiter = middleware.NewRateLimiter(
-			cfg.RateLimitGuest,
-			cfg.RateLimitWindow,
-			cfg.RateLimitAuth,
-			cfg.RateLimitWindow,
-		)
-		logger.Info("Rate limiting enabled", map[string]interface{}{
-			"guestRequests": cfg.RateLimitGuest,
-			"guestWindow":   cfg.RateLimitWindow,
-			"authRequests":  cfg.RateLimitAuth,
-		})
+		srv.initRateLimiter()
 	}
 
 	// Setup HTTP server
@@ -84,6 +116,52 @@ func New(cfg *config.Config) *Server {
 	return srv
 }
 
+// toolRoutes are the public tool endpoints, which share the budgets of
+// RATE_LIMIT_GUEST and RATE_LIMIT_AUTH
+var toolRoutes = []string{"/parse-schema", "/generate-sql", "/validate", "/suggest-mapping", "/infer-schema"}
+
+// initRateLimiter sets up the rate limiter with the configured policies,
+// followed by the limits of guests and users on the tool endpoints
+func (s *Server) initRateLimiter() {
+	policies, err := ratelimit.ParsePolicies(s.config.RateLimitPolicies)
+	if err != nil {
+		logger.Warn("Invalid RATE_LIMIT_POLICIES, using the default limits", map[string]interface{}{
+			"error": err.Error(),
+		})
+		policies = ratelimit.Policies{}
+	}
+
+	window := time.Duration(s.config.RateLimitWindow) * time.Second
+	policies = append(policies,
+		ratelimit.Rule{Routes: toolRoutes, Class: ratelimit.ClassGuest, Policy: ratelimit.Policy{Limit: s.config.RateLimitGuest, Window: window}},
+		ratelimit.Rule{Routes: toolRoutes, Class: ratelimit.ClassUser, Policy: ratelimit.Policy{Limit: s.config.RateLimitAuth, Window: window}},
+	)
+
+	var store ratelimit.Store
+	storeName := "memory"
+	if s.config.RateLimitStore == "postgres" && s.db != nil {
+		s.rateLimitBuckets = ratelimit.NewPostgresStore(s.db.Sqlx.DB)
+		store = s.rateLimitBuckets
+		storeName = "postgres"
+	} else {
+		store = ratelimit.NewMemoryStore()
+	}
+
+	var plans middleware.PlanResolver
+	if s.organizationService != nil {
+		plans = s.organizationService
+	}
+
+	s.rateLimiter = middleware.NewRateLimiter(store, policies, plans, s.trustedProxies)
+	logger.Info("Rate limiting enabled", map[string]interface{}{
+		"store":         storeName,
+		"rules":         len(policies),
+		"guestRequests": s.config.RateLimitGuest,
+		"guestWindow":   s.config.RateLimitWindow,
+		"authRequests":  s.config.RateLimitAuth,
+	})
+}
+
 // initDatabase initializes the database connection and runs migrations
 func (s *Server) initDatabase() {
 	logger.Info("Initializing database connection", nil)
@@ -156,26 +234,206 @@ func (s *Server) initHandlers() {
 		// Initialize repositories
 		userRepo := repository.NewUserRepository(s.db)
 		refreshTokenRepo := repository.NewRefreshTokenRepository(s.db)
+		apiTokenRepo := repository.NewAPITokenRepository(s.db)
 		importRepo := repository.NewImportRepository(s.db)
 		workflowSessionRepo := repository.NewWorkflowSessionRepository(s.db)
+		connectionProfileRepo := repository.NewConnectionProfileRepository(s.db)
+		jobRepo := repository.NewJobRepository(s.db)
+		uploadRepo := repository.NewUploadRepository(s.db)
+		mappingTemplateRepo := repository.NewMappingTemplateRepository(s.db)
+		organizationRepo := repository.NewOrganizationRepository(s.db)
+		emailTokenRepo := repository.NewEmailTokenRepository(s.db)
+		userIdentityRepo := repository.NewUserIdentityRepository(s.db)
+		mfaRepo := repository.NewMFARepository(s.db)
+		loginAttemptRepo := repository.NewLoginAttemptRepository(s.db)
+		auditRepo := repository.NewAuditRepository(s.db)
+
+		// Credentials of connection profiles and TOTP secrets are encrypted at rest
+		credentialCipher, err := utils.NewSecretCipher(s.config.CredentialsKey)
+		if err != nil {
+			logger.Warn("Connection profiles and two-factor authentication disabled: CREDENTIALS_ENCRYPTION_KEY is not set", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		// Object storage keeps uploads, generated SQL and schema content;
+		// without it chunked uploads are disabled and content stays in the database
+		blobs, err := s.newStorageBackend()
+		if err != nil {
+			logger.Warn("Object storage unavailable: chunked uploads disabled, content kept in the database", map[string]interface{}{
+				"backend": s.config.StorageBackend,
+				"error":   err.Error(),
+			})
+		} else {
+			s.uploadService = service.NewUploadService(uploadRepo, blobs, service.UploadConfig{
+				MaxSize:      s.config.MaxChunkedUploadSize,
+				MaxChunkSize: s.config.UploadChunkMaxSize,
+				TTL:          s.config.UploadTTL,
+			})
+		}
+
+		sender, err := s.newMailSender()
+		if err != nil {
+			logger.Warn("Mail server unavailable: emails are only logged", map[string]interface{}{
+				"sender": s.config.MailSender,
+				"error":  err.Error(),
+			})
+			sender, _ = mailer.NewFile("", "no-reply@localhost")
+		}
 
 		// Initialize services
-		authService := service.NewAuthService(userRepo, refreshTokenRepo, jwtConfig)
-		importService := service.NewImportService(importRepo)
-		s.workflowSessionService = service.NewWorkflowSessionService(workflowSessionRepo)
+		auditService := service.NewAuditService(auditRepo)
+		s.mfaService = service.NewMFAService(mfaRepo, userRepo, organizationRepo, credentialCipher, service.MFAConfig{
+			Issuer:      s.config.MFAIssuer,
+			TokenSecret: []byte(s.config.JWTRefreshSecret),
+		})
+		authService := service.NewAuthService(userRepo, refreshTokenRepo, loginAttemptRepo, s.mfaService, auditService, jwtConfig, service.LoginPolicy{
+			DelayAfter:      s.config.LoginDelayAfter,
+			LockoutAfter:    s.config.LoginLockoutAfter,
+			LockoutDuration: s.config.LoginLockoutDuration,
+		})
+		s.authService = authService
+		sessionService := service.NewSessionService(refreshTokenRepo)
+		s.accountService = service.NewAccountService(userRepo, refreshTokenRepo, emailTokenRepo, organizationRepo, auditRepo, s.uploadService, blobs, sender, auditService, service.AccountConfig{
+			TokenSecret:   s.config.EmailTokenKey(),
+			AppURL:        s.config.AppURL,
+			DeletionGrace: s.config.AccountDeletionGrace,
+		})
+		oidcService := s.newOIDCService(authService, userRepo, userIdentityRepo)
+		s.apiTokenService = service.NewAPITokenService(apiTokenRepo, auditService)
+		organizationService := service.NewOrganizationService(organizationRepo, userRepo, blobs, auditService)
+		s.organizationService = organizationService
+		connectionProfileService := service.NewConnectionProfileService(connectionProfileRepo, organizationService, credentialCipher, s.config.SQLiteIntrospectionDir)
+		s.importService = service.NewImportService(importRepo, organizationService, connectionProfileService, s.uploadService, blobs, auditService)
+		mappingTemplateService := service.NewMappingTemplateService(mappingTemplateRepo, organizationService)
+		s.workflowSessionService = service.NewWorkflowSessionService(workflowSessionRepo, organizationService, connectionProfileService, s.uploadService, mappingTemplateService, s.importService, blobs, auditService)
+		s.accountExportService = service.NewAccountExportService(userRepo, organizationRepo, importRepo, workflowSessionRepo, auditRepo, jobRepo, blobs, auditService, service.AccountExportConfig{
+			TTL: s.config.AccountExportTTL,
+		})
+		s.jobService = service.NewJobService(jobRepo, organizationService, s.importService, s.accountExportService, service.JobServiceConfig{
+			Workers:          s.config.JobWorkers,
+			PollInterval:     s.config.JobPollInterval,
+			ExecutionEnabled: s.config.SQLExecutionEnabled,
+		})
 
 		// Initialize handlers
-		s.authHandler = handler.NewAuthHandler(authService)
-		s.importHandler = handler.NewImportHandler(importService)
+		s.authHandler = handler.NewAuthHandler(authService, s.accountService, organizationService)
+		s.accountDataHandler = handler.NewAccountDataHandler(s.accountService, s.accountExportService)
+		s.oidcHandler = handler.NewOIDCHandler(oidcService)
+		s.mfaHandler = handler.NewMFAHandler(s.mfaService, authService)
+		s.sessionHandler = handler.NewSessionHandler(sessionService)
+		s.apiTokenHandler = handler.NewAPITokenHandler(s.apiTokenService)
+		s.importHandler = handler.NewImportHandler(s.importService, s.config.SQLExecutionEnabled)
+		s.jobHandler = handler.NewJobHandler(s.jobService)
 		s.workflowSessionHandler = handler.NewWorkflowSessionHandler(s.workflowSessionService)
+		s.connectionProfileHandler = handler.NewConnectionProfileHandler(connectionProfileService)
+		s.mappingTemplateHandler = handler.NewMappingTemplateHandler(mappingTemplateService)
+		s.organizationHandler = handler.NewOrganizationHandler(organizationService)
+		s.auditHandler = handler.NewAuditHandler(auditService, organizationService)
+		if s.uploadService != nil {
+			s.uploadHandler = handler.NewUploadHandler(s.uploadService)
+		}
 
-		// Start cleanup job for expired workflow sessions
+		// Start cleanup job for expired workflow sessions and uploads
 		s.startCleanupJob()
 
+		// Start background import job workers
+		s.jobService.Start()
+
 		logger.Info("Authentication, import, and workflow session systems initialized", nil)
 	}
 }
 
+// newStorageBackend creates the configured object storage backend
+func (s *Server) newStorageBackend() (storage.Backend, error) {
+	switch s.config.StorageBackend {
+	case "local":
+		local, err := storage.NewLocal(s.config.StorageLocalDir)
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	case "s3":
+		bucket, err := storage.NewS3(storage.S3Config{
+			Endpoint:        s.config.S3Endpoint,
+			Region:          s.config.S3Region,
+			Bucket:          s.config.S3Bucket,
+			AccessKeyID:     s.config.S3AccessKeyID,
+			SecretAccessKey: s.config.S3SecretKey,
+			PathStyle:       s.config.S3PathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return bucket, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", s.config.StorageBackend)
+	}
+}
+
+// newMailSender creates the configured mail sender
+func (s *Server) newMailSender() (mailer.Sender, error) {
+	switch s.config.MailSender {
+	case "file":
+		return mailer.NewFile(s.config.MailFileDir, s.config.MailFrom)
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     s.config.SMTPHost,
+			Port:     s.config.SMTPPort,
+			Username: s.config.SMTPUsername,
+			Password: s.config.SMTPPassword,
+			From:     s.config.MailFrom,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", s.config.MailSender)
+	}
+}
+
+// newOIDCService creates the single sign-on service, or returns nil when no
+// OpenID Connect provider is configured. The provider is contacted on the
+// first sign-in, so it may be down while the server starts.
+func (s *Server) newOIDCService(
+	authService *service.AuthService,
+	userRepo *repository.UserRepository,
+	identityRepo *repository.UserIdentityRepository,
+) *service.OIDCService {
+	if s.config.OIDCIssuerURL == "" {
+		return nil
+	}
+
+	client, err := oidc.New(oidc.Config{
+		IssuerURL:    s.config.OIDCIssuerURL,
+		ClientID:     s.config.OIDCClientID,
+		ClientSecret: s.config.OIDCClientSecret,
+		RedirectURL:  s.config.OIDCRedirectURL,
+		Scopes:       s.config.OIDCScopes,
+	})
+	if err != nil {
+		logger.Warn("Single sign-on disabled: invalid OpenID Connect settings", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	logger.Info("Single sign-on enabled", map[string]interface{}{
+		"issuer": s.config.OIDCIssuerURL,
+	})
+	return service.NewOIDCService(client, authService, userRepo, identityRepo, service.OIDCConfig{
+		ProviderName: s.config.OIDCProviderName,
+		// The login state is signed for its own purpose, so the refresh
+		// token secret can sign it too
+		StateSecret: []byte(s.config.JWTRefreshSecret),
+		AppURL:      s.config.AppURL,
+		Claims: oidc.ClaimMapping{
+			Email:      s.config.OIDCEmailClaim,
+			FirstName:  s.config.OIDCFirstNameClaim,
+			LastName:   s.config.OIDCLastNameClaim,
+			TrustEmail: s.config.OIDCTrustEmail,
+		},
+		AutoProvision: s.config.OIDCAutoProvision,
+	})
+}
+
 // setupHTTPServer configures the HTTP server and routes
 func (s *Server) setupHTTPServer() {
 	mux := http.NewServeMux()
@@ -216,6 +474,7 @@ func (s *Server) Start() error {
 }
 
 // startCleanupJob starts a background job to cleanup expired workflow sessions
+// and uploads
 func (s *Server) startCleanupJob() {
 	if s.workflowSessionService == nil {
 		return
@@ -227,6 +486,14 @@ func (s *Server) startCleanupJob() {
 	go func() {
 		// Run immediately on startup
 		s.cleanupExpiredSessions()
+		s.cleanupExpiredUploads()
+		s.cleanupExpiredEmailTokens()
+		s.cleanupExpiredMFAChallenges()
+		s.cleanupLoginAttempts()
+		s.cleanupExpiredExports()
+		s.purgeDeletedAccounts()
+		s.cleanupRateLimitBuckets()
+		s.migrateLegacyContent()
 
 		// Then run every hour
 		ticker := time.NewTicker(1 * time.Hour)
@@ -236,6 +503,14 @@ func (s *Server) startCleanupJob() {
 			select {
 			case <-ticker.C:
 				s.cleanupExpiredSessions()
+				s.cleanupExpiredUploads()
+				s.cleanupExpiredEmailTokens()
+				s.cleanupExpiredMFAChallenges()
+				s.cleanupLoginAttempts()
+				s.cleanupExpiredExports()
+				s.purgeDeletedAccounts()
+				s.cleanupRateLimitBuckets()
+				s.migrateLegacyContent()
 			case <-ctx.Done():
 				logger.Info("Cleanup job stopped", nil)
 				return
@@ -264,6 +539,204 @@ func (s *Server) cleanupExpiredSessions() {
 	}
 }
 
+// cleanupExpiredUploads removes expired uploads and their stored bytes
+func (s *Server) cleanupExpiredUploads() {
+	if s.uploadService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	deleted, err := s.uploadService.CleanupExpiredUploads(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup expired uploads", err)
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up expired uploads", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// cleanupExpiredEmailTokens removes expired email verification and password
+// reset tokens
+func (s *Server) cleanupExpiredEmailTokens() {
+	if s.accountService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := s.accountService.CleanupExpiredTokens(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup expired email tokens", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up expired email tokens", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// cleanupExpiredMFAChallenges removes expired second steps of sign-ins
+func (s *Server) cleanupExpiredMFAChallenges() {
+	if s.mfaService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := s.mfaService.CleanupExpiredChallenges(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup expired MFA challenges", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up expired MFA challenges", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// cleanupLoginAttempts removes login attempts that no longer count towards
+// delays and lockouts
+func (s *Server) cleanupLoginAttempts() {
+	if s.authService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := s.authService.CleanupLoginAttempts(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup login attempts", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up login attempts", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// cleanupExpiredExports removes account exports that can no longer be
+// downloaded
+func (s *Server) cleanupExpiredExports() {
+	if s.accountExportService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := s.accountExportService.CleanupExpiredExports(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup expired account exports", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up expired account exports", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// cleanupRateLimitBuckets deletes rate limit buckets that are full again
+func (s *Server) cleanupRateLimitBuckets() {
+	if s.rateLimitBuckets == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := s.rateLimitBuckets.DeleteExpired(ctx)
+	if err != nil {
+		logger.Error("Failed to cleanup rate limit buckets", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Cleaned up rate limit buckets", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// purgeDeletedAccounts deletes the accounts whose deletion grace period has
+// ended
+func (s *Server) purgeDeletedAccounts() {
+	if s.accountService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	deleted, err := s.accountService.PurgeDueAccounts(ctx)
+	if err != nil {
+		logger.Error("Failed to delete accounts due for deletion", err)
+	}
+
+	if deleted > 0 {
+		logger.Info("Deleted accounts due for deletion", map[string]interface{}{
+			"deleted_count": deleted,
+		})
+	}
+}
+
+// migrateLegacyContent moves generated SQL and schema content stored
+// compressed in the database to object storage, in batches
+func (s *Server) migrateLegacyContent() {
+	if s.importService == nil || s.workflowSessionService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	const batchSize = 100
+	steps := []struct {
+		name    string
+		migrate func(context.Context, int) (int, error)
+	}{
+		{"imports", s.importService.MigrateLegacySQL},
+		{"workflow_sessions", s.workflowSessionService.MigrateLegacySchemas},
+	}
+
+	for _, step := range steps {
+		total := 0
+		for {
+			moved, err := step.migrate(ctx, batchSize)
+			total += moved
+			if err != nil {
+				logger.Error("Failed to move "+step.name+" content to object storage", err)
+				break
+			}
+			if moved == 0 {
+				break
+			}
+		}
+
+		if total > 0 {
+			logger.Info("Moved content to object storage", map[string]interface{}{
+				"table":       step.name,
+				"moved_count": total,
+			})
+		}
+	}
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	logger.Info("Shutting down server", nil)
@@ -273,6 +746,14 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		s.cleanupCancel()
 	}
 
+	// Stop claiming jobs and let running ones finish; this also ends
+	// job event streams so the HTTP server can shut down
+	if s.jobService != nil {
+		if err := s.jobService.Shutdown(ctx); err != nil {
+			logger.Error("Job workers did not drain in time", err)
+		}
+	}
+
 	// Shutdown HTTP server
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		logger.Error("Server shutdown error", err)
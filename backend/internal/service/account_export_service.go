@@ -0,0 +1,402 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrExportUnavailable = errors.New("account export is not available: object storage is not configured")
+	ErrExportNotReady    = errors.New("account export has not finished")
+	ErrExportExpired     = errors.New("account export has expired, request a new one")
+)
+
+const (
+	// accountExportBatchSize is how many imports or workflow sessions an
+	// export reads at a time
+	accountExportBatchSize = 50
+)
+
+// AccountExportConfig configures account exports
+type AccountExportConfig struct {
+	TTL time.Duration // how long a finished export can be downloaded
+}
+
+// AccountExportService bundles everything a user stored into a zip archive:
+// their profile, imports with their SQL, workflow sessions and the audit
+// events they caused. Exports run as background jobs and are kept in
+// object storage until they expire.
+type AccountExportService struct {
+	userRepo            *repository.UserRepository
+	orgRepo             *repository.OrganizationRepository
+	importRepo          *repository.ImportRepository
+	workflowSessionRepo *repository.WorkflowSessionRepository
+	auditRepo           *repository.AuditRepository
+	jobRepo             *repository.JobRepository
+	blobs               storage.Backend
+	audit               *AuditService
+	config              AccountExportConfig
+}
+
+// NewAccountExportService creates a new AccountExportService. Without
+// object storage exports cannot be requested.
+func NewAccountExportService(
+	userRepo *repository.UserRepository,
+	orgRepo *repository.OrganizationRepository,
+	importRepo *repository.ImportRepository,
+	workflowSessionRepo *repository.WorkflowSessionRepository,
+	auditRepo *repository.AuditRepository,
+	jobRepo *repository.JobRepository,
+	blobs storage.Backend,
+	audit *AuditService,
+	config AccountExportConfig,
+) *AccountExportService {
+	return &AccountExportService{
+		userRepo:            userRepo,
+		orgRepo:             orgRepo,
+		importRepo:          importRepo,
+		workflowSessionRepo: workflowSessionRepo,
+		auditRepo:           auditRepo,
+		jobRepo:             jobRepo,
+		blobs:               blobs,
+		audit:               audit,
+		config:              config,
+	}
+}
+
+// AccountExportFile is a finished account export opened for download
+type AccountExportFile struct {
+	io.ReadSeekCloser
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// accountExportProfile is the profile.json file of an export
+type accountExportProfile struct {
+	User          *models.UserResponse            `json:"user"`
+	Organizations []models.OrganizationMembership `json:"organizations"`
+	ExportedAt    time.Time                       `json:"exportedAt"`
+}
+
+// exportPrefix is where the exports of a user are stored
+func exportPrefix(userID uuid.UUID) string {
+	return "exports/" + userID.String()
+}
+
+// RequestExport queues an export of the user's data. While an export is
+// queued or running, it is returned instead of queueing another one.
+func (s *AccountExportService) RequestExport(ctx context.Context, userID uuid.UUID) (*models.JobResponse, error) {
+	if s.blobs == nil {
+		return nil, ErrExportUnavailable
+	}
+
+	job, err := s.jobRepo.GetUnfinishedByKind(ctx, userID, models.JobKindAccountExport)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		return job.ToResponse(), nil
+	}
+
+	job = &models.Job{
+		UserID:  userID,
+		Kind:    models.JobKindAccountExport,
+		Payload: json.RawMessage(`{}`),
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	s.audit.RecordByUser(ctx, userID, models.AuditAccountExport, models.AuditTargetUser, userID.String(), models.AuditMetadata{
+		"jobId": job.ID.String(),
+	})
+	return job.ToResponse(), nil
+}
+
+// OpenExport opens the archive of a finished export of the user for
+// download
+func (s *AccountExportService) OpenExport(ctx context.Context, userID, jobID uuid.UUID) (*AccountExportFile, error) {
+	job, err := s.jobRepo.GetByID(ctx, jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Kind != models.JobKindAccountExport {
+		return nil, fmt.Errorf("job not found")
+	}
+	if job.Status != models.JobStatusSucceeded {
+		return nil, ErrExportNotReady
+	}
+	if job.ResultKey == nil || job.ResultSize == nil {
+		return nil, ErrExportExpired
+	}
+	if s.blobs == nil {
+		return nil, ErrExportUnavailable
+	}
+
+	s.audit.RecordByUser(ctx, userID, models.AuditAccountExportDownload, models.AuditTargetUser, userID.String(), models.AuditMetadata{
+		"jobId": job.ID.String(),
+	})
+
+	modTime := job.UpdatedAt
+	if job.FinishedAt != nil {
+		modTime = *job.FinishedAt
+	}
+	return &AccountExportFile{
+		ReadSeekCloser: storage.NewReadSeeker(ctx, s.blobs, *job.ResultKey, *job.ResultSize),
+		Name:           "account_export_" + modTime.UTC().Format("20060102_150405") + ".zip",
+		Size:           *job.ResultSize,
+		ModTime:        modTime,
+	}, nil
+}
+
+// CleanupExpiredExports deletes the archives of exports that finished
+// longer than the configured TTL ago and returns how many were deleted
+func (s *AccountExportService) CleanupExpiredExports(ctx context.Context) (int, error) {
+	keys, err := s.jobRepo.ExpireResults(ctx, s.config.TTL)
+	if err != nil {
+		return 0, err
+	}
+
+	deleteBlobs(ctx, s.blobs, keys...)
+	return len(keys), nil
+}
+
+// runExport writes the export of a job's user to object storage while it is
+// being built and records where it is. The number of files written is
+// reported as progress.
+func (s *AccountExportService) runExport(ctx context.Context, job *models.Job, progress *jobProgress) error {
+	if s.blobs == nil {
+		return ErrExportUnavailable
+	}
+
+	user, err := s.userRepo.GetByID(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	if err := progress.update(ctx, models.JobPhaseExporting, 0); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s.zip", exportPrefix(job.UserID), job.ID)
+	reader, pipe := io.Pipe()
+	stored := make(chan error, 1)
+	var size int64
+	go func() {
+		n, err := s.blobs.Put(ctx, key, reader)
+		size = n
+		// Unblocks the archive writer when storing failed before the end
+		reader.CloseWithError(err)
+		stored <- err
+	}()
+
+	archive := zip.NewWriter(pipe)
+	err = s.writeArchive(ctx, archive, user, progress)
+	if err == nil {
+		err = archive.Close()
+	}
+	pipe.CloseWithError(err)
+	if storeErr := <-stored; err == nil {
+		err = storeErr
+	}
+	if err != nil {
+		deleteBlobs(context.WithoutCancel(ctx), s.blobs, key)
+		return fmt.Errorf("failed to export account: %w", err)
+	}
+
+	if err := s.jobRepo.SetResult(ctx, job.ID, progress.workerID, key, size); err != nil {
+		deleteBlobs(context.WithoutCancel(ctx), s.blobs, key)
+		if errors.Is(err, repository.ErrJobOwnershipLost) {
+			return errJobLost
+		}
+		return err
+	}
+	return nil
+}
+
+// writeArchive writes the files of an export
+func (s *AccountExportService) writeArchive(ctx context.Context, archive *zip.Writer, user *models.User, progress *jobProgress) error {
+	files := 0
+	written := func() error {
+		files++
+		progress.set(models.JobPhaseExporting, files)
+		return context.Cause(ctx)
+	}
+
+	memberships, err := s.orgRepo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = addJSON(archive, "profile.json", accountExportProfile{
+		User:          user.ToResponse(),
+		Organizations: memberships,
+		ExportedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := written(); err != nil {
+		return err
+	}
+
+	// Imports, with their SQL and rollback script
+	afterID := uuid.Nil
+	for {
+		imports, err := s.importRepo.ListByUserAfter(ctx, user.ID, afterID, accountExportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, imp := range imports {
+			name := "imports/" + imp.ID.String()
+			if err := addJSON(archive, name+".json", imp.ToResponse()); err != nil {
+				return err
+			}
+			if err := s.addStored(ctx, archive, name+".sql", imp.SQLStorageKey, imp.GeneratedSQL); err != nil {
+				return err
+			}
+			if err := s.addStored(ctx, archive, name+".rollback.sql", imp.RollbackKey, imp.RollbackSQL); err != nil {
+				return err
+			}
+			if err := written(); err != nil {
+				return err
+			}
+			afterID = imp.ID
+		}
+
+		if len(imports) < accountExportBatchSize {
+			break
+		}
+	}
+
+	// Workflow sessions, with their schema and data file
+	afterID = uuid.Nil
+	for {
+		sessions, err := s.workflowSessionRepo.ListByUserAfter(ctx, user.ID, afterID, accountExportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, session := range sessions {
+			name := "workflow_sessions/" + session.ID.String()
+			if err := addJSON(archive, name+".json", session); err != nil {
+				return err
+			}
+			if err := s.addStored(ctx, archive, name+".schema.sql", session.SchemaStorageKey, session.SchemaContent); err != nil {
+				return err
+			}
+			// Rows as gzip-compressed JSON lines, headers first
+			if err := s.addStored(ctx, archive, name+".data.jsonl.gz", session.DataStorageKey, nil); err != nil {
+				return err
+			}
+			if err := written(); err != nil {
+				return err
+			}
+			afterID = session.ID
+		}
+
+		if len(sessions) < accountExportBatchSize {
+			break
+		}
+	}
+
+	// Audit events the user caused, one per line
+	events, err := archive.CreateHeader(newZipHeader("audit_events.ndjson"))
+	if err != nil {
+		return fmt.Errorf("failed to add audit events: %w", err)
+	}
+	encoder := json.NewEncoder(events)
+	var afterEventID int64
+	for {
+		batch, err := s.auditRepo.ListByActorAfter(ctx, user.ID, afterEventID, auditExportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range batch {
+			if err := encoder.Encode(event); err != nil {
+				return fmt.Errorf("failed to add audit events: %w", err)
+			}
+			afterEventID = event.ID
+		}
+
+		if len(batch) < auditExportBatchSize {
+			break
+		}
+	}
+	return written()
+}
+
+// addStored adds a file with content kept under key in object storage, or
+// compressed in the database. Nothing is added when there is neither.
+func (s *AccountExportService) addStored(ctx context.Context, archive *zip.Writer, name string, key *string, compressed *string) error {
+	if key != nil {
+		rc, err := s.blobs.Get(ctx, *key)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer rc.Close()
+
+		w, err := archive.CreateHeader(newZipHeader(name))
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", name, err)
+		}
+		if _, err := io.Copy(w, rc); err != nil {
+			return fmt.Errorf("failed to add %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if compressed == nil || *compressed == "" {
+		return nil
+	}
+	content, err := decompressSQL(*compressed)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", name, err)
+	}
+
+	w, err := archive.CreateHeader(newZipHeader(name))
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, strings.NewReader(content)); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return nil
+}
+
+// addJSON adds a file holding v as indented JSON
+func addJSON(archive *zip.Writer, name string, v interface{}) error {
+	w, err := archive.CreateHeader(newZipHeader(name))
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return nil
+}
+
+// newZipHeader describes a compressed file of an export
+func newZipHeader(name string) *zip.FileHeader {
+	return &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+	"db-importer/mailer"
+	"db-importer/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailTokenInvalid    = errors.New("link is invalid, expired or already used")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+	ErrEmailThrottled       = errors.New("an email was sent recently, try again in a minute")
+	ErrDeletionConfirmation = errors.New("confirm the deletion with your password, or your email address if you have none")
+	ErrDeletionNotScheduled = errors.New("account deletion is not scheduled")
+)
+
+// SoleOwnerError rejects deleting the account of the only owner of
+// organizations that have other members
+type SoleOwnerError struct {
+	Organizations []string
+}
+
+func (e *SoleOwnerError) Error() string {
+	return fmt.Sprintf("you are the only owner of %s; make another member owner or remove the other members first", strings.Join(e.Organizations, ", "))
+}
+
+const (
+	// emailVerificationTTL is how long a verification link works
+	emailVerificationTTL = 48 * time.Hour
+	// passwordResetTTL is how long a password reset link works
+	passwordResetTTL = time.Hour
+	// passwordResetSendTimeout bounds creating and sending a reset link in
+	// the background
+	passwordResetSendTimeout = time.Minute
+	// emailResendInterval is the minimum time between two emails of the
+	// same kind to a user
+	emailResendInterval = time.Minute
+	// accountPurgeBatchSize is how many due accounts a cleanup run deletes
+	accountPurgeBatchSize = 100
+)
+
+// AccountConfig configures the links sent by AccountService and account
+// deletion
+type AccountConfig struct {
+	TokenSecret   []byte        // signs the tokens in links
+	AppURL        string        // frontend base URL the links point to
+	DeletionGrace time.Duration // how long a deletion can be canceled
+}
+
+// AccountService verifies email addresses, resets forgotten passwords with
+// single-use links sent by email, and deletes accounts the grace period
+// after their users asked for it
+type AccountService struct {
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	emailTokenRepo   *repository.EmailTokenRepository
+	orgRepo          *repository.OrganizationRepository
+	auditRepo        *repository.AuditRepository
+	uploads          *UploadService
+	blobs            storage.Backend
+	mailer           mailer.Sender
+	audit            *AuditService
+	config           AccountConfig
+}
+
+// NewAccountService creates a new AccountService. uploads and blobs are nil
+// without object storage.
+func NewAccountService(
+	userRepo *repository.UserRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	emailTokenRepo *repository.EmailTokenRepository,
+	orgRepo *repository.OrganizationRepository,
+	auditRepo *repository.AuditRepository,
+	uploads *UploadService,
+	blobs storage.Backend,
+	sender mailer.Sender,
+	audit *AuditService,
+	config AccountConfig,
+) *AccountService {
+	return &AccountService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		emailTokenRepo:   emailTokenRepo,
+		orgRepo:          orgRepo,
+		auditRepo:        auditRepo,
+		uploads:          uploads,
+		blobs:            blobs,
+		mailer:           sender,
+		audit:            audit,
+		config:           config,
+	}
+}
+
+// SendVerificationEmail emails the user a link confirming their address
+func (s *AccountService) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issueToken(ctx, user, utils.EmailTokenVerify, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Confirm that %s is your email address by opening this link:\n\n%s\n\n"+
+			"The link works for %d hours. If you did not create an account, ignore this email.\n",
+			user.Email, s.link("/verify-email", token), int(emailVerificationTTL.Hours())),
+	})
+}
+
+// VerifyEmail marks the address of the user a verification link was sent
+// to as verified
+func (s *AccountService) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) (*models.UserResponse, error) {
+	id, err := utils.ParseEmailToken(s.config.TokenSecret, utils.EmailTokenVerify, req.Token)
+	if err != nil {
+		return nil, ErrEmailTokenInvalid
+	}
+
+	userID, err := s.emailTokenRepo.VerifyEmail(ctx, id)
+	if err != nil {
+		if err.Error() == "email token not found" {
+			return nil, ErrEmailTokenInvalid
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
+
+// RequestPasswordReset emails a password reset link to the address if it
+// belongs to an active account. The link is created and sent in the
+// background, so that the response takes as long whether or not the address
+// has an account; failures are only logged.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, req *models.ForgotPasswordRequest) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetSendTimeout)
+	go func() {
+		defer cancel()
+		s.sendPasswordReset(ctx, req.Email)
+	}()
+}
+
+// sendPasswordReset emails a password reset link to the account using
+// email, if there is an active one
+func (s *AccountService) sendPasswordReset(ctx context.Context, email string) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || !user.IsActive {
+		return
+	}
+
+	token, err := s.issueToken(ctx, user, utils.EmailTokenReset, passwordResetTTL)
+	if errors.Is(err, ErrEmailThrottled) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create password reset link", err, map[string]interface{}{
+			"userId": user.ID.String(),
+		})
+		return
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your account %s. Choose a new password by opening this link:\n\n%s\n\n"+
+			"The link works for %d minutes and only once. If you did not ask for it, ignore this email; your password stays the same.\n",
+			user.Email, s.link("/reset-password", token), int(passwordResetTTL.Minutes())),
+	})
+	if err != nil {
+		logger.Error("Failed to send password reset email", err, map[string]interface{}{
+			"userId": user.ID.String(),
+		})
+	}
+}
+
+// ResetPassword sets a new password for the user a reset link was sent to
+// and signs them out everywhere by revoking all their refresh tokens
+func (s *AccountService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	id, err := utils.ParseEmailToken(s.config.TokenSecret, utils.EmailTokenReset, req.Token)
+	if err != nil {
+		return ErrEmailTokenInvalid
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	userID, err := s.emailTokenRepo.ResetPassword(ctx, id, hashedPassword)
+	if err != nil {
+		if err.Error() == "email token not found" {
+			return ErrEmailTokenInvalid
+		}
+		return err
+	}
+
+	return s.refreshTokenRepo.RevokeAllForUser(ctx, userID)
+}
+
+// CleanupExpiredTokens deletes email tokens that expired over a day ago
+func (s *AccountService) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	return s.emailTokenRepo.DeleteExpired(ctx, 24*time.Hour)
+}
+
+// ScheduleDeletion schedules the deletion of the user's account after the
+// grace period, during which CancelDeletion undoes it. Users confirm with
+// their password, or their email address when they only sign in with
+// single sign-on. The only owner of organizations with other members has
+// to hand them over first.
+func (s *AccountService) ScheduleDeletion(ctx context.Context, userID uuid.UUID, req *models.DeleteAccountRequest) (*models.AccountDeletionResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PasswordHash != "" {
+		if req.Password == "" || utils.CheckPassword(req.Password, user.PasswordHash) != nil {
+			return nil, ErrDeletionConfirmation
+		}
+	} else if !strings.EqualFold(strings.TrimSpace(req.Email), user.Email) {
+		return nil, ErrDeletionConfirmation
+	}
+
+	owned, err := s.orgRepo.ListSoleOwnedShared(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(owned) > 0 {
+		return nil, &SoleOwnerError{Organizations: owned}
+	}
+
+	scheduledAt, err := s.userRepo.ScheduleDeletion(ctx, userID, s.config.DeletionGrace)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.RecordByUser(ctx, userID, models.AuditAccountDeletionSet, models.AuditTargetUser, userID.String(), models.AuditMetadata{
+		"deletionScheduledAt": scheduledAt,
+	})
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Text: fmt.Sprintf("The account %s and everything stored with it will be deleted for good on %s.\n\n"+
+			"Changed your mind? Sign in before then and cancel the deletion in your account settings:\n\n%s\n",
+			user.Email, scheduledAt.UTC().Format("January 2, 2006 at 15:04 UTC"), strings.TrimRight(s.config.AppURL, "/")),
+	})
+	if err != nil {
+		logger.Error("Failed to send account deletion email", err, map[string]interface{}{
+			"userId": user.ID.String(),
+		})
+	}
+
+	return &models.AccountDeletionResponse{DeletionScheduledAt: scheduledAt}, nil
+}
+
+// CancelDeletion cancels the scheduled deletion of the user's account
+func (s *AccountService) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	canceled, err := s.userRepo.CancelDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !canceled {
+		return ErrDeletionNotScheduled
+	}
+
+	s.audit.RecordByUser(ctx, userID, models.AuditAccountDeletionCancel, models.AuditTargetUser, userID.String(), nil)
+	return nil
+}
+
+// PurgeDueAccounts deletes the accounts whose grace period has ended, with
+// their imports, workflow sessions, refresh tokens and stored files, and
+// the organizations they were the only member of. What they created in
+// other organizations is handed to a remaining member. Their audit events are
+// kept without their email address, IP addresses and user agents. It
+// returns how many accounts were deleted.
+func (s *AccountService) PurgeDueAccounts(ctx context.Context) (int, error) {
+	users, err := s.userRepo.ListDueForDeletion(ctx, accountPurgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, user := range users {
+		purged, err := s.purgeAccount(ctx, user)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete account %s: %w", user.ID, err)
+		}
+		if purged {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// purgeAccount deletes an account that is due. It returns false when the
+// deletion was canceled meanwhile or has to wait for the user's
+// organizations to get another owner.
+func (s *AccountService) purgeAccount(ctx context.Context, user *models.User) (bool, error) {
+	// Members may have joined since the deletion was scheduled
+	owned, err := s.orgRepo.ListSoleOwnedShared(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(owned) > 0 {
+		logger.Warn("Account deletion postponed: the user is the only owner of organizations with other members", map[string]interface{}{
+			"userId":        user.ID.String(),
+			"organizations": owned,
+		})
+		return false, nil
+	}
+
+	soleOrgs, err := s.orgRepo.ListSoleMember(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if s.uploads != nil {
+		if err := s.uploads.DeleteUserUploads(ctx, user.ID); err != nil {
+			return false, err
+		}
+	}
+
+	keys, purged, err := s.userRepo.Purge(ctx, user.ID)
+	if err != nil || !purged {
+		return false, err
+	}
+
+	// The user's memberships went with them, leaving their own
+	// organizations empty
+	for _, orgID := range soleOrgs {
+		orgKeys, _, err := s.orgRepo.DeleteIfEmpty(ctx, orgID)
+		if err != nil {
+			logger.Warn("Failed to delete organization of deleted account", map[string]interface{}{
+				"organizationId": orgID.String(),
+				"error":          err.Error(),
+			})
+			continue
+		}
+		keys = append(keys, orgKeys...)
+	}
+
+	// Files of what was handed over to other members are kept, so only the
+	// deleted ones are removed by key
+	deleteBlobs(ctx, s.blobs, keys...)
+	if s.blobs != nil {
+		if err := s.blobs.DeletePrefix(ctx, exportPrefix(user.ID)); err != nil {
+			logger.Warn("Failed to delete stored files of deleted account", map[string]interface{}{
+				"prefix": exportPrefix(user.ID),
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	if _, err := s.auditRepo.RedactActor(ctx, user.ID, user.Email); err != nil {
+		logger.Warn("Failed to redact audit events of deleted account", map[string]interface{}{
+			"userId": user.ID.String(),
+			"error":  err.Error(),
+		})
+	}
+	// Recorded after the user is gone, so without their email address
+	userID := user.ID
+	s.audit.Record(ctx, &models.AuditEvent{
+		ActorID:    &userID,
+		Action:     models.AuditAccountDelete,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Metadata:   models.AuditMetadata{"organizationsDeleted": len(soleOrgs)},
+	})
+
+	logger.Info("Account deleted", map[string]interface{}{
+		"userId": user.ID.String(),
+	})
+	return true, nil
+}
+
+// issueToken stores a new token of the purpose for the user, superseding
+// earlier ones, and returns it signed. It fails with ErrEmailThrottled when
+// one was issued less than emailResendInterval ago.
+func (s *AccountService) issueToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	recent, err := s.emailTokenRepo.CreatedSince(ctx, user.ID, purpose, time.Now().Add(-emailResendInterval))
+	if err != nil {
+		return "", err
+	}
+	if recent {
+		return "", ErrEmailThrottled
+	}
+
+	token := &models.EmailToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.emailTokenRepo.Create(ctx, token); err != nil {
+		return "", err
+	}
+
+	return utils.SignEmailToken(s.config.TokenSecret, purpose, token.ID, token.ExpiresAt), nil
+}
+
+// link builds a frontend link carrying token
+func (s *AccountService) link(path, token string) string {
+	return strings.TrimRight(s.config.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
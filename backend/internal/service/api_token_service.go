@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+
+	"github.com/google/uuid"
+)
+
+// maxActiveAPITokens is how many tokens a user can have that are neither
+// revoked nor expired. Each token has its own rate limit bucket besides its
+// owner's, so they are capped as well.
+const maxActiveAPITokens = 20
+
+var (
+	// ErrInvalidAPIToken is returned for unknown, revoked and expired
+	// personal access tokens
+	ErrInvalidAPIToken = errors.New("invalid or expired API token")
+	// ErrTooManyAPITokens is returned when creating a token beyond
+	// maxActiveAPITokens
+	ErrTooManyAPITokens = fmt.Errorf("a user can have at most %d active API tokens; revoke one first", maxActiveAPITokens)
+)
+
+// APITokenService manages personal access tokens and authenticates
+// requests made with them
+type APITokenService struct {
+	tokenRepo *repository.APITokenRepository
+	audit     *AuditService
+}
+
+// NewAPITokenService creates a new APITokenService
+func NewAPITokenService(tokenRepo *repository.APITokenRepository, audit *AuditService) *APITokenService {
+	return &APITokenService{tokenRepo: tokenRepo, audit: audit}
+}
+
+// CreateToken creates a personal access token. The returned token value is
+// not stored and cannot be shown again. Users with maxActiveAPITokens
+// active tokens get ErrTooManyAPITokens.
+func (s *APITokenService) CreateToken(ctx context.Context, userID uuid.UUID, req *models.CreateAPITokenRequest) (*models.CreateAPITokenResponse, error) {
+	value, err := utils.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Scopes are kept sorted and without duplicates
+	seen := make(map[string]bool, len(req.Scopes))
+	scopes := models.TokenScopes{}
+	for _, scope := range req.Scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+
+	token := &models.APIToken{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		TokenHash:   utils.HashAPIToken(value),
+		TokenPrefix: utils.APITokenDisplayPrefix(value),
+		Scopes:      scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+
+	created, err := s.tokenRepo.Create(ctx, token, maxActiveAPITokens)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrTooManyAPITokens
+	}
+
+	metadata := models.AuditMetadata{"name": token.Name, "scopes": []string(token.Scopes)}
+	if token.ExpiresAt != nil {
+		metadata["expiresAt"] = token.ExpiresAt
+	}
+	s.audit.RecordByUser(ctx, userID, models.AuditAPITokenCreate, models.AuditTargetAPIToken, token.ID.String(), metadata)
+
+	return &models.CreateAPITokenResponse{APIToken: token, Token: value}, nil
+}
+
+// ListTokens lists the personal access tokens of a user, including revoked
+// and expired ones
+func (s *APITokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	return s.tokenRepo.ListByUserID(ctx, userID)
+}
+
+// RevokeToken revokes a personal access token; requests made with it are
+// refused from then on
+func (s *APITokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	if err := s.tokenRepo.Revoke(ctx, tokenID, userID); err != nil {
+		return err
+	}
+
+	s.audit.RecordByUser(ctx, userID, models.AuditAPITokenRevoke, models.AuditTargetAPIToken, tokenID.String(), nil)
+	return nil
+}
+
+// AuthenticateAPIToken resolves the token of a request and records its use
+func (s *APITokenService) AuthenticateAPIToken(ctx context.Context, value string) (*models.APIToken, error) {
+	token, err := s.tokenRepo.GetByTokenHash(ctx, utils.HashAPIToken(value))
+	if err != nil {
+		if err.Error() == "API token not found" {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+
+	if !token.IsValid() {
+		return nil, ErrInvalidAPIToken
+	}
+
+	// Losing a last-used timestamp is not worth failing the request
+	if err := s.tokenRepo.TouchLastUsed(ctx, token.ID); err != nil {
+		logger.Warn("Failed to record API token use", map[string]interface{}{
+			"tokenId": token.ID.String(),
+			"error":   err.Error(),
+		})
+	}
+
+	return token, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/logger"
+
+	"github.com/google/uuid"
+)
+
+// auditExportBatchSize is how many events an export reads at a time
+const auditExportBatchSize = 500
+
+// AuditService records who did what, from where, and lets users and
+// organization admins read it back. Callers name the actor, action and
+// target; the device comes from the request context.
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+// NewAuditService creates a new AuditService
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record adds an event to the audit log. Failing to record an event is
+// logged but does not fail the action, which has already happened. A nil
+// AuditService records nothing.
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent) {
+	if s == nil {
+		return
+	}
+
+	client := clientFromContext(ctx)
+	event.IPAddress = client.IPAddress
+	event.UserAgent = client.UserAgent
+	if tokenID, ok := ctx.Value("apiTokenID").(string); ok {
+		if event.Metadata == nil {
+			event.Metadata = models.AuditMetadata{}
+		}
+		event.Metadata["apiTokenId"] = tokenID
+	}
+
+	if err := s.auditRepo.Create(ctx, event); err != nil {
+		logger.Warn("Failed to record audit event", map[string]interface{}{
+			"action":   event.Action,
+			"targetId": event.TargetID,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// RecordInWorkspace records an event by the workspace's user, visible to
+// the admins of its organization if it has one
+func (s *AuditService) RecordInWorkspace(ctx context.Context, ws models.Workspace, action, targetType, targetID string, metadata models.AuditMetadata) {
+	userID := ws.UserID
+	s.Record(ctx, &models.AuditEvent{
+		ActorID:        &userID,
+		OrganizationID: ws.OrganizationID,
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		Metadata:       metadata,
+	})
+}
+
+// RecordByUser records a personal event of a user
+func (s *AuditService) RecordByUser(ctx context.Context, userID uuid.UUID, action, targetType, targetID string, metadata models.AuditMetadata) {
+	s.RecordInWorkspace(ctx, models.Workspace{UserID: userID}, action, targetType, targetID, metadata)
+}
+
+// ListEvents lists the audit events of the workspace matching the filter,
+// newest first
+func (s *AuditService) ListEvents(ctx context.Context, ws models.Workspace, filter *models.AuditFilter) (*models.GetAuditEventsResponse, error) {
+	events, total, err := s.auditRepo.List(ctx, ws, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := 1
+	pageSize := 20
+	if filter.Page > 0 {
+		page = filter.Page
+	}
+	if filter.PageSize > 0 && filter.PageSize <= 100 {
+		pageSize = filter.PageSize
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	return &models.GetAuditEventsResponse{
+		Events:     events,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// ExportEvents passes every audit event of the workspace matching the
+// filter to write, oldest first, ignoring the filter's pagination. The
+// export itself is recorded.
+func (s *AuditService) ExportEvents(ctx context.Context, ws models.Workspace, filter *models.AuditFilter, write func(*models.AuditEvent) error) error {
+	s.RecordInWorkspace(ctx, ws, models.AuditExport, "", "", auditFilterMetadata(filter))
+
+	var afterID int64
+	for {
+		events, err := s.auditRepo.ListAfter(ctx, ws, filter, afterID, auditExportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := write(event); err != nil {
+				return err
+			}
+			afterID = event.ID
+		}
+
+		if len(events) < auditExportBatchSize {
+			return nil
+		}
+	}
+}
+
+// auditFilterMetadata describes the filter of an export
+func auditFilterMetadata(filter *models.AuditFilter) models.AuditMetadata {
+	metadata := models.AuditMetadata{}
+	if filter.Action != "" {
+		metadata["action"] = filter.Action
+	}
+	if filter.ActorID != nil {
+		metadata["actorId"] = filter.ActorID.String()
+	}
+	if filter.TargetType != "" {
+		metadata["targetType"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		metadata["targetId"] = filter.TargetID
+	}
+	if filter.From != nil {
+		metadata["from"] = filter.From
+	}
+	if filter.To != nil {
+		metadata["to"] = filter.To
+	}
+	return metadata
+}
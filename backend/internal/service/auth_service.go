@@ -1,14 +1,17 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"db-importer/internal/models"
 	"db-importer/internal/repository"
 	"db-importer/internal/utils"
+	"db-importer/logger"
 
 	"github.com/google/uuid"
 )
@@ -19,23 +22,86 @@ var (
 	ErrUserNotActive      = errors.New("user account is not active")
 )
 
+const (
+	// maxLoginDelay caps the delay between failed logins before lockout
+	maxLoginDelay = 30 * time.Second
+	// minLoginFailureWindow is how long failed logins count at least
+	minLoginFailureWindow = time.Hour
+)
+
+// LoginThrottledError rejects a login made too soon after failed ones
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // the account is locked, not just delayed
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account is temporarily locked after too many failed logins"
+	}
+	return "too many failed logins, wait before trying again"
+}
+
+// LoginPolicy limits password and two-factor code guessing per account.
+// Failed logins count for an hour (or LockoutDuration if longer) until a
+// login succeeds.
+type LoginPolicy struct {
+	DelayAfter      int           // failures after which each attempt waits 1s, 2s, 4s... up to 30s (0 = never)
+	LockoutAfter    int           // failures that lock the account (0 = never)
+	LockoutDuration time.Duration // how long a lockout lasts after the last failure
+}
+
+// failureWindow is how long failed logins count
+func (p LoginPolicy) failureWindow() time.Duration {
+	return max(minLoginFailureWindow, p.LockoutDuration)
+}
+
+// wait returns how long the next attempt has to wait after failures, the
+// last of which was sinceLast ago
+func (p LoginPolicy) wait(failures int, sinceLast time.Duration) time.Duration {
+	var delay time.Duration
+	switch {
+	case p.LockoutAfter > 0 && failures >= p.LockoutAfter:
+		delay = p.LockoutDuration
+	case p.DelayAfter > 0 && failures >= p.DelayAfter:
+		delay = min(time.Second<<min(failures-p.DelayAfter, 8), maxLoginDelay)
+	}
+
+	if sinceLast >= delay {
+		return 0
+	}
+	return delay - sinceLast
+}
+
 // AuthService handles authentication business logic
 type AuthService struct {
 	userRepo         *repository.UserRepository
 	refreshTokenRepo *repository.RefreshTokenRepository
+	loginAttemptRepo *repository.LoginAttemptRepository
+	mfaService       *MFAService
+	audit            *AuditService
 	jwtConfig        utils.JWTConfig
+	loginPolicy      LoginPolicy
 }
 
 // NewAuthService creates a new AuthService
 func NewAuthService(
 	userRepo *repository.UserRepository,
 	refreshTokenRepo *repository.RefreshTokenRepository,
+	loginAttemptRepo *repository.LoginAttemptRepository,
+	mfaService *MFAService,
+	audit *AuditService,
 	jwtConfig utils.JWTConfig,
+	loginPolicy LoginPolicy,
 ) *AuthService {
 	return &AuthService{
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		mfaService:       mfaService,
+		audit:            audit,
 		jwtConfig:        jwtConfig,
+		loginPolicy:      loginPolicy,
 	}
 }
 
@@ -71,26 +137,170 @@ func (s *AuthService) Register(ctx context.Context, req *models.CreateUserReques
 	return user.ToResponse(), nil
 }
 
-// Login authenticates a user and returns tokens
+// Login authenticates a user and returns tokens. Repeated failures for an
+// email address delay further attempts and then lock it out for a while.
 func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
+	attemptID, err := s.beginLoginAttempt(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
+		s.recordLoginFailure(ctx, nil, req.Email, "unknown_email")
 		return nil, ErrInvalidCredentials
 	}
 
 	// Check if user is active
 	if !user.IsActive {
+		s.recordLoginFailure(ctx, user, req.Email, "inactive")
 		return nil, ErrUserNotActive
 	}
 
 	// Verify password
 	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		s.recordLoginFailure(ctx, user, req.Email, "wrong_password")
 		return nil, ErrInvalidCredentials
 	}
 
+	resp, err := s.SignIn(ctx, user, req.RememberMe)
+	if err != nil {
+		return nil, err
+	}
+
+	// A correct password awaiting its code neither fails nor succeeds, so
+	// it cannot reset the failures of guessed codes
+	s.finishLoginAttempt(ctx, attemptID, !resp.MFARequired)
+	return resp, nil
+}
+
+// SignIn finishes the first step of a sign-in of an authenticated user.
+// Users with two-factor authentication get an MFA token to complete it with
+// CompleteMFALogin, everyone else a session.
+func (s *AuthService) SignIn(ctx context.Context, user *models.User, rememberMe bool) (*models.LoginResponse, error) {
+	enabled, err := s.mfaService.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+	if !enabled {
+		return s.StartSession(ctx, user, rememberMe, false)
+	}
+
+	token, err := s.mfaService.Challenge(ctx, user.ID, rememberMe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start two-factor authentication: %w", err)
+	}
+
+	return &models.LoginResponse{
+		MFARequired: true,
+		MFAToken:    token,
+	}, nil
+}
+
+// CompleteMFALogin finishes a sign-in with the MFA token of its first step
+// and a code, returning the session and whether it was started with
+// rememberMe. Wrong codes count as failed logins of the account.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, req *models.MFALoginRequest) (*models.LoginResponse, bool, error) {
+	challenge, err := s.mfaService.OpenChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !user.IsActive {
+		return nil, false, ErrUserNotActive
+	}
+
+	attemptID, err := s.beginLoginAttempt(ctx, user.Email)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.mfaService.CompleteChallenge(ctx, challenge, req.Code); err != nil {
+		if errors.Is(err, ErrMFAInvalidCode) {
+			s.recordLoginFailure(ctx, user, user.Email, "wrong_code")
+		}
+		return nil, false, err
+	}
+
+	session, err := s.StartSession(ctx, user, challenge.RememberMe, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.finishLoginAttempt(ctx, attemptID, true)
+	return session, challenge.RememberMe, nil
+}
+
+// beginLoginAttempt checks that the login policy allows another attempt
+// for the email address and records it as failed until it succeeds
+func (s *AuthService) beginLoginAttempt(ctx context.Context, email string) (int64, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	ipAddress := clientFromContext(ctx).IPAddress
+
+	return s.loginAttemptRepo.Begin(ctx, email, ipAddress, s.loginPolicy.failureWindow(), func(failures int, sinceLast time.Duration) error {
+		wait := s.loginPolicy.wait(failures, sinceLast)
+		if wait <= 0 {
+			return nil
+		}
+
+		locked := s.loginPolicy.LockoutAfter > 0 && failures >= s.loginPolicy.LockoutAfter
+		if locked {
+			logger.Warn("Login refused: account locked after failed logins", map[string]interface{}{
+				"failures":  failures,
+				"ipAddress": ipAddress,
+			})
+		}
+		return &LoginThrottledError{RetryAfter: wait, Locked: locked}
+	})
+}
+
+// finishLoginAttempt marks a login attempt succeeded or, when it awaits a
+// second step, discards it
+func (s *AuthService) finishLoginAttempt(ctx context.Context, id int64, succeeded bool) {
+	var err error
+	if succeeded {
+		err = s.loginAttemptRepo.Succeed(ctx, id)
+	} else {
+		err = s.loginAttemptRepo.Discard(ctx, id)
+	}
+	if err != nil {
+		logger.Warn("Failed to update login attempt", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// recordLoginFailure records a failed login for email, of user if the
+// address belongs to one
+func (s *AuthService) recordLoginFailure(ctx context.Context, user *models.User, email, reason string) {
+	event := &models.AuditEvent{
+		Action:     models.AuditLoginFailed,
+		TargetType: models.AuditTargetUser,
+		Metadata:   models.AuditMetadata{"email": email, "reason": reason},
+	}
+	if user != nil {
+		event.ActorID = &user.ID
+		event.TargetID = user.ID.String()
+	}
+	s.audit.Record(ctx, event)
+}
+
+// CleanupLoginAttempts deletes login attempts that no longer count
+func (s *AuthService) CleanupLoginAttempts(ctx context.Context) (int64, error) {
+	return s.loginAttemptRepo.DeleteOlderThan(ctx, s.loginPolicy.failureWindow())
+}
+
+// StartSession issues access and refresh tokens for an authenticated user.
+// The refresh token lasts 1 day, or 3 days with rememberMe. mfa marks a
+// session that passed two-factor authentication.
+func (s *AuthService) StartSession(ctx context.Context, user *models.User, rememberMe, mfa bool) (*models.LoginResponse, error) {
 	// Generate access token
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, s.jwtConfig)
+	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, mfa, s.jwtConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -98,7 +308,7 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 	// Determine refresh token expiry based on rememberMe
 	// RememberMe = true: 3 days, false: 1 day
 	refreshExpiry := 24 * time.Hour // 1 day
-	if req.RememberMe {
+	if rememberMe {
 		refreshExpiry = 72 * time.Hour // 3 days
 	}
 
@@ -109,17 +319,20 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		AccessExpiry:  s.jwtConfig.AccessExpiry,
 		RefreshExpiry: refreshExpiry,
 	}
-	refreshToken, _, err := utils.GenerateRefreshToken(user.ID, customConfig)
+	refreshToken, _, err := utils.GenerateRefreshToken(user.ID, mfa, customConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	// Store refresh token hash in database
+	// Store refresh token hash in database with the device it was issued to
+	client := clientFromContext(ctx)
 	tokenHash := utils.HashRefreshToken(refreshToken)
 	refreshTokenModel := &models.RefreshToken{
 		UserID:    user.ID,
 		TokenHash: tokenHash,
 		ExpiresAt: time.Now().Add(refreshExpiry),
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
 	}
 
 	if err := s.refreshTokenRepo.Create(ctx, refreshTokenModel); err != nil {
@@ -132,6 +345,10 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		fmt.Printf("Warning: failed to update last login: %v\n", err)
 	}
 
+	s.audit.RecordByUser(ctx, user.ID, models.AuditLogin, models.AuditTargetUser, user.ID.String(), models.AuditMetadata{
+		"rememberMe": rememberMe,
+	})
+
 	return &models.LoginResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -139,6 +356,17 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 	}, nil
 }
 
+// StartMFASession starts a session that passed two-factor authentication
+// for a user who just enabled it, in place of the sessions revoked by that
+func (s *AuthService) StartMFASession(ctx context.Context, userID uuid.UUID) (*models.LoginResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.StartSession(ctx, user, false, true)
+}
+
 // RefreshTokens generates new access and refresh tokens
 func (s *AuthService) RefreshTokens(ctx context.Context, refreshTokenString string) (*models.RefreshTokenResponse, error) {
 	// Validate refresh token
@@ -179,24 +407,28 @@ func (s *AuthService) RefreshTokens(ctx context.Context, refreshTokenString stri
 		fmt.Printf("Warning: failed to revoke old refresh token: %v\n", err)
 	}
 
-	// Generate new access token
-	newAccessToken, err := utils.GenerateAccessToken(user.ID, user.Email, s.jwtConfig)
+	// Generate new access token; the session keeps its two-factor state
+	newAccessToken, err := utils.GenerateAccessToken(user.ID, user.Email, claims.MFA, s.jwtConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate new refresh token
-	newRefreshToken, _, err := utils.GenerateRefreshToken(user.ID, s.jwtConfig)
+	newRefreshToken, _, err := utils.GenerateRefreshToken(user.ID, claims.MFA, s.jwtConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	// Store new refresh token
+	// Store new refresh token; it continues the session of the old one
+	client := clientFromContext(ctx)
 	newTokenHash := utils.HashRefreshToken(newRefreshToken)
 	refreshTokenModel := &models.RefreshToken{
-		UserID:    user.ID,
-		TokenHash: newTokenHash,
-		ExpiresAt: time.Now().Add(s.jwtConfig.RefreshExpiry),
+		UserID:     user.ID,
+		TokenHash:  newTokenHash,
+		ExpiresAt:  time.Now().Add(s.jwtConfig.RefreshExpiry),
+		UserAgent:  cmp.Or(client.UserAgent, storedToken.UserAgent),
+		IPAddress:  cmp.Or(client.IPAddress, storedToken.IPAddress),
+		SignedInAt: storedToken.SignedInAt,
 	}
 
 	if err := s.refreshTokenRepo.Create(ctx, refreshTokenModel); err != nil {
@@ -219,6 +451,10 @@ func (s *AuthService) Logout(ctx context.Context, refreshTokenString string) err
 		return fmt.Errorf("failed to logout: %w", err)
 	}
 
+	if token, err := s.refreshTokenRepo.GetByTokenHash(ctx, tokenHash); err == nil {
+		s.audit.RecordByUser(ctx, token.UserID, models.AuditLogout, models.AuditTargetUser, token.UserID.String(), nil)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"db-importer/internal/database"
+	"db-importer/internal/repository"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+)
+
+func TestLoginPolicy_Wait(t *testing.T) {
+	policy := LoginPolicy{DelayAfter: 3, LockoutAfter: 10, LockoutDuration: 15 * time.Minute}
+
+	tests := []struct {
+		name      string
+		failures  int
+		sinceLast time.Duration
+		want      time.Duration
+	}{
+		{"no failures", 0, 0, 0},
+		{"below delay", 2, 0, 0},
+		{"first delay", 3, 0, time.Second},
+		{"delay doubles", 4, 0, 2 * time.Second},
+		{"delay keeps doubling", 6, 0, 8 * time.Second},
+		{"delay is capped", 9, 0, maxLoginDelay},
+		{"delay partly waited", 5, 3 * time.Second, time.Second},
+		{"delay waited", 5, 4 * time.Second, 0},
+		{"lockout at threshold", 10, 0, 15 * time.Minute},
+		{"lockout past threshold", 12, time.Minute, 14 * time.Minute},
+		{"lockout expired", 10, 15 * time.Minute, 0},
+		{"lockout long expired", 10, time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.wait(tt.failures, tt.sinceLast); got != tt.want {
+				t.Errorf("wait(%d, %s) = %s, want %s", tt.failures, tt.sinceLast, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginPolicy_WaitDisabled(t *testing.T) {
+	if got := (LoginPolicy{}).wait(100, 0); got != 0 {
+		t.Errorf("wait without delay or lockout = %s, want 0", got)
+	}
+}
+
+// testDB connects to TEST_DATABASE_URL and migrates it, or skips the test
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	m, err := migrate.New("file://../../migrations", url)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	db, err := database.NewDB(database.Config{URL: url, MaxOpenConns: 20, MaxIdleConns: 5})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestBeginLoginAttempt_ConcurrentAttemptsHitLockout(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	const lockoutAfter = 3
+	s := &AuthService{
+		loginAttemptRepo: repository.NewLoginAttemptRepository(db),
+		loginPolicy:      LoginPolicy{LockoutAfter: lockoutAfter, LockoutDuration: time.Minute},
+	}
+	email := "lockout-" + uuid.NewString() + "@example.com"
+	t.Cleanup(func() {
+		db.Sqlx.Exec(`DELETE FROM login_attempts WHERE email = $1`, email)
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, locked := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.beginLoginAttempt(ctx, email)
+
+			mu.Lock()
+			defer mu.Unlock()
+			var throttled *LoginThrottledError
+			switch {
+			case err == nil:
+				allowed++
+			case errors.As(err, &throttled) && throttled.Locked:
+				locked++
+			default:
+				t.Errorf("beginLoginAttempt failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != lockoutAfter {
+		t.Errorf("%d concurrent attempts got through, want %d", allowed, lockoutAfter)
+	}
+	if allowed+locked != 20 {
+		t.Errorf("%d attempts allowed and %d locked, want 20 in total", allowed, locked)
+	}
+}
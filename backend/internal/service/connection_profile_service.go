@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/introspect"
+	"db-importer/parser"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrConnectionProfileExists = errors.New("connection profile name already exists")
+	ErrSQLiteNotAllowed        = errors.New("sqlite connections are disabled or outside SQLITE_INTROSPECTION_DIR")
+	ErrConnectionFailed        = errors.New("could not connect to database")
+	ErrInvalidDSN              = errors.New("invalid connection string")
+)
+
+// ConnectionProfileService manages saved database connections and reads
+// schemas from them
+type ConnectionProfileService struct {
+	profileRepo *repository.ConnectionProfileRepository
+	orgs        *OrganizationService
+	cipher      *utils.SecretCipher // nil when no encryption key is configured
+	sqliteDir   string
+}
+
+// NewConnectionProfileService creates a new ConnectionProfileService.
+// cipher may be nil, in which case profiles cannot be created or used.
+func NewConnectionProfileService(profileRepo *repository.ConnectionProfileRepository, orgs *OrganizationService, cipher *utils.SecretCipher, sqliteDir string) *ConnectionProfileService {
+	return &ConnectionProfileService{
+		profileRepo: profileRepo,
+		orgs:        orgs,
+		cipher:      cipher,
+		sqliteDir:   sqliteDir,
+	}
+}
+
+// CreateProfile encrypts and stores a connection, optionally testing it first
+func (s *ConnectionProfileService) CreateProfile(ctx context.Context, userID uuid.UUID, req *models.CreateConnectionProfileRequest) (*models.ConnectionProfileResponse, error) {
+	if s.cipher == nil {
+		return nil, utils.ErrEncryptionKeyMissing
+	}
+
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, err := parser.ParseDialect(req.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn, err := s.resolveDSN(dialect, req.DSN, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Test {
+		if err := introspect.Ping(ctx, dialect, dsn); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		}
+	}
+
+	encrypted, err := s.cipher.Encrypt(req.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt connection string: %w", err)
+	}
+
+	profile := &models.ConnectionProfile{
+		UserID:         userID,
+		OrganizationID: ws.OrganizationID,
+		Name:           req.Name,
+		Dialect:        string(dialect),
+		EncryptedDSN:   encrypted,
+		DisplayDSN:     introspect.RedactDSN(req.DSN),
+	}
+	if req.DBSchema != "" {
+		profile.DBSchema = &req.DBSchema
+	}
+
+	if err := s.profileRepo.Create(ctx, profile); err != nil {
+		if err.Error() == ErrConnectionProfileExists.Error() {
+			return nil, ErrConnectionProfileExists
+		}
+		return nil, err
+	}
+
+	return profile.ToResponse(), nil
+}
+
+// ListProfiles lists the connection profiles of the workspace
+func (s *ConnectionProfileService) ListProfiles(ctx context.Context, userID uuid.UUID) ([]*models.ConnectionProfileResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := s.profileRepo.List(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.ConnectionProfileResponse, len(profiles))
+	for i := range profiles {
+		responses[i] = profiles[i].ToResponse()
+	}
+
+	return responses, nil
+}
+
+// DeleteProfile deletes a connection profile
+func (s *ConnectionProfileService) DeleteProfile(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	return s.profileRepo.Delete(ctx, id, ws)
+}
+
+// IntrospectProfile connects with a saved profile and reads its tables
+func (s *ConnectionProfileService) IntrospectProfile(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]parser.Table, *models.ConnectionProfile, error) {
+	profile, dsn, err := s.profileDSN(ctx, id, userID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts introspect.Options
+	if profile.DBSchema != nil {
+		opts.Schema = *profile.DBSchema
+	}
+
+	tables, err := introspect.Introspect(ctx, parser.Dialect(profile.Dialect), dsn, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+
+	if err := s.profileRepo.TouchLastUsed(ctx, profile.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return tables, profile, nil
+}
+
+// OpenProfile connects with a saved profile for writing; the caller must
+// close the returned pool
+func (s *ConnectionProfileService) OpenProfile(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*sql.DB, *models.ConnectionProfile, error) {
+	profile, dsn, err := s.profileDSN(ctx, id, userID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := introspect.Open(parser.Dialect(profile.Dialect), dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+	}
+
+	if err := s.profileRepo.TouchLastUsed(ctx, profile.ID); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, profile, nil
+}
+
+// profileDSN loads a profile and decrypts its connection string. Any member
+// of an organization may read through its profiles; writing takes an
+// editor.
+func (s *ConnectionProfileService) profileDSN(ctx context.Context, id uuid.UUID, userID uuid.UUID, readOnly bool) (*models.ConnectionProfile, string, error) {
+	if s.cipher == nil {
+		return nil, "", utils.ErrEncryptionKeyMissing
+	}
+
+	minRole := models.RoleEditor
+	if readOnly {
+		minRole = models.RoleViewer
+	}
+	ws, err := s.orgs.Workspace(ctx, userID, minRole)
+	if err != nil {
+		return nil, "", err
+	}
+
+	profile, err := s.profileRepo.GetByID(ctx, id, ws)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rawDSN, err := s.cipher.Decrypt(profile.EncryptedDSN)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt connection string (was CREDENTIALS_ENCRYPTION_KEY changed?): %w", err)
+	}
+
+	dsn, err := s.resolveDSN(parser.Dialect(profile.Dialect), rawDSN, readOnly)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return profile, dsn, nil
+}
+
+// resolveDSN validates a connection string for the dialect. SQLite paths are
+// confined to the configured directory, so profiles cannot be used to read
+// arbitrary files on the server, and are opened read-only unless writing.
+// Existing files only are opened either way. Other dialects refuse driver
+// parameters that read local files.
+func (s *ConnectionProfileService) resolveDSN(dialect parser.Dialect, dsn string, readOnly bool) (string, error) {
+	if _, err := introspect.DriverName(dialect); err != nil {
+		return "", err
+	}
+	if dialect != parser.DialectSQLite {
+		safe, err := introspect.SafeDSN(dialect, dsn)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidDSN, err)
+		}
+		return safe, nil
+	}
+
+	if s.sqliteDir == "" {
+		return "", ErrSQLiteNotAllowed
+	}
+
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.sqliteDir, path)
+	}
+
+	base, err := filepath.Abs(s.sqliteDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrSQLiteNotAllowed
+	}
+
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	return "file:" + filepath.Join(base, rel) + "?mode=" + mode, nil
+}
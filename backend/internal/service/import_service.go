@@ -4,26 +4,243 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
 
+	"db-importer/executor"
+	"db-importer/generator"
+	"db-importer/ingest"
 	"db-importer/internal/models"
 	"db-importer/internal/repository"
+	"db-importer/logger"
+	"db-importer/parser"
+	"db-importer/storage"
+	"db-importer/transform"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrInvalidExecution    = errors.New("invalid execution options")
+	ErrNothingToExecute    = errors.New("no mapped columns to insert")
+	ErrRollbackUnavailable = errors.New("import has no rollback script")
+	ErrImportNotRerunnable = errors.New("import does not record its table definition and mapping")
+	ErrInvalidMapping      = errors.New("invalid mapping")
+
+	errNothingCommitted = errors.New("nothing was committed")
+)
+
+// DataValidationError lists data that does not match the target fields
+type DataValidationError struct {
+	Errors []string
+}
+
+func (e *DataValidationError) Error() string {
+	return fmt.Sprintf("data validation failed with %d errors", len(e.Errors))
+}
+
 // ImportService handles import business logic
 type ImportService struct {
-	importRepo *repository.ImportRepository
+	importRepo         *repository.ImportRepository
+	orgs               *OrganizationService
+	connectionProfiles *ConnectionProfileService
+	uploads            *UploadService  // data files of re-runs
+	blobs              storage.Backend // nil keeps SQL compressed in the database
+	audit              *AuditService
 }
 
-// NewImportService creates a new ImportService
-func NewImportService(importRepo *repository.ImportRepository) *ImportService {
+// NewImportService creates a new ImportService. Generated SQL is kept in
+// blobs when given, otherwise compressed in the imports table.
+func NewImportService(importRepo *repository.ImportRepository, orgs *OrganizationService, connectionProfiles *ConnectionProfileService, uploads *UploadService, blobs storage.Backend, audit *AuditService) *ImportService {
 	return &ImportService{
-		importRepo: importRepo,
+		importRepo:         importRepo,
+		orgs:               orgs,
+		connectionProfiles: connectionProfiles,
+		uploads:            uploads,
+		blobs:              blobs,
+		audit:              audit,
+	}
+}
+
+// ImportSQLFile is the generated SQL of an import opened for download
+type ImportSQLFile struct {
+	io.ReadSeeker
+	Name     string
+	Size     int64
+	Checksum string // SHA-256, hex encoded
+	ModTime  time.Time
+	closer   io.Closer
+}
+
+// Close releases the underlying object
+func (f *ImportSQLFile) Close() error {
+	if f.closer != nil {
+		return f.closer.Close()
+	}
+	return nil
+}
+
+// storeBlob writes content to the backend and returns its SHA-256 and size
+func storeBlob(ctx context.Context, backend storage.Backend, key, content string) (string, int64, error) {
+	hash := sha256.New()
+	size, err := backend.Put(ctx, key, io.TeeReader(strings.NewReader(content), hash))
+	if err != nil {
+		backend.Delete(context.WithoutCancel(ctx), key)
+		return "", 0, fmt.Errorf("failed to store object: %w", err)
 	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// readBlob reads a whole object
+func readBlob(ctx context.Context, backend storage.Backend, key string) (string, error) {
+	if backend == nil {
+		return "", fmt.Errorf("object storage is not configured")
+	}
+
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to open object: %w", err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object: %w", err)
+	}
+	return string(content), nil
+}
+
+// deleteBlobs removes objects that are no longer referenced; failures are
+// only logged since the records are already gone
+func deleteBlobs(ctx context.Context, backend storage.Backend, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if backend == nil {
+			logger.Warn("Cannot delete object: object storage is not configured", map[string]interface{}{
+				"key": key,
+			})
+			continue
+		}
+		if err := backend.Delete(ctx, key); err != nil {
+			logger.Warn("Failed to delete object", map[string]interface{}{
+				"key":   key,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// setGeneratedSQL stores the SQL of a new import in object storage, or
+// compressed on the record when there is none
+func (s *ImportService) setGeneratedSQL(ctx context.Context, imp *models.Import, sql string) error {
+	if s.blobs == nil {
+		compressedSQL, err := compressSQL(sql)
+		if err != nil {
+			return fmt.Errorf("failed to compress SQL: %w", err)
+		}
+		imp.GeneratedSQL = &compressedSQL
+		return nil
+	}
+
+	key := fmt.Sprintf("imports/%s/%s.sql", imp.UserID, uuid.New())
+	checksum, size, err := storeBlob(ctx, s.blobs, key, sql)
+	if err != nil {
+		return fmt.Errorf("failed to store SQL: %w", err)
+	}
+
+	imp.SQLStorageKey = &key
+	imp.SQLChecksum = &checksum
+	imp.SQLSize = &size
+	return nil
+}
+
+// setRollback stores the undo script of a new import like its SQL and
+// flags it in the metadata. A script that could not be generated (genErr)
+// is recorded as unavailable with the reason.
+func (s *ImportService) setRollback(ctx context.Context, imp *models.Import, rb *generator.Rollback, genErr error) error {
+	if genErr != nil {
+		imp.Metadata.Rollback = &models.ImportRollback{Reason: genErr.Error()}
+		return nil
+	}
+
+	info := &models.ImportRollback{
+		Available:     true,
+		KeyColumns:    rb.KeyColumns,
+		RowsDeleted:   rb.Deleted,
+		RowsRestored:  rb.Restored,
+		RowsUnmatched: rb.Unmatched,
+	}
+
+	if s.blobs == nil {
+		compressed, err := compressSQL(rb.SQL)
+		if err != nil {
+			return fmt.Errorf("failed to compress rollback script: %w", err)
+		}
+		sum := sha256.Sum256([]byte(rb.SQL))
+		info.Checksum = hex.EncodeToString(sum[:])
+		info.Size = int64(len(rb.SQL))
+		imp.RollbackSQL = &compressed
+	} else {
+		key := fmt.Sprintf("imports/%s/%s.rollback.sql", imp.UserID, uuid.New())
+		checksum, size, err := storeBlob(ctx, s.blobs, key, rb.SQL)
+		if err != nil {
+			return fmt.Errorf("failed to store rollback script: %w", err)
+		}
+		info.Checksum = checksum
+		info.Size = size
+		imp.RollbackKey = &key
+	}
+
+	imp.Metadata.Rollback = info
+	return nil
+}
+
+// createImport inserts an import, removing its stored objects if that fails
+func (s *ImportService) createImport(ctx context.Context, imp *models.Import) error {
+	if err := s.importRepo.Create(ctx, imp); err != nil {
+		s.discardObjects(ctx, imp)
+		return err
+	}
+
+	ws := models.Workspace{UserID: imp.UserID, OrganizationID: imp.OrganizationID}
+	s.audit.RecordInWorkspace(ctx, ws, models.AuditImportCreate, models.AuditTargetImport, imp.ID.String(), models.AuditMetadata{
+		"tableName": imp.TableName,
+		"rowCount":  imp.RowCount,
+		"status":    imp.Status,
+	})
+	return nil
+}
+
+// recordSQLAccess records that the SQL or rollback script of an import was
+// read, and how
+func (s *ImportService) recordSQLAccess(ctx context.Context, ws models.Workspace, imp *models.Import, via string) {
+	s.audit.RecordInWorkspace(ctx, ws, models.AuditImportViewSQL, models.AuditTargetImport, imp.ID.String(), models.AuditMetadata{
+		"tableName": imp.TableName,
+		"via":       via,
+	})
+}
+
+// discardObjects deletes the stored SQL and rollback script of an import
+// that was not recorded
+func (s *ImportService) discardObjects(ctx context.Context, imp *models.Import) {
+	var keys []string
+	if imp.SQLStorageKey != nil {
+		keys = append(keys, *imp.SQLStorageKey)
+	}
+	if imp.RollbackKey != nil {
+		keys = append(keys, *imp.RollbackKey)
+	}
+	deleteBlobs(context.WithoutCancel(ctx), s.blobs, keys...)
 }
 
 // compressSQL compresses SQL using gzip and encodes to base64
@@ -77,38 +294,530 @@ func decompressSQL(compressedSQL string) (string, error) {
 	return buf.String(), nil
 }
 
-// CreateImport creates a new import record with compressed SQL
+// CreateImport creates a new import record in the workspace, storing its SQL
 func (s *ImportService) CreateImport(ctx context.Context, userID uuid.UUID, req *models.CreateImportRequest) (*models.ImportResponse, error) {
-	// Compress the SQL
-	compressedSQL, err := compressSQL(req.GeneratedSQL)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compress SQL: %w", err)
+		return nil, err
 	}
 
 	// Create import model
 	imp := &models.Import{
-		UserID:       userID,
-		TableName:    req.TableName,
-		RowCount:     req.RowCount,
-		Status:       req.Status,
-		GeneratedSQL: &compressedSQL,
-		ErrorCount:   req.ErrorCount,
-		WarningCount: req.WarningCount,
-		Metadata:     req.Metadata,
+		UserID:         userID,
+		OrganizationID: ws.OrganizationID,
+		TableName:      req.TableName,
+		RowCount:       req.RowCount,
+		Status:         req.Status,
+		ErrorCount:     req.ErrorCount,
+		WarningCount:   req.WarningCount,
+		Metadata:       req.Metadata,
+	}
+	if len(req.KeyColumns) > 0 && imp.Metadata.Options == nil {
+		imp.Metadata.Options = &models.ImportOptions{KeyColumns: req.KeyColumns}
+	}
+
+	if err := s.setGeneratedSQL(ctx, imp, req.GeneratedSQL); err != nil {
+		return nil, err
+	}
+
+	// The undo script is read back from the SQL; unknown dialects are
+	// treated like MySQL, as when generating
+	dialect, _ := parser.ParseDialect(req.Metadata.DatabaseType)
+	rb, rbErr := generator.RollbackForSQL(dialect, req.GeneratedSQL, req.KeyColumns)
+	if err := s.setRollback(ctx, imp, rb, rbErr); err != nil {
+		s.discardObjects(ctx, imp)
+		return nil, err
 	}
 
 	// Save to database
-	err = s.importRepo.Create(ctx, imp)
-	if err != nil {
+	if err := s.createImport(ctx, imp); err != nil {
 		return nil, fmt.Errorf("failed to create import: %w", err)
 	}
 
 	return imp.ToResponse(), nil
 }
 
+// ExecuteImport generates INSERT (or upsert) batches for the rows, runs them
+// against a saved connection and records the outcome as an executed or
+// rolled back import, with a script undoing the committed batches.
+// Database errors are part of the recorded result, not an error.
+func (s *ImportService) ExecuteImport(ctx context.Context, userID uuid.UUID, req *models.ExecuteImportRequest) (*models.ImportResponse, error) {
+	return s.ExecuteImportWithProgress(ctx, userID, req, nil)
+}
+
+// ExecuteImportWithProgress is ExecuteImport reporting the number of rows
+// run so far after every batch
+func (s *ImportService) ExecuteImportWithProgress(ctx context.Context, userID uuid.UUID, req *models.ExecuteImportRequest, progress func(rowsDone, rowsTotal int)) (*models.ImportResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	profileID, err := uuid.Parse(req.ProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid profile ID", ErrInvalidExecution)
+	}
+
+	opts, err := executor.Options{
+		Mode:         executor.Mode(req.Mode),
+		MaxErrors:    req.MaxErrors,
+		AllowPartial: req.AllowPartial,
+	}.Normalize()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExecution, err)
+	}
+
+	batchSize := req.BatchSize
+	if batchSize == 0 {
+		batchSize = executor.DefaultBatchSize
+	}
+
+	fields := make([]generator.FieldInfo, len(req.Fields))
+	var keyColumns []string
+	for i, f := range req.Fields {
+		fields[i] = generator.FieldInfo(f)
+		if f.Key {
+			keyColumns = append(keyColumns, f.Name)
+		}
+	}
+	if req.Upsert && len(keyColumns) == 0 {
+		return nil, fmt.Errorf("%w: upsert needs at least one key field", ErrInvalidExecution)
+	}
+	if validationErrors := generator.ValidateFieldTypes(req.Rows, fields, req.Mapping); len(validationErrors) > 0 {
+		return nil, &DataValidationError{Errors: validationErrors}
+	}
+
+	db, profile, err := s.connectionProfiles.OpenProfile(ctx, profileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	dialect := parser.Dialect(profile.Dialect)
+	var plan *generator.InsertPlan
+	if req.Upsert {
+		plan = generator.PlanUpsert(dialect, req.TableName, req.Mapping, req.Rows, fields, batchSize)
+	} else {
+		plan = generator.PlanInsert(dialect, req.TableName, req.Mapping, req.Rows, fields, batchSize)
+	}
+	if plan == nil {
+		return nil, ErrNothingToExecute
+	}
+
+	if progress != nil {
+		rowsDone := 0
+		opts.OnBatch = func(b executor.BatchResult) {
+			rowsDone += b.RowCount
+			progress(rowsDone, plan.RowCount())
+		}
+	}
+
+	result, err := executor.Execute(ctx, db, plan, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute import: %w", err)
+	}
+
+	statements := make([]string, len(plan.Batches))
+	for i, batch := range plan.Batches {
+		statements[i] = batch.SQL
+	}
+
+	execution := &models.ImportExecution{
+		ConnectionProfileID: profile.ID,
+		ConnectionName:      profile.Name,
+		Mode:                string(result.Mode),
+		BatchSize:           batchSize,
+		AllowPartial:        opts.AllowPartial,
+		RowsAffected:        result.RowsAffected,
+		Batches:             make([]models.ImportExecutionBatch, len(result.Batches)),
+		ErrorsTruncated:     result.ErrorsTruncated,
+		StartedAt:           result.StartedAt,
+		DurationMs:          result.DurationMs,
+	}
+	for i, b := range result.Batches {
+		execution.Batches[i] = models.ImportExecutionBatch(b)
+	}
+	for _, e := range result.Errors {
+		execution.Errors = append(execution.Errors, models.ImportExecutionError(e))
+	}
+
+	// Keep what is needed to re-run the import
+	metadata := req.Metadata
+	metadata.DatabaseType = profile.Dialect
+	metadata.Execution = execution
+	metadata.Fields = req.Fields
+	metadata.Options = &models.ImportOptions{KeyColumns: keyColumns, Upsert: req.Upsert}
+	if len(metadata.MappingSummary) == 0 {
+		metadata.MappingSummary = req.Mapping
+	}
+
+	status := models.ImportStatusExecuted
+	if result.Status == executor.StatusRolledBack {
+		status = models.ImportStatusRolledBack
+	}
+
+	imp := &models.Import{
+		UserID:         userID,
+		OrganizationID: ws.OrganizationID,
+		TableName:      req.TableName,
+		RowCount:       len(req.Rows),
+		Status:         status,
+		ErrorCount:     len(result.Errors),
+		Metadata:       metadata,
+	}
+
+	// Record the outcome even when ctx was canceled mid-execution
+	recordCtx := context.WithoutCancel(ctx)
+	if err := s.setGeneratedSQL(recordCtx, imp, strings.Join(statements, "\n\n")); err != nil {
+		return nil, err
+	}
+
+	var rb *generator.Rollback
+	rbErr := errNothingCommitted
+	if committed := result.Committed(); len(committed) > 0 {
+		rb, rbErr = plan.RollbackSQL(committed, result.PreImages)
+	}
+	if err := s.setRollback(recordCtx, imp, rb, rbErr); err != nil {
+		s.discardObjects(recordCtx, imp)
+		return nil, err
+	}
+
+	if err := s.createImport(recordCtx, imp); err != nil {
+		return nil, fmt.Errorf("failed to record import: %w", err)
+	}
+
+	return imp.ToResponse(), nil
+}
+
+// RerunImport runs a past import again with a new data file. The file is
+// read with the recorded mapping and transformations, validated against
+// the recorded table definition and generated again like a background job,
+// or executed against the same connection when req.Execute is set. The new
+// import links back to the original and reports the headers that changed;
+// dry runs only return that report.
+func (s *ImportService) RerunImport(ctx context.Context, id uuid.UUID, userID uuid.UUID, req *models.RerunImportRequest) (*models.RerunImportResponse, error) {
+	uploadID, err := uuid.Parse(req.UploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := s.importRepo.GetByID(ctx, id, ws)
+	if err != nil {
+		return nil, err
+	}
+	previous := original.Metadata
+	if len(previous.Fields) == 0 || len(previous.MappingSummary) == 0 {
+		return nil, ErrImportNotRerunnable
+	}
+	transforms, err := transform.ParseApplied(previous.Transformations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportNotRerunnable, err)
+	}
+	if req.Execute && previous.Execution == nil {
+		return nil, fmt.Errorf("%w: only executed imports can be executed again", ErrInvalidExecution)
+	}
+
+	file, upload, err := s.uploads.OpenUpload(ctx, uploadID, userID, models.UploadPurposeData)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if !ingest.Supported(upload.FileName) {
+		return nil, ingest.ErrUnsupportedFormat
+	}
+	reader, err := ingest.NewCSVReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+	}
+	headers := reader.Headers()
+
+	mapping, report, err := matchHeaders(previous, headers, req.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		return &models.RerunImportResponse{HeaderMatch: report}, nil
+	}
+
+	// The mapped fields in table order, with the column each is read from
+	columnOf := make(map[string]int, len(headers))
+	for i := len(headers) - 1; i >= 0; i-- {
+		columnOf[headers[i]] = i
+	}
+	var fields []models.ImportField
+	var columns []int
+	for _, f := range previous.Fields {
+		for header, field := range mapping {
+			if field == f.Name {
+				fields = append(fields, f)
+				columns = append(columns, columnOf[header])
+				break
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, ErrNothingToExecute
+	}
+
+	var rows [][]interface{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+		}
+
+		row := make([]interface{}, len(fields))
+		for i, column := range columns {
+			row[i] = record[column]
+			if name, ok := transforms[fields[i].Name]; ok {
+				// Names were checked by ParseApplied
+				row[i], _ = transform.Apply(name, row[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the data file has no rows", ErrInvalidUploadContent)
+	}
+
+	metadata := models.ImportMetadata{
+		SourceFileName:   upload.FileName,
+		SourceHeaders:    headers,
+		MappingSummary:   mapping,
+		Transformations:  previous.Transformations,
+		DatabaseType:     previous.DatabaseType,
+		Fields:           previous.Fields,
+		Options:          previous.Options,
+		PreviousImportID: &original.ID,
+		HeaderMatch:      report,
+	}
+	options := previous.Options
+	if options == nil {
+		options = &models.ImportOptions{}
+	}
+
+	var imp *models.ImportResponse
+	if req.Execute {
+		execution := previous.Execution
+		imp, err = s.ExecuteImport(ctx, userID, &models.ExecuteImportRequest{
+			ProfileID:    execution.ConnectionProfileID.String(),
+			TableName:    original.TableName,
+			Mapping:      mapping,
+			Rows:         rows,
+			Fields:       fields,
+			Mode:         execution.Mode,
+			BatchSize:    execution.BatchSize,
+			AllowPartial: execution.AllowPartial,
+			Upsert:       options.Upsert,
+			Metadata:     metadata,
+		})
+	} else {
+		imp, err = s.GenerateFromRows(ctx, userID, original.TableName, mapping, rows, fields, options, metadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RerunImportResponse{HeaderMatch: report, Import: imp}, nil
+}
+
+// GenerateFromRows validates rows holding the values of fields in order,
+// generates SQL for the valid ones and records it with the rejected rows,
+// as a generate job does. Re-runs and workflow sessions use it.
+func (s *ImportService) GenerateFromRows(ctx context.Context, userID uuid.UUID, tableName string, mapping map[string]string, rows [][]interface{}, importFields []models.ImportField, options *models.ImportOptions, metadata models.ImportMetadata) (*models.ImportResponse, error) {
+	// Unknown dialects are treated like MySQL, as when generating
+	dialect, _ := parser.ParseDialect(metadata.DatabaseType)
+	metadata.DatabaseType = string(dialect)
+
+	fields := make([]generator.FieldInfo, len(importFields))
+	for i, f := range importFields {
+		fields[i] = generator.FieldInfo(f)
+	}
+
+	valid := make([][]interface{}, 0, len(rows))
+	for i, row := range rows {
+		if errs := generator.ValidateRow(i+1, row, fields); len(errs) > 0 {
+			metadata.RejectedRows = append(metadata.RejectedRows, models.RejectedRow{Row: i + 1, Errors: errs})
+			if len(metadata.ValidationErrors) < maxReportedErrors {
+				metadata.ValidationErrors = append(metadata.ValidationErrors, errs...)
+			}
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	var plan *generator.InsertPlan
+	if options.Upsert {
+		plan = generator.PlanUpsert(dialect, tableName, mapping, valid, fields, jobGenerateBatch)
+	} else {
+		plan = generator.PlanInsert(dialect, tableName, mapping, valid, fields, jobGenerateBatch)
+	}
+	var statements []string
+	if plan != nil {
+		for _, batch := range plan.Batches {
+			statements = append(statements, batch.SQL)
+		}
+	}
+
+	status := models.ImportStatusSuccess
+	if len(metadata.RejectedRows) > 0 {
+		status = models.ImportStatusWarning
+	}
+	if len(valid) == 0 {
+		status = models.ImportStatusFailed
+	}
+
+	return s.CreateImport(ctx, userID, &models.CreateImportRequest{
+		TableName:    tableName,
+		RowCount:     len(valid),
+		Status:       status,
+		GeneratedSQL: strings.Join(statements, "\n\n"),
+		ErrorCount:   len(metadata.RejectedRows),
+		Metadata:     metadata,
+		KeyColumns:   options.KeyColumns,
+	})
+}
+
+// matchHeaders compares the headers of a re-run's data file with those of
+// the previous import and carries its mapping over: unchanged headers keep
+// their field, headers that only differ in case, spaces or punctuation are
+// taken as renamed, and overrides (header -> field, "" to unmap) apply last.
+func matchHeaders(previous models.ImportMetadata, headers []string, overrides map[string]string) (map[string]string, *models.HeaderMatchReport, error) {
+	report := &models.HeaderMatchReport{Matched: []string{}, Missing: []string{}, Added: []string{}}
+
+	previousHeaders := previous.SourceHeaders
+	if len(previousHeaders) == 0 {
+		// Imports saved without their headers only know the mapped ones
+		for header := range previous.MappingSummary {
+			previousHeaders = append(previousHeaders, header)
+		}
+		sort.Strings(previousHeaders)
+	}
+
+	present := make(map[string]bool, len(headers))
+	byNormalized := make(map[string][]string)
+	for _, h := range headers {
+		present[h] = true
+		n := normalizeHeader(h)
+		byNormalized[n] = append(byNormalized[n], h)
+	}
+
+	// Exact matches first, so a renamed header never takes one of them
+	used := make(map[string]bool)
+	for _, h := range previousHeaders {
+		if present[h] {
+			report.Matched = append(report.Matched, h)
+			used[h] = true
+		}
+	}
+	renamed := make(map[string]string)
+	for _, h := range previousHeaders {
+		if present[h] {
+			continue
+		}
+		for _, candidate := range byNormalized[normalizeHeader(h)] {
+			if !used[candidate] {
+				used[candidate] = true
+				renamed[h] = candidate
+				break
+			}
+		}
+		if _, ok := renamed[h]; !ok {
+			report.Missing = append(report.Missing, h)
+		}
+	}
+	for _, h := range headers {
+		if !used[h] {
+			report.Added = append(report.Added, h)
+		}
+	}
+	if len(renamed) > 0 {
+		report.Renamed = renamed
+	}
+
+	mapping := make(map[string]string)
+	for header, field := range previous.MappingSummary {
+		if field == "" {
+			continue
+		}
+		if present[header] {
+			mapping[header] = field
+		} else if to, ok := renamed[header]; ok {
+			mapping[to] = field
+		}
+	}
+
+	known := make(map[string]bool, len(previous.Fields))
+	for _, f := range previous.Fields {
+		known[f.Name] = true
+	}
+	for header, field := range overrides {
+		if !present[header] {
+			return nil, nil, fmt.Errorf("%w: header %q is not in the data file", ErrInvalidMapping, header)
+		}
+		if field != "" && !known[field] {
+			return nil, nil, fmt.Errorf("%w: field %q is not in the table definition", ErrInvalidMapping, field)
+		}
+	}
+	for header, field := range overrides {
+		for h, f := range mapping {
+			if field != "" && f == field {
+				delete(mapping, h)
+			}
+		}
+		if field == "" {
+			delete(mapping, header)
+		} else {
+			mapping[header] = field
+		}
+	}
+
+	mapped := make(map[string]bool, len(mapping))
+	for _, field := range mapping {
+		mapped[field] = true
+	}
+	for _, f := range previous.Fields {
+		wasMapped := false
+		for _, field := range previous.MappingSummary {
+			wasMapped = wasMapped || field == f.Name
+		}
+		if wasMapped && !mapped[f.Name] {
+			report.UnmappedFields = append(report.UnmappedFields, f.Name)
+		}
+	}
+
+	return mapping, report, nil
+}
+
+// normalizeHeader lowercases a header and drops everything but letters and
+// digits
+func normalizeHeader(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
 // GetImport retrieves an import by ID (without SQL)
 func (s *ImportService) GetImport(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.ImportResponse, error) {
-	imp, err := s.importRepo.GetByID(ctx, id, userID)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	imp, err := s.importRepo.GetByID(ctx, id, ws)
 	if err != nil {
 		return nil, err
 	}
@@ -116,17 +825,27 @@ func (s *ImportService) GetImport(ctx context.Context, id uuid.UUID, userID uuid
 	return imp.ToResponse(), nil
 }
 
-// GetImportWithSQL retrieves an import by ID including decompressed SQL
+// GetImportWithSQL retrieves an import by ID including its SQL
 func (s *ImportService) GetImportWithSQL(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.ImportWithSQL, error) {
-	imp, err := s.importRepo.GetByIDWithSQL(ctx, id, userID)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
 	if err != nil {
 		return nil, err
 	}
 
-	// Decompress SQL
-	var decompressedSQL string
-	if imp.GeneratedSQL != nil && *imp.GeneratedSQL != "" {
-		decompressedSQL, err = decompressSQL(*imp.GeneratedSQL)
+	imp, err := s.importRepo.GetByIDWithSQL(ctx, id, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var generatedSQL string
+	switch {
+	case imp.SQLStorageKey != nil:
+		generatedSQL, err = readBlob(ctx, s.blobs, *imp.SQLStorageKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQL: %w", err)
+		}
+	case imp.GeneratedSQL != nil && *imp.GeneratedSQL != "":
+		generatedSQL, err = decompressSQL(*imp.GeneratedSQL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decompress SQL: %w", err)
 		}
@@ -134,15 +853,119 @@ func (s *ImportService) GetImportWithSQL(ctx context.Context, id uuid.UUID, user
 
 	result := &models.ImportWithSQL{
 		ImportResponse: *imp.ToResponse(),
-		GeneratedSQL:   decompressedSQL,
+		GeneratedSQL:   generatedSQL,
 	}
 
+	s.recordSQLAccess(ctx, ws, imp, "view")
+
 	return result, nil
 }
 
-// ListImports retrieves imports with pagination and filters
+// OpenImportSQL opens the SQL of an import for a streaming download. SQL
+// in object storage is read in ranges as needed; legacy SQL is
+// decompressed in memory.
+func (s *ImportService) OpenImportSQL(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*ImportSQLFile, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	imp, err := s.importRepo.GetByIDWithSQL(ctx, id, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ImportSQLFile{
+		Name:    fmt.Sprintf("%s_%s.sql", imp.TableName, imp.CreatedAt.Format("20060102_150405")),
+		ModTime: imp.CreatedAt,
+	}
+	checksum := ""
+	if imp.SQLChecksum != nil {
+		checksum = *imp.SQLChecksum
+	}
+
+	s.recordSQLAccess(ctx, ws, imp, "download")
+	return s.openSQLFile(ctx, file, imp.SQLStorageKey, imp.GeneratedSQL, checksum, imp.SQLSize)
+}
+
+// OpenImportRollback opens the rollback script of an import for download,
+// like OpenImportSQL
+func (s *ImportService) OpenImportRollback(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*ImportSQLFile, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	imp, err := s.importRepo.GetByIDWithSQL(ctx, id, ws)
+	if err != nil {
+		return nil, err
+	}
+	info := imp.Metadata.Rollback
+	if info == nil || !info.Available || (imp.RollbackKey == nil && imp.RollbackSQL == nil) {
+		return nil, ErrRollbackUnavailable
+	}
+
+	file := &ImportSQLFile{
+		Name:    fmt.Sprintf("%s_%s_rollback.sql", imp.TableName, imp.CreatedAt.Format("20060102_150405")),
+		ModTime: imp.CreatedAt,
+	}
+	var size *int64
+	if info.Size > 0 {
+		size = &info.Size
+	}
+
+	s.recordSQLAccess(ctx, ws, imp, "rollback_download")
+	return s.openSQLFile(ctx, file, imp.RollbackKey, imp.RollbackSQL, info.Checksum, size)
+}
+
+// openSQLFile opens SQL kept under key in object storage, or compressed in
+// the database
+func (s *ImportService) openSQLFile(ctx context.Context, file *ImportSQLFile, key *string, compressed *string, checksum string, knownSize *int64) (*ImportSQLFile, error) {
+	if key != nil {
+		if s.blobs == nil {
+			return nil, fmt.Errorf("object storage is not configured")
+		}
+		var size int64
+		var err error
+		if knownSize != nil {
+			size = *knownSize
+		} else if size, err = s.blobs.Size(ctx, *key); err != nil {
+			return nil, fmt.Errorf("failed to stat SQL: %w", err)
+		}
+
+		content := storage.NewReadSeeker(ctx, s.blobs, *key, size)
+		file.ReadSeeker = content
+		file.closer = content
+		file.Size = size
+		file.Checksum = checksum
+		return file, nil
+	}
+
+	var content string
+	if compressed != nil && *compressed != "" {
+		var err error
+		content, err = decompressSQL(*compressed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress SQL: %w", err)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	file.ReadSeeker = strings.NewReader(content)
+	file.Size = int64(len(content))
+	file.Checksum = hex.EncodeToString(sum[:])
+	return file, nil
+}
+
+// ListImports retrieves the imports of the workspace with pagination and
+// filters
 func (s *ImportService) ListImports(ctx context.Context, userID uuid.UUID, req *models.GetImportsRequest) (*models.GetImportsResponse, error) {
-	imports, total, err := s.importRepo.List(ctx, userID, req)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	imports, total, err := s.importRepo.List(ctx, ws, req)
 	if err != nil {
 		return nil, err
 	}
@@ -177,21 +1000,147 @@ func (s *ImportService) ListImports(ctx context.Context, userID uuid.UUID, req *
 	}, nil
 }
 
-// DeleteImport deletes an import by ID
+// DeleteImport deletes an import by ID along with its stored SQL and
+// rollback script
 func (s *ImportService) DeleteImport(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
-	return s.importRepo.Delete(ctx, id, userID)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	storageKeys, err := s.importRepo.Delete(ctx, id, ws)
+	if err != nil {
+		return err
+	}
+
+	deleteBlobs(ctx, s.blobs, storageKeys...)
+	s.audit.RecordInWorkspace(ctx, ws, models.AuditImportDelete, models.AuditTargetImport, id.String(), nil)
+	return nil
 }
 
-// GetStats retrieves statistics about user's imports
+// GetStats retrieves statistics about the imports of the workspace
 func (s *ImportService) GetStats(ctx context.Context, userID uuid.UUID) (*models.ImportStats, error) {
-	return s.importRepo.GetStats(ctx, userID)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.importRepo.GetStats(ctx, ws)
 }
 
-// DeleteOldImports deletes imports older than the specified number of days
+// DeleteOldImports deletes imports older than the specified number of days.
+// In an organization this takes an admin.
 func (s *ImportService) DeleteOldImports(ctx context.Context, userID uuid.UUID, olderThanDays int) (int64, error) {
 	if olderThanDays <= 0 {
 		return 0, fmt.Errorf("olderThanDays must be positive")
 	}
 
-	return s.importRepo.DeleteOldImports(ctx, userID, olderThanDays)
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleAdmin)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, storageKeys, err := s.importRepo.DeleteOldImports(ctx, ws, olderThanDays)
+	if err != nil {
+		return 0, err
+	}
+
+	deleteBlobs(ctx, s.blobs, storageKeys...)
+	s.audit.RecordInWorkspace(ctx, ws, models.AuditImportDeleteOld, "", "", models.AuditMetadata{
+		"olderThanDays": olderThanDays,
+		"deleted":       deleted,
+	})
+	return deleted, nil
+}
+
+// MigrateLegacySQL moves up to limit imports whose SQL or rollback script
+// is still compressed in the database to object storage and returns how
+// many were moved
+func (s *ImportService) MigrateLegacySQL(ctx context.Context, limit int) (int, error) {
+	if s.blobs == nil {
+		return 0, nil
+	}
+
+	moved, err := s.migrateLegacyGeneratedSQL(ctx, limit)
+	if err != nil {
+		return moved, err
+	}
+	rollbacks, err := s.migrateLegacyRollbackSQL(ctx, limit)
+	return moved + rollbacks, err
+}
+
+func (s *ImportService) migrateLegacyGeneratedSQL(ctx context.Context, limit int) (int, error) {
+	imports, err := s.importRepo.ListLegacySQL(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, imp := range imports {
+		generatedSQL, err := decompressSQL(*imp.GeneratedSQL)
+		if err != nil {
+			logger.Warn("Skipping import with unreadable SQL", map[string]interface{}{
+				"importId": imp.ID.String(),
+				"error":    err.Error(),
+			})
+			continue
+		}
+
+		key := fmt.Sprintf("imports/%s/%s.sql", imp.UserID, imp.ID)
+		checksum, size, err := storeBlob(ctx, s.blobs, key, generatedSQL)
+		if err != nil {
+			return moved, err
+		}
+
+		updated, err := s.importRepo.SetSQLObject(ctx, imp.ID, key, checksum, size)
+		if err != nil {
+			deleteBlobs(ctx, s.blobs, key)
+			return moved, err
+		}
+		if !updated {
+			// Deleted while being moved
+			deleteBlobs(ctx, s.blobs, key)
+			continue
+		}
+		moved++
+	}
+
+	return moved, nil
+}
+
+func (s *ImportService) migrateLegacyRollbackSQL(ctx context.Context, limit int) (int, error) {
+	imports, err := s.importRepo.ListLegacyRollbackSQL(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, imp := range imports {
+		script, err := decompressSQL(*imp.RollbackSQL)
+		if err != nil {
+			logger.Warn("Skipping import with unreadable rollback script", map[string]interface{}{
+				"importId": imp.ID.String(),
+				"error":    err.Error(),
+			})
+			continue
+		}
+
+		key := fmt.Sprintf("imports/%s/%s.rollback.sql", imp.UserID, imp.ID)
+		if _, _, err := storeBlob(ctx, s.blobs, key, script); err != nil {
+			return moved, err
+		}
+
+		updated, err := s.importRepo.SetRollbackObject(ctx, imp.ID, key)
+		if err != nil {
+			deleteBlobs(ctx, s.blobs, key)
+			return moved, err
+		}
+		if !updated {
+			deleteBlobs(ctx, s.blobs, key)
+			continue
+		}
+		moved++
+	}
+
+	return moved, nil
 }
@@ -0,0 +1,573 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"db-importer/generator"
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+	"db-importer/parser"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidJob          = errors.New("invalid job")
+	ErrJobFinished         = errors.New("job has already finished")
+	ErrJobKindDisabled     = errors.New("job kind is disabled on this server")
+	errJobCanceled         = errors.New("job canceled")
+	errJobInterrupted      = errors.New("job interrupted by shutdown")
+	errJobAttemptsExceeded = errors.New("job was interrupted too many times")
+	errJobLost             = errors.New("job was reclaimed by another worker")
+)
+
+const (
+	// jobStaleAfter is how long a running job may go without a heartbeat
+	// before another worker reclaims it
+	jobStaleAfter = 2 * time.Minute
+	// jobHeartbeat is how often a worker refreshes the heartbeat and checks
+	// for cancellation
+	jobHeartbeat = 5 * time.Second
+	// jobMaxAttempts bounds how often a generate or export job is retried
+	// after its worker disappeared; execute jobs are never retried
+	jobMaxAttempts = 3
+	// jobProgressEvery is the number of rows between progress updates
+	jobProgressEvery  = 1000
+	jobGenerateBatch  = 1000
+	maxReportedErrors = 100
+	jobListLimit      = 50
+)
+
+// JobServiceConfig tunes the job workers
+type JobServiceConfig struct {
+	Workers          int           // number of workers on this instance (0 = submit only)
+	PollInterval     time.Duration // how often idle workers look for jobs
+	ExecutionEnabled bool          // accept execute jobs (SQL_EXECUTION_ENABLED)
+}
+
+// JobService queues import jobs and processes them with background workers.
+// Jobs live in Postgres and are claimed with SKIP LOCKED, so several server
+// instances can share the queue.
+type JobService struct {
+	jobRepo       *repository.JobRepository
+	orgs          *OrganizationService
+	importService *ImportService
+	exporter      *AccountExportService
+	config        JobServiceConfig
+	workerID      string
+
+	wake     chan struct{}
+	stopping chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
+
+	// abort cancels running jobs when draining takes too long
+	abortCtx context.Context
+	abort    context.CancelCauseFunc
+}
+
+// NewJobService creates a new JobService; call Start to run workers
+func NewJobService(jobRepo *repository.JobRepository, orgs *OrganizationService, importService *ImportService, exporter *AccountExportService, config JobServiceConfig) *JobService {
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+
+	hostname, _ := os.Hostname()
+	abortCtx, abort := context.WithCancelCause(context.Background())
+
+	return &JobService{
+		jobRepo:       jobRepo,
+		orgs:          orgs,
+		importService: importService,
+		exporter:      exporter,
+		config:        config,
+		workerID:      fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8]),
+		wake:          make(chan struct{}, 1),
+		stopping:      make(chan struct{}),
+		abortCtx:      abortCtx,
+		abort:         abort,
+	}
+}
+
+// SubmitJob validates and queues a job. The job is listed among the user's
+// jobs and its import is recorded in the workspace the user submitted it in.
+func (s *JobService) SubmitJob(ctx context.Context, userID uuid.UUID, req *models.SubmitJobRequest) (*models.JobResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows int
+	switch req.Kind {
+	case models.JobKindGenerateSQL:
+		var payload models.GenerateSQLJobPayload
+		if err := decodeJobPayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
+		if _, err := parser.ParseDialect(payload.Dialect); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		rows = len(payload.Rows)
+	case models.JobKindExecute:
+		if !s.config.ExecutionEnabled {
+			return nil, ErrJobKindDisabled
+		}
+		var payload models.ExecuteImportRequest
+		if err := decodeJobPayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
+		rows = len(payload.Rows)
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidJob, req.Kind)
+	}
+
+	job := &models.Job{
+		UserID:         userID,
+		OrganizationID: ws.OrganizationID,
+		Kind:           req.Kind,
+		Payload:        req.Payload,
+		RowsTotal:      rows,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// Let an idle local worker pick it up without waiting for the next poll
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return job.ToResponse(), nil
+}
+
+// decodeJobPayload strictly decodes and validates a job payload
+func decodeJobPayload(raw json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", ErrInvalidJob, err)
+	}
+	if err := utils.ValidateStruct(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	return nil
+}
+
+// GetJob retrieves a job owned by the user
+func (s *JobService) GetJob(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.JobResponse, error) {
+	job, err := s.jobRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return job.ToResponse(), nil
+}
+
+// ListJobs lists the user's most recent jobs
+func (s *JobService) ListJobs(ctx context.Context, userID uuid.UUID) ([]*models.JobResponse, error) {
+	jobs, err := s.jobRepo.ListByUserID(ctx, userID, jobListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.JobResponse, len(jobs))
+	for i := range jobs {
+		responses[i] = jobs[i].ToResponse()
+	}
+	return responses, nil
+}
+
+// CancelJob cancels a queued job or asks the worker of a running one to stop
+func (s *JobService) CancelJob(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.JobResponse, error) {
+	job, err := s.jobRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.Finished() {
+		return nil, ErrJobFinished
+	}
+
+	job, err = s.jobRepo.RequestCancel(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return job.ToResponse(), nil
+}
+
+// Stopping is closed when the service starts shutting down
+func (s *JobService) Stopping() <-chan struct{} {
+	return s.stopping
+}
+
+// Start launches the configured number of workers
+func (s *JobService) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.Add(1)
+		go s.work()
+	}
+
+	if s.config.Workers > 0 {
+		logger.Info("Job workers started", map[string]interface{}{
+			"workers":  s.config.Workers,
+			"workerId": s.workerID,
+		})
+	}
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. When
+// ctx expires first, running jobs are interrupted and put back in the queue.
+func (s *JobService) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Job workers drained", nil)
+		return nil
+	case <-ctx.Done():
+		s.abort(errJobInterrupted)
+		<-done
+		logger.Warn("Job workers interrupted, running jobs were requeued", nil)
+		return ctx.Err()
+	}
+}
+
+// work claims and runs jobs until shutdown
+func (s *JobService) work() {
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+
+		job, err := s.jobRepo.Claim(context.Background(), s.workerID, jobStaleAfter)
+		if err != nil {
+			logger.Error("Failed to claim job", err)
+		}
+		if job != nil {
+			s.runJob(job)
+			continue
+		}
+
+		select {
+		case <-s.stopping:
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJob processes a claimed job and records its outcome
+func (s *JobService) runJob(job *models.Job) {
+	ctx, cancel := context.WithCancelCause(s.abortCtx)
+	defer cancel(nil)
+	if job.OrganizationID != nil {
+		// Record the import in the organization the job was submitted in;
+		// its two-factor requirement was checked on submission
+		ctx = WithOrganization(ctx, *job.OrganizationID)
+		ctx = context.WithValue(ctx, "mfa", true)
+	}
+
+	logger.Info("Running job", map[string]interface{}{
+		"jobId":    job.ID.String(),
+		"kind":     job.Kind,
+		"attempts": job.Attempts,
+	})
+
+	progress := newJobProgress(s.jobRepo, job.ID, s.workerID, cancel)
+	stopHeartbeat := progress.heartbeat(ctx)
+
+	var importID *uuid.UUID
+	var err error
+	switch {
+	case job.CancelRequested:
+		err = errJobCanceled
+	case job.Attempts > 1 && job.Kind == models.JobKindExecute:
+		// Part of the rows may already be committed; never run twice
+		err = errJobAttemptsExceeded
+	case job.Attempts > jobMaxAttempts:
+		err = errJobAttemptsExceeded
+	case job.Kind == models.JobKindGenerateSQL:
+		importID, err = s.runGenerateJob(ctx, job, progress)
+	case job.Kind == models.JobKindExecute:
+		importID, err = s.runExecuteJob(ctx, job, progress)
+	case job.Kind == models.JobKindAccountExport:
+		err = s.exporter.runExport(ctx, job, progress)
+	default:
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	stopHeartbeat()
+
+	// Record the outcome even if the job context was canceled
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer finishCancel()
+
+	if cause := context.Cause(ctx); err != nil && cause != nil {
+		err = cause
+	}
+
+	switch {
+	case errors.Is(err, errJobLost):
+		// The job belongs to another worker now, which records its outcome
+	case err == nil:
+		err = s.jobRepo.Finish(finishCtx, job.ID, s.workerID, models.JobStatusSucceeded, importID, nil)
+	case errors.Is(err, errJobCanceled):
+		err = s.jobRepo.Finish(finishCtx, job.ID, s.workerID, models.JobStatusCanceled, importID, nil)
+	case errors.Is(err, errJobInterrupted) && job.Kind != models.JobKindExecute:
+		logger.Warn("Job interrupted by shutdown, requeueing", map[string]interface{}{
+			"jobId": job.ID.String(),
+		})
+		err = s.jobRepo.Requeue(finishCtx, job.ID, s.workerID)
+	default:
+		msg := err.Error()
+		err = s.jobRepo.Finish(finishCtx, job.ID, s.workerID, models.JobStatusFailed, importID, &msg)
+	}
+	if err != nil && (errors.Is(err, errJobLost) || errors.Is(err, repository.ErrJobOwnershipLost)) {
+		logger.Warn("Job was reclaimed by another worker, dropping its outcome", map[string]interface{}{
+			"jobId": job.ID.String(),
+		})
+	} else if err != nil {
+		logger.Error("Failed to record job outcome", err)
+	}
+}
+
+// runGenerateJob validates the rows, generates SQL for the valid ones and
+// stores it with the rejected rows and a report on a new import record
+func (s *JobService) runGenerateJob(ctx context.Context, job *models.Job, progress *jobProgress) (*uuid.UUID, error) {
+	startedAt := time.Now()
+
+	var payload models.GenerateSQLJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	dialect, err := parser.ParseDialect(payload.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]generator.FieldInfo, len(payload.Fields))
+	var keyColumns []string
+	for i, f := range payload.Fields {
+		fields[i] = generator.FieldInfo(f)
+		if f.Key {
+			keyColumns = append(keyColumns, f.Name)
+		}
+	}
+
+	// Validate row by row, keeping the valid ones
+	valid := make([][]interface{}, 0, len(payload.Rows))
+	var rejected []models.RejectedRow
+	var validationErrors []string
+	for i, row := range payload.Rows {
+		if i%jobProgressEvery == 0 {
+			if err := progress.update(ctx, models.JobPhaseValidating, i); err != nil {
+				return nil, err
+			}
+		}
+
+		if errs := generator.ValidateRow(i+1, row, fields); len(errs) > 0 {
+			rejected = append(rejected, models.RejectedRow{Row: i + 1, Errors: errs})
+			if len(validationErrors) < maxReportedErrors {
+				validationErrors = append(validationErrors, errs...)
+			}
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	// Generate batches, reporting progress per batch
+	var sql strings.Builder
+	if plan := generator.PlanInsert(dialect, payload.TableName, payload.Mapping, valid, fields, jobGenerateBatch); plan != nil {
+		for i, batch := range plan.Batches {
+			if err := progress.update(ctx, models.JobPhaseGenerating, batch.FirstRow); err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				sql.WriteString("\n\n")
+			}
+			sql.WriteString(batch.SQL)
+		}
+	}
+
+	if err := progress.update(ctx, models.JobPhaseSaving, len(payload.Rows)); err != nil {
+		return nil, err
+	}
+
+	status := models.ImportStatusSuccess
+	if len(rejected) > 0 {
+		status = models.ImportStatusWarning
+	}
+	if len(valid) == 0 {
+		status = models.ImportStatusFailed
+	}
+
+	metadata := payload.Metadata
+	metadata.DatabaseType = string(dialect)
+	metadata.MappingSummary = payload.Mapping
+	metadata.Fields = payload.Fields
+	metadata.ValidationErrors = validationErrors
+	metadata.RejectedRows = rejected
+	metadata.Report = &models.ImportReport{
+		JobID:        job.ID,
+		RowsTotal:    len(payload.Rows),
+		RowsAccepted: len(valid),
+		RowsRejected: len(rejected),
+		StartedAt:    startedAt,
+		DurationMs:   time.Since(startedAt).Milliseconds(),
+	}
+
+	imp, err := s.importService.CreateImport(ctx, job.UserID, &models.CreateImportRequest{
+		TableName:    payload.TableName,
+		RowCount:     len(valid),
+		Status:       status,
+		GeneratedSQL: sql.String(),
+		ErrorCount:   len(rejected),
+		Metadata:     metadata,
+		KeyColumns:   keyColumns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &imp.ID, nil
+}
+
+// runExecuteJob runs an import against a saved connection
+func (s *JobService) runExecuteJob(ctx context.Context, job *models.Job, progress *jobProgress) (*uuid.UUID, error) {
+	startedAt := time.Now()
+
+	var req models.ExecuteImportRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	if err := progress.update(ctx, models.JobPhaseExecuting, 0); err != nil {
+		return nil, err
+	}
+
+	req.Metadata.Report = &models.ImportReport{
+		JobID:        job.ID,
+		RowsTotal:    len(req.Rows),
+		RowsAccepted: len(req.Rows),
+		StartedAt:    startedAt,
+	}
+
+	imp, err := s.importService.ExecuteImportWithProgress(ctx, job.UserID, &req, func(rowsDone, _ int) {
+		progress.set(models.JobPhaseExecuting, rowsDone)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A cancellation during execution rolls the import back but still
+	// records it, so keep the import on the canceled job
+	if context.Cause(ctx) != nil {
+		return &imp.ID, context.Cause(ctx)
+	}
+
+	return &imp.ID, nil
+}
+
+// jobProgress writes progress for a running job and cancels the job's
+// context when a cancellation is requested or another worker reclaimed it
+type jobProgress struct {
+	repo     *repository.JobRepository
+	jobID    uuid.UUID
+	workerID string
+	cancel   context.CancelCauseFunc
+
+	mu            sync.Mutex
+	phase         string
+	rowsProcessed int
+}
+
+func newJobProgress(repo *repository.JobRepository, jobID uuid.UUID, workerID string, cancel context.CancelCauseFunc) *jobProgress {
+	return &jobProgress{repo: repo, jobID: jobID, workerID: workerID, cancel: cancel}
+}
+
+// set records progress in memory; the heartbeat persists it
+func (p *jobProgress) set(phase string, rowsProcessed int) {
+	p.mu.Lock()
+	p.phase = phase
+	p.rowsProcessed = rowsProcessed
+	p.mu.Unlock()
+}
+
+// update records progress and persists it right away
+func (p *jobProgress) update(ctx context.Context, phase string, rowsProcessed int) error {
+	p.set(phase, rowsProcessed)
+	if err := p.flush(ctx); err != nil {
+		return err
+	}
+	return context.Cause(ctx)
+}
+
+func (p *jobProgress) flush(ctx context.Context) error {
+	p.mu.Lock()
+	phase, rows := p.phase, p.rowsProcessed
+	p.mu.Unlock()
+
+	cancelRequested, err := p.repo.UpdateProgress(ctx, p.jobID, p.workerID, phase, rows)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobOwnershipLost) {
+			p.cancel(errJobLost)
+			return errJobLost
+		}
+		return err
+	}
+	if cancelRequested {
+		p.cancel(errJobCanceled)
+	}
+	return nil
+}
+
+// heartbeat periodically persists progress until the returned func is called
+func (p *jobProgress) heartbeat(ctx context.Context) func() {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(jobHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := p.flush(ctx)
+				if errors.Is(err, errJobLost) {
+					return
+				}
+				if err != nil && ctx.Err() == nil {
+					logger.Error("Failed to update job progress", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		stop()
+		<-done
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/transform"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMappingTemplateExists = errors.New("mapping template name already exists")
+	ErrInvalidTemplate       = errors.New("invalid mapping template")
+)
+
+// MappingTemplateService manages named column mappings that can be reused
+// across workflow sessions and shared between users
+type MappingTemplateService struct {
+	templateRepo *repository.MappingTemplateRepository
+	orgs         *OrganizationService
+}
+
+// NewMappingTemplateService creates a new MappingTemplateService
+func NewMappingTemplateService(templateRepo *repository.MappingTemplateRepository, orgs *OrganizationService) *MappingTemplateService {
+	return &MappingTemplateService{
+		templateRepo: templateRepo,
+		orgs:         orgs,
+	}
+}
+
+// headerSignature identifies a data file layout by its headers, normalized
+// as for re-runs, deduplicated and sorted so column order does not matter
+func headerSignature(headers []string) string {
+	seen := make(map[string]bool, len(headers))
+	var normalized []string
+	for _, h := range headers {
+		n := normalizeHeader(h)
+		if n != "" && !seen[n] {
+			seen[n] = true
+			normalized = append(normalized, n)
+		}
+	}
+	sort.Strings(normalized)
+	sum := sha256.Sum256([]byte(strings.Join(normalized, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// tableSignature identifies a target table by its name and field names,
+// ignoring case and field order
+func tableSignature(table string, fields []string) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = strings.ToLower(f)
+	}
+	sort.Strings(names)
+	sum := sha256.Sum256([]byte(strings.ToLower(table) + "\n" + strings.Join(names, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTemplate checks a template request for consistency and builds the
+// template with its signatures
+func newTemplate(ws models.Workspace, req *models.MappingTemplateRequest) (*models.MappingTemplate, error) {
+	headers := make(map[string]bool, len(req.SourceHeaders))
+	for _, h := range req.SourceHeaders {
+		if h == "" {
+			return nil, fmt.Errorf("%w: empty source header", ErrInvalidTemplate)
+		}
+		headers[h] = true
+	}
+
+	fields := make(map[string]bool, len(req.TableFields))
+	fieldNames := make([]string, len(req.TableFields))
+	for i, f := range req.TableFields {
+		if fields[f.Name] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidTemplate, f.Name)
+		}
+		fields[f.Name] = true
+		fieldNames[i] = f.Name
+	}
+
+	mapping := make(models.ColumnMapping, len(req.ColumnMapping))
+	mapped := make(map[string]string, len(req.ColumnMapping))
+	for header, field := range req.ColumnMapping {
+		if field == "" {
+			continue
+		}
+		if !headers[header] {
+			return nil, fmt.Errorf("%w: mapped header %q is not a source header", ErrInvalidTemplate, header)
+		}
+		if !fields[field] {
+			return nil, fmt.Errorf("%w: header %q is mapped to unknown field %q", ErrInvalidTemplate, header, field)
+		}
+		if other, ok := mapped[field]; ok {
+			return nil, fmt.Errorf("%w: field %q is mapped from both %q and %q", ErrInvalidTemplate, field, other, header)
+		}
+		mapped[field] = header
+		mapping[header] = field
+	}
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("%w: no mapped columns", ErrInvalidTemplate)
+	}
+
+	transformations := make(models.FieldTransformations, len(req.FieldTransformations))
+	for field, name := range req.FieldTransformations {
+		if !fields[field] {
+			return nil, fmt.Errorf("%w: transformation for unknown field %q", ErrInvalidTemplate, field)
+		}
+		if !transform.Supported(name) {
+			return nil, fmt.Errorf("%w: unknown transformation %q for field %q", ErrInvalidTemplate, name, field)
+		}
+		if name != transform.None {
+			transformations[field] = name
+		}
+	}
+
+	for _, key := range req.Options.KeyColumns {
+		if !fields[key] {
+			return nil, fmt.Errorf("%w: key column %q is not a field", ErrInvalidTemplate, key)
+		}
+	}
+
+	template := &models.MappingTemplate{
+		UserID:               ws.UserID,
+		OrganizationID:       ws.OrganizationID,
+		Name:                 req.Name,
+		TableName:            req.TableName,
+		TableFields:          req.TableFields,
+		TableSignature:       tableSignature(req.TableName, fieldNames),
+		SourceHeaders:        req.SourceHeaders,
+		HeaderSignature:      headerSignature(req.SourceHeaders),
+		ColumnMapping:        mapping,
+		FieldTransformations: transformations,
+		Options:              req.Options,
+		Shared:               req.Shared,
+	}
+	if req.Description != "" {
+		template.Description = &req.Description
+	}
+	return template, nil
+}
+
+// templateWriteError maps duplicate names to ErrMappingTemplateExists
+func templateWriteError(err error) error {
+	if err.Error() == ErrMappingTemplateExists.Error() {
+		return ErrMappingTemplateExists
+	}
+	return err
+}
+
+// CreateTemplate saves a new mapping template in the workspace
+func (s *MappingTemplateService) CreateTemplate(ctx context.Context, userID uuid.UUID, req *models.MappingTemplateRequest) (*models.MappingTemplateResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := newTemplate(ws, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.Create(ctx, template); err != nil {
+		return nil, templateWriteError(err)
+	}
+
+	return template.ToResponse(ws), nil
+}
+
+// ListTemplates lists the workspace's templates and those shared by others
+func (s *MappingTemplateService) ListTemplates(ctx context.Context, userID uuid.UUID) ([]*models.MappingTemplateResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.templateRepo.List(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.MappingTemplateResponse, len(templates))
+	for i := range templates {
+		responses[i] = templates[i].ToResponse(ws)
+	}
+	return responses, nil
+}
+
+// getTemplate retrieves a template of the workspace or shared by others
+func (s *MappingTemplateService) getTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.MappingTemplate, models.Workspace, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, ws, err
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, id, ws)
+	if err != nil {
+		return nil, ws, err
+	}
+	return template, ws, nil
+}
+
+// GetTemplate retrieves a template of the workspace or shared by others
+func (s *MappingTemplateService) GetTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.MappingTemplateResponse, error) {
+	template, ws, err := s.getTemplate(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return template.ToResponse(ws), nil
+}
+
+// UpdateTemplate replaces a template of the workspace
+func (s *MappingTemplateService) UpdateTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID, req *models.MappingTemplateRequest) (*models.MappingTemplateResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := newTemplate(ws, req)
+	if err != nil {
+		return nil, err
+	}
+	template.ID = id
+
+	if err := s.templateRepo.Update(ctx, template, ws); err != nil {
+		return nil, templateWriteError(err)
+	}
+
+	return template.ToResponse(ws), nil
+}
+
+// DeleteTemplate deletes a template of the workspace
+func (s *MappingTemplateService) DeleteTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	return s.templateRepo.Delete(ctx, id, ws)
+}
+
+// ExportTemplate returns a template as a document that can be imported
+// again, by anyone; whether it is shared is not exported
+func (s *MappingTemplateService) ExportTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.MappingTemplateDocument, error) {
+	template, _, err := s.getTemplate(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &models.MappingTemplateDocument{
+		Format:  models.MappingTemplateFormat,
+		Version: models.MappingTemplateVersion,
+		MappingTemplateRequest: models.MappingTemplateRequest{
+			Name:                 template.Name,
+			TableName:            template.TableName,
+			TableFields:          template.TableFields,
+			SourceHeaders:        template.SourceHeaders,
+			ColumnMapping:        template.ColumnMapping,
+			FieldTransformations: template.FieldTransformations,
+			Options:              template.Options,
+		},
+	}
+	if template.Description != nil {
+		doc.Description = *template.Description
+	}
+	return doc, nil
+}
+
+// ImportTemplate saves an exported template document as a new template of
+// the workspace
+func (s *MappingTemplateService) ImportTemplate(ctx context.Context, userID uuid.UUID, doc *models.MappingTemplateDocument) (*models.MappingTemplateResponse, error) {
+	if doc.Format != models.MappingTemplateFormat {
+		return nil, fmt.Errorf("%w: format must be %q", ErrInvalidTemplate, models.MappingTemplateFormat)
+	}
+	if doc.Version > models.MappingTemplateVersion {
+		return nil, fmt.Errorf("%w: version %d is newer than this server supports (%d)", ErrInvalidTemplate, doc.Version, models.MappingTemplateVersion)
+	}
+	return s.CreateTemplate(ctx, userID, &doc.MappingTemplateRequest)
+}
+
+// SuggestTemplates lists the templates made for data files with the same
+// headers (in any order), those for the given table first. table may be nil.
+func (s *MappingTemplateService) SuggestTemplates(ctx context.Context, userID uuid.UUID, headers []string, table *models.TableDefinition) ([]models.MappingTemplateSuggestion, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.templateRepo.ListByHeaderSignature(ctx, ws, headerSignature(headers))
+	if err != nil {
+		return nil, err
+	}
+
+	var signature string
+	if table != nil {
+		names := make([]string, len(table.Fields))
+		for i, f := range table.Fields {
+			names[i] = f.Name
+		}
+		signature = tableSignature(table.Name, names)
+	}
+
+	suggestions := make([]models.MappingTemplateSuggestion, len(templates))
+	for i, t := range templates {
+		suggestions[i] = models.MappingTemplateSuggestion{
+			ID:         t.ID,
+			Name:       t.Name,
+			TableName:  t.TableName,
+			TableMatch: signature != "" && t.TableSignature == signature,
+			Owned:      ws.Owns(t.UserID, t.OrganizationID),
+			UpdatedAt:  t.UpdatedAt,
+		}
+	}
+	// Keep the most recent first within each group
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.TableMatch != b.TableMatch {
+			return a.TableMatch
+		}
+		return a.Owned && !b.Owned
+	})
+	return suggestions, nil
+}
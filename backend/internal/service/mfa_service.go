@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/totp"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMFAUnavailable            = errors.New("two-factor authentication is not available: credential encryption key is not configured")
+	ErrMFAAlreadyEnabled         = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled             = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupMissing           = errors.New("start the authenticator setup first")
+	ErrMFAInvalidCode            = errors.New("invalid or already used code")
+	ErrMFAChallengeInvalid       = errors.New("sign-in attempt is invalid or expired, sign in again")
+	ErrMFARequiredByOrganization = errors.New("an organization you belong to requires two-factor authentication")
+)
+
+const (
+	// mfaChallengeTTL is how long the second step of a sign-in stays open
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeAttempts is how many codes can be tried per sign-in
+	mfaChallengeAttempts = 5
+	// mfaChallengePurpose binds signed challenge tokens to this use
+	mfaChallengePurpose = "mfa_challenge"
+	// recoveryCodeCount is how many recovery codes a user gets
+	recoveryCodeCount = 10
+	// totpSkew accepts codes of one step before or after the current one,
+	// allowing for clock drift of the user's device
+	totpSkew = 1
+)
+
+// recoveryCodeEncoding spells recovery codes in lower case letters and
+// digits that are hard to confuse
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// MFAConfig configures two-factor authentication
+type MFAConfig struct {
+	Issuer      string // service name shown by authenticator apps
+	TokenSecret []byte // signs the challenge tokens of two-step sign-ins
+}
+
+// MFAService manages TOTP two-factor authentication: enrollment, recovery
+// codes and the second step of sign-ins. Secrets are encrypted at rest, so
+// it is unavailable without a credential encryption key.
+type MFAService struct {
+	mfaRepo  *repository.MFARepository
+	userRepo *repository.UserRepository
+	orgRepo  *repository.OrganizationRepository
+	cipher   *utils.SecretCipher // nil when no encryption key is configured
+	config   MFAConfig
+}
+
+// NewMFAService creates a new MFAService
+func NewMFAService(
+	mfaRepo *repository.MFARepository,
+	userRepo *repository.UserRepository,
+	orgRepo *repository.OrganizationRepository,
+	cipher *utils.SecretCipher,
+	config MFAConfig,
+) *MFAService {
+	return &MFAService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		orgRepo:  orgRepo,
+		cipher:   cipher,
+		config:   config,
+	}
+}
+
+// Status describes the user's two-factor authentication
+func (s *MFAService) Status(ctx context.Context, userID uuid.UUID) (*models.MFAStatusResponse, error) {
+	requiredBy, err := s.orgRepo.ListRequiringMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.MFAStatusResponse{RequiredBy: requiredBy}
+
+	auth, err := s.enabledTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnabled) {
+			return status, nil
+		}
+		return nil, err
+	}
+
+	remaining, err := s.mfaRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = true
+	status.EnabledAt = auth.EnabledAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+// BeginSetup creates a new authenticator secret for the user, replacing an
+// unconfirmed one. It takes effect once confirmed with Enable.
+func (s *MFAService) BeginSetup(ctx context.Context, userID uuid.UUID) (*models.TOTPSetupResponse, error) {
+	if s.cipher == nil {
+		return nil, ErrMFAUnavailable
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	saved, err := s.mfaRepo.SavePendingTOTP(ctx, userID, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return &models.TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.config.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms the pending authenticator with a code from it and returns
+// the user's recovery codes, which are shown only this once
+func (s *MFAService) Enable(ctx context.Context, userID uuid.UUID, req *models.MFACodeRequest) (*models.RecoveryCodesResponse, error) {
+	if s.cipher == nil {
+		return nil, ErrMFAUnavailable
+	}
+
+	auth, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err.Error() == "totp not found" {
+			return nil, ErrMFASetupMissing
+		}
+		return nil, err
+	}
+	if auth.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.cipher.Decrypt(auth.SecretEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	step, ok := totp.Validate(secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.mfaRepo.EnableTOTP(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		// Enabled or set up again concurrently
+		return nil, ErrMFASetupMissing
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable turns two-factor authentication off after checking a current
+// code. Members of organizations requiring it cannot turn it off.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, req *models.MFACodeRequest) error {
+	requiredBy, err := s.orgRepo.ListRequiringMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(requiredBy) > 0 {
+		return ErrMFARequiredByOrganization
+	}
+
+	if err := s.verifyCode(ctx, userID, req.Code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.DisableTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current code and returns the new ones
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req *models.MFACodeRequest) (*models.RecoveryCodesResponse, error) {
+	if err := s.verifyCode(ctx, userID, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Enabled reports whether sign-ins of the user require a second step
+func (s *MFAService) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if _, err := s.enabledTOTP(ctx, userID); err != nil {
+		if errors.Is(err, ErrMFANotEnabled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Challenge opens the second step of a sign-in of the user and returns its
+// token, which the client sends back with a code
+func (s *MFAService) Challenge(ctx context.Context, userID uuid.UUID, rememberMe bool) (string, error) {
+	challenge := &models.MFAChallenge{
+		UserID:     userID,
+		RememberMe: rememberMe,
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.mfaRepo.CreateChallenge(ctx, challenge); err != nil {
+		return "", err
+	}
+
+	return utils.SignPayload(s.config.TokenSecret, mfaChallengePurpose, challenge.ID[:]), nil
+}
+
+// OpenChallenge resolves the challenge of a token for another attempt at
+// its code; each challenge allows a few attempts
+func (s *MFAService) OpenChallenge(ctx context.Context, token string) (*models.MFAChallenge, error) {
+	payload, err := utils.VerifyPayload(s.config.TokenSecret, mfaChallengePurpose, token)
+	if err != nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	id, err := uuid.FromBytes(payload)
+	if err != nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	challenge, err := s.mfaRepo.AttemptChallenge(ctx, id, mfaChallengeAttempts)
+	if err != nil {
+		if err.Error() == "mfa challenge not found" {
+			return nil, ErrMFAChallengeInvalid
+		}
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// CompleteChallenge checks the code for an opened challenge and closes it
+func (s *MFAService) CompleteChallenge(ctx context.Context, challenge *models.MFAChallenge, code string) error {
+	if err := s.verifyCode(ctx, challenge.UserID, code); err != nil {
+		if errors.Is(err, ErrMFANotEnabled) {
+			// Turned off since the first step
+			return ErrMFAChallengeInvalid
+		}
+		return err
+	}
+
+	completed, err := s.mfaRepo.CompleteChallenge(ctx, challenge.ID)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return ErrMFAChallengeInvalid
+	}
+
+	return nil
+}
+
+// CleanupExpiredChallenges deletes expired sign-in challenges
+func (s *MFAService) CleanupExpiredChallenges(ctx context.Context) (int64, error) {
+	return s.mfaRepo.DeleteExpiredChallenges(ctx)
+}
+
+// verifyCode checks a code of the user's authenticator or one of their
+// recovery codes; either works only once
+func (s *MFAService) verifyCode(ctx context.Context, userID uuid.UUID, code string) error {
+	auth, err := s.enabledTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if digits := strings.ReplaceAll(strings.TrimSpace(code), " ", ""); len(digits) == totp.Digits {
+		if s.cipher == nil {
+			return ErrMFAUnavailable
+		}
+		secret, err := s.cipher.Decrypt(auth.SecretEncrypted)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret: %w", err)
+		}
+
+		step, ok := totp.Validate(secret, digits, time.Now(), totpSkew)
+		if !ok {
+			return ErrMFAInvalidCode
+		}
+		used, err := s.mfaRepo.UseStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrMFAInvalidCode
+		}
+		return nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, utils.HashRefreshToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+// enabledTOTP returns the user's enabled authenticator
+func (s *MFAService) enabledTOTP(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	auth, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err.Error() == "totp not found" {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if !auth.Enabled() {
+		return nil, ErrMFANotEnabled
+	}
+	return auth, nil
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted xxxxx-xxxxx,
+// and their hashes for storage
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := recoveryCodeEncoding.EncodeToString(raw)[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		hashes[i] = utils.HashRefreshToken(encoded)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops case, dashes and spaces users may type
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
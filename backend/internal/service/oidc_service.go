@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/logger"
+	"db-importer/oidc"
+)
+
+var (
+	ErrOIDCLoginInvalid    = errors.New("sign-in attempt is invalid or expired, start again")
+	ErrOIDCEmailRequired   = errors.New("identity provider did not share an email address")
+	ErrOIDCEmailUnverified = errors.New("an account uses this email address, but the identity provider did not confirm it")
+	ErrOIDCNoAccount       = errors.New("no account for this identity and automatic sign-up is disabled")
+)
+
+const (
+	// OIDCLoginTTL is how long a user has to sign in at the provider
+	OIDCLoginTTL = 10 * time.Minute
+	// oidcLoginPurpose binds signed login state to this use
+	oidcLoginPurpose = "oidc_login"
+)
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider
+type OIDCConfig struct {
+	ProviderName  string            // label of the sign-in button
+	StateSecret   []byte            // signs the login state kept in a cookie
+	AppURL        string            // frontend base URL users return to
+	Claims        oidc.ClaimMapping // where the profile is read from
+	AutoProvision bool              // create users on their first sign-in
+}
+
+// OIDCService signs users in through an OpenID Connect provider. Provider
+// accounts are linked to users by verified email address or, with
+// AutoProvision, get a new user; sessions are issued by AuthService.
+type OIDCService struct {
+	client       *oidc.Client
+	authService  *AuthService
+	userRepo     *repository.UserRepository
+	identityRepo *repository.UserIdentityRepository
+	config       OIDCConfig
+}
+
+// NewOIDCService creates a new OIDCService
+func NewOIDCService(
+	client *oidc.Client,
+	authService *AuthService,
+	userRepo *repository.UserRepository,
+	identityRepo *repository.UserIdentityRepository,
+	config OIDCConfig,
+) *OIDCService {
+	return &OIDCService{
+		client:       client,
+		authService:  authService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		config:       config,
+	}
+}
+
+// oidcLogin is the state of a sign-in between BeginLogin and CompleteLogin,
+// kept signed in a cookie of the browser that started it
+type oidcLogin struct {
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"` // PKCE code verifier
+	Redirect  string `json:"r"` // frontend path to return to
+	ExpiresAt int64  `json:"e"`
+}
+
+// ProviderName returns the label of the sign-in button
+func (s *OIDCService) ProviderName() string {
+	return s.config.ProviderName
+}
+
+// BeginLogin starts a sign-in that returns to the frontend path redirect.
+// It returns the provider URL to send the browser to and the login state
+// the browser must keep until the callback.
+func (s *OIDCService) BeginLogin(ctx context.Context, redirect string) (string, string, error) {
+	login := oidcLogin{
+		State:     oidc.RandomString(),
+		Nonce:     oidc.RandomString(),
+		Verifier:  oidc.RandomString(),
+		Redirect:  safeRedirect(redirect),
+		ExpiresAt: time.Now().Add(OIDCLoginTTL).Unix(),
+	}
+
+	authURL, err := s.client.AuthCodeURL(ctx, login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(login)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode login state: %w", err)
+	}
+	return authURL, utils.SignPayload(s.config.StateSecret, oidcLoginPurpose, payload), nil
+}
+
+// CompleteLogin finishes a sign-in with the state and code the provider
+// sent to the callback and the login state kept by the browser. It returns
+// the session, or an MFA token for users with two-factor authentication,
+// and the frontend path to return to.
+func (s *OIDCService) CompleteLogin(ctx context.Context, loginState, state, code string) (*models.LoginResponse, string, error) {
+	login, err := s.openLogin(loginState)
+	if err != nil || code == "" || subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return nil, "", ErrOIDCLoginInvalid
+	}
+
+	token, err := s.client.Authenticate(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		return nil, "", fmt.Errorf("identity provider sign-in failed: %w", err)
+	}
+	profile := s.config.Claims.Profile(token)
+
+	user, err := s.resolveUser(ctx, profile)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.IsActive {
+		return nil, "", ErrUserNotActive
+	}
+
+	if err := s.identityRepo.RecordLogin(ctx, profile.Issuer, profile.Subject, profile.Email); err != nil {
+		logger.Warn("Failed to record identity sign-in", map[string]interface{}{
+			"userId": user.ID.String(),
+			"error":  err.Error(),
+		})
+	}
+
+	session, err := s.authService.SignIn(ctx, user, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, login.Redirect, nil
+}
+
+// AppLink returns the frontend URL of path
+func (s *OIDCService) AppLink(path string) string {
+	return strings.TrimRight(s.config.AppURL, "/") + path
+}
+
+// resolveUser finds the user linked to the provider account, links it to
+// the user with its verified email address or creates a new user
+func (s *OIDCService) resolveUser(ctx context.Context, profile oidc.Profile) (*models.User, error) {
+	user, err := s.identityRepo.GetUser(ctx, profile.Issuer, profile.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err.Error() != "identity not found" {
+		return nil, err
+	}
+
+	if profile.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	identity := &models.UserIdentity{
+		Issuer:  profile.Issuer,
+		Subject: profile.Subject,
+		Email:   &profile.Email,
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, profile.Email)
+	switch {
+	case err == nil:
+		return s.linkUser(ctx, existing, identity, profile)
+	case err.Error() != "user not found":
+		return nil, err
+	case !s.config.AutoProvision:
+		return nil, ErrOIDCNoAccount
+	}
+
+	user = &models.User{
+		Email:         profile.Email,
+		FirstName:     namePart(profile.FirstName),
+		LastName:      namePart(profile.LastName),
+		EmailVerified: profile.EmailVerified,
+	}
+	if err := s.identityRepo.CreateWithUser(ctx, user, identity); err != nil {
+		if err.Error() == "identity already linked" {
+			// A concurrent callback of the same account got there first
+			return s.identityRepo.GetUser(ctx, profile.Issuer, profile.Subject)
+		}
+		return nil, err
+	}
+
+	logger.Info("User created on first single sign-on", map[string]interface{}{
+		"userId": user.ID.String(),
+		"issuer": profile.Issuer,
+	})
+	return user, nil
+}
+
+// linkUser links the provider account to an existing user with the same
+// address, which the provider must have verified. When the user had not
+// verified it, their password, sessions, API tokens and two-factor
+// authentication are removed, so someone who registered the address without
+// owning it loses access.
+func (s *OIDCService) linkUser(ctx context.Context, user *models.User, identity *models.UserIdentity, profile oidc.Profile) (*models.User, error) {
+	if !profile.EmailVerified {
+		return nil, ErrOIDCEmailUnverified
+	}
+
+	identity.UserID = user.ID
+	if err := s.identityRepo.Link(ctx, identity); err != nil {
+		if err.Error() == "identity already linked" {
+			return s.identityRepo.GetUser(ctx, profile.Issuer, profile.Subject)
+		}
+		return nil, err
+	}
+
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		user.PasswordHash = ""
+	}
+
+	logger.Info("Identity provider account linked by email", map[string]interface{}{
+		"userId": user.ID.String(),
+		"issuer": profile.Issuer,
+	})
+	return user, nil
+}
+
+// openLogin checks the signature and expiry of login state
+func (s *OIDCService) openLogin(loginState string) (*oidcLogin, error) {
+	payload, err := utils.VerifyPayload(s.config.StateSecret, oidcLoginPurpose, loginState)
+	if err != nil {
+		return nil, err
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal(payload, &login); err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+	if time.Now().Unix() >= login.ExpiresAt {
+		return nil, utils.ErrExpiredToken
+	}
+	return &login, nil
+}
+
+// safeRedirect keeps only frontend-relative paths, so the callback cannot
+// be used to send users to another site
+func safeRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// namePart converts a name from the provider for the user table, which
+// holds up to 100 characters
+func namePart(name string) *string {
+	if name == "" {
+		return nil
+	}
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	return &name
+}
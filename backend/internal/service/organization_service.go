@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+	"db-importer/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrOrganizationNotFound is returned for organizations that do not
+	// exist and for those the user does not belong to
+	ErrOrganizationNotFound    = errors.New("organization not found")
+	ErrInsufficientRole        = errors.New("your role in this organization does not allow this")
+	ErrMemberNotFound          = errors.New("organization member not found")
+	ErrLastOwner               = errors.New("an organization needs at least one owner")
+	ErrInvitationInvalid       = errors.New("invitation is invalid, expired or already accepted")
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+	ErrInvitationUnverified    = errors.New("verify your email address before accepting the invitation")
+	// ErrMFARequired is returned to members without two-factor
+	// authentication of organizations that require it
+	ErrMFARequired = errors.New("this organization requires two-factor authentication; enable it in your account settings")
+	// ErrMFASessionRequired is returned to members of such organizations
+	// whose session did not pass two-factor authentication
+	ErrMFASessionRequired = errors.New("this organization requires two-factor authentication; sign in again with a code")
+)
+
+// invitationTTL is how long an invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// organizationKey carries the organization selected with WithOrganization
+type organizationKey struct{}
+
+// WithOrganization makes services act in the organization's workspace
+// instead of the user's personal one. Membership and role are checked by
+// each service call.
+func WithOrganization(ctx context.Context, orgID uuid.UUID) context.Context {
+	return context.WithValue(ctx, organizationKey{}, orgID)
+}
+
+// OrganizationFromContext returns the organization selected with
+// WithOrganization
+func OrganizationFromContext(ctx context.Context) (uuid.UUID, bool) {
+	orgID, ok := ctx.Value(organizationKey{}).(uuid.UUID)
+	return orgID, ok
+}
+
+// OrganizationService manages organizations, their members and invitations,
+// and authorizes access to the resources they own
+type OrganizationService struct {
+	orgRepo  *repository.OrganizationRepository
+	userRepo *repository.UserRepository
+	blobs    storage.Backend // nil when nothing is kept in object storage
+	audit    *AuditService
+}
+
+// NewOrganizationService creates a new OrganizationService
+func NewOrganizationService(orgRepo *repository.OrganizationRepository, userRepo *repository.UserRepository, blobs storage.Backend, audit *AuditService) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		blobs:    blobs,
+		audit:    audit,
+	}
+}
+
+// Workspace resolves the workspace a request acts in: the organization
+// selected with WithOrganization, provided the user's role in it is at
+// least minRole, or else the user's personal workspace, where the user may
+// do anything.
+func (s *OrganizationService) Workspace(ctx context.Context, userID uuid.UUID, minRole models.OrganizationRole) (models.Workspace, error) {
+	orgID, ok := OrganizationFromContext(ctx)
+	if !ok {
+		return models.Workspace{UserID: userID}, nil
+	}
+
+	role, err := s.authorize(ctx, userID, orgID, minRole)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	return models.Workspace{UserID: userID, OrganizationID: &orgID, Role: role}, nil
+}
+
+// authorize checks that the user belongs to the organization with a role of
+// at least minRole and returns their role
+func (s *OrganizationService) authorize(ctx context.Context, userID, orgID uuid.UUID, minRole models.OrganizationRole) (models.OrganizationRole, error) {
+	access, err := s.memberAccess(ctx, userID, orgID, minRole)
+	if err != nil {
+		if access != nil {
+			return access.Role, err
+		}
+		return "", err
+	}
+
+	return access.Role, nil
+}
+
+// memberAccess checks like authorize and returns the member's access. An
+// organization requiring two-factor authentication refuses members who
+// have not enabled it or whose session did not pass it, whatever their
+// role.
+func (s *OrganizationService) memberAccess(ctx context.Context, userID, orgID uuid.UUID, minRole models.OrganizationRole) (*models.MemberAccess, error) {
+	access, err := s.orgRepo.GetMemberAccess(ctx, orgID, userID)
+	if err != nil {
+		if err.Error() == "organization member not found" {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+
+	if access.RequireMFA && !access.MFAEnabled {
+		return access, ErrMFARequired
+	}
+	if access.RequireMFA && !mfaVerified(ctx) {
+		return access, ErrMFASessionRequired
+	}
+	if !access.Role.Allows(minRole) {
+		return access, ErrInsufficientRole
+	}
+
+	return access, nil
+}
+
+// mfaVerified reports whether the request passed two-factor authentication.
+// Sessions started with a code say so in their token. API tokens count, as
+// enabling two-factor authentication revokes those created before it, and
+// jobs were checked when they were submitted.
+func mfaVerified(ctx context.Context) bool {
+	if verified, _ := ctx.Value("mfa").(bool); verified {
+		return true
+	}
+	_, isAPIToken := ctx.Value("apiTokenID").(string)
+	return isAPIToken
+}
+
+// RequestPlan returns the organization selected with WithOrganization and
+// its plan. Nothing is returned when none is selected or the user is not a
+// member of it.
+func (s *OrganizationService) RequestPlan(ctx context.Context, userID uuid.UUID) (uuid.UUID, string, error) {
+	orgID, ok := OrganizationFromContext(ctx)
+	if !ok {
+		return uuid.Nil, "", nil
+	}
+
+	plan, err := s.orgRepo.GetMemberPlan(ctx, orgID, userID)
+	if err != nil {
+		if err.Error() == "organization member not found" {
+			return uuid.Nil, "", nil
+		}
+		return uuid.Nil, "", err
+	}
+
+	return orgID, plan, nil
+}
+
+// CreateOrganization creates an organization owned by the user
+func (s *OrganizationService) CreateOrganization(ctx context.Context, userID uuid.UUID, req *models.CreateOrganizationRequest) (*models.OrganizationMembership, error) {
+	org := &models.Organization{Name: strings.TrimSpace(req.Name)}
+	if err := s.orgRepo.Create(ctx, org, userID); err != nil {
+		return nil, err
+	}
+
+	return &models.OrganizationMembership{ID: org.ID, Name: org.Name, Role: models.RoleOwner, Plan: org.Plan}, nil
+}
+
+// ListOrganizations lists the organizations the user belongs to and their
+// role in each
+func (s *OrganizationService) ListOrganizations(ctx context.Context, userID uuid.UUID) ([]models.OrganizationMembership, error) {
+	return s.orgRepo.ListByUserID(ctx, userID)
+}
+
+// RenameOrganization renames an organization; admins and owners only
+func (s *OrganizationService) RenameOrganization(ctx context.Context, userID, orgID uuid.UUID, req *models.CreateOrganizationRequest) (*models.OrganizationMembership, error) {
+	role, err := s.authorize(ctx, userID, orgID, models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if err := s.orgRepo.Rename(ctx, orgID, name); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OrganizationMembership{ID: orgID, Name: name, Role: role, Plan: org.Plan, RequireMFA: org.RequireMFA}, nil
+}
+
+// SetMFARequirement sets whether the organization requires its members to
+// use two-factor authentication; admins and owners only. Admins must have
+// enabled it themselves before requiring it. Members without it lose access
+// to the organization until they enable it.
+func (s *OrganizationService) SetMFARequirement(ctx context.Context, userID, orgID uuid.UUID, req *models.UpdateMFARequirementRequest) (*models.OrganizationMembership, error) {
+	access, err := s.memberAccess(ctx, userID, orgID, models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if req.Required && !access.MFAEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.orgRepo.SetRequireMFA(ctx, orgID, req.Required); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OrganizationMembership{ID: org.ID, Name: org.Name, Role: access.Role, Plan: org.Plan, RequireMFA: org.RequireMFA}, nil
+}
+
+// DeleteOrganization deletes an organization and everything it owns,
+// including the stored files of its imports and workflow sessions; owners
+// only. The deletion is recorded in the owner's personal audit log, since
+// nobody can read the organization's log once it is gone.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, userID, orgID uuid.UUID) error {
+	if _, err := s.authorize(ctx, userID, orgID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	storageKeys, err := s.orgRepo.Delete(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	deleteBlobs(ctx, s.blobs, storageKeys...)
+	s.audit.RecordByUser(ctx, userID, models.AuditOrganizationDelete, models.AuditTargetOrganization, orgID.String(), models.AuditMetadata{
+		"name": org.Name,
+	})
+	return nil
+}
+
+// ListMembers lists the members of an organization; any member may
+func (s *OrganizationService) ListMembers(ctx context.Context, userID, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	if _, err := s.authorize(ctx, userID, orgID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.orgRepo.ListMembers(ctx, orgID)
+}
+
+// memberRole returns the role of another member of the organization
+func (s *OrganizationService) memberRole(ctx context.Context, orgID, memberID uuid.UUID) (models.OrganizationRole, error) {
+	role, err := s.orgRepo.GetMemberRole(ctx, orgID, memberID)
+	if err != nil {
+		if err.Error() == "organization member not found" {
+			return "", ErrMemberNotFound
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+// UpdateMemberRole changes the role of a member. Admins manage admins,
+// editors and viewers; only owners may grant ownership or change the role
+// of another owner.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, userID, orgID, memberID uuid.UUID, req *models.UpdateMemberRoleRequest) error {
+	role, err := s.authorize(ctx, userID, orgID, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.memberRole(ctx, orgID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if (current == models.RoleOwner || req.Role == models.RoleOwner) && role != models.RoleOwner {
+		return ErrInsufficientRole
+	}
+
+	updated, err := s.orgRepo.UpdateMemberRole(ctx, orgID, memberID, req.Role)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrLastOwner
+	}
+
+	s.recordMembership(ctx, userID, orgID, models.AuditMemberRoleChange, models.AuditTargetUser, memberID, models.AuditMetadata{
+		"from": current,
+		"to":   req.Role,
+	})
+	return nil
+}
+
+// RemoveMember removes a member from an organization. Members may leave on
+// their own; removing others takes the role needed to change theirs.
+func (s *OrganizationService) RemoveMember(ctx context.Context, userID, orgID, memberID uuid.UUID) error {
+	minRole := models.RoleAdmin
+	if memberID == userID {
+		minRole = models.RoleViewer
+	}
+
+	role, err := s.authorize(ctx, userID, orgID, minRole)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.memberRole(ctx, orgID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if current == models.RoleOwner && role != models.RoleOwner {
+		return ErrInsufficientRole
+	}
+
+	removed, err := s.orgRepo.RemoveMember(ctx, orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrLastOwner
+	}
+
+	s.recordMembership(ctx, userID, orgID, models.AuditMemberRemove, models.AuditTargetUser, memberID, models.AuditMetadata{
+		"role": current,
+	})
+	return nil
+}
+
+// recordMembership records a change to the members of an organization,
+// visible to its admins
+func (s *OrganizationService) recordMembership(ctx context.Context, userID, orgID uuid.UUID, action, targetType string, targetID uuid.UUID, metadata models.AuditMetadata) {
+	ws := models.Workspace{UserID: userID, OrganizationID: &orgID}
+	s.audit.RecordInWorkspace(ctx, ws, action, targetType, targetID.String(), metadata)
+}
+
+// CreateInvitation invites an email address to join an organization;
+// admins and owners only. The returned token is not stored and cannot be
+// shown again: the inviter passes it on.
+func (s *OrganizationService) CreateInvitation(ctx context.Context, userID, orgID uuid.UUID, req *models.CreateInvitationRequest) (*models.CreateInvitationResponse, error) {
+	if _, err := s.authorize(ctx, userID, orgID, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	value, err := utils.GenerateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &models.OrganizationInvitation{
+		OrganizationID: orgID,
+		Email:          strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:           req.Role,
+		TokenHash:      utils.HashRefreshToken(value),
+		InvitedBy:      &userID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	if err := s.orgRepo.CreateInvitation(ctx, inv); err != nil {
+		return nil, err
+	}
+
+	s.recordMembership(ctx, userID, orgID, models.AuditInvitationCreate, models.AuditTargetInvitation, inv.ID, models.AuditMetadata{
+		"email": inv.Email,
+		"role":  inv.Role,
+	})
+
+	return &models.CreateInvitationResponse{OrganizationInvitation: inv, Token: value}, nil
+}
+
+// ListInvitations lists the pending invitations of an organization; admins
+// and owners only
+func (s *OrganizationService) ListInvitations(ctx context.Context, userID, orgID uuid.UUID) ([]*models.OrganizationInvitation, error) {
+	if _, err := s.authorize(ctx, userID, orgID, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.orgRepo.ListPendingInvitations(ctx, orgID)
+}
+
+// RevokeInvitation deletes a pending invitation; admins and owners only
+func (s *OrganizationService) RevokeInvitation(ctx context.Context, userID, orgID, invitationID uuid.UUID) error {
+	if _, err := s.authorize(ctx, userID, orgID, models.RoleAdmin); err != nil {
+		return err
+	}
+
+	if err := s.orgRepo.DeleteInvitation(ctx, orgID, invitationID); err != nil {
+		return err
+	}
+
+	s.recordMembership(ctx, userID, orgID, models.AuditInvitationRevoke, models.AuditTargetInvitation, invitationID, nil)
+	return nil
+}
+
+// AcceptInvitation adds the user to the organization they were invited to.
+// The invitation must have been sent to the user's email address, which
+// they must have verified: anyone can register an unverified address.
+func (s *OrganizationService) AcceptInvitation(ctx context.Context, userID uuid.UUID, req *models.AcceptInvitationRequest) (*models.OrganizationMembership, error) {
+	inv, err := s.orgRepo.GetInvitationByTokenHash(ctx, utils.HashRefreshToken(req.Token))
+	if err != nil {
+		if err.Error() == "invitation not found" {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+	if !inv.IsPending() {
+		return nil, ErrInvitationInvalid
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, inv.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+	if !user.EmailVerified {
+		return nil, ErrInvitationUnverified
+	}
+
+	accepted, err := s.orgRepo.AcceptInvitation(ctx, inv, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvitationInvalid
+	}
+
+	s.recordMembership(ctx, userID, inv.OrganizationID, models.AuditMemberJoin, models.AuditTargetInvitation, inv.ID, models.AuditMetadata{
+		"role": inv.Role,
+	})
+
+	org, err := s.orgRepo.GetByID(ctx, inv.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Members who were already in the organization keep their role
+	role, err := s.orgRepo.GetMemberRole(ctx, org.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OrganizationMembership{ID: org.ID, Name: org.Name, Role: role, RequireMFA: org.RequireMFA}, nil
+}
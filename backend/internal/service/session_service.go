@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength is how much of a user agent sessions keep
+const maxUserAgentLength = 512
+
+type clientKey struct{}
+
+// ClientInfo describes the device making a request
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// WithClient records the device making a request, for the sessions it signs
+// in and the login attempts it makes
+func WithClient(ctx context.Context, client ClientInfo) context.Context {
+	if runes := []rune(client.UserAgent); len(runes) > maxUserAgentLength {
+		client.UserAgent = string(runes[:maxUserAgentLength])
+	}
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFromContext returns the device recorded with WithClient
+func clientFromContext(ctx context.Context) ClientInfo {
+	client, _ := ctx.Value(clientKey{}).(ClientInfo)
+	return client
+}
+
+// SessionService lets users see where they are signed in and sign out
+// other devices. Each session is a refresh token; revoking it ends the
+// session once its access token expires, within 15 minutes.
+type SessionService struct {
+	refreshTokenRepo *repository.RefreshTokenRepository
+}
+
+// NewSessionService creates a new SessionService
+func NewSessionService(refreshTokenRepo *repository.RefreshTokenRepository) *SessionService {
+	return &SessionService{
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// ListSessions lists the active sessions of the user, marking the one of
+// currentRefreshToken
+func (s *SessionService) ListSessions(ctx context.Context, userID uuid.UUID, currentRefreshToken string) ([]*models.Session, error) {
+	tokens, err := s.refreshTokenRepo.ListActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentHash := ""
+	if currentRefreshToken != "" {
+		currentHash = utils.HashRefreshToken(currentRefreshToken)
+	}
+
+	sessions := make([]*models.Session, len(tokens))
+	for i, token := range tokens {
+		sessions[i] = token.ToSession(token.TokenHash == currentHash)
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out one session of the user
+func (s *SessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return s.refreshTokenRepo.RevokeByID(ctx, userID, sessionID)
+}
+
+// RevokeOtherSessions signs out all sessions of the user but the one of
+// currentRefreshToken and returns how many were revoked
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentRefreshToken string) (*models.RevokeSessionsResponse, error) {
+	revoked, err := s.refreshTokenRepo.RevokeAllExcept(ctx, userID, utils.HashRefreshToken(currentRefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	return &models.RevokeSessionsResponse{Revoked: revoked}, nil
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/repository"
+	"db-importer/logger"
+	"db-importer/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadOffsetMismatch = errors.New("chunk does not start at the received offset")
+	ErrUploadTooLarge       = errors.New("upload exceeds the maximum size")
+	ErrChunkIncomplete      = errors.New("chunk body is shorter than announced")
+	ErrUploadNotInProgress  = errors.New("upload is not in progress")
+	ErrUploadIncomplete     = errors.New("upload has not received all bytes")
+	ErrInvalidChecksum      = errors.New("checksum must be a hex encoded SHA-256")
+	ErrChecksumMismatch     = errors.New("checksum does not match the received bytes")
+	ErrUploadNotCompleted   = errors.New("upload has not been completed")
+	ErrUploadWrongPurpose   = errors.New("upload was created for a different purpose")
+)
+
+// UploadConfig limits chunked uploads
+type UploadConfig struct {
+	MaxSize      int64         // maximum file size
+	MaxChunkSize int64         // maximum bytes per chunk request
+	TTL          time.Duration // how long unfinished and completed uploads are kept
+}
+
+// UploadService implements resumable chunked uploads: chunks are stored as
+// separate objects and concatenated into one object when the upload is
+// finalised with a checksum
+type UploadService struct {
+	uploadRepo *repository.UploadRepository
+	backend    storage.Backend
+	config     UploadConfig
+}
+
+// NewUploadService creates a new UploadService
+func NewUploadService(uploadRepo *repository.UploadRepository, backend storage.Backend, config UploadConfig) *UploadService {
+	return &UploadService{
+		uploadRepo: uploadRepo,
+		backend:    backend,
+		config:     config,
+	}
+}
+
+func uploadPrefix(id uuid.UUID) string {
+	return "uploads/" + id.String()
+}
+
+func uploadDataKey(id uuid.UUID) string {
+	return uploadPrefix(id) + "/data"
+}
+
+// CreateUpload starts an upload session
+func (s *UploadService) CreateUpload(ctx context.Context, userID uuid.UUID, req *models.CreateUploadRequest) (*models.UploadResponse, error) {
+	if req.TotalSize > s.config.MaxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	upload := &models.Upload{
+		UserID:    userID,
+		FileName:  req.FileName,
+		Purpose:   req.Purpose,
+		ExpiresAt: time.Now().Add(s.config.TTL),
+	}
+	if req.TotalSize > 0 {
+		upload.TotalSize = &req.TotalSize
+	}
+
+	if err := s.uploadRepo.Create(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	return upload.ToResponse(s.config.MaxChunkSize), nil
+}
+
+// GetUpload returns the state of an upload, including the offset the next
+// chunk must start at
+func (s *UploadService) GetUpload(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.UploadResponse, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return upload.ToResponse(s.config.MaxChunkSize), nil
+}
+
+// WriteChunk stores the bytes of body at offset. length is the announced
+// chunk size, or -1 when unknown. On ErrUploadOffsetMismatch the returned
+// response carries the offset the client has to resume from.
+func (s *UploadService) WriteChunk(ctx context.Context, id uuid.UUID, userID uuid.UUID, offset, length int64, body io.Reader) (*models.UploadResponse, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status != models.UploadStatusUploading {
+		return nil, ErrUploadNotInProgress
+	}
+	if offset != upload.ReceivedBytes {
+		return upload.ToResponse(s.config.MaxChunkSize), ErrUploadOffsetMismatch
+	}
+
+	limit := min(s.config.MaxChunkSize, s.config.MaxSize-offset)
+	if upload.TotalSize != nil {
+		limit = min(limit, *upload.TotalSize-offset)
+	}
+	if length > limit {
+		return nil, ErrUploadTooLarge
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	part := models.UploadPart{
+		Key: fmt.Sprintf("%s/parts/%020d-%s", uploadPrefix(id), offset, hex.EncodeToString(suffix)),
+	}
+
+	// Read one byte past the limit to detect oversized bodies
+	part.Size, err = s.backend.Put(ctx, part.Key, io.LimitReader(body, limit+1))
+	if err != nil {
+		s.backend.Delete(context.WithoutCancel(ctx), part.Key)
+		return nil, fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	switch {
+	case part.Size > limit:
+		err = ErrUploadTooLarge
+	case length >= 0 && part.Size != length:
+		err = ErrChunkIncomplete
+	case part.Size == 0:
+		err = s.backend.Delete(ctx, part.Key)
+		return upload.ToResponse(s.config.MaxChunkSize), err
+	}
+	if err != nil {
+		s.backend.Delete(ctx, part.Key)
+		return nil, err
+	}
+
+	ok, err := s.uploadRepo.AppendPart(ctx, id, offset, part)
+	if err != nil || !ok {
+		s.backend.Delete(context.WithoutCancel(ctx), part.Key)
+		if err != nil {
+			return nil, err
+		}
+		// A concurrent request stored a chunk at this offset first
+		current, getErr := s.uploadRepo.GetByID(ctx, id, userID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		return current.ToResponse(s.config.MaxChunkSize), ErrUploadOffsetMismatch
+	}
+
+	upload.ReceivedBytes += part.Size
+	return upload.ToResponse(s.config.MaxChunkSize), nil
+}
+
+// CompleteUpload concatenates the chunks into the final file and verifies
+// its SHA-256. Completing an already completed upload with the same
+// checksum succeeds again.
+func (s *UploadService) CompleteUpload(ctx context.Context, id uuid.UUID, userID uuid.UUID, checksum string) (*models.UploadResponse, error) {
+	expected, err := normalizeChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := s.uploadRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status == models.UploadStatusCompleted && upload.Checksum != nil && *upload.Checksum == expected {
+		return upload.ToResponse(s.config.MaxChunkSize), nil
+	}
+	if upload.Status != models.UploadStatusUploading {
+		return nil, ErrUploadNotInProgress
+	}
+	if upload.TotalSize != nil && upload.ReceivedBytes != *upload.TotalSize {
+		return nil, ErrUploadIncomplete
+	}
+
+	keys := make([]string, len(upload.Parts))
+	for i, part := range upload.Parts {
+		keys[i] = part.Key
+	}
+
+	parts := storage.ConcatReader(ctx, s.backend, keys)
+	defer parts.Close()
+
+	hash := sha256.New()
+	dataKey := uploadDataKey(id)
+	size, err := s.backend.Put(ctx, dataKey, io.TeeReader(parts, hash))
+	if err != nil {
+		s.backend.Delete(context.WithoutCancel(ctx), dataKey)
+		return nil, fmt.Errorf("failed to assemble upload: %w", err)
+	}
+	if size != upload.ReceivedBytes {
+		s.backend.Delete(ctx, dataKey)
+		return nil, fmt.Errorf("assembled %d bytes but %d were received", size, upload.ReceivedBytes)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		// The stored bytes are corrupt; the client has to start over
+		s.backend.DeletePrefix(ctx, uploadPrefix(id))
+		if err := s.uploadRepo.MarkFailed(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrChecksumMismatch
+	}
+
+	expiresAt := time.Now().Add(s.config.TTL)
+	if err := s.uploadRepo.MarkCompleted(ctx, id, dataKey, expected, expiresAt); err != nil {
+		return nil, err
+	}
+	if err := s.backend.DeletePrefix(ctx, uploadPrefix(id)+"/parts"); err != nil {
+		logger.Warn("Failed to delete upload chunks", map[string]interface{}{
+			"uploadId": id.String(),
+			"error":    err.Error(),
+		})
+	}
+
+	now := time.Now()
+	upload.Status = models.UploadStatusCompleted
+	upload.Checksum = &expected
+	upload.TotalSize = &size
+	upload.CompletedAt = &now
+	upload.ExpiresAt = expiresAt
+
+	return upload.ToResponse(s.config.MaxChunkSize), nil
+}
+
+// normalizeChecksum accepts "sha256:<hex>" or "<hex>"
+func normalizeChecksum(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	checksum = strings.TrimPrefix(checksum, "sha256:")
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", ErrInvalidChecksum
+	}
+	return checksum, nil
+}
+
+// DeleteUpload aborts or removes an upload and its stored bytes
+func (s *UploadService) DeleteUpload(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	if err := s.uploadRepo.Delete(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.backend.DeletePrefix(ctx, uploadPrefix(id))
+}
+
+// OpenUpload opens the assembled file of a completed upload made for the
+// given purpose; the caller must close it
+func (s *UploadService) OpenUpload(ctx context.Context, id uuid.UUID, userID uuid.UUID, purpose string) (io.ReadCloser, *models.Upload, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upload.Status != models.UploadStatusCompleted || upload.StorageKey == nil {
+		return nil, nil, ErrUploadNotCompleted
+	}
+	if upload.Purpose != purpose {
+		return nil, nil, ErrUploadWrongPurpose
+	}
+
+	rc, err := s.backend.Get(ctx, *upload.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	return rc, upload, nil
+}
+
+// CleanupExpiredUploads deletes expired uploads and their stored bytes
+func (s *UploadService) CleanupExpiredUploads(ctx context.Context) (int, error) {
+	ids, err := s.uploadRepo.ListExpiredIDs(ctx, 500)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err := s.backend.DeletePrefix(ctx, uploadPrefix(id)); err != nil {
+			return deleted, fmt.Errorf("failed to delete stored upload %s: %w", id, err)
+		}
+		if err := s.uploadRepo.DeleteByID(ctx, id); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// DeleteUserUploads deletes all uploads of a user and their stored bytes
+func (s *UploadService) DeleteUserUploads(ctx context.Context, userID uuid.UUID) error {
+	ids, err := s.uploadRepo.ListIDsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.backend.DeletePrefix(ctx, uploadPrefix(id)); err != nil {
+			return fmt.Errorf("failed to delete stored upload %s: %w", id, err)
+		}
+		if err := s.uploadRepo.DeleteByID(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
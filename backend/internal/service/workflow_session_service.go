@@ -5,25 +5,89 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"db-importer/generator"
+	"db-importer/infer"
+	"db-importer/ingest"
 	"db-importer/internal/models"
 	"db-importer/internal/repository"
+	"db-importer/logger"
+	"db-importer/mapping"
+	"db-importer/parser"
+	"db-importer/storage"
+	"db-importer/transform"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrNoTablesFound is returned when an introspected database or an
+	// uploaded schema has no tables
+	ErrNoTablesFound = errors.New("no tables found")
+	// ErrInvalidUploadContent is returned when an uploaded file cannot be read
+	ErrInvalidUploadContent = errors.New("invalid upload content")
+	// ErrNoDataFile is returned when a step needs the session's data file
+	// before one was saved
+	ErrNoDataFile = errors.New("the session has no data file yet")
+	// ErrTooManySessions is returned when a user starting a session already
+	// has the maximum number of active sessions
+	ErrTooManySessions = errors.New("too many workflow sessions")
+	// ErrNoDataset is returned when SQL is generated for a session that kept
+	// only the sample rows of its data file
+	ErrNoDataset = errors.New("the session did not keep the rows of its data file")
+	// ErrSessionIncomplete is returned when SQL is generated for a session
+	// without a selected table or a mapping
+	ErrSessionIncomplete = errors.New("the session has no table or mapping yet")
+	// ErrSessionConflict is returned when a session was changed since the
+	// version a write is based on
+	ErrSessionConflict = errors.New("the workflow session was changed by another request")
+	// ErrHistoryVersionNotFound is returned when restoring a version that is
+	// not in the session history
+	ErrHistoryVersionNotFound = errors.New("version not found in the session history")
+)
+
+const (
+	// sampleRowLimit is the number of data rows kept on the session
+	sampleRowLimit = 50
+	// maxSessionsPerUser is the number of active sessions a user may keep
+	maxSessionsPerUser = 20
+	// defaultSessionName names sessions started without a name
+	defaultSessionName = "Untitled import"
+)
+
 // WorkflowSessionService handles workflow session business logic
 type WorkflowSessionService struct {
-	sessionRepo *repository.WorkflowSessionRepository
+	sessionRepo        *repository.WorkflowSessionRepository
+	orgs               *OrganizationService
+	connectionProfiles *ConnectionProfileService
+	uploads            *UploadService
+	templates          *MappingTemplateService
+	imports            *ImportService
+	blobs              storage.Backend // nil keeps schema content compressed in the database
+	audit              *AuditService
 }
 
-// NewWorkflowSessionService creates a new WorkflowSessionService
-func NewWorkflowSessionService(sessionRepo *repository.WorkflowSessionRepository) *WorkflowSessionService {
+// NewWorkflowSessionService creates a new WorkflowSessionService. Schema
+// content and the rows of data files are kept in blobs when given;
+// otherwise schema content is compressed in the session and only the
+// sample rows are kept.
+func NewWorkflowSessionService(sessionRepo *repository.WorkflowSessionRepository, orgs *OrganizationService, connectionProfiles *ConnectionProfileService, uploads *UploadService, templates *MappingTemplateService, imports *ImportService, blobs storage.Backend, audit *AuditService) *WorkflowSessionService {
 	return &WorkflowSessionService{
-		sessionRepo: sessionRepo,
+		sessionRepo:        sessionRepo,
+		orgs:               orgs,
+		connectionProfiles: connectionProfiles,
+		uploads:            uploads,
+		templates:          templates,
+		imports:            imports,
+		blobs:              blobs,
+		audit:              audit,
 	}
 }
 
@@ -78,9 +142,138 @@ func decompressContent(compressedContent string) (string, error) {
 	return buf.String(), nil
 }
 
+// Writes to a session take the version they are based on, from an If-Match
+// header, as expectedVersion and fail with ErrSessionConflict when the
+// session is at another one. anyVersion is passed for writes not based on
+// a particular version.
+const anyVersion = 0
+
+// getSession returns the active session with the given ID in the workspace
+// the user acts in with at least minRole, or the user's most recently
+// updated one there when sessionID is uuid.Nil, together with the
+// workspace. It returns a nil session when there is no such session, and
+// ErrSessionConflict when expectedVersion is not anyVersion and the session
+// is at another version.
+func (s *WorkflowSessionService) getSession(ctx context.Context, userID, sessionID uuid.UUID, minRole models.OrganizationRole, expectedVersion int) (*models.WorkflowSession, models.Workspace, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, minRole)
+	if err != nil {
+		return nil, ws, err
+	}
+
+	var session *models.WorkflowSession
+	if sessionID == uuid.Nil {
+		session, err = s.sessionRepo.GetLatest(ctx, ws)
+	} else {
+		session, err = s.sessionRepo.GetByID(ctx, sessionID, ws)
+	}
+	if err != nil || session == nil {
+		return nil, ws, err
+	}
+
+	if expectedVersion != anyVersion && expectedVersion != session.Version {
+		return nil, ws, fmt.Errorf("%w: it is at version %d, not %d", ErrSessionConflict, session.Version, expectedVersion)
+	}
+	return session, ws, nil
+}
+
+// updateSession saves a changed session and records the change in its
+// history. It fails with ErrSessionConflict when another request changed
+// the session after it was read.
+func (s *WorkflowSessionService) updateSession(ctx context.Context, session *models.WorkflowSession, change models.WorkflowSessionChange) error {
+	updated, err := s.sessionRepo.Update(ctx, session, change)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if !updated {
+		return ErrSessionConflict
+	}
+	return nil
+}
+
+// CreateSession starts a new, empty session next to the user's other
+// sessions
+func (s *WorkflowSessionService) CreateSession(ctx context.Context, userID uuid.UUID, req *models.CreateWorkflowSessionRequest) (*models.WorkflowSessionResponse, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.sessionRepo.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxSessionsPerUser {
+		return nil, fmt.Errorf("%w: at most %d sessions can be in progress", ErrTooManySessions, maxSessionsPerUser)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultSessionName
+	}
+
+	session := &models.WorkflowSession{
+		UserID:         userID,
+		OrganizationID: ws.OrganizationID,
+		Name:           name,
+		CurrentStep:    int(models.StepUploadSchema),
+		ExpiresAt:      time.Now().Add(7 * 24 * time.Hour), // 7 days
+	}
+
+	if err := s.sessionRepo.Create(ctx, session, models.SessionCreated); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session.ToResponse(), nil
+}
+
+// ListSessions lists the active workflow sessions of a user, most recently
+// updated first
+func (s *WorkflowSessionService) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.WorkflowSessionSummary, error) {
+	ws, err := s.orgs.Workspace(ctx, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.List(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.WorkflowSessionSummary, len(sessions))
+	for i, session := range sessions {
+		summaries[i] = session.ToSummary()
+	}
+	return summaries, nil
+}
+
+// RenameSession renames a workflow session
+func (s *WorkflowSessionService) RenameSession(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.RenameWorkflowSessionRequest) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("session name must not be blank")
+	}
+	session.Name = name
+
+	err = s.updateSession(ctx, session, models.SessionRenamed)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.ToResponse(), nil
+}
+
 // GetSession retrieves the active workflow session for a user
-func (s *WorkflowSessionService) GetSession(ctx context.Context, userID uuid.UUID) (*models.WorkflowSessionResponse, error) {
-	session, err := s.sessionRepo.GetByUserID(ctx, userID)
+func (s *WorkflowSessionService) GetSession(ctx context.Context, userID, sessionID uuid.UUID) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleViewer, anyVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -93,8 +286,8 @@ func (s *WorkflowSessionService) GetSession(ctx context.Context, userID uuid.UUI
 }
 
 // GetSessionWithSchema retrieves the active workflow session including schema content
-func (s *WorkflowSessionService) GetSessionWithSchema(ctx context.Context, userID uuid.UUID) (*models.WorkflowSessionWithSchema, error) {
-	session, err := s.sessionRepo.GetByUserID(ctx, userID)
+func (s *WorkflowSessionService) GetSessionWithSchema(ctx context.Context, userID, sessionID uuid.UUID) (*models.WorkflowSessionWithSchema, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleViewer, anyVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -103,9 +296,15 @@ func (s *WorkflowSessionService) GetSessionWithSchema(ctx context.Context, userI
 		return nil, nil // No active session
 	}
 
-	// Decompress schema content
 	var schemaContent string
-	if session.SchemaContent != nil && *session.SchemaContent != "" {
+	switch {
+	case session.SchemaStorageKey != nil:
+		schemaContent, err = readBlob(ctx, s.blobs, *session.SchemaStorageKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema content: %w", err)
+		}
+	case session.SchemaContent != nil && *session.SchemaContent != "":
+		// Decompress schema content
 		schemaContent, err = decompressContent(*session.SchemaContent)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decompress schema content: %w", err)
@@ -121,56 +320,372 @@ func (s *WorkflowSessionService) GetSessionWithSchema(ctx context.Context, userI
 }
 
 // SaveSchema saves schema content and tables (step 1)
-func (s *WorkflowSessionService) SaveSchema(ctx context.Context, userID uuid.UUID, req *models.SaveSchemaRequest) (*models.WorkflowSessionResponse, error) {
-	// Compress schema content
-	compressedSchema, err := compressContent(req.SchemaContent)
+func (s *WorkflowSessionService) SaveSchema(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveSchemaRequest) (*models.WorkflowSessionResponse, error) {
+	// Remember the schema dialect so SQL generation can quote accordingly;
+	// fall back to detection when the client did not send one
+	dialect := req.Dialect
+	if dialect == "" {
+		dialect = string(parser.DetectDialect(req.SchemaContent).Dialect)
+	}
+
+	return s.saveSchema(ctx, userID, sessionID, expectedVersion, req.SchemaContent, req.Tables, dialect, "content")
+}
+
+// SaveSchemaFromConnection fills step 1 by introspecting a saved connection
+// profile, as an alternative to uploading schema content
+func (s *WorkflowSessionService) SaveSchemaFromConnection(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveSchemaFromConnectionRequest) (*models.WorkflowSessionResponse, error) {
+	profileID, err := uuid.Parse(req.ProfileID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile ID: %w", err)
+	}
+
+	tables, profile, err := s.connectionProfiles.IntrospectProfile(ctx, profileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, ErrNoTablesFound
+	}
+
+	// There is no uploaded file; keep a note of where the schema came from
+	content := fmt.Sprintf("-- Schema introspected from connection profile %q (%s) at %s\n",
+		profile.Name, profile.DisplayDSN, time.Now().UTC().Format(time.RFC3339))
+
+	return s.saveSchema(ctx, userID, sessionID, expectedVersion, content, tableDefinitionsFromParser(tables), profile.Dialect, "connection")
+}
+
+// SaveSchemaFromUpload fills step 1 by parsing a completed chunked upload,
+// for schema files too large for a single request
+func (s *WorkflowSessionService) SaveSchemaFromUpload(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveSchemaFromUploadRequest) (*models.WorkflowSessionResponse, error) {
+	uploadID, err := uuid.Parse(req.UploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	forcedDialect, err := parser.ParseDialect(req.Dialect)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+	}
+
+	file, _, err := s.uploads.OpenUpload(ctx, uploadID, userID, models.UploadPurposeSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Parsers work on the whole document
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	schemaContent := string(content)
+
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	result, err := parser.DefaultRegistry().Parse(schemaContent, parser.ParseOptions{Format: format, Dialect: forcedDialect})
 	if err != nil {
-		return nil, fmt.Errorf("failed to compress schema: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+	}
+	if len(result.Tables) == 0 {
+		return nil, ErrNoTablesFound
+	}
+
+	// Same precedence as /parse-schema: explicit, declared by the format, detected
+	dialect := forcedDialect
+	if dialect == parser.DialectUnknown {
+		dialect = result.Dialect
+	}
+	if dialect == parser.DialectUnknown {
+		dialect = parser.DetectDialect(schemaContent).Dialect
 	}
 
+	return s.saveSchema(ctx, userID, sessionID, expectedVersion, schemaContent, tableDefinitionsFromParser(result.Tables), string(dialect), "upload")
+}
+
+// saveSchema updates the session with step 1 data. Without a session ID a
+// session is created when the user has none. source names where the schema
+// came from in the audit log.
+func (s *WorkflowSessionService) saveSchema(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, schemaContent string, tables []models.TableDefinition, dialect, source string) (*models.WorkflowSessionResponse, error) {
 	// Check if session already exists
-	existingSession, err := s.sessionRepo.GetByUserID(ctx, userID)
+	existingSession, ws, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if existingSession == nil && sessionID != uuid.Nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	compressedSchema, storageKey, err := s.storeSchemaContent(ctx, userID, schemaContent)
 	if err != nil {
 		return nil, err
 	}
 
 	expiresAt := time.Now().Add(7 * 24 * time.Hour) // 7 days
 
+	var dialectPtr *string
+	if dialect != "" {
+		dialectPtr = &dialect
+	}
+
 	if existingSession != nil {
+		previousKey := existingSession.SchemaStorageKey
+
 		// Update existing session
 		existingSession.CurrentStep = int(models.StepUploadSchema)
-		existingSession.SchemaContent = &compressedSchema
-		existingSession.SchemaTables = req.Tables
+		existingSession.SchemaContent = compressedSchema
+		existingSession.SchemaStorageKey = storageKey
+		existingSession.SchemaTables = tables
+		existingSession.Dialect = dialectPtr
 		existingSession.ExpiresAt = expiresAt
 
-		err = s.sessionRepo.Update(ctx, existingSession)
+		err = s.updateSession(ctx, existingSession, models.SessionSchema)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update session: %w", err)
+			s.discardObject(ctx, storageKey)
+			return nil, err
 		}
 
+		s.discardObject(ctx, previousKey)
+		s.recordSchemaUpload(ctx, ws, existingSession, source, len(schemaContent))
 		return existingSession.ToResponse(), nil
 	}
 
 	// Create new session
 	session := &models.WorkflowSession{
-		UserID:        userID,
-		CurrentStep:   int(models.StepUploadSchema),
-		SchemaContent: &compressedSchema,
-		SchemaTables:  req.Tables,
-		ExpiresAt:     expiresAt,
+		UserID:           userID,
+		OrganizationID:   ws.OrganizationID,
+		Name:             defaultSessionName,
+		CurrentStep:      int(models.StepUploadSchema),
+		SchemaContent:    compressedSchema,
+		SchemaStorageKey: storageKey,
+		SchemaTables:     tables,
+		Dialect:          dialectPtr,
+		ExpiresAt:        expiresAt,
 	}
 
-	err = s.sessionRepo.Create(ctx, session)
+	err = s.sessionRepo.Create(ctx, session, models.SessionSchema)
 	if err != nil {
+		s.discardObject(ctx, storageKey)
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
+	s.recordSchemaUpload(ctx, ws, session, source, len(schemaContent))
 	return session.ToResponse(), nil
 }
 
+// recordSchemaUpload records the schema saved to a session
+func (s *WorkflowSessionService) recordSchemaUpload(ctx context.Context, ws models.Workspace, session *models.WorkflowSession, source string, size int) {
+	tableNames := make([]string, len(session.SchemaTables))
+	for i, table := range session.SchemaTables {
+		tableNames[i] = table.Name
+	}
+
+	metadata := models.AuditMetadata{
+		"source": source,
+		"size":   size,
+		"tables": tableNames,
+	}
+	if session.Dialect != nil {
+		metadata["dialect"] = *session.Dialect
+	}
+	s.audit.RecordInWorkspace(ctx, ws, models.AuditSessionSchemaSet, models.AuditTargetWorkflowSession, session.ID.String(), metadata)
+}
+
+// schemaPrefix is where the schema content of a user's sessions is stored
+func schemaPrefix(userID uuid.UUID) string {
+	return "schemas/" + userID.String()
+}
+
+// datasetPrefix is where the data files of a user's sessions are stored
+func datasetPrefix(userID uuid.UUID) string {
+	return "datasets/" + userID.String()
+}
+
+// datasetUpload streams rows into a dataset object while they are read. It
+// is nil without object storage, and then drops the rows.
+type datasetUpload struct {
+	ctx    context.Context
+	blobs  storage.Backend
+	key    string
+	pipe   *io.PipeWriter
+	writer *ingest.DatasetWriter
+	stored chan error
+	done   bool
+}
+
+// errDatasetAborted stops storing a dataset that is not needed anymore
+var errDatasetAborted = errors.New("dataset aborted")
+
+// createDataset starts storing the rows of a data file with the given
+// headers
+func (s *WorkflowSessionService) createDataset(ctx context.Context, userID uuid.UUID, headers []string) (*datasetUpload, error) {
+	if s.blobs == nil {
+		return nil, nil
+	}
+
+	reader, pipe := io.Pipe()
+	d := &datasetUpload{
+		ctx:    ctx,
+		blobs:  s.blobs,
+		key:    fmt.Sprintf("%s/%s.jsonl.gz", datasetPrefix(userID), uuid.New()),
+		pipe:   pipe,
+		stored: make(chan error, 1),
+	}
+	go func() {
+		_, err := d.blobs.Put(ctx, d.key, reader)
+		// Unblocks the writer when storing failed before the end
+		reader.CloseWithError(err)
+		d.stored <- err
+	}()
+
+	writer, err := ingest.NewDatasetWriter(pipe, headers)
+	if err != nil {
+		d.abort()
+		return nil, fmt.Errorf("failed to store data file: %w", err)
+	}
+	d.writer = writer
+	return d, nil
+}
+
+// write appends a row
+func (d *datasetUpload) write(row []interface{}) error {
+	if d == nil {
+		return nil
+	}
+	if err := d.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to store data file: %w", err)
+	}
+	return nil
+}
+
+// finish waits until the dataset is stored and returns its key and row
+// count, both nil without object storage
+func (d *datasetUpload) finish() (*string, *int, error) {
+	if d == nil {
+		return nil, nil, nil
+	}
+
+	err := d.writer.Close()
+	d.pipe.CloseWithError(err)
+	if storeErr := <-d.stored; err == nil {
+		err = storeErr
+	}
+	d.done = true
+	if err != nil {
+		deleteBlobs(context.WithoutCancel(d.ctx), d.blobs, d.key)
+		return nil, nil, fmt.Errorf("failed to store data file: %w", err)
+	}
+
+	rows := d.writer.Rows()
+	return &d.key, &rows, nil
+}
+
+// abort deletes a dataset that was not finished; it does nothing after
+// finish
+func (d *datasetUpload) abort() {
+	if d == nil || d.done {
+		return
+	}
+	d.done = true
+	d.pipe.CloseWithError(errDatasetAborted)
+	<-d.stored
+	deleteBlobs(context.WithoutCancel(d.ctx), d.blobs, d.key)
+}
+
+// storeDataset stores rows sent by the client as a dataset
+func (s *WorkflowSessionService) storeDataset(ctx context.Context, userID uuid.UUID, headers []string, rows [][]interface{}) (*string, *int, error) {
+	dataset, err := s.createDataset(ctx, userID, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dataset.abort()
+
+	for _, row := range rows {
+		if err := dataset.write(row); err != nil {
+			return nil, nil, err
+		}
+	}
+	return dataset.finish()
+}
+
+// openDataset opens a stored dataset; the returned func closes it
+func (s *WorkflowSessionService) openDataset(ctx context.Context, key string) (*ingest.DatasetReader, func(), error) {
+	if s.blobs == nil {
+		return nil, nil, fmt.Errorf("object storage is not configured")
+	}
+
+	rc, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+
+	reader, err := ingest.NewDatasetReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	return reader, func() {
+		reader.Close()
+		rc.Close()
+	}, nil
+}
+
+// storeSchemaContent stores schema content in object storage and returns
+// its key, or compresses it for the session row when there is no object
+// storage
+func (s *WorkflowSessionService) storeSchemaContent(ctx context.Context, userID uuid.UUID, content string) (*string, *string, error) {
+	if s.blobs == nil {
+		// Compress schema content
+		compressedSchema, err := compressContent(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compress schema: %w", err)
+		}
+		return &compressedSchema, nil, nil
+	}
+
+	key := fmt.Sprintf("%s/%s.sql", schemaPrefix(userID), uuid.New())
+	if _, _, err := storeBlob(ctx, s.blobs, key, content); err != nil {
+		return nil, nil, fmt.Errorf("failed to store schema: %w", err)
+	}
+	return nil, &key, nil
+}
+
+// discardObject deletes schema content or a dataset that is no longer
+// referenced
+func (s *WorkflowSessionService) discardObject(ctx context.Context, storageKey *string) {
+	if storageKey != nil {
+		deleteBlobs(context.WithoutCancel(ctx), s.blobs, *storageKey)
+	}
+}
+
+// tableDefinitionsFromParser converts parsed or introspected tables to the
+// session representation
+func tableDefinitionsFromParser(tables []parser.Table) []models.TableDefinition {
+	definitions := make([]models.TableDefinition, len(tables))
+	for i, t := range tables {
+		definition := models.TableDefinition{Name: t.Name}
+		for _, f := range t.Fields {
+			definition.Fields = append(definition.Fields, models.Field{
+				Name:          f.Name,
+				Type:          f.Type,
+				Nullable:      f.Nullable,
+				PrimaryKey:    f.PrimaryKey,
+				Unique:        f.Unique,
+				AutoIncrement: f.AutoIncrement,
+				Default:       f.Default,
+			})
+		}
+		for _, fk := range t.ForeignKeys {
+			definition.ForeignKeys = append(definition.ForeignKeys, models.ForeignKey(fk))
+		}
+		definitions[i] = definition
+	}
+	return definitions
+}
+
 // SaveTableSelection saves selected table (step 2)
-func (s *WorkflowSessionService) SaveTableSelection(ctx context.Context, userID uuid.UUID, req *models.SaveTableSelectionRequest) (*models.WorkflowSessionResponse, error) {
-	session, err := s.sessionRepo.GetByUserID(ctx, userID)
+func (s *WorkflowSessionService) SaveTableSelection(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveTableSelectionRequest) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -183,17 +698,17 @@ func (s *WorkflowSessionService) SaveTableSelection(ctx context.Context, userID
 	session.CurrentStep = int(models.StepSelectTable)
 	session.SelectedTableName = &req.TableName
 
-	err = s.sessionRepo.Update(ctx, session)
+	err = s.updateSession(ctx, session, models.SessionTable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update session: %w", err)
+		return nil, err
 	}
 
 	return session.ToResponse(), nil
 }
 
 // SaveDataFile saves data file information (step 3)
-func (s *WorkflowSessionService) SaveDataFile(ctx context.Context, userID uuid.UUID, req *models.SaveDataFileRequest) (*models.WorkflowSessionResponse, error) {
-	session, err := s.sessionRepo.GetByUserID(ctx, userID)
+func (s *WorkflowSessionService) SaveDataFile(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveDataFileRequest) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -204,27 +719,124 @@ func (s *WorkflowSessionService) SaveDataFile(ctx context.Context, userID uuid.U
 
 	// Limit sample data to 50 rows
 	sampleData := req.SampleData
-	if len(sampleData) > 50 {
-		sampleData = sampleData[:50]
+	if len(sampleData) == 0 {
+		sampleData = req.Rows
+	}
+	if len(sampleData) > sampleRowLimit {
+		sampleData = sampleData[:sampleRowLimit]
+	}
+
+	// Without rows only the sample of the new file is known
+	var dataKey *string
+	var rowCount *int
+	if len(req.Rows) > 0 {
+		dataKey, rowCount, err = s.storeDataset(ctx, userID, req.Headers, req.Rows)
+		if err != nil {
+			return nil, err
+		}
 	}
+	previousDataKey := session.DataStorageKey
 
 	// Update session
 	session.CurrentStep = int(models.StepUploadData)
 	session.DataFileName = &req.FileName
 	session.DataHeaders = req.Headers
 	session.SampleData = sampleData
+	session.DataStorageKey = dataKey
+	session.DataRowCount = rowCount
 
-	err = s.sessionRepo.Update(ctx, session)
+	err = s.updateSession(ctx, session, models.SessionData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update session: %w", err)
+		s.discardObject(ctx, dataKey)
+		return nil, err
 	}
 
-	return session.ToResponse(), nil
+	s.discardObject(ctx, previousDataKey)
+	return s.withTemplateSuggestions(ctx, session), nil
+}
+
+// SaveDataFromUpload fills step 3 from a completed chunked CSV upload. The
+// rows are kept in object storage next to the sample.
+func (s *WorkflowSessionService) SaveDataFromUpload(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveDataFromUploadRequest) (*models.WorkflowSessionResponse, error) {
+	uploadID, err := uuid.Parse(req.UploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	file, upload, err := s.uploads.OpenUpload(ctx, uploadID, userID, models.UploadPurposeData)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if !ingest.Supported(upload.FileName) {
+		return nil, ingest.ErrUnsupportedFormat
+	}
+
+	reader, err := ingest.NewCSVReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+	}
+
+	dataset, err := s.createDataset(ctx, userID, reader.Headers())
+	if err != nil {
+		return nil, err
+	}
+	defer dataset.abort()
+
+	sampleData := make([][]interface{}, 0, sampleRowLimit)
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+		}
+		if err := dataset.write(row); err != nil {
+			return nil, err
+		}
+		if len(sampleData) < sampleRowLimit {
+			sampleData = append(sampleData, row)
+		}
+	}
+
+	dataKey, rowCount, err := dataset.finish()
+	if err != nil {
+		return nil, err
+	}
+	previousDataKey := session.DataStorageKey
+
+	// Update session
+	session.CurrentStep = int(models.StepUploadData)
+	session.DataFileName = &upload.FileName
+	session.DataHeaders = reader.Headers()
+	session.SampleData = sampleData
+	session.DataStorageKey = dataKey
+	session.DataRowCount = rowCount
+
+	err = s.updateSession(ctx, session, models.SessionData)
+	if err != nil {
+		s.discardObject(ctx, dataKey)
+		return nil, err
+	}
+
+	s.discardObject(ctx, previousDataKey)
+	return s.withTemplateSuggestions(ctx, session), nil
 }
 
 // SaveMapping saves column mapping and transformations (step 4)
-func (s *WorkflowSessionService) SaveMapping(ctx context.Context, userID uuid.UUID, req *models.SaveMappingRequest) (*models.WorkflowSessionResponse, error) {
-	session, err := s.sessionRepo.GetByUserID(ctx, userID)
+func (s *WorkflowSessionService) SaveMapping(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.SaveMappingRequest) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -238,29 +850,636 @@ func (s *WorkflowSessionService) SaveMapping(ctx context.Context, userID uuid.UU
 	session.ColumnMapping = req.Mapping
 	session.FieldTransformations = req.Transformations
 
-	err = s.sessionRepo.Update(ctx, session)
+	err = s.updateSession(ctx, session, models.SessionMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.ToResponse(), nil
+}
+
+// selectedTable returns the definition of the session's selected table, or
+// nil when none is selected
+func selectedTable(session *models.WorkflowSession) *models.TableDefinition {
+	if session.SelectedTableName == nil {
+		return nil
+	}
+	for i := range session.SchemaTables {
+		if session.SchemaTables[i].Name == *session.SelectedTableName {
+			return &session.SchemaTables[i]
+		}
+	}
+	return nil
+}
+
+// withTemplateSuggestions returns the session with the mapping templates
+// matching its data file. Suggestions are a convenience, so failing to
+// list them is only logged.
+func (s *WorkflowSessionService) withTemplateSuggestions(ctx context.Context, session *models.WorkflowSession) *models.WorkflowSessionResponse {
+	resp := session.ToResponse()
+	suggestions, err := s.templates.SuggestTemplates(ctx, session.UserID, session.DataHeaders, selectedTable(session))
+	if err != nil {
+		logger.Warn("Failed to suggest mapping templates", map[string]interface{}{
+			"user_id": session.UserID.String(),
+			"error":   err.Error(),
+		})
+		return resp
+	}
+	resp.SuggestedTemplates = suggestions
+	return resp
+}
+
+// SuggestMappingTemplates lists the mapping templates matching the data file
+// of the session
+func (s *WorkflowSessionService) SuggestMappingTemplates(ctx context.Context, userID, sessionID uuid.UUID) ([]models.MappingTemplateSuggestion, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleViewer, anyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	suggestions, err := s.templates.SuggestTemplates(ctx, userID, session.DataHeaders, selectedTable(session))
+	if err != nil {
+		return nil, err
+	}
+	if suggestions == nil {
+		suggestions = []models.MappingTemplateSuggestion{}
+	}
+	return suggestions, nil
+}
+
+// ApplyMappingTemplate sets the session's mapping and transformations from
+// a template (step 4). Template headers are matched with the data file
+// headers exactly, then ignoring case, spaces and punctuation; fields the
+// selected table lacks are left out and reported.
+func (s *WorkflowSessionService) ApplyMappingTemplate(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, templateID uuid.UUID) (*models.ApplyMappingTemplateResult, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+	if len(session.DataHeaders) == 0 {
+		return nil, fmt.Errorf("%w: the session has no data file yet", ErrInvalidTemplate)
+	}
+
+	template, _, err := s.templates.getTemplate(ctx, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(session.DataHeaders))
+	byNormalized := make(map[string]string, len(session.DataHeaders))
+	for _, h := range session.DataHeaders {
+		present[h] = true
+		if n := normalizeHeader(h); byNormalized[n] == "" {
+			byNormalized[n] = h
+		}
+	}
+	var fields map[string]bool
+	if table := selectedTable(session); table != nil {
+		fields = make(map[string]bool, len(table.Fields))
+		for _, f := range table.Fields {
+			fields[f.Name] = true
+		}
+	}
+
+	result := &models.ApplyMappingTemplateResult{Applied: map[string]string{}}
+	transformations := models.FieldTransformations{}
+	headers := make([]string, 0, len(template.ColumnMapping))
+	for header := range template.ColumnMapping {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		field := template.ColumnMapping[header]
+		target := header
+		if !present[header] {
+			target = byNormalized[normalizeHeader(header)]
+		}
+		switch {
+		case target == "" || result.Applied[target] != "":
+			result.MissingHeaders = append(result.MissingHeaders, header)
+		case fields != nil && !fields[field]:
+			result.UnknownFields = append(result.UnknownFields, field)
+		default:
+			result.Applied[target] = field
+			if name, ok := template.FieldTransformations[field]; ok {
+				transformations[field] = name
+			}
+		}
+	}
+
+	session.CurrentStep = int(models.StepMapColumns)
+	session.ColumnMapping = models.ColumnMapping(result.Applied)
+	session.FieldTransformations = transformations
+
+	err = s.updateSession(ctx, session, models.SessionTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Session = session.ToResponse()
+	return result, nil
+}
+
+// InferTable creates the session's target table from the rows of its data
+// file (the sample rows when only those were kept), for data that has no
+// table yet. The inferred table replaces the session schema and is
+// selected, and every column is mapped to it.
+func (s *WorkflowSessionService) InferTable(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.InferTableRequest) (*models.InferTableResult, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+	if len(session.DataHeaders) == 0 {
+		return nil, ErrNoDataFile
+	}
+
+	if session.DataStorageKey == nil {
+		result := infer.Infer(session.DataHeaders, session.SampleData)
+		return s.saveInferredTable(ctx, userID, session, req, result)
+	}
+
+	reader, closeDataset, err := s.openDataset(ctx, *session.DataStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDataset()
+
+	inferrer := infer.New(reader.Headers())
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data file: %w", err)
+		}
+		inferrer.Add(row)
+	}
+	return s.saveInferredTable(ctx, userID, session, req, inferrer.Result())
+}
+
+// InferTableFromUpload fills step 3 from a completed chunked CSV upload and
+// infers the target table from all of its rows, then continues as
+// InferTable. Without a session ID a session is created when the user has
+// none.
+func (s *WorkflowSessionService) InferTableFromUpload(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.InferTableFromUploadRequest) (*models.InferTableResult, error) {
+	uploadID, err := uuid.Parse(req.UploadID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	session, ws, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil && sessionID != uuid.Nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	file, upload, err := s.uploads.OpenUpload(ctx, uploadID, userID, models.UploadPurposeData)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if !ingest.Supported(upload.FileName) {
+		return nil, ingest.ErrUnsupportedFormat
+	}
+
+	reader, err := ingest.NewCSVReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+	}
+
+	dataset, err := s.createDataset(ctx, userID, reader.Headers())
+	if err != nil {
+		return nil, err
+	}
+	defer dataset.abort()
+
+	inferrer := infer.New(reader.Headers())
+	sampleData := make([][]interface{}, 0, sampleRowLimit)
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUploadContent, err)
+		}
+		if err := dataset.write(row); err != nil {
+			return nil, err
+		}
+		inferrer.Add(row)
+		if len(sampleData) < sampleRowLimit {
+			sampleData = append(sampleData, row)
+		}
+	}
+
+	dataKey, rowCount, err := dataset.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		session = &models.WorkflowSession{UserID: userID, OrganizationID: ws.OrganizationID, Name: defaultSessionName}
+	}
+	previousDataKey := session.DataStorageKey
+	session.DataFileName = &upload.FileName
+	session.DataHeaders = reader.Headers()
+	session.SampleData = sampleData
+	session.DataStorageKey = dataKey
+	session.DataRowCount = rowCount
+
+	result, err := s.saveInferredTable(ctx, userID, session, &req.InferTableRequest, inferrer.Result())
+	if err != nil {
+		s.discardObject(ctx, dataKey)
+		return nil, err
+	}
+
+	s.discardObject(ctx, previousDataKey)
+	return result, nil
+}
+
+// saveInferredTable stores the CREATE TABLE statement of an inferred table
+// as the session schema and maps the data file onto the table. A session
+// without ID is created.
+func (s *WorkflowSessionService) saveInferredTable(ctx context.Context, userID uuid.UUID, session *models.WorkflowSession, req *models.InferTableRequest, result *infer.Result) (*models.InferTableResult, error) {
+	dialectName := req.Dialect
+	if dialectName == "" && session.Dialect != nil {
+		dialectName = *session.Dialect
+	}
+	dialect, err := parser.ParseDialect(dialectName)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == parser.DialectUnknown {
+		dialect = parser.DialectMySQL
+	}
+
+	tableName := req.TableName
+	if tableName == "" && session.DataFileName != nil {
+		base := filepath.Base(*session.DataFileName)
+		tableName = mapping.Identifier(strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	if tableName == "" {
+		tableName = "imported_data"
+	}
+
+	table := result.Table(tableName, dialect)
+	createSQL := generator.GenerateCreateTable(dialect, table)
+
+	creating := session.ID == uuid.Nil
+	compressedSchema, storageKey, err := s.storeSchemaContent(ctx, userID, createSQL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update session: %w", err)
+		return nil, err
+	}
+	previousKey := session.SchemaStorageKey
+
+	dialectValue := string(dialect)
+	session.CurrentStep = int(models.StepMapColumns)
+	session.SchemaContent = compressedSchema
+	session.SchemaStorageKey = storageKey
+	session.SchemaTables = tableDefinitionsFromParser([]parser.Table{table})
+	session.Dialect = &dialectValue
+	session.SelectedTableName = &table.Name
+	session.ColumnMapping = models.ColumnMapping(result.Mapping())
+	session.FieldTransformations = models.FieldTransformations(result.Transformations())
+	session.ExpiresAt = time.Now().Add(7 * 24 * time.Hour) // 7 days
+
+	if creating {
+		if err = s.sessionRepo.Create(ctx, session, models.SessionInferred); err != nil {
+			err = fmt.Errorf("failed to create session: %w", err)
+		}
+	} else {
+		err = s.updateSession(ctx, session, models.SessionInferred)
+	}
+	if err != nil {
+		s.discardObject(ctx, storageKey)
+		return nil, err
+	}
+	s.discardObject(ctx, previousKey)
+
+	columns := make([]models.InferredColumn, len(result.Columns))
+	for i, c := range result.Columns {
+		columns[i] = models.InferredColumn{
+			Header:         c.Header,
+			Name:           c.Name,
+			Kind:           string(c.Kind),
+			Type:           table.Fields[i].Type,
+			Nullable:       c.Nullable,
+			PrimaryKey:     c.PrimaryKey,
+			MaxLength:      c.MaxLength,
+			DateFormat:     c.DateFormat,
+			Transformation: c.Transformation,
+		}
+	}
+
+	return &models.InferTableResult{
+		Session: session.ToResponse(),
+		SQL:     createSQL,
+		Rows:    result.Rows,
+		Columns: columns,
+	}, nil
+}
+
+// ListHistory lists the changes of a session, newest first
+func (s *WorkflowSessionService) ListHistory(ctx context.Context, userID, sessionID uuid.UUID) ([]*models.WorkflowSessionHistoryEntry, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleViewer, anyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	return s.sessionRepo.ListHistory(ctx, session.ID)
+}
+
+// RestoreVersion undoes a session back to the table selection, mapping and
+// step of an earlier version. The schema and data file stay as they are, so
+// the table must still be in the schema. The restore is recorded as a new
+// version; history is never rewritten.
+func (s *WorkflowSessionService) RestoreVersion(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, req *models.RestoreWorkflowSessionRequest) (*models.WorkflowSessionResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+
+	entry, err := s.sessionRepo.GetHistoryEntry(ctx, session.ID, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %d", ErrHistoryVersionNotFound, req.Version)
+	}
+
+	session.CurrentStep = entry.CurrentStep
+	session.SelectedTableName = entry.SelectedTableName
+	session.ColumnMapping = entry.ColumnMapping
+	session.FieldTransformations = entry.FieldTransformations
+	if session.SelectedTableName != nil && selectedTable(session) == nil {
+		return nil, fmt.Errorf("%w: table %q of version %d is not in the current schema", ErrInvalidMapping, *session.SelectedTableName, req.Version)
+	}
+
+	err = s.updateSession(ctx, session, models.SessionRestored)
+	if err != nil {
+		return nil, err
 	}
 
 	return session.ToResponse(), nil
 }
 
-// DeleteSession deletes a workflow session
-func (s *WorkflowSessionService) DeleteSession(ctx context.Context, userID uuid.UUID) error {
-	return s.sessionRepo.Delete(ctx, userID)
+// GenerateSQL generates INSERT SQL for every stored row of the session's
+// data file into its selected table, applying the mapping and
+// transformations of step 4. The SQL is recorded as an import like a
+// generate job's, with the rows failing validation left out and reported.
+func (s *WorkflowSessionService) GenerateSQL(ctx context.Context, userID, sessionID uuid.UUID, req *models.GenerateSessionSQLRequest) (*models.ImportResponse, error) {
+	session, _, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, anyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("no active session found")
+	}
+	if session.DataStorageKey == nil {
+		return nil, ErrNoDataset
+	}
+	table := selectedTable(session)
+	if table == nil || len(session.ColumnMapping) == 0 {
+		return nil, ErrSessionIncomplete
+	}
+
+	transforms := make(map[string]string, len(session.FieldTransformations))
+	var applied []string
+	for field, name := range session.FieldTransformations {
+		if !transform.Supported(name) {
+			return nil, fmt.Errorf("%w: unknown transformation %q for field %q", ErrInvalidMapping, name, field)
+		}
+		if name != transform.None {
+			transforms[field] = name
+			applied = append(applied, field+": "+name)
+		}
+	}
+	sort.Strings(applied)
+
+	reader, closeDataset, err := s.openDataset(ctx, *session.DataStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDataset()
+	headers := reader.Headers()
+
+	keyColumns := make(map[string]bool, len(req.KeyColumns))
+	for _, name := range req.KeyColumns {
+		keyColumns[name] = true
+	}
+
+	// The mapped fields in table order, with the column each is read from
+	columnOf := make(map[string]int, len(headers))
+	for i := len(headers) - 1; i >= 0; i-- {
+		columnOf[headers[i]] = i
+	}
+	var tableFields, fields []models.ImportField
+	var columns []int
+	for _, f := range table.Fields {
+		field := models.ImportField{Name: f.Name, Type: f.Type, Nullable: f.Nullable, Key: f.PrimaryKey || f.Unique}
+		if len(keyColumns) > 0 {
+			field.Key = keyColumns[f.Name]
+		}
+		tableFields = append(tableFields, field)
+
+		for header, mapped := range session.ColumnMapping {
+			column, ok := columnOf[header]
+			if mapped == f.Name && ok {
+				fields = append(fields, field)
+				columns = append(columns, column)
+				break
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, ErrNothingToExecute
+	}
+	if req.Upsert {
+		hasKey := false
+		for _, f := range fields {
+			hasKey = hasKey || f.Key
+		}
+		if !hasKey {
+			return nil, fmt.Errorf("%w: upserts need a mapped key column", ErrInvalidMapping)
+		}
+	}
+
+	var rows [][]interface{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data file: %w", err)
+		}
+
+		row := make([]interface{}, len(fields))
+		for i, column := range columns {
+			if column < len(record) {
+				row[i] = record[column]
+			}
+			if name, ok := transforms[fields[i].Name]; ok {
+				// Names were checked above
+				row[i], _ = transform.Apply(name, row[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the data file has no rows", ErrNoDataset)
+	}
+
+	var dialect string
+	if session.Dialect != nil {
+		dialect = *session.Dialect
+	}
+	var fileName string
+	if session.DataFileName != nil {
+		fileName = *session.DataFileName
+	}
+	options := &models.ImportOptions{KeyColumns: req.KeyColumns, Upsert: req.Upsert}
+	metadata := models.ImportMetadata{
+		SourceFileName:  fileName,
+		SourceHeaders:   headers,
+		MappingSummary:  session.ColumnMapping,
+		Transformations: applied,
+		DatabaseType:    dialect,
+		Fields:          tableFields,
+		Options:         options,
+		Extra:           map[string]interface{}{"workflowSessionId": session.ID.String()},
+	}
+
+	return s.imports.GenerateFromRows(ctx, userID, table.Name, session.ColumnMapping, rows, fields, options, metadata)
+}
+
+// DeleteSession deletes a workflow session with its stored schema content
+// and data file
+func (s *WorkflowSessionService) DeleteSession(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int) error {
+	session, ws, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	if session == nil {
+		return fmt.Errorf("workflow session not found")
+	}
+
+	storageKeys, deleted, err := s.sessionRepo.Delete(ctx, session.ID, ws, session.Version)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSessionConflict
+	}
+
+	deleteBlobs(ctx, s.blobs, storageKeys...)
+	return nil
 }
 
-// CleanupExpiredSessions deletes all expired workflow sessions
+// CleanupExpiredSessions deletes all expired workflow sessions with their
+// stored schema content and data files
 func (s *WorkflowSessionService) CleanupExpiredSessions(ctx context.Context) (int64, error) {
-	return s.sessionRepo.DeleteExpired(ctx)
+	deleted, storageKeys, err := s.sessionRepo.DeleteExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleteBlobs(ctx, s.blobs, storageKeys...)
+	return deleted, nil
+}
+
+// MigrateLegacySchemas moves up to limit sessions whose schema content is
+// still compressed in the database to object storage and returns how many
+// were moved
+func (s *WorkflowSessionService) MigrateLegacySchemas(ctx context.Context, limit int) (int, error) {
+	if s.blobs == nil {
+		return 0, nil
+	}
+
+	sessions, err := s.sessionRepo.ListLegacySchemas(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, session := range sessions {
+		content, err := decompressContent(*session.SchemaContent)
+		if err != nil {
+			logger.Warn("Skipping workflow session with unreadable schema content", map[string]interface{}{
+				"sessionId": session.ID.String(),
+				"error":     err.Error(),
+			})
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s.sql", schemaPrefix(session.UserID), uuid.New())
+		if _, _, err := storeBlob(ctx, s.blobs, key, content); err != nil {
+			return moved, err
+		}
+
+		updated, err := s.sessionRepo.SetSchemaObject(ctx, session.ID, key)
+		if err != nil || !updated {
+			// Failed, or the session was replaced while being moved
+			deleteBlobs(ctx, s.blobs, key)
+			if err != nil {
+				return moved, err
+			}
+			continue
+		}
+		moved++
+	}
+
+	return moved, nil
 }
 
 // ExtendExpiration extends the expiration time of a session
-func (s *WorkflowSessionService) ExtendExpiration(ctx context.Context, userID uuid.UUID, days int) error {
+func (s *WorkflowSessionService) ExtendExpiration(ctx context.Context, userID, sessionID uuid.UUID, expectedVersion int, days int) error {
 	if days <= 0 || days > 30 {
 		return fmt.Errorf("days must be between 1 and 30")
 	}
 
-	return s.sessionRepo.ExtendExpiration(ctx, userID, days)
+	session, ws, err := s.getSession(ctx, userID, sessionID, models.RoleEditor, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	if session == nil {
+		return fmt.Errorf("workflow session not found or expired")
+	}
+
+	extended, err := s.sessionRepo.ExtendExpiration(ctx, session.ID, ws, session.Version, days)
+	if err != nil {
+		return err
+	}
+	if !extended {
+		return ErrSessionConflict
+	}
+	return nil
 }
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// APITokenPrefix starts every personal access token, telling them apart
+// from JWTs and making leaked tokens easy to spot
+const APITokenPrefix = "dbi_pat_"
+
+// apiTokenDisplayLength is how much of a token is kept to identify it
+const apiTokenDisplayLength = len(APITokenPrefix) + 6
+
+// InvitationTokenPrefix starts every organization invitation token
+const InvitationTokenPrefix = "dbi_inv_"
+
+// generateToken returns prefix followed by 32 random bytes
+func generateToken(prefix string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// GenerateAPIToken returns a new random personal access token
+func GenerateAPIToken() (string, error) {
+	return generateToken(APITokenPrefix)
+}
+
+// GenerateInvitationToken returns a new random organization invitation
+// token; store it hashed with HashRefreshToken
+func GenerateInvitationToken() (string, error) {
+	return generateToken(InvitationTokenPrefix)
+}
+
+// IsAPIToken reports whether a bearer token is a personal access token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// APITokenDisplayPrefix returns the start of a token, which is stored in the
+// clear so users can recognise their tokens
+func APITokenDisplayPrefix(token string) string {
+	if len(token) <= apiTokenDisplayLength {
+		return token
+	}
+	return token[:apiTokenDisplayLength]
+}
+
+// HashAPIToken hashes a personal access token for storage, like
+// HashRefreshToken
+func HashAPIToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix versions the ciphertext format so the key derivation or
+// algorithm can change later without breaking stored values
+const encryptedPrefix = "v1:"
+
+var (
+	ErrEncryptionKeyMissing = errors.New("credential encryption key is not configured")
+	ErrInvalidCiphertext    = errors.New("invalid encrypted value")
+)
+
+// SecretCipher encrypts secrets at rest (e.g. database credentials) with
+// AES-256-GCM. The key is derived from a configured secret of any length.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher creates a cipher from the configured secret
+func NewSecretCipher(secret string) (*SecretCipher, error) {
+	if secret == "" {
+		return nil, ErrEncryptionKeyMissing
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Encrypt returns "v1:" followed by base64(nonce || ciphertext)
+func (c *SecretCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt; it fails if the value was tampered with or
+// encrypted with a different key
+func (c *SecretCipher) Decrypt(encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, encryptedPrefix) {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -46,3 +50,87 @@ func SetCookie(w http.ResponseWriter, r *http.Request, name, value string, maxAg
 func ClearCookie(w http.ResponseWriter, r *http.Request, name string) {
 	SetCookie(w, r, name, "", -1)
 }
+
+// TrustedProxies are the reverse proxies in front of the server, whose
+// forwarding headers are believed
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and
+// CIDR ranges such as "10.0.0.0/8, 127.0.0.1"
+func ParseTrustedProxies(spec string) (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", entry)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// trusts reports whether ip belongs to a trusted proxy
+func (p TrustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client of a request. Requests
+// from a trusted proxy are attributed to the last address in
+// X-Forwarded-For that is not a trusted proxy itself, or to X-Real-IP.
+// Anyone else could send these headers, so otherwise it is the remote
+// address without its port.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !p.trusts(remote) {
+		return remote
+	}
+
+	// Each proxy appends the address it received the request from
+	if forwarded := strings.Join(r.Header.Values("X-Forwarded-For"), ","); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			client = strings.TrimSpace(hops[i])
+			if client != "" && !p.trusts(client) {
+				break
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return remote
+}
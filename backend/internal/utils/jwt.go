@@ -15,7 +15,8 @@ import (
 type JWTClaims struct {
 	UserID string `json:"sub"`
 	Email  string `json:"email"`
-	Type   string `json:"type"` // "access" or "refresh"
+	Type   string `json:"type"`          // "access" or "refresh"
+	MFA    bool   `json:"mfa,omitempty"` // the session passed two-factor authentication
 	jwt.RegisteredClaims
 }
 
@@ -34,12 +35,14 @@ var (
 	ErrInvalidClaims = errors.New("invalid token claims")
 )
 
-// GenerateAccessToken creates a new access token for a user
-func GenerateAccessToken(userID uuid.UUID, email string, cfg JWTConfig) (string, error) {
+// GenerateAccessToken creates a new access token for a user, marked with
+// mfa when the session passed two-factor authentication
+func GenerateAccessToken(userID uuid.UUID, email string, mfa bool, cfg JWTConfig) (string, error) {
 	claims := &JWTClaims{
 		UserID: userID.String(),
 		Email:  email,
 		Type:   "access",
+		MFA:    mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -51,13 +54,15 @@ func GenerateAccessToken(userID uuid.UUID, email string, cfg JWTConfig) (string,
 	return token.SignedString(cfg.AccessSecret)
 }
 
-// GenerateRefreshToken creates a new refresh token for a user
-func GenerateRefreshToken(userID uuid.UUID, cfg JWTConfig) (string, uuid.UUID, error) {
+// GenerateRefreshToken creates a new refresh token for a user. mfa carries
+// over to the access tokens it is exchanged for.
+func GenerateRefreshToken(userID uuid.UUID, mfa bool, cfg JWTConfig) (string, uuid.UUID, error) {
 	jti := uuid.New()
 
 	claims := &JWTClaims{
 		UserID: userID.String(),
 		Type:   "refresh",
+		MFA:    mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        jti.String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.RefreshExpiry)),
@@ -20,6 +20,8 @@ const (
 	ErrTokenExpired       ErrorCode = "TOKEN_EXPIRED"
 	ErrTokenInvalid       ErrorCode = "TOKEN_INVALID"
 	ErrValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	ErrTooManyRequests    ErrorCode = "TOO_MANY_REQUESTS"
+	ErrMFARequired        ErrorCode = "MFA_REQUIRED"
 )
 
 // ErrorResponse represents a standardized error response
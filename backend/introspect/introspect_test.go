@@ -0,0 +1,158 @@
+package introspect
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"db-importer/parser"
+)
+
+func TestIntrospect_SQLite(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "shop.db")
+
+	db, err := Open(parser.DialectSQLite, dsn)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	statements := []string{
+		`CREATE TABLE customers (
+			id INTEGER PRIMARY KEY,
+			email TEXT NOT NULL UNIQUE,
+			name TEXT,
+			status TEXT NOT NULL DEFAULT 'active'
+		)`,
+		`CREATE TABLE orders (
+			id INTEGER PRIMARY KEY,
+			customer_id INTEGER NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+			total NUMERIC(10,2) NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to create fixture: %v", err)
+		}
+	}
+
+	tables, err := Introspect(context.Background(), parser.DialectSQLite, dsn, Options{})
+	if err != nil {
+		t.Fatalf("Introspect failed: %v", err)
+	}
+	if len(tables) != 2 || tables[0].Name != "customers" || tables[1].Name != "orders" {
+		t.Fatalf("Expected customers and orders, got %+v", tables)
+	}
+
+	customers := tables[0]
+	if id := customers.Fields[0]; !id.PrimaryKey || !id.AutoIncrement || id.Nullable {
+		t.Errorf("Expected rowid primary key, got %+v", id)
+	}
+	if email := customers.Fields[1]; !email.Unique || email.Nullable || email.Type != "TEXT" {
+		t.Errorf("Expected unique non-null email, got %+v", email)
+	}
+	if name := customers.Fields[2]; !name.Nullable {
+		t.Errorf("Expected nullable name, got %+v", name)
+	}
+	if status := customers.Fields[3]; status.Default == nil || *status.Default != "'active'" {
+		t.Errorf("Expected default 'active', got %+v", status.Default)
+	}
+
+	orders := tables[1]
+	if total := orders.Fields[2]; total.Type != "NUMERIC(10,2)" {
+		t.Errorf("Expected declared type to be kept, got %q", total.Type)
+	}
+	if len(orders.ForeignKeys) != 1 {
+		t.Fatalf("Expected one foreign key, got %+v", orders.ForeignKeys)
+	}
+	fk := orders.ForeignKeys[0]
+	if fk.RefTable != "customers" || fk.Columns[0] != "customer_id" || fk.RefColumns[0] != "id" || fk.OnDelete != "CASCADE" {
+		t.Errorf("Unexpected foreign key: %+v", fk)
+	}
+}
+
+func TestIntrospect_UnsupportedDialect(t *testing.T) {
+	if _, err := Introspect(context.Background(), parser.DialectSQLServer, "sqlserver://localhost", Options{}); err == nil {
+		t.Error("Expected an error for sqlserver")
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected string
+	}{
+		{"postgres://app:s3cret@db:5432/shop?sslmode=disable", "postgres://app:xxxxx@db:5432/shop?sslmode=disable"},
+		{"host=db user=app password=s3cret dbname=shop", "host=db user=app password=xxxxx dbname=shop"},
+		{"app:s3cret@tcp(db:3306)/shop", "app:xxxxx@tcp(db:3306)/shop"},
+		{"/var/data/shop.db", "/var/data/shop.db"},
+	}
+
+	for _, tt := range tests {
+		if got := RedactDSN(tt.dsn); got != tt.expected {
+			t.Errorf("RedactDSN(%q) = %q, expected %q", tt.dsn, got, tt.expected)
+		}
+	}
+}
+
+func TestMySQLDSN_AcceptsURLs(t *testing.T) {
+	if got, err := mysqlDSN("mysql://app:pw@db/shop?parseTime=true"); err != nil || got != "app:pw@tcp(db:3306)/shop?parseTime=true" {
+		t.Errorf("Unexpected DSN: %q, %v", got, err)
+	}
+	if got, err := mysqlDSN("app:pw@tcp(db:3306)/shop"); err != nil || got != "app:pw@tcp(db:3306)/shop" {
+		t.Errorf("Native DSN should be unchanged, got %q, %v", got, err)
+	}
+}
+
+func TestSafeDSN_RefusesFileParameters(t *testing.T) {
+	tests := []struct {
+		dialect parser.Dialect
+		dsn     string
+	}{
+		{parser.DialectMySQL, "app:pw@tcp(db:3306)/shop?allowAllFiles=true"},
+		{parser.DialectMySQL, "mysql://app:pw@db/shop?allowAllFiles=true"},
+		{parser.DialectMySQL, "app:pw@tcp(db:3306)/shop?allowCleartextPasswords=true"},
+		{parser.DialectMySQL, "app:pw@tcp(db:3306)/shop?local_infile=1"},
+		{parser.DialectPostgres, "postgres://app:pw@db/shop?sslkey=/app/.env"},
+		{parser.DialectPostgres, "postgresql://app:pw@db/shop?passfile=/app/.env"},
+		{parser.DialectPostgres, "host=db user=app sslrootcert=/app/.env"},
+		{parser.DialectPostgres, "host=db password='a\\'b' sslcert = /app/.env"},
+	}
+	for _, tt := range tests {
+		if _, err := SafeDSN(tt.dialect, tt.dsn); !errors.Is(err, ErrUnsafeDSN) {
+			t.Errorf("SafeDSN(%s, %q) = %v, expected ErrUnsafeDSN", tt.dialect, tt.dsn, err)
+		}
+	}
+
+	allowed := []struct {
+		dialect parser.Dialect
+		dsn     string
+	}{
+		{parser.DialectMySQL, "app:pw@tcp(db:3306)/shop?parseTime=true&tls=true"},
+		{parser.DialectPostgres, "postgres://app:pw@db/shop?sslmode=require"},
+		{parser.DialectPostgres, "host=db user=app password='sslkey=x' dbname=shop"},
+	}
+	for _, tt := range allowed {
+		if _, err := SafeDSN(tt.dialect, tt.dsn); err != nil {
+			t.Errorf("SafeDSN(%s, %q) failed: %v", tt.dialect, tt.dsn, err)
+		}
+	}
+}
+
+func TestMySQLDefault(t *testing.T) {
+	tests := []struct {
+		value, columnType, extra, expected string
+	}{
+		{"active", "varchar(20)", "", "'active'"},
+		{"'active'", "varchar(20)", "", "'active'"},
+		{"0", "int", "", "0"},
+		{"CURRENT_TIMESTAMP", "timestamp", "DEFAULT_GENERATED", "CURRENT_TIMESTAMP"},
+	}
+	for _, tt := range tests {
+		if got := mysqlDefault(tt.value, tt.columnType, tt.extra); got != tt.expected {
+			t.Errorf("mysqlDefault(%q, %q) = %q, expected %q", tt.value, tt.columnType, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"db-importer/parser"
+)
+
+const mysqlColumnsQuery = `
+	SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE()
+	ORDER BY TABLE_NAME, ORDINAL_POSITION
+`
+
+// mysqlKeysQuery returns one row per key column; foreign key rules come from
+// REFERENTIAL_CONSTRAINTS and are empty for primary and unique keys
+const mysqlKeysQuery = `
+	SELECT tc.CONSTRAINT_NAME,
+	       tc.CONSTRAINT_TYPE,
+	       k.TABLE_NAME,
+	       k.COLUMN_NAME,
+	       COALESCE(k.REFERENCED_TABLE_NAME, ''),
+	       COALESCE(k.REFERENCED_COLUMN_NAME, ''),
+	       COALESCE(rc.DELETE_RULE, ''),
+	       COALESCE(rc.UPDATE_RULE, '')
+	FROM information_schema.TABLE_CONSTRAINTS tc
+	JOIN information_schema.KEY_COLUMN_USAGE k
+	  ON k.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+	 AND k.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+	 AND k.TABLE_NAME = tc.TABLE_NAME
+	LEFT JOIN information_schema.REFERENTIAL_CONSTRAINTS rc
+	  ON rc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+	 AND rc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+	 AND rc.TABLE_NAME = tc.TABLE_NAME
+	WHERE tc.TABLE_SCHEMA = DATABASE()
+	  AND tc.CONSTRAINT_TYPE IN ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')
+	ORDER BY k.TABLE_NAME, tc.CONSTRAINT_NAME, k.ORDINAL_POSITION
+`
+
+var mysqlExpressionDefault = regexp.MustCompile(`(?i)^(CURRENT_TIMESTAMP|NOW|CURRENT_DATE|CURRENT_TIME|LOCALTIME|LOCALTIMESTAMP|UUID)\b`)
+
+func mysqlTables(ctx context.Context, db *sql.DB) ([]parser.Table, error) {
+	set := newTableSet()
+
+	rows, err := db.QueryContext(ctx, mysqlColumnsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column, columnType, isNullable, extra string
+		var def sql.NullString
+		if err := rows.Scan(&table, &column, &columnType, &isNullable, &def, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+
+		field := parser.Field{
+			Name:          column,
+			Type:          columnType,
+			Nullable:      isNullable == "YES",
+			AutoIncrement: strings.Contains(strings.ToLower(extra), "auto_increment"),
+		}
+		if def.Valid && !(field.Nullable && strings.EqualFold(def.String, "NULL")) {
+			value := mysqlDefault(def.String, columnType, extra)
+			field.Default = &value
+		}
+
+		t := set.table(table)
+		t.Fields = append(t.Fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	keys, err := db.QueryContext(ctx, mysqlKeysQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+	defer keys.Close()
+
+	type key struct {
+		name, kind, table  string
+		columns, refColumn []string
+		refTable           string
+		onDelete, onUpdate string
+	}
+	var order []string
+	grouped := make(map[string]*key)
+
+	for keys.Next() {
+		var name, kind, table, column, refTable, refColumn, onDelete, onUpdate string
+		if err := keys.Scan(&name, &kind, &table, &column, &refTable, &refColumn, &onDelete, &onUpdate); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+
+		id := table + "\x00" + name
+		k, ok := grouped[id]
+		if !ok {
+			k = &key{name: name, kind: kind, table: table, refTable: refTable, onDelete: onDelete, onUpdate: onUpdate}
+			grouped[id] = k
+			order = append(order, id)
+		}
+		k.columns = append(k.columns, column)
+		if refColumn != "" {
+			k.refColumn = append(k.refColumn, refColumn)
+		}
+	}
+	if err := keys.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	for _, id := range order {
+		k := grouped[id]
+		t := set.lookup(k.table)
+		if t == nil {
+			continue
+		}
+
+		switch k.kind {
+		case "PRIMARY KEY":
+			markColumns(t, k.columns, func(f *parser.Field) { f.PrimaryKey = true })
+		case "UNIQUE":
+			if len(k.columns) == 1 {
+				markColumns(t, k.columns, func(f *parser.Field) { f.Unique = true })
+			}
+		case "FOREIGN KEY":
+			t.ForeignKeys = append(t.ForeignKeys, parser.ForeignKey{
+				Name:       k.name,
+				Columns:    k.columns,
+				RefTable:   k.refTable,
+				RefColumns: k.refColumn,
+				OnDelete:   mysqlReferentialAction(k.onDelete),
+				OnUpdate:   mysqlReferentialAction(k.onUpdate),
+			})
+		}
+	}
+
+	return set.tables, nil
+}
+
+// mysqlDefault turns COLUMN_DEFAULT into a SQL expression. MySQL 8 reports
+// string defaults unquoted and marks expressions with DEFAULT_GENERATED;
+// MariaDB already returns literals quoted.
+func mysqlDefault(value, columnType, extra string) string {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		return value
+	case strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED"),
+		mysqlExpressionDefault.MatchString(value):
+		return value
+	case isNumericColumn(columnType):
+		return value
+	}
+	return parser.QuoteLiteral(value)
+}
+
+// isNumericColumn reports whether a MySQL column type stores numbers
+func isNumericColumn(columnType string) bool {
+	t := strings.ToLower(columnType)
+	for _, prefix := range []string{"tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "numeric", "float", "double", "bit"} {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlReferentialAction drops the default rule so only meaningful actions
+// are reported; InnoDB treats NO ACTION and RESTRICT the same way
+func mysqlReferentialAction(rule string) string {
+	if rule == "NO ACTION" || rule == "RESTRICT" {
+		return ""
+	}
+	return rule
+}
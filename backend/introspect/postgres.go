@@ -0,0 +1,156 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"db-importer/parser"
+)
+
+// postgresColumnsQuery reads columns from pg_catalog so types keep their
+// modifiers (e.g. character varying(255), numeric(10,2))
+const postgresColumnsQuery = `
+	SELECT c.relname,
+	       a.attname,
+	       format_type(a.atttypid, a.atttypmod),
+	       NOT a.attnotnull,
+	       pg_get_expr(d.adbin, d.adrelid),
+	       a.attidentity <> ''
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	WHERE n.nspname = $1
+	  AND c.relkind IN ('r', 'p')
+	  AND a.attnum > 0
+	  AND NOT a.attisdropped
+	ORDER BY c.relname, a.attnum
+`
+
+// postgresConstraintsQuery reads primary key, unique and foreign key
+// constraints; column lists are returned as JSON arrays in key order
+const postgresConstraintsQuery = `
+	SELECT con.conname,
+	       con.contype,
+	       c.relname,
+	       array_to_json(ARRAY(
+	           SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY AS k(num, ord)
+	           JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.num
+	           ORDER BY k.ord))::text,
+	       COALESCE(fc.relname, ''),
+	       COALESCE(array_to_json(ARRAY(
+	           SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY AS k(num, ord)
+	           JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.num
+	           ORDER BY k.ord))::text, '[]'),
+	       con.confdeltype,
+	       con.confupdtype
+	FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_class fc ON fc.oid = con.confrelid
+	WHERE n.nspname = $1
+	  AND con.contype IN ('p', 'u', 'f')
+	ORDER BY c.relname, con.conname
+`
+
+func postgresTables(ctx context.Context, db *sql.DB, schema string) ([]parser.Table, error) {
+	set := newTableSet()
+
+	rows, err := db.QueryContext(ctx, postgresColumnsQuery, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column, columnType string
+		var nullable, identity bool
+		var def sql.NullString
+		if err := rows.Scan(&table, &column, &columnType, &nullable, &def, &identity); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+
+		field := parser.Field{Name: column, Type: columnType, Nullable: nullable, AutoIncrement: identity}
+		if def.Valid {
+			if strings.HasPrefix(def.String, "nextval(") {
+				field.AutoIncrement = true // serial columns
+			} else {
+				value := def.String
+				field.Default = &value
+			}
+		}
+
+		t := set.table(table)
+		t.Fields = append(t.Fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	constraints, err := db.QueryContext(ctx, postgresConstraintsQuery, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+	defer constraints.Close()
+
+	for constraints.Next() {
+		var name, kind, table, columnsJSON, refTable, refColumnsJSON, onDelete, onUpdate string
+		if err := constraints.Scan(&name, &kind, &table, &columnsJSON, &refTable, &refColumnsJSON, &onDelete, &onUpdate); err != nil {
+			return nil, fmt.Errorf("failed to scan constraint: %w", err)
+		}
+
+		t := set.lookup(table)
+		if t == nil {
+			continue
+		}
+
+		var columns, refColumns []string
+		if err := json.Unmarshal([]byte(columnsJSON), &columns); err != nil {
+			return nil, fmt.Errorf("failed to decode columns of %s: %w", name, err)
+		}
+		if err := json.Unmarshal([]byte(refColumnsJSON), &refColumns); err != nil {
+			return nil, fmt.Errorf("failed to decode referenced columns of %s: %w", name, err)
+		}
+
+		switch kind {
+		case "p":
+			markColumns(t, columns, func(f *parser.Field) { f.PrimaryKey = true })
+		case "u":
+			if len(columns) == 1 {
+				markColumns(t, columns, func(f *parser.Field) { f.Unique = true })
+			}
+		case "f":
+			t.ForeignKeys = append(t.ForeignKeys, parser.ForeignKey{
+				Name:       name,
+				Columns:    columns,
+				RefTable:   refTable,
+				RefColumns: refColumns,
+				OnDelete:   postgresReferentialAction(onDelete),
+				OnUpdate:   postgresReferentialAction(onUpdate),
+			})
+		}
+	}
+	if err := constraints.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+
+	return set.tables, nil
+}
+
+// postgresReferentialAction maps pg_constraint action codes to SQL
+func postgresReferentialAction(code string) string {
+	switch code {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return "" // "a" is NO ACTION, the default
+}
@@ -0,0 +1,191 @@
+package introspect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"db-importer/parser"
+)
+
+const sqliteTablesQuery = `
+	SELECT name FROM sqlite_master
+	WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+	ORDER BY name
+`
+
+func sqliteTables(ctx context.Context, db *sql.DB) ([]parser.Table, error) {
+	rows, err := db.QueryContext(ctx, sqliteTablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	tables := make([]parser.Table, 0, len(names))
+	for _, name := range names {
+		table, err := sqliteTable(ctx, db, name)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", name, err)
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+func sqliteTable(ctx context.Context, db *sql.DB, name string) (parser.Table, error) {
+	table := parser.Table{Name: name}
+
+	columns, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, name)
+	if err != nil {
+		return table, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer columns.Close()
+
+	pkColumns := 0
+	for columns.Next() {
+		var column, columnType string
+		var notNull bool
+		var def sql.NullString
+		var pk int
+		if err := columns.Scan(&column, &columnType, &notNull, &def, &pk); err != nil {
+			return table, fmt.Errorf("failed to scan column: %w", err)
+		}
+
+		field := parser.Field{
+			Name:       column,
+			Type:       columnType,
+			Nullable:   !notNull && pk == 0,
+			PrimaryKey: pk > 0,
+		}
+		if def.Valid {
+			value := def.String
+			field.Default = &value
+		}
+		if pk > 0 {
+			pkColumns++
+		}
+		table.Fields = append(table.Fields, field)
+	}
+	if err := columns.Err(); err != nil {
+		return table, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	// A single INTEGER PRIMARY KEY aliases the rowid and is assigned automatically
+	if pkColumns == 1 {
+		for i := range table.Fields {
+			if table.Fields[i].PrimaryKey && strings.EqualFold(table.Fields[i].Type, "INTEGER") {
+				table.Fields[i].AutoIncrement = true
+			}
+		}
+	}
+
+	if err := sqliteUniqueColumns(ctx, db, &table); err != nil {
+		return table, err
+	}
+	if err := sqliteForeignKeys(ctx, db, &table); err != nil {
+		return table, err
+	}
+
+	return table, nil
+}
+
+// sqliteUniqueColumns marks columns covered by a single-column UNIQUE constraint
+func sqliteUniqueColumns(ctx context.Context, db *sql.DB, table *parser.Table) error {
+	indexes, err := db.QueryContext(ctx, `SELECT name FROM pragma_index_list(?) WHERE "unique" = 1 AND origin = 'u'`, table.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read indexes: %w", err)
+	}
+	var names []string
+	for indexes.Next() {
+		var name string
+		if err := indexes.Scan(&name); err != nil {
+			indexes.Close()
+			return fmt.Errorf("failed to scan index: %w", err)
+		}
+		names = append(names, name)
+	}
+	indexes.Close()
+	if err := indexes.Err(); err != nil {
+		return fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	for _, index := range names {
+		var columns []string
+		rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_index_info(?) ORDER BY seqno`, index)
+		if err != nil {
+			return fmt.Errorf("failed to read index %s: %w", index, err)
+		}
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan index column: %w", err)
+			}
+			columns = append(columns, column)
+		}
+		rows.Close()
+
+		if len(columns) == 1 {
+			markColumns(table, columns, func(f *parser.Field) { f.Unique = true })
+		}
+	}
+
+	return nil
+}
+
+// sqliteForeignKeys reads FOREIGN KEY clauses; a missing target column
+// list refers to the primary key of the parent table
+func sqliteForeignKeys(ctx context.Context, db *sql.DB, table *parser.Table) error {
+	rows, err := db.QueryContext(ctx, `SELECT id, "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var refTable, column, onUpdate, onDelete string
+		var refColumn sql.NullString
+		if err := rows.Scan(&id, &refTable, &column, &refColumn, &onUpdate, &onDelete); err != nil {
+			return fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+
+		if id != lastID {
+			table.ForeignKeys = append(table.ForeignKeys, parser.ForeignKey{
+				RefTable: refTable,
+				OnDelete: sqliteReferentialAction(onDelete),
+				OnUpdate: sqliteReferentialAction(onUpdate),
+			})
+			lastID = id
+		}
+		fk := &table.ForeignKeys[len(table.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, column)
+		if refColumn.Valid && refColumn.String != "" {
+			fk.RefColumns = append(fk.RefColumns, refColumn.String)
+		}
+	}
+
+	return rows.Err()
+}
+
+func sqliteReferentialAction(action string) string {
+	if action == "NO ACTION" {
+		return ""
+	}
+	return action
+}
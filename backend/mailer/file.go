@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"db-importer/logger"
+)
+
+// File keeps messages instead of delivering them, for development and
+// tests: each message is written to a .eml file in a directory, or only
+// logged with its text when no directory is given
+type File struct {
+	dir  string
+	from string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewFile creates a sender writing messages from the given address into
+// dir, creating it if needed; an empty dir only logs them
+func NewFile(dir, from string) (*File, error) {
+	if _, err := addressOf(from); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create mail directory: %w", err)
+		}
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+// Send writes or logs msg
+func (f *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := Format(f.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.sent = append(f.sent, msg)
+	f.mu.Unlock()
+
+	if f.dir == "" {
+		logger.Info("Email not delivered (no mail server configured)", map[string]interface{}{
+			"to":      msg.To,
+			"subject": msg.Subject,
+			"text":    msg.Text,
+		})
+		return nil
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	path := filepath.Join(f.dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix)))
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	logger.Info("Email written to file", map[string]interface{}{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"path":    path,
+	})
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first
+func (f *File) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
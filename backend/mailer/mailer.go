@@ -0,0 +1,75 @@
+// Package mailer sends plain-text emails such as verification and password
+// reset links, independent of how they are delivered (an SMTP server, or
+// files and log lines during development and tests).
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	// Send delivers msg or returns why it could not
+	Send(ctx context.Context, msg Message) error
+}
+
+// Format renders msg as an RFC 5322 message from the given address. It
+// rejects addresses that do not parse and header values spanning lines.
+func Format(from string, msg Message, date time.Time) ([]byte, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+	recipient, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient address %q: %w", msg.To, err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", sender.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", recipient.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	text := strings.ReplaceAll(strings.ReplaceAll(msg.Text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := body.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// addressOf returns the bare address of a possibly named address
+func addressOf(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	return parsed.Address, nil
+}
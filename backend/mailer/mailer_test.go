@@ -0,0 +1,199 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	msg := Message{
+		To:      "jane@example.com",
+		Subject: "Réinitialiser le mot de passe",
+		Text:    "Open this link:\nhttps://example.com/reset-password?token=abc.def\n",
+	}
+	data, err := Format("DB Importer <no-reply@example.com>", msg, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("Formatted message does not parse: %v", err)
+	}
+	if got := parsed.Header.Get("To"); got != "<jane@example.com>" {
+		t.Errorf("Unexpected To header %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject decoded to %q, %v", subject, err)
+	}
+	if got := parsed.Header.Get("Date"); got != "Tue, 02 Jan 2024 03:04:05 +0000" {
+		t.Errorf("Unexpected Date header %q", got)
+	}
+
+	body, _ := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if !strings.Contains(string(body), "https://example.com/reset-password?token=abc.def\r\n") {
+		t.Errorf("Body lost the link: %q", body)
+	}
+}
+
+func TestFormat_RejectsHeaderInjection(t *testing.T) {
+	from := "no-reply@example.com"
+	cases := []Message{
+		{To: "jane@example.com\r\nBcc: eve@example.com", Subject: "Hi"},
+		{To: "jane@example.com", Subject: "Hi\r\nBcc: eve@example.com"},
+		{To: "not an address", Subject: "Hi"},
+	}
+	for _, msg := range cases {
+		if _, err := Format(from, msg, time.Now()); err == nil {
+			t.Errorf("Expected %+v to be rejected", msg)
+		}
+	}
+	if _, err := Format("nobody", Message{To: "jane@example.com"}, time.Now()); err == nil {
+		t.Error("Expected an invalid sender to be rejected")
+	}
+}
+
+func TestFile_WritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender, err := NewFile(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+
+	msg := Message{To: "jane@example.com", Subject: "Verify your email", Text: "Hello"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".eml" {
+		t.Fatalf("Expected one .eml file, got %v, %v", entries, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if !strings.Contains(string(data), "Subject: Verify your email") {
+		t.Errorf("File does not hold the message: %q", data)
+	}
+
+	if sent := sender.Sent(); len(sent) != 1 || sent[0] != msg {
+		t.Errorf("Sent returned %+v", sent)
+	}
+}
+
+func TestFile_LogsWithoutDirectory(t *testing.T) {
+	sender, err := NewFile("", "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	if err := sender.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(sender.Sent()) != 1 {
+		t.Error("Expected the message to be recorded")
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan smtpTranscript, 1)
+	go serveSMTP(listener, received)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	sender, err := NewSMTP(SMTPConfig{Host: host, Port: portNumber, From: "DB Importer <no-reply@example.com>"})
+	if err != nil {
+		t.Fatalf("NewSMTP failed: %v", err)
+	}
+
+	err = sender.Send(context.Background(), Message{To: "Jane <jane@example.com>", Subject: "Hi", Text: "Hello"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got.from != "<no-reply@example.com>" || got.to != "<jane@example.com>" {
+			t.Errorf("Unexpected envelope %q -> %q", got.from, got.to)
+		}
+		if !strings.Contains(got.data, "Subject: Hi") || !strings.Contains(got.data, "Hello") {
+			t.Errorf("Unexpected message %q", got.data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server received nothing")
+	}
+}
+
+func TestNewSMTP_RequiresHostAndSender(t *testing.T) {
+	if _, err := NewSMTP(SMTPConfig{From: "no-reply@example.com"}); err == nil {
+		t.Error("Expected a missing host to be rejected")
+	}
+	if _, err := NewSMTP(SMTPConfig{Host: "localhost", From: "nobody"}); err == nil {
+		t.Error("Expected an invalid sender to be rejected")
+	}
+}
+
+type smtpTranscript struct {
+	from, to, data string
+}
+
+// serveSMTP accepts one connection and speaks just enough SMTP to take a
+// message, without STARTTLS or authentication
+func serveSMTP(listener net.Listener, received chan<- smtpTranscript) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	var got smtpTranscript
+	text.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL":
+			got.from = strings.TrimPrefix(line, "MAIL FROM:")
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			got.to = strings.TrimPrefix(line, "RCPT TO:")
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := io.ReadAll(bufio.NewReader(text.DotReader()))
+			if err != nil {
+				return
+			}
+			got.data = string(data)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			received <- got
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpTimeout bounds a whole delivery when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig configures delivery through an SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     int    // usually 587 (STARTTLS) or 25
+	Username string // empty disables authentication
+	Password string
+	From     string // sender, e.g. "DB Importer <no-reply@example.com>"
+}
+
+// SMTP delivers messages through an SMTP server, upgrading the connection
+// with STARTTLS whenever the server offers it
+type SMTP struct {
+	config SMTPConfig
+	from   string // bare sender address for the envelope
+}
+
+// NewSMTP creates an SMTP sender
+func NewSMTP(config SMTPConfig) (*SMTP, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if config.Port <= 0 {
+		config.Port = 587
+	}
+	from, err := addressOf(config.From)
+	if err != nil {
+		return nil, err
+	}
+	return &SMTP{config: config, from: from}, nil
+}
+
+// Send delivers msg in one SMTP session
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := Format(s.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	to, err := addressOf(msg.To)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		// PlainAuth refuses to send credentials over unencrypted
+		// connections to remote hosts
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("SMTP server refused sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP server refused recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP server refused message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server refused message: %w", err)
+	}
+
+	return client.Quit()
+}
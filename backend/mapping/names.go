@@ -0,0 +1,220 @@
+package mapping
+
+import (
+	"strings"
+	"unicode"
+)
+
+// accents folds accented Latin letters to their base letter
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ł': "l", 'ľ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ß': "ss", 'ť': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// synonyms groups names meaning the same column, written as compact
+// names (tokens joined without separators). The first entry is canonical.
+var synonyms = [][]string{
+	{"email", "mail", "emailaddress", "mailaddress", "courriel"},
+	{"postalcode", "zip", "zipcode", "postcode", "postal", "plz", "codepostal"},
+	{"phone", "phonenumber", "telephone", "tel", "telephonenumber", "mobile", "mobilephone", "cellphone"},
+	{"firstname", "givenname", "forename", "fname", "prenom"},
+	{"lastname", "surname", "familyname", "lname", "nom"},
+	{"name", "fullname", "displayname"},
+	{"address", "streetaddress", "street", "addressline1", "address1", "addr"},
+	{"city", "town", "ville", "locality"},
+	{"country", "nation", "pays"},
+	{"state", "province", "region"},
+	{"birthdate", "dateofbirth", "dob", "birthday"},
+	{"createdat", "created", "createdon", "creationdate", "datecreated"},
+	{"updatedat", "updated", "updatedon", "modified", "modifiedat", "lastmodified"},
+	{"quantity", "qty"},
+	{"price", "unitprice"},
+	{"description", "desc", "details"},
+	{"company", "companyname", "organization", "organisation", "org", "employer"},
+	{"url", "website", "homepage", "link"},
+	{"gender", "sex"},
+	{"id", "identifier"},
+}
+
+// canonical maps every synonym to the canonical compact name of its group
+var canonical = func() map[string]string {
+	m := make(map[string]string)
+	for _, group := range synonyms {
+		for _, name := range group {
+			m[name] = group[0]
+		}
+	}
+	return m
+}()
+
+// tokens splits a column name into lowercase, accent-free words at
+// separators, camelCase humps and letter/digit boundaries:
+// "CustomerE-mail2" -> [customer e mail 2]
+func tokens(name string) []string {
+	var words []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, fold(string(current)))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if len(current) > 0 {
+			prev := runes[i-1]
+			switch {
+			case unicode.IsDigit(r) != unicode.IsDigit(prev):
+				flush()
+			case unicode.IsUpper(r) && unicode.IsLower(prev):
+				flush()
+			// The last capital of an acronym starts the next word: "HTTPServer"
+			case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return words
+}
+
+// fold lowercases a word and strips accents
+func fold(word string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(word) {
+		if base, ok := accents[r]; ok {
+			b.WriteString(base)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// compact joins the tokens of a name without separators
+func compact(name string) string {
+	return strings.Join(tokens(name), "")
+}
+
+// canonicalName returns the canonical compact name of a synonym, or the
+// compact name itself
+func canonicalName(name string) string {
+	c := compact(name)
+	if canon, ok := canonical[c]; ok {
+		return canon
+	}
+	return c
+}
+
+// NameSimilarity scores how alike two column names are, from 0 to 1.
+// Names equal after normalization (case, separators, camelCase, accents)
+// score 1 and synonyms 0.95; otherwise the best of edit distance, shared
+// words and containment counts.
+func NameSimilarity(a, b string) float64 {
+	ca, cb := compact(a), compact(b)
+	if ca == "" || cb == "" {
+		return 0
+	}
+	if ca == cb {
+		return 1
+	}
+	if canonicalName(a) == canonicalName(b) {
+		return 0.95
+	}
+
+	best := 1 - float64(levenshtein(ca, cb))/float64(max(len([]rune(ca)), len([]rune(cb))))
+
+	// Shared words, each reduced to its synonym group
+	wa, wb := wordSet(a), wordSet(b)
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	if union := len(wa) + len(wb) - shared; union > 0 {
+		best = max(best, 0.9*float64(shared)/float64(union))
+	}
+
+	// One name contains the other, e.g. "customer_email" and "email"
+	short, long := ca, cb
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) >= 3 && strings.Contains(long, short) {
+		best = max(best, 0.6+0.3*float64(len(short))/float64(len(long)))
+	}
+
+	return best
+}
+
+// Identifier turns a column name into a lowercase snake_case SQL
+// identifier of ASCII letters, digits and underscores: "Prénom (FR)" ->
+// "prenom_fr". Names without any such character give "", names starting
+// with a digit get a "c_" prefix.
+func Identifier(name string) string {
+	var words []string
+	for _, w := range tokens(name) {
+		var b strings.Builder
+		for _, r := range w {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			words = append(words, b.String())
+		}
+	}
+
+	id := strings.Join(words, "_")
+	if id != "" && unicode.IsDigit(rune(id[0])) {
+		id = "c_" + id
+	}
+	return id
+}
+
+func wordSet(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range tokens(name) {
+		if canon, ok := canonical[w]; ok {
+			w = canon
+		}
+		set[w] = true
+	}
+	return set
+}
+
+// levenshtein returns the edit distance between two strings, by rune
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,72 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"first_name", []string{"first", "name"}},
+		{"firstName", []string{"first", "name"}},
+		{"First Name", []string{"first", "name"}},
+		{"HTTPServer", []string{"http", "server"}},
+		{"address2", []string{"address", "2"}},
+		{"Prénom", []string{"prenom"}},
+		{"E-Mail", []string{"e", "mail"}},
+	}
+
+	for _, tt := range tests {
+		if got := tokens(tt.name); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("tokens(%q) = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"First Name":  "first_name",
+		"customerID":  "customer_id",
+		"Prénom (FR)": "prenom_fr",
+		"2nd line":    "c_2_nd_line",
+		"  ":          "",
+		"名前":          "",
+	}
+
+	for name, expected := range tests {
+		if got := Identifier(name); got != expected {
+			t.Errorf("Identifier(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"first_name", "firstName", 1, 1},
+		{"E-Mail", "email", 1, 1},
+		{"Zip", "postal_code", 0.95, 0.95},
+		{"Prénom", "first_name", 0.95, 0.95},
+		{"Ville", "city", 0.95, 0.95},
+		{"customer_email", "email", 0.6, 0.9},
+		{"customer_id", "order_id", 0.3, 0.7},
+		{"price", "description", 0, 0.4},
+		{"", "name", 0, 0},
+	}
+
+	for _, tt := range tests {
+		got := NameSimilarity(tt.a, tt.b)
+		if got < tt.min || got > tt.max {
+			t.Errorf("NameSimilarity(%q, %q) = %.3f, want %.2f to %.2f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+		if rev := NameSimilarity(tt.b, tt.a); rev != got {
+			t.Errorf("NameSimilarity is not symmetric for %q and %q: %.3f vs %.3f", tt.a, tt.b, got, rev)
+		}
+	}
+}
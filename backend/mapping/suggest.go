@@ -0,0 +1,287 @@
+// Package mapping suggests how the columns of a data file map to the fields
+// of a target table. Candidates are scored on name similarity, on how well
+// the sample values fit the field type, and on uniqueness and nullability.
+// Scoring is deterministic: the same input always gives the same result.
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"db-importer/parser"
+)
+
+// Weights of the three scores in a candidate's overall score
+const (
+	NameWeight  = 0.6
+	TypeWeight  = 0.25
+	ShapeWeight = 0.15
+)
+
+// Defaults for Options
+const (
+	DefaultMinScore      = 0.6
+	DefaultMaxCandidates = 3
+)
+
+// Options tunes Suggest; zero values take the defaults
+type Options struct {
+	// MinScore is the lowest overall score a column is mapped with
+	MinScore float64
+	// MaxCandidates caps the ranked candidates listed per column
+	MaxCandidates int
+}
+
+// Candidate is a field a column may map to
+type Candidate struct {
+	Field      string  `json:"field"`
+	Score      float64 `json:"score"`      // weighted overall score, 0 to 1
+	NameScore  float64 `json:"nameScore"`  // similarity of the names
+	TypeScore  float64 `json:"typeScore"`  // share of sample values the field type accepts
+	ShapeScore float64 `json:"shapeScore"` // fit with NOT NULL and unique constraints
+}
+
+// Suggestion lists the candidates of one data file column, best first.
+// Field is the field the column is mapped to once every field is used at
+// most once, or "" when no candidate reaches the minimum score.
+type Suggestion struct {
+	Header     string      `json:"header"`
+	Column     int         `json:"column"`
+	Field      string      `json:"field,omitempty"`
+	Score      float64     `json:"score,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Suggest scores every column against every field of table and maps each
+// column to at most one field. samples holds data rows in header order.
+func Suggest(headers []string, samples [][]interface{}, table parser.Table, opts Options) []Suggestion {
+	if opts.MinScore <= 0 {
+		opts.MinScore = DefaultMinScore
+	}
+	if opts.MaxCandidates <= 0 {
+		opts.MaxCandidates = DefaultMaxCandidates
+	}
+
+	type pair struct {
+		column, field int
+		score         float64
+	}
+	var pairs []pair
+
+	suggestions := make([]Suggestion, len(headers))
+	for c, header := range headers {
+		values := columnValues(samples, c)
+		candidates := make([]Candidate, len(table.Fields))
+		for f, field := range table.Fields {
+			cand := Candidate{
+				Field:      field.Name,
+				NameScore:  round(NameSimilarity(header, field.Name)),
+				TypeScore:  round(TypeScore(values, field.Type)),
+				ShapeScore: round(ShapeScore(values, field)),
+			}
+			cand.Score = round(NameWeight*cand.NameScore + TypeWeight*cand.TypeScore + ShapeWeight*cand.ShapeScore)
+			candidates[f] = cand
+			if cand.Score >= opts.MinScore {
+				pairs = append(pairs, pair{column: c, field: f, score: cand.Score})
+			}
+		}
+
+		// Best first; ties keep the table's field order
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Score > candidates[j].Score
+		})
+		if len(candidates) > opts.MaxCandidates {
+			candidates = candidates[:opts.MaxCandidates]
+		}
+		suggestions[c] = Suggestion{Header: header, Column: c, Candidates: candidates}
+	}
+
+	// Assign the best pairs first so every field is used once
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].score != pairs[j].score {
+			return pairs[i].score > pairs[j].score
+		}
+		if pairs[i].column != pairs[j].column {
+			return pairs[i].column < pairs[j].column
+		}
+		return pairs[i].field < pairs[j].field
+	})
+	usedField := make(map[int]bool)
+	for _, p := range pairs {
+		s := &suggestions[p.column]
+		if s.Field != "" || usedField[p.field] {
+			continue
+		}
+		usedField[p.field] = true
+		s.Field = table.Fields[p.field].Name
+		s.Score = p.score
+	}
+
+	return suggestions
+}
+
+// Mapping returns the mapped columns of suggestions as header -> field
+func Mapping(suggestions []Suggestion) map[string]string {
+	m := make(map[string]string)
+	for _, s := range suggestions {
+		if s.Field != "" {
+			m[s.Header] = s.Field
+		}
+	}
+	return m
+}
+
+// columnValues returns the sample values of column c, with nil for cells
+// missing from short rows
+func columnValues(samples [][]interface{}, c int) []interface{} {
+	values := make([]interface{}, len(samples))
+	for i, row := range samples {
+		if c < len(row) {
+			values[i] = row[c]
+		}
+	}
+	return values
+}
+
+// isNull reports whether a sample value is empty or NULL
+func isNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	return s == "" || strings.EqualFold(s, "null")
+}
+
+var (
+	integerValue = regexp.MustCompile(`^[+-]?\d+$`)
+	dateValue    = regexp.MustCompile(`^(\d{4}[-/.]\d{1,2}[-/.]\d{1,2}|\d{1,2}[-/.]\d{1,2}[-/.]\d{2,4})([ T]\d{1,2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?)?$`)
+	timeValue    = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2}(\.\d+)?)?$`)
+	lengthParam  = regexp.MustCompile(`\((\d+)`)
+)
+
+// typeKind groups SQL types by the values they accept
+type typeKind int
+
+const (
+	kindText typeKind = iota
+	kindInteger
+	kindDecimal
+	kindBoolean
+	kindDate
+	kindTime
+)
+
+// kindOf classifies an SQL type
+func kindOf(sqlType string) typeKind {
+	t := strings.ToLower(sqlType)
+	switch {
+	case strings.Contains(t, "bool") || t == "bit" || strings.HasPrefix(t, "bit(1)") || strings.HasPrefix(t, "tinyint(1)"):
+		return kindBoolean
+	case strings.Contains(t, "int") || strings.Contains(t, "serial"):
+		return kindInteger
+	case strings.Contains(t, "decimal") || strings.Contains(t, "numeric") || strings.Contains(t, "float") ||
+		strings.Contains(t, "double") || strings.Contains(t, "real") || strings.Contains(t, "money"):
+		return kindDecimal
+	case strings.Contains(t, "date") || strings.Contains(t, "timestamp") || t == "year":
+		return kindDate
+	case strings.HasPrefix(t, "time"):
+		return kindTime
+	}
+	return kindText
+}
+
+// textFit is the type score of text fields for values they accept: any
+// value fits, so text fields never outrank a field of the matching type
+const textFit = 0.7
+
+// TypeScore returns the share of non-null sample values an SQL type
+// accepts, 0.5 when there are none. Text types score at most textFit.
+func TypeScore(values []interface{}, sqlType string) float64 {
+	kind := kindOf(sqlType)
+	maxLen := 0
+	if kind == kindText {
+		if m := lengthParam.FindStringSubmatch(sqlType); m != nil {
+			maxLen, _ = strconv.Atoi(m[1])
+		}
+	}
+
+	total, fit := 0, 0
+	for _, v := range values {
+		if isNull(v) {
+			continue
+		}
+		total++
+		if fits(strings.TrimSpace(fmt.Sprint(v)), kind, maxLen) {
+			fit++
+		}
+	}
+	if total == 0 {
+		return 0.5
+	}
+
+	score := float64(fit) / float64(total)
+	if kind == kindText {
+		score *= textFit
+	}
+	return score
+}
+
+func fits(s string, kind typeKind, maxLen int) bool {
+	switch kind {
+	case kindInteger:
+		return integerValue.MatchString(s)
+	case kindDecimal:
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	case kindBoolean:
+		switch strings.ToLower(s) {
+		case "true", "false", "yes", "no", "y", "n", "t", "f", "0", "1", "on", "off":
+			return true
+		}
+		return false
+	case kindDate:
+		return dateValue.MatchString(s)
+	case kindTime:
+		return timeValue.MatchString(s)
+	}
+	return maxLen == 0 || len([]rune(s)) <= maxLen
+}
+
+// ShapeScore rates how well the sample values suit the field's
+// constraints: NULLs in a NOT NULL field and duplicates in a primary key
+// or unique field lower it in proportion. It is 1 without samples.
+func ShapeScore(values []interface{}, field parser.Field) float64 {
+	if len(values) == 0 {
+		return 1
+	}
+
+	nulls := 0
+	distinct := make(map[string]bool)
+	for _, v := range values {
+		if isNull(v) {
+			nulls++
+			continue
+		}
+		distinct[strings.TrimSpace(fmt.Sprint(v))] = true
+	}
+	present := len(values) - nulls
+
+	score := 1.0
+	// An auto-increment key can be left to the database
+	if !field.Nullable && !field.AutoIncrement && field.Default == nil {
+		score *= float64(present) / float64(len(values))
+	}
+	if (field.PrimaryKey || field.Unique) && present > 0 {
+		score *= float64(len(distinct)) / float64(present)
+	}
+	return score
+}
+
+// round keeps three decimals so scores compare and print stably
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,154 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	"db-importer/parser"
+)
+
+var customers = parser.Table{
+	Name: "customers",
+	Fields: []parser.Field{
+		{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true},
+		{Name: "first_name", Type: "varchar(50)"},
+		{Name: "last_name", Type: "varchar(50)"},
+		{Name: "email", Type: "varchar(255)", Unique: true},
+		{Name: "postal_code", Type: "varchar(10)", Nullable: true},
+		{Name: "birth_date", Type: "date", Nullable: true},
+		{Name: "active", Type: "boolean"},
+	},
+}
+
+func TestSuggest(t *testing.T) {
+	headers := []string{"E-Mail", "FirstName", "Nom", "Zip", "DOB", "Active?", "Notes"}
+	samples := [][]interface{}{
+		{"ann@example.com", "Ann", "Lee", "75001", "1990-04-01", "yes", "vip"},
+		{"bob@example.com", "Bob", "Roy", "", "1985-12-24", "no", ""},
+		{"cy@example.com", "Cy", "Poe", "10115", "", "true", "call back"},
+	}
+
+	suggestions := Suggest(headers, samples, customers, Options{})
+
+	expected := map[string]string{
+		"E-Mail":    "email",
+		"FirstName": "first_name",
+		"Nom":       "last_name",
+		"Zip":       "postal_code",
+		"DOB":       "birth_date",
+		"Active?":   "active",
+	}
+	if got := Mapping(suggestions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Mapping = %v, want %v", got, expected)
+	}
+
+	notes := suggestions[6]
+	if notes.Field != "" {
+		t.Errorf("Expected Notes to stay unmapped, got %q (%.3f)", notes.Field, notes.Score)
+	}
+	for i, s := range suggestions {
+		if s.Header != headers[i] || s.Column != i {
+			t.Errorf("Suggestion %d is for %q (column %d)", i, s.Header, s.Column)
+		}
+		if len(s.Candidates) != DefaultMaxCandidates {
+			t.Errorf("Expected %d candidates for %q, got %d", DefaultMaxCandidates, s.Header, len(s.Candidates))
+		}
+		for j := 1; j < len(s.Candidates); j++ {
+			if s.Candidates[j].Score > s.Candidates[j-1].Score {
+				t.Errorf("Candidates of %q are not ranked: %v", s.Header, s.Candidates)
+			}
+		}
+	}
+}
+
+func TestSuggestPrefersCompatibleType(t *testing.T) {
+	table := parser.Table{
+		Name: "events",
+		Fields: []parser.Field{
+			{Name: "created_text", Type: "text", Nullable: true},
+			{Name: "created_on", Type: "timestamp", Nullable: true},
+		},
+	}
+	samples := [][]interface{}{{"2024-01-02 10:00:00"}, {"2024-01-03T11:30:00Z"}}
+
+	suggestions := Suggest([]string{"created"}, samples, table, Options{})
+	if got := suggestions[0].Field; got != "created_on" {
+		t.Errorf("Expected created -> created_on, got %q (candidates %v)", got, suggestions[0].Candidates)
+	}
+}
+
+func TestSuggestUsesEachFieldOnce(t *testing.T) {
+	headers := []string{"Mail", "Email"}
+	suggestions := Suggest(headers, nil, customers, Options{})
+
+	if suggestions[1].Field != "email" {
+		t.Errorf("Expected the exact match Email -> email, got %q", suggestions[1].Field)
+	}
+	if suggestions[0].Field == "email" {
+		t.Error("Expected email to be mapped only once")
+	}
+	if suggestions[0].Candidates[0].Field != "email" {
+		t.Errorf("Expected email to stay the top candidate of Mail, got %v", suggestions[0].Candidates)
+	}
+}
+
+func TestSuggestIsDeterministic(t *testing.T) {
+	headers := []string{"name", "names", "Name"}
+	table := parser.Table{Fields: []parser.Field{{Name: "name", Type: "text"}, {Name: "nickname", Type: "text"}}}
+
+	first := Suggest(headers, nil, table, Options{MinScore: 0.3})
+	for i := 0; i < 20; i++ {
+		if got := Suggest(headers, nil, table, Options{MinScore: 0.3}); !reflect.DeepEqual(got, first) {
+			t.Fatalf("Suggest changed between runs: %v vs %v", got, first)
+		}
+	}
+	// Equal scores go to the earlier column
+	if first[0].Field != "name" || first[2].Field == "name" {
+		t.Errorf("Expected the first exact match to win, got %v", Mapping(first))
+	}
+}
+
+func TestTypeScore(t *testing.T) {
+	tests := []struct {
+		values   []interface{}
+		sqlType  string
+		expected float64
+	}{
+		{[]interface{}{"1", "2", "x"}, "int", 2.0 / 3},
+		{[]interface{}{"1.5", 2, nil}, "decimal(10,2)", 1},
+		{[]interface{}{"1.5"}, "bigint", 0},
+		{[]interface{}{"Y", "off"}, "boolean", 1},
+		{[]interface{}{"2024-01-02", "02/01/2024"}, "date", 1},
+		{[]interface{}{"12:30"}, "time", 1},
+		{[]interface{}{"abc", "abcdef"}, "varchar(4)", 0.5 * textFit},
+		{[]interface{}{"anything"}, "text", textFit},
+		{[]interface{}{nil, ""}, "int", 0.5},
+	}
+
+	for _, tt := range tests {
+		if got := TypeScore(tt.values, tt.sqlType); got != tt.expected {
+			t.Errorf("TypeScore(%v, %s) = %.3f, want %.3f", tt.values, tt.sqlType, got, tt.expected)
+		}
+	}
+}
+
+func TestShapeScore(t *testing.T) {
+	values := []interface{}{"a", "a", "b", nil}
+
+	tests := []struct {
+		field    parser.Field
+		expected float64
+	}{
+		{parser.Field{Name: "f", Nullable: true}, 1},
+		{parser.Field{Name: "f"}, 0.75},
+		{parser.Field{Name: "f", Nullable: true, Unique: true}, 2.0 / 3},
+		{parser.Field{Name: "f", PrimaryKey: true}, 0.75 * 2 / 3},
+		{parser.Field{Name: "f", AutoIncrement: true}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := ShapeScore(values, tt.field); got != tt.expected {
+			t.Errorf("ShapeScore(%+v) = %.3f, want %.3f", tt.field, got, tt.expected)
+		}
+	}
+}
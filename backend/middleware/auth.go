@@ -5,33 +5,74 @@ import (
 	"net/http"
 	"strings"
 
+	"db-importer/internal/models"
 	"db-importer/internal/utils"
 )
 
-// AuthMiddleware creates a middleware that validates JWT tokens
-func AuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.Handler {
+// APITokenAuthenticator resolves personal access tokens, which are sent as
+// Bearer tokens in place of a JWT
+type APITokenAuthenticator interface {
+	AuthenticateAPIToken(ctx context.Context, token string) (*models.APIToken, error)
+}
+
+// tokenFromRequest reads the Bearer token of a request, falling back to the
+// access_token cookie
+func tokenFromRequest(r *http.Request) string {
+	// Try to get token from Authorization header first
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		// Check if it starts with "Bearer "
+		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			return parts[1]
+		}
+	}
+
+	// If no token in header, try to get from cookie
+	if cookie, err := r.Cookie("access_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// authenticateAPIToken adds the owner and the scopes of a personal access
+// token to the request context
+func authenticateAPIToken(r *http.Request, tokens APITokenAuthenticator, tokenString string) (context.Context, error) {
+	if tokens == nil {
+		return nil, utils.ErrInvalidToken
+	}
+
+	token, err := tokens.AuthenticateAPIToken(r.Context(), tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(r.Context(), "userID", token.UserID.String())
+	ctx = context.WithValue(ctx, "apiTokenID", token.ID.String())
+	ctx = context.WithValue(ctx, "apiTokenScopes", []string(token.Scopes))
+	return ctx, nil
+}
+
+// AuthMiddleware creates a middleware that validates JWT tokens and, when
+// tokens is not nil, personal access tokens
+func AuthMiddleware(jwtConfig utils.JWTConfig, tokens APITokenAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var tokenString string
-
-			// Try to get token from Authorization header first
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" {
-				// Check if it starts with "Bearer "
-				parts := strings.Split(authHeader, " ")
-				if len(parts) == 2 && parts[0] == "Bearer" {
-					tokenString = parts[1]
-				}
+			tokenString := tokenFromRequest(r)
+			if tokenString == "" {
+				utils.Unauthorized(w, "Missing authorization token")
+				return
 			}
 
-			// If no token in header, try to get from cookie
-			if tokenString == "" {
-				cookie, err := r.Cookie("access_token")
+			// Personal access tokens are looked up rather than verified
+			if utils.IsAPIToken(tokenString) {
+				ctx, err := authenticateAPIToken(r, tokens, tokenString)
 				if err != nil {
-					utils.Unauthorized(w, "Missing authorization token")
+					utils.RespondError(w, http.StatusUnauthorized, utils.ErrTokenInvalid, "Invalid or expired API token", nil)
 					return
 				}
-				tokenString = cookie.Value
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
 			}
 
 			// Validate access token
@@ -45,9 +86,10 @@ func AuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Add user ID and email to request context
+			// Add user ID, email and two-factor state to request context
 			ctx := context.WithValue(r.Context(), "userID", claims.UserID)
 			ctx = context.WithValue(ctx, "email", claims.Email)
+			ctx = context.WithValue(ctx, "mfa", claims.MFA)
 
 			// Call next handler with updated context
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -55,29 +97,12 @@ func AuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.Handler {
 	}
 }
 
-// OptionalAuthMiddleware validates JWT tokens if present, but doesn't require them
-func OptionalAuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.Handler {
+// OptionalAuthMiddleware validates JWT tokens and personal access tokens if
+// present, but doesn't require them
+func OptionalAuthMiddleware(jwtConfig utils.JWTConfig, tokens APITokenAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var tokenString string
-
-			// Try to get token from Authorization header first
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" {
-				// Check if it starts with "Bearer "
-				parts := strings.Split(authHeader, " ")
-				if len(parts) == 2 && parts[0] == "Bearer" {
-					tokenString = parts[1]
-				}
-			}
-
-			// If no token in header, try to get from cookie
-			if tokenString == "" {
-				cookie, err := r.Cookie("access_token")
-				if err == nil {
-					tokenString = cookie.Value
-				}
-			}
+			tokenString := tokenFromRequest(r)
 
 			// If no token found, continue without authentication
 			if tokenString == "" {
@@ -85,6 +110,18 @@ func OptionalAuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.H
 				return
 			}
 
+			// Without a database there are no API tokens to check
+			if utils.IsAPIToken(tokenString) && tokens != nil {
+				ctx, err := authenticateAPIToken(r, tokens, tokenString)
+				if err != nil {
+					// A script must not silently fall back to guest limits
+					utils.RespondError(w, http.StatusUnauthorized, utils.ErrTokenInvalid, "Invalid or expired API token", nil)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// Validate access token
 			claims, err := utils.ValidateAccessToken(tokenString, jwtConfig)
 			if err != nil {
@@ -93,12 +130,42 @@ func OptionalAuthMiddleware(jwtConfig utils.JWTConfig) func(http.Handler) http.H
 				return
 			}
 
-			// Add user ID and email to request context
+			// Add user ID, email and two-factor state to request context
 			ctx := context.WithValue(r.Context(), "userID", claims.UserID)
 			ctx = context.WithValue(ctx, "email", claims.Email)
+			ctx = context.WithValue(ctx, "mfa", claims.MFA)
 
 			// Call next handler with updated context
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// RequireScope lets requests made with a personal access token through only
+// when the token was granted scope. An empty scope keeps tokens out
+// entirely. Guests and JWT users are not affected.
+func RequireScope(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			scopes, isAPIToken := r.Context().Value("apiTokenScopes").([]string)
+			if !isAPIToken {
+				next(w, r)
+				return
+			}
+
+			if scope == "" {
+				utils.Forbidden(w, "This endpoint cannot be used with an API token")
+				return
+			}
+			for _, granted := range scopes {
+				if granted == scope {
+					next(w, r)
+					return
+				}
+			}
+			utils.RespondError(w, http.StatusForbidden, utils.ErrForbidden, "API token lacks the "+scope+" scope", map[string]interface{}{
+				"requiredScope": scope,
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"db-importer/internal/models"
+	"db-importer/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// fakeTokens authenticates one personal access token
+type fakeTokens struct {
+	token  string
+	scopes models.TokenScopes
+}
+
+func (f fakeTokens) AuthenticateAPIToken(ctx context.Context, token string) (*models.APIToken, error) {
+	if token != f.token {
+		return nil, utils.ErrInvalidToken
+	}
+	return &models.APIToken{ID: uuid.New(), UserID: uuid.New(), Scopes: f.scopes}, nil
+}
+
+func TestRequireScope(t *testing.T) {
+	jwtConfig := utils.JWTConfig{
+		AccessSecret:  []byte("access-secret"),
+		RefreshSecret: []byte("refresh-secret"),
+		AccessExpiry:  time.Minute,
+		RefreshExpiry: time.Hour,
+	}
+	jwt, err := utils.GenerateAccessToken(uuid.New(), "user@example.com", false, jwtConfig)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken failed: %v", err)
+	}
+	pat := utils.APITokenPrefix + "test"
+	tokens := fakeTokens{token: pat, scopes: models.TokenScopes{models.ScopeImportsRead}}
+
+	tests := []struct {
+		name   string
+		token  string
+		scope  string
+		status int
+	}{
+		{"jwt with scope", jwt, models.ScopeImportsRead, http.StatusOK},
+		{"jwt without token scopes", jwt, models.ScopeImportsWrite, http.StatusOK},
+		{"jwt on account endpoint", jwt, "", http.StatusOK},
+		{"pat with matching scope", pat, models.ScopeImportsRead, http.StatusOK},
+		{"pat with missing scope", pat, models.ScopeImportsWrite, http.StatusForbidden},
+		{"pat on account endpoint", pat, "", http.StatusForbidden},
+		{"unknown pat", utils.APITokenPrefix + "unknown", models.ScopeImportsRead, http.StatusUnauthorized},
+		{"no token", "", models.ScopeImportsRead, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+			handler := AuthMiddleware(jwtConfig, tokens)(RequireScope(tt.scope)(ok))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/imports", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareMFAClaim(t *testing.T) {
+	jwtConfig := utils.JWTConfig{
+		AccessSecret:  []byte("access-secret"),
+		RefreshSecret: []byte("refresh-secret"),
+		AccessExpiry:  time.Minute,
+		RefreshExpiry: time.Hour,
+	}
+
+	for _, mfa := range []bool{false, true} {
+		token, err := utils.GenerateAccessToken(uuid.New(), "user@example.com", mfa, jwtConfig)
+		if err != nil {
+			t.Fatalf("GenerateAccessToken failed: %v", err)
+		}
+
+		var got interface{}
+		handler := AuthMiddleware(jwtConfig, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Context().Value("mfa")
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/imports", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got != mfa {
+			t.Errorf("mfa = %v, want %v", got, mfa)
+		}
+	}
+}
@@ -1,81 +1,111 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
+	"db-importer/internal/utils"
+	"db-importer/logger"
+	"db-importer/ratelimit"
+
 	"github.com/google/uuid"
 )
 
-// RateLimiter implements a simple token bucket rate limiter with different limits for guests and authenticated users
-type RateLimiter struct {
-	requests    map[string]*clientLimit
-	mu          sync.RWMutex
-	guestLimit  int           // Limit for guest users
-	authLimit   int           // Limit for authenticated users (0 = unlimited)
-	guestWindow time.Duration // Time window for guest users
-	authWindow  time.Duration // Time window for authenticated users
+// PlanResolver resolves the organization a request acts in and its plan,
+// for rate limits per plan. Nothing is returned when the request acts in no
+// organization the user is a member of.
+type PlanResolver interface {
+	RequestPlan(ctx context.Context, userID uuid.UUID) (uuid.UUID, string, error)
 }
 
-type clientLimit struct {
-	count     int
-	resetTime time.Time
+// RateLimiter limits requests with the first policy matching the route
+// and the caller: their organization's plan, then signed-in users, or
+// guests by IP address. Requests made with an API token are also limited
+// by the token policy. Callers without a matching policy are not limited.
+type RateLimiter struct {
+	store    ratelimit.Store
+	policies ratelimit.Policies
+	plans    PlanResolver // nil without a database
+	proxies  utils.TrustedProxies
 }
 
-// NewRateLimiter creates a new rate limiter with different limits for guests and authenticated users
-func NewRateLimiter(guestRequests int, guestWindowSeconds int, authRequests int, authWindowSeconds int) *RateLimiter {
-	rl := &RateLimiter{
-		requests:    make(map[string]*clientLimit),
-		guestLimit:  guestRequests,
-		authLimit:   authRequests,
-		guestWindow: time.Duration(guestWindowSeconds) * time.Second,
-		authWindow:  time.Duration(authWindowSeconds) * time.Second,
+// NewRateLimiter creates a new rate limiter keeping its buckets in store.
+// Guests are told apart by the IP address proxies forward for them.
+func NewRateLimiter(store ratelimit.Store, policies ratelimit.Policies, plans PlanResolver, proxies utils.TrustedProxies) *RateLimiter {
+	return &RateLimiter{
+		store:    store,
+		policies: policies,
+		plans:    plans,
+		proxies:  proxies,
 	}
+}
 
-	// Cleanup old entries every minute
-	go rl.cleanup()
+// rateLimitCaller is a class a caller belongs to and who they are within it
+type rateLimitCaller struct {
+	class    string
+	identity string
+}
 
-	return rl
+// rateLimitCharge is a bucket a request is taken from
+type rateLimitCharge struct {
+	caller rateLimitCaller
+	rule   ratelimit.Rule
 }
 
-// RateLimit middleware checks rate limits per IP for guest users and per user ID for authenticated users
+// RateLimit middleware takes each request from the buckets of its caller
+// and reports the state of the one closest to its limit in RateLimit-*
+// headers. Requests over a limit are refused with 429 Too Many Requests and
+// Retry-After. When the store fails, requests are let through.
 func (rl *RateLimiter) RateLimit(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if user is authenticated by looking at context
-		userID, isAuthenticated := r.Context().Value("userID").(uuid.UUID)
-
-		var identifier string
-		var limit int
-		var window time.Duration
-
-		if isAuthenticated {
-			// Use user ID for authenticated users
-			identifier = "user:" + userID.String()
-			limit = rl.authLimit
-			window = rl.authWindow
-		} else {
-			// Use IP for guest users
-			identifier = "ip:" + getClientIP(r)
-			limit = rl.guestLimit
-			window = rl.guestWindow
+		route := r.URL.Path
+
+		var charged *rateLimitCharge
+		var result ratelimit.Result
+		for _, charge := range rl.charges(r, route) {
+			res, err := rl.store.Allow(r.Context(), charge.rule.Key(charge.caller.identity), charge.rule.Policy)
+			if err != nil {
+				logger.Warn("Rate limit check failed, letting request through", map[string]interface{}{
+					"route": route,
+					"error": err.Error(),
+				})
+				continue
+			}
+			if charged == nil || !res.Allowed || res.Remaining < result.Remaining {
+				charged, result = &charge, res
+			}
+			if !res.Allowed {
+				break
+			}
 		}
-
-		// If auth limit is 0, it means unlimited for authenticated users
-		if isAuthenticated && rl.authLimit == 0 {
+		if charged == nil {
 			next(w, r)
 			return
 		}
+		rule, caller := charged.rule, charged.caller
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		w.Header().Set("RateLimit-Policy", rule.Policy.String())
 
-		if !rl.allow(identifier, limit, window) {
-			remainingTime := rl.getRemainingTime(identifier)
-			if isAuthenticated {
-				http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
-			} else {
-				http.Error(w,
-					"Rate limit exceeded for guest users (3 requests per day). Please sign in for unlimited access or try again in "+remainingTime,
-					http.StatusTooManyRequests)
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+			message := fmt.Sprintf("Rate limit of %s exceeded. Try again in %s", rule.Policy.Describe(), time.Duration(retryAfter)*time.Second)
+			if caller.class == ratelimit.ClassGuest {
+				message += " or sign in for a higher limit"
 			}
+			utils.RespondError(w, http.StatusTooManyRequests, utils.ErrTooManyRequests, message, map[string]interface{}{
+				"limit":             result.Limit,
+				"windowSeconds":     int(rule.Policy.Window / time.Second),
+				"retryAfterSeconds": retryAfter,
+			})
 			return
 		}
 
@@ -83,85 +113,72 @@ func (rl *RateLimiter) RateLimit(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// allow checks if a request from an identifier is allowed
-func (rl *RateLimiter) allow(identifier string, limit int, window time.Duration) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-
-	client, exists := rl.requests[identifier]
-	if !exists || now.After(client.resetTime) {
-		rl.requests[identifier] = &clientLimit{
-			count:     1,
-			resetTime: now.Add(window),
+// charges lists the buckets a request is taken from: its API token's under
+// the first token rule, then its caller's under the first rule matching
+// their plan, the user or the guest. Requests made with a token count
+// against their owner's budget too, so creating tokens does not multiply
+// it.
+func (rl *RateLimiter) charges(r *http.Request, route string) []rateLimitCharge {
+	var charges []rateLimitCharge
+	add := func(callers ...rateLimitCaller) {
+		for _, caller := range callers {
+			if rule, found := rl.policies.Lookup(route, caller.class); found {
+				if !rule.Policy.Unlimited() {
+					charges = append(charges, rateLimitCharge{caller, rule})
+				}
+				return
+			}
 		}
-		return true
 	}
 
-	if client.count >= limit {
-		return false
+	if tokenID, isAPIToken := r.Context().Value("apiTokenID").(string); isAPIToken {
+		add(rateLimitCaller{ratelimit.ClassToken, "token:" + tokenID})
 	}
-
-	client.count++
-	return true
+	add(rl.callers(r, route)...)
+	return charges
 }
 
-// getRemainingTime returns the remaining time until the rate limit resets
-func (rl *RateLimiter) getRemainingTime(identifier string) string {
-	rl.mu.RLock()
-	defer rl.mu.RUnlock()
-
-	client, exists := rl.requests[identifier]
-	if !exists {
-		return "0s"
+// callers lists the classes of the user or guest making a request, most
+// specific first. Members of an organization on a plan share its budget.
+func (rl *RateLimiter) callers(r *http.Request, route string) []rateLimitCaller {
+	userID, isAuthenticated := r.Context().Value("userID").(string)
+	if !isAuthenticated {
+		return []rateLimitCaller{{ratelimit.ClassGuest, "ip:" + rl.proxies.ClientIP(r)}}
 	}
 
-	remaining := time.Until(client.resetTime)
-	if remaining < 0 {
-		return "0s"
+	var callers []rateLimitCaller
+	if plan := rl.plan(r, route, userID); plan != nil {
+		callers = append(callers, *plan)
 	}
-
-	// Format the remaining time nicely
-	if remaining > 24*time.Hour {
-		hours := int(remaining.Hours())
-		return (time.Duration(hours) * time.Hour).String()
-	}
-
-	return remaining.Round(time.Second).String()
+	return append(callers, rateLimitCaller{ratelimit.ClassUser, "user:" + userID})
 }
 
-// cleanup removes expired entries
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for id, client := range rl.requests {
-			if now.After(client.resetTime) {
-				delete(rl.requests, id)
-			}
-		}
-		rl.mu.Unlock()
+// plan resolves the organization plan of a request, only when a policy for
+// the route depends on it
+func (rl *RateLimiter) plan(r *http.Request, route, userID string) *rateLimitCaller {
+	if rl.plans == nil || !rl.policies.HasPlans(route) {
+		return nil
 	}
-}
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		return forwarded
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
 	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+	orgID, plan, err := rl.plans.RequestPlan(r.Context(), uid)
+	if err != nil {
+		logger.Warn("Failed to resolve organization plan for rate limiting", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
 	}
+	if plan == "" {
+		return nil
+	}
+
+	return &rateLimitCaller{ratelimit.PlanClass(plan), "org:" + orgID.String()}
+}
 
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
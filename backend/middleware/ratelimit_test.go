@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"db-importer/internal/utils"
+	"db-importer/ratelimit"
+)
+
+func TestRateLimit_TokensShareOwnerBudget(t *testing.T) {
+	policies := ratelimit.Policies{
+		{Routes: []string{"*"}, Class: ratelimit.ClassToken, Policy: ratelimit.Policy{Limit: 10, Window: time.Minute}},
+		{Routes: []string{"*"}, Class: ratelimit.ClassUser, Policy: ratelimit.Policy{Limit: 3, Window: time.Minute}},
+	}
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), policies, nil, nil)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	handler := limiter.RateLimit(ok)
+
+	request := func(tokenID string) int {
+		ctx := context.WithValue(context.Background(), "userID", "owner")
+		if tokenID != "" {
+			ctx = context.WithValue(ctx, "apiTokenID", tokenID)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/imports", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Each token has room left, but together with the browser session they
+	// use up the owner's budget
+	for i, tokenID := range []string{"token-a", "token-b", ""} {
+		if code := request(tokenID); code != http.StatusOK {
+			t.Fatalf("Request %d: status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if code := request("token-c"); code != http.StatusTooManyRequests {
+		t.Errorf("Status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimit_GuestsByTrustedClientIP(t *testing.T) {
+	policies := ratelimit.Policies{
+		{Routes: []string{"*"}, Class: ratelimit.ClassGuest, Policy: ratelimit.Policy{Limit: 1, Window: time.Hour}},
+	}
+	proxies, err := utils.ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       []int
+	}{
+		{
+			name:       "forwarded by trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			forwarded:  []string{"198.51.100.1, 10.0.0.3", "198.51.100.2"},
+			want:       []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "spoofed by client",
+			remoteAddr: "203.0.113.9:4000",
+			forwarded:  []string{"198.51.100.1", "198.51.100.2"},
+			want:       []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(ratelimit.NewMemoryStore(), policies, nil, proxies)
+			ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+			handler := limiter.RateLimit(ok)
+
+			for i, forwarded := range tt.forwarded {
+				req := httptest.NewRequest(http.MethodGet, "/generate-sql", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwarded)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				if rec.Code != tt.want[i] {
+					t.Errorf("Request %d: status = %d, want %d", i, rec.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+-- Remove dialect column from workflow_sessions table
+ALTER TABLE workflow_sessions
+DROP COLUMN IF EXISTS dialect;
@@ -0,0 +1,6 @@
+-- Add dialect column to workflow_sessions table
+ALTER TABLE workflow_sessions
+ADD COLUMN dialect VARCHAR(32);
+
+-- Add comment for documentation
+COMMENT ON COLUMN workflow_sessions.dialect IS 'SQL dialect of the uploaded schema (mysql, postgresql, sqlserver, sqlite), used for SQL generation';
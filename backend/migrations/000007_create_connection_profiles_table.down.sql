@@ -0,0 +1,4 @@
+-- Drop connection_profiles table
+DROP TRIGGER IF EXISTS update_connection_profiles_updated_at ON connection_profiles;
+DROP INDEX IF EXISTS idx_connection_profiles_user_id;
+DROP TABLE IF EXISTS connection_profiles;
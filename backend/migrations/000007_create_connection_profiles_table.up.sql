@@ -0,0 +1,31 @@
+-- Create connection_profiles table
+CREATE TABLE IF NOT EXISTS connection_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    dialect VARCHAR(32) NOT NULL,
+    encrypted_dsn TEXT NOT NULL,
+    display_dsn TEXT NOT NULL,
+    db_schema VARCHAR(255),
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT connection_profiles_dialect_check CHECK (dialect IN ('postgresql', 'mysql', 'sqlite')),
+    CONSTRAINT connection_profiles_user_name_unique UNIQUE (user_id, name)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_connection_profiles_user_id ON connection_profiles(user_id);
+
+-- Create trigger to auto-update updated_at (reuse existing function)
+CREATE TRIGGER update_connection_profiles_updated_at
+    BEFORE UPDATE ON connection_profiles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE connection_profiles IS 'Saved database connections used for live schema introspection';
+COMMENT ON COLUMN connection_profiles.encrypted_dsn IS 'Connection string encrypted with AES-256-GCM (CREDENTIALS_ENCRYPTION_KEY)';
+COMMENT ON COLUMN connection_profiles.display_dsn IS 'Connection string with the password redacted, safe to show to users';
+COMMENT ON COLUMN connection_profiles.db_schema IS 'PostgreSQL schema to introspect (default public)';
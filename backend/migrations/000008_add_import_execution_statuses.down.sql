@@ -0,0 +1,9 @@
+-- Map execution statuses back before restoring the original constraint
+UPDATE imports SET status = 'success' WHERE status = 'executed';
+UPDATE imports SET status = 'failed' WHERE status = 'rolled_back';
+
+ALTER TABLE imports DROP CONSTRAINT IF EXISTS imports_status_check;
+ALTER TABLE imports
+ADD CONSTRAINT imports_status_check CHECK (status IN ('success', 'failed', 'warning'));
+
+COMMENT ON COLUMN imports.status IS 'Import result status: success (no errors), warning (has warnings), failed (has errors)';
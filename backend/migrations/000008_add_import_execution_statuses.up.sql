@@ -0,0 +1,7 @@
+-- Allow statuses for imports executed against a saved connection
+ALTER TABLE imports DROP CONSTRAINT IF EXISTS imports_status_check;
+ALTER TABLE imports
+ADD CONSTRAINT imports_status_check CHECK (status IN ('success', 'failed', 'warning', 'executed', 'rolled_back'));
+
+-- Update comment for documentation
+COMMENT ON COLUMN imports.status IS 'Import result status: success (no errors), warning (has warnings), failed (has errors), executed (run against a connection), rolled_back (execution failed and was rolled back)';
@@ -0,0 +1,5 @@
+-- Drop jobs table
+DROP TRIGGER IF EXISTS update_jobs_updated_at ON jobs;
+DROP INDEX IF EXISTS idx_jobs_queue;
+DROP INDEX IF EXISTS idx_jobs_user_created;
+DROP TABLE IF EXISTS jobs;
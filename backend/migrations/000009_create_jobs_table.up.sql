@@ -0,0 +1,40 @@
+-- Create jobs table (background import jobs, claimed with FOR UPDATE SKIP LOCKED)
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    payload JSONB NOT NULL,
+    phase VARCHAR(32),
+    rows_total INTEGER NOT NULL DEFAULT 0,
+    rows_processed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    import_id UUID REFERENCES imports(id) ON DELETE SET NULL,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    locked_by VARCHAR(100),
+    locked_at TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT jobs_kind_check CHECK (kind IN ('generate_sql', 'execute')),
+    CONSTRAINT jobs_status_check CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'canceled'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_jobs_user_created ON jobs(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_queue ON jobs(created_at) WHERE status IN ('queued', 'running');
+
+-- Create trigger to auto-update updated_at (reuse existing function)
+CREATE TRIGGER update_jobs_updated_at
+    BEFORE UPDATE ON jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE jobs IS 'Background import jobs processed by server workers';
+COMMENT ON COLUMN jobs.payload IS 'Job request (rows, mapping, fields, options) as submitted';
+COMMENT ON COLUMN jobs.locked_at IS 'Worker heartbeat; running jobs with a stale heartbeat are reclaimed';
+COMMENT ON COLUMN jobs.import_id IS 'Import record holding the results (SQL, rejected rows, report)';
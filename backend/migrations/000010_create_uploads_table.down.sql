@@ -0,0 +1,5 @@
+-- Drop uploads table
+DROP TRIGGER IF EXISTS update_uploads_updated_at ON uploads;
+DROP INDEX IF EXISTS idx_uploads_expires_at;
+DROP INDEX IF EXISTS idx_uploads_user_id;
+DROP TABLE IF EXISTS uploads;
@@ -0,0 +1,36 @@
+-- Create uploads table (resumable chunked uploads)
+CREATE TABLE IF NOT EXISTS uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    purpose VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'uploading',
+    total_size BIGINT,
+    received_bytes BIGINT NOT NULL DEFAULT 0,
+    parts JSONB NOT NULL DEFAULT '[]'::jsonb,
+    checksum VARCHAR(100),
+    storage_key TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT uploads_purpose_check CHECK (purpose IN ('schema', 'data')),
+    CONSTRAINT uploads_status_check CHECK (status IN ('uploading', 'completed', 'failed')),
+    CONSTRAINT uploads_received_check CHECK (received_bytes >= 0)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_uploads_user_id ON uploads(user_id);
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads(expires_at);
+
+-- Create trigger to auto-update updated_at (reuse existing function)
+CREATE TRIGGER update_uploads_updated_at
+    BEFORE UPDATE ON uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE uploads IS 'Resumable chunked uploads of schema and data files';
+COMMENT ON COLUMN uploads.parts IS 'Stored chunks in byte order: [{key, size}]';
+COMMENT ON COLUMN uploads.storage_key IS 'Storage key of the assembled file once completed';
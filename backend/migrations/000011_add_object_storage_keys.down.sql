@@ -0,0 +1,12 @@
+-- Content already moved to object storage is not copied back
+DROP INDEX IF EXISTS idx_imports_legacy_sql;
+
+ALTER TABLE workflow_sessions DROP COLUMN IF EXISTS schema_storage_key;
+
+ALTER TABLE imports
+DROP COLUMN IF EXISTS sql_size,
+DROP COLUMN IF EXISTS sql_checksum,
+DROP COLUMN IF EXISTS sql_storage_key;
+
+COMMENT ON COLUMN imports.generated_sql IS 'Compressed SQL statements using gzip';
+COMMENT ON COLUMN workflow_sessions.schema_content IS 'Original SQL schema file content (compressed with gzip)';
@@ -0,0 +1,21 @@
+-- Generated SQL and schema content move to object storage; the TEXT columns
+-- stay for rows written before the move (and without a storage backend)
+ALTER TABLE imports
+ADD COLUMN sql_storage_key TEXT,
+ADD COLUMN sql_checksum VARCHAR(64),
+ADD COLUMN sql_size BIGINT;
+
+ALTER TABLE workflow_sessions
+ADD COLUMN schema_storage_key TEXT;
+
+-- Finds rows still to be moved to object storage
+CREATE INDEX idx_imports_legacy_sql ON imports(created_at)
+WHERE generated_sql IS NOT NULL AND sql_storage_key IS NULL;
+
+-- Comments for documentation
+COMMENT ON COLUMN imports.generated_sql IS 'Compressed SQL statements using gzip (legacy, see sql_storage_key)';
+COMMENT ON COLUMN imports.sql_storage_key IS 'Object storage key of the generated SQL (plain text)';
+COMMENT ON COLUMN imports.sql_checksum IS 'Hex encoded SHA-256 of the generated SQL';
+COMMENT ON COLUMN imports.sql_size IS 'Size of the generated SQL in bytes';
+COMMENT ON COLUMN workflow_sessions.schema_content IS 'Original SQL schema file content (compressed with gzip, legacy, see schema_storage_key)';
+COMMENT ON COLUMN workflow_sessions.schema_storage_key IS 'Object storage key of the schema file content';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_imports_legacy_rollback;
+
+ALTER TABLE imports
+DROP COLUMN IF EXISTS rollback_sql,
+DROP COLUMN IF EXISTS rollback_storage_key;
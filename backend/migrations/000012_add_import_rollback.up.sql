@@ -0,0 +1,13 @@
+-- Undo script generated for every import, kept like the forward SQL:
+-- in object storage, or compressed when no storage backend is configured
+ALTER TABLE imports
+ADD COLUMN rollback_storage_key TEXT,
+ADD COLUMN rollback_sql TEXT;
+
+-- Finds compressed scripts still to be moved to object storage
+CREATE INDEX idx_imports_legacy_rollback ON imports(created_at)
+WHERE rollback_sql IS NOT NULL AND rollback_storage_key IS NULL;
+
+-- Comments for documentation
+COMMENT ON COLUMN imports.rollback_storage_key IS 'Object storage key of the rollback script (plain text)';
+COMMENT ON COLUMN imports.rollback_sql IS 'Compressed rollback script using gzip (without object storage)';
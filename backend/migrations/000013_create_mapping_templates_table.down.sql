@@ -0,0 +1,5 @@
+-- Drop mapping_templates table
+DROP TRIGGER IF EXISTS update_mapping_templates_updated_at ON mapping_templates;
+DROP INDEX IF EXISTS idx_mapping_templates_header_signature;
+DROP INDEX IF EXISTS idx_mapping_templates_user_id;
+DROP TABLE IF EXISTS mapping_templates;
@@ -0,0 +1,38 @@
+-- Create mapping_templates table (named, reusable column mappings)
+CREATE TABLE IF NOT EXISTS mapping_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    table_name VARCHAR(255) NOT NULL,
+    table_fields JSONB NOT NULL DEFAULT '[]'::jsonb,
+    table_signature VARCHAR(64) NOT NULL,
+    source_headers JSONB NOT NULL DEFAULT '[]'::jsonb,
+    header_signature VARCHAR(64) NOT NULL,
+    column_mapping JSONB NOT NULL DEFAULT '{}'::jsonb,
+    field_transformations JSONB NOT NULL DEFAULT '{}'::jsonb,
+    options JSONB NOT NULL DEFAULT '{}'::jsonb,
+    shared BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT mapping_templates_user_name_unique UNIQUE (user_id, name)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_mapping_templates_user_id ON mapping_templates(user_id);
+CREATE INDEX IF NOT EXISTS idx_mapping_templates_header_signature ON mapping_templates(header_signature);
+
+-- Create trigger to auto-update updated_at (reuse existing function)
+CREATE TRIGGER update_mapping_templates_updated_at
+    BEFORE UPDATE ON mapping_templates
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE mapping_templates IS 'Named column mappings reusable across workflow sessions';
+COMMENT ON COLUMN mapping_templates.table_signature IS 'SHA-256 of the target table and field names, normalized';
+COMMENT ON COLUMN mapping_templates.header_signature IS 'SHA-256 of the source headers, normalized and sorted; matched against uploaded data files';
+COMMENT ON COLUMN mapping_templates.column_mapping IS 'Source header -> target field';
+COMMENT ON COLUMN mapping_templates.field_transformations IS 'Target field -> transformation';
+COMMENT ON COLUMN mapping_templates.shared IS 'Visible to every user; only the owner can change it';
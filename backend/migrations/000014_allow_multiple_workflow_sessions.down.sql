@@ -0,0 +1,13 @@
+-- Keep only the most recently updated session of each user; schema content
+-- of the deleted sessions stays in object storage
+DELETE FROM workflow_sessions older
+USING workflow_sessions newer
+WHERE older.user_id = newer.user_id
+  AND (older.updated_at < newer.updated_at
+       OR (older.updated_at = newer.updated_at AND older.id < newer.id));
+
+ALTER TABLE workflow_sessions DROP COLUMN IF EXISTS name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_sessions_user_active ON workflow_sessions(user_id);
+
+COMMENT ON TABLE workflow_sessions IS 'Stores active workflow sessions to allow users to resume after page refresh';
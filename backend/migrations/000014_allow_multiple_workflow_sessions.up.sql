@@ -0,0 +1,9 @@
+-- Users may keep several workflow sessions in progress, told apart by name
+DROP INDEX IF EXISTS idx_workflow_sessions_user_active;
+
+ALTER TABLE workflow_sessions
+ADD COLUMN name VARCHAR(255) NOT NULL DEFAULT 'Untitled import';
+
+-- Add comments for documentation
+COMMENT ON TABLE workflow_sessions IS 'Stores workflow sessions (several per user) to allow users to resume imports after page refresh';
+COMMENT ON COLUMN workflow_sessions.name IS 'User chosen name telling the sessions of a user apart';
@@ -0,0 +1,4 @@
+-- Stored datasets are left in object storage
+ALTER TABLE workflow_sessions
+DROP COLUMN IF EXISTS data_row_count,
+DROP COLUMN IF EXISTS data_storage_key;
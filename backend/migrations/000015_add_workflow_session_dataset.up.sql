@@ -0,0 +1,9 @@
+-- The complete data file of a session is kept in object storage so SQL can
+-- be generated for every row after the browser lost it
+ALTER TABLE workflow_sessions
+ADD COLUMN data_storage_key TEXT,
+ADD COLUMN data_row_count INTEGER;
+
+-- Comments for documentation
+COMMENT ON COLUMN workflow_sessions.data_storage_key IS 'Object storage key of all data file rows (gzip compressed JSON lines)';
+COMMENT ON COLUMN workflow_sessions.data_row_count IS 'Number of rows stored under data_storage_key';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS workflow_session_history;
+
+ALTER TABLE workflow_sessions
+DROP COLUMN IF EXISTS version;
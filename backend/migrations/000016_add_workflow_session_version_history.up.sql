@@ -0,0 +1,28 @@
+-- Writes to a session carry the version they were based on, so two tabs
+-- editing the same session cannot overwrite each other's changes
+ALTER TABLE workflow_sessions
+ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Append-only history of the step state after every change of a session
+CREATE TABLE IF NOT EXISTS workflow_session_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES workflow_sessions(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    change VARCHAR(50) NOT NULL,
+
+    -- Step state after the change
+    current_step INTEGER NOT NULL,
+    selected_table_name VARCHAR(255),
+    column_mapping JSONB DEFAULT '{}'::jsonb,
+    field_transformations JSONB DEFAULT '{}'::jsonb,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT workflow_session_history_version_unique UNIQUE (session_id, version)
+);
+
+-- Add comments for documentation
+COMMENT ON COLUMN workflow_sessions.version IS 'Incremented on every change; sent as the ETag and checked against If-Match';
+COMMENT ON TABLE workflow_session_history IS 'Step state of workflow sessions after each change, used to undo to a previous table selection or mapping';
+COMMENT ON COLUMN workflow_session_history.version IS 'Session version the change produced';
+COMMENT ON COLUMN workflow_session_history.change IS 'What was changed: created, renamed, schema, table, data, mapping, template, inferred or restored';
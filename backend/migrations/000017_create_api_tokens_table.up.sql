@@ -0,0 +1,26 @@
+-- Personal access tokens for scripted access (CI pipelines). Only a SHA-256
+-- hash of each token is stored, like refresh tokens.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(255) NOT NULL,
+    token_prefix VARCHAR(20) NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]'::jsonb,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT api_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id, created_at DESC);
+
+-- Add comments for documentation
+COMMENT ON TABLE api_tokens IS 'Long-lived, revocable and scoped personal access tokens';
+COMMENT ON COLUMN api_tokens.token_prefix IS 'First characters of the token, shown so users can tell their tokens apart';
+COMMENT ON COLUMN api_tokens.scopes IS 'Granted scopes: schema:parse, sql:generate, imports:read, imports:write';
+COMMENT ON COLUMN api_tokens.expires_at IS 'Expiration timestamp; NULL never expires';
+COMMENT ON COLUMN api_tokens.last_used_at IS 'Last authenticated request, updated at most once a minute';
@@ -0,0 +1,26 @@
+-- Organization-owned resources are removed along with the organizations
+DELETE FROM workflow_sessions WHERE organization_id IS NOT NULL;
+DELETE FROM connection_profiles WHERE organization_id IS NOT NULL;
+DELETE FROM mapping_templates WHERE organization_id IS NOT NULL;
+DELETE FROM imports WHERE organization_id IS NOT NULL;
+DELETE FROM jobs WHERE organization_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_connection_profiles_organization_name;
+DROP INDEX IF EXISTS idx_connection_profiles_user_name;
+ALTER TABLE connection_profiles ADD CONSTRAINT connection_profiles_user_name_unique UNIQUE (user_id, name);
+
+DROP INDEX IF EXISTS idx_mapping_templates_organization_name;
+DROP INDEX IF EXISTS idx_mapping_templates_user_name;
+ALTER TABLE mapping_templates ADD CONSTRAINT mapping_templates_user_name_unique UNIQUE (user_id, name);
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE workflow_sessions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE connection_profiles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE mapping_templates DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE imports DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TRIGGER IF EXISTS update_organization_members_updated_at ON organization_members;
+DROP TABLE IF EXISTS organization_members;
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+DROP TABLE IF EXISTS organizations;
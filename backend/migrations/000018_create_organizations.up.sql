@@ -0,0 +1,85 @@
+-- Organizations let a team share imports, mapping templates, connection
+-- profiles and workflow sessions. Members hold one of four roles.
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    PRIMARY KEY (organization_id, user_id),
+    CONSTRAINT organization_members_role_check CHECK (role IN ('owner', 'admin', 'editor', 'viewer'))
+);
+
+-- Invitations are sent to an email address. Only a SHA-256 hash of the
+-- invitation token is stored, like refresh tokens.
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(255) NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT organization_invitations_token_hash_key UNIQUE (token_hash),
+    CONSTRAINT organization_invitations_role_check CHECK (role IN ('admin', 'editor', 'viewer'))
+);
+
+-- Resources owned by an organization keep the user who created them in user_id
+ALTER TABLE imports ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE mapping_templates ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE connection_profiles ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE workflow_sessions ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE jobs ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- Names are unique per workspace: per user for personal resources and per
+-- organization for shared ones
+ALTER TABLE mapping_templates DROP CONSTRAINT mapping_templates_user_name_unique;
+CREATE UNIQUE INDEX idx_mapping_templates_user_name ON mapping_templates(user_id, name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX idx_mapping_templates_organization_name ON mapping_templates(organization_id, name) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE connection_profiles DROP CONSTRAINT connection_profiles_user_name_unique;
+CREATE UNIQUE INDEX idx_connection_profiles_user_name ON connection_profiles(user_id, name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX idx_connection_profiles_organization_name ON connection_profiles(organization_id, name) WHERE organization_id IS NOT NULL;
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_organization_id ON organization_invitations(organization_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_imports_organization_created ON imports(organization_id, created_at DESC) WHERE organization_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mapping_templates_organization_id ON mapping_templates(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_connection_profiles_organization_id ON connection_profiles(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workflow_sessions_organization_updated ON workflow_sessions(organization_id, updated_at DESC) WHERE organization_id IS NOT NULL;
+
+-- Create triggers to automatically update updated_at
+CREATE TRIGGER update_organizations_updated_at
+    BEFORE UPDATE ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_organization_members_updated_at
+    BEFORE UPDATE ON organization_members
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE organizations IS 'Shared workspaces for teams';
+COMMENT ON TABLE organization_members IS 'Users belonging to an organization and their role';
+COMMENT ON COLUMN organization_members.role IS 'owner, admin, editor or viewer';
+COMMENT ON TABLE organization_invitations IS 'Pending and accepted invitations to join an organization';
+COMMENT ON COLUMN organization_invitations.accepted_at IS 'When the invitation was accepted; NULL while pending';
+COMMENT ON COLUMN imports.organization_id IS 'Owning organization; NULL for personal imports';
+COMMENT ON COLUMN mapping_templates.organization_id IS 'Owning organization; NULL for personal templates';
+COMMENT ON COLUMN connection_profiles.organization_id IS 'Owning organization; NULL for personal profiles';
+COMMENT ON COLUMN workflow_sessions.organization_id IS 'Owning organization; NULL for personal sessions';
+COMMENT ON COLUMN jobs.organization_id IS 'Organization the job imports into; NULL for personal imports';
@@ -0,0 +1,22 @@
+-- Single-use tokens sent by email to verify an address or reset a
+-- password. The token itself is signed and names its row here, which
+-- records whether it was used.
+CREATE TABLE IF NOT EXISTS email_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT email_tokens_purpose_check CHECK (purpose IN ('verify_email', 'reset_password'))
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user_purpose ON email_tokens(user_id, purpose, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_email_tokens_expires_at ON email_tokens(expires_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE email_tokens IS 'Email verification and password reset tokens';
+COMMENT ON COLUMN email_tokens.purpose IS 'verify_email or reset_password';
+COMMENT ON COLUMN email_tokens.used_at IS 'When the token was used or superseded by a newer one; NULL while it can be used';
@@ -0,0 +1,24 @@
+-- Accounts of users at external OpenID Connect providers. A user signs in
+-- through a provider once one of its accounts is linked here; users created
+-- on their first provider sign-in have an empty password hash and cannot
+-- sign in with a password until they reset it.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+
+    CONSTRAINT user_identities_issuer_subject_key UNIQUE (issuer, subject)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE user_identities IS 'Accounts at OpenID Connect providers linked to users';
+COMMENT ON COLUMN user_identities.issuer IS 'Issuer URL of the provider';
+COMMENT ON COLUMN user_identities.subject IS 'Stable account ID at the provider (sub claim)';
+COMMENT ON COLUMN user_identities.email IS 'Email address the provider last reported';
@@ -0,0 +1,5 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS require_mfa;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TRIGGER IF EXISTS update_user_totp_updated_at ON user_totp;
+DROP TABLE IF EXISTS user_totp;
@@ -0,0 +1,52 @@
+-- Optional TOTP two-factor authentication. The secret is encrypted with
+-- the credentials key like connection profile passwords; enabled_at stays
+-- NULL until the user confirmed a first code.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TRIGGER update_user_totp_updated_at
+    BEFORE UPDATE ON user_totp
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Single-use recovery codes for users who lost their authenticator. Only a
+-- SHA-256 hash of each code is stored.
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Second step of a sign-in: the password was correct and a code is due.
+-- Each challenge allows a few attempts.
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    remember_me BOOLEAN NOT NULL DEFAULT false,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Organizations can require their members to use two-factor authentication
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_mfa BOOLEAN NOT NULL DEFAULT false;
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE user_totp IS 'TOTP authenticator secrets of users';
+COMMENT ON COLUMN user_totp.last_used_step IS 'Time step of the last accepted code; codes of this or earlier steps are rejected';
+COMMENT ON TABLE mfa_recovery_codes IS 'Hashed single-use two-factor recovery codes';
+COMMENT ON TABLE mfa_challenges IS 'Pending second steps of two-factor sign-ins';
+COMMENT ON COLUMN organizations.require_mfa IS 'Members must enable two-factor authentication to access the organization';
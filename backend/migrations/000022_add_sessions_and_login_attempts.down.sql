@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS signed_in_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,29 @@
+-- Refresh tokens describe the device session they belong to. A session
+-- keeps signed_in_at while its token is rotated on each refresh.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip_address VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS signed_in_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP DEFAULT NOW();
+
+UPDATE refresh_tokens SET signed_in_at = created_at, last_used_at = created_at;
+
+-- Password and two-factor login attempts per email address, for delaying
+-- and locking out repeated failures. Attempts are recorded as failed when
+-- they start and marked succeeded once a session is issued.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    succeeded BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email_created_at ON login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
+
+-- Add comments for documentation
+COMMENT ON TABLE login_attempts IS 'Login attempts per email address for brute-force protection';
+COMMENT ON COLUMN login_attempts.email IS 'Email address as entered, lower-cased; need not belong to a user';
+COMMENT ON COLUMN refresh_tokens.signed_in_at IS 'When the session of this token signed in';
+COMMENT ON COLUMN refresh_tokens.last_used_at IS 'When the token was issued or last refreshed';
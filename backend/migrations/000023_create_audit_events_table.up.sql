@@ -0,0 +1,42 @@
+-- Append-only log of security- and data-relevant actions. The actor's
+-- email is copied so events stay readable after the user is deleted;
+-- actor and organization IDs are kept without foreign keys for the same
+-- reason.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    actor_id UUID,
+    actor_email VARCHAR(255),
+    organization_id UUID,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(50) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}'
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_organization_id ON audit_events(organization_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- Events cannot be changed or deleted through normal statements
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_audit_event_changes();
+
+-- Add comments for documentation
+COMMENT ON TABLE audit_events IS 'Append-only log of security- and data-relevant actions';
+COMMENT ON COLUMN audit_events.actor_id IS 'User who acted; NULL for failed logins of unknown addresses';
+COMMENT ON COLUMN audit_events.organization_id IS 'Organization whose admins can read the event; NULL for personal events';
+COMMENT ON COLUMN audit_events.action IS 'What happened, e.g. import.delete';
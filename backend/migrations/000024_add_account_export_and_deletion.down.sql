@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_jobs_result_storage_key;
+ALTER TABLE jobs DROP COLUMN IF EXISTS result_size;
+ALTER TABLE jobs DROP COLUMN IF EXISTS result_storage_key;
+DELETE FROM jobs WHERE kind = 'account_export';
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_kind_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_kind_check CHECK (kind IN ('generate_sql', 'execute'));
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_at;
@@ -0,0 +1,38 @@
+-- Accounts can be scheduled for deletion; they are deleted for good, with
+-- everything that cascades from users, once the grace period ends
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Account data exports run as background jobs and leave a zip archive in
+-- object storage
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_kind_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_kind_check CHECK (kind IN ('generate_sql', 'execute', 'account_export'));
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result_storage_key VARCHAR(512);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result_size BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_result_storage_key ON jobs(finished_at)
+    WHERE result_storage_key IS NOT NULL;
+
+-- Audit events stay append-only, except that the personal data of deleted
+-- users (email, IP address, user agent) can be erased from them
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND NEW.actor_email IS NULL AND NEW.ip_address = '' AND NEW.user_agent = ''
+       AND NEW.metadata = OLD.metadata - 'email'
+       AND (NEW.id, NEW.occurred_at, NEW.actor_id, NEW.organization_id, NEW.action, NEW.target_type, NEW.target_id)
+           IS NOT DISTINCT FROM
+           (OLD.id, OLD.occurred_at, OLD.actor_id, OLD.organization_id, OLD.action, OLD.target_type, OLD.target_id)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Add comments for documentation
+COMMENT ON COLUMN users.deletion_scheduled_at IS 'When the account is deleted for good; NULL unless deletion was requested';
+COMMENT ON COLUMN jobs.result_storage_key IS 'Object storage key of the file a job produced, such as an account export';
@@ -0,0 +1,12 @@
+-- Rate limit buckets shared by every replica. tat is the theoretical
+-- arrival time of the next request of the generic cell rate algorithm; a
+-- bucket whose tat has passed is full and can be deleted.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(512) PRIMARY KEY,
+    tat TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_tat ON rate_limit_buckets(tat);
+
+-- Plan of an organization, set by operators, for per-plan rate limits
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS plan VARCHAR(50) NOT NULL DEFAULT 'free';
@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingMethods are the ID token algorithms accepted; symmetric and
+// unsigned tokens are always rejected
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// clockSkew is the leeway allowed on the time claims of ID tokens
+const clockSkew = time.Minute
+
+// IDToken is a verified ID token
+type IDToken struct {
+	Issuer  string
+	Subject string
+	Expiry  time.Time
+	claims  jwt.MapClaims
+}
+
+// Verify checks the signature of an ID token against the provider's keys
+// and its issuer, audience, expiry and nonce
+func (c *Client) Verify(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	metadata, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.keys.key(ctx, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	token := &IDToken{Issuer: metadata.Issuer, claims: claims}
+	token.Subject = token.String("sub")
+	if token.Subject == "" {
+		return nil, fmt.Errorf("invalid ID token: no subject")
+	}
+	if token.String("nonce") != nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+	// With several audiences the token must have been issued to us
+	if audience, _ := claims.GetAudience(); len(audience) > 1 || token.String("azp") != "" {
+		if token.String("azp") != c.config.ClientID {
+			return nil, fmt.Errorf("invalid ID token: issued to another client")
+		}
+	}
+	if expiry, _ := claims.GetExpirationTime(); expiry != nil {
+		token.Expiry = expiry.Time
+	}
+	return token, nil
+}
+
+// String returns a string claim, or "" when it is missing or not a string
+func (t *IDToken) String(name string) string {
+	value, _ := t.claims[name].(string)
+	return value
+}
+
+// Bool returns a boolean claim; some providers send booleans as strings
+func (t *IDToken) Bool(name string) bool {
+	switch value := t.claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	}
+	return false
+}
+
+// ClaimMapping names the ID token claims a Profile is read from; empty
+// fields use the standard claims
+type ClaimMapping struct {
+	Email         string // default "email"
+	EmailVerified string // default "email_verified"
+	FirstName     string // default "given_name"
+	LastName      string // default "family_name"
+
+	// TrustEmail treats every email the provider sends as verified, for
+	// providers that only hand out addresses they own but do not send
+	// email_verified
+	TrustEmail bool
+}
+
+// Profile is the user an ID token describes
+type Profile struct {
+	Issuer        string
+	Subject       string
+	Email         string // lower-cased
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Profile reads the user from token. Without first and last name claims
+// the name claim is split at its first space.
+func (m ClaimMapping) Profile(token *IDToken) Profile {
+	profile := Profile{
+		Issuer:    token.Issuer,
+		Subject:   token.Subject,
+		Email:     strings.ToLower(strings.TrimSpace(token.String(orDefault(m.Email, "email")))),
+		FirstName: strings.TrimSpace(token.String(orDefault(m.FirstName, "given_name"))),
+		LastName:  strings.TrimSpace(token.String(orDefault(m.LastName, "family_name"))),
+	}
+	profile.EmailVerified = profile.Email != "" && (m.TrustEmail || token.Bool(orDefault(m.EmailVerified, "email_verified")))
+
+	if profile.FirstName == "" && profile.LastName == "" {
+		first, last, _ := strings.Cut(strings.TrimSpace(token.String("name")), " ")
+		profile.FirstName, profile.LastName = first, strings.TrimSpace(last)
+	}
+	return profile
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
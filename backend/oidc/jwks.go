@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minKeyRefresh limits how often an unknown key ID makes the key set
+// reload, so forged tokens cannot hammer the provider
+const minKeyRefresh = time.Minute
+
+// jsonWebKey is a public key of a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a parsed signing key
+type publicKey struct {
+	id  string
+	alg string // empty when the key does not restrict it
+	key crypto.PublicKey
+}
+
+// keySet caches the provider's signing keys and reloads them when a token
+// names a key it does not know, which is how providers rotate keys
+type keySet struct {
+	url   string
+	fetch func(ctx context.Context, url string, v interface{}) error
+
+	mu        sync.Mutex
+	keys      []publicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(url string, fetch func(ctx context.Context, url string, v interface{}) error) *keySet {
+	return &keySet{url: url, fetch: fetch}
+}
+
+// key returns the key with id (any suitable key when the token has none)
+// that can verify alg
+func (s *keySet) key(ctx context.Context, id, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key := s.find(id, alg); key != nil {
+		return key, nil
+	}
+	if !s.fetchedAt.IsZero() && time.Since(s.fetchedAt) < minKeyRefresh {
+		return nil, fmt.Errorf("no signing key %q", id)
+	}
+	if err := s.reload(ctx); err != nil {
+		return nil, err
+	}
+	if key := s.find(id, alg); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no signing key %q", id)
+}
+
+// find looks a key up in the cached set
+func (s *keySet) find(id, alg string) crypto.PublicKey {
+	for _, k := range s.keys {
+		if (id == "" || k.id == id) && (k.alg == "" || k.alg == alg) && keyFits(k.key, alg) {
+			return k.key
+		}
+	}
+	return nil
+}
+
+// reload fetches the JWKS document, skipping keys it cannot use
+func (s *keySet) reload(ctx context.Context) error {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.fetch(ctx, s.url, &document); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make([]publicKey, 0, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, publicKey{id: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// publicKey parses an RSA or EC key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// keyFits reports whether key can verify signatures made with alg
+func keyFits(key crypto.PublicKey, alg string) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return alg == "ES256"
+		case elliptic.P384():
+			return alg == "ES384"
+		case elliptic.P521():
+			return alg == "ES512"
+		}
+	}
+	return false
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
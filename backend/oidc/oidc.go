@@ -0,0 +1,268 @@
+// Package oidc signs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE. It discovers the provider's endpoints,
+// exchanges codes for tokens and validates ID tokens against the
+// provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTimeout bounds requests to the provider when the context has no
+// deadline
+const httpTimeout = 10 * time.Second
+
+// Config configures the client registered with the provider
+type Config struct {
+	IssuerURL    string // e.g. https://login.example.com/realms/staff
+	ClientID     string
+	ClientSecret string // empty for public clients
+	RedirectURL  string // callback registered with the provider
+	Scopes       []string
+	HTTPClient   *http.Client // defaults to http.DefaultClient
+}
+
+// Metadata is the part of the provider's discovery document the client uses
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Tokens is the token endpoint response
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Client signs users in through one provider. The discovery document is
+// fetched on first use and kept once it loaded.
+type Client struct {
+	config Config
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     *keySet
+}
+
+// New creates a client for the provider; it does not contact the provider
+func New(config Config) (*Client, error) {
+	if config.IssuerURL == "" {
+		return nil, fmt.Errorf("issuer URL is required")
+	}
+	if config.ClientID == "" {
+		return nil, fmt.Errorf("client ID is required")
+	}
+	if config.RedirectURL == "" {
+		return nil, fmt.Errorf("redirect URL is required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &Client{config: config}, nil
+}
+
+// Discover returns the provider metadata, fetching it if needed
+func (c *Client) Discover(ctx context.Context) (*Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	var metadata Metadata
+	wellKnown := strings.TrimSuffix(c.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, wellKnown, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+	if metadata.Issuer != c.config.IssuerURL {
+		return nil, fmt.Errorf("provider issuer %q does not match configured issuer %q", metadata.Issuer, c.config.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider discovery document is missing endpoints")
+	}
+
+	c.metadata = &metadata
+	c.keys = newKeySet(metadata.JWKSURI, c.getJSON)
+	return c.metadata, nil
+}
+
+// AuthCodeURL returns the provider URL to send the browser to. The
+// callback receives state back; nonce comes back inside the ID token and
+// verifier must be presented when exchanging the code.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.config.ClientID},
+		"redirect_uri":          {c.config.RedirectURL},
+		"scope":                 {strings.Join(c.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*Tokens, error) {
+	metadata, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if c.config.ClientSecret == "" {
+		form.Set("client_id", c.config.ClientID)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		// client_secret_basic form-encodes both parts (RFC 6749 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return nil, fmt.Errorf("token request rejected: %s %s", failure.Error, failure.Description)
+		}
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var tokens Tokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("token response has no ID token")
+	}
+	return &tokens, nil
+}
+
+// Authenticate exchanges the code from the callback and returns the
+// verified ID token
+func (c *Client) Authenticate(ctx context.Context, code, verifier, nonce string) (*IDToken, error) {
+	tokens, err := c.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	return c.Verify(ctx, tokens.IDToken, nonce)
+}
+
+// getJSON fetches url and decodes its JSON body into v
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON from %s: %w", url, err)
+	}
+	return nil
+}
+
+// RandomString returns a URL-safe random string for state, nonce and PKCE
+// verifiers
+func RandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE code challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// withTimeout applies httpTimeout unless ctx already has a deadline
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, httpTimeout)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
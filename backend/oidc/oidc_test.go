@@ -0,0 +1,457 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "db-importer"
+	testClientSecret = "s3cr%t"
+	testRedirectURL  = "http://localhost:8080/auth/oidc/callback"
+)
+
+// mockProvider is a minimal OpenID provider: it authorizes every request,
+// checks PKCE and client authentication at the token endpoint and signs ID
+// tokens with a key it can rotate
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	keyID     string
+	key       crypto.Signer
+	method    jwt.SigningMethod
+	codes     map[string]authorization
+	jwksCalls int
+}
+
+// authorization is a code issued by the authorization endpoint
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	p := &mockProvider{t: t, codes: map[string]authorization{}}
+	p.rotateRSA("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256", "ES256"},
+		})
+	})
+	mux.HandleFunc("/jwks", p.serveJWKS)
+	mux.HandleFunc("/authorize", p.serveAuthorize)
+	mux.HandleFunc("/token", p.serveToken)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) rotateRSA(keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		p.t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	p.mu.Lock()
+	p.keyID, p.key, p.method = keyID, key, jwt.SigningMethodRS256
+	p.mu.Unlock()
+}
+
+func (p *mockProvider) rotateEC(keyID string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	p.mu.Lock()
+	p.keyID, p.key, p.method = keyID, key, jwt.SigningMethodES256
+	p.mu.Unlock()
+}
+
+func (p *mockProvider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jwksCalls++
+
+	jwk := map[string]string{"kid": p.keyID, "use": "sig"}
+	switch key := p.key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = encodeBigInt(key.N)
+		jwk["e"] = encodeBigInt(big.NewInt(int64(key.E)))
+	case *ecdsa.PublicKey:
+		jwk["kty"], jwk["crv"] = "EC", "P-256"
+		jwk["x"] = encodeBigInt(key.X)
+		jwk["y"] = encodeBigInt(key.Y)
+	}
+	encryption := map[string]string{"kid": "enc-1", "kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{encryption, jwk}})
+}
+
+func (p *mockProvider) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL ||
+		q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" ||
+		!strings.Contains(" "+q.Get("scope")+" ", " openid ") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := RandomString()
+	p.mu.Lock()
+	p.codes[code] = authorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	p.mu.Unlock()
+
+	target, _ := url.Parse(q.Get("redirect_uri"))
+	target.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *mockProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		tokenError("invalid_client")
+		return
+	}
+	user, _ = url.QueryUnescape(user)
+	password, _ = url.QueryUnescape(password)
+	if user != testClientID || password != testClientSecret {
+		tokenError("invalid_client")
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.mu.Unlock()
+	if !ok || r.FormValue("grant_type") != "authorization_code" || r.FormValue("redirect_uri") != testRedirectURL {
+		tokenError("invalid_grant")
+		return
+	}
+	if Challenge(r.FormValue("code_verifier")) != auth.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.sign(jwt.MapClaims{"nonce": auth.nonce}),
+	})
+}
+
+// sign issues an ID token for user-1 with the current key; claims override
+// the defaults
+func (p *mockProvider) sign(claims jwt.MapClaims) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"email":          "Jane.Doe@Example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	}
+	for name, value := range claims {
+		all[name] = value
+	}
+
+	token := jwt.NewWithClaims(p.method, all)
+	token.Header["kid"] = p.keyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Fatalf("Failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func (p *mockProvider) client(t *testing.T) *Client {
+	client, err := New(Config{
+		IssuerURL:    p.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client
+}
+
+// authorize follows the authorization URL and returns the code and state
+// the provider redirects back with
+func authorize(t *testing.T, authURL string) (code, state string) {
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(authURL)
+	if err != nil {
+		t.Fatalf("Authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Authorization returned status %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func TestClient_AuthorizationCodeFlow(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client(t)
+	ctx := context.Background()
+
+	state, nonce, verifier := RandomString(), RandomString(), RandomString()
+	authURL, err := client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	if strings.Contains(authURL, verifier) {
+		t.Error("Authorization URL must carry the challenge, not the verifier")
+	}
+
+	code, returnedState := authorize(t, authURL)
+	if returnedState != state {
+		t.Fatalf("State %q came back as %q", state, returnedState)
+	}
+
+	token, err := client.Authenticate(ctx, code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if token.Issuer != provider.server.URL || token.Subject != "user-1" {
+		t.Errorf("Unexpected issuer/subject %q/%q", token.Issuer, token.Subject)
+	}
+	if token.Expiry.Before(time.Now()) {
+		t.Errorf("Expiry %v is in the past", token.Expiry)
+	}
+
+	profile := ClaimMapping{}.Profile(token)
+	want := Profile{
+		Issuer:        provider.server.URL,
+		Subject:       "user-1",
+		Email:         "jane.doe@example.com",
+		EmailVerified: true,
+		FirstName:     "Jane",
+		LastName:      "Doe",
+	}
+	if profile != want {
+		t.Errorf("Profile = %+v, want %+v", profile, want)
+	}
+
+	// Codes are single use
+	if _, err := client.Authenticate(ctx, code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant for a reused code, got %v", err)
+	}
+}
+
+func TestClient_ExchangeRequiresVerifier(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client(t)
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state", "nonce", RandomString())
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	code, _ := authorize(t, authURL)
+
+	_, err = client.Exchange(ctx, code, RandomString())
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant for a wrong verifier, got %v", err)
+	}
+}
+
+func TestClient_VerifyRejectsInvalidTokens(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client(t)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": provider.server.URL, "sub": "user-1", "aud": testClientID, "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = "key-1"
+	forgedToken, _ := forged.SignedString(otherKey)
+
+	symmetric, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": provider.server.URL, "sub": "user-1", "aud": testClientID, "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(testClientSecret))
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss": provider.server.URL, "sub": "user-1", "aud": testClientID, "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong nonce", provider.sign(jwt.MapClaims{"nonce": "other"})},
+		{"missing nonce", provider.sign(nil)},
+		{"wrong audience", provider.sign(jwt.MapClaims{"nonce": "n", "aud": "someone-else"})},
+		{"other authorized party", provider.sign(jwt.MapClaims{"nonce": "n", "aud": []string{testClientID, "api"}, "azp": "api"})},
+		{"wrong issuer", provider.sign(jwt.MapClaims{"nonce": "n", "iss": "https://evil.example.com"})},
+		{"expired", provider.sign(jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()})},
+		{"no expiry", provider.sign(jwt.MapClaims{"nonce": "n", "exp": nil})},
+		{"no subject", provider.sign(jwt.MapClaims{"nonce": "n", "sub": ""})},
+		{"signed by another key", forgedToken},
+		{"symmetric algorithm", symmetric},
+		{"unsigned", unsigned},
+		{"garbage", "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Verify(ctx, tt.token, "n"); err == nil {
+				t.Error("Expected the token to be rejected")
+			}
+		})
+	}
+
+	if _, err := client.Verify(ctx, provider.sign(jwt.MapClaims{"nonce": "n", "aud": []string{testClientID, "api"}, "azp": testClientID}), "n"); err != nil {
+		t.Errorf("Token with several audiences issued to the client was rejected: %v", err)
+	}
+}
+
+func TestClient_VerifyFollowsKeyRotation(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client(t)
+	ctx := context.Background()
+
+	if _, err := client.Verify(ctx, provider.sign(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	// A token signed with a new key reloads the key set once the last
+	// fetch is older than the refresh interval
+	client.keys.fetchedAt = time.Now().Add(-minKeyRefresh)
+	provider.rotateEC("key-2")
+	if _, err := client.Verify(ctx, provider.sign(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("Verify after rotation failed: %v", err)
+	}
+
+	// Unknown keys do not reload the set again right away
+	provider.rotateRSA("key-3")
+	if _, err := client.Verify(ctx, provider.sign(jwt.MapClaims{"nonce": "n"}), "n"); err == nil {
+		t.Error("Expected an unknown key to be rejected within the refresh interval")
+	}
+	if provider.jwksCalls != 2 {
+		t.Errorf("Key set fetched %d times, want 2", provider.jwksCalls)
+	}
+}
+
+func TestClient_DiscoveryChecksIssuer(t *testing.T) {
+	provider := newMockProvider(t)
+
+	client, err := New(Config{
+		IssuerURL:   provider.server.URL + "/",
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := client.Discover(context.Background()); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected an issuer mismatch, got %v", err)
+	}
+}
+
+func TestNew_RequiresClientSettings(t *testing.T) {
+	valid := Config{IssuerURL: "https://idp.example.com", ClientID: "id", RedirectURL: testRedirectURL}
+	if _, err := New(valid); err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for name, config := range map[string]Config{
+		"issuer":   {ClientID: "id", RedirectURL: testRedirectURL},
+		"client":   {IssuerURL: "https://idp.example.com", RedirectURL: testRedirectURL},
+		"redirect": {IssuerURL: "https://idp.example.com", ClientID: "id"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("Expected an error without %s", name)
+		}
+	}
+
+	client, _ := New(Config{IssuerURL: "https://idp.example.com", ClientID: "id", RedirectURL: testRedirectURL, Scopes: []string{"email"}})
+	if strings.Join(client.config.Scopes, " ") != "openid email" {
+		t.Errorf("Scopes = %v, want openid added", client.config.Scopes)
+	}
+}
+
+func TestClaimMapping_Profile(t *testing.T) {
+	token := &IDToken{Issuer: "https://idp", Subject: "42", claims: jwt.MapClaims{
+		"upn":            "J.Smith@Corp.example",
+		"email_verified": "false",
+		"name":           "John Ronald Smith",
+	}}
+
+	profile := ClaimMapping{Email: "upn"}.Profile(token)
+	if profile.Email != "j.smith@corp.example" || profile.EmailVerified {
+		t.Errorf("Unexpected email %q verified=%v", profile.Email, profile.EmailVerified)
+	}
+	if profile.FirstName != "John" || profile.LastName != "Ronald Smith" {
+		t.Errorf("Name split into %q / %q", profile.FirstName, profile.LastName)
+	}
+
+	if !(ClaimMapping{Email: "upn", TrustEmail: true}).Profile(token).EmailVerified {
+		t.Error("TrustEmail should mark the email verified")
+	}
+
+	token.claims["email_verified"] = "TRUE"
+	if !(ClaimMapping{Email: "upn"}).Profile(token).EmailVerified {
+		t.Error("String email_verified claim was not read")
+	}
+
+	if (ClaimMapping{TrustEmail: true}).Profile(token).EmailVerified {
+		t.Error("A missing email must not count as verified")
+	}
+}
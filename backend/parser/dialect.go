@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour of a schema file
+type Dialect string
+
+const (
+	DialectUnknown   Dialect = ""
+	DialectMySQL     Dialect = "mysql"
+	DialectPostgres  Dialect = "postgresql"
+	DialectSQLServer Dialect = "sqlserver"
+	DialectSQLite    Dialect = "sqlite"
+)
+
+// SupportedDialects lists the dialects that can be detected or forced
+var SupportedDialects = []Dialect{DialectMySQL, DialectPostgres, DialectSQLServer, DialectSQLite}
+
+// DialectDetection is the outcome of scoring a schema file for dialect markers
+type DialectDetection struct {
+	Dialect    Dialect             `json:"dialect"`
+	Confidence float64             `json:"confidence"` // 0..1, share of the marker weight won by Dialect
+	Scores     map[Dialect]float64 `json:"scores,omitempty"`
+	Markers    []string            `json:"markers,omitempty"` // markers that matched for Dialect
+}
+
+// dialectMarker is a weighted pattern that hints at a specific dialect
+type dialectMarker struct {
+	dialect Dialect
+	name    string
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+var dialectMarkers = []dialectMarker{
+	// MySQL / MariaDB
+	{DialectMySQL, "backtick identifiers", regexp.MustCompile("`[A-Za-z0-9_$]+`"), 1},
+	{DialectMySQL, "ENGINE=", regexp.MustCompile(`(?i)\)\s*ENGINE\s*=`), 4},
+	{DialectMySQL, "AUTO_INCREMENT", regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`), 3},
+	{DialectMySQL, "DEFAULT CHARSET", regexp.MustCompile(`(?i)DEFAULT\s+CHARSET\s*=`), 3},
+	{DialectMySQL, "UNSIGNED", regexp.MustCompile(`(?i)\bUNSIGNED\b`), 2},
+	{DialectMySQL, "conditional comment", regexp.MustCompile(`/\*!\d+`), 3},
+
+	// PostgreSQL
+	{DialectPostgres, "SERIAL", regexp.MustCompile(`(?i)\b(BIG|SMALL)?SERIAL\b`), 4},
+	{DialectPostgres, ":: cast", regexp.MustCompile(`[A-Za-z0-9_')\]]::[A-Za-z]`), 3},
+	{DialectPostgres, "$$ body", regexp.MustCompile(`\$\$|\$[A-Za-z_]+\$`), 3},
+	{DialectPostgres, "nextval()", regexp.MustCompile(`(?i)\bnextval\s*\(`), 3},
+	{DialectPostgres, "OWNER TO", regexp.MustCompile(`(?i)\bOWNER\s+TO\b`), 3},
+	{DialectPostgres, "CREATE EXTENSION", regexp.MustCompile(`(?i)\bCREATE\s+EXTENSION\b`), 3},
+	{DialectPostgres, "TIMESTAMPTZ/JSONB", regexp.MustCompile(`(?i)\b(TIMESTAMPTZ|JSONB|BYTEA)\b`), 2},
+
+	// SQL Server
+	{DialectSQLServer, "GO batch separator", regexp.MustCompile(`(?im)^\s*GO\s*;?\s*$`), 4},
+	{DialectSQLServer, "[dbo] schema", regexp.MustCompile(`(?i)\[dbo\]`), 4},
+	{DialectSQLServer, "bracket identifiers", regexp.MustCompile(`\[[A-Za-z0-9_ ]+\]`), 1},
+	{DialectSQLServer, "IDENTITY()", regexp.MustCompile(`(?i)\bIDENTITY\s*\(`), 3},
+	{DialectSQLServer, "NVARCHAR", regexp.MustCompile(`(?i)\bN(VAR)?CHAR\s*\(`), 2},
+	{DialectSQLServer, "SET ANSI_NULLS", regexp.MustCompile(`(?i)\bSET\s+(ANSI_NULLS|QUOTED_IDENTIFIER)\b`), 3},
+
+	// SQLite
+	{DialectSQLite, "AUTOINCREMENT", regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`), 3},
+	{DialectSQLite, "PRAGMA", regexp.MustCompile(`(?im)^\s*PRAGMA\b`), 4},
+	{DialectSQLite, "WITHOUT ROWID", regexp.MustCompile(`(?i)\bWITHOUT\s+ROWID\b`), 4},
+	{DialectSQLite, "sqlite_sequence", regexp.MustCompile(`(?i)\bsqlite_sequence\b`), 4},
+}
+
+// maxMarkerHits caps how often a single marker may count, so a dump with
+// hundreds of backticked columns does not drown out rarer, stronger markers
+const maxMarkerHits = 5
+
+// DetectDialect scores SQL content for dialect-specific markers and returns
+// the most likely dialect together with a confidence between 0 and 1.
+// Content without any marker yields DialectUnknown and zero confidence.
+func DetectDialect(content string) DialectDetection {
+	scores := make(map[Dialect]float64)
+	matched := make(map[Dialect][]string)
+
+	for _, marker := range dialectMarkers {
+		hits := len(marker.pattern.FindAllStringIndex(content, maxMarkerHits))
+		if hits == 0 {
+			continue
+		}
+		scores[marker.dialect] += marker.weight * float64(hits)
+		matched[marker.dialect] = append(matched[marker.dialect], marker.name)
+	}
+
+	var total float64
+	for _, score := range scores {
+		total += score
+	}
+	if total == 0 {
+		return DialectDetection{Dialect: DialectUnknown}
+	}
+
+	// Pick the best score; iterate in a fixed order so ties are deterministic
+	best := DialectUnknown
+	for _, d := range SupportedDialects {
+		if scores[d] > scores[best] {
+			best = d
+		}
+	}
+
+	return DialectDetection{
+		Dialect:    best,
+		Confidence: roundConfidence(scores[best] / total),
+		Scores:     scores,
+		Markers:    matched[best],
+	}
+}
+
+// roundConfidence keeps two decimals so responses stay readable
+func roundConfidence(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
+
+// ParseDialect converts a user supplied dialect name (including common
+// aliases such as "postgres", "mariadb" or "mssql") into a Dialect
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return DialectUnknown, nil
+	case "mysql", "mariadb", "tidb":
+		return DialectMySQL, nil
+	case "postgresql", "postgres", "pg", "pgsql":
+		return DialectPostgres, nil
+	case "sqlserver", "mssql", "tsql", "t-sql":
+		return DialectSQLServer, nil
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	}
+
+	names := make([]string, len(SupportedDialects))
+	for i, d := range SupportedDialects {
+		names[i] = string(d)
+	}
+	sort.Strings(names)
+	return DialectUnknown, fmt.Errorf("unsupported dialect %q (expected one of: %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDetectDialect_MySQL(t *testing.T) {
+	sql := "CREATE TABLE `users` (\n" +
+		"  `id` int(11) UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
+		"  `email` varchar(255) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;"
+
+	result := DetectDialect(sql)
+
+	if result.Dialect != DialectMySQL {
+		t.Fatalf("Expected dialect mysql, got %q", result.Dialect)
+	}
+	if result.Confidence < 0.9 {
+		t.Errorf("Expected high confidence for an unambiguous MySQL dump, got %v", result.Confidence)
+	}
+	if len(result.Markers) == 0 {
+		t.Errorf("Expected matched markers to be reported")
+	}
+}
+
+func TestDetectDialect_PostgreSQL(t *testing.T) {
+	sql := `CREATE TABLE public.orders (
+		id BIGSERIAL NOT NULL,
+		status VARCHAR(20) DEFAULT 'new'::character varying,
+		payload JSONB
+	);
+	ALTER TABLE public.orders OWNER TO postgres;`
+
+	result := DetectDialect(sql)
+
+	if result.Dialect != DialectPostgres {
+		t.Fatalf("Expected dialect postgresql, got %q", result.Dialect)
+	}
+	if result.Confidence != 1 {
+		t.Errorf("Expected confidence 1 with only PostgreSQL markers, got %v", result.Confidence)
+	}
+}
+
+func TestDetectDialect_SQLServer(t *testing.T) {
+	sql := `SET ANSI_NULLS ON
+GO
+CREATE TABLE [dbo].[Customers] (
+	[Id] INT IDENTITY(1,1) NOT NULL,
+	[Name] NVARCHAR(100) NOT NULL
+)
+GO`
+
+	result := DetectDialect(sql)
+
+	if result.Dialect != DialectSQLServer {
+		t.Fatalf("Expected dialect sqlserver, got %q", result.Dialect)
+	}
+}
+
+func TestDetectDialect_SQLite(t *testing.T) {
+	sql := `PRAGMA foreign_keys=OFF;
+CREATE TABLE notes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	body TEXT
+);`
+
+	result := DetectDialect(sql)
+
+	if result.Dialect != DialectSQLite {
+		t.Fatalf("Expected dialect sqlite, got %q", result.Dialect)
+	}
+}
+
+func TestDetectDialect_SQLitePragmaAfterCreateTable(t *testing.T) {
+	sql := `CREATE TABLE notes (
+	id INTEGER PRIMARY KEY,
+	body TEXT
+);
+PRAGMA foreign_keys=ON;`
+
+	result := DetectDialect(sql)
+
+	if result.Dialect != DialectSQLite {
+		t.Fatalf("Expected dialect sqlite, got %q", result.Dialect)
+	}
+	if len(result.Markers) != 1 || result.Markers[0] != "PRAGMA" {
+		t.Errorf("Expected the PRAGMA marker to match, got %v", result.Markers)
+	}
+}
+
+func TestDetectDialect_NoMarkers(t *testing.T) {
+	result := DetectDialect("CREATE TABLE t (id INT, name VARCHAR(10));")
+
+	if result.Dialect != DialectUnknown {
+		t.Errorf("Expected unknown dialect, got %q", result.Dialect)
+	}
+	if result.Confidence != 0 {
+		t.Errorf("Expected zero confidence, got %v", result.Confidence)
+	}
+}
+
+func TestDetectDialect_MixedMarkersLowerConfidence(t *testing.T) {
+	// Backticks point to MySQL but SERIAL points to PostgreSQL
+	sql := "CREATE TABLE `t` (id SERIAL, `name` TEXT);"
+
+	result := DetectDialect(sql)
+
+	if result.Confidence >= 1 {
+		t.Errorf("Expected confidence below 1 for mixed markers, got %v", result.Confidence)
+	}
+	if len(result.Scores) != 2 {
+		t.Errorf("Expected scores for 2 dialects, got %v", result.Scores)
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Dialect
+		wantErr  bool
+	}{
+		{"", DialectUnknown, false},
+		{"mysql", DialectMySQL, false},
+		{"MariaDB", DialectMySQL, false},
+		{"postgres", DialectPostgres, false},
+		{"postgresql", DialectPostgres, false},
+		{"mssql", DialectSQLServer, false},
+		{"sqlite3", DialectSQLite, false},
+		{"oracle", DialectUnknown, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseDialect(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDialect(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if result != tt.expected {
+			t.Errorf("ParseDialect(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSONSchemaParser maps JSON Schema object definitions to tables: the root
+// schema (when it has properties) and every entry of $defs/definitions.
+//
+// Relational details that JSON Schema cannot express are read from
+// extension keywords on properties: x-primary-key, x-unique,
+// x-auto-increment, x-column-name and x-references ("table.column").
+// A property named "id" is the primary key when none is marked.
+type JSONSchemaParser struct {
+	dialect Dialect
+}
+
+// NewJSONSchemaParser creates a JSON Schema parser targeting MySQL
+func NewJSONSchemaParser() *JSONSchemaParser {
+	return &JSONSchemaParser{}
+}
+
+// Name implements SchemaParser
+func (p *JSONSchemaParser) Name() string { return "jsonschema" }
+
+// Description implements FormatDescriber
+func (p *JSONSchemaParser) Description() string {
+	return "JSON Schema object definitions (root schema, $defs and definitions)"
+}
+
+// Extensions implements FormatDescriber
+func (p *JSONSchemaParser) Extensions() []string { return []string{".json"} }
+
+// Detect implements SchemaParser
+func (p *JSONSchemaParser) Detect(content string) float64 {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") {
+		return 0
+	}
+	if strings.Contains(trimmed, "json-schema.org") {
+		return 0.95
+	}
+	if strings.Contains(trimmed, `"properties"`) {
+		return 0.7
+	}
+	return 0
+}
+
+// ForDialect implements DialectTargeter
+func (p *JSONSchemaParser) ForDialect(dialect Dialect) SchemaParser {
+	return &JSONSchemaParser{dialect: dialect}
+}
+
+// DefaultDialect implements DialectTargeter; JSON Schema has no notion of
+// a database, so types are rendered for the generator's default
+func (p *JSONSchemaParser) DefaultDialect(string) Dialect { return DialectMySQL }
+
+// jsonObject is a decoded JSON object that remembers key order, so columns
+// keep the order of the properties in the document
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) get(key string) interface{} {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+func (o *jsonObject) object(key string) *jsonObject {
+	obj, _ := o.get(key).(*jsonObject)
+	return obj
+}
+
+func (o *jsonObject) string(key string) string {
+	s, _ := o.get(key).(string)
+	return s
+}
+
+func (o *jsonObject) bool(key string) bool {
+	b, _ := o.get(key).(bool)
+	return b
+}
+
+// number returns a numeric keyword and whether it was present
+func (o *jsonObject) number(key string) (float64, bool) {
+	n, ok := o.get(key).(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// Parse implements SchemaParser
+func (p *JSONSchemaParser) Parse(r io.Reader) ([]Table, []Diagnostic, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	value, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	root, ok := value.(*jsonObject)
+	if !ok {
+		return nil, nil, fmt.Errorf("JSON Schema document must be an object")
+	}
+
+	dialect := p.dialect
+	if dialect == DialectUnknown {
+		dialect = p.DefaultDialect("")
+	}
+
+	definitions := root.object("$defs")
+	if definitions == nil {
+		definitions = root.object("definitions")
+	}
+
+	var tables []Table
+	var diagnostics []Diagnostic
+
+	addTable := func(name string, schema *jsonObject) {
+		table, diags := jsonSchemaTable(name, schema, definitions, dialect)
+		diagnostics = append(diagnostics, diags...)
+		if len(table.Fields) > 0 {
+			tables = append(tables, table)
+		}
+	}
+
+	if root.object("properties") != nil {
+		name := root.string("x-table-name")
+		if name == "" {
+			name = root.string("title")
+		}
+		if name == "" {
+			name = "root"
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityInfo,
+				Message:  `root schema has no title, table named "root"`,
+			})
+		}
+		addTable(name, root)
+	}
+
+	if definitions != nil {
+		for _, key := range definitions.keys {
+			schema := definitions.object(key)
+			if schema.object("properties") == nil {
+				continue // enums and other reusable property types
+			}
+			name := schema.string("x-table-name")
+			if name == "" {
+				name = key
+			}
+			addTable(name, schema)
+		}
+	}
+
+	return tables, diagnostics, nil
+}
+
+// jsonSchemaTable converts one object schema to a table
+func jsonSchemaTable(name string, schema, definitions *jsonObject, dialect Dialect) (Table, []Diagnostic) {
+	table := Table{Name: name}
+	var diagnostics []Diagnostic
+
+	required := make(map[string]bool)
+	if list, ok := schema.get("required").([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	properties := schema.object("properties")
+	hasPrimaryKey := false
+	idColumn := -1
+
+	for _, propName := range properties.keys {
+		prop := properties.object(propName)
+		if prop == nil {
+			continue
+		}
+
+		field := Field{Name: propName}
+		if column := prop.string("x-column-name"); column != "" {
+			field.Name = column
+		}
+
+		// Resolve local $refs; object definitions are tables of their own
+		resolved := prop
+		if ref := prop.string("$ref"); ref != "" {
+			target := resolveJSONRef(ref, definitions)
+			if target == nil {
+				diagnostics = append(diagnostics, Diagnostic{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("property %s: unresolved reference %s, stored as JSON", propName, ref),
+					Table:    name,
+				})
+			}
+			resolved = target
+		}
+
+		logical, nullable := jsonSchemaType(resolved)
+		field.Type = logical.SQLType(dialect)
+		field.Nullable = nullable || !required[propName] || prop.bool("nullable")
+
+		if prop.bool("x-primary-key") {
+			field.PrimaryKey = true
+			hasPrimaryKey = true
+		}
+		if propName == "id" {
+			idColumn = len(table.Fields)
+		}
+		field.Unique = prop.bool("x-unique")
+		field.AutoIncrement = prop.bool("x-auto-increment")
+
+		if def, ok := jsonSchemaDefault(prop.get("default"), logical); ok {
+			field.Default = &def
+		}
+
+		if ref := prop.string("x-references"); ref != "" {
+			if dot := strings.LastIndex(ref, "."); dot > 0 {
+				table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+					Columns:    []string{field.Name},
+					RefTable:   ref[:dot],
+					RefColumns: []string{ref[dot+1:]},
+				})
+			} else {
+				diagnostics = append(diagnostics, Diagnostic{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("property %s: x-references must look like \"table.column\"", propName),
+					Table:    name,
+				})
+			}
+		}
+
+		table.Fields = append(table.Fields, field)
+	}
+
+	if !hasPrimaryKey && idColumn >= 0 {
+		table.Fields[idColumn].PrimaryKey = true
+	}
+	for i := range table.Fields {
+		if table.Fields[i].PrimaryKey {
+			table.Fields[i].Nullable = false
+		}
+	}
+
+	return table, diagnostics
+}
+
+// jsonSchemaType maps type/format/enum keywords to a logical type; the
+// second result reports whether "null" is one of the allowed types
+func jsonSchemaType(schema *jsonObject) (LogicalType, bool) {
+	if schema == nil {
+		return LogicalType{Kind: TypeJSON}, false
+	}
+
+	var typeName string
+	nullable := false
+	switch t := schema.get("type").(type) {
+	case string:
+		typeName = t
+	case []interface{}:
+		for _, item := range t {
+			if s, _ := item.(string); s == "null" {
+				nullable = true
+			} else if typeName == "" {
+				typeName = s
+			}
+		}
+	}
+
+	if values, ok := schema.get("enum").([]interface{}); ok && (typeName == "" || typeName == "string") {
+		var strs []string
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				strs = append(strs, s)
+			} else if v == nil {
+				nullable = true
+			}
+		}
+		if len(strs) > 0 {
+			return LogicalType{Kind: TypeEnum, Values: strs}, nullable
+		}
+	}
+
+	switch typeName {
+	case "string":
+		switch schema.string("format") {
+		case "date-time":
+			return LogicalType{Kind: TypeDateTime}, nullable
+		case "date":
+			return LogicalType{Kind: TypeDate}, nullable
+		case "time":
+			return LogicalType{Kind: TypeTime}, nullable
+		case "uuid":
+			return LogicalType{Kind: TypeUUID}, nullable
+		}
+		if schema.string("contentEncoding") == "base64" {
+			return LogicalType{Kind: TypeBinary}, nullable
+		}
+		if maxLength, ok := schema.number("maxLength"); ok && maxLength > 0 && maxLength <= 65535 {
+			return LogicalType{Kind: TypeString, Length: int(maxLength)}, nullable
+		}
+		return LogicalType{Kind: TypeText}, nullable
+
+	case "integer":
+		return LogicalType{Kind: TypeInteger, Bytes: jsonSchemaIntegerBytes(schema)}, nullable
+
+	case "number":
+		if step, ok := schema.number("multipleOf"); ok && step > 0 && step < 1 {
+			scale := int(math.Round(-math.Log10(step)))
+			return LogicalType{Kind: TypeDecimal, Precision: max(18, scale+1), Scale: scale}, nullable
+		}
+		return LogicalType{Kind: TypeFloat}, nullable
+
+	case "boolean":
+		return LogicalType{Kind: TypeBoolean}, nullable
+
+	case "array":
+		if items := schema.object("items"); items != nil && items.get("$ref") == nil {
+			element, _ := jsonSchemaType(items)
+			if element.Kind != TypeJSON && element.Kind != TypeEnum {
+				element.Array = true
+				return element, nullable
+			}
+		}
+		return LogicalType{Kind: TypeJSON}, nullable
+	}
+
+	return LogicalType{Kind: TypeJSON}, nullable
+}
+
+// jsonSchemaIntegerBytes sizes an integer column from format or bounds
+func jsonSchemaIntegerBytes(schema *jsonObject) int {
+	switch schema.string("format") {
+	case "int64":
+		return 8
+	case "int32":
+		return 4
+	case "int16":
+		return 2
+	}
+
+	minimum, hasMin := schema.number("minimum")
+	maximum, hasMax := schema.number("maximum")
+	switch {
+	case (hasMin && minimum < math.MinInt32) || (hasMax && maximum > math.MaxInt32):
+		return 8
+	case hasMin && hasMax && minimum >= math.MinInt16 && maximum <= math.MaxInt16:
+		return 2
+	}
+	return 4
+}
+
+// jsonSchemaDefault renders a "default" keyword as a SQL default expression
+func jsonSchemaDefault(value interface{}, logical LogicalType) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return QuoteLiteral(v), true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if logical.Kind == TypeBoolean {
+			return strings.ToUpper(strconv.FormatBool(v)), true
+		}
+	}
+	return "", false
+}
+
+// resolveJSONRef resolves "#/$defs/Name" and "#/definitions/Name"
+func resolveJSONRef(ref string, definitions *jsonObject) *jsonObject {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return definitions.object(strings.TrimPrefix(ref, prefix))
+		}
+	}
+	return nil
+}
+
+// decodeOrdered decodes the next JSON value, using *jsonObject for objects
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch delim := token.(type) {
+	case json.Delim:
+		switch delim {
+		case '{':
+			obj := &jsonObject{values: make(map[string]interface{})}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := keyToken.(string)
+				value, err := decodeOrdered(decoder)
+				if err != nil {
+					return nil, err
+				}
+				if _, seen := obj.values[key]; !seen {
+					obj.keys = append(obj.keys, key)
+				}
+				obj.values[key] = value
+			}
+			_, err := decoder.Token() // closing brace
+			return obj, err
+
+		case '[':
+			var list []interface{}
+			for decoder.More() {
+				value, err := decodeOrdered(decoder)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := decoder.Token() // closing bracket
+			if list == nil {
+				list = []interface{}{}
+			}
+			return list, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %q", delim)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const jsonSchemaFixture = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "orders",
+  "type": "object",
+  "required": ["id", "total", "status"],
+  "properties": {
+    "id": {"type": "integer", "x-auto-increment": true},
+    "reference": {"type": "string", "maxLength": 40, "x-unique": true},
+    "total": {"type": "number", "multipleOf": 0.01},
+    "status": {"type": "string", "enum": ["open", "paid"], "default": "open"},
+    "placedAt": {"type": ["string", "null"], "format": "date-time", "x-column-name": "placed_at"},
+    "customerId": {"type": "integer", "format": "int64", "x-references": "customers.id"},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "address": {"$ref": "#/$defs/address"},
+    "note": {"$ref": "#/$defs/missing"}
+  },
+  "$defs": {
+    "customers": {
+      "type": "object",
+      "required": ["email"],
+      "properties": {
+        "uuid": {"type": "string", "format": "uuid", "x-primary-key": true},
+        "email": {"type": "string", "maxLength": 320},
+        "active": {"type": "boolean", "default": true}
+      }
+    },
+    "address": {
+      "type": "object",
+      "properties": {"street": {"type": "string"}}
+    }
+  }
+}`
+
+func TestJSONSchemaParser_ParsesRootAndDefinitions(t *testing.T) {
+	p := NewJSONSchemaParser()
+
+	if score := p.Detect(jsonSchemaFixture); score < 0.9 {
+		t.Errorf("Expected high detection score, got %v", score)
+	}
+
+	tables, diagnostics, err := p.Parse(strings.NewReader(jsonSchemaFixture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tables) != 3 {
+		t.Fatalf("Expected orders, customers and address tables, got %d", len(tables))
+	}
+
+	orders := tables[0]
+	if orders.Name != "orders" {
+		t.Errorf("Expected root table named after title, got %q", orders.Name)
+	}
+
+	// Property order must be preserved
+	expectedOrder := []string{"id", "reference", "total", "status", "placed_at", "customerId", "tags", "address", "note"}
+	for i, name := range expectedOrder {
+		if orders.Fields[i].Name != name {
+			t.Fatalf("Expected field %d to be %s, got %s", i, name, orders.Fields[i].Name)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		typ      string
+		nullable bool
+	}{
+		{"id", "INT", false},
+		{"reference", "VARCHAR(40)", true},
+		{"total", "DECIMAL(18,2)", false},
+		{"status", "ENUM('open','paid')", false},
+		{"placed_at", "DATETIME", true},
+		{"customerId", "BIGINT", true},
+		{"tags", "JSON", true},
+		{"address", "JSON", true},
+		{"note", "JSON", true},
+	}
+	for _, tt := range tests {
+		f := findField(t, orders, tt.name)
+		if f.Type != tt.typ || f.Nullable != tt.nullable {
+			t.Errorf("%s: expected %s nullable=%v, got %s nullable=%v", tt.name, tt.typ, tt.nullable, f.Type, f.Nullable)
+		}
+	}
+
+	if id := findField(t, orders, "id"); !id.PrimaryKey || !id.AutoIncrement {
+		t.Errorf("Expected id to be an auto-increment primary key, got %+v", id)
+	}
+	if ref := findField(t, orders, "reference"); !ref.Unique {
+		t.Errorf("Expected reference to be unique")
+	}
+	if status := findField(t, orders, "status"); status.Default == nil || *status.Default != "'open'" {
+		t.Errorf("Expected default 'open', got %+v", status.Default)
+	}
+	if len(orders.ForeignKeys) != 1 || orders.ForeignKeys[0].RefTable != "customers" || orders.ForeignKeys[0].RefColumns[0] != "id" {
+		t.Errorf("Unexpected foreign keys: %+v", orders.ForeignKeys)
+	}
+
+	customers := tables[1]
+	if pk := customers.PrimaryKey(); len(pk) != 1 || pk[0] != "uuid" {
+		t.Errorf("Expected x-primary-key uuid, got %v", pk)
+	}
+	if active := findField(t, customers, "active"); active.Default == nil || *active.Default != "TRUE" {
+		t.Errorf("Expected TRUE default, got %+v", active.Default)
+	}
+
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "#/$defs/missing") {
+		t.Errorf("Expected one diagnostic for the unresolved reference, got %+v", diagnostics)
+	}
+}
+
+func TestJSONSchemaParser_ForDialect(t *testing.T) {
+	p := NewJSONSchemaParser().ForDialect(DialectPostgres)
+
+	tables, _, err := p.Parse(strings.NewReader(jsonSchemaFixture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tags := findField(t, tables[0], "tags"); tags.Type != "TEXT[]" {
+		t.Errorf("Expected TEXT[] on postgresql, got %q", tags.Type)
+	}
+	if uuid := findField(t, tables[1], "uuid"); uuid.Type != "UUID" {
+		t.Errorf("Expected UUID on postgresql, got %q", uuid.Type)
+	}
+}
+
+func TestJSONSchemaParser_RejectsInvalidJSON(t *testing.T) {
+	if _, _, err := NewJSONSchemaParser().Parse(strings.NewReader(`{"properties": `)); err == nil {
+		t.Error("Expected an error for truncated JSON")
+	}
+	if score := NewJSONSchemaParser().Detect("CREATE TABLE users (id INT);"); score != 0 {
+		t.Errorf("Expected SQL not to be detected as JSON Schema, got %v", score)
+	}
+}
@@ -10,12 +10,40 @@ type Field struct {
 	Name     string `json:"name"`
 	Type     string `json:"type"`
 	Nullable bool   `json:"nullable"`
+
+	// Optional key and default information (not every parser fills these)
+	PrimaryKey    bool    `json:"primaryKey,omitempty"`
+	Unique        bool    `json:"unique,omitempty"`
+	AutoIncrement bool    `json:"autoIncrement,omitempty"`
+	Default       *string `json:"default,omitempty"` // SQL expression, e.g. 'draft' or CURRENT_TIMESTAMP
+}
+
+// ForeignKey represents a reference from columns of a table to another table
+type ForeignKey struct {
+	Name       string   `json:"name,omitempty"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"refTable"`
+	RefColumns []string `json:"refColumns"`
+	OnDelete   string   `json:"onDelete,omitempty"`
+	OnUpdate   string   `json:"onUpdate,omitempty"`
 }
 
 // Table represents a database table
 type Table struct {
-	Name   string  `json:"name"`
-	Fields []Field `json:"fields"`
+	Name        string       `json:"name"`
+	Fields      []Field      `json:"fields"`
+	ForeignKeys []ForeignKey `json:"foreignKeys,omitempty"`
+}
+
+// PrimaryKey returns the names of the primary key columns in field order
+func (t Table) PrimaryKey() []string {
+	var columns []string
+	for _, f := range t.Fields {
+		if f.PrimaryKey {
+			columns = append(columns, f.Name)
+		}
+	}
+	return columns
 }
 
 // ParseMySQL parses MySQL/MariaDB CREATE TABLE statements
@@ -0,0 +1,590 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PrismaParser reads `schema.prisma` files and maps models to tables.
+// Column types follow the target dialect: a forced dialect wins, otherwise
+// the provider of the datasource block is used (MySQL when absent).
+type PrismaParser struct {
+	dialect Dialect
+}
+
+// NewPrismaParser creates a Prisma parser that targets the datasource provider
+func NewPrismaParser() *PrismaParser {
+	return &PrismaParser{}
+}
+
+var (
+	prismaModelMarker      = regexp.MustCompile(`(?m)^\s*model\s+\w+\s*\{`)
+	prismaDatasourceMarker = regexp.MustCompile(`(?m)^\s*(datasource|generator)\s+\w+\s*\{`)
+	prismaBlockStart       = regexp.MustCompile(`(?m)^\s*(model|enum|type|view)\s+(\w+)\s*\{`)
+	prismaProvider         = regexp.MustCompile(`(?s)datasource\s+\w+\s*\{[^}]*provider\s*=\s*"(\w+)"`)
+	prismaAttrArgs         = regexp.MustCompile(`^\w+\s*:\s*`)
+)
+
+// Name implements SchemaParser
+func (p *PrismaParser) Name() string { return "prisma" }
+
+// Description implements FormatDescriber
+func (p *PrismaParser) Description() string {
+	return "Prisma schema models (@id, @default, @unique, @map, relations)"
+}
+
+// Extensions implements FormatDescriber
+func (p *PrismaParser) Extensions() []string { return []string{".prisma"} }
+
+// Detect implements SchemaParser
+func (p *PrismaParser) Detect(content string) float64 {
+	if !prismaModelMarker.MatchString(content) {
+		return 0
+	}
+	if prismaDatasourceMarker.MatchString(content) {
+		return 0.95
+	}
+	return 0.8
+}
+
+// ForDialect implements DialectTargeter
+func (p *PrismaParser) ForDialect(dialect Dialect) SchemaParser {
+	return &PrismaParser{dialect: dialect}
+}
+
+// DefaultDialect implements DialectTargeter using the datasource provider
+func (p *PrismaParser) DefaultDialect(content string) Dialect {
+	if m := prismaProvider.FindStringSubmatch(content); len(m) > 1 {
+		switch m[1] {
+		case "cockroachdb":
+			return DialectPostgres
+		default:
+			if d, err := ParseDialect(m[1]); err == nil && d != DialectUnknown {
+				return d
+			}
+		}
+	}
+	return DialectMySQL
+}
+
+// prismaBlock is a model/enum/type block before relations are resolved
+type prismaBlock struct {
+	kind  string
+	name  string
+	lines []string
+}
+
+// prismaField is a parsed model field line
+type prismaField struct {
+	name     string
+	typeName string
+	optional bool
+	list     bool
+	attrs    []prismaAttr
+}
+
+// prismaAttr is a single @attribute or @@attribute with its raw arguments
+type prismaAttr struct {
+	name string // without the leading @ / @@
+	args string // text between the parentheses, empty when absent
+}
+
+// Parse implements SchemaParser
+func (p *PrismaParser) Parse(r io.Reader) ([]Table, []Diagnostic, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	content := stripPrismaComments(string(raw))
+
+	provider := p.DefaultDialect(content)
+	dialect := p.dialect
+	if dialect == DialectUnknown {
+		dialect = provider
+	}
+	// Native @db.* types are provider specific, only keep them when they apply
+	useNativeTypes := dialect == provider
+
+	blocks := splitPrismaBlocks(content)
+	enums := make(map[string][]string)
+	composites := make(map[string]bool)
+	models := make(map[string]*prismaBlock)
+	var modelOrder []string
+
+	for i := range blocks {
+		b := &blocks[i]
+		switch b.kind {
+		case "enum":
+			for _, line := range b.lines {
+				if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "@") {
+					enums[b.name] = append(enums[b.name], fields[0])
+				}
+			}
+		case "type":
+			composites[b.name] = true
+		case "model", "view":
+			models[b.name] = b
+			modelOrder = append(modelOrder, b.name)
+		}
+	}
+
+	// Resolve table and column names first: relations reference Prisma
+	// field names, which may be remapped with @map / @@map
+	tableNames := make(map[string]string)
+	columnNames := make(map[string]map[string]string)
+	for _, name := range modelOrder {
+		tableNames[name] = name
+		columnNames[name] = make(map[string]string)
+		for _, line := range models[name].lines {
+			if strings.HasPrefix(line, "@@") {
+				if attr := parsePrismaAttrs(line); len(attr) > 0 && attr[0].name == "@map" {
+					tableNames[name] = unquotePrisma(attr[0].args)
+				}
+				continue
+			}
+			field, ok := parsePrismaField(line)
+			if !ok {
+				continue
+			}
+			column := field.name
+			for _, a := range field.attrs {
+				if a.name == "map" {
+					column = unquotePrisma(a.args)
+				}
+			}
+			columnNames[name][field.name] = column
+		}
+	}
+
+	var tables []Table
+	var diagnostics []Diagnostic
+
+	for _, modelName := range modelOrder {
+		block := models[modelName]
+		table := Table{Name: tableNames[modelName]}
+		ignored := false
+		var compositeID []string
+
+		for _, line := range block.lines {
+			if strings.HasPrefix(line, "@@") {
+				for _, attr := range parsePrismaAttrs(line) {
+					switch attr.name {
+					case "@ignore":
+						ignored = true
+					case "@id":
+						compositeID = parsePrismaList(attr.args)
+					case "@unique":
+						diagnostics = append(diagnostics, Diagnostic{
+							Severity: SeverityInfo,
+							Message:  "composite unique constraint " + attr.args + " is not represented",
+							Table:    table.Name,
+						})
+					}
+				}
+				continue
+			}
+
+			field, ok := parsePrismaField(line)
+			if !ok {
+				continue
+			}
+
+			// Relation fields carry foreign keys but are not columns themselves
+			if _, isModel := models[field.typeName]; isModel {
+				if fk, ok := prismaForeignKey(field, columnNames[modelName], tableNames, columnNames); ok {
+					table.ForeignKeys = append(table.ForeignKeys, fk)
+				}
+				continue
+			}
+
+			column, skip, diags := p.prismaColumn(field, dialect, useNativeTypes, enums, composites)
+			for i := range diags {
+				diags[i].Table = table.Name
+			}
+			diagnostics = append(diagnostics, diags...)
+			if skip {
+				continue
+			}
+			column.Name = columnNames[modelName][field.name]
+			table.Fields = append(table.Fields, column)
+		}
+
+		if ignored {
+			continue
+		}
+
+		for _, fieldName := range compositeID {
+			column := columnNames[modelName][fieldName]
+			for i := range table.Fields {
+				if table.Fields[i].Name == column {
+					table.Fields[i].PrimaryKey = true
+					table.Fields[i].Nullable = false
+				}
+			}
+		}
+
+		if len(table.Fields) > 0 {
+			tables = append(tables, table)
+		}
+	}
+
+	return tables, diagnostics, nil
+}
+
+// prismaColumn converts a scalar/enum field to a column; skip reports fields
+// that must not become columns (e.g. @ignore)
+func (p *PrismaParser) prismaColumn(field prismaField, dialect Dialect, useNativeTypes bool, enums map[string][]string, composites map[string]bool) (Field, bool, []Diagnostic) {
+	var diagnostics []Diagnostic
+	column := Field{Nullable: field.optional}
+
+	var logical LogicalType
+	switch field.typeName {
+	case "String":
+		logical = LogicalType{Kind: TypeString, Length: 191}
+		switch dialect {
+		case DialectPostgres, DialectSQLite:
+			logical = LogicalType{Kind: TypeText}
+		case DialectSQLServer:
+			logical = LogicalType{Kind: TypeString, Length: 1000}
+		}
+	case "Boolean":
+		logical = LogicalType{Kind: TypeBoolean}
+	case "Int":
+		logical = LogicalType{Kind: TypeInteger, Bytes: 4}
+	case "BigInt":
+		logical = LogicalType{Kind: TypeInteger, Bytes: 8}
+	case "Float":
+		logical = LogicalType{Kind: TypeFloat}
+	case "Decimal":
+		logical = LogicalType{Kind: TypeDecimal}
+	case "DateTime":
+		logical = LogicalType{Kind: TypeDateTime}
+	case "Json":
+		logical = LogicalType{Kind: TypeJSON}
+	case "Bytes":
+		logical = LogicalType{Kind: TypeBinary}
+	default:
+		if values, ok := enums[field.typeName]; ok {
+			logical = LogicalType{Kind: TypeEnum, Values: values}
+		} else if composites[field.typeName] {
+			logical = LogicalType{Kind: TypeJSON}
+		} else if strings.HasPrefix(field.typeName, "Unsupported(") {
+			column.Type = unquotePrisma(strings.TrimSuffix(strings.TrimPrefix(field.typeName, "Unsupported("), ")"))
+		} else {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("unknown type %q for field %s, using text", field.typeName, field.name),
+			})
+			logical = LogicalType{Kind: TypeText}
+		}
+	}
+	logical.Array = field.list
+	if column.Type == "" {
+		column.Type = logical.SQLType(dialect)
+	}
+
+	for _, attr := range field.attrs {
+		switch {
+		case attr.name == "ignore":
+			return column, true, nil
+		case attr.name == "id":
+			column.PrimaryKey = true
+			column.Nullable = false
+		case attr.name == "unique":
+			column.Unique = true
+		case attr.name == "default":
+			def, auto, generated := prismaDefault(attr.args, enums[field.typeName] != nil)
+			column.AutoIncrement = auto
+			column.Default = def
+			if generated != "" {
+				diagnostics = append(diagnostics, Diagnostic{
+					Severity: SeverityInfo,
+					Message:  fmt.Sprintf("field %s uses %s(), generated by the Prisma client without a database default", field.name, generated),
+				})
+			}
+		case strings.HasPrefix(attr.name, "db.") && useNativeTypes:
+			native := strings.ToUpper(strings.TrimPrefix(attr.name, "db."))
+			if attr.args != "" {
+				native += "(" + attr.args + ")"
+			}
+			if field.list && dialect == DialectPostgres {
+				native += "[]"
+			}
+			column.Type = native
+		}
+	}
+
+	return column, false, diagnostics
+}
+
+// prismaDefault converts the arguments of @default(...) into a SQL default.
+// It returns the default expression, whether the column auto-increments and
+// the name of a client-side generator function when one is used.
+func prismaDefault(args string, isEnum bool) (*string, bool, string) {
+	args = strings.TrimSpace(args)
+	var expr string
+
+	switch {
+	case args == "autoincrement()" || args == "sequence()":
+		return nil, true, ""
+	case args == "now()":
+		expr = CurrentTimestamp
+	case args == "uuid()" || args == "cuid()" || args == "nanoid()" || args == "ulid()" ||
+		strings.HasPrefix(args, "uuid(") || strings.HasPrefix(args, "cuid(") || strings.HasPrefix(args, "nanoid("):
+		return nil, false, args[:strings.Index(args, "(")]
+	case strings.HasPrefix(args, "dbgenerated("):
+		inner := strings.TrimSuffix(strings.TrimPrefix(args, "dbgenerated("), ")")
+		if inner == "" {
+			return nil, false, ""
+		}
+		expr = unquotePrisma(inner)
+	case strings.HasPrefix(args, `"`):
+		expr = QuoteLiteral(unquotePrisma(args))
+	case args == "true" || args == "false":
+		expr = strings.ToUpper(args)
+	case strings.HasPrefix(args, "["):
+		return nil, false, "" // scalar list defaults have no portable SQL form
+	default:
+		if _, err := strconv.ParseFloat(args, 64); err == nil {
+			expr = args
+		} else if isEnum {
+			expr = QuoteLiteral(args)
+		} else {
+			return nil, false, ""
+		}
+	}
+
+	return &expr, false, ""
+}
+
+// prismaForeignKey builds a foreign key from a relation field's
+// @relation(fields: [...], references: [...]) attribute
+func prismaForeignKey(field prismaField, ownColumns map[string]string, tableNames map[string]string, columnNames map[string]map[string]string) (ForeignKey, bool) {
+	for _, attr := range field.attrs {
+		if attr.name != "relation" {
+			continue
+		}
+
+		named := parsePrismaNamedArgs(attr.args)
+		fields := parsePrismaList(named["fields"])
+		references := parsePrismaList(named["references"])
+		if len(fields) == 0 || len(fields) != len(references) {
+			return ForeignKey{}, false // back-relation side, no columns here
+		}
+
+		fk := ForeignKey{
+			Name:     unquotePrisma(named["map"]),
+			RefTable: tableNames[field.typeName],
+			OnDelete: prismaReferentialAction(named["onDelete"]),
+			OnUpdate: prismaReferentialAction(named["onUpdate"]),
+		}
+		for _, f := range fields {
+			fk.Columns = append(fk.Columns, ownColumns[f])
+		}
+		for _, ref := range references {
+			fk.RefColumns = append(fk.RefColumns, columnNames[field.typeName][ref])
+		}
+		return fk, true
+	}
+	return ForeignKey{}, false
+}
+
+// prismaReferentialAction maps Prisma referential actions to SQL
+func prismaReferentialAction(action string) string {
+	switch strings.TrimSpace(action) {
+	case "Cascade":
+		return "CASCADE"
+	case "Restrict":
+		return "RESTRICT"
+	case "NoAction":
+		return "NO ACTION"
+	case "SetNull":
+		return "SET NULL"
+	case "SetDefault":
+		return "SET DEFAULT"
+	}
+	return ""
+}
+
+// stripPrismaComments removes // and /// comments outside of string literals
+func stripPrismaComments(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		inString := false
+		for j := 0; j < len(line); j++ {
+			switch {
+			case line[j] == '\\' && inString:
+				j++
+			case line[j] == '"':
+				inString = !inString
+			case !inString && line[j] == '/' && j+1 < len(line) && line[j+1] == '/':
+				lines[i] = line[:j]
+				j = len(line)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitPrismaBlocks returns every model/enum/type/view block with its
+// trimmed, non-empty body lines
+func splitPrismaBlocks(content string) []prismaBlock {
+	var blocks []prismaBlock
+
+	for _, loc := range prismaBlockStart.FindAllStringSubmatchIndex(content, -1) {
+		bodyStart := loc[1]
+		bodyEnd := strings.Index(content[bodyStart:], "}")
+		if bodyEnd < 0 {
+			continue
+		}
+
+		block := prismaBlock{
+			kind: content[loc[2]:loc[3]],
+			name: content[loc[4]:loc[5]],
+		}
+		for _, line := range strings.Split(content[bodyStart:bodyStart+bodyEnd], "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				block.lines = append(block.lines, line)
+			}
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// parsePrismaField parses "name Type? @attr(...) @attr" lines
+func parsePrismaField(line string) (prismaField, bool) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 || strings.HasPrefix(parts[0], "@") {
+		return prismaField{}, false
+	}
+
+	// The type may contain spaces inside Unsupported("...")
+	rest := strings.TrimSpace(strings.TrimPrefix(line, parts[0]))
+	typeEnd := len(rest)
+	depth := 0
+	for i, ch := range rest {
+		if ch == '(' {
+			depth++
+		} else if ch == ')' {
+			depth--
+		} else if depth == 0 && (ch == ' ' || ch == '\t' || ch == '@') {
+			typeEnd = i
+			break
+		}
+	}
+
+	field := prismaField{name: parts[0], typeName: rest[:typeEnd]}
+	if strings.HasSuffix(field.typeName, "?") {
+		field.optional = true
+		field.typeName = strings.TrimSuffix(field.typeName, "?")
+	}
+	if strings.HasSuffix(field.typeName, "[]") {
+		field.list = true
+		field.typeName = strings.TrimSuffix(field.typeName, "[]")
+	}
+	field.attrs = parsePrismaAttrs(rest[typeEnd:])
+
+	return field, true
+}
+
+// parsePrismaAttrs splits "@id @default(now()) @db.VarChar(255)" into attributes.
+// Block attributes keep one leading @ in their name (e.g. "@map" for @@map).
+func parsePrismaAttrs(s string) []prismaAttr {
+	var attrs []prismaAttr
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '@' {
+			continue
+		}
+		start := i + 1
+		j := start
+		for j < len(s) && (s[j] == '@' || s[j] == '.' || s[j] == '_' || isAlnum(s[j])) {
+			j++
+		}
+		attr := prismaAttr{name: s[start:j]}
+
+		if j < len(s) && s[j] == '(' {
+			depth, inString := 0, false
+			k := j
+			for ; k < len(s); k++ {
+				switch {
+				case s[k] == '\\' && inString:
+					k++
+				case s[k] == '"':
+					inString = !inString
+				case !inString && s[k] == '(':
+					depth++
+				case !inString && s[k] == ')':
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if k >= len(s) {
+				k = len(s) - 1
+			}
+			attr.args = strings.TrimSpace(s[j+1 : k])
+			j = k + 1
+		}
+
+		attrs = append(attrs, attr)
+		i = j - 1
+	}
+
+	return attrs
+}
+
+// parsePrismaNamedArgs splits "fields: [a], references: [id], onDelete: Cascade"
+func parsePrismaNamedArgs(args string) map[string]string {
+	named := make(map[string]string)
+	for _, part := range splitByComma(strings.NewReplacer("[", "(", "]", ")").Replace(args)) {
+		part = strings.TrimSpace(part)
+		if loc := prismaAttrArgs.FindStringIndex(part); loc != nil {
+			key := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part[:loc[1]]), ":"))
+			named[key] = strings.NewReplacer("(", "[", ")", "]").Replace(strings.TrimSpace(part[loc[1]:]))
+		} else if _, ok := named["name"]; !ok {
+			named["name"] = part // positional relation name
+		}
+	}
+	return named
+}
+
+// parsePrismaList parses "[a, b]" into its identifiers, dropping sort options
+func parsePrismaList(s string) []string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if inner := prismaAttrArgs.FindStringIndex(s); inner != nil && strings.HasPrefix(s, "fields") {
+		s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s[inner[1]:]), "["), "]")
+	}
+
+	var items []string
+	for _, item := range splitByComma(s) {
+		item = strings.TrimSpace(item)
+		if i := strings.IndexAny(item, "( "); i > 0 {
+			item = item[:i] // e.g. "title(sort: Desc)"
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// unquotePrisma strips surrounding double quotes and unescapes the content
+func unquotePrisma(s string) string {
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return strings.Trim(s, `"`)
+}
+
+func isAlnum(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
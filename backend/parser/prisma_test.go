@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const prismaFixture = `
+datasource db {
+  provider = "postgresql"
+  url      = env("DATABASE_URL")
+}
+
+generator client {
+  provider = "prisma-client-js"
+}
+
+enum Role {
+  USER
+  ADMIN
+}
+
+/// A registered user
+model User {
+  id        Int      @id @default(autoincrement())
+  email     String   @unique @db.VarChar(320)
+  name      String?
+  role      Role     @default(USER)
+  createdAt DateTime @default(now()) @map("created_at")
+  posts     Post[]
+
+  @@map("users")
+}
+
+model Post {
+  id        String   @id @default(uuid())
+  title     String   // headline
+  published Boolean  @default(false)
+  tags      String[]
+  author    User     @relation(fields: [authorId], references: [id], onDelete: Cascade)
+  authorId  Int      @map("author_id")
+}
+
+model Membership {
+  userId Int
+  teamId Int
+
+  @@id([userId, teamId])
+}
+`
+
+func findField(t *testing.T, table Table, name string) Field {
+	t.Helper()
+	for _, f := range table.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("Field %s not found in table %s: %+v", name, table.Name, table.Fields)
+	return Field{}
+}
+
+func TestPrismaParser_ParsesModels(t *testing.T) {
+	p := NewPrismaParser()
+
+	if score := p.Detect(prismaFixture); score < 0.9 {
+		t.Errorf("Expected high detection score, got %v", score)
+	}
+	if d := p.DefaultDialect(prismaFixture); d != DialectPostgres {
+		t.Errorf("Expected datasource provider postgresql, got %q", d)
+	}
+
+	tables, diagnostics, err := p.Parse(strings.NewReader(prismaFixture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tables) != 3 {
+		t.Fatalf("Expected 3 tables, got %d: %+v", len(tables), tables)
+	}
+
+	users := tables[0]
+	if users.Name != "users" {
+		t.Errorf("Expected @@map table name 'users', got %q", users.Name)
+	}
+	if len(users.Fields) != 5 {
+		t.Errorf("Expected relation list field to be skipped, got %+v", users.Fields)
+	}
+
+	id := findField(t, users, "id")
+	if !id.PrimaryKey || !id.AutoIncrement || id.Nullable || id.Type != "INTEGER" {
+		t.Errorf("Unexpected id field: %+v", id)
+	}
+	if email := findField(t, users, "email"); !email.Unique || email.Type != "VARCHAR(320)" {
+		t.Errorf("Expected unique VARCHAR(320) email, got %+v", email)
+	}
+	if name := findField(t, users, "name"); !name.Nullable || name.Type != "TEXT" {
+		t.Errorf("Expected nullable TEXT name, got %+v", name)
+	}
+	if role := findField(t, users, "role"); role.Default == nil || *role.Default != "'USER'" {
+		t.Errorf("Expected enum default 'USER', got %+v", role)
+	}
+	if created := findField(t, users, "created_at"); created.Default == nil || *created.Default != CurrentTimestamp {
+		t.Errorf("Expected @map column created_at defaulting to now, got %+v", created)
+	}
+
+	posts := tables[1]
+	if tags := findField(t, posts, "tags"); tags.Type != "TEXT[]" {
+		t.Errorf("Expected TEXT[] for scalar list, got %q", tags.Type)
+	}
+	if published := findField(t, posts, "published"); published.Default == nil || *published.Default != "FALSE" {
+		t.Errorf("Expected FALSE default, got %+v", published)
+	}
+	if len(posts.ForeignKeys) != 1 {
+		t.Fatalf("Expected 1 foreign key, got %+v", posts.ForeignKeys)
+	}
+	fk := posts.ForeignKeys[0]
+	if fk.RefTable != "users" || fk.Columns[0] != "author_id" || fk.RefColumns[0] != "id" || fk.OnDelete != "CASCADE" {
+		t.Errorf("Unexpected foreign key: %+v", fk)
+	}
+
+	membership := tables[2]
+	if pk := membership.PrimaryKey(); len(pk) != 2 || pk[0] != "userId" || pk[1] != "teamId" {
+		t.Errorf("Expected composite primary key, got %v", pk)
+	}
+
+	foundUUIDNote := false
+	for _, d := range diagnostics {
+		if strings.Contains(d.Message, "uuid()") && d.Table == "Post" {
+			foundUUIDNote = true
+		}
+	}
+	if !foundUUIDNote {
+		t.Errorf("Expected an info diagnostic about the client-side uuid() default, got %+v", diagnostics)
+	}
+}
+
+func TestPrismaParser_ForDialect(t *testing.T) {
+	p := NewPrismaParser().ForDialect(DialectSQLServer)
+
+	tables, _, err := p.Parse(strings.NewReader(prismaFixture))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Native @db types belong to the postgresql provider and are ignored
+	if email := findField(t, tables[0], "email"); email.Type != "NVARCHAR(1000)" {
+		t.Errorf("Expected NVARCHAR(1000) for String on sqlserver, got %q", email.Type)
+	}
+	if published := findField(t, tables[1], "published"); published.Type != "BIT" {
+		t.Errorf("Expected BIT for Boolean on sqlserver, got %q", published.Type)
+	}
+}
+
+func TestPrismaParser_DefaultsToMySQLWithoutDatasource(t *testing.T) {
+	content := "model Item {\n  id Int @id\n  label String\n}\n"
+
+	p := NewPrismaParser()
+	if d := p.DefaultDialect(content); d != DialectMySQL {
+		t.Errorf("Expected mysql default, got %q", d)
+	}
+
+	tables, _, err := p.Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if label := findField(t, tables[0], "label"); label.Type != "VARCHAR(191)" {
+		t.Errorf("Expected VARCHAR(191), got %q", label.Type)
+	}
+}
+
+func TestDefaultRegistry_ParsesPrismaWithProviderDialect(t *testing.T) {
+	result, err := DefaultRegistry().Parse(prismaFixture, ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Format != "prisma" || result.Dialect != DialectPostgres {
+		t.Errorf("Expected prisma format targeting postgresql, got %q / %q", result.Format, result.Dialect)
+	}
+}
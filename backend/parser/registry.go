@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Diagnostic severities
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// Diagnostic is a non-fatal message produced while parsing a schema,
+// e.g. a table that was skipped because it could not be parsed
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Table    string `json:"table,omitempty"`
+	Parser   string `json:"parser,omitempty"`
+}
+
+// SchemaParser turns a schema document of one format into tables.
+// Implementations must be safe for concurrent use.
+type SchemaParser interface {
+	// Name is the unique, lowercase identifier of the format (e.g. "mysql")
+	Name() string
+	// Detect scores how likely content is in this format, from 0 (no) to 1 (certain)
+	Detect(content string) float64
+	// Parse reads the whole document and returns the tables found
+	Parse(r io.Reader) ([]Table, []Diagnostic, error)
+}
+
+// FormatDescriber is optionally implemented by parsers to describe
+// themselves in the format listing
+type FormatDescriber interface {
+	Description() string
+	// Extensions lists accepted file extensions including the dot (e.g. ".sql")
+	Extensions() []string
+}
+
+// DialectParser is optionally implemented by SQL parsers to declare which
+// dialects they understand, so a forced dialect can rank them first
+type DialectParser interface {
+	Dialects() []Dialect
+}
+
+// DialectTargeter is optionally implemented by non-SQL parsers (ORM and
+// schema-definition formats) whose column types depend on a target dialect
+type DialectTargeter interface {
+	ForDialect(dialect Dialect) SchemaParser
+	// DefaultDialect is the dialect used when none is forced, e.g. the
+	// datasource provider of a Prisma schema
+	DefaultDialect(content string) Dialect
+}
+
+// FormatInfo describes a registered schema format
+type FormatInfo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Extensions  []string  `json:"extensions,omitempty"`
+	Dialects    []Dialect `json:"dialects,omitempty"`
+}
+
+// ParseOptions tunes Registry.Parse
+type ParseOptions struct {
+	// Format forces a single parser by name; empty means auto-detect
+	Format string
+	// Dialect ranks parsers supporting it first and is handed to dialect targeters
+	Dialect Dialect
+}
+
+// ParseResult is the outcome of Registry.Parse
+type ParseResult struct {
+	Tables []Table `json:"tables"`
+	Format string  `json:"format"`
+	// Dialect is the dialect column types were rendered for by a dialect
+	// targeter; empty for SQL parsers, whose types come from the file
+	Dialect     Dialect      `json:"dialect,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Registry holds the known schema parsers in registration order
+type Registry struct {
+	mu      sync.RWMutex
+	parsers []SchemaParser
+}
+
+// NewRegistry creates a registry with the given parsers
+func NewRegistry(parsers ...SchemaParser) *Registry {
+	r := &Registry{}
+	for _, p := range parsers {
+		if err := r.Register(p); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// DefaultRegistry returns the process-wide registry with all built-in parsers.
+// Registering a parser here makes it available to the HTTP handlers.
+func DefaultRegistry() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry(builtinParsers()...)
+	})
+	return defaultRegistry
+}
+
+// Register adds a parser; names must be unique and lowercase, as Get
+// looks them up case-insensitively
+func (r *Registry) Register(p SchemaParser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := p.Name()
+	if name == "" {
+		return fmt.Errorf("parser name must not be empty")
+	}
+	if name != strings.ToLower(name) {
+		return fmt.Errorf("parser name %q must be lowercase", name)
+	}
+	for _, existing := range r.parsers {
+		if existing.Name() == name {
+			return fmt.Errorf("parser %q is already registered", name)
+		}
+	}
+
+	r.parsers = append(r.parsers, p)
+	return nil
+}
+
+// Get returns the parser with the given name
+func (r *Registry) Get(name string) (SchemaParser, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.parsers {
+		if p.Name() == strings.ToLower(name) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Parsers returns the registered parsers in registration order
+func (r *Registry) Parsers() []SchemaParser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]SchemaParser(nil), r.parsers...)
+}
+
+// Formats describes every registered parser
+func (r *Registry) Formats() []FormatInfo {
+	parsers := r.Parsers()
+	formats := make([]FormatInfo, 0, len(parsers))
+
+	for _, p := range parsers {
+		info := FormatInfo{Name: p.Name()}
+		if d, ok := p.(FormatDescriber); ok {
+			info.Description = d.Description()
+			info.Extensions = d.Extensions()
+		}
+		if d, ok := p.(DialectParser); ok {
+			info.Dialects = d.Dialects()
+		}
+		formats = append(formats, info)
+	}
+
+	return formats
+}
+
+// AcceptsExtension reports whether any registered parser claims the file
+// extension of filename
+func (r *Registry) AcceptsExtension(filename string) bool {
+	lower := strings.ToLower(filename)
+	for _, format := range r.Formats() {
+		for _, ext := range format.Extensions {
+			if strings.HasSuffix(lower, ext) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rank orders parsers by how well they match content (and the optional
+// dialect). Parsers scoring zero are dropped; ties keep registration order.
+func (r *Registry) Rank(content string, dialect Dialect) []SchemaParser {
+	type scored struct {
+		parser SchemaParser
+		score  float64
+	}
+
+	var candidates []scored
+	for _, p := range r.Parsers() {
+		score := p.Detect(content)
+		if score <= 0 {
+			continue
+		}
+		if dialect != DialectUnknown && supportsDialect(p, dialect) {
+			score++
+		}
+		candidates = append(candidates, scored{p, score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	ranked := make([]SchemaParser, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.parser
+	}
+	return ranked
+}
+
+// Parse runs the best matching parsers in turn and returns the first result
+// containing tables. Diagnostics of parsers that found nothing are kept so
+// callers can explain why a document was rejected.
+func (r *Registry) Parse(content string, opts ParseOptions) (*ParseResult, error) {
+	var candidates []SchemaParser
+	if opts.Format != "" {
+		p, ok := r.Get(opts.Format)
+		if !ok {
+			return nil, fmt.Errorf("unknown schema format %q", opts.Format)
+		}
+		candidates = []SchemaParser{p}
+	} else {
+		candidates = r.Rank(content, opts.Dialect)
+	}
+
+	result := &ParseResult{}
+	for _, p := range candidates {
+		var targetDialect Dialect
+		if t, ok := p.(DialectTargeter); ok {
+			targetDialect = opts.Dialect
+			if targetDialect == DialectUnknown {
+				targetDialect = t.DefaultDialect(content)
+			}
+			p = t.ForDialect(targetDialect)
+		}
+
+		tables, diagnostics, err := p.Parse(strings.NewReader(content))
+		for i := range diagnostics {
+			if diagnostics[i].Parser == "" {
+				diagnostics[i].Parser = p.Name()
+			}
+		}
+		result.Diagnostics = append(result.Diagnostics, diagnostics...)
+
+		if err != nil {
+			result.Diagnostics = append(result.Diagnostics, Diagnostic{
+				Severity: SeverityError,
+				Message:  err.Error(),
+				Parser:   p.Name(),
+			})
+			continue
+		}
+
+		if len(tables) > 0 {
+			result.Tables = tables
+			result.Format = p.Name()
+			result.Dialect = targetDialect
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// supportsDialect reports whether p declares support for dialect
+func supportsDialect(p SchemaParser, dialect Dialect) bool {
+	d, ok := p.(DialectParser)
+	if !ok {
+		return false
+	}
+	for _, supported := range d.Dialects() {
+		if supported == dialect {
+			return true
+		}
+	}
+	return false
+}
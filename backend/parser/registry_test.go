@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// stubParser is a minimal SchemaParser used to exercise the registry
+type stubParser struct {
+	name  string
+	score float64
+	table string
+}
+
+func (p *stubParser) Name() string                  { return p.name }
+func (p *stubParser) Detect(content string) float64 { return p.score }
+func (p *stubParser) Parse(r io.Reader) ([]Table, []Diagnostic, error) {
+	if p.table == "" {
+		return nil, []Diagnostic{{Severity: SeverityWarning, Message: "nothing here"}}, nil
+	}
+	return []Table{{Name: p.table, Fields: []Field{{Name: "id", Type: "INT"}}}}, nil, nil
+}
+
+func TestDefaultRegistry_BuiltinParsersParseTheirFixtures(t *testing.T) {
+	fixtures := map[string]string{
+		"tidb":       "CREATE TABLE `users` (`id` INT NOT NULL, `name` VARCHAR(50)) ENGINE=InnoDB;",
+		"postgresql": "CREATE TABLE users (id SERIAL NOT NULL, name TEXT);",
+		"mysql":      "CREATE TABLE `users` (\n`id` INT NOT NULL,\n`name` VARCHAR(50)\n)",
+	}
+
+	for _, p := range DefaultRegistry().Parsers() {
+		fixture, ok := fixtures[p.Name()]
+		if !ok {
+			continue // parsers added later bring their own tests
+		}
+
+		if score := p.Detect(fixture); score <= 0 {
+			t.Errorf("%s: expected positive detection score, got %v", p.Name(), score)
+		}
+
+		tables, _, err := p.Parse(strings.NewReader(fixture))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", p.Name(), err)
+		}
+		if len(tables) != 1 || tables[0].Name != "users" || len(tables[0].Fields) != 2 {
+			t.Errorf("%s: expected users table with 2 fields, got %+v", p.Name(), tables)
+		}
+	}
+}
+
+func TestDefaultRegistry_FormatsDescribeEveryParser(t *testing.T) {
+	registry := DefaultRegistry()
+	formats := registry.Formats()
+
+	if len(formats) != len(registry.Parsers()) {
+		t.Fatalf("Expected one format per parser, got %d formats for %d parsers", len(formats), len(registry.Parsers()))
+	}
+	for _, f := range formats {
+		if f.Description == "" || len(f.Extensions) == 0 {
+			t.Errorf("Format %q should have a description and extensions", f.Name)
+		}
+	}
+	if !registry.AcceptsExtension("dump.SQL") {
+		t.Errorf("Expected .sql files to be accepted")
+	}
+	if registry.AcceptsExtension("photo.png") {
+		t.Errorf("Expected .png files to be rejected")
+	}
+}
+
+func TestRegistry_RegisterRejectsDuplicates(t *testing.T) {
+	registry := NewRegistry(&stubParser{name: "a"})
+
+	if err := registry.Register(&stubParser{name: "a"}); err == nil {
+		t.Errorf("Expected error when registering a duplicate name")
+	}
+	if err := registry.Register(&stubParser{name: ""}); err == nil {
+		t.Errorf("Expected error when registering an empty name")
+	}
+}
+
+func TestRegistry_RegisterRejectsMixedCaseNames(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.Register(&stubParser{name: "MyFormat"}); err == nil {
+		t.Errorf("Expected error when registering a mixed-case name")
+	}
+	if err := registry.Register(&stubParser{name: "myformat"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := registry.Get("MyFormat"); !ok {
+		t.Errorf("Expected lowercase parser to be found by a mixed-case lookup")
+	}
+}
+
+func TestRegistry_RankOrdersByScoreAndDropsZero(t *testing.T) {
+	registry := NewRegistry(
+		&stubParser{name: "low", score: 0.2},
+		&stubParser{name: "none", score: 0},
+		&stubParser{name: "high", score: 0.9},
+	)
+
+	ranked := registry.Rank("anything", DialectUnknown)
+
+	if len(ranked) != 2 {
+		t.Fatalf("Expected 2 ranked parsers, got %d", len(ranked))
+	}
+	if ranked[0].Name() != "high" || ranked[1].Name() != "low" {
+		t.Errorf("Expected [high low], got [%s %s]", ranked[0].Name(), ranked[1].Name())
+	}
+}
+
+func TestRegistry_ParseFallsThroughAndKeepsDiagnostics(t *testing.T) {
+	registry := NewRegistry(
+		&stubParser{name: "empty", score: 0.9},
+		&stubParser{name: "works", score: 0.5, table: "orders"},
+	)
+
+	result, err := registry.Parse("content", ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Format != "works" {
+		t.Errorf("Expected format 'works', got %q", result.Format)
+	}
+	if len(result.Tables) != 1 || result.Tables[0].Name != "orders" {
+		t.Errorf("Expected orders table, got %+v", result.Tables)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Parser != "empty" {
+		t.Errorf("Expected diagnostic from 'empty' parser, got %+v", result.Diagnostics)
+	}
+}
+
+func TestRegistry_ParseForcedFormat(t *testing.T) {
+	registry := NewRegistry(
+		&stubParser{name: "first", score: 0.9, table: "a"},
+		&stubParser{name: "second", score: 0.1, table: "b"},
+	)
+
+	result, err := registry.Parse("content", ParseOptions{Format: "second"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Format != "second" || result.Tables[0].Name != "b" {
+		t.Errorf("Expected forced parser 'second' to be used, got %q", result.Format)
+	}
+
+	if _, err := registry.Parse("content", ParseOptions{Format: "missing"}); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestDefaultRegistry_DialectRanksParsers(t *testing.T) {
+	sql := `CREATE TABLE [dbo].[Customers] (
+	[Id] INT NOT NULL,
+	[Name] NVARCHAR(100) NULL
+)
+GO`
+
+	result, err := DefaultRegistry().Parse(sql, ParseOptions{Dialect: DialectSQLServer})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Format != "postgresql" {
+		t.Errorf("Expected SQL Server content to be handled by the postgresql parser, got %q", result.Format)
+	}
+	if len(result.Tables) != 1 || result.Tables[0].Name != "Customers" {
+		t.Fatalf("Expected Customers table, got %+v", result.Tables)
+	}
+	if len(result.Tables[0].Fields) != 2 || result.Tables[0].Fields[0].Name != "Id" {
+		t.Errorf("Expected fields Id and Name, got %+v", result.Tables[0].Fields)
+	}
+}
+
+func TestDefaultRegistry_TiDBReportsSkippedStatements(t *testing.T) {
+	sql := "CREATE TABLE `ok` (`id` INT);\nCREATE TABLE `broken` (`id` INT NOT NULL DEFAULT ((;"
+
+	tables, diagnostics := parseWithTiDB(sql)
+
+	if len(tables) != 1 {
+		t.Fatalf("Expected 1 parsed table, got %d", len(tables))
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Table != "broken" {
+		t.Errorf("Expected a diagnostic for table 'broken', got %+v", diagnostics)
+	}
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// sqlParser adapts one of the CREATE TABLE parsers to the SchemaParser interface
+type sqlParser struct {
+	name        string
+	description string
+	dialects    []Dialect
+	// priority breaks ties when nothing hints at a dialect; it preserves the
+	// historical TiDB -> PostgreSQL -> MySQL fallback order
+	priority float64
+	parse    func(content string) ([]Table, []Diagnostic)
+}
+
+// builtinParsers returns the parsers shipped with db-importer, in fallback order
+func builtinParsers() []SchemaParser {
+	return []SchemaParser{
+		&sqlParser{
+			name:        "tidb",
+			description: "MySQL/MariaDB dumps parsed with the TiDB SQL grammar",
+			dialects:    []Dialect{DialectMySQL},
+			priority:    0.05,
+			parse:       parseWithTiDB,
+		},
+		&sqlParser{
+			name:        "postgresql",
+			description: "PostgreSQL, SQLite and SQL Server CREATE TABLE statements (regex based)",
+			dialects:    []Dialect{DialectPostgres, DialectSQLite, DialectSQLServer},
+			priority:    0.02,
+			parse: func(content string) ([]Table, []Diagnostic) {
+				return ParsePostgreSQL(stripBracketIdentifiers(content)), nil
+			},
+		},
+		&sqlParser{
+			name:        "mysql",
+			description: "MySQL/MariaDB CREATE TABLE statements (regex based fallback)",
+			dialects:    []Dialect{DialectMySQL},
+			parse: func(content string) ([]Table, []Diagnostic) {
+				return ParseMySQL(content), nil
+			},
+		},
+		NewPrismaParser(),
+		NewJSONSchemaParser(),
+	}
+}
+
+var createTableMarker = regexp.MustCompile(`(?i)\bCREATE\s+TABLE\b`)
+
+// Name implements SchemaParser
+func (p *sqlParser) Name() string { return p.name }
+
+// Description implements FormatDescriber
+func (p *sqlParser) Description() string { return p.description }
+
+// Extensions implements FormatDescriber
+func (p *sqlParser) Extensions() []string { return []string{".sql"} }
+
+// Dialects implements DialectParser
+func (p *sqlParser) Dialects() []Dialect { return p.dialects }
+
+// Detect implements SchemaParser: any document with CREATE TABLE is a
+// candidate, and a detected dialect the parser supports raises the score
+func (p *sqlParser) Detect(content string) float64 {
+	if !createTableMarker.MatchString(content) {
+		return 0
+	}
+
+	score := 0.5 + p.priority
+	detection := DetectDialect(content)
+	for _, d := range p.dialects {
+		if d == detection.Dialect {
+			score += 0.4 * detection.Confidence
+			break
+		}
+	}
+	return score
+}
+
+// Parse implements SchemaParser
+func (p *sqlParser) Parse(r io.Reader) ([]Table, []Diagnostic, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tables, diagnostics := p.parse(string(content))
+	return tables, diagnostics, nil
+}
+
+var createTableNameRegex = regexp.MustCompile("(?i)CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?[`\"\\[]?(?:[A-Za-z0-9_]+[`\"\\]]?\\.[`\"\\[]?)?([A-Za-z0-9_]+)")
+
+// createTableName extracts the table name of a CREATE TABLE statement for diagnostics
+func createTableName(stmt string) string {
+	if m := createTableNameRegex.FindStringSubmatch(stmt); len(m) > 1 {
+		return m[1]
+	}
+	return strings.TrimSpace(firstLine(stmt))
+}
+
+var bracketIdentifier = regexp.MustCompile(`\[([^\]]+)\]`)
+
+// stripBracketIdentifiers turns SQL Server [identifiers] into bare names so
+// the regex parsers can read them
+func stripBracketIdentifiers(sqlContent string) string {
+	return bracketIdentifier.ReplaceAllString(sqlContent, "$1")
+}
@@ -12,7 +12,14 @@ import (
 // 1. Extract each CREATE TABLE with regex
 // 2. Parse each table individually with TiDB
 func ParseWithTiDB(sqlContent string) []Table {
+	tables, _ := parseWithTiDB(sqlContent)
+	return tables
+}
+
+// parseWithTiDB is ParseWithTiDB that also reports statements TiDB rejected
+func parseWithTiDB(sqlContent string) ([]Table, []Diagnostic) {
 	var tables []Table
+	var diagnostics []Diagnostic
 
 	// Clean SQL content (remove phpMyAdmin directives and comments)
 	sqlContent = cleanSQLContent(sqlContent)
@@ -28,7 +35,12 @@ func ParseWithTiDB(sqlContent string) []Table {
 		// Try to parse this single CREATE TABLE
 		stmts, _, err := p.Parse(stmt, "", "")
 		if err != nil {
-			// Skip tables that fail to parse
+			// Skip tables that fail to parse, but tell the caller about it
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  "skipped statement: " + firstLine(err.Error()),
+				Table:    createTableName(stmt),
+			})
 			continue
 		}
 
@@ -78,7 +90,15 @@ func ParseWithTiDB(sqlContent string) []Table {
 		}
 	}
 
-	return tables
+	return tables, diagnostics
+}
+
+// firstLine returns the first line of a possibly multi-line message
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
 }
 
 // parseStatementByStatement tries to parse SQL statement by statement
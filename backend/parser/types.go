@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeKind is a dialect-independent column type used by schema formats
+// that do not carry SQL types themselves (ORM schemas, JSON Schema, inference)
+type TypeKind string
+
+const (
+	TypeString   TypeKind = "string"
+	TypeText     TypeKind = "text"
+	TypeInteger  TypeKind = "integer"
+	TypeFloat    TypeKind = "float"
+	TypeDecimal  TypeKind = "decimal"
+	TypeBoolean  TypeKind = "boolean"
+	TypeDate     TypeKind = "date"
+	TypeTime     TypeKind = "time"
+	TypeDateTime TypeKind = "datetime"
+	TypeJSON     TypeKind = "json"
+	TypeBinary   TypeKind = "binary"
+	TypeUUID     TypeKind = "uuid"
+	TypeEnum     TypeKind = "enum"
+)
+
+// LogicalType describes a column type independently of any SQL dialect
+type LogicalType struct {
+	Kind      TypeKind
+	Length    int      // max length for TypeString (0 = 255)
+	Bytes     int      // storage size for TypeInteger: 2, 4 (default) or 8
+	Precision int      // TypeDecimal precision (0 = dialect default)
+	Scale     int      // TypeDecimal scale
+	Values    []string // allowed values for TypeEnum
+	Array     bool     // list of Kind; native arrays on PostgreSQL, JSON elsewhere
+}
+
+// SQLType renders the logical type as a column type for the dialect.
+// Unknown dialects are rendered like MySQL, the generator's default.
+func (t LogicalType) SQLType(dialect Dialect) string {
+	if t.Array {
+		element := t
+		element.Array = false
+		if dialect == DialectPostgres {
+			return element.SQLType(dialect) + "[]"
+		}
+		return LogicalType{Kind: TypeJSON}.SQLType(dialect)
+	}
+
+	switch t.Kind {
+	case TypeString:
+		length := t.Length
+		if length <= 0 {
+			length = 255
+		}
+		switch dialect {
+		case DialectSQLServer:
+			return fmt.Sprintf("NVARCHAR(%d)", length)
+		case DialectSQLite:
+			return "TEXT"
+		default:
+			return fmt.Sprintf("VARCHAR(%d)", length)
+		}
+
+	case TypeText:
+		if dialect == DialectSQLServer {
+			return "NVARCHAR(MAX)"
+		}
+		return "TEXT"
+
+	case TypeInteger:
+		if dialect == DialectSQLite {
+			return "INTEGER"
+		}
+		switch t.Bytes {
+		case 2:
+			return "SMALLINT"
+		case 8:
+			return "BIGINT"
+		}
+		if dialect == DialectPostgres {
+			return "INTEGER"
+		}
+		return "INT"
+
+	case TypeFloat:
+		switch dialect {
+		case DialectPostgres:
+			return "DOUBLE PRECISION"
+		case DialectSQLServer:
+			return "FLOAT"
+		case DialectSQLite:
+			return "REAL"
+		default:
+			return "DOUBLE"
+		}
+
+	case TypeDecimal:
+		precision, scale := t.Precision, t.Scale
+		if precision <= 0 {
+			precision, scale = 65, 30
+			if dialect == DialectSQLServer {
+				precision, scale = 38, 10
+			}
+		}
+		if dialect == DialectPostgres || dialect == DialectSQLite {
+			return fmt.Sprintf("NUMERIC(%d,%d)", precision, scale)
+		}
+		return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale)
+
+	case TypeBoolean:
+		if dialect == DialectSQLServer {
+			return "BIT"
+		}
+		return "BOOLEAN"
+
+	case TypeDate:
+		return "DATE"
+
+	case TypeTime:
+		return "TIME"
+
+	case TypeDateTime:
+		switch dialect {
+		case DialectPostgres:
+			return "TIMESTAMP"
+		case DialectSQLServer:
+			return "DATETIME2"
+		default:
+			return "DATETIME"
+		}
+
+	case TypeJSON:
+		switch dialect {
+		case DialectPostgres:
+			return "JSONB"
+		case DialectSQLServer:
+			return "NVARCHAR(MAX)"
+		case DialectSQLite:
+			return "TEXT"
+		default:
+			return "JSON"
+		}
+
+	case TypeBinary:
+		switch dialect {
+		case DialectPostgres:
+			return "BYTEA"
+		case DialectSQLServer:
+			return "VARBINARY(MAX)"
+		case DialectSQLite:
+			return "BLOB"
+		default:
+			return "LONGBLOB"
+		}
+
+	case TypeUUID:
+		switch dialect {
+		case DialectPostgres:
+			return "UUID"
+		case DialectSQLServer:
+			return "UNIQUEIDENTIFIER"
+		case DialectSQLite:
+			return "TEXT"
+		default:
+			return "CHAR(36)"
+		}
+
+	case TypeEnum:
+		if (dialect == DialectMySQL || dialect == DialectUnknown) && len(t.Values) > 0 {
+			quoted := make([]string, len(t.Values))
+			for i, v := range t.Values {
+				quoted[i] = QuoteLiteral(v)
+			}
+			return "ENUM(" + strings.Join(quoted, ",") + ")"
+		}
+		length := 0
+		for _, v := range t.Values {
+			if len(v) > length {
+				length = len(v)
+			}
+		}
+		return LogicalType{Kind: TypeString, Length: max(length, 32)}.SQLType(dialect)
+	}
+
+	return LogicalType{Kind: TypeText}.SQLType(dialect)
+}
+
+// QuoteLiteral renders s as a single-quoted SQL string literal
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// CurrentTimestamp is the portable SQL expression for "now" used in defaults
+const CurrentTimestamp = "CURRENT_TIMESTAMP"
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets full buckets
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory. They are lost on restart and
+// not shared between replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time // theoretical arrival time by key
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Allow implements Store
+func (s *MemoryStore) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	if policy.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	var ahead time.Duration
+	if tat, ok := s.buckets[key]; ok {
+		ahead = tat.Sub(now)
+	}
+
+	result, next := evaluate(ahead, policy)
+	if result.Allowed {
+		s.buckets[key] = now.Add(next)
+	}
+	return result, nil
+}
+
+// sweep forgets buckets that are full again, which behave like missing
+// ones, at most once per sweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, tat := range s.buckets {
+		if !tat.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
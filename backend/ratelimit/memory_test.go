@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a memory store on a clock the test moves forward
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryStore_BurstThenRefill(t *testing.T) {
+	store, now := newTestStore()
+	ctx := context.Background()
+	policy := Policy{Limit: 3, Window: 24 * time.Hour}
+
+	for i, want := range []int{2, 1, 0} {
+		result, err := store.Allow(ctx, "ip:1", policy)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("Request %d: allowed %v remaining %d, want allowed with %d", i+1, result.Allowed, result.Remaining, want)
+		}
+	}
+
+	result, _ := store.Allow(ctx, "ip:1", policy)
+	if result.Allowed {
+		t.Fatal("Request past the limit was allowed")
+	}
+	if result.RetryAfter != 8*time.Hour {
+		t.Errorf("RetryAfter = %s, want 8h", result.RetryAfter)
+	}
+	if result.Reset != 24*time.Hour {
+		t.Errorf("Reset = %s, want 24h", result.Reset)
+	}
+
+	// One request is regained per interval
+	*now = now.Add(8 * time.Hour)
+	result, _ = store.Allow(ctx, "ip:1", policy)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("After one interval: allowed %v remaining %d, want allowed with 0", result.Allowed, result.Remaining)
+	}
+	if result, _ = store.Allow(ctx, "ip:1", policy); result.Allowed {
+		t.Error("Second request after one interval was allowed")
+	}
+}
+
+func TestMemoryStore_KeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore()
+	ctx := context.Background()
+	policy := Policy{Limit: 1, Window: time.Minute}
+
+	if result, _ := store.Allow(ctx, "user:a", policy); !result.Allowed {
+		t.Fatal("First request of user a was denied")
+	}
+	if result, _ := store.Allow(ctx, "user:b", policy); !result.Allowed {
+		t.Error("First request of user b was denied")
+	}
+	if result, _ := store.Allow(ctx, "user:a", policy); result.Allowed {
+		t.Error("Second request of user a was allowed")
+	}
+}
+
+func TestMemoryStore_Unlimited(t *testing.T) {
+	store, _ := newTestStore()
+	for i := 0; i < 100; i++ {
+		result, _ := store.Allow(context.Background(), "user:a", Policy{Limit: 0, Window: time.Second})
+		if !result.Allowed {
+			t.Fatalf("Request %d denied by an unlimited policy", i+1)
+		}
+	}
+	if len(store.buckets) != 0 {
+		t.Errorf("Unlimited policy kept %d buckets", len(store.buckets))
+	}
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	store, now := newTestStore()
+	ctx := context.Background()
+
+	store.Allow(ctx, "user:a", Policy{Limit: 10, Window: time.Minute})
+	store.Allow(ctx, "user:b", Policy{Limit: 1, Window: time.Hour})
+
+	*now = now.Add(2 * time.Minute)
+	store.Allow(ctx, "user:c", Policy{Limit: 1, Window: time.Hour})
+
+	if _, ok := store.buckets["user:a"]; ok {
+		t.Error("Full bucket was not swept")
+	}
+	if _, ok := store.buckets["user:b"]; !ok {
+		t.Error("Refilling bucket was swept")
+	}
+}
+
+func TestPolicy_String(t *testing.T) {
+	policy := Policy{Limit: 100, Window: time.Hour}
+	if got := policy.String(); got != "100;w=3600" {
+		t.Errorf("String() = %q, want 100;w=3600", got)
+	}
+	if got := policy.Describe(); got != "100 requests per 1h0m0s" {
+		t.Errorf("Describe() = %q", got)
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity classes of callers
+const (
+	ClassGuest = "guest" // not signed in, limited per IP address
+	ClassUser  = "user"  // signed in
+	ClassToken = "token" // authenticated with a personal access token
+
+	// planPrefix starts the class of callers acting in an organization on
+	// a plan, e.g. "plan:team"
+	planPrefix = "plan:"
+)
+
+// PlanClass is the class of callers acting in an organization on plan
+func PlanClass(plan string) string {
+	return planPrefix + plan
+}
+
+// Rule applies a policy to one class of callers on some routes
+type Rule struct {
+	// Routes are "*" for any route, an exact path, or a path prefix ending
+	// in "*" such as "/api/v1/imports*"
+	Routes []string
+	Class  string
+	Policy Policy
+}
+
+// Matches reports whether the rule applies to route
+func (r Rule) Matches(route string) bool {
+	for _, pattern := range r.Routes {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if pattern == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Key is the bucket of a caller under the rule. Requests to all routes of a
+// rule share one bucket.
+func (r Rule) Key(identity string) string {
+	return strings.Join(r.Routes, ",") + " " + r.Class + " " + identity
+}
+
+// Policies are rules in order of precedence
+type Policies []Rule
+
+// Lookup returns the first rule for route of the first class that has one
+func (p Policies) Lookup(route string, classes ...string) (Rule, bool) {
+	for _, class := range classes {
+		for _, rule := range p {
+			if rule.Class == class && rule.Matches(route) {
+				return rule, true
+			}
+		}
+	}
+	return Rule{}, false
+}
+
+// HasPlans reports whether any rule for route applies to an organization
+// plan, which callers then need to be resolved to
+func (p Policies) HasPlans(route string) bool {
+	for _, rule := range p {
+		if strings.HasPrefix(rule.Class, planPrefix) && rule.Matches(route) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePolicies parses rules separated by semicolons. Each rule is a
+// comma-separated list of routes followed by one or more
+// class=limit/window assignments, e.g.
+//
+//	/generate-sql,/validate guest=10/1h user=1000/1h; * plan:team=6000/1m
+//
+// Windows are Go durations; a limit of 0 means unlimited.
+func ParsePolicies(spec string) (Policies, error) {
+	policies := Policies{}
+	for _, text := range strings.Split(spec, ";") {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("rate limit rule %q has no class=limit/window", strings.TrimSpace(text))
+		}
+
+		routes := strings.Split(fields[0], ",")
+		for _, route := range routes {
+			if route != "*" && !strings.HasPrefix(route, "/") {
+				return nil, fmt.Errorf("rate limit route %q must be * or start with /", route)
+			}
+		}
+
+		for _, assignment := range fields[1:] {
+			class, policy, err := parseAssignment(assignment)
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, Rule{Routes: routes, Class: class, Policy: policy})
+		}
+	}
+	return policies, nil
+}
+
+// parseAssignment parses class=limit/window
+func parseAssignment(assignment string) (string, Policy, error) {
+	class, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return "", Policy{}, fmt.Errorf("rate limit %q must be class=limit/window", assignment)
+	}
+	switch {
+	case class == ClassGuest, class == ClassUser, class == ClassToken:
+	case strings.HasPrefix(class, planPrefix) && len(class) > len(planPrefix):
+	default:
+		return "", Policy{}, fmt.Errorf("rate limit class %q must be guest, user, token or plan:<name>", class)
+	}
+
+	limitText, windowText, ok := strings.Cut(value, "/")
+	if !ok {
+		return "", Policy{}, fmt.Errorf("rate limit %q must be class=limit/window", assignment)
+	}
+	limit, err := strconv.Atoi(limitText)
+	if err != nil || limit < 0 {
+		return "", Policy{}, fmt.Errorf("rate limit %q needs a limit of 0 or more", assignment)
+	}
+	window, err := time.ParseDuration(windowText)
+	if err != nil || window < time.Second {
+		return "", Policy{}, fmt.Errorf("rate limit %q needs a window of at least 1s", assignment)
+	}
+
+	return class, Policy{Limit: limit, Window: window}, nil
+}
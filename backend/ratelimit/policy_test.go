@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("/generate-sql,/validate guest=10/1h user=1000/1h; * plan:team=6000/1m ;")
+	if err != nil {
+		t.Fatalf("ParsePolicies failed: %v", err)
+	}
+	if len(policies) != 3 {
+		t.Fatalf("Got %d rules, want 3", len(policies))
+	}
+
+	guest := policies[0]
+	if guest.Class != ClassGuest || guest.Policy != (Policy{Limit: 10, Window: time.Hour}) {
+		t.Errorf("First rule = %+v", guest)
+	}
+	if len(guest.Routes) != 2 || guest.Routes[1] != "/validate" {
+		t.Errorf("First rule routes = %v", guest.Routes)
+	}
+	if plan := policies[2]; plan.Class != PlanClass("team") || plan.Policy.Window != time.Minute {
+		t.Errorf("Third rule = %+v", plan)
+	}
+}
+
+func TestParsePolicies_Invalid(t *testing.T) {
+	specs := []string{
+		"/generate-sql",
+		"generate-sql guest=1/1h",
+		"* admin=1/1h",
+		"* plan:=1/1h",
+		"* guest=-1/1h",
+		"* guest=ten/1h",
+		"* guest=1",
+		"* guest=1/100ms",
+		"* guest",
+	}
+	for _, spec := range specs {
+		if _, err := ParsePolicies(spec); err == nil {
+			t.Errorf("ParsePolicies(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestPolicies_Lookup(t *testing.T) {
+	policies, err := ParsePolicies("/api/v1/imports* user=10/1m; * user=100/1m token=50/1m; /generate-sql guest=3/24h")
+	if err != nil {
+		t.Fatalf("ParsePolicies failed: %v", err)
+	}
+
+	tests := []struct {
+		route   string
+		classes []string
+		limit   int
+		found   bool
+	}{
+		{"/api/v1/imports/get", []string{ClassUser}, 10, true},
+		{"/api/v1/jobs", []string{ClassUser}, 100, true},
+		{"/api/v1/imports/get", []string{ClassToken, ClassUser}, 50, true},
+		{"/generate-sql", []string{ClassGuest}, 3, true},
+		{"/validate", []string{ClassGuest}, 0, false},
+		{"/generate-sql", []string{PlanClass("team"), ClassUser}, 100, true},
+	}
+	for _, tt := range tests {
+		rule, ok := policies.Lookup(tt.route, tt.classes...)
+		if ok != tt.found || rule.Policy.Limit != tt.limit {
+			t.Errorf("Lookup(%s, %v) = %d, %v; want %d, %v", tt.route, tt.classes, rule.Policy.Limit, ok, tt.limit, tt.found)
+		}
+	}
+}
+
+func TestPolicies_HasPlans(t *testing.T) {
+	policies, _ := ParsePolicies("/api/v1/* plan:team=600/1m; * user=100/1m")
+	if !policies.HasPlans("/api/v1/imports") {
+		t.Error("Plan rule not found for a matching route")
+	}
+	if policies.HasPlans("/generate-sql") {
+		t.Error("Plan rule found for another route")
+	}
+}
+
+func TestRule_KeySharedAcrossRoutes(t *testing.T) {
+	rule := Rule{Routes: []string{"/a", "/b"}, Class: ClassGuest}
+	other := Rule{Routes: []string{"/a"}, Class: ClassGuest}
+	if rule.Key("ip:1") == other.Key("ip:1") {
+		t.Error("Rules with different routes share a bucket")
+	}
+	if rule.Key("ip:1") == rule.Key("ip:2") {
+		t.Error("Callers share a bucket")
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, so limits
+// hold across restarts and are shared by every replica. Each request is a
+// single upsert that only advances the bucket while it has requests left.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore on db
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Allow implements Store
+func (s *PostgresStore) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	if policy.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	interval := policy.interval().Seconds()
+	window := policy.Window.Seconds()
+
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tat)
+		VALUES ($1, NOW() + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE
+		SET tat = GREATEST(b.tat, NOW()) + make_interval(secs => $2)
+		WHERE GREATEST(b.tat, NOW()) + make_interval(secs => $2) <= NOW() + make_interval(secs => $3)
+		RETURNING EXTRACT(EPOCH FROM tat - NOW())::float8
+	`
+
+	var next float64
+	err := s.db.QueryRowContext(ctx, query, key, interval, window).Scan(&next)
+	if err == nil {
+		return conforming(seconds(next), policy), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Result{}, fmt.Errorf("failed to take from rate limit bucket: %w", err)
+	}
+
+	// The bucket is empty and was left unchanged
+	var ahead float64
+	query = `SELECT EXTRACT(EPOCH FROM GREATEST(tat, NOW()) - NOW())::float8 FROM rate_limit_buckets WHERE key = $1`
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&ahead); err != nil {
+		return Result{}, fmt.Errorf("failed to get rate limit bucket: %w", err)
+	}
+	return exceeded(seconds(ahead), policy), nil
+}
+
+// DeleteExpired deletes buckets that are full again, which behave like
+// missing ones, and returns how many were deleted
+func (s *PostgresStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE tat < NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete rate limit buckets: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// seconds converts seconds as returned by Postgres to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
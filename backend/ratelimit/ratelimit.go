@@ -0,0 +1,96 @@
+// Package ratelimit limits how often callers may make requests with the
+// generic cell rate algorithm (GCRA): a token bucket that keeps a single
+// timestamp per key, the theoretical arrival time of the next request. A
+// policy of n requests per window lets a full bucket absorb a burst of n
+// requests and refills it evenly over the window.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Policy allows Limit requests per Window. A limit of 0 means unlimited.
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Unlimited reports whether the policy lets every request through
+func (p Policy) Unlimited() bool {
+	return p.Limit <= 0 || p.Window <= 0
+}
+
+// interval is how long the bucket takes to regain one request
+func (p Policy) interval() time.Duration {
+	return p.Window / time.Duration(p.Limit)
+}
+
+// String formats the policy as in the RateLimit-Policy header, e.g.
+// "100;w=3600"
+func (p Policy) String() string {
+	return strconv.Itoa(p.Limit) + ";w=" + strconv.FormatInt(int64(p.Window/time.Second), 10)
+}
+
+// Describe formats the policy for people, e.g. "100 requests per 1h0m0s"
+func (p Policy) Describe() string {
+	noun := "requests"
+	if p.Limit == 1 {
+		noun = "request"
+	}
+	return fmt.Sprintf("%d %s per %s", p.Limit, noun, p.Window)
+}
+
+// Result is the outcome of a request against a policy
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           // requests left in the bucket
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// Store keeps the buckets of a limiter
+type Store interface {
+	// Allow takes one request from the bucket of key under policy, if it
+	// has one left
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// conforming is the result of an allowed request after which the
+// theoretical arrival time is next ahead of now
+func conforming(next time.Duration, p Policy) Result {
+	// Stores round timestamps, so a bucket that refilled all but a rounding
+	// error still counts the request as regained
+	remaining := int((p.Window - next + time.Millisecond) / p.interval())
+	return Result{
+		Allowed:   true,
+		Limit:     p.Limit,
+		Remaining: max(0, min(remaining, p.Limit-1)),
+		Reset:     next,
+	}
+}
+
+// exceeded is the result of a denied request while the theoretical arrival
+// time is ahead of now
+func exceeded(ahead time.Duration, p Policy) Result {
+	return Result{
+		Limit:      p.Limit,
+		Reset:      ahead,
+		RetryAfter: max(0, ahead+p.interval()-p.Window),
+	}
+}
+
+// evaluate applies the algorithm to a bucket whose theoretical arrival time
+// is ahead of now, zero or negative for a full bucket. It returns the
+// result and how far ahead the theoretical arrival time is afterwards.
+func evaluate(ahead time.Duration, p Policy) (Result, time.Duration) {
+	ahead = max(0, ahead)
+	next := ahead + p.interval()
+	if next > p.Window {
+		return exceeded(ahead, p), ahead
+	}
+	return conforming(next, p), next
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores objects as files below a root directory
+type Local struct {
+	root string
+}
+
+// NewLocal creates a local-disk backend rooted at dir, creating it if needed
+func NewLocal(dir string) (*Local, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Local{root: root}, nil
+}
+
+// path maps a key to a file below the root
+func (l *Local) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file and renames it into place, so readers
+// never see a partially written object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	n, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return n, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return n, nil
+}
+
+// Get opens the file of an object
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// GetRange opens the file of an object positioned at offset
+func (l *Local) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := l.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	f := rc.(*os.File)
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return limitedFile{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+// Size returns the size of the file of an object
+func (l *Local) Size(ctx context.Context, key string) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Delete removes the file of an object
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeletePrefix removes a directory of objects; the prefix must end at a
+// key segment boundary (e.g. "uploads/<id>")
+func (l *Local) DeletePrefix(ctx context.Context, prefix string) error {
+	path, err := l.path(prefix)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// limitedFile closes the file under a limited reader
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// contextReader stops a copy when the context is canceled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
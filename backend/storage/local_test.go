@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocal_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+
+	n, err := backend.Put(ctx, "uploads/a/data", strings.NewReader("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Put returned %d, %v", n, err)
+	}
+
+	rc, err := backend.Get(ctx, "uploads/a/data")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(content) != "hello" {
+		t.Errorf("Expected hello, got %q", content)
+	}
+
+	if err := backend.DeletePrefix(ctx, "uploads/a"); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if _, err := backend.Get(ctx, "uploads/a/data"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := backend.Delete(ctx, "uploads/a/data"); err != nil {
+		t.Errorf("Deleting a missing object should succeed, got %v", err)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"", "/abs", "a/../b", "a//b", "a/./b", `a\b`} {
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("Expected %q to be rejected", key)
+		}
+	}
+	if err := ValidateKey("uploads/123/parts/0-abc"); err != nil {
+		t.Errorf("Expected a valid key, got %v", err)
+	}
+}
+
+func TestConcatReader(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+
+	for key, content := range map[string]string{"p/1": "abc", "p/2": "", "p/3": "def"} {
+		if _, err := backend.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	rc := ConcatReader(ctx, backend, []string{"p/1", "p/2", "p/3"})
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil || string(content) != "abcdef" {
+		t.Errorf("Expected abcdef, got %q (%v)", content, err)
+	}
+
+	if _, err := io.ReadAll(ConcatReader(ctx, backend, []string{"p/missing"})); err == nil {
+		t.Error("Expected an error for a missing part")
+	}
+}
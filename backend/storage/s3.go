@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config configures an S3-compatible backend
+type S3Config struct {
+	// Endpoint is the service URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000 for MinIO
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as /bucket/key instead of
+	// bucket.host/key; most self-hosted services need it
+	PathStyle bool
+}
+
+// S3 stores objects in a bucket of an S3-compatible service, signing
+// requests with AWS Signature Version 4
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 creates an S3 backend
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint and bucket are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 access key ID and secret access key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+
+	return &S3{
+		config:   cfg,
+		endpoint: endpoint,
+		client:   &http.Client{},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of an object, or of the bucket for an empty key
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		u.Path = u.Path + "/" + s.config.Bucket
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+	}
+	u.Path += "/" + key
+	u.RawPath = u.Path[:len(u.Path)-len(key)] + s3EscapePath(key)
+	return &u
+}
+
+// do signs and sends a request
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	s.sign(req, payloadHash, s.now())
+	return s.client.Do(req)
+}
+
+// Put spools the object to a temporary file first: a single PUT needs the
+// length and hash of the body up front
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if err := ValidateKey(key); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp("", "s3-put-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return n, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return n, err
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), tmp, n, hex.EncodeToString(hash.Sum(nil)), nil)
+	if err != nil {
+		return n, fmt.Errorf("failed to store object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return n, s3Error("store object", resp)
+	}
+	return n, nil
+}
+
+// Get opens an object
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+// GetRange opens part of an object with a Range request
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	switch {
+	case length >= 0:
+		if length == 0 {
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, 0, emptyPayloadHash, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Reading at the very end of an object yields nothing
+		resp.Body.Close()
+		return io.NopCloser(strings.NewReader("")), nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("get object", resp)
+	}
+}
+
+// Size returns the Content-Length of a HEAD request
+func (s *S3) Size(ctx context.Context, key string) (int64, error) {
+	if err := ValidateKey(key); err != nil {
+		return 0, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat object: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, ErrNotFound
+	default:
+		return 0, fmt.Errorf("failed to stat object: unexpected status %s", resp.Status)
+	}
+}
+
+// Delete removes an object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error("delete object", resp)
+	}
+}
+
+// DeletePrefix deletes the object named prefix and every object below
+// prefix + "/", listing them page by page
+func (s *S3) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := ValidateKey(prefix); err != nil {
+		return err
+	}
+	if err := s.Delete(ctx, prefix); err != nil {
+		return err
+	}
+
+	token := ""
+	for {
+		keys, next, err := s.list(ctx, prefix+"/", token)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := s.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// listBucketResult is the part of a ListObjectsV2 response we use
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns one page of keys starting with prefix and the token of the
+// next page, if any
+func (s *S3) list(ctx context.Context, prefix, token string) ([]string, string, error) {
+	u := s.objectURL("")
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	u.RawQuery = s3EscapeQuery(query)
+
+	resp, err := s.do(ctx, http.MethodGet, u, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list objects: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", s3Error("list objects", resp)
+	}
+
+	var result listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode object listing: %w", err)
+	}
+
+	keys := make([]string, len(result.Contents))
+	for i, c := range result.Contents {
+		keys[i] = c.Key
+	}
+	if !result.IsTruncated {
+		return keys, "", nil
+	}
+	return keys, result.NextContinuationToken, nil
+}
+
+// sign adds AWS Signature Version 4 headers; every header already set on
+// the request is signed along with the host
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3EscapeQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), day)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but unreserved characters, as
+// Signature Version 4 requires
+func s3Escape(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath escapes each segment of a key
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3EscapeQuery builds a canonical query string, sorted by name
+func s3EscapeQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Error describes an unexpected response, including the service's error code
+func s3Error(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var parsed struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(bytes.TrimSpace(body), &parsed) == nil && parsed.Code != "" {
+		return fmt.Errorf("failed to %s: %s (%s)", action, parsed.Code, parsed.Message)
+	}
+	return fmt.Errorf("failed to %s: unexpected status %s", action, resp.Status)
+}
@@ -0,0 +1,158 @@
+// Package storage stores uploaded files, generated SQL and schema content as
+// objects addressed by slash-separated keys, independent of where the bytes
+// end up (local disk or an S3-compatible service).
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Backend is an object store
+type Backend interface {
+	// Put stores everything read from r under key, replacing any existing
+	// object, and returns the number of bytes written
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get opens the object for reading; the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange opens length bytes of the object starting at offset; a
+	// negative length reads to the end
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Size returns the size of the object in bytes
+	Size(ctx context.Context, key string) (int64, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix removes every object whose key starts with prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// ValidateKey checks that a key is a relative, slash-separated path
+// without empty, "." or ".." segments
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty storage key")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
+
+// ConcatReader reads the given objects one after another, opening each only
+// when the previous one is exhausted
+func ConcatReader(ctx context.Context, backend Backend, keys []string) io.ReadCloser {
+	return &concatReader{ctx: ctx, backend: backend, keys: keys}
+}
+
+type concatReader struct {
+	ctx     context.Context
+	backend Backend
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *concatReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := r.backend.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to open %s: %w", r.keys[0], err)
+			}
+			r.current = rc
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *concatReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// NewReadSeeker returns a seekable reader over an object of a known size,
+// e.g. for http.ServeContent. Each read after a seek opens a new ranged
+// read, so serving a byte range never fetches the whole object.
+func NewReadSeeker(ctx context.Context, backend Backend, key string, size int64) io.ReadSeekCloser {
+	return &readSeeker{ctx: ctx, backend: backend, key: key, size: size}
+}
+
+type readSeeker struct {
+	ctx     context.Context
+	backend Backend
+	key     string
+	size    int64
+	offset  int64
+	current io.ReadCloser
+}
+
+func (r *readSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.current == nil {
+		rc, err := r.backend.GetRange(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.current = rc
+	}
+
+	n, err := r.current.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		target = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if target < 0 {
+		return 0, fmt.Errorf("negative position %d", target)
+	}
+
+	if target != r.offset && r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+	r.offset = target
+	return target, nil
+}
+
+func (r *readSeeker) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// The last six digits of the RFC 6238 appendix B SHA-1 values
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code failed: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	current, _ := Code(secret, Step(now))
+	step, ok := Validate(secret, current, now, 1)
+	if !ok || step != Step(now) {
+		t.Fatalf("Current code rejected (step %d, ok %v)", step, ok)
+	}
+
+	previous, _ := Code(secret, Step(now)-1)
+	if step, ok := Validate(secret, previous, now, 1); !ok || step != Step(now)-1 {
+		t.Errorf("Code of the previous step rejected within skew")
+	}
+	if _, ok := Validate(secret, previous, now, 0); ok {
+		t.Error("Code of the previous step accepted without skew")
+	}
+
+	old, _ := Code(secret, Step(now)-5)
+	if _, ok := Validate(secret, old, now, 1); ok {
+		t.Error("Old code accepted")
+	}
+
+	spaced := current[:3] + " " + current[3:]
+	if _, ok := Validate(secret, spaced, now, 1); !ok {
+		t.Error("Code typed with a space rejected")
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(secret, code, now, 1); ok {
+			t.Errorf("Malformed code %q accepted", code)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	b, _ := GenerateSecret()
+	if a == b {
+		t.Error("Secrets repeat")
+	}
+	if len(a) != 32 || strings.Contains(a, "=") {
+		t.Errorf("Unexpected secret format %q", a)
+	}
+
+	// Secrets typed by hand in lower case and groups still work
+	typed := strings.ToLower(a[:4] + " " + a[4:])
+	code, err := Code(typed, 1)
+	if err != nil {
+		t.Fatalf("Code with typed secret failed: %v", err)
+	}
+	if want, _ := Code(a, 1); code != want {
+		t.Errorf("Typed secret gives %s, want %s", code, want)
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Expected an error for an invalid secret")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("DB Importer", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("URI does not parse: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+	if parsed.Path != "/DB Importer:jane@example.com" {
+		t.Errorf("Unexpected label %q", parsed.Path)
+	}
+
+	q := parsed.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "DB Importer" ||
+		q.Get("digits") != "6" || q.Get("period") != "30" || q.Get("algorithm") != "SHA1" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+}
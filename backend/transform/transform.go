@@ -0,0 +1,220 @@
+// Package transform applies the cell transformations offered by the mapping
+// step (uppercase, formatDate, excelDate, ...) on the server, so a recorded
+// import can be replayed against a new data file. The behaviour mirrors the
+// frontend implementation in utils/transformations.ts.
+package transform
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Names of the supported transformations
+const (
+	None               = "none"
+	Uppercase          = "uppercase"
+	Lowercase          = "lowercase"
+	Trim               = "trim"
+	Capitalize         = "capitalize"
+	RemoveSpaces       = "removeSpaces"
+	RemoveSpecialChars = "removeSpecialChars"
+	FormatPhone        = "formatPhone"
+	FormatEmail        = "formatEmail"
+	ExtractNumbers     = "extractNumbers"
+	ToBoolean          = "toBoolean"
+	ToNumber           = "toNumber"
+	FormatDate         = "formatDate"
+	ExcelDate          = "excelDate"
+)
+
+var (
+	whitespace   = regexp.MustCompile(`\s+`)
+	specialChars = regexp.MustCompile(`[^a-zA-Z0-9\s]`)
+	nonDigits    = regexp.MustCompile(`[^\d]`)
+	digitRuns    = regexp.MustCompile(`\d+`)
+	nonNumeric   = regexp.MustCompile(`[^\d.-]`)
+	leadingFloat = regexp.MustCompile(`^-?(\d+(\.\d*)?|\.\d+)`)
+
+	isoDate   = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})`)
+	slashDate = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})`)
+	dashDate  = regexp.MustCompile(`^(\d{1,2})-(\d{1,2})-(\d{4})`)
+)
+
+// transformations maps each name to its function on the cell text
+var transformations = map[string]func(string) interface{}{
+	None:      func(v string) interface{} { return v },
+	Uppercase: func(v string) interface{} { return strings.ToUpper(v) },
+	Lowercase: func(v string) interface{} { return strings.ToLower(v) },
+	Trim:      func(v string) interface{} { return strings.TrimSpace(v) },
+	Capitalize: func(v string) interface{} {
+		words := strings.Split(strings.ToLower(v), " ")
+		for i, w := range words {
+			if w != "" {
+				r := []rune(w)
+				r[0] = unicode.ToUpper(r[0])
+				words[i] = string(r)
+			}
+		}
+		return strings.Join(words, " ")
+	},
+	RemoveSpaces:       func(v string) interface{} { return whitespace.ReplaceAllString(v, "") },
+	RemoveSpecialChars: func(v string) interface{} { return specialChars.ReplaceAllString(v, "") },
+	FormatPhone:        func(v string) interface{} { return nonDigits.ReplaceAllString(v, "") },
+	FormatEmail:        func(v string) interface{} { return strings.TrimSpace(strings.ToLower(v)) },
+	ExtractNumbers:     func(v string) interface{} { return strings.Join(digitRuns.FindAllString(v, -1), "") },
+	ToBoolean: func(v string) interface{} {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "1", "on":
+			return true
+		}
+		return false
+	},
+	ToNumber: func(v string) interface{} {
+		n, ok := parseLeadingFloat(nonNumeric.ReplaceAllString(v, ""))
+		if !ok {
+			return float64(0)
+		}
+		return n
+	},
+	FormatDate: func(v string) interface{} {
+		if t, ok := parseSmartDate(v); ok {
+			return t.Format("2006-01-02")
+		}
+		return v
+	},
+	ExcelDate: func(v string) interface{} {
+		if t, ok := parseExcelDate(v); ok {
+			return t.Format("2006-01-02 15:04:05")
+		}
+		return v
+	},
+}
+
+// Supported reports whether name is a known transformation
+func Supported(name string) bool {
+	_, ok := transformations[name]
+	return ok
+}
+
+// Apply transforms a cell value; nil stays nil
+func Apply(name string, value interface{}) (interface{}, error) {
+	fn, ok := transformations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transformation %q", name)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return fn(fmt.Sprint(value)), nil
+}
+
+// ParseApplied reads the "field: transformation" entries recorded in import
+// metadata into a field -> transformation map, skipping "none"
+func ParseApplied(entries []string) (map[string]string, error) {
+	applied := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid transformation entry %q (expected \"field: transformation\")", entry)
+		}
+		field, name := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if !Supported(name) {
+			return nil, fmt.Errorf("unknown transformation %q for field %q", name, field)
+		}
+		if name != None {
+			applied[field] = name
+		}
+	}
+	return applied, nil
+}
+
+// parseLeadingFloat parses the number at the start of s like JavaScript's
+// parseFloat
+func parseLeadingFloat(s string) (float64, bool) {
+	match := leadingFloat.FindString(s)
+	if match == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(match, "."), 64)
+	return n, err == nil
+}
+
+// parseSmartDate tries ISO, DD/MM/YYYY (then MM/DD/YYYY), DD-MM-YYYY and a
+// few written-out formats, returning the date at midnight UTC
+func parseSmartDate(value string) (time.Time, bool) {
+	cleaned := strings.TrimSpace(value)
+	if cleaned == "" {
+		return time.Time{}, false
+	}
+
+	if m := isoDate.FindStringSubmatch(cleaned); m != nil {
+		return date(atoi(m[1]), atoi(m[2]), atoi(m[3])), true
+	}
+
+	if m := slashDate.FindStringSubmatch(cleaned); m != nil {
+		first, second, year := atoi(m[1]), atoi(m[2]), atoi(m[3])
+		if first < 1 || first > 31 || second < 1 || second > 12 {
+			return time.Time{}, false
+		}
+		// European order first, then US order if the fields can be swapped
+		if t := date(year, second, first); t.Day() == first && int(t.Month()) == second {
+			return t, true
+		}
+		if first <= 12 && second <= 31 {
+			if t := date(year, first, second); t.Day() == second && int(t.Month()) == first {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	if m := dashDate.FindStringSubmatch(cleaned); m != nil {
+		return date(atoi(m[3]), atoi(m[2]), atoi(m[1])), true
+	}
+
+	for _, layout := range []string{time.RFC3339, time.RFC1123, "Jan 2, 2006", "January 2, 2006", "2 Jan 2006", "2 January 2006"} {
+		if t, err := time.Parse(layout, cleaned); err == nil {
+			return date(t.Year(), int(t.Month()), t.Day()), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseExcelDate converts an Excel serial date (days since 1900-01-01,
+// counting Excel's fictitious 1900-02-29) to a time. Integers between
+// 1900 and 2100 are taken as a year and become January 1st.
+func parseExcelDate(value string) (time.Time, bool) {
+	num, ok := parseLeadingFloat(strings.TrimSpace(value))
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if num >= 1900 && num <= 2100 && num == math.Trunc(num) {
+		return date(int(num), 1, 1), true
+	}
+	if num < 1 || num > 100000 {
+		return time.Time{}, false
+	}
+
+	days := num - 1
+	if num > 60 {
+		days = num - 2
+	}
+	epoch := time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ms := int64(math.Round(days * 24 * 60 * 60 * 1000))
+	return epoch.Add(time.Duration(ms) * time.Millisecond), true
+}
+
+func date(year, month, day int) time.Time {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,63 @@
+package transform
+
+import "testing"
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected interface{}
+	}{
+		{Uppercase, "abc", "ABC"},
+		{Capitalize, "hELLO wORLD", "Hello World"},
+		{RemoveSpaces, " a b\tc ", "abc"},
+		{RemoveSpecialChars, "a-b_c!", "abc"},
+		{FormatPhone, "+1 (555) 010-99", "155501099"},
+		{FormatEmail, " John@Example.COM ", "john@example.com"},
+		{ExtractNumbers, "ab12cd34", "1234"},
+		{ToBoolean, "Yes", true},
+		{ToBoolean, "maybe", false},
+		{ToNumber, "$1,234.50", 1234.5},
+		{ToNumber, "n/a", float64(0)},
+		{FormatDate, "2024-03-05T10:00:00", "2024-03-05"},
+		{FormatDate, "31/12/2023", "2023-12-31"},
+		{FormatDate, "12/31/2023", "12/31/2023"}, // as in the frontend, the month must come second
+		{FormatDate, "not a date", "not a date"},
+		{ExcelDate, "45000", "2023-03-15 00:00:00"},
+		{ExcelDate, "45000.5", "2023-03-15 12:00:00"},
+		{ExcelDate, "2023", "2023-01-01 00:00:00"},
+		{ExcelDate, 30, "1900-01-30 00:00:00"},
+		{Trim, nil, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Apply(tt.name, tt.input)
+		if err != nil {
+			t.Fatalf("Apply(%s, %v) failed: %v", tt.name, tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Apply(%s, %v) = %v (%T), want %v (%T)", tt.name, tt.input, got, got, tt.expected, tt.expected)
+		}
+	}
+
+	if _, err := Apply("rot13", "x"); err == nil {
+		t.Error("Expected an error for an unknown transformation")
+	}
+}
+
+func TestParseApplied(t *testing.T) {
+	applied, err := ParseApplied([]string{"email: formatEmail", "created_at:excelDate", "name: none"})
+	if err != nil {
+		t.Fatalf("ParseApplied failed: %v", err)
+	}
+	if len(applied) != 2 || applied["email"] != FormatEmail || applied["created_at"] != ExcelDate {
+		t.Errorf("Unexpected transformations: %v", applied)
+	}
+
+	if _, err := ParseApplied([]string{"email: shout"}); err == nil {
+		t.Error("Expected an error for an unknown transformation")
+	}
+	if _, err := ParseApplied([]string{"email"}); err == nil {
+		t.Error("Expected an error for an entry without a transformation")
+	}
+}
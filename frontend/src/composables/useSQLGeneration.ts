@@ -105,7 +105,8 @@ export function useSQLGeneration(
           table: store.selectedTable.name,
           mapping: localMapping.value,
           rows: mappedRows,
-          fields: fields
+          fields: fields,
+          dialect: store.dialect ?? undefined
         })
       })
 
@@ -216,7 +217,8 @@ export function useSQLGeneration(
           table: store.selectedTable.name,
           mapping: localMapping.value,
           rows: mappedRows,
-          fields: fields
+          fields: fields,
+          dialect: store.dialect ?? undefined
         })
       })
 
@@ -274,7 +276,9 @@ export function useSQLGeneration(
               sourceFileName: filename,
               mappingSummary: localMapping.value,
               transformations: appliedTransformations.length > 0 ? appliedTransformations : undefined,
-              databaseType: 'mysql', // Could be detected from schema
+              databaseType: store.dialect ?? undefined,
+              sourceHeaders: store.excelHeaders,
+              fields: store.selectedTable.fields,
               validationErrors: serverValidationErrors.value.length > 0 ? serverValidationErrors.value : undefined,
               validationWarnings: validationStats.value?.warningCount ? ['Some data validation warnings occurred'] : undefined
             }
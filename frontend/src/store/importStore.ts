@@ -17,6 +17,9 @@ export interface ImportMetadata {
   validationErrors?: string[]
   validationWarnings?: string[]
   extra?: Record<string, JsonValue>
+  sourceHeaders?: string[]
+  fields?: { name: string; type: string; nullable: boolean; key?: boolean }[]
+  previousImportId?: string
 }
 
 export interface Import {
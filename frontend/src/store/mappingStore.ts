@@ -11,6 +11,9 @@ export interface Table {
   fields: Field[]
 }
 
+// SQL dialect a schema was parsed as, detected by /parse-schema or forced
+export type SQLDialect = 'mysql' | 'postgresql' | 'sqlserver' | 'sqlite'
+
 // Type for cell values in Excel/CSV data
 export type CellValue = string | number | boolean | null | undefined
 
@@ -21,6 +24,7 @@ export interface ValidationResult {
 
 export interface MappingState {
   tables: Table[]
+  dialect: SQLDialect | null
   selectedTable: Table | null
   excelData: CellValue[][]
   excelHeaders: string[]
@@ -62,6 +66,7 @@ function saveToStorage(state: MappingState): void {
       timestamp: new Date().toISOString(),
       state: {
         tables: state.tables,
+        dialect: state.dialect,
         selectedTable: state.selectedTable,
         excelHeaders: state.excelHeaders,
         // Don't store full excel data to avoid storage limits
@@ -83,6 +88,7 @@ export const useMappingStore = defineStore('mapping', {
     if (stored) {
       return {
         tables: stored.tables || [],
+        dialect: stored.dialect || null,
         selectedTable: stored.selectedTable || null,
         excelData: [], // Excel data is not persisted due to size
         excelHeaders: stored.excelHeaders || [],
@@ -94,6 +100,7 @@ export const useMappingStore = defineStore('mapping', {
 
     return {
       tables: [],
+      dialect: null,
       selectedTable: null,
       excelData: [],
       excelHeaders: [],
@@ -109,6 +116,11 @@ export const useMappingStore = defineStore('mapping', {
       this.persist()
     },
 
+    setDialect(dialect: SQLDialect | null) {
+      this.dialect = dialect
+      this.persist()
+    },
+
     selectTable(tableName: string) {
       this.selectedTable = this.tables.find(t => t.name === tableName) || null
       this.persist()
@@ -154,6 +166,7 @@ export const useMappingStore = defineStore('mapping', {
 
     reset() {
       this.tables = []
+      this.dialect = null
       this.selectedTable = null
       this.excelData = []
       this.excelHeaders = []
@@ -2,7 +2,7 @@ import { defineStore } from 'pinia'
 import { nextTick } from 'vue'
 import { useAuthStore } from './authStore'
 import { useMappingStore } from './mappingStore'
-import type { Table, CellValue } from './mappingStore'
+import type { Table, CellValue, SQLDialect } from './mappingStore'
 import { apiClient, ApiError } from '../utils/apiClient'
 import type {
   SaveSchemaRequest,
@@ -34,7 +34,7 @@ export const useWorkflowSessionStore = defineStore('workflowSession', {
     /**
      * Save schema content and parsed tables (Step 1)
      */
-    async saveSchema(schemaContent: string, tables: Table[]): Promise<void> {
+    async saveSchema(schemaContent: string, tables: Table[], dialect?: SQLDialect): Promise<void> {
       const authStore = useAuthStore()
 
       // Only save to backend if user is authenticated
@@ -48,7 +48,8 @@ export const useWorkflowSessionStore = defineStore('workflowSession', {
       try {
         const requestBody: SaveSchemaRequest = {
           schemaContent,
-          tables
+          tables,
+          dialect
         }
 
         const response = await apiClient.post<{ data: SaveSchemaResponse }>('/api/v1/workflow/session/schema', requestBody)
@@ -218,6 +219,10 @@ export const useWorkflowSessionStore = defineStore('workflowSession', {
           mappingStore.setTables(session.schemaTables)
         }
 
+        if (session.dialect) {
+          mappingStore.setDialect(session.dialect)
+        }
+
         if (session.selectedTableName) {
           mappingStore.selectTable(session.selectedTableName)
         }
@@ -7,7 +7,7 @@
 
 import type { User } from '../store/authStore'
 import type { Import, ImportMetadata, ImportStats } from '../store/importStore'
-import type { Table } from '../store/mappingStore'
+import type { Table, SQLDialect } from '../store/mappingStore'
 import type { CellValue } from '../store/mappingStore'
 
 // ============================================================================
@@ -153,6 +153,7 @@ export interface DeleteOldImportsResponse {
 export interface SaveSchemaRequest {
   schemaContent: string
   tables: Table[]
+  dialect?: SQLDialect
 }
 
 export interface SaveSchemaResponse {
@@ -193,6 +194,7 @@ export interface WorkflowSession {
   currentStep: number
   schemaContent?: string
   schemaTables?: Table[]
+  dialect?: SQLDialect
   selectedTableName?: string
   dataFileName?: string
   dataHeaders?: string[]
@@ -209,6 +211,7 @@ export interface GetSessionResponse {
   currentStep: number
   schemaContent?: string
   schemaTables?: Table[]
+  dialect?: SQLDialect
   selectedTableName?: string
   dataFileName?: string
   dataHeaders?: string[]